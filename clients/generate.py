@@ -0,0 +1,194 @@
+#!/usr/bin/env python3
+"""Generates the models and operations of the Python and TypeScript clients
+from openapi.json. The transport each client's operations call, with its
+retries, idempotency keys and circuit breaking, is written by hand.
+
+    python3 clients/generate.py
+"""
+
+import json
+import os
+import re
+
+HERE = os.path.dirname(os.path.abspath(__file__))
+HEADER = "Code generated by clients/generate.py from openapi.json. DO NOT EDIT."
+METHODS = ("get", "put", "post", "delete", "patch")
+
+
+def load_spec():
+    with open(os.path.join(HERE, "openapi.json")) as f:
+        return json.load(f)
+
+
+def ref_name(schema):
+    return schema["$ref"].rsplit("/", 1)[-1]
+
+
+def snake(name):
+    return re.sub(r"(?<!^)(?=[A-Z])", "_", name).lower()
+
+
+def operations(spec):
+    """Yields the spec's operations in path order"""
+    for path in sorted(spec["paths"]):
+        for method in METHODS:
+            operation = spec["paths"][path].get(method)
+            if operation:
+                yield path, method.upper(), operation
+
+
+def success_schema(operation):
+    for status in sorted(operation["responses"]):
+        if status.startswith("2"):
+            content = operation["responses"][status].get("content", {})
+            if "application/json" in content:
+                return content["application/json"]["schema"]
+    return None
+
+
+def body_schema(operation):
+    body = operation.get("requestBody")
+    return body["content"]["application/json"]["schema"] if body else None
+
+
+# Python
+
+def py_type(schema):
+    if schema is None:
+        return "None"
+    if "$ref" in schema:
+        return ref_name(schema)
+    kind = schema.get("type")
+    if kind == "array":
+        return "List[%s]" % py_type(schema["items"])
+    if kind == "object":
+        extra = schema.get("additionalProperties")
+        return "Dict[str, %s]" % (py_type(extra) if isinstance(extra, dict) else "Any")
+    return {"string": "str", "integer": "int", "number": "float", "boolean": "bool"}.get(kind, "Any")
+
+
+def generate_python(spec):
+    lines = ['"""%s"""' % HEADER, "", "from typing import Any, Dict, List, Optional, TypedDict", "from urllib.parse import quote", ""]
+    for name, schema in spec["components"]["schemas"].items():
+        required = set(schema.get("required", []))
+        properties = schema.get("properties", {})
+        # Optional fields go in a base without totality
+        optional = [field for field in properties if field not in required]
+        base = "TypedDict"
+        if optional:
+            base = "_%sOptional" % name
+            lines += ["", "class %s(TypedDict, total=False):" % base]
+            for field in optional:
+                lines.append("    %s: %s" % (field, py_type(properties[field])))
+            lines.append("")
+        lines += ["", "class %s(%s):" % (name, base)]
+        lines.append('    """%s"""' % schema.get("description", name))
+        for field in properties:
+            if field in required:
+                lines.append("    %s: %s" % (field, py_type(properties[field])))
+        lines.append("")
+
+    lines += ["", "class Operations:", '    """The API\'s operations, sent with _request"""', ""]
+    lines += [
+        "    def _request(self, method: str, path: str, query: Optional[Dict[str, Any]] = None, body: Any = None) -> Any:",
+        "        raise NotImplementedError",
+        "",
+    ]
+    for path, method, operation in operations(spec):
+        params = operation.get("parameters", [])
+        path_params = [p for p in params if p["in"] == "path"]
+        query_params = [p for p in params if p["in"] == "query"]
+        args = ["self"] + ["%s: str" % p["name"] for p in path_params]
+        body = body_schema(operation)
+        if body:
+            args.append("body: %s" % py_type(body))
+        if query_params:
+            args.append("*")
+            args += ["%s: Optional[%s] = None" % (p["name"], py_type(p["schema"])) for p in query_params]
+        result = success_schema(operation)
+        lines.append("    def %s(%s) -> %s:" % (snake(operation["operationId"]), ", ".join(args), py_type(result)))
+        lines.append('        """%s"""' % operation["summary"])
+        formatted = path
+        for p in path_params:
+            formatted = formatted.replace("{%s}" % p["name"], "{_quote(%s)}" % p["name"])
+        call = ['"%s"' % method, ('f"%s"' % formatted) if path_params else ('"%s"' % path)]
+        if query_params:
+            call.append("query={%s}" % ", ".join('"%s": %s' % (p["name"], p["name"]) for p in query_params))
+        if body:
+            call.append("body=body")
+        lines.append("        return self._request(%s)" % ", ".join(call))
+        lines.append("")
+
+    lines += ["", "def _quote(value: str) -> str:", '    return quote(value, safe="")', ""]
+    return "\n".join(lines)
+
+
+# TypeScript
+
+def ts_type(schema):
+    if schema is None:
+        return "void"
+    if "$ref" in schema:
+        return ref_name(schema)
+    kind = schema.get("type")
+    if kind == "array":
+        return "%s[]" % ts_type(schema["items"])
+    if kind == "object":
+        extra = schema.get("additionalProperties")
+        return "Record<string, %s>" % (ts_type(extra) if isinstance(extra, dict) else "unknown")
+    return {"string": "string", "integer": "number", "number": "number", "boolean": "boolean"}.get(kind, "unknown")
+
+
+def generate_typescript(spec):
+    lines = ["// %s" % HEADER, ""]
+    for name, schema in spec["components"]["schemas"].items():
+        required = set(schema.get("required", []))
+        lines.append("/** %s */" % schema.get("description", name))
+        lines.append("export interface %s {" % name)
+        for field, prop in schema.get("properties", {}).items():
+            lines.append("  %s%s: %s;" % (field, "" if field in required else "?", ts_type(prop)))
+        lines += ["}", ""]
+
+    lines += [
+        "export type Query = Record<string, string | number | boolean | undefined>;",
+        "",
+        "/** The API's operations, sent with request */",
+        "export abstract class Operations {",
+        "  protected abstract request<T>(method: string, path: string, query?: Query, body?: unknown, signal?: AbortSignal): Promise<T>;",
+        "",
+    ]
+    for path, method, operation in operations(spec):
+        params = operation.get("parameters", [])
+        path_params = [p for p in params if p["in"] == "path"]
+        query_params = [p for p in params if p["in"] == "query"]
+        args = ["%s: string" % p["name"] for p in path_params]
+        body = body_schema(operation)
+        if body:
+            args.append("body: %s" % ts_type(body))
+        if query_params:
+            args.append("query: { %s } = {}" % "; ".join("%s?: %s" % (p["name"], ts_type(p["schema"])) for p in query_params))
+        args.append("signal?: AbortSignal")
+        result = ts_type(success_schema(operation))
+        formatted = path
+        for p in path_params:
+            formatted = formatted.replace("{%s}" % p["name"], "${encodeURIComponent(%s)}" % p["name"])
+        target = ("`%s`" % formatted) if path_params else ('"%s"' % path)
+        call = ['"%s"' % method, target, "query" if query_params else "undefined", "body" if body else "undefined", "signal"]
+        lines.append("  /** %s */" % operation["summary"])
+        lines.append("  %s(%s): Promise<%s> {" % (operation["operationId"], ", ".join(args), result))
+        lines.append("    return this.request<%s>(%s);" % (result, ", ".join(call)))
+        lines += ["  }", ""]
+    lines[-1] = "}"
+    lines.append("")
+    return "\n".join(lines)
+
+
+def write(path, content):
+    with open(os.path.join(HERE, path), "w") as f:
+        f.write(content)
+
+
+if __name__ == "__main__":
+    spec = load_spec()
+    write("python/hydap_client/_generated.py", generate_python(spec))
+    write("typescript/src/generated.ts", generate_typescript(spec))
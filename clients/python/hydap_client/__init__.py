@@ -0,0 +1,7 @@
+"""Python client of the hydap API. Models and operations are generated from
+clients/openapi.json; the transport is in client.py."""
+
+from ._generated import *  # noqa: F401,F403
+from .client import APIError, BreakerPolicy, CircuitOpenError, Client, RetryPolicy
+
+__version__ = "1.0.0"
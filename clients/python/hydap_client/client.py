@@ -0,0 +1,222 @@
+"""The transport of the Python client. Like pkg/client, it retries failed
+requests with jittered backoff, repeats an idempotency key across the
+retries of a write, keeps a circuit breaker per gateway and sticks to the
+gateway that last answered."""
+
+import json
+import random
+import threading
+import time
+import urllib.error
+import urllib.request
+import uuid
+from dataclasses import dataclass
+from typing import Any, Dict, List, Optional, Sequence
+from urllib.parse import urlencode, urlparse
+
+from ._generated import Operations
+
+IDEMPOTENCY_KEY_HEADER = "Idempotency-Key"
+
+
+class APIError(Exception):
+    """A response with an error status"""
+
+    def __init__(self, status: int, message: str = "", retry_after: float = 0):
+        super().__init__("API error %d%s" % (status, ": " + message if message else ""))
+        self.status = status
+        self.message = message
+        self.retry_after = retry_after
+
+
+class CircuitOpenError(Exception):
+    """Every endpoint's circuit is open"""
+
+
+@dataclass
+class RetryPolicy:
+    """Bounds the attempts of a request. The wait before each retry is drawn
+    uniformly up to base_delay doubled per attempt, capped at max_delay, and
+    is at least a response's Retry-After."""
+
+    max_attempts: int = 4  # Including the first; 1 disables retries
+    base_delay: float = 0.1
+    max_delay: float = 5.0
+
+
+@dataclass
+class BreakerPolicy:
+    """Opens an endpoint's circuit after failures failed attempts in a row.
+    Requests skip an open endpoint for cooldown seconds, after which one
+    trial request decides whether it closes again."""
+
+    failures: int = 5
+    cooldown: float = 30.0
+
+
+@dataclass
+class _Endpoint:
+    base: str
+    failures: int = 0
+    open_until: float = 0.0
+    probing: bool = False
+
+
+_RETRYABLE = {502, 503, 504}
+
+
+class Client(Operations):
+    """A client of the API of one cluster through its gateways. It is safe
+    for concurrent use.
+
+        client = Client(["http://gw1:8088", "http://gw2:8088"], token="hydap_...")
+        client.register_chain({"id": "eth-main", "endpoint": "http://localhost:8545", "protocol": "eth"})
+    """
+
+    def __init__(
+        self,
+        endpoints: Sequence[str],
+        token: Optional[str] = None,
+        retry: Optional[RetryPolicy] = None,
+        breaker: Optional[BreakerPolicy] = None,
+        timeout: float = 30.0,
+    ):
+        if not endpoints:
+            raise ValueError("no API endpoints")
+        self._endpoints: List[_Endpoint] = []
+        for address in endpoints:
+            parsed = urlparse(address)
+            if parsed.scheme not in ("http", "https") or not parsed.netloc:
+                raise ValueError("invalid endpoint %r, want http(s)://host[:port]" % address)
+            self._endpoints.append(_Endpoint(address.rstrip("/")))
+        self._token = token
+        self._retry = retry or RetryPolicy()
+        self._breaker = breaker or BreakerPolicy()
+        self._timeout = timeout
+        self._lock = threading.Lock()
+        self._preferred = 0
+
+    def request(
+        self,
+        method: str,
+        path: str,
+        query: Optional[Dict[str, Any]] = None,
+        body: Any = None,
+        idempotency_key: Optional[str] = None,
+        deadline: Optional[float] = None,
+    ) -> Any:
+        """Sends a request for path, such as /api/agglomerator/chains, and
+        returns its decoded JSON response. Writes carry idempotency_key, or
+        a generated one, on every attempt. deadline is a time.monotonic()
+        after which no attempt starts."""
+        if query:
+            encoded = urlencode({k: v for k, v in query.items() if v is not None})
+            if encoded:
+                path += "?" + encoded
+        payload = None if body is None else json.dumps(body).encode()
+        if idempotency_key is None and method not in ("GET", "HEAD"):
+            idempotency_key = str(uuid.uuid4())
+
+        last_error: Optional[Exception] = None
+        for attempt in range(max(self._retry.max_attempts, 1)):
+            if attempt > 0:
+                self._wait(attempt, last_error, deadline)
+            target = self._pick()
+            if target is None:
+                raise CircuitOpenError("circuit open on every endpoint") from last_error
+            try:
+                return self._send(target, method, path, payload, idempotency_key, deadline)
+            except APIError as err:
+                if not self._retryable(err, attempt, idempotency_key):
+                    raise
+                last_error = err
+            except (urllib.error.URLError, OSError) as err:
+                self._report(target, False)
+                last_error = err
+        assert last_error is not None
+        raise last_error
+
+    # The generated operations send requests with _request
+    def _request(self, method: str, path: str, query: Optional[Dict[str, Any]] = None, body: Any = None) -> Any:
+        return self.request(method, path, query, body)
+
+    def _send(self, target: _Endpoint, method: str, path: str, payload: Optional[bytes], key: Optional[str], deadline: Optional[float]) -> Any:
+        headers = {"Accept": "application/json"}
+        if payload is not None:
+            headers["Content-Type"] = "application/json"
+        if key:
+            headers[IDEMPOTENCY_KEY_HEADER] = key
+        if self._token:
+            headers["Authorization"] = "Bearer " + self._token
+        timeout = self._timeout
+        if deadline is not None:
+            timeout = min(timeout, max(deadline - time.monotonic(), 0.001))
+
+        req = urllib.request.Request(target.base + path, data=payload, headers=headers, method=method)
+        try:
+            with urllib.request.urlopen(req, timeout=timeout) as resp:
+                data = resp.read()
+        except urllib.error.HTTPError as err:
+            error = _api_error(err)
+            # An overloaded gateway, or one shedding load with a
+            # Retry-After, is still up, so its circuit stays closed
+            shedding = err.code == 503 and err.headers.get("Retry-After") is not None
+            self._report(target, shedding or err.code not in _RETRYABLE)
+            raise error from None
+        self._report(target, True)
+        return json.loads(data) if data else None
+
+    @staticmethod
+    def _retryable(err: APIError, attempt: int, key: Optional[str]) -> bool:
+        if err.status in _RETRYABLE or err.status == 429:
+            return True
+        # An earlier attempt may still be running under the same key
+        return err.status == 409 and attempt > 0 and key is not None
+
+    def _pick(self) -> Optional[_Endpoint]:
+        with self._lock:
+            now = time.monotonic()
+            for i in range(len(self._endpoints)):
+                index = (self._preferred + i) % len(self._endpoints)
+                target = self._endpoints[index]
+                if self._breaker.failures <= 0 or target.failures < self._breaker.failures:
+                    self._preferred = index
+                    return target
+                if now >= target.open_until and not target.probing:
+                    target.probing = True
+                    return target
+            return None
+
+    def _report(self, target: _Endpoint, ok: bool) -> None:
+        with self._lock:
+            target.probing = False
+            if ok:
+                target.failures = 0
+                return
+            target.failures += 1
+            if target.failures >= self._breaker.failures:
+                target.open_until = time.monotonic() + self._breaker.cooldown
+            if self._endpoints[self._preferred] is target:
+                self._preferred = (self._preferred + 1) % len(self._endpoints)
+
+    def _wait(self, attempt: int, last_error: Optional[Exception], deadline: Optional[float]) -> None:
+        ceiling = min(self._retry.base_delay * 2 ** (attempt - 1), self._retry.max_delay)
+        delay = random.uniform(0, ceiling)
+        if isinstance(last_error, APIError):
+            delay = max(delay, last_error.retry_after)
+        if deadline is not None and time.monotonic() + delay > deadline:
+            raise TimeoutError("deadline exceeded") from last_error
+        time.sleep(delay)
+
+
+def _api_error(err: urllib.error.HTTPError) -> APIError:
+    message = ""
+    try:
+        message = json.loads(err.read(64 << 10)).get("error", "")
+    except (ValueError, AttributeError):
+        pass
+    try:
+        retry_after = float(err.headers.get("Retry-After", 0))
+    except ValueError:
+        retry_after = 0
+    return APIError(err.code, message, retry_after)
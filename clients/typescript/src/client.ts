@@ -0,0 +1,259 @@
+// The transport of the TypeScript client. Like pkg/client, it retries failed
+// requests with jittered backoff, repeats an idempotency key across the
+// retries of a write, keeps a circuit breaker per gateway and sticks to the
+// gateway that last answered.
+
+import { Operations, Query } from "./generated.js";
+
+export const IDEMPOTENCY_KEY_HEADER = "Idempotency-Key";
+
+/** A response with an error status */
+export class APIError extends Error {
+  constructor(
+    readonly status: number,
+    readonly detail: string = "",
+    readonly retryAfterMs: number = 0,
+  ) {
+    super(`API error ${status}${detail ? ": " + detail : ""}`);
+    this.name = "APIError";
+  }
+}
+
+/** Every endpoint's circuit is open */
+export class CircuitOpenError extends Error {
+  constructor(readonly lastError?: unknown) {
+    super("circuit open on every endpoint");
+    this.name = "CircuitOpenError";
+  }
+}
+
+/**
+ * Bounds the attempts of a request. The wait before each retry is drawn
+ * uniformly up to baseDelayMs doubled per attempt, capped at maxDelayMs, and
+ * is at least a response's Retry-After.
+ */
+export interface RetryPolicy {
+  maxAttempts: number; // Including the first; 1 disables retries
+  baseDelayMs: number;
+  maxDelayMs: number;
+}
+
+/**
+ * Opens an endpoint's circuit after failures failed attempts in a row.
+ * Requests skip an open endpoint for cooldownMs, after which one trial
+ * request decides whether it closes again.
+ */
+export interface BreakerPolicy {
+  failures: number;
+  cooldownMs: number;
+}
+
+export const defaultRetryPolicy: RetryPolicy = { maxAttempts: 4, baseDelayMs: 100, maxDelayMs: 5000 };
+export const defaultBreakerPolicy: BreakerPolicy = { failures: 5, cooldownMs: 30000 };
+
+export interface ClientOptions {
+  token?: string;
+  retry?: RetryPolicy;
+  breaker?: BreakerPolicy;
+  fetch?: typeof fetch;
+}
+
+/** Options of a single request */
+export interface RequestOptions {
+  idempotencyKey?: string;
+  signal?: AbortSignal;
+}
+
+interface Endpoint {
+  base: string;
+  failures: number;
+  openUntil: number;
+  probing: boolean; // A trial request is out on the open circuit
+}
+
+const retryable = new Set([502, 503, 504]);
+
+/**
+ * A client of the API of one cluster through its gateways.
+ *
+ *     const client = new Client(["http://gw1:8088", "http://gw2:8088"], { token: "hydap_..." });
+ *     await client.registerChain({ id: "eth-main", endpoint: "http://localhost:8545", protocol: "eth" });
+ */
+export class Client extends Operations {
+  private readonly endpoints: Endpoint[];
+  private readonly token?: string;
+  private readonly retry: RetryPolicy;
+  private readonly breaker: BreakerPolicy;
+  private readonly fetch: typeof fetch;
+  private preferred = 0; // The endpoint that last answered
+
+  constructor(endpoints: string[], options: ClientOptions = {}) {
+    super();
+    if (endpoints.length === 0) {
+      throw new Error("no API endpoints");
+    }
+    this.endpoints = endpoints.map((address) => {
+      const url = new URL(address);
+      if (url.protocol !== "http:" && url.protocol !== "https:") {
+        throw new Error(`invalid endpoint ${address}, want http(s)://host[:port]`);
+      }
+      return { base: address.replace(/\/+$/, ""), failures: 0, openUntil: 0, probing: false };
+    });
+    this.token = options.token;
+    this.retry = options.retry ?? defaultRetryPolicy;
+    this.breaker = options.breaker ?? defaultBreakerPolicy;
+    this.fetch = options.fetch ?? globalThis.fetch.bind(globalThis);
+  }
+
+  /**
+   * Sends a request for path, such as /api/agglomerator/chains, and returns
+   * its decoded JSON response. Writes carry options.idempotencyKey, or a
+   * generated one, on every attempt. Aborting options.signal stops the
+   * request, including while it waits to retry.
+   */
+  async send<T>(method: string, path: string, query?: Query, body?: unknown, options: RequestOptions = {}): Promise<T> {
+    const params = new URLSearchParams();
+    for (const [name, value] of Object.entries(query ?? {})) {
+      if (value !== undefined) {
+        params.set(name, String(value));
+      }
+    }
+    const encoded = params.toString();
+    if (encoded) {
+      path += "?" + encoded;
+    }
+    const payload = body === undefined ? undefined : JSON.stringify(body);
+    let key = options.idempotencyKey;
+    if (key === undefined && method !== "GET" && method !== "HEAD") {
+      key = crypto.randomUUID();
+    }
+
+    let lastError: unknown;
+    const attempts = Math.max(this.retry.maxAttempts, 1);
+    for (let attempt = 0; attempt < attempts; attempt++) {
+      if (attempt > 0) {
+        await this.wait(attempt, lastError, options.signal);
+      }
+      const target = this.pick();
+      if (target === undefined) {
+        throw new CircuitOpenError(lastError);
+      }
+      try {
+        return await this.attempt<T>(target, method, path, payload, key, options.signal);
+      } catch (err) {
+        if (options.signal?.aborted) {
+          throw err;
+        }
+        if (err instanceof APIError) {
+          if (!isRetryable(err, attempt, key)) {
+            throw err;
+          }
+        } else {
+          this.report(target, false);
+        }
+        lastError = err;
+      }
+    }
+    throw lastError;
+  }
+
+  // The generated operations send requests with request
+  protected request<T>(method: string, path: string, query?: Query, body?: unknown, signal?: AbortSignal): Promise<T> {
+    return this.send<T>(method, path, query, body, { signal });
+  }
+
+  private async attempt<T>(target: Endpoint, method: string, path: string, payload: string | undefined, key: string | undefined, signal?: AbortSignal): Promise<T> {
+    const headers: Record<string, string> = { Accept: "application/json" };
+    if (payload !== undefined) {
+      headers["Content-Type"] = "application/json";
+    }
+    if (key !== undefined) {
+      headers[IDEMPOTENCY_KEY_HEADER] = key;
+    }
+    if (this.token) {
+      headers["Authorization"] = "Bearer " + this.token;
+    }
+
+    const resp = await this.fetch(target.base + path, { method, headers, body: payload, signal });
+    const text = await resp.text();
+    if (!resp.ok) {
+      // An overloaded gateway, or one shedding load with a Retry-After, is
+      // still up, so its circuit stays closed
+      const shedding = resp.status === 503 && resp.headers.has("Retry-After");
+      this.report(target, shedding || !retryable.has(resp.status));
+      let detail = "";
+      try {
+        detail = JSON.parse(text).error ?? "";
+      } catch {
+        // Not a JSON error
+      }
+      const retryAfter = Number(resp.headers.get("Retry-After") ?? 0);
+      throw new APIError(resp.status, detail, Number.isFinite(retryAfter) ? retryAfter * 1000 : 0);
+    }
+    this.report(target, true);
+    return (text ? JSON.parse(text) : undefined) as T;
+  }
+
+  private pick(): Endpoint | undefined {
+    const now = Date.now();
+    for (let i = 0; i < this.endpoints.length; i++) {
+      const index = (this.preferred + i) % this.endpoints.length;
+      const target = this.endpoints[index];
+      if (this.breaker.failures <= 0 || target.failures < this.breaker.failures) {
+        this.preferred = index;
+        return target;
+      }
+      if (now >= target.openUntil && !target.probing) {
+        target.probing = true;
+        return target;
+      }
+    }
+    return undefined;
+  }
+
+  private report(target: Endpoint, ok: boolean): void {
+    target.probing = false;
+    if (ok) {
+      target.failures = 0;
+      return;
+    }
+    target.failures++;
+    if (target.failures >= this.breaker.failures) {
+      target.openUntil = Date.now() + this.breaker.cooldownMs;
+    }
+    if (this.endpoints[this.preferred] === target) {
+      this.preferred = (this.preferred + 1) % this.endpoints.length;
+    }
+  }
+
+  private wait(attempt: number, lastError: unknown, signal?: AbortSignal): Promise<void> {
+    const ceiling = Math.min(this.retry.baseDelayMs * 2 ** (attempt - 1), this.retry.maxDelayMs);
+    let delay = Math.random() * ceiling;
+    if (lastError instanceof APIError) {
+      delay = Math.max(delay, lastError.retryAfterMs);
+    }
+    return new Promise((resolve, reject) => {
+      if (signal?.aborted) {
+        reject(signal.reason);
+        return;
+      }
+      const timer = setTimeout(() => {
+        signal?.removeEventListener("abort", onAbort);
+        resolve();
+      }, delay);
+      const onAbort = () => {
+        clearTimeout(timer);
+        reject(signal?.reason);
+      };
+      signal?.addEventListener("abort", onAbort, { once: true });
+    });
+  }
+}
+
+function isRetryable(err: APIError, attempt: number, key: string | undefined): boolean {
+  if (retryable.has(err.status) || err.status === 429) {
+    return true;
+  }
+  // An earlier attempt may still be running under the same key
+  return err.status === 409 && attempt > 0 && key !== undefined;
+}
@@ -0,0 +1,5 @@
+// TypeScript client of the hydap API. Models and operations are generated
+// from clients/openapi.json; the transport is in client.ts.
+
+export * from "./generated.js";
+export * from "./client.js";
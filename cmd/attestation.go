@@ -0,0 +1,172 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/theaxiomverse/hydap-api/pkg/keymanagement"
+	"github.com/theaxiomverse/hydap-api/pkg/keymanagement/pb"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/agglomerator"
+)
+
+var attestationCmd = &cobra.Command{
+	Use:   "attestation",
+	Short: "Manage node key attestations",
+	Long: `Create a deployment CA key and use it to sign node attestations. Nodes
+present their attestation in the P2P handshake; peers with the CA public key
+in p2p.attestation.trustAnchors refuse nodes that cannot present one.`,
+}
+
+var attestationKeygenCmd = &cobra.Command{
+	Use:          "ca-keygen [file]",
+	Short:        "Generate a CA key and print its public half",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return generateCAKey(args[0])
+	},
+}
+
+var attestationNodeKeygenCmd = &cobra.Command{
+	Use:          "node-keygen [file]",
+	Short:        "Generate a node signing key for p2p.keyFile",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		algorithm, _ := cmd.Flags().GetString("algorithm")
+		return generateNodeKeys(args[0], algorithm)
+	},
+}
+
+var attestationIssueCmd = &cobra.Command{
+	Use:          "issue [node-id]",
+	Short:        "Sign an attestation for a node's public keys",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		caKeyFile, _ := cmd.Flags().GetString("ca-key")
+		signingKey, _ := cmd.Flags().GetString("signing-key")
+		signingAlgorithm, _ := cmd.Flags().GetString("signing-algorithm")
+		kemKey, _ := cmd.Flags().GetString("kem-key")
+		kemAlgorithm, _ := cmd.Flags().GetString("kem-algorithm")
+		validFor, _ := cmd.Flags().GetDuration("valid-for")
+		out, _ := cmd.Flags().GetString("out")
+
+		attestation := agglomerator.Attestation{
+			NodeID:           args[0],
+			SigningAlgorithm: signingAlgorithm,
+			SigningKey:       signingKey,
+			KEMAlgorithm:     kemAlgorithm,
+			KEMKey:           kemKey,
+		}
+		return issueAttestation(caKeyFile, attestation, validFor, out)
+	},
+}
+
+func init() {
+	// p2p.keyFile is read with liboqs, which the module cannot link itself
+	agglomerator.RegisterNodeKeysLoader(func(path string) (agglomerator.NodeKeys, error) {
+		return keymanagement.LoadNodeKeys(path)
+	})
+
+	attestationIssueCmd.Flags().String("ca-key", "", "CA key file written by ca-keygen")
+	attestationIssueCmd.Flags().String("signing-key", "", "node's base64 signing public key")
+	attestationIssueCmd.Flags().String("signing-algorithm", "DILITHIUM5", "algorithm of the signing key")
+	attestationIssueCmd.Flags().String("kem-key", "", "node's base64 KEM public key")
+	attestationIssueCmd.Flags().String("kem-algorithm", "KYBER1024", "algorithm of the KEM key")
+	attestationIssueCmd.Flags().Duration("valid-for", 365*24*time.Hour, "how long the attestation is valid")
+	attestationIssueCmd.Flags().String("out", "", "file to write the attestation to (default stdout)")
+	attestationIssueCmd.MarkFlagRequired("ca-key")
+	attestationIssueCmd.MarkFlagRequired("signing-key")
+	attestationNodeKeygenCmd.Flags().String("algorithm", "DILITHIUM5", "signature algorithm of the key")
+	attestationCmd.AddCommand(attestationKeygenCmd)
+	attestationCmd.AddCommand(attestationNodeKeygenCmd)
+	attestationCmd.AddCommand(attestationIssueCmd)
+}
+
+func generateCAKey(path string) error {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate CA key: %w", err)
+	}
+	encoded := base64.StdEncoding.EncodeToString(privateKey)
+	if err := os.WriteFile(path, []byte(encoded+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write CA key: %w", err)
+	}
+
+	fmt.Printf("CA key written to %s\n", path)
+	fmt.Printf("Trust anchor public key: %s\n", base64.StdEncoding.EncodeToString(publicKey))
+	fmt.Printf("Fingerprint:             %s\n", agglomerator.KeyFingerprint(publicKey))
+	return nil
+}
+
+func generateNodeKeys(path, algorithm string) error {
+	value, ok := pb.Algorithm_value[algorithm]
+	if !ok {
+		return fmt.Errorf("unknown algorithm %q", algorithm)
+	}
+	keys, err := keymanagement.GenerateNodeKeys(pb.Algorithm(value))
+	if err != nil {
+		return fmt.Errorf("failed to generate node keys: %w", err)
+	}
+	if err := keys.Save(path); err != nil {
+		return fmt.Errorf("failed to write node keys: %w", err)
+	}
+
+	fmt.Printf("Node keys written to %s\n", path)
+	fmt.Printf("Signing algorithm: %s\n", keys.SigningAlgorithm())
+	fmt.Printf("Signing key:       %s\n", base64.StdEncoding.EncodeToString(keys.SigningKey()))
+	return nil
+}
+
+func loadCAKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA key: %w", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("%s is not a CA key written by ca-keygen", path)
+	}
+	return ed25519.PrivateKey(key), nil
+}
+
+func issueAttestation(caKeyFile string, attestation agglomerator.Attestation, validFor time.Duration, out string) error {
+	caKey, err := loadCAKey(caKeyFile)
+	if err != nil {
+		return err
+	}
+	if _, err := base64.StdEncoding.DecodeString(attestation.SigningKey); err != nil {
+		return fmt.Errorf("signing key must be base64: %w", err)
+	}
+	if attestation.KEMKey == "" {
+		attestation.KEMAlgorithm = ""
+	} else if _, err := base64.StdEncoding.DecodeString(attestation.KEMKey); err != nil {
+		return fmt.Errorf("KEM key must be base64: %w", err)
+	}
+
+	attestation.IssuedAt = time.Now().UTC().Truncate(time.Second)
+	attestation.ExpiresAt = attestation.IssuedAt.Add(validFor)
+	signed := agglomerator.SignAttestation(attestation, caKey)
+
+	data, err := json.MarshalIndent(signed, "", "  ")
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		fmt.Println(string(data))
+		return nil
+	}
+	if err := os.WriteFile(out, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write attestation: %w", err)
+	}
+	fmt.Printf("Attestation for %s written to %s (expires %s)\n", signed.NodeID, out, signed.ExpiresAt.Format(time.RFC3339))
+	return nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/theaxiomverse/hydap-api/pkg/keymanagement"
+	"github.com/theaxiomverse/hydap-api/pkg/keymanagement/pb"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/agglomerator"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+// bootstrapFile declares everything a node needs at startup. Its modules
+// section has the same shape as the config file's, so a config file is also
+// a valid bootstrap file.
+type bootstrapFile struct {
+	Modules   map[string]map[string]interface{} `yaml:"modules"`
+	Chains    []bootstrapChain                  `yaml:"chains"`
+	Protocols map[string]bootstrapProtocol      `yaml:"protocols"`
+	KeysDir   string                            `yaml:"keysDir"` // Defaults to <data-dir>/keys
+	Keys      []bootstrapKey                    `yaml:"keys"`
+}
+
+// bootstrapChain is registered with the agglomerator as an enabled chain
+type bootstrapChain struct {
+	ID        string   `yaml:"id"`
+	Protocol  string   `yaml:"protocol"`
+	Endpoint  string   `yaml:"endpoint"`
+	Endpoints []string `yaml:"endpoints"`
+}
+
+// bootstrapProtocol overrides a protocol's routing parameters; zero fields
+// keep the built-in values
+type bootstrapProtocol struct {
+	BlockTime        float64 `yaml:"blockTime"`
+	ConfirmationTime float64 `yaml:"confirmationTime"`
+	TPS              float64 `yaml:"tps"`
+	Finality         float64 `yaml:"finality"`
+	CostWeight       float64 `yaml:"costWeight"`
+}
+
+// bootstrapKey is a key pair generated on first start, as <name>.key and
+// <name>.pub in the keys directory
+type bootstrapKey struct {
+	Name      string `yaml:"name"`
+	Algorithm string `yaml:"algorithm"` // e.g. FALCON512, KYBER768
+}
+
+func loadBootstrap(path string, secrets core.SecretProvider) (*bootstrapFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bootstrap file: %w", err)
+	}
+
+	var bootstrap bootstrapFile
+	if err := unmarshalConfig(data, &bootstrap, secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse bootstrap file: %w", err)
+	}
+	if bootstrap.Modules == nil {
+		bootstrap.Modules = make(map[string]map[string]interface{})
+	}
+	return &bootstrap, nil
+}
+
+// apply generates missing keys, registers protocol overrides and merges the
+// declared chains into the agglomerator config. It is safe to run on every
+// start: existing keys are kept and chains are matched by ID.
+func (b *bootstrapFile) apply(dataDir string) error {
+	keysDir := b.KeysDir
+	if keysDir == "" {
+		keysDir = filepath.Join(dataDir, "keys")
+	}
+	for _, key := range b.Keys {
+		created, err := ensureKey(keysDir, key)
+		if err != nil {
+			return err
+		}
+		if created {
+			fmt.Printf("Generated %s key %s\n", key.Algorithm, filepath.Join(keysDir, key.Name))
+		}
+	}
+
+	protocols := make([]string, 0, len(b.Protocols))
+	for id := range b.Protocols {
+		protocols = append(protocols, id)
+	}
+	sort.Strings(protocols)
+	for _, id := range protocols {
+		if err := applyProtocolOverride(id, b.Protocols[id]); err != nil {
+			return err
+		}
+	}
+
+	if len(b.Chains) > 0 {
+		if err := b.mergeChains(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyProtocolOverride(id string, override bootstrapProtocol) error {
+	config, _ := agglomerator.ProtocolConfig(id)
+	config.ID = id
+	if override.BlockTime != 0 {
+		config.BlockTime = override.BlockTime
+	}
+	if override.ConfirmationTime != 0 {
+		config.ConfirmationTime = override.ConfirmationTime
+	}
+	if override.TPS != 0 {
+		config.TPS = override.TPS
+	}
+	if override.Finality != 0 {
+		config.Finality = override.Finality
+	}
+	if override.CostWeight != 0 {
+		config.CostWeight = override.CostWeight
+	}
+	return agglomerator.RegisterProtocol(config)
+}
+
+// mergeChains adds the declared chains to the agglomerator's enabledChains,
+// replacing entries with the same ID
+func (b *bootstrapFile) mergeChains() error {
+	moduleConfig := b.Modules["blockchain_agglomerator"]
+	if moduleConfig == nil {
+		return errors.New("bootstrap chains require a blockchain_agglomerator module")
+	}
+
+	existing, _ := moduleConfig["enabledChains"].([]interface{})
+	declared := make(map[string]bool, len(b.Chains))
+	for _, chain := range b.Chains {
+		if chain.ID == "" {
+			return errors.New("bootstrap chain id is required")
+		}
+		declared[chain.ID] = true
+	}
+
+	chains := make([]interface{}, 0, len(existing)+len(b.Chains))
+	for _, entry := range existing {
+		if chain, ok := entry.(map[string]interface{}); ok && declared[fmt.Sprint(chain["id"])] {
+			continue
+		}
+		chains = append(chains, entry)
+	}
+	for _, chain := range b.Chains {
+		entry := map[string]interface{}{
+			"id":       chain.ID,
+			"protocol": chain.Protocol,
+			"endpoint": chain.Endpoint,
+		}
+		if len(chain.Endpoints) > 0 {
+			entry["endpoints"] = chain.Endpoints
+		}
+		chains = append(chains, entry)
+	}
+
+	moduleConfig["enabledChains"] = chains
+	return nil
+}
+
+// ensureKey generates a key pair unless its secret key file already exists
+func ensureKey(dir string, key bootstrapKey) (bool, error) {
+	if key.Name == "" {
+		return false, errors.New("bootstrap key name is required")
+	}
+	secretPath := filepath.Join(dir, key.Name+".key")
+	if _, err := os.Stat(secretPath); err == nil {
+		return false, nil
+	}
+
+	algorithm, exists := pb.Algorithm_value[key.Algorithm]
+	if !exists {
+		return false, fmt.Errorf("key %s: unknown algorithm %q", key.Name, key.Algorithm)
+	}
+	manager, err := keymanagement.NewKeyManager(pb.Algorithm(algorithm), "")
+	if err != nil {
+		return false, fmt.Errorf("key %s: %w", key.Name, err)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return false, fmt.Errorf("failed to create keys directory: %w", err)
+	}
+	publicPath := filepath.Join(dir, key.Name+".pub")
+	if err := os.WriteFile(publicPath, []byte(manager.GetPublicKey()+"\n"), 0644); err != nil {
+		return false, fmt.Errorf("key %s: %w", key.Name, err)
+	}
+	// The secret is written last so a partial run is retried on next start
+	secret := base64.StdEncoding.EncodeToString(manager.GetPrivate())
+	if err := os.WriteFile(secretPath, []byte(secret+"\n"), 0600); err != nil {
+		return false, fmt.Errorf("key %s: %w", key.Name, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/agglomerator"
+)
+
+const testBootstrap = `
+modules:
+  blockchain_agglomerator:
+    nodeID: "bootstrap"
+    enabledChains:
+      - id: "eth-main"
+        protocol: "eth"
+        endpoint: "http://old:8545"
+      - id: "btc-main"
+        protocol: "btc"
+        endpoint: "http://localhost:8332"
+chains:
+  - id: "eth-main"
+    protocol: "eth"
+    endpoint: "http://localhost:8545"
+    endpoints: ["http://localhost:8546"]
+  - id: "mock-local"
+    protocol: "bootstrap-test"
+    endpoint: "mock://local"
+protocols:
+  bootstrap-test:
+    blockTime: 3
+    finality: 30
+keys:
+  - name: "node"
+    algorithm: "FALCON512"
+`
+
+func TestBootstrapApply(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bootstrap.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testBootstrap), 0644))
+
+	// An existing key is kept rather than generated again
+	keysDir := filepath.Join(dir, "keys")
+	require.NoError(t, os.MkdirAll(keysDir, 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(keysDir, "node.key"), []byte("existing\n"), 0600))
+
+	for range 2 {
+		modules, err := loadStartupModules("", path, dir, nil)
+		require.NoError(t, err)
+
+		// Declared chains replace those with the same ID
+		chains := modules["blockchain_agglomerator"]["enabledChains"].([]interface{})
+		require.Len(t, chains, 3)
+		assert.Equal(t, "btc-main", chains[0].(map[string]interface{})["id"])
+		assert.Equal(t, map[string]interface{}{
+			"id":        "eth-main",
+			"protocol":  "eth",
+			"endpoint":  "http://localhost:8545",
+			"endpoints": []string{"http://localhost:8546"},
+		}, chains[1])
+		assert.Equal(t, "mock-local", chains[2].(map[string]interface{})["id"])
+	}
+
+	protocol, ok := agglomerator.ProtocolConfig("bootstrap-test")
+	require.True(t, ok)
+	assert.Equal(t, 3.0, protocol.BlockTime)
+	assert.Equal(t, 30.0, protocol.Finality)
+
+	key, err := os.ReadFile(filepath.Join(keysDir, "node.key"))
+	require.NoError(t, err)
+	assert.Equal(t, "existing\n", string(key))
+}
+
+func TestBootstrapRejectsInvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"bootstrap chains require a blockchain_agglomerator module": "chains:\n  - id: a\n    protocol: eth\n",
+		"bootstrap chain id is required":                            "modules:\n  blockchain_agglomerator: {}\nchains:\n  - protocol: eth\n",
+		`key node: unknown algorithm "MD5"`:                         "keys:\n  - name: node\n    algorithm: MD5\n",
+		"blockTime and finality must be positive":                   "protocols:\n  broken:\n    costWeight: 1\n",
+	} {
+		path := filepath.Join(dir, "bootstrap.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		_, err := loadStartupModules("", path, dir, nil)
+		assert.ErrorContains(t, err, name)
+	}
+}
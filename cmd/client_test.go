@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/client"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/agglomerator"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+var fastRetries = client.WithRetryPolicy(client.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond})
+
+func TestClientRetriesWritesOnce(t *testing.T) {
+	node := startTestNode(t, t.TempDir(), nil)
+	target, err := url.Parse(node.server.URL)
+	require.NoError(t, err)
+
+	// The first response is lost after the node has served the request
+	var mu sync.Mutex
+	var replayed []string
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		mu.Lock()
+		defer mu.Unlock()
+		replayed = append(replayed, resp.Header.Get(core.IdempotentReplayedHeader))
+		if len(replayed) == 1 {
+			resp.StatusCode = http.StatusBadGateway
+		}
+		return nil
+	}
+	lossy := httptest.NewServer(proxy)
+	defer lossy.Close()
+
+	c, err := client.New([]string{lossy.URL}, fastRetries)
+	require.NoError(t, err)
+	chain := client.Chain{ID: "eth", Endpoint: "http://localhost:8545", Protocol: agglomerator.ProtocolEthereum}
+	require.NoError(t, c.RegisterChain(context.Background(), chain))
+	mu.Lock()
+	assert.Equal(t, []string{"", "true"}, replayed, "the retry gets the first response")
+	mu.Unlock()
+
+	var events struct {
+		Events []agglomerator.Event `json:"events"`
+	}
+	require.Equal(t, http.StatusOK, node.do(http.MethodGet, "/api/agglomerator/events?type="+agglomerator.EventChainRegistered, nil, &events))
+	assert.Len(t, events.Events, 1, "the chain was registered once")
+
+	chains, err := c.ListChains(context.Background())
+	require.NoError(t, err)
+	require.Len(t, chains, 1)
+	assert.Equal(t, "eth", chains[0].ID)
+
+	var apiErr *client.APIError
+	err = c.Do(context.Background(), http.MethodGet, "/api/agglomerator/chains/missing", nil, nil)
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+}
+
+func TestClientCircuitBreaking(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	var served int
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer up.Close()
+
+	c, err := client.New([]string{down.URL, up.URL}, fastRetries, client.WithBreakerPolicy(client.BreakerPolicy{Failures: 1, Cooldown: time.Hour}))
+	require.NoError(t, err)
+	require.NoError(t, c.Do(context.Background(), http.MethodGet, "/", nil, nil), "fails over to the next endpoint")
+	require.NoError(t, c.Do(context.Background(), http.MethodGet, "/", nil, nil))
+	assert.Equal(t, 2, served, "the open circuit and affinity keep requests on the live endpoint")
+
+	up.Close()
+	err = c.Do(context.Background(), http.MethodGet, "/", nil, nil)
+	assert.ErrorIs(t, err, client.ErrCircuitOpen)
+
+	_, err = client.New(nil)
+	assert.ErrorIs(t, err, client.ErrNoEndpoints)
+}
+
+func TestClientSheddingKeepsCircuitClosed(t *testing.T) {
+	var requests int
+	shedding := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer shedding.Close()
+
+	c, err := client.New([]string{shedding.URL}, fastRetries, client.WithBreakerPolicy(client.BreakerPolicy{Failures: 1, Cooldown: time.Hour}))
+	require.NoError(t, err)
+	require.NoError(t, c.Do(context.Background(), http.MethodGet, "/", nil, nil), "a 503 with Retry-After is retried on the same gateway")
+	assert.Equal(t, 2, requests)
+}
+
+func TestClientHonorsContext(t *testing.T) {
+	busy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer busy.Close()
+
+	c, err := client.New([]string{busy.URL}, fastRetries)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = c.Do(ctx, http.MethodPost, "/", map[string]string{}, nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "Retry-After outlasts the deadline")
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
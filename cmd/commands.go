@@ -1,21 +1,25 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"gopkg.in/yaml.v3"
-	"math"
+	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/google/uuid"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/spf13/cobra"
+	"github.com/theaxiomverse/hydap-api/pkg/client"
 	"github.com/theaxiomverse/hydap-api/pkg/modules/agglomerator"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/api"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/base"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/compression"
 	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
-	"github.com/theaxiomverse/hydap-api/pkg/vectors"
 )
 
 var startCmd = &cobra.Command{
@@ -24,7 +28,21 @@ var startCmd = &cobra.Command{
 	Long:  `Start the blockchain agglomerator service with the specified configuration.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		configFile, _ := cmd.Flags().GetString("config")
-		return startService(configFile)
+		bootstrapFile, _ := cmd.Flags().GetString("bootstrap")
+		dataDir, _ := cmd.Flags().GetString("data-dir")
+		addr, _ := cmd.Flags().GetString("addr")
+		grpcAddr, _ := cmd.Flags().GetString("grpc-addr")
+		auth, _ := cmd.Flags().GetBool("auth")
+		gatewayID, _ := cmd.Flags().GetString("gateway-id")
+		gateways, _ := cmd.Flags().GetStringToString("gateways")
+		var gatewayRouter *core.GatewayRouter
+		if len(gateways) > 0 {
+			var err error
+			if gatewayRouter, err = core.NewGatewayRouter(gatewayID, gateways); err != nil {
+				return err
+			}
+		}
+		return startService(configFile, bootstrapFile, dataDir, addr, grpcAddr, auth, gatewayRouter, secretsProvider(cmd))
 	},
 }
 
@@ -73,6 +91,15 @@ var txCreateCmd = &cobra.Command{
 }
 
 func init() {
+	// Start command flags
+	startCmd.Flags().String("data-dir", "./data", "directory for the module config database")
+	startCmd.Flags().String("addr", ":8088", "HTTP listen address")
+	startCmd.Flags().String("grpc-addr", "", "gRPC listen address; the gRPC API is off when empty")
+	startCmd.Flags().String("bootstrap", "", "declarative bootstrap file applied instead of the config file")
+	startCmd.Flags().Bool("auth", false, "require API tokens, issued with the token command")
+	startCmd.Flags().StringToString("gateways", nil, "gateway instances fronting the cluster, as id=url pairs; module requests are proxied to the gateway owning the module")
+	startCmd.Flags().String("gateway-id", "", "ID of this instance among --gateways")
+
 	// Chain command flags
 	chainAddCmd.Flags().StringP("protocol", "p", "", "chain protocol (eth, sol, etc)")
 	chainCmd.AddCommand(chainAddCmd)
@@ -83,43 +110,131 @@ func init() {
 	txCmd.AddCommand(txCreateCmd)
 }
 
-func startService(configFile string) error {
-	// Read config file
-	configData, err := os.ReadFile(configFile)
+func startService(configFile, bootstrapFile, dataDir, addr, grpcAddr string, auth bool, gateways *core.GatewayRouter, secrets core.SecretProvider) error {
+	modules, err := loadStartupModules(configFile, bootstrapFile, dataDir, secrets)
 	if err != nil {
-		return fmt.Errorf("failed to read config file: %w", err)
+		return err
 	}
 
-	// Parse YAML config
-	var config struct {
-		Modules struct {
-			BlockchainAgglomerator map[string]interface{} `yaml:"blockchain_agglomerator"`
-		} `yaml:"modules"`
+	// Initialize core components
+	configManager, err := core.NewConfigManager(filepath.Join(dataDir, "agglomerator.db"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize config manager: %w", err)
 	}
-	if err := yaml.Unmarshal(configData, &config); err != nil {
-		return fmt.Errorf("failed to parse config: %w", err)
+
+	var tokens *core.TokenStore
+	if auth {
+		if tokens, err = openTokenStore(dataDir); err != nil {
+			return err
+		}
+		defer tokens.Close()
+		if count, err := tokens.Count(); err == nil && count == 0 {
+			fmt.Println("API token auth is enabled but no tokens have been issued; issue one with the token issue command")
+		}
 	}
 
-	// Initialize core components
-	configManager, err := core.NewConfigManager("./data/agglomerator.db")
+	// Fatal errors leave a bundle of the node's state in the data directory
+	dumper := core.NewCrashDumper(filepath.Join(dataDir, "crash"))
+	if err := dumper.CaptureCrashOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "Crash output will not be captured: %v\n", err)
+	}
+	defer dumper.Recover()
+
+	router, registry, err := newService(context.Background(), configManager, modules, tokens, gateways, dumper)
 	if err != nil {
-		return fmt.Errorf("failed to initialize config manager: %w", err)
+		return err
+	}
+	if grpcAddr != "" {
+		if err := serveGRPC(grpcAddr, registry, tokens); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Starting agglomerator service on %s\n", addr)
+	if err := http.ListenAndServe(addr, router); err != nil {
+		dumper.Fatal(err)
+		return err
+	}
+	return nil
+}
+
+// serveGRPC serves the agglomerator's gRPC API on addr in the background,
+// authenticating calls with tokens when set
+func serveGRPC(addr string, registry *core.ModuleRegistry, tokens *core.TokenStore) error {
+	identity, _ := registry.Get("blockchain_agglomerator")
+	module, ok := identity.(*agglomerator.AgglomeratorModule)
+	if !ok {
+		return fmt.Errorf("the gRPC API needs the agglomerator module")
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for gRPC: %w", err)
 	}
 
+	fmt.Printf("Serving gRPC on %s\n", addr)
+	go func() {
+		if err := agglomerator.NewGRPCServer(module, registry, tokens).Serve(listener); err != nil {
+			fmt.Fprintf(os.Stderr, "gRPC server stopped: %v\n", err)
+		}
+	}()
+	return nil
+}
+
+// responseCompressionLevel is the gzip and deflate level of API responses.
+// Only text and JSON bodies are compressed, and only for clients that
+// accept it; blobs and event streams pass through as sent.
+const responseCompressionLevel = 5
+
+// Responses to mutating requests sent with an Idempotency-Key are replayed
+// to requests repeating the key for idempotencyKeyTTL, up to
+// idempotencyKeyCapacity remembered responses. Requests and responses over
+// idempotencyMaxBody, such as blob uploads, are not remembered.
+const (
+	idempotencyKeyTTL      = 24 * time.Hour
+	idempotencyKeyCapacity = 10000
+	idempotencyMaxBody     = 1 << 20
+)
+
+// newService stores the module configs, registers the service modules and
+// mounts their routes. With tokens set, every route requires an API token
+// scoped to the route's module. With gateways set, requests for modules
+// another gateway owns are proxied to it. ctx bounds module initialization,
+// on top of each module's own deadline. The registry and modules add their
+// state to bundles taken by dumper.
+func newService(ctx context.Context, configManager *core.ConfigManager, modules map[string]map[string]interface{}, tokens *core.TokenStore, gateways *core.GatewayRouter, dumper *core.CrashDumper) (chi.Router, *core.ModuleRegistry, error) {
 	// Store initial configuration
-	moduleConfig, err := json.Marshal(config.Modules.BlockchainAgglomerator)
+	moduleConfig, err := json.Marshal(modules["blockchain_agglomerator"])
 	if err != nil {
-		return fmt.Errorf("failed to marshal module config: %w", err)
+		return nil, nil, fmt.Errorf("failed to marshal module config: %w", err)
 	}
 
 	if err := configManager.SetConfig("blockchain_agglomerator", moduleConfig); err != nil {
-		return fmt.Errorf("failed to store initial config: %w", err)
+		return nil, nil, fmt.Errorf("failed to store initial config: %w", err)
+	}
+
+	if len(modules[compression.ModuleName]) > 0 {
+		compressionConfig, err := json.Marshal(modules[compression.ModuleName])
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to marshal compression config: %w", err)
+		}
+		if err := configManager.SetConfig(compression.ModuleName, compressionConfig); err != nil {
+			return nil, nil, fmt.Errorf("failed to store compression config: %w", err)
+		}
 	}
 
 	metrics := core.NewMetricsExporter()
 	logger := &core.ModuleLogger{
 		Outputs: make(map[string]*os.File),
 	}
+	registry := core.NewModuleRegistry(newModuleLoaders(configManager, metrics, logger))
+	registry.SetSandboxLoader(newModuleLoaders)
+	for name, config := range modules {
+		timeouts, err := core.TimeoutsFromConfig(config)
+		if err != nil {
+			return nil, nil, fmt.Errorf("module %s: %w", name, err)
+		}
+		registry.SetTimeouts(name, timeouts)
+	}
 
 	// Create and initialize module
 	module := agglomerator.NewAgglomeratorModule(
@@ -127,47 +242,176 @@ func startService(configFile string) error {
 		metrics,
 		logger,
 	)
+	// The agglomerator reconciles the desired state while it runs, and
+	// owns its chains section
+	desiredState := core.NewDesiredStateReconciler(configManager)
+	module.RegisterDesiredState(desiredState)
+
+	if err := registry.Register(ctx, module); err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize module: %w", err)
+	}
+
+	compressionModule := compression.NewCompressionModule(
+		configManager,
+		metrics,
+		logger,
+	)
 
-	if err := module.Initialize(); err != nil {
-		return fmt.Errorf("failed to initialize module: %w", err)
+	if err := registry.Register(ctx, compressionModule); err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize compression module: %w", err)
 	}
 
 	// Create API router
 	apiHandler := agglomerator.NewAPI(module)
 	router := chi.NewRouter()
-	router.Mount("/api/agglomerator", apiHandler.Routes())
+	routes := map[string][]string{
+		module.Name():            {"/api/agglomerator", "/api/p2p", "/api/metrics/history", "/api/vectors", "/api/events"},
+		compressionModule.Name(): {"/api/compress", "/api/decompress"},
+	}
+	// Gateways proxy requests for modules they do not own ahead of
+	// compression and auth; the owner compresses and authenticates them
+	if gateways != nil {
+		for name, prefixes := range routes {
+			for _, prefix := range prefixes {
+				gateways.Route(prefix, name)
+			}
+		}
+		for _, name := range api.FixedModuleRoutes() {
+			gateways.FixedRoute(name)
+		}
+		router.Use(gateways.Middleware)
+	}
+	router.Use(middleware.Compress(responseCompressionLevel))
+	if tokens != nil {
+		auth := core.NewTokenAuth(tokens)
+		for name, prefixes := range routes {
+			for _, prefix := range prefixes {
+				auth.Route(prefix, name)
+			}
+		}
+		for _, name := range api.FixedModuleRoutes() {
+			auth.FixedRoute(name)
+		}
+		router.Use(auth.Middleware)
+	}
+	router.Use(core.NewIdempotencyCache(idempotencyKeyTTL, idempotencyKeyCapacity, idempotencyMaxBody).Middleware)
+
+	// A panicking handler puts its module in StateError rather than
+	// taking down the server
+	registry.OnPanic(func(record core.PanicRecord) {
+		fmt.Fprintf(os.Stderr, "Recovered panic in module %s (%s): %s\n%s", record.Module, record.Operation, record.Value, record.Stack)
+	})
+	recoverAgglomerator := registry.Recover(module.Name())
+	recoverCompression := registry.Recover(compressionModule.Name())
+
+	router.Mount("/api/agglomerator", recoverAgglomerator(apiHandler.Routes()))
+	if p2p := module.GetP2P(); p2p != nil {
+		router.Mount("/api/p2p", recoverAgglomerator(agglomerator.NewP2PAPI(p2p).Routes()))
+	}
+	compressionRoutes := recoverCompression(compression.NewAPI(compressionModule).Routes())
+	apiRouter := chi.NewRouter()
+	for _, pattern := range []string{"/compress", "/compress/*", "/decompress"} {
+		apiRouter.Handle(pattern, compressionRoutes)
+	}
+	apiRouter.Method(http.MethodGet, "/metrics/history", recoverAgglomerator(http.HandlerFunc(apiHandler.GetMetricsHistory)))
+	apiRouter.Method(http.MethodGet, "/vectors/analysis", recoverAgglomerator(http.HandlerFunc(apiHandler.GetVectorAnalysis)))
+	apiRouter.Method(http.MethodGet, "/vectors/export", recoverAgglomerator(http.HandlerFunc(apiHandler.ExportVectors)))
+	apiRouter.Method(http.MethodGet, "/events/replay", recoverAgglomerator(http.HandlerFunc(apiHandler.ReplayEvents)))
+	moduleAPI := api.NewModuleAPI(registry, configManager, metrics)
+	moduleAPI.SetTokenStore(tokens)
+	moduleAPI.SetConfigValidator(validateModuleConfig)
+	moduleAPI.SetDesiredState(desiredState)
+	diagnostics := core.NewDiagnostics()
+	diagnostics.Register("config.db", configManager.CheckIntegrity())
+	diagnostics.Register("liboqs", checkLiboqs)
+	module.RegisterDiagnostics(diagnostics)
+	moduleAPI.SetDiagnostics(diagnostics)
+	registerCrashState(dumper, registry, configManager)
+	module.RegisterCrashState(dumper)
+	moduleAPI.SetCrashDumper(dumper)
+	// GraphQL reads every module, so it belongs to none and needs an
+	// unscoped token
+	graphQL, err := agglomerator.NewGraphQLAPI(module, registry)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build GraphQL schema: %w", err)
+	}
+	apiRouter.Mount("/graphql", recoverAgglomerator(graphQL.Routes()))
+	apiRouter.Mount("/", moduleAPI.Router())
+	router.Mount("/api", apiRouter)
 
-	fmt.Println("Starting agglomerator service on :8088")
-	return http.ListenAndServe(":8088", router)
+	return router, registry, nil
 }
 
-func addChain(chainID, endpoint, protocol string) error {
-	chain := &agglomerator.Chain{
-		ID:       chainID,
-		Endpoint: endpoint,
-		Protocol: protocol,
-		StateVector: vectors.InfiniteVector{
-			Generator: func(dim int) float64 {
-				return math.Exp(-float64(dim)/10.0) * math.Sin(float64(dim))
-			},
-		},
-	}
-
-	// Make API request to register chain
-	url := "http://localhost:8088/api/agglomerator/chains"
-	body, err := json.Marshal(chain)
-	if err != nil {
-		return err
+// registerCrashState adds module states, recovered panics and config
+// revisions to crash bundles
+func registerCrashState(dumper *core.CrashDumper, registry *core.ModuleRegistry, configManager *core.ConfigManager) {
+	dumper.Register("modules", func() (interface{}, error) {
+		return registry.List(), nil
+	})
+	dumper.Register("panics", func() (interface{}, error) {
+		return registry.Panics(), nil
+	})
+	dumper.Register("configRevisions", func() (interface{}, error) {
+		revisions := make(map[string][]core.ConfigRevision)
+		for _, info := range registry.List() {
+			list, err := configManager.ListConfigRevisions(info.Name)
+			if err != nil {
+				return nil, err
+			}
+			revisions[info.Name] = list
+		}
+		return revisions, nil
+	})
+}
+
+// loadStartupModules reads module configs from the bootstrap file, after
+// applying it, or from the config file when no bootstrap file is given
+func loadStartupModules(configFile, bootstrapFile, dataDir string, secrets core.SecretProvider) (map[string]map[string]interface{}, error) {
+	if bootstrapFile != "" {
+		bootstrap, err := loadBootstrap(bootstrapFile, secrets)
+		if err != nil {
+			return nil, err
+		}
+		if err := bootstrap.apply(dataDir); err != nil {
+			return nil, fmt.Errorf("failed to apply bootstrap: %w", err)
+		}
+		return bootstrap.Modules, nil
 	}
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(body))
+	// Read config file
+	configData, err := os.ReadFile(configFile)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// Parse YAML config, expanding ${env:...} and ${secret:...} references
+	var config struct {
+		Modules map[string]map[string]interface{} `yaml:"modules"`
 	}
-	defer resp.Body.Close()
+	if err := unmarshalConfig(configData, &config, secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	return config.Modules, nil
+}
+
+// newModuleLoaders returns loaders for the built-in modules
+func newModuleLoaders(configManager *core.ConfigManager, metrics *core.MetricsExporter, logger *core.ModuleLogger) base.ModuleLoader {
+	return core.LoaderSet{
+		"blockchain_agglomerator": agglomerator.NewAgglomeratorLoader(configManager, metrics, logger),
+		compression.ModuleName:    compression.NewCompressionLoader(configManager, metrics, logger),
+	}
+}
 
-	if resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("failed to register chain: %s", resp.Status)
+// localClient is a client of the service started on the default address
+func localClient() *client.Client {
+	c, _ := client.New([]string{"http://localhost:8088"})
+	return c
+}
+
+func addChain(chainID, endpoint, protocol string) error {
+	chain := client.Chain{ID: chainID, Endpoint: endpoint, Protocol: protocol}
+	if err := localClient().RegisterChain(context.Background(), chain); err != nil {
+		return fmt.Errorf("failed to register chain: %w", err)
 	}
 
 	fmt.Printf("Successfully registered chain %s\n", chainID)
@@ -175,17 +419,10 @@ func addChain(chainID, endpoint, protocol string) error {
 }
 
 func listChains() error {
-	// Make API request to list chains
-	resp, err := http.Get("http://localhost:8088/api/agglomerator/chains")
+	chains, err := localClient().ListChains(context.Background())
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-
-	var chains []*agglomerator.Chain
-	if err := json.NewDecoder(resp.Body).Decode(&chains); err != nil {
-		return err
-	}
 
 	// Print chains in a formatted table
 	fmt.Printf("%-20s %-40s %-10s\n", "CHAIN ID", "ENDPOINT", "PROTOCOL")
@@ -198,34 +435,16 @@ func listChains() error {
 }
 
 func createTransaction(fromChain, toChain string, data []byte) error {
-	tx := &agglomerator.Transaction{
-		ID:        uuid.NewString(),
-		FromChain: fromChain,
-		ToChain:   toChain,
-		Data:      data,
-		StateVector: vectors.InfiniteVector{
-			Generator: func(dim int) float64 {
-				return math.Exp(-float64(dim)/10.0) * math.Sin(float64(dim))
-			},
-		},
+	tx := client.Transaction{
+		ID:         core.NewID(),
+		FromChain:  fromChain,
+		ToChain:    toChain,
+		Data:       data,
 		Similarity: 0.7,
 	}
 
-	// Make API request to create transaction
-	url := "http://localhost:8088/api/agglomerator/transaction"
-	body, err := json.Marshal(tx)
-	if err != nil {
-		return err
-	}
-
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(body))
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusAccepted {
-		return fmt.Errorf("failed to create transaction: %s", resp.Status)
+	if err := localClient().SubmitTransaction(context.Background(), tx); err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
 	}
 
 	fmt.Printf("Successfully created transaction %s\n", tx.ID)
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/agglomerator"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/compression"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect and validate configuration",
+	Long:  `Inspect and validate agglomerator configuration files.`,
+}
+
+var configLintCmd = &cobra.Command{
+	Use:          "lint [file]",
+	Short:        "Validate a config file and report every problem",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resolve, _ := cmd.Flags().GetBool("resolve")
+		return lintConfig(args[0], resolve, secretsProvider(cmd))
+	},
+}
+
+func init() {
+	configLintCmd.Flags().Bool("resolve", false, "check that chain endpoint hosts resolve")
+	configCmd.AddCommand(configLintCmd)
+}
+
+func lintConfig(configFile string, resolve bool, secrets core.SecretProvider) error {
+	configData, err := os.ReadFile(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var config struct {
+		Modules map[string]map[string]interface{} `yaml:"modules"`
+	}
+	if err := unmarshalConfig(configData, &config, secrets); err != nil {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	names := make([]string, 0, len(config.Modules))
+	for name := range config.Modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []string
+	report := func(module string, err error) {
+		problems = append(problems, fmt.Sprintf("modules.%s: %v", module, err))
+	}
+
+	for _, name := range names {
+		raw := config.Modules[name]
+		errs := validateModuleConfig(name, raw)
+		for _, err := range errs {
+			report(name, err)
+		}
+		if resolve && name == "blockchain_agglomerator" {
+			var moduleConfig agglomerator.ModuleConfig
+			if err := decodeStrict(withoutKey(raw, "timeouts"), &moduleConfig); err == nil {
+				for _, chain := range moduleConfig.EnabledChains {
+					if err := resolveEndpoint(chain.Endpoint); err != nil {
+						report(name, fmt.Errorf("chain %s: %w", chain.ID, err))
+					}
+				}
+			}
+		}
+	}
+
+	if len(problems) == 0 {
+		fmt.Printf("%s: OK\n", configFile)
+		return nil
+	}
+
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	return fmt.Errorf("%s: %d problem(s) found", configFile, len(problems))
+}
+
+// validateModuleConfig checks a module's config section without loading
+// the module, returning every problem found
+func validateModuleConfig(name string, raw map[string]interface{}) []error {
+	var errs []error
+	if _, err := core.TimeoutsFromConfig(raw); err != nil {
+		errs = append(errs, err)
+	}
+	// Timeouts are read by the registry, not the module
+	raw = withoutKey(raw, "timeouts")
+	switch name {
+	case "blockchain_agglomerator":
+		var moduleConfig agglomerator.ModuleConfig
+		if err := decodeStrict(raw, &moduleConfig); err != nil {
+			return append(errs, err)
+		}
+		errs = append(errs, moduleConfig.Validate()...)
+
+	case compression.ModuleName:
+		moduleConfig := compression.DefaultModuleConfig()
+		if err := decodeStrict(raw, &moduleConfig); err != nil {
+			return append(errs, err)
+		}
+		errs = append(errs, moduleConfig.Validate()...)
+
+	default:
+		errs = append(errs, fmt.Errorf("unknown module"))
+	}
+	return errs
+}
+
+// withoutKey returns a copy of a config section without key
+func withoutKey(raw map[string]interface{}, key string) map[string]interface{} {
+	section := make(map[string]interface{}, len(raw))
+	for k, v := range raw {
+		if k != key {
+			section[k] = v
+		}
+	}
+	return section
+}
+
+// decodeStrict converts a YAML section to a config struct, rejecting unknown fields
+func decodeStrict(raw map[string]interface{}, target interface{}) error {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(target)
+}
+
+func resolveEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	if _, err := net.LookupHost(u.Hostname()); err != nil {
+		return fmt.Errorf("endpoint host does not resolve: %w", err)
+	}
+	return nil
+}
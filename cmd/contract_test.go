@@ -0,0 +1,612 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/agglomerator"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/api"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/compression"
+)
+
+// Golden files under testdata/contract hold the canonical request for each
+// endpoint and the shape of its response: every field, with values replaced
+// by their JSON types. A change to a shape fails the test; when it is
+// intended, rewrite the files with go test ./cmd -run TestAPIContract -update
+// and review the diff.
+var updateContracts = flag.Bool("update", false, "rewrite the API contract golden files")
+
+const contractDir = "testdata/contract"
+
+// contractCase is one request in the contract run. Cases run in order
+// against a single node, so later cases see the state earlier ones created.
+type contractCase struct {
+	name        string
+	method      string
+	path        string
+	body        interface{} // JSON encoded, unless []byte
+	contentType string      // application/json if empty
+	stream      bool        // Only the status and content type are read
+}
+
+// contractGolden is the content of a golden file
+type contractGolden struct {
+	Request struct {
+		Method      string          `json:"method"`
+		Path        string          `json:"path"`
+		ContentType string          `json:"contentType,omitempty"`
+		Body        json.RawMessage `json:"body,omitempty"`
+	} `json:"request"`
+	Status      int         `json:"status"`
+	ContentType string      `json:"contentType,omitempty"`
+	Response    interface{} `json:"response,omitempty"`
+}
+
+const (
+	contractTrustAnchor = "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+	contractBlob        = "contract blob"
+	contractBlobHash    = "b63ae700b9d687e577a9588f68f62be7447615c6bc6d088c32a62be9de93dfc3" // Blake3 of contractBlob
+)
+
+var contractTx = map[string]interface{}{
+	"id": "tx-1", "fromChain": "sol-main", "toChain": "eth-main", "data": []byte("hello"),
+	"similarity": 0.5, "priority": 2, "fee": 1.5, "asset": "ETH", "amount": "1500000000000000000",
+}
+
+var contractCases = []contractCase{
+	// Modules
+	{name: "version", method: http.MethodGet, path: "/api/version"},
+	{name: "modules-list", method: http.MethodGet, path: "/api/modules"},
+	{name: "modules-watch", method: http.MethodGet, path: "/api/modules?watch=true", stream: true},
+	{name: "module-get", method: http.MethodGet, path: "/api/modules/blockchain_agglomerator"},
+	{name: "module-health", method: http.MethodGet, path: "/api/modules/blockchain_agglomerator/health"},
+	{name: "module-validate", method: http.MethodPost, path: "/api/modules/validate", body: map[string]interface{}{
+		"Name": compression.ModuleName, "Version": "1.0.0", "Config": map[string]interface{}{"maxRank": 8},
+	}},
+	{name: "module-add", method: http.MethodPost, path: "/api/modules", body: map[string]interface{}{
+		"Name": "unknown", "Version": "1.0.0",
+	}},
+	{name: "module-config-update", method: http.MethodPut, path: "/api/modules/compression/config", body: map[string]interface{}{"maxRank": 8}},
+	{name: "module-config-update-second", method: http.MethodPut, path: "/api/modules/compression/config", body: map[string]interface{}{"maxRank": 4}},
+	{name: "module-config-revisions", method: http.MethodGet, path: "/api/modules/compression/config/revisions"},
+	{name: "module-config-diff", method: http.MethodGet, path: "/api/modules/compression/config/diff"},
+	{name: "module-config-bulk", method: http.MethodPut, path: "/api/modules/config/bulk", body: map[string]interface{}{
+		"compression": map[string]interface{}{"maxRank": 6},
+	}},
+	{name: "module-config-bulk-invalid", method: http.MethodPut, path: "/api/modules/config/bulk", body: map[string]interface{}{
+		"compression": map[string]interface{}{"maxRank": 2},
+		"unknown":     map[string]interface{}{},
+	}},
+	{name: "preset-save", method: http.MethodPut, path: "/api/presets/compression-fast", body: map[string]interface{}{
+		"module": "compression", "description": "contract", "config": map[string]interface{}{"maxRank": 4, "tolerance": 0.05},
+	}},
+	{name: "presets-list", method: http.MethodGet, path: "/api/presets"},
+	{name: "preset-get", method: http.MethodGet, path: "/api/presets/compression-fast"},
+	{name: "preset-apply", method: http.MethodPost, path: "/api/presets/compression-fast/apply", body: map[string]interface{}{
+		"overrides": map[string]interface{}{"maxRank": 8},
+	}},
+	{name: "preset-apply-invalid", method: http.MethodPost, path: "/api/presets/compression-fast/apply?dryRun=true", body: map[string]interface{}{
+		"overrides": map[string]interface{}{"maxRank": 0},
+	}},
+	{name: "preset-delete", method: http.MethodDelete, path: "/api/presets/compression-fast"},
+	{name: "module-panics", method: http.MethodGet, path: "/api/modules/panics"},
+	{name: "flags-list", method: http.MethodGet, path: "/api/flags"},
+	{name: "flag-set", method: http.MethodPut, path: "/api/flags/" + agglomerator.FlagHedging, body: map[string]interface{}{"enabled": false}},
+	{name: "flag-reset", method: http.MethodDelete, path: "/api/flags/" + agglomerator.FlagHedging},
+	{name: "desired-state-update", method: http.MethodPut, path: "/api/desired-state", body: map[string]interface{}{
+		"mode":    "report",
+		"chains":  []map[string]interface{}{{"id": "dot-main", "protocol": "dot", "endpoint": "http://localhost:9933"}},
+		"modules": map[string]interface{}{"compression": map[string]interface{}{"maxRank": 6}},
+	}},
+	{name: "desired-state-get", method: http.MethodGet, path: "/api/desired-state"},
+	{name: "desired-state-drift", method: http.MethodGet, path: "/api/desired-state/drift"},
+	{name: "desired-state-reconcile", method: http.MethodPost, path: "/api/desired-state/reconcile?dryRun=true"},
+	{name: "admin-diagnostics", method: http.MethodGet, path: "/api/admin/diagnostics"},
+	{name: "admin-dump", method: http.MethodGet, path: "/api/admin/dump"},
+	{name: "tokens-list", method: http.MethodGet, path: "/api/tokens"},
+	{name: "token-issue", method: http.MethodPost, path: "/api/tokens", body: map[string]interface{}{"name": "ci", "modules": []string{"*"}}},
+	{name: "token-revoke", method: http.MethodDelete, path: "/api/tokens/unknown"},
+
+	// Chains and transactions
+	{name: "chain-register", method: http.MethodPost, path: "/api/agglomerator/chains", body: map[string]interface{}{
+		"id": "eth-main", "endpoint": "http://localhost:8545", "protocol": "eth",
+	}},
+	{name: "chain-register-second", method: http.MethodPost, path: "/api/agglomerator/chains", body: map[string]interface{}{
+		"id": "sol-main", "endpoint": "http://localhost:8899", "protocol": "sol",
+		"capabilities": map[string]interface{}{"maxTxSize": 1 << 20},
+	}},
+	{name: "chains-list", method: http.MethodGet, path: "/api/agglomerator/chains"},
+	{name: "chain-registry-import", method: http.MethodPost, path: "/api/agglomerator/chain-registry/import"},
+	{name: "chain-registry", method: http.MethodGet, path: "/api/agglomerator/chain-registry"},
+	{name: "chains-watch", method: http.MethodGet, path: "/api/agglomerator/chains?watch=true", stream: true},
+	{name: "chain-get", method: http.MethodGet, path: "/api/agglomerator/chains/eth-main"},
+	{name: "chain-maintenance-set", method: http.MethodPut, path: "/api/agglomerator/chains/sol-main/maintenance", body: map[string]interface{}{
+		"mode": "draining", "reason": "contract",
+	}},
+	{name: "chain-maintenance-clear", method: http.MethodDelete, path: "/api/agglomerator/chains/sol-main/maintenance"},
+	{name: "chain-canary-set", method: http.MethodPut, path: "/api/agglomerator/chains/sol-main/canary", body: map[string]interface{}{
+		"state": "promoted", "reason": "contract",
+	}},
+	{name: "asset-register", method: http.MethodPost, path: "/api/agglomerator/assets", body: map[string]interface{}{
+		"symbol": "ETH", "chain": "sol-main", "decimals": 18,
+	}},
+	{name: "assets-list", method: http.MethodGet, path: "/api/agglomerator/assets"},
+	{name: "asset-value", method: http.MethodGet, path: "/api/agglomerator/assets/sol-main/ETH/value?amount=1500000000000000000"},
+	{name: "experiment-start", method: http.MethodPut, path: "/api/agglomerator/experiment", body: map[string]interface{}{
+		"name": "contract", "arms": []map[string]interface{}{{"name": "cheap", "percent": 50, "weights": map[string]interface{}{"cost": 1}}},
+	}},
+	{name: "transaction", method: http.MethodPost, path: "/api/agglomerator/transaction", body: contractTx},
+	{name: "transaction-fee-budget", method: http.MethodPost, path: "/api/agglomerator/transaction", body: map[string]interface{}{
+		"id": "tx-budget", "fromChain": "sol-main", "toChain": "eth-main", "similarity": 0.5,
+		"feeBudget": map[string]interface{}{"amount": 5, "currency": "USD"},
+	}},
+	{name: "transaction-sponsored", method: http.MethodPost, path: "/api/agglomerator/transaction", body: map[string]interface{}{
+		"id": "tx-sponsored", "fromChain": "sol-main", "toChain": "eth-main", "similarity": 0.5,
+		"metadata": map[string]string{"tenant": "acme"},
+	}},
+	{name: "sponsors", method: http.MethodGet, path: "/api/agglomerator/sponsors"},
+	{name: "chain-register-reverting", method: http.MethodPost, path: "/api/agglomerator/chains", body: map[string]interface{}{
+		"id": "mock-revert", "endpoint": "mock://revert?revertRate=1", "protocol": "mock",
+	}},
+	{name: "transaction-simulation-failed", method: http.MethodPost, path: "/api/agglomerator/transaction", body: map[string]interface{}{
+		"id": "tx-revert", "fromChain": "sol-main", "toChain": "mock-revert", "similarity": 0.5,
+	}},
+	{name: "transaction-unroutable", method: http.MethodPost, path: "/api/agglomerator/transaction", body: map[string]interface{}{
+		"id": "tx-2", "fromChain": "unknown", "toChain": "eth-main", "similarity": 0.5,
+	}},
+	{name: "transaction-stream", method: http.MethodPost, contentType: "application/octet-stream",
+		path: "/api/agglomerator/transaction/stream?id=tx-3&fromChain=sol-main&toChain=eth-main&similarity=0.5",
+		body: []byte("streamed payload")},
+	{name: "transactions-batch", method: http.MethodPost, path: "/api/agglomerator/transactions/batch", body: []map[string]interface{}{
+		{"id": "batch-1", "fromChain": "eth-main", "toChain": "sol-main", "similarity": 0.5},
+		{"id": "batch-2", "fromChain": "eth-main", "toChain": "missing", "similarity": 0.5},
+	}},
+	{name: "transactions-query", method: http.MethodGet, path: "/api/agglomerator/transactions?q=status:completed"},
+	{name: "transaction-route", method: http.MethodGet, path: "/api/agglomerator/transactions/tx-1/route"},
+	{name: "transaction-receipt", method: http.MethodGet, path: "/api/agglomerator/transactions/tx-1/receipt"},
+	{name: "transaction-reroute", method: http.MethodPost, path: "/api/agglomerator/transactions/batch-2/reroute", body: map[string]interface{}{
+		"operator": "contract", "reason": "destination never registered",
+	}},
+	{name: "transaction-reroute-not-stuck", method: http.MethodPost, path: "/api/agglomerator/transactions/tx-1/reroute", body: map[string]interface{}{
+		"operator": "contract", "reason": "already routed",
+	}},
+	{name: "transaction-reroutes", method: http.MethodGet, path: "/api/agglomerator/transactions/batch-2/reroutes"},
+	{name: "chain-pool", method: http.MethodGet, path: "/api/agglomerator/chains/eth-main/pool"},
+	{name: "chain-transaction", method: http.MethodGet, path: "/api/agglomerator/chains/eth-main/transactions/tx-1"},
+	{name: "blob-put", method: http.MethodPost, path: "/api/agglomerator/blobs", contentType: "application/octet-stream", body: []byte(contractBlob)},
+	{name: "blob-get", method: http.MethodGet, path: "/api/agglomerator/blobs/" + contractBlobHash},
+
+	// Routing and analysis
+	{name: "topology", method: http.MethodGet, path: "/api/agglomerator/topology"},
+	{name: "prerouting", method: http.MethodGet, path: "/api/agglomerator/prerouting"},
+	{name: "experiment-get", method: http.MethodGet, path: "/api/agglomerator/experiment"},
+	{name: "experiment-stop", method: http.MethodDelete, path: "/api/agglomerator/experiment"},
+	{name: "clusters-list", method: http.MethodGet, path: "/api/agglomerator/clusters"},
+	{name: "clusters-refit", method: http.MethodPost, path: "/api/agglomerator/clusters/refit"},
+	{name: "cluster-get", method: http.MethodGet, path: "/api/agglomerator/clusters/0"},
+	{name: "vectors-analysis", method: http.MethodGet, path: "/api/agglomerator/vectors/analysis?dims=8"},
+	{name: "vectors-analysis-alias", method: http.MethodGet, path: "/api/vectors/analysis?dims=8"},
+	{name: "vectors-export", method: http.MethodGet, path: "/api/agglomerator/vectors/export?dims=8"},
+	{name: "vectors-export-parquet", method: http.MethodGet, path: "/api/vectors/export?protocol=sol&dims=8&format=parquet"},
+	{name: "chain-vector-import", method: http.MethodPut, path: "/api/agglomerator/chains/sol-main/vector", contentType: "text/csv",
+		body: []byte("chain,protocol,d0,d1\nsol-main,sol,0.5,-0.25\n")},
+	{name: "chain-vector-import-invalid", method: http.MethodPut, path: "/api/agglomerator/chains/sol-main/vector", body: map[string]interface{}{
+		"values": []float64{},
+	}},
+	{name: "anomalies-list", method: http.MethodGet, path: "/api/agglomerator/anomalies"},
+	{name: "anomalies-retrain", method: http.MethodPost, path: "/api/agglomerator/anomalies/retrain"},
+	{name: "anomaly-approve", method: http.MethodPost, path: "/api/agglomerator/anomalies/unknown/approve"},
+	{name: "anomaly-reject", method: http.MethodPost, path: "/api/agglomerator/anomalies/unknown/reject"},
+
+	// Policy
+	{name: "policy-get", method: http.MethodGet, path: "/api/agglomerator/policy"},
+	{name: "policy-update", method: http.MethodPut, path: "/api/agglomerator/policy", body: map[string]interface{}{
+		"defaultAction": "allow",
+		"rules":         []map[string]interface{}{{"name": "no-btc", "action": "deny", "chainPairs": []string{"*->btc-main"}}},
+	}},
+	{name: "policy-reload", method: http.MethodPost, path: "/api/agglomerator/policy/reload"},
+	{name: "policy-evaluate", method: http.MethodPost, path: "/api/agglomerator/policy/evaluate", body: contractTx},
+	{name: "policy-decisions", method: http.MethodGet, path: "/api/agglomerator/policy/decisions"},
+
+	// Events, reconciliation and operations
+	{name: "events-list", method: http.MethodGet, path: "/api/agglomerator/events?limit=2"},
+	{name: "events-stream", method: http.MethodGet, path: "/api/agglomerator/events/stream", stream: true},
+	{name: "events-state", method: http.MethodGet, path: "/api/agglomerator/events/state"},
+	{name: "events-replay", method: http.MethodGet, path: "/api/agglomerator/events/replay?from=1&limit=2"},
+	{name: "events-replay-alias", method: http.MethodGet, path: "/api/events/replay?from=0"},
+	{name: "events-replay-gone", method: http.MethodGet, path: "/api/events/replay?from=1000000"},
+	{name: "reconciliation-run", method: http.MethodPost, path: "/api/agglomerator/reconciliation/run?date=2024-01-02"},
+	{name: "reconciliation-reports", method: http.MethodGet, path: "/api/agglomerator/reconciliation/reports"},
+	{name: "reconciliation-report", method: http.MethodGet, path: "/api/agglomerator/reconciliation/reports/2024-01-02"},
+	{name: "metrics-history", method: http.MethodGet, path: "/api/agglomerator/metrics/history?series=chain_count"},
+	{name: "metrics-history-alias", method: http.MethodGet, path: "/api/metrics/history?series=chain_count"},
+	{name: "graphql-disabled", method: http.MethodPost, path: "/api/graphql", body: map[string]interface{}{"query": "{ chains { id } }"}},
+	{name: "graphql-enable", method: http.MethodPut, path: "/api/flags/" + agglomerator.FlagGraphQL, body: map[string]interface{}{"enabled": true}},
+	{name: "graphql-query", method: http.MethodPost, path: "/api/graphql", body: map[string]interface{}{
+		"query": `query Overview($limit: Int!) {
+			modules { name status health { status } }
+			chain(id: "eth-main") {
+				id protocol pool { size }
+				pendingTransactions(limit: $limit) {
+					id fee
+					transaction { status }
+					route { mode route candidates { chainId score selected } }
+				}
+			}
+			completed: transactions(query: "status:completed", limit: 2) { id fromChain to { id } }
+			peers { id }
+			metrics(series: ["chain_count"]) { name samples { value } }
+		}`,
+		"variables": map[string]interface{}{"limit": 5},
+	}},
+	{name: "graphql-get", method: http.MethodGet, path: "/api/graphql?query=%7Bchains%7Bid%7D%7D"},
+	{name: "graphql-invalid", method: http.MethodPost, path: "/api/graphql", body: map[string]interface{}{"query": "{ chains { missing } }"}},
+	{name: "graphql-schema", method: http.MethodGet, path: "/api/graphql/schema"},
+	{name: "status", method: http.MethodGet, path: "/api/agglomerator/status"},
+	{name: "gc-stats", method: http.MethodGet, path: "/api/agglomerator/gc"},
+	{name: "gc-trigger", method: http.MethodPost, path: "/api/agglomerator/gc"},
+	{name: "pause", method: http.MethodPost, path: "/api/agglomerator/pause"},
+	{name: "resume", method: http.MethodPost, path: "/api/agglomerator/resume"},
+	{name: "asset-remove", method: http.MethodDelete, path: "/api/agglomerator/assets/sol-main/ETH"},
+
+	// P2P
+	{name: "p2p-stats", method: http.MethodGet, path: "/api/p2p/stats"},
+	{name: "p2p-peer-pin", method: http.MethodPut, path: "/api/p2p/reputation/peer-1/pin", body: map[string]interface{}{"score": 0.9}},
+	{name: "p2p-peer-boost", method: http.MethodPut, path: "/api/p2p/reputation/peer-1/boost", body: map[string]interface{}{"amount": 0.1}},
+	{name: "p2p-peer-penalize", method: http.MethodPut, path: "/api/p2p/reputation/peer-1/penalize", body: map[string]interface{}{"amount": 0.1}},
+	{name: "p2p-peer-unpin", method: http.MethodDelete, path: "/api/p2p/reputation/peer-1/pin"},
+	{name: "p2p-reputation", method: http.MethodGet, path: "/api/p2p/reputation"},
+	{name: "p2p-attestation", method: http.MethodGet, path: "/api/p2p/attestation"},
+	{name: "p2p-trust-anchor-add", method: http.MethodPost, path: "/api/p2p/trust-anchors", body: map[string]interface{}{
+		"name": "ca", "publicKey": contractTrustAnchor,
+	}},
+	{name: "p2p-trust-anchors", method: http.MethodGet, path: "/api/p2p/trust-anchors"},
+	{name: "p2p-trust-anchor-remove", method: http.MethodDelete, path: "/api/p2p/trust-anchors/ca"},
+	{name: "p2p-roles", method: http.MethodGet, path: "/api/p2p/roles"},
+	{name: "p2p-shards-rebalance", method: http.MethodPost, path: "/api/p2p/shards/rebalance"},
+	{name: "p2p-shards", method: http.MethodGet, path: "/api/p2p/shards"},
+
+	// Compression
+	{name: "compress", method: http.MethodPost, path: "/api/compress", body: map[string]interface{}{"data": []float64{1, 2, 3, 4}}},
+	{name: "compress-stats", method: http.MethodGet, path: "/api/compress/stats"},
+	{name: "decompress", method: http.MethodPost, path: "/api/decompress", body: map[string]interface{}{
+		"encoding": compression.EncodingFloats,
+		"block":    json.RawMessage(`{"U":[[1]],"V":[[1]],"S":[2],"OriginalRows":1,"OriginalCols":1,"OriginalSize":1}`),
+	}},
+
+	// Restarting and removing a module last, so the cases before it find
+	// it running
+	{name: "module-stop", method: http.MethodPost, path: "/api/modules/compression/stop"},
+	{name: "module-start", method: http.MethodPost, path: "/api/modules/compression/start"},
+	{name: "module-delete", method: http.MethodDelete, path: "/api/modules/compression"},
+}
+
+func TestAPIContract(t *testing.T) {
+	dataDir := t.TempDir()
+	// An empty chain registry, so imports leave the cases' chains alone
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	}))
+	defer registry.Close()
+	node := startTestNode(t, dataDir, map[string]interface{}{
+		"chainRegistry": map[string]interface{}{"url": registry.URL, "chainIds": []int{1}},
+		"metrics":       map[string]interface{}{"enabled": true, "interval": "1h"},
+		"p2p": map[string]interface{}{"address": "127.0.0.1", "port": freePort(t), "disableDiscovery": true,
+			"shards": map[string]interface{}{"count": 4}},
+		"anomaly":        map[string]interface{}{"enabled": true},
+		"policy":         map[string]interface{}{"enabled": true},
+		"preRouting":     map[string]interface{}{"enabled": true, "interval": "1h"},
+		"reconciliation": map[string]interface{}{"enabled": true, "interval": "1h"},
+		"simulation":     map[string]interface{}{"enabled": true},
+		"sponsors": map[string]interface{}{"enabled": true, "wallets": map[string]string{"sol-main": "SponsorSo1"},
+			"budgets": map[string]float64{"acme": 100}},
+		"gc": map[string]interface{}{"interval": "1h"},
+		"assets": map[string]interface{}{
+			"currency":  "USD",
+			"prices":    map[string]float64{"ETH": 2000, "SOL": 150},
+			"registry":  []map[string]interface{}{{"symbol": "SOL", "chain": "sol-main", "decimals": 9}},
+			"feeAssets": map[string]string{"sol-main": "SOL"},
+		},
+	})
+
+	seen := make(map[string]bool)
+	for _, c := range contractCases {
+		require.False(t, seen[c.name], "duplicate case %s", c.name)
+		seen[c.name] = true
+		t.Run(c.name, func(t *testing.T) {
+			golden := node.contract(t, c)
+			encoded, err := json.MarshalIndent(golden, "", "  ")
+			require.NoError(t, err)
+			encoded = append(encoded, '\n')
+
+			path := filepath.Join(contractDir, c.name+".json")
+			if *updateContracts {
+				require.NoError(t, os.MkdirAll(contractDir, 0755))
+				require.NoError(t, os.WriteFile(path, encoded, 0644))
+				return
+			}
+			expected, err := os.ReadFile(path)
+			require.NoError(t, err, "no golden file; run with -update to create it")
+			assert.Equal(t, string(expected), string(encoded), "contract of %s %s changed", c.method, c.path)
+		})
+	}
+
+	t.Run("golden files have cases", func(t *testing.T) {
+		files, err := filepath.Glob(filepath.Join(contractDir, "*.json"))
+		require.NoError(t, err)
+		for _, file := range files {
+			name := strings.TrimSuffix(filepath.Base(file), ".json")
+			if *updateContracts && !seen[name] {
+				require.NoError(t, os.Remove(file))
+				continue
+			}
+			assert.True(t, seen[name], "golden file %s has no case", file)
+		}
+	})
+
+	t.Run("every route has a case", func(t *testing.T) {
+		for _, route := range contractRoutes(t) {
+			covered := false
+			for _, c := range contractCases {
+				path, _, _ := strings.Cut(c.path, "?")
+				if c.method == route.method && route.pattern.MatchString(path) {
+					covered = true
+					break
+				}
+			}
+			assert.True(t, covered, "no contract case for %s %s", route.method, route.pattern)
+		}
+	})
+}
+
+// openAPISpec is the spec the Python and TypeScript clients are generated
+// from
+const openAPISpec = "../clients/openapi.json"
+
+// TestOpenAPISpec checks that every operation in the clients' spec is a
+// route the service mounts, and that the spec lists the status of each
+// contract case for it
+func TestOpenAPISpec(t *testing.T) {
+	data, err := os.ReadFile(openAPISpec)
+	require.NoError(t, err)
+	var spec struct {
+		Paths map[string]map[string]struct {
+			OperationID string              `json:"operationId"`
+			Responses   map[string]struct{} `json:"responses"`
+		} `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(data, &spec))
+	require.NotEmpty(t, spec.Paths)
+
+	routes := contractRoutes(t)
+	param := regexp.MustCompile(`\{[^}]+\}`)
+	for path, operations := range spec.Paths {
+		example := param.ReplaceAllString(path, "x")
+		expr := regexp.QuoteMeta(param.ReplaceAllString(path, "\x00"))
+		template := regexp.MustCompile("^" + strings.ReplaceAll(expr, "\x00", `[^/]+`) + "$")
+		for method, operation := range operations {
+			method = strings.ToUpper(method)
+			mounted := false
+			for _, route := range routes {
+				if route.method == method && route.pattern.MatchString(example) {
+					mounted = true
+					break
+				}
+			}
+			assert.True(t, mounted, "%s (%s %s) is not a route", operation.OperationID, method, path)
+
+			for _, c := range contractCases {
+				casePath, _, _ := strings.Cut(c.path, "?")
+				if c.method != method || !template.MatchString(casePath) {
+					continue
+				}
+				var golden contractGolden
+				encoded, err := os.ReadFile(filepath.Join(contractDir, c.name+".json"))
+				require.NoError(t, err)
+				require.NoError(t, json.Unmarshal(encoded, &golden))
+				assert.Contains(t, operation.Responses, strconv.Itoa(golden.Status), "%s does not list the status of case %s", operation.OperationID, c.name)
+			}
+		}
+	}
+}
+
+// contract sends a case's request and records it with its response's shape
+func (n *testNode) contract(t *testing.T, c contractCase) contractGolden {
+	var golden contractGolden
+	golden.Request.Method = c.method
+	golden.Request.Path = c.path
+
+	var body []byte
+	switch value := c.body.(type) {
+	case nil:
+	case []byte:
+		body = value
+		golden.Request.Body, _ = json.Marshal(string(value))
+	default:
+		encoded, err := json.Marshal(value)
+		require.NoError(t, err)
+		body = encoded
+		golden.Request.Body = encoded
+	}
+	contentType := c.contentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	if body != nil {
+		golden.Request.ContentType = contentType
+	}
+
+	req, err := http.NewRequest(c.method, n.server.URL+c.path, bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", contentType)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	golden.Status = resp.StatusCode
+	golden.ContentType, _, _ = strings.Cut(resp.Header.Get("Content-Type"), ";")
+	if c.stream {
+		return golden
+	}
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	// Some handlers send JSON without declaring it
+	var decoded interface{}
+	if json.Unmarshal(data, &decoded) == nil {
+		golden.Response = shapeOf(decoded)
+	}
+	return golden
+}
+
+// shapeOf replaces the values in decoded JSON by the names of their types.
+// An array's shape is the merged shape of its elements, so it does not
+// depend on their number or order.
+func shapeOf(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		shape := make(map[string]interface{}, len(v))
+		for key, field := range v {
+			shape[key] = shapeOf(field)
+		}
+		return shape
+	case []interface{}:
+		var element interface{}
+		for i, item := range v {
+			if i == 0 {
+				element = shapeOf(item)
+			} else {
+				element = mergeShapes(element, shapeOf(item))
+			}
+		}
+		if element == nil && len(v) == 0 {
+			return []interface{}{}
+		}
+		return []interface{}{element}
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return nil
+	}
+}
+
+// mergeShapes combines two shapes: objects have the union of their fields,
+// and differing types are joined as "a|b"
+func mergeShapes(a, b interface{}) interface{} {
+	objectA, okA := a.(map[string]interface{})
+	objectB, okB := b.(map[string]interface{})
+	if okA && okB {
+		merged := make(map[string]interface{}, len(objectA))
+		for key, shape := range objectA {
+			merged[key] = shape
+		}
+		for key, shape := range objectB {
+			if existing, ok := merged[key]; ok {
+				merged[key] = mergeShapes(existing, shape)
+			} else {
+				merged[key] = shape
+			}
+		}
+		return merged
+	}
+	arrayA, okA := a.([]interface{})
+	arrayB, okB := b.([]interface{})
+	if okA && okB {
+		switch {
+		case len(arrayA) == 0:
+			return arrayB
+		case len(arrayB) == 0:
+			return arrayA
+		}
+		return []interface{}{mergeShapes(arrayA[0], arrayB[0])}
+	}
+
+	names := make(map[string]bool)
+	for _, shape := range []interface{}{a, b} {
+		for _, name := range strings.Split(shapeName(shape), "|") {
+			names[name] = true
+		}
+	}
+	if len(names) == 1 {
+		return a
+	}
+	union := make([]string, 0, len(names))
+	for name := range names {
+		union = append(union, name)
+	}
+	sort.Strings(union)
+	return strings.Join(union, "|")
+}
+
+// shapeName names a shape's type
+func shapeName(shape interface{}) string {
+	switch s := shape.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return s
+	default:
+		return "null"
+	}
+}
+
+// contractRoute is an endpoint the service mounts
+type contractRoute struct {
+	method  string
+	pattern *regexp.Regexp
+}
+
+// contractRoutes lists the endpoints of every router newService mounts
+func contractRoutes(t *testing.T) []contractRoute {
+	mounts := []struct {
+		prefix string
+		routes chi.Routes
+	}{
+		{"/api/agglomerator", agglomerator.NewAPI(nil).Routes()},
+		{"/api/p2p", agglomerator.NewP2PAPI(nil).Routes()},
+		{"/api", compression.NewAPI(nil).Routes()},
+		{"/api", api.NewModuleAPI(nil, nil, nil).Router()},
+	}
+	param := regexp.MustCompile(`\{[^}]+\}`)
+
+	var routes []contractRoute
+	for _, mount := range mounts {
+		err := chi.Walk(mount.routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+			route = strings.TrimSuffix(mount.prefix+route, "/")
+			expr := regexp.QuoteMeta(param.ReplaceAllString(route, "\x00"))
+			expr = strings.ReplaceAll(expr, "\x00", `[^/]+`)
+			routes = append(routes, contractRoute{method: method, pattern: regexp.MustCompile("^" + expr + "$")})
+			return nil
+		})
+		require.NoError(t, err)
+	}
+	return routes
+}
+
+// freePort returns a local TCP port that was free when checked
+func freePort(t *testing.T) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
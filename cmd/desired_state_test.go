@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+// driftOf returns a report's drift by section and resource
+func driftOf(report core.DriftReport) map[string]core.Drift {
+	drift := make(map[string]core.Drift)
+	for _, entry := range report.Drift {
+		drift[entry.Section+"/"+entry.Resource] = entry
+	}
+	return drift
+}
+
+func TestDesiredStateReconcile(t *testing.T) {
+	node := startTestNode(t, t.TempDir(), nil)
+	node.registerChains(map[string]string{"eth-main": "eth", "btc-main": "btc"})
+
+	require.Equal(t, http.StatusNotFound, node.do(http.MethodGet, "/api/desired-state/drift", nil, nil))
+
+	desired := map[string]interface{}{
+		"interval": "1m",
+		"chains": []map[string]interface{}{
+			{"id": "eth-main", "protocol": "eth", "endpoint": "http://localhost:8545"},
+			{"id": "sol-main", "protocol": "sol", "endpoint": "http://localhost:8899"},
+		},
+		"modules": map[string]interface{}{"compression": map[string]interface{}{"maxRank": 8}},
+	}
+	var report core.DriftReport
+	require.Equal(t, http.StatusOK, node.do(http.MethodPut, "/api/desired-state", desired, &report))
+	assert.Equal(t, 1, report.Revision)
+	assert.Equal(t, core.DesiredStateEnforce, report.Mode)
+	drift := driftOf(report)
+	assert.Len(t, drift, 3, "eth-main matches")
+	assert.Equal(t, core.Drift{Section: "chains", Resource: "sol-main", Kind: core.DriftMissing, Corrected: true}, drift["chains/sol-main"])
+	assert.Equal(t, core.Drift{Section: "modules", Resource: "compression", Kind: core.DriftMissing, Corrected: true}, drift["modules/compression"])
+	assert.Equal(t, core.Drift{Section: "chains", Resource: "btc-main", Kind: core.DriftUnmanaged}, drift["chains/btc-main"])
+	assert.False(t, report.InSync, "unmanaged chains are never corrected")
+
+	require.Equal(t, http.StatusOK, node.do(http.MethodGet, "/api/agglomerator/chains/sol-main", nil, nil))
+	config, err := node.configManager.GetConfig("compression")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"maxRank": 8}`, string(config))
+
+	var last core.DriftReport
+	require.Equal(t, http.StatusOK, node.do(http.MethodGet, "/api/desired-state/drift", nil, &last))
+	assert.Equal(t, report.Drift, last.Drift)
+
+	// A chain changed by hand is reported by a dry run and corrected by the
+	// next reconciliation
+	moved := map[string]interface{}{"id": "eth-main", "endpoint": "http://localhost:9545", "protocol": "eth"}
+	require.Equal(t, http.StatusCreated, node.do(http.MethodPost, "/api/agglomerator/chains", moved, nil))
+	require.Equal(t, http.StatusOK, node.do(http.MethodPost, "/api/desired-state/reconcile?dryRun=true", nil, &report))
+	assert.True(t, report.DryRun)
+	changed := driftOf(report)["chains/eth-main"]
+	assert.Equal(t, core.DriftChanged, changed.Kind)
+	assert.False(t, changed.Corrected)
+	assert.Equal(t, []core.ConfigChange{{Path: "endpoint", Op: core.ConfigChanged, From: "http://localhost:9545", To: "http://localhost:8545"}}, changed.Changes)
+	require.Equal(t, http.StatusOK, node.do(http.MethodGet, "/api/desired-state/drift", nil, &last))
+	assert.NotContains(t, driftOf(last), "chains/eth-main", "dry runs are not kept")
+
+	require.Equal(t, http.StatusOK, node.do(http.MethodPost, "/api/desired-state/reconcile", nil, &report))
+	assert.True(t, driftOf(report)["chains/eth-main"].Corrected)
+	var chain map[string]interface{}
+	require.Equal(t, http.StatusOK, node.do(http.MethodGet, "/api/agglomerator/chains/eth-main", nil, &chain))
+	assert.Equal(t, "http://localhost:8545", chain["endpoint"])
+
+	// Declaring every chain brings the node in sync
+	desired["chains"] = append(desired["chains"].([]map[string]interface{}),
+		map[string]interface{}{"id": "btc-main", "protocol": "btc", "endpoint": "http://localhost:8545"})
+	require.Equal(t, http.StatusOK, node.do(http.MethodPut, "/api/desired-state", desired, &report))
+	assert.Equal(t, 2, report.Revision)
+	assert.True(t, report.InSync)
+	assert.Empty(t, report.Drift)
+
+	var stored struct {
+		Revision int             `json:"revision"`
+		State    json.RawMessage `json:"state"`
+	}
+	require.Equal(t, http.StatusOK, node.do(http.MethodGet, "/api/desired-state", nil, &stored))
+	assert.Equal(t, 2, stored.Revision)
+	assert.Contains(t, string(stored.State), `"interval":"1m"`)
+}
+
+func TestDesiredStateReportMode(t *testing.T) {
+	node := startTestNode(t, t.TempDir(), nil)
+
+	desired := map[string]interface{}{
+		"mode":   "report",
+		"chains": []map[string]interface{}{{"id": "sol-main", "protocol": "sol", "endpoint": "http://localhost:8899"}},
+	}
+	var report core.DriftReport
+	require.Equal(t, http.StatusOK, node.do(http.MethodPut, "/api/desired-state", desired, &report))
+	assert.Equal(t, core.DesiredStateReport, report.Mode)
+	assert.Equal(t, []core.Drift{{Section: "chains", Resource: "sol-main", Kind: core.DriftMissing}}, report.Drift)
+	assert.False(t, report.InSync)
+	assert.Equal(t, http.StatusNotFound, node.do(http.MethodGet, "/api/agglomerator/chains/sol-main", nil, nil), "report mode changes nothing")
+}
+
+func TestDesiredStateRejectsInvalidDocuments(t *testing.T) {
+	node := startTestNode(t, t.TempDir(), nil)
+
+	for name, desired := range map[string]map[string]interface{}{
+		"unknown section":   {"tokens": []string{}},
+		"unknown mode":      {"mode": "apply"},
+		"short interval":    {"interval": "10ms"},
+		"chain without id":  {"chains": []map[string]interface{}{{"protocol": "eth"}}},
+		"duplicated chains": {"chains": []map[string]interface{}{{"id": "a", "protocol": "eth"}, {"id": "a", "protocol": "eth"}}},
+	} {
+		assert.Equal(t, http.StatusBadRequest, node.do(http.MethodPut, "/api/desired-state", desired, nil), name)
+	}
+
+	invalidConfig := map[string]interface{}{"modules": map[string]interface{}{"compression": map[string]interface{}{"maxRank": "high"}}}
+	assert.Equal(t, http.StatusUnprocessableEntity, node.do(http.MethodPut, "/api/desired-state", invalidConfig, nil))
+	assert.Equal(t, http.StatusNotFound, node.do(http.MethodGet, "/api/desired-state", nil, nil), "nothing was stored")
+}
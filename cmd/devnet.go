@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/agglomerator"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+	"gopkg.in/yaml.v3"
+)
+
+var devnetCmd = &cobra.Command{
+	Use:   "devnet",
+	Short: "Run a local multi-node development network",
+	Long: `Run a local network of agglomerator nodes with pre-wired bootstrap
+peers and mock chains, either in this process or as a docker-compose project.`,
+}
+
+var devnetUpCmd = &cobra.Command{
+	Use:          "up",
+	Short:        "Start a local development network",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := devnetOptions{}
+		opts.Nodes, _ = cmd.Flags().GetInt("nodes")
+		opts.Mode, _ = cmd.Flags().GetString("mode")
+		opts.Dir, _ = cmd.Flags().GetString("dir")
+		opts.APIPort, _ = cmd.Flags().GetInt("api-port")
+		opts.P2PPort, _ = cmd.Flags().GetInt("p2p-port")
+
+		if opts.Nodes < 1 {
+			return fmt.Errorf("--nodes must be at least 1")
+		}
+
+		switch opts.Mode {
+		case "inprocess":
+			return runDevnet(opts)
+		case "compose":
+			return writeDevnetCompose(opts)
+		default:
+			return fmt.Errorf("unknown mode %q (use inprocess or compose)", opts.Mode)
+		}
+	},
+}
+
+func init() {
+	devnetUpCmd.Flags().Int("nodes", 3, "number of nodes")
+	devnetUpCmd.Flags().String("mode", "inprocess", "how to run the nodes (inprocess, compose)")
+	devnetUpCmd.Flags().String("dir", ".devnet", "directory for node data and generated files")
+	devnetUpCmd.Flags().Int("api-port", 8100, "HTTP port of the first node; later nodes count up")
+	devnetUpCmd.Flags().Int("p2p-port", 9100, "P2P port of the first node; later nodes count up")
+	devnetCmd.AddCommand(devnetUpCmd)
+}
+
+type devnetOptions struct {
+	Nodes   int
+	Mode    string
+	Dir     string
+	APIPort int
+	P2PPort int
+}
+
+// devnetNode describes one node of the development network
+type devnetNode struct {
+	Name    string
+	NodeID  string
+	Host    string
+	APIPort int
+	P2PPort int
+}
+
+// devnetNodes lays out the network; in compose mode every node runs in its
+// own container on the default ports and is reached by service name
+func devnetNodes(opts devnetOptions) []devnetNode {
+	nodes := make([]devnetNode, opts.Nodes)
+	for i := range nodes {
+		node := devnetNode{
+			Name:    fmt.Sprintf("node-%d", i+1),
+			NodeID:  fmt.Sprintf("devnet-%d", i+1),
+			Host:    "127.0.0.1",
+			APIPort: opts.APIPort + i,
+			P2PPort: opts.P2PPort + i,
+		}
+		if opts.Mode == "compose" {
+			node.Host = node.Name
+			node.APIPort = 8088
+			node.P2PPort = opts.P2PPort
+		}
+		nodes[i] = node
+	}
+	return nodes
+}
+
+// devnetModules returns the module configuration for node i, with every
+// other node listed as a bootstrap peer
+func devnetModules(nodes []devnetNode, i int) map[string]map[string]interface{} {
+	node := nodes[i]
+
+	var peers []string
+	for j, peer := range nodes {
+		if j != i {
+			peers = append(peers, peer.NodeID+"@"+net.JoinHostPort(peer.Host, strconv.Itoa(peer.P2PPort)))
+		}
+	}
+
+	listen := node.Host
+	if node.Host != "127.0.0.1" {
+		listen = "0.0.0.0"
+	}
+
+	return map[string]map[string]interface{}{
+		"blockchain_agglomerator": {
+			"nodeID":       node.NodeID,
+			"version":      "1.0.0",
+			"vectorDims":   50,
+			"simThreshold": 0.7,
+			"logLevel":     "debug",
+			"p2p": map[string]interface{}{
+				"address":          listen,
+				"port":             node.P2PPort,
+				"bootstrapPeers":   peers,
+				"disableDiscovery": true,
+				"transport":        map[string]interface{}{"type": "tcp"},
+			},
+			"enabledChains": []map[string]interface{}{{
+				"id":       node.NodeID + "-chain",
+				"protocol": agglomerator.ProtocolMock,
+				"endpoint": fmt.Sprintf("mock://%s?blockTime=%s&jitter=250ms", node.NodeID, time.Duration(i+1)*time.Second),
+			}},
+		},
+	}
+}
+
+// runDevnet starts every node in this process and blocks until interrupted
+func runDevnet(opts devnetOptions) error {
+	nodes := devnetNodes(opts)
+	servers := make([]*http.Server, 0, len(nodes))
+	registries := make([]*core.ModuleRegistry, 0, len(nodes))
+
+	shutdown := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		for _, server := range servers {
+			server.Shutdown(ctx)
+		}
+		for _, registry := range registries {
+			for _, info := range registry.List() {
+				registry.TerminateCascade(ctx, info.Name)
+			}
+		}
+	}
+
+	errs := make(chan error, len(nodes))
+	for i, node := range nodes {
+		configManager, err := core.NewConfigManager(filepath.Join(opts.Dir, node.Name, "agglomerator.db"))
+		if err != nil {
+			shutdown()
+			return fmt.Errorf("%s: failed to initialize config manager: %w", node.Name, err)
+		}
+
+		dumper := core.NewCrashDumper(filepath.Join(opts.Dir, node.Name, "crash"))
+		router, registry, err := newService(context.Background(), configManager, devnetModules(nodes, i), nil, nil, dumper)
+		if err != nil {
+			shutdown()
+			return fmt.Errorf("%s: %w", node.Name, err)
+		}
+		registries = append(registries, registry)
+
+		server := &http.Server{
+			Addr:    net.JoinHostPort(node.Host, strconv.Itoa(node.APIPort)),
+			Handler: router,
+		}
+		servers = append(servers, server)
+		go func(name string) {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errs <- fmt.Errorf("%s: %w", name, err)
+			}
+		}(node.Name)
+	}
+
+	printDevnet(nodes)
+	fmt.Println("\nPress Ctrl+C to stop")
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	var err error
+	select {
+	case <-signals:
+	case err = <-errs:
+	}
+	shutdown()
+	return err
+}
+
+// writeDevnetCompose writes per-node configs and a docker-compose project
+// that runs each node from the source tree
+func writeDevnetCompose(opts devnetOptions) error {
+	nodes := devnetNodes(opts)
+
+	// The source tree is mounted into every container
+	root, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	services := make(map[string]interface{}, len(nodes))
+	for i, node := range nodes {
+		nodeDir := filepath.Join(opts.Dir, node.Name)
+		if err := os.MkdirAll(nodeDir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", nodeDir, err)
+		}
+
+		configData, err := yaml.Marshal(map[string]interface{}{"modules": devnetModules(nodes, i)})
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s config: %w", node.Name, err)
+		}
+		if err := os.WriteFile(filepath.Join(nodeDir, "config.yaml"), configData, 0644); err != nil {
+			return fmt.Errorf("failed to write %s config: %w", node.Name, err)
+		}
+
+		configPath, err := filepath.Abs(filepath.Join(nodeDir, "config.yaml"))
+		if err == nil {
+			configPath, err = filepath.Rel(root, configPath)
+		}
+		if err != nil || !filepath.IsLocal(configPath) {
+			return fmt.Errorf("--dir must be inside the source tree in compose mode")
+		}
+
+		services[node.Name] = map[string]interface{}{
+			"image":       "golang:1.23",
+			"working_dir": "/src/cmd",
+			"volumes":     []string{root + ":/src"},
+			"command": []string{
+				"go", "run", ".", "start",
+				"--config", "/src/" + filepath.ToSlash(configPath),
+				"--data-dir", "/data",
+			},
+			"ports": []string{fmt.Sprintf("%d:%d", opts.APIPort+i, node.APIPort)},
+		}
+	}
+
+	composeData, err := yaml.Marshal(map[string]interface{}{"services": services})
+	if err != nil {
+		return fmt.Errorf("failed to marshal compose file: %w", err)
+	}
+	composeFile := filepath.Join(opts.Dir, "docker-compose.yml")
+	if err := os.WriteFile(composeFile, composeData, 0644); err != nil {
+		return fmt.Errorf("failed to write compose file: %w", err)
+	}
+
+	printDevnet(nodes)
+	fmt.Printf("\nWrote %s; start the network with:\n\n\tdocker compose -f %s up\n", composeFile, composeFile)
+	return nil
+}
+
+func printDevnet(nodes []devnetNode) {
+	fmt.Printf("%-8s %-10s %-22s %s\n", "NODE", "NODE ID", "API", "P2P")
+	for _, node := range nodes {
+		fmt.Printf("%-8s %-10s %-22s %s\n",
+			node.Name,
+			node.NodeID,
+			net.JoinHostPort(node.Host, strconv.Itoa(node.APIPort)),
+			net.JoinHostPort(node.Host, strconv.Itoa(node.P2PPort)),
+		)
+	}
+}
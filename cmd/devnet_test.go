@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+	"gopkg.in/yaml.v3"
+)
+
+func TestDevnetLayout(t *testing.T) {
+	nodes := devnetNodes(devnetOptions{Nodes: 3, Mode: "inprocess", APIPort: 8100, P2PPort: 9100})
+	require.Len(t, nodes, 3)
+	assert.Equal(t, devnetNode{Name: "node-3", NodeID: "devnet-3", Host: "127.0.0.1", APIPort: 8102, P2PPort: 9102}, nodes[2])
+
+	// Every node bootstraps from all the others
+	p2p := devnetModules(nodes, 1)["blockchain_agglomerator"]["p2p"].(map[string]interface{})
+	assert.Equal(t, []string{"devnet-1@127.0.0.1:9100", "devnet-3@127.0.0.1:9102"}, p2p["bootstrapPeers"])
+	assert.Equal(t, "127.0.0.1", p2p["address"])
+
+	// Containers use the default ports and reach each other by service name
+	nodes = devnetNodes(devnetOptions{Nodes: 2, Mode: "compose", APIPort: 8100, P2PPort: 9100})
+	assert.Equal(t, devnetNode{Name: "node-2", NodeID: "devnet-2", Host: "node-2", APIPort: 8088, P2PPort: 9100}, nodes[1])
+	p2p = devnetModules(nodes, 1)["blockchain_agglomerator"]["p2p"].(map[string]interface{})
+	assert.Equal(t, []string{"devnet-1@node-1:9100"}, p2p["bootstrapPeers"])
+	assert.Equal(t, "0.0.0.0", p2p["address"])
+}
+
+func TestDevnetNodesConnect(t *testing.T) {
+	nodes := devnetNodes(devnetOptions{Nodes: 2, Mode: "inprocess"})
+	for i := range nodes {
+		nodes[i].P2PPort = freePort(t)
+	}
+
+	var servers []*httptest.Server
+	for i := range nodes {
+		configManager, err := core.NewMemoryConfigManager()
+		require.NoError(t, err)
+		dumper := core.NewCrashDumper(filepath.Join(t.TempDir(), "crash"))
+		router, registry, err := newService(context.Background(), configManager, devnetModules(nodes, i), nil, nil, dumper)
+		require.NoError(t, err)
+
+		server := httptest.NewServer(router)
+		servers = append(servers, server)
+		t.Cleanup(func() {
+			server.Close()
+			for _, info := range registry.List() {
+				registry.TerminateCascade(context.Background(), info.Name)
+			}
+			configManager.Close()
+		})
+	}
+
+	for _, server := range servers {
+		node := &testNode{t: t, server: server}
+		assert.Eventually(t, func() bool {
+			var stats struct {
+				Peers int `json:"peers"`
+			}
+			return node.do("GET", "/api/p2p/stats", nil, &stats) == 200 && stats.Peers == 1
+		}, 10*time.Second, 50*time.Millisecond)
+	}
+}
+
+func TestDevnetCompose(t *testing.T) {
+	// Compose mounts the source tree, so the output must be inside it
+	err := writeDevnetCompose(devnetOptions{Nodes: 2, Mode: "compose", Dir: t.TempDir(), APIPort: 8100, P2PPort: 9100})
+	assert.ErrorContains(t, err, "--dir must be inside the source tree")
+
+	dir, err := os.MkdirTemp(".", "devnet-test-")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	require.NoError(t, writeDevnetCompose(devnetOptions{Nodes: 2, Mode: "compose", Dir: dir, APIPort: 8100, P2PPort: 9100}))
+
+	var compose struct {
+		Services map[string]struct {
+			Command []string `yaml:"command"`
+			Ports   []string `yaml:"ports"`
+		} `yaml:"services"`
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "docker-compose.yml"))
+	require.NoError(t, err)
+	require.NoError(t, yaml.Unmarshal(data, &compose))
+	require.Len(t, compose.Services, 2)
+	assert.Equal(t, []string{"8101:8088"}, compose.Services["node-2"].Ports)
+	assert.Contains(t, compose.Services["node-2"].Command, "/src/"+filepath.ToSlash(filepath.Join(dir, "node-2", "config.yaml")))
+
+	var config struct {
+		Modules map[string]struct {
+			NodeID string `yaml:"nodeID"`
+		} `yaml:"modules"`
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "node-2", "config.yaml"))
+	require.NoError(t, err)
+	require.NoError(t, yaml.Unmarshal(data, &config))
+	assert.Equal(t, "devnet-2", config.Modules["blockchain_agglomerator"].NodeID)
+}
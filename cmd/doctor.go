@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/theaxiomverse/hydap-api/pkg/keymanagement"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/agglomerator"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the node's environment",
+	Long: `Check the config and storage databases, free disk space, liboqs, the HTTP and P2P ports and
+bootstrap peers before starting a node, or ask a running node with --server, which also reports
+peer clock skew. Exits non-zero when a check fails.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configFile, _ := cmd.Flags().GetString("config")
+		dataDir, _ := cmd.Flags().GetString("data-dir")
+		addr, _ := cmd.Flags().GetString("addr")
+		server, _ := cmd.Flags().GetString("server")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		var report core.DiagnosticReport
+		var err error
+		if server != "" {
+			report, err = fetchDiagnostics(server)
+		} else {
+			report, err = runDiagnostics(cmd.Context(), configFile, dataDir, addr, secretsProvider(cmd))
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := printDiagnostics(report, asJSON); err != nil {
+			return err
+		}
+		if report.Status == core.CheckFail {
+			return fmt.Errorf("diagnostics failed")
+		}
+		return nil
+	},
+}
+
+func init() {
+	doctorCmd.Flags().String("data-dir", "./data", "directory for the module config database")
+	doctorCmd.Flags().String("addr", ":8088", "HTTP listen address")
+	doctorCmd.Flags().String("server", "", "base URL of a running node's HTTP API")
+	doctorCmd.Flags().Bool("json", false, "print the report as JSON")
+}
+
+// checkLiboqs warns when liboqs lacks signature algorithms offered to peers
+func checkLiboqs(ctx context.Context) (core.CheckStatus, string) {
+	version := keymanagement.LiboqsVersion()
+	if missing := keymanagement.MissingSignatureAlgorithms(); len(missing) > 0 {
+		return core.CheckWarn, fmt.Sprintf("liboqs %s lacks %s", version, strings.Join(missing, ", "))
+	}
+	return core.CheckPass, "liboqs " + version
+}
+
+// runDiagnostics checks the environment of a node that is not running
+func runDiagnostics(ctx context.Context, configFile, dataDir, addr string, secrets core.SecretProvider) (core.DiagnosticReport, error) {
+	modules, err := loadStartupModules(configFile, "", dataDir, secrets)
+	if err != nil {
+		return core.DiagnosticReport{}, err
+	}
+	data, err := json.Marshal(modules["blockchain_agglomerator"])
+	if err != nil {
+		return core.DiagnosticReport{}, fmt.Errorf("failed to marshal module config: %w", err)
+	}
+	var config agglomerator.ModuleConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return core.DiagnosticReport{}, fmt.Errorf("failed to parse module config: %w", err)
+	}
+
+	diagnostics := core.NewDiagnostics()
+	diagnostics.Register("config.db", core.CheckDatabaseFile(filepath.Join(dataDir, "agglomerator.db")))
+	diagnostics.Register("liboqs", checkLiboqs)
+	diagnostics.Register("http.port", core.CheckPortBindable(addr))
+	agglomerator.RegisterDiagnostics(diagnostics, &config)
+	return diagnostics.Run(ctx), nil
+}
+
+func fetchDiagnostics(server string) (core.DiagnosticReport, error) {
+	var report core.DiagnosticReport
+	url := strings.TrimSuffix(server, "/") + "/api/admin/diagnostics"
+	resp, err := http.Get(url)
+	if err != nil {
+		return report, err
+	}
+	defer resp.Body.Close()
+
+	// A failing report is served with 503
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return report, fmt.Errorf("failed to fetch diagnostics: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return report, fmt.Errorf("failed to decode diagnostics: %w", err)
+	}
+	return report, nil
+}
+
+func printDiagnostics(report core.DiagnosticReport, asJSON bool) error {
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	fmt.Printf("%-6s %-24s %s\n", "STATUS", "CHECK", "MESSAGE")
+	fmt.Println(strings.Repeat("-", 70))
+	for _, check := range report.Checks {
+		fmt.Printf("%-6s %-24s %s\n", check.Status, check.Name, check.Message)
+	}
+	fmt.Printf("\nOverall: %s\n", report.Status)
+	return nil
+}
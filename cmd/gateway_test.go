@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+func TestGatewayRouting(t *testing.T) {
+	var mu sync.Mutex
+	var forwarded []*http.Request
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		forwarded = append(forwarded, r)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer remote.Close()
+
+	gateways, err := core.NewGatewayRouter("a", map[string]string{"a": "http://localhost:1", "b": remote.URL})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, gateways.Gateways())
+
+	// Pick a module each gateway owns
+	owned := map[string]string{}
+	for _, module := range []string{"alpha", "beta", "gamma", "delta", "epsilon", "zeta", "eta", "theta"} {
+		owner := gateways.Owner(module)
+		assert.Equal(t, owner, gateways.Owner(module), "ownership is stable")
+		if _, ok := owned[owner]; !ok {
+			owned[owner] = module
+		}
+	}
+	require.Len(t, owned, 2)
+	gateways.Route("/api/local", owned["a"])
+	gateways.Route("/api/remote", owned["b"])
+
+	handler := gateways.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	local := serve("/api/local/chains", nil)
+	assert.Equal(t, http.StatusOK, local.Code)
+	assert.Equal(t, "a", local.Header().Get(core.GatewayHeader))
+
+	managed := serve("/api/modules/"+owned["b"]+"/health", nil)
+	assert.Equal(t, http.StatusAccepted, managed.Code, "module management routes go to the owner")
+	proxied := serve("/api/remote/chains?limit=2", nil)
+	assert.Equal(t, http.StatusAccepted, proxied.Code)
+	mu.Lock()
+	require.Len(t, forwarded, 2)
+	mu.Unlock()
+	assert.Equal(t, "/api/remote/chains", forwarded[1].URL.Path)
+	assert.Equal(t, "limit=2", forwarded[1].URL.RawQuery)
+	assert.Equal(t, "a", forwarded[1].Header.Get(core.ForwardedByHeader))
+
+	again := serve("/api/remote/chains", http.Header{core.ForwardedByHeader: {"c"}})
+	assert.Equal(t, http.StatusOK, again.Code, "forwarded requests are not forwarded again")
+	assert.Equal(t, http.StatusOK, serve("/api/modules", nil).Code, "routes of no module are served locally")
+	mu.Lock()
+	assert.Len(t, forwarded, 2)
+	mu.Unlock()
+
+	remote.Close()
+	down := serve("/api/remote/chains", nil)
+	assert.Equal(t, http.StatusBadGateway, down.Code)
+	assert.Contains(t, down.Body.String(), "gateway b is unreachable")
+
+	_, err = core.NewGatewayRouter("c", map[string]string{"a": remote.URL})
+	assert.ErrorIs(t, err, core.ErrInvalidGateways)
+	_, err = core.NewGatewayRouter("a", map[string]string{"a": "localhost:8088"})
+	assert.ErrorIs(t, err, core.ErrInvalidGateways)
+}
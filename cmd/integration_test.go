@@ -0,0 +1,284 @@
+//go:build integration
+
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/base"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+type historyResponse struct {
+	Transactions []struct {
+		ID        string `json:"id"`
+		FromChain string `json:"fromChain"`
+		ToChain   string `json:"toChain"`
+		Status    string `json:"status"`
+		Size      int    `json:"size"`
+	} `json:"transactions"`
+	Count int `json:"count"`
+}
+
+type poolResponse struct {
+	Transactions []struct {
+		TxID     string  `json:"txId"`
+		Priority int     `json:"priority"`
+		Fee      float64 `json:"fee"`
+	} `json:"transactions"`
+}
+
+func TestIntegrationTransactionLifecycle(t *testing.T) {
+	node := startTestNode(t, t.TempDir(), nil)
+	node.registerChains(map[string]string{"eth-main": "eth", "sol-main": "sol"})
+
+	var chains []map[string]interface{}
+	require.Equal(t, http.StatusOK, node.do(http.MethodGet, "/api/agglomerator/chains", nil, &chains))
+	assert.Len(t, chains, 2)
+
+	var modules []core.ModuleInfo
+	require.Equal(t, http.StatusOK, node.do(http.MethodGet, "/api/modules", nil, &modules))
+	for _, info := range modules {
+		if info.Name == "blockchain_agglomerator" {
+			assert.Equal(t, base.StateRunning, info.Status)
+		}
+	}
+
+	tx := map[string]interface{}{
+		"id": "tx-1", "fromChain": "sol-main", "toChain": "eth-main",
+		"data": []byte("hello"), "similarity": 0.5, "priority": 2, "fee": 1.5,
+	}
+	var accepted map[string]interface{}
+	require.Equal(t, http.StatusAccepted, node.do(http.MethodPost, "/api/agglomerator/transaction", tx, &accepted))
+	assert.Equal(t, "accepted", accepted["status"])
+
+	unroutable := map[string]interface{}{"id": "tx-2", "fromChain": "unknown", "toChain": "eth-main", "similarity": 0.5}
+	assert.Equal(t, http.StatusInternalServerError, node.do(http.MethodPost, "/api/agglomerator/transaction", unroutable, nil))
+
+	// Status in the SQLite history
+	var history historyResponse
+	require.Equal(t, http.StatusOK, node.do(http.MethodGet, "/api/agglomerator/transactions?q=status:completed", nil, &history))
+	require.Equal(t, 1, history.Count)
+	assert.Equal(t, "tx-1", history.Transactions[0].ID)
+	assert.Equal(t, "sol-main", history.Transactions[0].FromChain)
+	assert.Equal(t, "eth-main", history.Transactions[0].ToChain)
+	assert.Equal(t, len("hello"), history.Transactions[0].Size)
+
+	require.Equal(t, http.StatusOK, node.do(http.MethodGet, "/api/agglomerator/transactions?q=status:failed", nil, &history))
+	require.Equal(t, 1, history.Count)
+	assert.Equal(t, "tx-2", history.Transactions[0].ID)
+	assert.Equal(t, http.StatusOK, node.do(http.MethodGet, "/api/agglomerator/transactions/tx-1/route", nil, nil))
+
+	// Both chains' pools hold the routed transaction
+	for _, chainID := range []string{"sol-main", "eth-main"} {
+		var pool poolResponse
+		require.Equal(t, http.StatusOK, node.do(http.MethodGet, "/api/agglomerator/chains/"+chainID+"/pool", nil, &pool))
+		require.Len(t, pool.Transactions, 1, chainID)
+		assert.Equal(t, "tx-1", pool.Transactions[0].TxID)
+		assert.Equal(t, 2, pool.Transactions[0].Priority)
+		assert.Equal(t, 1.5, pool.Transactions[0].Fee)
+	}
+	assert.Equal(t, http.StatusOK, node.do(http.MethodGet, "/api/agglomerator/chains/eth-main/transactions/tx-1", nil, nil))
+
+	// Metrics are sampled in the background
+	require.Eventually(t, func() bool {
+		var response struct {
+			Series map[string][]struct {
+				Value float64 `json:"v"`
+			} `json:"series"`
+		}
+		if node.do(http.MethodGet, "/api/metrics/history?series=tx_throughput,tx_failures,chain_count", nil, &response) != http.StatusOK {
+			return false
+		}
+		seen := func(name string, ok func(float64) bool) bool {
+			for _, sample := range response.Series[name] {
+				if ok(sample.Value) {
+					return true
+				}
+			}
+			return false
+		}
+		return seen("tx_throughput", func(v float64) bool { return v > 0 }) &&
+			seen("tx_failures", func(v float64) bool { return v > 0 }) &&
+			seen("chain_count", func(v float64) bool { return v == 2 })
+	}, 5*time.Second, 50*time.Millisecond, "metrics history records the transactions and chains")
+}
+
+func TestIntegrationBatchTransactions(t *testing.T) {
+	node := startTestNode(t, t.TempDir(), nil)
+	node.registerChains(map[string]string{"eth-main": "eth", "sol-main": "sol", "dot-main": "dot"})
+
+	batch := []map[string]interface{}{
+		{"id": "batch-1", "fromChain": "sol-main", "toChain": "eth-main", "similarity": 0.5},
+		{"id": "batch-2", "fromChain": "sol-main", "toChain": "dot-main", "similarity": 0.5},
+		{"id": "batch-3", "fromChain": "sol-main", "toChain": "missing", "similarity": 0.5},
+	}
+	var results []map[string]interface{}
+	require.Equal(t, http.StatusOK, node.do(http.MethodPost, "/api/agglomerator/transactions/batch", batch, &results))
+	require.Len(t, results, 3)
+	assert.Equal(t, "accepted", results[0]["status"])
+	assert.Equal(t, "accepted", results[1]["status"])
+	assert.Equal(t, "failed", results[2]["status"], "unknown destination")
+
+	var pool poolResponse
+	require.Equal(t, http.StatusOK, node.do(http.MethodGet, "/api/agglomerator/chains/sol-main/pool", nil, &pool))
+	assert.Len(t, pool.Transactions, 2)
+
+	var history historyResponse
+	require.Equal(t, http.StatusOK, node.do(http.MethodGet, "/api/agglomerator/transactions?q=from:sol-main", nil, &history))
+	assert.Equal(t, 3, history.Count)
+}
+
+func TestIntegrationStateSurvivesRestart(t *testing.T) {
+	dataDir := t.TempDir()
+	node := startTestNode(t, dataDir, nil)
+	node.registerChains(map[string]string{"eth-main": "eth", "sol-main": "sol"})
+	tx := map[string]interface{}{"id": "tx-1", "fromChain": "eth-main", "toChain": "sol-main", "similarity": 0.5}
+	require.Equal(t, http.StatusAccepted, node.do(http.MethodPost, "/api/agglomerator/transaction", tx, nil))
+	node.stop()
+
+	// Chains registered through the API are restored from the event log
+	node = startTestNode(t, dataDir, nil)
+	var chains []map[string]interface{}
+	require.Equal(t, http.StatusOK, node.do(http.MethodGet, "/api/agglomerator/chains", nil, &chains))
+	assert.Len(t, chains, 2)
+
+	var history historyResponse
+	require.Equal(t, http.StatusOK, node.do(http.MethodGet, "/api/agglomerator/transactions?q=status:completed", nil, &history))
+	require.Equal(t, 1, history.Count)
+	assert.Equal(t, "tx-1", history.Transactions[0].ID)
+
+	tx["id"] = "tx-2"
+	assert.Equal(t, http.StatusAccepted, node.do(http.MethodPost, "/api/agglomerator/transaction", tx, nil), "restored chains route")
+}
+
+func TestIntegrationCompressionAndETags(t *testing.T) {
+	node := startTestNode(t, t.TempDir(), nil)
+	node.registerChains(map[string]string{"eth-main": "eth", "sol-main": "sol"})
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	get := func(path string, header http.Header) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, node.server.URL+path, nil)
+		require.NoError(t, err)
+		req.Header = header
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	resp := get("/api/agglomerator/chains", http.Header{"Accept-Encoding": {"gzip"}})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	body, err := gzip.NewReader(resp.Body)
+	require.NoError(t, err)
+	var chains []map[string]interface{}
+	require.NoError(t, json.NewDecoder(body).Decode(&chains))
+	assert.Len(t, chains, 2)
+
+	tag := resp.Header.Get("ETag")
+	require.NotEmpty(t, tag)
+	resp = get("/api/agglomerator/chains", http.Header{"If-None-Match": {tag}})
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode, "an unchanged list is not sent again")
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+
+	node.registerChains(map[string]string{"dot-main": "dot"})
+	resp = get("/api/agglomerator/chains", http.Header{"If-None-Match": {tag}})
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "registering a chain changes the list")
+	assert.NotEqual(t, tag, resp.Header.Get("ETag"))
+
+	for _, path := range []string{"/api/modules", "/api/modules/blockchain_agglomerator/config/revisions"} {
+		resp = get(path, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode, path)
+		assert.Equal(t, http.StatusNotModified, get(path, http.Header{"If-None-Match": {resp.Header.Get("ETag")}}).StatusCode, path)
+	}
+}
+
+func TestIntegrationBulkConfigIsAllOrNothing(t *testing.T) {
+	node := startTestNode(t, t.TempDir(), nil)
+	revisions := func(module string) int {
+		var list []core.ConfigRevision
+		if node.do(http.MethodGet, "/api/modules/"+module+"/config/revisions", nil, &list) != http.StatusOK {
+			return 0
+		}
+		return len(list)
+	}
+	before := revisions("blockchain_agglomerator")
+
+	var rejected struct {
+		Problems map[string][]string `json:"problems"`
+	}
+	require.Equal(t, http.StatusUnprocessableEntity, node.do(http.MethodPut, "/api/modules/config/bulk", map[string]interface{}{
+		"blockchain_agglomerator": map[string]interface{}{"nodeID": "integration", "vectorDims": 64},
+		"compression":             map[string]interface{}{"maxRank": "high"},
+	}, &rejected))
+	assert.Contains(t, rejected.Problems, "compression")
+	assert.NotContains(t, rejected.Problems, "blockchain_agglomerator")
+	assert.Equal(t, before, revisions("blockchain_agglomerator"), "the valid config is not applied alone")
+	assert.Zero(t, revisions("compression"))
+
+	var applied struct {
+		Revisions map[string]int `json:"revisions"`
+	}
+	require.Equal(t, http.StatusOK, node.do(http.MethodPut, "/api/modules/config/bulk", map[string]interface{}{
+		"blockchain_agglomerator": map[string]interface{}{"nodeID": "integration", "vectorDims": 64},
+		"compression":             map[string]interface{}{"maxRank": 8},
+	}, &applied))
+	assert.Equal(t, map[string]int{"blockchain_agglomerator": before + 1, "compression": 1}, applied.Revisions)
+}
+
+func TestIntegrationConfigPresets(t *testing.T) {
+	node := startTestNode(t, t.TempDir(), nil)
+
+	require.Equal(t, http.StatusOK, node.do(http.MethodPut, "/api/presets/testnet-fast", map[string]interface{}{
+		"module": "compression",
+		"config": map[string]interface{}{"maxRank": 4, "tolerance": 0.05, "forceMaxRank": true},
+	}, nil))
+	require.Equal(t, http.StatusUnprocessableEntity, node.do(http.MethodPut, "/api/presets/broken", map[string]interface{}{
+		"module": "compression",
+		"config": map[string]interface{}{"maxRank": "high"},
+	}, nil), "presets are validated as the module's config")
+	require.Equal(t, http.StatusBadRequest, node.do(http.MethodPut, "/api/presets/Bad%20Name", map[string]interface{}{
+		"module": "compression",
+		"config": map[string]interface{}{"maxRank": 4},
+	}, nil))
+
+	var applied struct {
+		Module   string                 `json:"module"`
+		Revision int                    `json:"revision"`
+		Config   map[string]interface{} `json:"config"`
+	}
+	require.Equal(t, http.StatusOK, node.do(http.MethodPost, "/api/presets/testnet-fast/apply?dryRun=true", map[string]interface{}{
+		"overrides": map[string]interface{}{"maxRank": 8, "forceMaxRank": nil},
+	}, &applied))
+	assert.Equal(t, map[string]interface{}{"maxRank": float64(8), "tolerance": 0.05}, applied.Config, "null overrides remove a key")
+	assert.Zero(t, applied.Revision, "a dry run stores nothing")
+
+	require.Equal(t, http.StatusUnprocessableEntity, node.do(http.MethodPost, "/api/presets/testnet-fast/apply", map[string]interface{}{
+		"overrides": map[string]interface{}{"maxRank": 0},
+	}, nil))
+
+	require.Equal(t, http.StatusOK, node.do(http.MethodPost, "/api/presets/testnet-fast/apply", map[string]interface{}{
+		"overrides": map[string]interface{}{"maxRank": 8},
+	}, &applied))
+	assert.Equal(t, "compression", applied.Module)
+	assert.Equal(t, 1, applied.Revision, "only the valid apply is stored")
+
+	stored, err := node.configManager.GetConfigRevision("compression", applied.Revision)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"maxRank": 8, "tolerance": 0.05, "forceMaxRank": true}`, string(stored.Config))
+
+	var presets []core.ConfigPreset
+	require.Equal(t, http.StatusOK, node.do(http.MethodGet, "/api/presets", nil, &presets))
+	require.Len(t, presets, 1)
+	assert.Equal(t, "testnet-fast", presets[0].Name)
+	assert.Nil(t, presets[0].Config, "configs are left out of the list")
+
+	require.Equal(t, http.StatusNoContent, node.do(http.MethodDelete, "/api/presets/testnet-fast", nil, nil))
+	assert.Equal(t, http.StatusNotFound, node.do(http.MethodPost, "/api/presets/testnet-fast/apply", nil, nil))
+}
@@ -20,10 +20,19 @@ func init() {
 	rootCmd.AddCommand(startCmd)
 	rootCmd.AddCommand(chainCmd)
 	rootCmd.AddCommand(txCmd)
+	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(moduleCmd)
+	rootCmd.AddCommand(devnetCmd)
+	rootCmd.AddCommand(topologyCmd)
+	rootCmd.AddCommand(tokenCmd)
+	rootCmd.AddCommand(attestationCmd)
+	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(doctorCmd)
 
 	// Global flags
 	rootCmd.PersistentFlags().StringP("config", "c", "config.yaml", "config file path")
 	rootCmd.PersistentFlags().StringP("log-level", "l", "info", "log level (debug, info, warn, error)")
+	rootCmd.PersistentFlags().String("secrets-dir", "", "directory for ${secret:...} config references (default $HYDAP_SECRETS_DIR or "+defaultSecretsDir+")")
 }
 
 func main() {
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"go/format"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+//go:embed templates/module/*.tmpl
+var moduleTemplates embed.FS
+
+var moduleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+var moduleCmd = &cobra.Command{
+	Use:   "module",
+	Short: "Module development helpers",
+	Long:  `Helpers for developing modules that plug into the module registry.`,
+}
+
+var moduleScaffoldCmd = &cobra.Command{
+	Use:          "scaffold [name]",
+	Short:        "Generate a module skeleton",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, _ := cmd.Flags().GetString("dir")
+		force, _ := cmd.Flags().GetBool("force")
+
+		data, err := newScaffoldData(args[0])
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dir, data.Package)
+		files, err := scaffoldModule(target, data, force)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			fmt.Printf("created %s\n", file)
+		}
+		fmt.Printf(`
+Register the module in newService:
+
+	%[1]sModule := %[2]s.New%[3]s(configManager, metrics, logger)
+	if err := registry.Register(ctx, %[1]sModule); err != nil {
+		return fmt.Errorf("failed to register %[4]s module: %%w", err)
+	}
+	router.Mount("/api/%[4]s", %[2]s.NewAPI(%[1]sModule).Routes())
+`, data.Var, data.Package, data.Type, data.Name)
+		return nil
+	},
+}
+
+func init() {
+	moduleScaffoldCmd.Flags().String("dir", "pkg/modules", "parent directory for the generated package")
+	moduleScaffoldCmd.Flags().Bool("force", false, "overwrite files in an existing package directory")
+	moduleCmd.AddCommand(moduleScaffoldCmd)
+}
+
+// scaffoldData holds the identifiers substituted into module templates
+type scaffoldData struct {
+	Name    string
+	Package string
+	Type    string
+	Loader  string
+	Var     string
+}
+
+func newScaffoldData(name string) (scaffoldData, error) {
+	if !moduleNamePattern.MatchString(name) {
+		return scaffoldData{}, fmt.Errorf("invalid module name %q: use lowercase letters, digits and underscores", name)
+	}
+
+	var camel strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		if part == "" {
+			continue
+		}
+		camel.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+
+	pkg := strings.ReplaceAll(name, "_", "")
+	return scaffoldData{
+		Name:    name,
+		Package: pkg,
+		Type:    camel.String() + "Module",
+		Loader:  camel.String() + "Loader",
+		Var:     pkg,
+	}, nil
+}
+
+// scaffoldModule renders every module template into dir and returns the written paths
+func scaffoldModule(dir string, data scaffoldData, force bool) ([]string, error) {
+	if _, err := os.Stat(dir); err == nil && !force {
+		return nil, fmt.Errorf("%s already exists (use --force to overwrite)", dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create module directory: %w", err)
+	}
+
+	names, err := fs.Glob(moduleTemplates, "templates/module/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	var written []string
+	for _, name := range names {
+		tmpl, err := template.ParseFS(moduleTemplates, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+		}
+
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("failed to render template %s: %w", name, err)
+		}
+
+		source, err := format.Source(buf.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("failed to format %s: %w", name, err)
+		}
+
+		file := filepath.Join(dir, strings.TrimSuffix(path.Base(name), ".tmpl"))
+		if err := os.WriteFile(file, source, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", file, err)
+		}
+		written = append(written, file)
+	}
+
+	return written, nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+// testNode is a service wired as the start command wires it, with its
+// SQLite databases in a temporary data directory, served on a local port
+type testNode struct {
+	t             *testing.T
+	dataDir       string
+	server        *httptest.Server
+	registry      *core.ModuleRegistry
+	configManager *core.ConfigManager
+}
+
+// startTestNode boots a node on dataDir, with settings overriding the
+// agglomerator's defaults. The node is stopped when the test ends, if it has
+// not been already.
+func startTestNode(t *testing.T, dataDir string, settings map[string]interface{}) *testNode {
+	t.Helper()
+	configManager, err := core.NewConfigManager(filepath.Join(dataDir, "agglomerator.db"))
+	require.NoError(t, err)
+
+	config := map[string]interface{}{
+		"nodeID":       "integration",
+		"vectorDims":   50,
+		"simThreshold": 0.7,
+		"storage":      map[string]interface{}{"path": filepath.Join(dataDir, "storage")},
+		"metrics":      map[string]interface{}{"enabled": true, "interval": "50ms"},
+	}
+	for key, value := range settings {
+		config[key] = value
+	}
+	modules := map[string]map[string]interface{}{"blockchain_agglomerator": config}
+	dumper := core.NewCrashDumper(filepath.Join(dataDir, "crash"))
+	router, registry, err := newService(context.Background(), configManager, modules, nil, nil, dumper)
+	require.NoError(t, err)
+
+	node := &testNode{
+		t:             t,
+		dataDir:       dataDir,
+		server:        httptest.NewServer(router),
+		registry:      registry,
+		configManager: configManager,
+	}
+	t.Cleanup(node.stop)
+	return node
+}
+
+// stop shuts the server down and terminates the modules, closing their
+// databases so the data directory can be booted again
+func (n *testNode) stop() {
+	if n.server == nil {
+		return
+	}
+	n.server.Close()
+	n.server = nil
+	for _, info := range n.registry.List() {
+		assert.NoError(n.t, n.registry.Terminate(context.Background(), info.Name))
+	}
+	assert.NoError(n.t, n.configManager.Close())
+}
+
+// do sends body, JSON encoded unless nil, and decodes the response into out
+// unless it is nil, returning the status code
+func (n *testNode) do(method, path string, body, out interface{}) int {
+	n.t.Helper()
+	var reader bytes.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		require.NoError(n.t, err)
+		reader.Reset(encoded)
+	}
+	req, err := http.NewRequest(method, n.server.URL+path, &reader)
+	require.NoError(n.t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(n.t, err)
+	defer resp.Body.Close()
+	if out != nil {
+		require.NoError(n.t, json.NewDecoder(resp.Body).Decode(out), "%s %s", method, path)
+	}
+	return resp.StatusCode
+}
+
+// registerChains registers chains of the given protocols through the API
+func (n *testNode) registerChains(protocols map[string]string) {
+	n.t.Helper()
+	for id, protocol := range protocols {
+		chain := map[string]interface{}{"id": id, "endpoint": "http://localhost:8545", "protocol": protocol}
+		require.Equal(n.t, http.StatusCreated, n.do(http.MethodPost, "/api/agglomerator/chains", chain, nil), "register %s", id)
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+	"gopkg.in/yaml.v3"
+)
+
+var configPresetCmd = &cobra.Command{
+	Use:   "preset",
+	Short: "Manage named module config presets",
+	Long: `Save, list, show, delete and apply named module config presets, such as
+mainnet-conservative or testnet-fast. Applying a preset stores a new revision of
+its module's config: the preset with any overrides merged in.`,
+}
+
+var configPresetSaveCmd = &cobra.Command{
+	Use:          "save [name]",
+	Short:        "Save a module config file as a preset",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dataDir, _ := cmd.Flags().GetString("data-dir")
+		module, _ := cmd.Flags().GetString("module")
+		file, _ := cmd.Flags().GetString("file")
+		description, _ := cmd.Flags().GetString("description")
+		return savePreset(dataDir, args[0], module, file, description)
+	},
+}
+
+var configPresetListCmd = &cobra.Command{
+	Use:          "list",
+	Short:        "List saved presets",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dataDir, _ := cmd.Flags().GetString("data-dir")
+		return listPresets(dataDir)
+	},
+}
+
+var configPresetShowCmd = &cobra.Command{
+	Use:          "show [name]",
+	Short:        "Print a preset's config",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dataDir, _ := cmd.Flags().GetString("data-dir")
+		return showPreset(dataDir, args[0])
+	},
+}
+
+var configPresetDeleteCmd = &cobra.Command{
+	Use:          "delete [name]",
+	Short:        "Delete a preset",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dataDir, _ := cmd.Flags().GetString("data-dir")
+		return deletePreset(dataDir, args[0])
+	},
+}
+
+var configPresetApplyCmd = &cobra.Command{
+	Use:          "apply [name]",
+	Short:        "Apply a preset with overrides to its module's config",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dataDir, _ := cmd.Flags().GetString("data-dir")
+		file, _ := cmd.Flags().GetString("overrides")
+		sets, _ := cmd.Flags().GetStringArray("set")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		overrides, err := presetOverrides(file, sets)
+		if err != nil {
+			return err
+		}
+		return applyPreset(dataDir, args[0], overrides, dryRun)
+	},
+}
+
+func init() {
+	configPresetCmd.PersistentFlags().String("data-dir", "./data", "directory for the module config database")
+	configPresetSaveCmd.Flags().String("module", "", "module the preset configures")
+	configPresetSaveCmd.Flags().String("file", "", "YAML or JSON file holding the module's config")
+	configPresetSaveCmd.Flags().String("description", "", "what the preset is for")
+	configPresetSaveCmd.MarkFlagRequired("module")
+	configPresetSaveCmd.MarkFlagRequired("file")
+	configPresetApplyCmd.Flags().String("overrides", "", "YAML or JSON file of overrides merged into the preset")
+	configPresetApplyCmd.Flags().StringArray("set", nil, "override as path=value, e.g. routing.maxHops=3; the value is JSON or else a string")
+	configPresetApplyCmd.Flags().Bool("dry-run", false, "print the config without storing it")
+	configPresetCmd.AddCommand(configPresetSaveCmd)
+	configPresetCmd.AddCommand(configPresetListCmd)
+	configPresetCmd.AddCommand(configPresetShowCmd)
+	configPresetCmd.AddCommand(configPresetDeleteCmd)
+	configPresetCmd.AddCommand(configPresetApplyCmd)
+	configCmd.AddCommand(configPresetCmd)
+}
+
+func openConfigManager(dataDir string) (*core.ConfigManager, error) {
+	configManager, err := core.NewConfigManager(filepath.Join(dataDir, "agglomerator.db"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create config manager: %w", err)
+	}
+	return configManager, nil
+}
+
+// readConfigObject reads a YAML or JSON file holding a single object
+func readConfigObject(file string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	var object map[string]interface{}
+	if err := yaml.Unmarshal(data, &object); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	if object == nil {
+		return nil, fmt.Errorf("%s: expected a config object", file)
+	}
+	return object, nil
+}
+
+func savePreset(dataDir, name, module, file, description string) error {
+	config, err := readConfigObject(file)
+	if err != nil {
+		return err
+	}
+	if errs := validateModuleConfig(module, config); len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Printf("modules.%s: %v\n", module, err)
+		}
+		return fmt.Errorf("%s: %d problem(s) found", file, len(errs))
+	}
+	data, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	configManager, err := openConfigManager(dataDir)
+	if err != nil {
+		return err
+	}
+	defer configManager.Close()
+
+	if err := configManager.SavePreset(core.ConfigPreset{Name: name, Module: module, Description: description, Config: data}); err != nil {
+		return err
+	}
+	fmt.Printf("Saved preset %s for %s\n", name, module)
+	return nil
+}
+
+func listPresets(dataDir string) error {
+	configManager, err := openConfigManager(dataDir)
+	if err != nil {
+		return err
+	}
+	defer configManager.Close()
+
+	presets, err := configManager.ListPresets()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tMODULE\tUPDATED\tDESCRIPTION")
+	for _, preset := range presets {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", preset.Name, preset.Module, preset.UpdatedAt.Format("2006-01-02 15:04"), preset.Description)
+	}
+	return w.Flush()
+}
+
+func showPreset(dataDir, name string) error {
+	configManager, err := openConfigManager(dataDir)
+	if err != nil {
+		return err
+	}
+	defer configManager.Close()
+
+	preset, err := configManager.GetPreset(name)
+	if err != nil {
+		return err
+	}
+	return printJSON(preset)
+}
+
+func deletePreset(dataDir, name string) error {
+	configManager, err := openConfigManager(dataDir)
+	if err != nil {
+		return err
+	}
+	defer configManager.Close()
+
+	if err := configManager.DeletePreset(name); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted preset %s\n", name)
+	return nil
+}
+
+// presetOverrides merges an overrides file and --set overrides, the latter
+// taking precedence, into a JSON merge patch
+func presetOverrides(file string, sets []string) (json.RawMessage, error) {
+	overrides := make(map[string]interface{})
+	if file != "" {
+		object, err := readConfigObject(file)
+		if err != nil {
+			return nil, err
+		}
+		overrides = object
+	}
+	for _, set := range sets {
+		path, value, found := strings.Cut(set, "=")
+		if !found || path == "" {
+			return nil, fmt.Errorf("invalid --set %q: expected path=value", set)
+		}
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+			parsed = value
+		}
+		keys := strings.Split(path, ".")
+		target := overrides
+		for _, key := range keys[:len(keys)-1] {
+			next, ok := target[key].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				target[key] = next
+			}
+			target = next
+		}
+		target[keys[len(keys)-1]] = parsed
+	}
+	if len(overrides) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(overrides)
+}
+
+func applyPreset(dataDir, name string, overrides json.RawMessage, dryRun bool) error {
+	configManager, err := openConfigManager(dataDir)
+	if err != nil {
+		return err
+	}
+	defer configManager.Close()
+
+	module, config, err := configManager.InstantiatePreset(name, overrides)
+	if err != nil {
+		return err
+	}
+	var section map[string]interface{}
+	if err := json.Unmarshal(config, &section); err != nil {
+		return err
+	}
+	if errs := validateModuleConfig(module, section); len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Printf("modules.%s: %v\n", module, err)
+		}
+		return fmt.Errorf("preset %s: %d problem(s) found; nothing was applied", name, len(errs))
+	}
+
+	if !dryRun {
+		revisions, err := configManager.SetConfigs(map[string]json.RawMessage{module: config})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Applied preset %s to %s as revision %d\n", name, module, revisions[module])
+	}
+	return printJSON(json.RawMessage(config))
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
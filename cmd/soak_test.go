@@ -0,0 +1,179 @@
+//go:build soak
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	soakDuration       = flag.Duration("soak.duration", time.Minute, "how long the soak test runs")
+	soakSample         = flag.Duration("soak.sample", 5*time.Second, "interval between heap and goroutine samples")
+	soakRestart        = flag.Duration("soak.restart", 10*time.Second, "interval between restarts of the peer node")
+	soakGoroutineSlack = flag.Int("soak.max-goroutine-growth", 25, "goroutines the run may end with above its baseline")
+	soakHeapSlack      = flag.Int("soak.max-heap-growth-mb", 64, "MiB of live heap the run may end with above its baseline")
+)
+
+// soakSampleStats is a reading of the process after a forced collection
+type soakSampleStats struct {
+	at         time.Duration
+	heap       uint64
+	goroutines int
+}
+
+func readSoakSample(start time.Time) soakSampleStats {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return soakSampleStats{at: time.Since(start), heap: stats.HeapAlloc, goroutines: runtime.NumGoroutine()}
+}
+
+func (s soakSampleStats) String() string {
+	return fmt.Sprintf("%8s heap %6.1f MiB, %4d goroutines", s.at.Round(time.Second), float64(s.heap)/(1<<20), s.goroutines)
+}
+
+// TestSoak runs two peered nodes for -soak.duration, routing transactions
+// between their chains, reloading the primary's policy from stored config
+// and restarting the peer, while sampling the heap and goroutine count. The
+// run fails when either ends above the baseline taken after the first
+// restart by more than its threshold, or when goroutines outlive the nodes.
+func TestSoak(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	primaryPort, peerPort := freePort(t), freePort(t)
+	policy := map[string]interface{}{"enabled": true, "reloadInterval": "100ms"}
+	primarySettings := map[string]interface{}{
+		"nodeID": "soak-primary",
+		"policy": policy,
+		"p2p":    map[string]interface{}{"address": "127.0.0.1", "port": primaryPort, "disableDiscovery": true},
+	}
+	peerSettings := map[string]interface{}{
+		"nodeID": "soak-peer",
+		"p2p": map[string]interface{}{
+			"address": "127.0.0.1", "port": peerPort, "disableDiscovery": true,
+			"bootstrapPeers": []string{fmt.Sprintf("soak-primary@127.0.0.1:%d", primaryPort)},
+		},
+	}
+
+	primary := startTestNode(t, t.TempDir(), primarySettings)
+	primary.registerChains(map[string]string{"primary-eth": "eth", "primary-sol": "sol"})
+	peerDir := t.TempDir()
+	peer := startTestNode(t, peerDir, peerSettings)
+	peer.registerChains(map[string]string{"peer-dot": "dot"})
+
+	start := time.Now()
+	sampleTicker := time.NewTicker(*soakSample)
+	defer sampleTicker.Stop()
+	restartTicker := time.NewTicker(*soakRestart)
+	defer restartTicker.Stop()
+	deadline := time.After(*soakDuration)
+
+	var (
+		samples    []soakSampleStats
+		steady     *soakSampleStats
+		sent       int
+		failed     int
+		reloads    int
+		restarts   int
+		denyRoutes bool
+	)
+	for running := true; running; {
+		select {
+		case <-deadline:
+			running = false
+			continue
+		case <-sampleTicker.C:
+			sample := readSoakSample(start)
+			samples = append(samples, sample)
+			t.Log(sample)
+			continue
+		case <-restartTicker.C:
+			peer.stop()
+			peer = startTestNode(t, peerDir, peerSettings)
+			restarts++
+			if steady == nil {
+				// Caches, pools and connections have filled by now
+				sample := readSoakSample(start)
+				steady = &sample
+				t.Logf("baseline %s", sample)
+			}
+			continue
+		default:
+		}
+
+		// Routing, to the peer's chain every other transaction
+		to := "primary-eth"
+		if sent%2 == 1 {
+			to = "peer-dot"
+		}
+		tx := map[string]interface{}{
+			"id": fmt.Sprintf("soak-%d", sent), "fromChain": "primary-sol", "toChain": to,
+			"data": []byte("soak"), "similarity": 0.5,
+		}
+		if primary.do(http.MethodPost, "/api/agglomerator/transaction", tx, nil) != http.StatusAccepted {
+			failed++
+		}
+		sent++
+
+		// Hot reload: the primary picks up each stored revision's rules
+		if sent%100 == 0 {
+			denyRoutes = !denyRoutes
+			rules := []map[string]interface{}{}
+			if denyRoutes {
+				rules = append(rules, map[string]interface{}{"name": "soak", "action": "deny", "chainPairs": []string{"*->soak-unused"}})
+			}
+			policy["rules"] = rules
+			require.NoError(t, primary.updateConfig(primarySettings))
+			reloads++
+		}
+	}
+
+	t.Logf("%d transactions (%d not accepted), %d policy reloads, %d peer restarts", sent, failed, reloads, restarts)
+	require.NotNil(t, steady, "-soak.duration must cover at least one -soak.restart")
+	final := readSoakSample(start)
+	t.Logf("final %s", final)
+	assert.LessOrEqual(t, final.goroutines, steady.goroutines+*soakGoroutineSlack, "goroutines grew from %d", steady.goroutines)
+	assert.LessOrEqual(t, final.heap, steady.heap+uint64(*soakHeapSlack)<<20, "heap grew from %d bytes", steady.heap)
+
+	// Every goroutine the nodes started ends with them
+	// (polled here, as assert.Eventually would count its own goroutines)
+	peer.stop()
+	primary.stop()
+	left := runtime.NumGoroutine()
+	for wait := time.Now().Add(10 * time.Second); left > baseline+*soakGoroutineSlack && time.Now().Before(wait); left = runtime.NumGoroutine() {
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, left, baseline+*soakGoroutineSlack, "goroutines left after stopping the nodes")
+	if t.Failed() {
+		buf := make([]byte, 1<<20)
+		t.Logf("goroutines:\n%s", buf[:runtime.Stack(buf, true)])
+	}
+}
+
+// updateConfig stores the agglomerator's config with settings applied over
+// the running one, as an operator editing it through the API would
+func (n *testNode) updateConfig(settings map[string]interface{}) error {
+	stored, err := n.configManager.GetConfig("blockchain_agglomerator")
+	if err != nil {
+		return err
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal(stored, &config); err != nil {
+		return err
+	}
+	for key, value := range settings {
+		config[key] = value
+	}
+	if status := n.do(http.MethodPut, "/api/modules/blockchain_agglomerator/config", config, nil); status != http.StatusOK {
+		return fmt.Errorf("config update: status %d", status)
+	}
+	return nil
+}
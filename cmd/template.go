@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+	"gopkg.in/yaml.v3"
+)
+
+// configTemplatePattern matches ${env:VAR}, ${env:VAR:-default} and
+// ${secret:path}; a leading $$ escapes the reference
+var configTemplatePattern = regexp.MustCompile(`(\$?)\$\{([a-z]+):([^}]*)\}`)
+
+// defaultSecretsDir is where secrets are read from unless --secrets-dir or
+// HYDAP_SECRETS_DIR says otherwise
+const defaultSecretsDir = "/run/secrets"
+
+// secretsProvider returns the provider for ${secret:...} references
+func secretsProvider(cmd *cobra.Command) core.SecretProvider {
+	dir, _ := cmd.Flags().GetString("secrets-dir")
+	if dir == "" {
+		dir = os.Getenv("HYDAP_SECRETS_DIR")
+	}
+	if dir == "" {
+		dir = defaultSecretsDir
+	}
+	return core.NewFileSecretProvider(dir)
+}
+
+// unmarshalConfig decodes YAML after expanding template references in its
+// string values. A plain scalar that is a single reference, such as
+// port: ${env:P2P_PORT}, takes the type of the value it expands to; quoted
+// scalars always stay strings. Expanded values are never parsed as YAML
+// structure, so secrets cannot inject keys.
+func unmarshalConfig(data []byte, out interface{}, secrets core.SecretProvider) error {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return err
+	}
+
+	var errs []error
+	expandConfigNode(&root, secrets, &errs)
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	if root.Kind == 0 {
+		return nil
+	}
+	return root.Decode(out)
+}
+
+func expandConfigNode(node *yaml.Node, secrets core.SecretProvider, errs *[]error) {
+	if node.Kind != yaml.ScalarNode {
+		for _, child := range node.Content {
+			expandConfigNode(child, secrets, errs)
+		}
+		return
+	}
+
+	matches := configTemplatePattern.FindAllStringSubmatchIndex(node.Value, -1)
+	if len(matches) == 0 {
+		return
+	}
+	whole := len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(node.Value) && matches[0][3] == matches[0][2]
+
+	value := configTemplatePattern.ReplaceAllStringFunc(node.Value, func(ref string) string {
+		groups := configTemplatePattern.FindStringSubmatch(ref)
+		if groups[1] == "$" {
+			return ref[1:]
+		}
+		expanded, err := resolveConfigTemplate(groups[2], groups[3], secrets)
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("line %d: %s: %w", node.Line, ref, err))
+		}
+		return expanded
+	})
+
+	node.Value = value
+	if whole && node.Style == 0 {
+		// Let the expanded value resolve to int, bool, etc. like any plain
+		// scalar would
+		node.Tag = ""
+	}
+}
+
+func resolveConfigTemplate(source, ref string, secrets core.SecretProvider) (string, error) {
+	switch source {
+	case "env":
+		name, fallback, hasFallback := strings.Cut(ref, ":-")
+		if value, exists := os.LookupEnv(name); exists {
+			return value, nil
+		}
+		if hasFallback {
+			return fallback, nil
+		}
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	case "secret":
+		return secrets.Secret(ref)
+	default:
+		return "", fmt.Errorf("unknown template source %q", source)
+	}
+}
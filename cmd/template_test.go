@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+// mapSecrets serves secrets from a map
+type mapSecrets map[string]string
+
+func (s mapSecrets) Secret(path string) (string, error) {
+	if value, exists := s[path]; exists {
+		return value, nil
+	}
+	return "", core.ErrSecretNotFound
+}
+
+func TestUnmarshalConfigExpandsReferences(t *testing.T) {
+	t.Setenv("TEST_P2P_PORT", "9000")
+	t.Setenv("TEST_NODE", "node-7")
+	secrets := mapSecrets{"api/token": "s3cret", "inject": "x\nadmin: true"}
+
+	var config map[string]interface{}
+	require.NoError(t, unmarshalConfig([]byte(`
+port: ${env:TEST_P2P_PORT}
+quoted: "${env:TEST_P2P_PORT}"
+url: http://${env:TEST_NODE}:${env:TEST_P2P_PORT}/
+level: ${env:TEST_UNSET:-info}
+token: ${secret:api/token}
+escaped: $${env:TEST_NODE}
+injected: ${secret:inject}
+`), &config, secrets))
+
+	assert.Equal(t, map[string]interface{}{
+		"port":     9000,
+		"quoted":   "9000",
+		"url":      "http://node-7:9000/",
+		"level":    "info",
+		"token":    "s3cret",
+		"escaped":  "${env:TEST_NODE}",
+		"injected": "x\nadmin: true",
+	}, config)
+}
+
+func TestUnmarshalConfigReportsUnresolved(t *testing.T) {
+	var config map[string]interface{}
+	err := unmarshalConfig([]byte(`
+a: ${env:TEST_UNSET}
+b: ${secret:missing}
+c: ${vault:path}
+`), &config, mapSecrets{})
+	assert.ErrorContains(t, err, "line 2: ${env:TEST_UNSET}: environment variable TEST_UNSET is not set")
+	assert.ErrorContains(t, err, "line 3: ${secret:missing}: secret not found")
+	assert.ErrorContains(t, err, `line 4: ${vault:path}: unknown template source "vault"`)
+}
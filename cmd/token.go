@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Manage API tokens",
+	Long: `Issue, list and revoke the API tokens required by a node started with --auth.
+Tokens scoped with --module may only use that module's routes; tokens without
+a scope may use every route.`,
+}
+
+var tokenIssueCmd = &cobra.Command{
+	Use:          "issue [name]",
+	Short:        "Issue a token and print its secret",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dataDir, _ := cmd.Flags().GetString("data-dir")
+		modules, _ := cmd.Flags().GetStringSlice("module")
+		return issueToken(dataDir, args[0], modules)
+	},
+}
+
+var tokenListCmd = &cobra.Command{
+	Use:          "list",
+	Short:        "List issued tokens",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dataDir, _ := cmd.Flags().GetString("data-dir")
+		return listTokens(dataDir)
+	},
+}
+
+var tokenRevokeCmd = &cobra.Command{
+	Use:          "revoke [id]",
+	Short:        "Revoke a token",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dataDir, _ := cmd.Flags().GetString("data-dir")
+		return revokeToken(dataDir, args[0])
+	},
+}
+
+func init() {
+	tokenCmd.PersistentFlags().String("data-dir", "./data", "directory for the module config database")
+	tokenIssueCmd.Flags().StringSlice("module", nil, "module the token may manage; repeat for several (default every module)")
+	tokenCmd.AddCommand(tokenIssueCmd)
+	tokenCmd.AddCommand(tokenListCmd)
+	tokenCmd.AddCommand(tokenRevokeCmd)
+}
+
+// openTokenStore opens the token database kept next to the config database
+func openTokenStore(dataDir string) (*core.TokenStore, error) {
+	tokens, err := core.NewTokenStore(filepath.Join(dataDir, "tokens.db"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open token store: %w", err)
+	}
+	return tokens, nil
+}
+
+func issueToken(dataDir, name string, modules []string) error {
+	tokens, err := openTokenStore(dataDir)
+	if err != nil {
+		return err
+	}
+	defer tokens.Close()
+
+	token, secret, err := tokens.Issue(name, modules)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Issued token %s (%s); the secret is not shown again:\n", token.ID, tokenScope(token))
+	fmt.Println(secret)
+	return nil
+}
+
+func listTokens(dataDir string) error {
+	tokens, err := openTokenStore(dataDir)
+	if err != nil {
+		return err
+	}
+	defer tokens.Close()
+
+	issued, err := tokens.List()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSCOPE\tCREATED")
+	for _, token := range issued {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", token.ID, token.Name, tokenScope(token), token.CreatedAt.Format("2006-01-02 15:04"))
+	}
+	return w.Flush()
+}
+
+func revokeToken(dataDir, id string) error {
+	tokens, err := openTokenStore(dataDir)
+	if err != nil {
+		return err
+	}
+	defer tokens.Close()
+
+	if err := tokens.Revoke(id); err != nil {
+		return err
+	}
+	fmt.Printf("Revoked token %s\n", id)
+	return nil
+}
+
+func tokenScope(token core.APIToken) string {
+	if len(token.Modules) == 0 {
+		return "all modules"
+	}
+	return strings.Join(token.Modules, ",")
+}
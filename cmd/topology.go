@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/agglomerator"
+)
+
+var topologyCmd = &cobra.Command{
+	Use:          "topology",
+	Short:        "Show the chain routing graph",
+	Long:         `Fetch the routing graph from a running node and print it as JSON, or as Graphviz DOT with --dot.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server, _ := cmd.Flags().GetString("server")
+		dot, _ := cmd.Flags().GetBool("dot")
+		return showTopology(server, dot)
+	},
+}
+
+func init() {
+	topologyCmd.Flags().String("server", "http://localhost:8088", "base URL of the node's HTTP API")
+	topologyCmd.Flags().Bool("dot", false, "print Graphviz DOT instead of JSON")
+}
+
+func showTopology(server string, dot bool) error {
+	url := strings.TrimSuffix(server, "/") + "/api/agglomerator/topology"
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch topology: %s", resp.Status)
+	}
+
+	var topology agglomerator.Topology
+	if err := json.NewDecoder(resp.Body).Decode(&topology); err != nil {
+		return fmt.Errorf("failed to decode topology: %w", err)
+	}
+
+	if dot {
+		fmt.Print(topology.DOT())
+		return nil
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(topology)
+}
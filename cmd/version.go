@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/theaxiomverse/hydap-api/pkg/keymanagement"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/agglomerator"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+var versionCmd = &cobra.Command{
+	Use:          "version",
+	Short:        "Show build version and features",
+	Long:         `Print the version, commit, build date, features and API versions of this binary, or of a running node with --server.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server, _ := cmd.Flags().GetString("server")
+		return showVersion(server)
+	},
+}
+
+func init() {
+	versionCmd.Flags().String("server", "", "base URL of a running node's HTTP API")
+
+	core.RegisterFeature("liboqs", keymanagement.LiboqsVersion())
+	core.RegisterFeature("transports", agglomerator.AvailableTransports())
+	// Modules are compiled in; loading them from files is not supported
+	core.RegisterFeature("plugins", false)
+}
+
+func showVersion(server string) error {
+	info := core.GetBuildInfo()
+	if server != "" {
+		url := strings.TrimSuffix(server, "/") + "/api/version"
+		resp, err := http.Get(url)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("failed to fetch version: %s", resp.Status)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+			return fmt.Errorf("failed to decode version: %w", err)
+		}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(info)
+}
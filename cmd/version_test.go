@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+func TestVersionFeatures(t *testing.T) {
+	features := core.GetBuildInfo().Features
+	assert.NotEmpty(t, features["liboqs"])
+	assert.Contains(t, features["transports"], "tcp")
+	assert.Equal(t, false, features["plugins"])
+}
+
+func TestShowVersionFromServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/version":
+			w.Write([]byte(`{"version":"1.2.0","apiVersions":["v1"]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	assert.NoError(t, showVersion(server.URL+"/"))
+	assert.EqualError(t, showVersion(server.URL+"/missing"), "failed to fetch version: 404 Not Found")
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+// watchStream reads the events of a ?watch=true request
+type watchStream struct {
+	t      *testing.T
+	events chan core.WatchEvent
+}
+
+func (n *testNode) watch(path string) *watchStream {
+	n.t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	n.t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, n.server.URL+path+"?watch=true", nil)
+	require.NoError(n.t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(n.t, err)
+	require.Equal(n.t, http.StatusOK, resp.StatusCode)
+	require.Equal(n.t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+
+	stream := &watchStream{t: n.t, events: make(chan core.WatchEvent, 64)}
+	go func() {
+		defer resp.Body.Close()
+		defer close(stream.events)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			var event core.WatchEvent
+			if json.Unmarshal(scanner.Bytes(), &event) == nil {
+				stream.events <- event
+			}
+		}
+	}()
+	return stream
+}
+
+// next returns the next event's type and object
+func (s *watchStream) next() (string, map[string]interface{}) {
+	s.t.Helper()
+	select {
+	case event, ok := <-s.events:
+		require.True(s.t, ok, "watch ended")
+		var object map[string]interface{}
+		require.NoError(s.t, json.Unmarshal(event.Object, &object))
+		return event.Type, object
+	case <-time.After(5 * time.Second):
+		s.t.Fatal("no watch event")
+		return "", nil
+	}
+}
+
+func TestWatchChains(t *testing.T) {
+	node := startTestNode(t, t.TempDir(), nil)
+	node.registerChains(map[string]string{"eth-main": "eth"})
+
+	watch := node.watch("/api/agglomerator/chains")
+	kind, chain := watch.next()
+	assert.Equal(t, core.WatchAdded, kind, "existing chains are sent first")
+	assert.Equal(t, "eth-main", chain["id"])
+
+	node.registerChains(map[string]string{"sol-main": "sol"})
+	kind, chain = watch.next()
+	assert.Equal(t, core.WatchAdded, kind)
+	assert.Equal(t, "sol-main", chain["id"])
+
+	require.Equal(t, http.StatusOK, node.do(http.MethodPut, "/api/agglomerator/chains/eth-main/maintenance", map[string]interface{}{"mode": "draining"}, nil))
+	kind, chain = watch.next()
+	assert.Equal(t, core.WatchModified, kind)
+	assert.Equal(t, "eth-main", chain["id"])
+	assert.Contains(t, chain, "maintenance")
+}
+
+func TestWatchModules(t *testing.T) {
+	node := startTestNode(t, t.TempDir(), nil)
+
+	watch := node.watch("/api/modules")
+	var names []interface{}
+	for range node.registry.List() {
+		kind, module := watch.next()
+		require.Equal(t, core.WatchAdded, kind, "existing modules are sent first")
+		names = append(names, module["name"])
+	}
+	assert.Equal(t, []interface{}{"blockchain_agglomerator", "compression"}, names)
+
+	require.Equal(t, http.StatusNoContent, node.do(http.MethodDelete, "/api/modules/compression", nil, nil))
+	kind, module := watch.next()
+	assert.Equal(t, core.WatchDeleted, kind)
+	assert.Equal(t, "compression", module["name"], "deletions carry the last state")
+
+	// Without ?watch the list is served as before
+	var modules []core.ModuleInfo
+	require.Equal(t, http.StatusOK, node.do(http.MethodGet, "/api/modules", nil, &modules))
+	assert.Len(t, modules, 1)
+}
@@ -0,0 +1,383 @@
+// Package client is a Go client of the hydap API. Requests are retried with
+// jittered backoff, carrying an idempotency key so retried writes run once,
+// and spread over the configured gateways with a circuit breaker per
+// gateway. A client sticks to the gateway that last answered it.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+var (
+	ErrNoEndpoints = errors.New("no API endpoints")
+	ErrCircuitOpen = errors.New("circuit open on every endpoint")
+)
+
+// APIError is a response with an error status
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("API error %d", e.StatusCode)
+	}
+	return fmt.Sprintf("API error %d: %s", e.StatusCode, e.Message)
+}
+
+// RetryPolicy bounds the attempts of a request. The wait before each retry
+// is drawn uniformly up to BaseDelay doubled per attempt, capped at
+// MaxDelay, and is at least a response's Retry-After.
+type RetryPolicy struct {
+	MaxAttempts int // Including the first; 1 disables retries
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// BreakerPolicy opens an endpoint's circuit after Failures failed attempts
+// in a row. Requests skip an open endpoint for Cooldown, after which one
+// trial request decides whether it closes again.
+type BreakerPolicy struct {
+	Failures int
+	Cooldown time.Duration
+}
+
+var (
+	DefaultRetryPolicy   = RetryPolicy{MaxAttempts: 4, BaseDelay: 100 * time.Millisecond, MaxDelay: 5 * time.Second}
+	DefaultBreakerPolicy = BreakerPolicy{Failures: 5, Cooldown: 30 * time.Second}
+)
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sends requests with httpClient instead of
+// http.DefaultClient
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.http = httpClient }
+}
+
+// WithToken authenticates requests with an API token
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) { c.retry = policy }
+}
+
+// WithBreakerPolicy replaces DefaultBreakerPolicy
+func WithBreakerPolicy(policy BreakerPolicy) Option {
+	return func(c *Client) { c.breaker = policy }
+}
+
+// Client sends requests to the API of one cluster through its gateways. It
+// is safe for concurrent use.
+type Client struct {
+	http    *http.Client
+	token   string
+	retry   RetryPolicy
+	breaker BreakerPolicy
+	now     func() time.Time
+
+	mu        sync.Mutex
+	endpoints []*endpoint
+	preferred int // The endpoint that last answered
+}
+
+// endpoint is a gateway's base URL and its circuit
+type endpoint struct {
+	base      string
+	failures  int
+	openUntil time.Time
+	probing   bool // A trial request is out on the open circuit
+}
+
+// New creates a client of the gateways at endpoints, base URLs such as
+// http://localhost:8088
+func New(endpoints []string, opts ...Option) (*Client, error) {
+	if len(endpoints) == 0 {
+		return nil, ErrNoEndpoints
+	}
+	c := &Client{
+		http:    http.DefaultClient,
+		retry:   DefaultRetryPolicy,
+		breaker: DefaultBreakerPolicy,
+		now:     time.Now,
+	}
+	for _, address := range endpoints {
+		parsed, err := url.Parse(address)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid endpoint %q, want http(s)://host[:port]", address)
+		}
+		c.endpoints = append(c.endpoints, &endpoint{base: strings.TrimSuffix(address, "/")})
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.retry.MaxAttempts = max(c.retry.MaxAttempts, 1)
+	return c, nil
+}
+
+type idempotencyKeyContextKey struct{}
+
+// WithIdempotencyKey makes requests sent with ctx use key instead of a
+// generated one, so a write retried across calls, or processes, runs once
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey{}, key)
+}
+
+// Do sends a request for path, such as /api/agglomerator/chains, with body
+// JSON encoded unless nil, and decodes a successful response into out
+// unless it is nil. Error statuses are returned as *APIError. Writes carry
+// an idempotency key that every attempt repeats.
+func (c *Client) Do(ctx context.Context, method, path string, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	key, _ := ctx.Value(idempotencyKeyContextKey{}).(string)
+	if key == "" && method != http.MethodGet && method != http.MethodHead {
+		key = core.NewID()
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < c.retry.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			if err := c.wait(ctx, attempt, lastErr); err != nil {
+				return err
+			}
+		}
+		target, err := c.pick()
+		if err != nil {
+			if lastErr != nil {
+				return fmt.Errorf("%w: %w", err, lastErr)
+			}
+			return err
+		}
+
+		retry, err := c.send(ctx, target, method, path, payload, key, attempt, out)
+		if err == nil || !retry {
+			return err
+		}
+		lastErr = err
+	}
+	return lastErr
+}
+
+// send makes one attempt against target, reporting whether a failure may
+// be retried
+func (c *Client) send(ctx context.Context, target *endpoint, method, path string, payload []byte, key string, attempt int, out interface{}) (bool, error) {
+	defer c.release(target)
+
+	req, err := http.NewRequestWithContext(ctx, method, target.base+path, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if key != "" {
+		req.Header.Set(core.IdempotencyKeyHeader, key)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		c.report(target, false)
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusServiceUnavailable && resp.Header.Get("Retry-After") != "":
+		// A gateway shedding load says when to come back, so like a 429 it
+		// is up and its circuit stays closed
+		c.report(target, true)
+		return true, &retryAfterError{APIError: readAPIError(resp), after: retryAfter(resp)}
+	case resp.StatusCode == http.StatusBadGateway || resp.StatusCode == http.StatusServiceUnavailable || resp.StatusCode == http.StatusGatewayTimeout:
+		c.report(target, false)
+		return true, &retryAfterError{APIError: readAPIError(resp), after: retryAfter(resp)}
+	case resp.StatusCode == http.StatusTooManyRequests:
+		// An overloaded gateway is still up, so its circuit stays closed
+		c.report(target, true)
+		return true, &retryAfterError{APIError: readAPIError(resp), after: retryAfter(resp)}
+	case resp.StatusCode == http.StatusConflict && attempt > 0 && key != "":
+		// An earlier attempt may still be running under the same key
+		c.report(target, true)
+		return true, readAPIError(resp)
+	case resp.StatusCode >= 400:
+		c.report(target, true)
+		return false, readAPIError(resp)
+	}
+
+	c.report(target, true)
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	return false, json.NewDecoder(resp.Body).Decode(out)
+}
+
+// pick returns the endpoint for the next attempt: the preferred one, or the
+// next whose circuit lets a request through
+func (c *Client) pick() (*endpoint, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for i := range c.endpoints {
+		index := (c.preferred + i) % len(c.endpoints)
+		target := c.endpoints[index]
+		if target.failures < c.breaker.Failures || c.breaker.Failures <= 0 {
+			c.preferred = index
+			return target, nil
+		}
+		if !now.Before(target.openUntil) && !target.probing {
+			target.probing = true
+			return target, nil
+		}
+	}
+	return nil, ErrCircuitOpen
+}
+
+// report records the outcome of an attempt against target, moving
+// preference off a failing endpoint
+func (c *Client) report(target *endpoint, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if ok {
+		target.failures = 0
+		return
+	}
+	target.failures++
+	if target.failures >= c.breaker.Failures {
+		target.openUntil = c.now().Add(c.breaker.Cooldown)
+	}
+	if c.endpoints[c.preferred] == target {
+		c.preferred = (c.preferred + 1) % len(c.endpoints)
+	}
+}
+
+// release ends an attempt against target, letting another attempt probe it
+// once its cooldown has passed. Attempts that end without an outcome, such
+// as canceled ones, are not counted as failures.
+func (c *Client) release(target *endpoint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	target.probing = false
+}
+
+// wait sleeps before retry attempt, returning early with ctx's error
+func (c *Client) wait(ctx context.Context, attempt int, lastErr error) error {
+	ceiling := c.retry.BaseDelay << min(attempt-1, 30)
+	if ceiling <= 0 || ceiling > c.retry.MaxDelay {
+		ceiling = c.retry.MaxDelay
+	}
+	var delay time.Duration
+	if ceiling > 0 {
+		delay = rand.N(ceiling)
+	}
+	var hinted *retryAfterError
+	if errors.As(lastErr, &hinted) {
+		delay = max(delay, hinted.after)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// retryAfterError is a retryable error status with the response's
+// Retry-After
+type retryAfterError struct {
+	*APIError
+	after time.Duration
+}
+
+func (e *retryAfterError) Unwrap() error { return e.APIError }
+
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func readAPIError(resp *http.Response) *APIError {
+	var body struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+	return &APIError{StatusCode: resp.StatusCode, Message: body.Error}
+}
+
+// Chain is a chain as the API registers and lists it
+type Chain struct {
+	ID        string   `json:"id"`
+	Endpoint  string   `json:"endpoint"`
+	Endpoints []string `json:"endpoints,omitempty"` // Failover endpoints tried after Endpoint
+	Protocol  string   `json:"protocol"`
+	Zone      string   `json:"zone,omitempty"`
+}
+
+// Transaction is a cross-chain transaction submitted for routing
+type Transaction struct {
+	ID         string            `json:"id"`
+	FromChain  string            `json:"fromChain"`
+	ToChain    string            `json:"toChain"`
+	Data       []byte            `json:"data,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	Similarity float64           `json:"similarity"`
+	Fee        float64           `json:"fee,omitempty"`
+	Priority   int               `json:"priority,omitempty"`
+}
+
+// ListChains returns the registered chains
+func (c *Client) ListChains(ctx context.Context) ([]Chain, error) {
+	var chains []Chain
+	if err := c.Do(ctx, http.MethodGet, "/api/agglomerator/chains", nil, &chains); err != nil {
+		return nil, err
+	}
+	return chains, nil
+}
+
+// RegisterChain registers a chain
+func (c *Client) RegisterChain(ctx context.Context, chain Chain) error {
+	return c.Do(ctx, http.MethodPost, "/api/agglomerator/chains", chain, nil)
+}
+
+// SubmitTransaction submits a cross-chain transaction for routing
+func (c *Client) SubmitTransaction(ctx context.Context, tx Transaction) error {
+	return c.Do(ctx, http.MethodPost, "/api/agglomerator/transaction", tx, nil)
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestClient returns a client of endpoints whose circuits open after one
+// failure for a minute, and a function moving its clock forward
+func newTestClient(t *testing.T, endpoints ...string) (*Client, func(time.Duration)) {
+	c, err := New(endpoints,
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 1}),
+		WithBreakerPolicy(BreakerPolicy{Failures: 1, Cooldown: time.Minute}),
+	)
+	require.NoError(t, err)
+	now := time.Unix(0, 0)
+	c.now = func() time.Time { return now }
+	return c, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestBreakerProbesAfterCooldown(t *testing.T) {
+	var healthy atomic.Bool
+	var served atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	c, advance := newTestClient(t, server.URL)
+	ctx := context.Background()
+	var apiErr *APIError
+	require.ErrorAs(t, c.Do(ctx, http.MethodGet, "/", nil, nil), &apiErr)
+	assert.ErrorIs(t, c.Do(ctx, http.MethodGet, "/", nil, nil), ErrCircuitOpen, "the failure opens the circuit")
+	assert.Equal(t, int32(1), served.Load())
+
+	// A failed trial keeps the circuit open for another cooldown
+	advance(time.Minute)
+	require.ErrorAs(t, c.Do(ctx, http.MethodGet, "/", nil, nil), &apiErr)
+	assert.ErrorIs(t, c.Do(ctx, http.MethodGet, "/", nil, nil), ErrCircuitOpen)
+	assert.Equal(t, int32(2), served.Load())
+
+	// A successful trial closes it
+	advance(time.Minute)
+	healthy.Store(true)
+	require.NoError(t, c.Do(ctx, http.MethodGet, "/", nil, nil))
+	require.NoError(t, c.Do(ctx, http.MethodGet, "/", nil, nil))
+	assert.Equal(t, int32(4), served.Load())
+}
+
+func TestBreakerSendsOneProbe(t *testing.T) {
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-unblock
+	}))
+	defer server.Close()
+
+	c, advance := newTestClient(t, server.URL)
+	c.report(c.endpoints[0], false)
+	advance(time.Minute)
+
+	probed := make(chan error, 1)
+	go func() { probed <- c.Do(context.Background(), http.MethodGet, "/", nil, nil) }()
+	<-entered
+	assert.ErrorIs(t, c.Do(context.Background(), http.MethodGet, "/", nil, nil), ErrCircuitOpen, "only one trial goes out")
+	close(unblock)
+	require.NoError(t, <-probed)
+}
+
+func TestBreakerReleasesCanceledProbes(t *testing.T) {
+	entered := make(chan struct{})
+	var held atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if held.CompareAndSwap(false, true) {
+			close(entered)
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+
+	c, advance := newTestClient(t, server.URL)
+	c.report(c.endpoints[0], false)
+	advance(time.Minute)
+
+	// The trial is canceled in flight, then one is canceled before it is
+	// sent and one cannot be built
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-entered
+		cancel()
+	}()
+	assert.ErrorIs(t, c.Do(ctx, http.MethodGet, "/", nil, nil), context.Canceled)
+	assert.ErrorIs(t, c.Do(ctx, http.MethodGet, "/", nil, nil), context.Canceled)
+	assert.ErrorContains(t, c.Do(context.Background(), "BAD METHOD", "/", nil, nil), "invalid method")
+
+	assert.NoError(t, c.Do(context.Background(), http.MethodGet, "/", nil, nil), "canceled trials leave the endpoint open to another")
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/theaxiomverse/hydap-api/pkg/keymanagement"
 	"github.com/theaxiomverse/hydap-api/pkg/keymanagement/pb"
 	"google.golang.org/protobuf/proto"
+	"math"
 	"math/big"
 
 	"github.com/open-quantum-safe/liboqs-go/oqs"
@@ -20,6 +21,10 @@ const (
 	PrimeModulus            = (1 << 127) - 1
 	ScaleFactor             = 1e8 // Adjusted for precision
 	ErrFailedSignatureCheck = "signature verification failed for share"
+
+	// Bounds on shares received from other nodes
+	MaxShareIndex     = 1 << 16
+	MaxShareFieldSize = 64 << 10
 )
 
 type VSS struct {
@@ -57,7 +62,7 @@ func NewVSS(threshold int, algorithm pb.Algorithm) (*VSS, error) {
 		return nil, fmt.Errorf("failed to load secret key: %w", err)
 	}
 
-	// Initialize Falcon Signature
+	// Initialize the share signature (Falcon or Dilithium)
 	vss.sigManagement.Init(algorithm)
 
 	return vss, nil
@@ -120,13 +125,13 @@ func (vss *VSS) encryptAndSignShareWithKeyManagement(shamirShare *share.PriShare
 		return [4]interface{}{}, fmt.Errorf("failed to encapsulate secret: %w", err)
 	}
 
-	// Sign the ciphertext
-	signedPubKey := vss.keyManagement.SignedPublicKey()
-	if signedPubKey == "" {
-		return [4]interface{}{}, fmt.Errorf("failed to sign ciphertext using key management")
+	// Sign the ciphertext with the signature algorithm (Falcon or Dilithium)
+	signature, err := vss.sigManagement.Sign(ciphertext)
+	if err != nil {
+		return [4]interface{}{}, fmt.Errorf("failed to sign ciphertext: %w", err)
 	}
 
-	return [4]interface{}{shamirShare.I, ciphertext, sharedSecret, signedPubKey}, nil
+	return [4]interface{}{shamirShare.I, ciphertext, sharedSecret, signature}, nil
 }
 
 func (vss *VSS) ReconstructSecret(allEncryptedShares [][][4]interface{}, publicKeyBytes string) ([]float64, error) {
@@ -143,19 +148,19 @@ func (vss *VSS) ReconstructSecret(allEncryptedShares [][][4]interface{}, publicK
 	reconstructedCoords := []float64{}
 	for _, coordShares := range allEncryptedShares {
 		sharesForReconstruction := [][2]int64{}
-		for _, share := range coordShares {
-			index := share[0].(int64)
-			ciphertext := share[1].([]byte)
-			sharedSecret := share[2].([]byte)
-			signature := share[3].([]byte)
+		for _, raw := range coordShares {
+			share, err := parseShare(raw)
+			if err != nil {
+				return nil, err
+			}
 
 			// Verify the signature
-			if !vss.verifySignature(ciphertext, signature, publicKey) {
+			if !vss.verifySignature(share.ciphertext, share.signature, publicKey) {
 				return nil, errors.New(ErrFailedSignatureCheck)
 			}
 
-			shareInt := new(big.Int).SetBytes(sharedSecret).Int64()
-			sharesForReconstruction = append(sharesForReconstruction, [2]int64{index, shareInt})
+			shareInt := new(big.Int).SetBytes(share.sharedSecret).Int64()
+			sharesForReconstruction = append(sharesForReconstruction, [2]int64{share.index, shareInt})
 		}
 
 		// Perform Lagrange interpolation and scale back
@@ -169,6 +174,58 @@ func (vss *VSS) ReconstructSecret(allEncryptedShares [][][4]interface{}, publicK
 	return reconstructedCoords, nil
 }
 
+// parsedShare is an encrypted share whose fields have been type checked
+type parsedShare struct {
+	index        int64
+	ciphertext   []byte
+	sharedSecret []byte
+	signature    []byte
+}
+
+// parseShare checks a share of [index, ciphertext, shared secret, signature]
+// received from another node. Shares decoded from JSON carry a float64
+// index and base64 strings, and are accepted as well as native ones.
+func parseShare(raw [4]interface{}) (parsedShare, error) {
+	var share parsedShare
+	switch index := raw[0].(type) {
+	case int:
+		share.index = int64(index)
+	case int64:
+		share.index = index
+	case float64:
+		if index != math.Trunc(index) || index < 0 || index > MaxShareIndex {
+			return share, fmt.Errorf("invalid share index %v", index)
+		}
+		share.index = int64(index)
+	default:
+		return share, fmt.Errorf("invalid share index type %T", raw[0])
+	}
+	if share.index < 0 || share.index > MaxShareIndex {
+		return share, fmt.Errorf("share index %d out of range", share.index)
+	}
+
+	fields := []*[]byte{&share.ciphertext, &share.sharedSecret, &share.signature}
+	names := []string{"ciphertext", "shared secret", "signature"}
+	for i, field := range fields {
+		switch value := raw[i+1].(type) {
+		case []byte:
+			*field = value
+		case string:
+			decoded, err := base64Decode(value)
+			if err != nil {
+				return share, fmt.Errorf("invalid share %s: %w", names[i], err)
+			}
+			*field = decoded
+		default:
+			return share, fmt.Errorf("invalid share %s type %T", names[i], raw[i+1])
+		}
+		if len(*field) == 0 || len(*field) > MaxShareFieldSize {
+			return share, fmt.Errorf("share %s has %d bytes", names[i], len(*field))
+		}
+	}
+	return share, nil
+}
+
 func getKyberAlgorithmName(algorithm pb.Algorithm) string {
 	switch algorithm {
 	case pb.Algorithm_KYBER512:
@@ -182,27 +239,23 @@ func getKyberAlgorithmName(algorithm pb.Algorithm) string {
 	}
 }
 
-func getFalconAlgorithmName(algorithm pb.Algorithm) string {
-	switch algorithm {
-	case pb.Algorithm_FALCON512:
-		return "Falcon-512"
-	default:
-		return ""
-	}
-}
-
 func base64Decode(encoded string) ([]byte, error) {
 	// Decode a base64-encoded string into raw bytes
 	return base64.StdEncoding.DecodeString(encoded)
 }
 
 func (vss *VSS) verifySignature(ciphertext, signature, publicKey []byte) bool {
+	name, ok := keymanagement.SignatureAlgorithmName(vss.sigManagement.GetAlgorithm())
+	if !ok {
+		return false
+	}
 	sig := oqs.Signature{}
-	err := sig.Init(getFalconAlgorithmName(vss.sigManagement.GetAlgorithm()), nil)
-	if err != nil {
+	if err := sig.Init(name, nil); err != nil {
 		return false
 	}
-	return sig.Verify(ciphertext, signature, publicKey)
+	defer sig.Clean()
+	valid, err := sig.Verify(ciphertext, signature, publicKey)
+	return err == nil && valid
 }
 
 func (vss *VSS) reconstructFromShares(shares [][2]int64) (int64, error) {
@@ -0,0 +1,35 @@
+package vss
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// FuzzParseShare feeds shares as they arrive from other nodes, JSON
+// encoded, through share parsing. Inputs that once crashed it are kept
+// under testdata/fuzz/FuzzParseShare and replayed by every go test run.
+func FuzzParseShare(f *testing.F) {
+	f.Add([]byte(`[1, "Y2lwaGVydGV4dA==", "c2VjcmV0", "c2lnbmF0dXJl"]`))
+	f.Add([]byte(`[1.5, "", "", ""]`))
+	f.Add([]byte(`[1e300, "YQ==", "YQ==", "YQ=="]`))
+	f.Add([]byte(`[-1, null, {}, []]`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var raw [4]interface{}
+		if json.Unmarshal(data, &raw) != nil {
+			return
+		}
+		share, err := parseShare(raw)
+		if err != nil {
+			return
+		}
+		if share.index < 0 || share.index > MaxShareIndex {
+			t.Fatalf("accepted share index %d", share.index)
+		}
+		for _, field := range [][]byte{share.ciphertext, share.sharedSecret, share.signature} {
+			if len(field) == 0 || len(field) > MaxShareFieldSize {
+				t.Fatalf("accepted share field of %d bytes", len(field))
+			}
+		}
+	})
+}
@@ -4,15 +4,23 @@ go 1.23
 
 require (
 	github.com/open-quantum-safe/liboqs-go v0.0.0-20240412174151-8a109c3b4878
+	github.com/stretchr/testify v1.9.0
 	github.com/theaxiomverse/hydap-api/pkg/crypto v0.0.0-20241227010948-541b298b98ec
 	go.dedis.ch/kyber/v4 v4.0.0-pre2
-	google.golang.org/protobuf v1.36.1
+	google.golang.org/grpc v1.72.1
+	google.golang.org/protobuf v1.36.5
 )
 
 require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/klauspost/cpuid/v2 v2.0.12 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/zeebo/blake3 v0.2.4 // indirect
 	go.dedis.ch/fixbuf v1.0.3 // indirect
-	golang.org/x/crypto v0.0.0-20190123085648-057139ce5d2b // indirect
-	golang.org/x/sys v0.0.0-20190124100055-b90733256f2e // indirect
+	golang.org/x/crypto v0.33.0 // indirect
+	golang.org/x/net v0.35.0 // indirect
+	golang.org/x/sys v0.30.0 // indirect
+	golang.org/x/text v0.22.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250218202821-56aae31c358a // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -6,6 +6,7 @@ import (
 	"github.com/open-quantum-safe/liboqs-go/oqs"
 	"github.com/theaxiomverse/hydap-api/pkg/crypto"
 	"github.com/theaxiomverse/hydap-api/pkg/keymanagement/pb"
+	"sort"
 )
 
 type KeyManagement interface {
@@ -15,6 +16,7 @@ type KeyManagement interface {
 	Init(algorithm pb.Algorithm, secretKey string) error
 	Sign([]byte) ([]byte, error)
 	GetPrivate() []byte
+	GetAlgorithm() pb.Algorithm
 }
 
 type keygen struct {
@@ -68,13 +70,38 @@ func (k *keygen) Sign(message []byte) ([]byte, error) {
 	if k.privateKey == nil {
 		return nil, ErrPrivateKeyNotLoaded
 	}
-	signer, err := initOqsSigner("Falcon-"+getKeySecurityLevel(k.alg), k.privateKey)
+	name, ok := SignatureAlgorithmName(k.alg)
+	if !ok {
+		return nil, ErrNotSignatureAlgorithm
+	}
+	signer, err := initOqsSigner(name, k.privateKey)
 	if err != nil {
 		return nil, err
 	}
+	defer signer.Clean()
 	return signer.Sign(message)
 }
 
+// Verify checks a signature made with an algorithm's secret key against its
+// base64-encoded public key
+func Verify(algorithm pb.Algorithm, message, signature []byte, publicKey string) (bool, error) {
+	name, ok := SignatureAlgorithmName(algorithm)
+	if !ok {
+		return false, ErrNotSignatureAlgorithm
+	}
+	decodedKey, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		return false, ErrInvalidPublicKey
+	}
+
+	verifier, err := initOqsSigner(name, nil)
+	if err != nil {
+		return false, err
+	}
+	defer verifier.Clean()
+	return verifier.Verify(message, signature, decodedKey)
+}
+
 func (k *keygen) GetPrivate() []byte {
 	return k.privateKey
 }
@@ -89,32 +116,33 @@ func NewKeyManager(algorithm pb.Algorithm, secretKey string) (KeyManagement, err
 }
 
 func (k *keygen) generateKeyPair() ([]byte, []byte, error) {
-	switch k.alg {
-	case pb.Algorithm_FALCON512:
-		signer, err := initOqsSigner("Falcon-512", nil)
+	if name, ok := SignatureAlgorithmName(k.alg); ok {
+		signer, err := initOqsSigner(name, nil)
 		if err != nil {
 			return nil, nil, err
 		}
+		defer signer.Clean()
 		pk, err := signer.GenerateKeyPair()
-		sk := signer.ExportSecretKey()
-
-		return pk, sk, nil
+		if err != nil {
+			return nil, nil, err
+		}
+		return pk, signer.ExportSecretKey(), nil
+	}
 
-	case pb.Algorithm_KYBER512, pb.Algorithm_KYBER768, pb.Algorithm_KYBER1024:
-		kem, err := initOqsKEM("Kyber-"+getKeySecurityLevel(k.alg), nil)
+	if name, ok := KEMAlgorithmName(k.alg); ok {
+		kem, err := initOqsKEM(name, nil)
 		if err != nil {
 			return nil, nil, err
 		}
+		defer kem.Clean()
 		pk, err := kem.GenerateKeyPair()
-		sk := kem.ExportSecretKey()
 		if err != nil {
 			return nil, nil, err
 		}
-		return pk, sk, nil
-
-	default:
-		return nil, nil, ErrUnsupportedAlgorithm
+		return pk, kem.ExportSecretKey(), nil
 	}
+
+	return nil, nil, ErrUnsupportedAlgorithm
 }
 func initOqsSigner(keySecurityLevel string, secretKey []byte) (oqs.Signature, error) {
 	signer := oqs.Signature{}
@@ -136,17 +164,53 @@ func getKeySecurityLevel(algorithm pb.Algorithm) string {
 		return "768"
 	case pb.Algorithm_KYBER1024:
 		return "1024"
-	case pb.Algorithm_FALCON512:
-		return "512"
 	default:
 		return ""
 	}
 }
 
+// KEMAlgorithmName returns the liboqs name of a key encapsulation
+// algorithm, or false for algorithms that do not encapsulate
+func KEMAlgorithmName(algorithm pb.Algorithm) (string, bool) {
+	switch algorithm {
+	case pb.Algorithm_KYBER512, pb.Algorithm_KYBER768, pb.Algorithm_KYBER1024:
+		return "Kyber" + getKeySecurityLevel(algorithm), true
+	default:
+		return "", false
+	}
+}
+
+// SignatureAlgorithmName returns the liboqs name of a signature algorithm,
+// or false for algorithms that do not sign
+func SignatureAlgorithmName(algorithm pb.Algorithm) (string, bool) {
+	switch algorithm {
+	case pb.Algorithm_FALCON512:
+		return "Falcon-512", true
+	case pb.Algorithm_FALCON1024:
+		return "Falcon-1024", true
+	case pb.Algorithm_DILITHIUM2:
+		return "Dilithium2", true
+	case pb.Algorithm_DILITHIUM3:
+		return "Dilithium3", true
+	case pb.Algorithm_DILITHIUM5:
+		return "Dilithium5", true
+	// SPHINCS+ keeps no signing state and rests only on hash functions, for
+	// long-lived artifacts where that outweighs its larger signatures
+	case pb.Algorithm_SPHINCS_SHA2_128S:
+		return "SPHINCS+-SHA2-128s-simple", true
+	case pb.Algorithm_SPHINCS_SHA2_256S:
+		return "SPHINCS+-SHA2-256s-simple", true
+	default:
+		return "", false
+	}
+}
+
 var (
-	ErrUnsupportedAlgorithm = errors.New("unsupported algorithm")
-	ErrPrivateKeyNotLoaded  = errors.New("private key not loaded")
-	ErrInvalidSecretKey     = errors.New("invalid secret key")
+	ErrUnsupportedAlgorithm  = errors.New("unsupported algorithm")
+	ErrPrivateKeyNotLoaded   = errors.New("private key not loaded")
+	ErrInvalidSecretKey      = errors.New("invalid secret key")
+	ErrInvalidPublicKey      = errors.New("invalid public key")
+	ErrNotSignatureAlgorithm = errors.New("algorithm does not sign")
 )
 
 func (k *keygen) DeriveKey() string {
@@ -160,3 +224,22 @@ func (k *keygen) DeriveKey() string {
 func (k *keygen) GetAlgorithm() pb.Algorithm {
 	return k.alg
 }
+
+// LiboqsVersion returns the version of the linked liboqs library
+func LiboqsVersion() string {
+	return oqs.LiboqsVersion()
+}
+
+// MissingSignatureAlgorithms returns the liboqs names of the signature
+// algorithms the linked liboqs was built without
+func MissingSignatureAlgorithms() []string {
+	var missing []string
+	for value := range pb.Algorithm_name {
+		name, ok := SignatureAlgorithmName(pb.Algorithm(value))
+		if ok && !oqs.IsSigEnabled(name) {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
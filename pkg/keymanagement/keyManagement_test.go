@@ -0,0 +1,45 @@
+package keymanagement
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/keymanagement/pb"
+)
+
+func TestSphincsSignatures(t *testing.T) {
+	for algorithm, name := range map[pb.Algorithm]string{
+		pb.Algorithm_SPHINCS_SHA2_128S: "SPHINCS+-SHA2-128s-simple",
+		pb.Algorithm_SPHINCS_SHA2_256S: "SPHINCS+-SHA2-256s-simple",
+	} {
+		t.Run(algorithm.String(), func(t *testing.T) {
+			oqsName, ok := SignatureAlgorithmName(algorithm)
+			require.True(t, ok)
+			assert.Equal(t, name, oqsName)
+			_, isKEM := KEMAlgorithmName(algorithm)
+			assert.False(t, isKEM)
+
+			keys, err := NewKeyManager(algorithm, "")
+			require.NoError(t, err)
+			assert.Equal(t, algorithm, keys.GetAlgorithm())
+
+			message := []byte("sealed audit log segment")
+			signature, err := keys.Sign(message)
+			require.NoError(t, err)
+
+			valid, err := Verify(algorithm, message, signature, keys.GetPublicKey())
+			require.NoError(t, err)
+			assert.True(t, valid)
+
+			valid, err = Verify(algorithm, []byte("tampered"), signature, keys.GetPublicKey())
+			require.NoError(t, err)
+			assert.False(t, valid)
+		})
+	}
+}
+
+func TestVerifyRejectsKEMAlgorithms(t *testing.T) {
+	_, err := Verify(pb.Algorithm_KYBER768, []byte("message"), nil, "")
+	assert.ErrorIs(t, err, ErrNotSignatureAlgorithm)
+}
@@ -0,0 +1,187 @@
+package keymanagement
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/theaxiomverse/hydap-api/pkg/keymanagement/pb"
+)
+
+// DefaultKEMAlgorithm is the KEM node keys open peer channels with
+const DefaultKEMAlgorithm = pb.Algorithm_KYBER1024
+
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// NodeKeys are a node's long-term signing key pair and the KEM it uses for
+// the per-channel key exchange. Algorithms are named as in the pb.Algorithm
+// enum.
+type NodeKeys struct {
+	signer *keygen
+	kem    pb.Algorithm
+}
+
+// nodeKeyFile is the JSON layout of a node key file
+type nodeKeyFile struct {
+	SigningAlgorithm string `json:"signingAlgorithm"`
+	PublicKey        string `json:"publicKey"` // Base64
+	SecretKey        string `json:"secretKey"` // Base64
+	KEMAlgorithm     string `json:"kemAlgorithm"`
+}
+
+// GenerateNodeKeys creates a signing key pair with a signature algorithm
+func GenerateNodeKeys(algorithm pb.Algorithm) (*NodeKeys, error) {
+	if _, ok := SignatureAlgorithmName(algorithm); !ok {
+		return nil, ErrNotSignatureAlgorithm
+	}
+	signer := &keygen{alg: algorithm}
+	pk, sk, err := signer.generateKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	signer.publicKey, signer.privateKey = pk, sk
+	return &NodeKeys{signer: signer, kem: DefaultKEMAlgorithm}, nil
+}
+
+// LoadNodeKeys reads a key file written by Save
+func LoadNodeKeys(path string) (*NodeKeys, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read node keys: %w", err)
+	}
+	var file nodeKeyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse node keys: %w", err)
+	}
+
+	algorithm, err := parseAlgorithm(file.SigningAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := SignatureAlgorithmName(algorithm); !ok {
+		return nil, ErrNotSignatureAlgorithm
+	}
+	kem := DefaultKEMAlgorithm
+	if file.KEMAlgorithm != "" {
+		if kem, err = parseAlgorithm(file.KEMAlgorithm); err != nil {
+			return nil, err
+		}
+		if _, ok := KEMAlgorithmName(kem); !ok {
+			return nil, ErrUnsupportedAlgorithm
+		}
+	}
+
+	publicKey, err := base64.StdEncoding.DecodeString(file.PublicKey)
+	if err != nil {
+		return nil, ErrInvalidPublicKey
+	}
+	secretKey, err := base64.StdEncoding.DecodeString(file.SecretKey)
+	if err != nil {
+		return nil, ErrInvalidSecretKey
+	}
+	signer := &keygen{publicKey: publicKey, privateKey: secretKey, alg: algorithm}
+	return &NodeKeys{signer: signer, kem: kem}, nil
+}
+
+// Save writes the keys to a file readable only by the owner
+func (k *NodeKeys) Save(path string) error {
+	data, err := json.MarshalIndent(nodeKeyFile{
+		SigningAlgorithm: k.SigningAlgorithm(),
+		PublicKey:        k.signer.GetPublicKey(),
+		SecretKey:        base64.StdEncoding.EncodeToString(k.signer.privateKey),
+		KEMAlgorithm:     k.KEMAlgorithm(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+func (k *NodeKeys) SigningAlgorithm() string {
+	return k.signer.alg.String()
+}
+
+func (k *NodeKeys) SigningKey() []byte {
+	return k.signer.publicKey
+}
+
+func (k *NodeKeys) Sign(message []byte) ([]byte, error) {
+	return k.signer.Sign(message)
+}
+
+// Verify checks a signature by another node's signing key
+func (k *NodeKeys) Verify(algorithm string, key, message, signature []byte) error {
+	alg, err := parseAlgorithm(algorithm)
+	if err != nil {
+		return err
+	}
+	ok, err := Verify(alg, message, signature, base64.StdEncoding.EncodeToString(key))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (k *NodeKeys) KEMAlgorithm() string {
+	return k.kem.String()
+}
+
+// GenerateKEM creates a KEM key pair for a single channel
+func (k *NodeKeys) GenerateKEM() ([]byte, []byte, error) {
+	kem := &keygen{alg: k.kem}
+	return kem.generateKeyPair()
+}
+
+// Encapsulate creates a shared secret for the holder of a KEM public key
+func (k *NodeKeys) Encapsulate(algorithm string, public []byte) ([]byte, []byte, error) {
+	name, err := kemName(algorithm)
+	if err != nil {
+		return nil, nil, err
+	}
+	kem, err := initOqsKEM(name, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer kem.Clean()
+	return kem.EncapSecret(public)
+}
+
+// Decapsulate recovers the shared secret from a ciphertext made by
+// Encapsulate
+func (k *NodeKeys) Decapsulate(algorithm string, secret, ciphertext []byte) ([]byte, error) {
+	name, err := kemName(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	kem, err := initOqsKEM(name, secret)
+	if err != nil {
+		return nil, err
+	}
+	defer kem.Clean()
+	return kem.DecapSecret(ciphertext)
+}
+
+func parseAlgorithm(name string) (pb.Algorithm, error) {
+	value, ok := pb.Algorithm_value[name]
+	if !ok {
+		return pb.Algorithm_NONE, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, name)
+	}
+	return pb.Algorithm(value), nil
+}
+
+func kemName(algorithm string) (string, error) {
+	alg, err := parseAlgorithm(algorithm)
+	if err != nil {
+		return "", err
+	}
+	name, ok := KEMAlgorithmName(alg)
+	if !ok {
+		return "", fmt.Errorf("%w: %s is not a KEM", ErrUnsupportedAlgorithm, algorithm)
+	}
+	return name, nil
+}
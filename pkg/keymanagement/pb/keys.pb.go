@@ -25,17 +25,21 @@ const (
 type Algorithm int32
 
 const (
-	Algorithm_NONE         Algorithm = 0
-	Algorithm_KYBER512     Algorithm = 1
-	Algorithm_KYBER768     Algorithm = 2
-	Algorithm_KYBER1024    Algorithm = 3
-	Algorithm_FALCON512    Algorithm = 4
-	Algorithm_DILITHIUM2   Algorithm = 5
-	Algorithm_DILITHIUM3   Algorithm = 6
-	Algorithm_EDWARDS25519 Algorithm = 7
-	Algorithm_ECDSA        Algorithm = 8
-	Algorithm_RSA          Algorithm = 9
-	Algorithm_EDDSA        Algorithm = 10
+	Algorithm_NONE              Algorithm = 0
+	Algorithm_KYBER512          Algorithm = 1
+	Algorithm_KYBER768          Algorithm = 2
+	Algorithm_KYBER1024         Algorithm = 3
+	Algorithm_FALCON512         Algorithm = 4
+	Algorithm_DILITHIUM2        Algorithm = 5
+	Algorithm_DILITHIUM3        Algorithm = 6
+	Algorithm_EDWARDS25519      Algorithm = 7
+	Algorithm_ECDSA             Algorithm = 8
+	Algorithm_RSA               Algorithm = 9
+	Algorithm_EDDSA             Algorithm = 10
+	Algorithm_FALCON1024        Algorithm = 11
+	Algorithm_DILITHIUM5        Algorithm = 12
+	Algorithm_SPHINCS_SHA2_128S Algorithm = 13
+	Algorithm_SPHINCS_SHA2_256S Algorithm = 14
 )
 
 // Enum value maps for Algorithm.
@@ -52,19 +56,27 @@ var (
 		8:  "ECDSA",
 		9:  "RSA",
 		10: "EDDSA",
+		11: "FALCON1024",
+		12: "DILITHIUM5",
+		13: "SPHINCS_SHA2_128S",
+		14: "SPHINCS_SHA2_256S",
 	}
 	Algorithm_value = map[string]int32{
-		"NONE":         0,
-		"KYBER512":     1,
-		"KYBER768":     2,
-		"KYBER1024":    3,
-		"FALCON512":    4,
-		"DILITHIUM2":   5,
-		"DILITHIUM3":   6,
-		"EDWARDS25519": 7,
-		"ECDSA":        8,
-		"RSA":          9,
-		"EDDSA":        10,
+		"NONE":              0,
+		"KYBER512":          1,
+		"KYBER768":          2,
+		"KYBER1024":         3,
+		"FALCON512":         4,
+		"DILITHIUM2":        5,
+		"DILITHIUM3":        6,
+		"EDWARDS25519":      7,
+		"ECDSA":             8,
+		"RSA":               9,
+		"EDDSA":             10,
+		"FALCON1024":        11,
+		"DILITHIUM5":        12,
+		"SPHINCS_SHA2_128S": 13,
+		"SPHINCS_SHA2_256S": 14,
 	}
 )
 
@@ -156,7 +168,7 @@ var file_proto_keys_proto_rawDesc = []byte{
 	0x09, 0x61, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e,
 	0x32, 0x0d, 0x2e, 0x70, 0x62, 0x2e, 0x41, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x52,
 	0x09, 0x61, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x12, 0x12, 0x0a, 0x04, 0x6b, 0x65,
-	0x79, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6b, 0x65, 0x79, 0x73, 0x2a, 0xa0,
+	0x79, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6b, 0x65, 0x79, 0x73, 0x2a, 0xee,
 	0x01, 0x0a, 0x09, 0x41, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x12, 0x08, 0x0a, 0x04,
 	0x4e, 0x4f, 0x4e, 0x45, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x4b, 0x59, 0x42, 0x45, 0x52, 0x35,
 	0x31, 0x32, 0x10, 0x01, 0x12, 0x0c, 0x0a, 0x08, 0x4b, 0x59, 0x42, 0x45, 0x52, 0x37, 0x36, 0x38,
@@ -167,10 +179,16 @@ var file_proto_keys_proto_rawDesc = []byte{
 	0x12, 0x10, 0x0a, 0x0c, 0x45, 0x44, 0x57, 0x41, 0x52, 0x44, 0x53, 0x32, 0x35, 0x35, 0x31, 0x39,
 	0x10, 0x07, 0x12, 0x09, 0x0a, 0x05, 0x45, 0x43, 0x44, 0x53, 0x41, 0x10, 0x08, 0x12, 0x07, 0x0a,
 	0x03, 0x52, 0x53, 0x41, 0x10, 0x09, 0x12, 0x09, 0x0a, 0x05, 0x45, 0x44, 0x44, 0x53, 0x41, 0x10,
-	0x0a, 0x42, 0x2c, 0x5a, 0x2a, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f,
-	0x74, 0x68, 0x65, 0x61, 0x78, 0x69, 0x6f, 0x6d, 0x76, 0x65, 0x72, 0x73, 0x65, 0x2f, 0x68, 0x79,
-	0x64, 0x61, 0x70, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x3b, 0x70, 0x62, 0x62,
-	0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x0a, 0x12, 0x0e, 0x0a, 0x0a, 0x46, 0x41, 0x4c, 0x43, 0x4f, 0x4e, 0x31, 0x30, 0x32, 0x34, 0x10,
+	0x0b, 0x12, 0x0e, 0x0a, 0x0a, 0x44, 0x49, 0x4c, 0x49, 0x54, 0x48, 0x49, 0x55, 0x4d, 0x35, 0x10,
+	0x0c, 0x12, 0x15, 0x0a, 0x11, 0x53, 0x50, 0x48, 0x49, 0x4e, 0x43, 0x53, 0x5f, 0x53, 0x48, 0x41,
+	0x32, 0x5f, 0x31, 0x32, 0x38, 0x53, 0x10, 0x0d, 0x12, 0x15, 0x0a, 0x11, 0x53, 0x50, 0x48, 0x49,
+	0x4e, 0x43, 0x53, 0x5f, 0x53, 0x48, 0x41, 0x32, 0x5f, 0x32, 0x35, 0x36, 0x53, 0x10, 0x0e, 0x42,
+	0x3c, 0x5a, 0x3a, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x74, 0x68,
+	0x65, 0x61, 0x78, 0x69, 0x6f, 0x6d, 0x76, 0x65, 0x72, 0x73, 0x65, 0x2f, 0x68, 0x79, 0x64, 0x61,
+	0x70, 0x2d, 0x61, 0x70, 0x69, 0x2f, 0x70, 0x6b, 0x67, 0x2f, 0x6b, 0x65, 0x79, 0x6d, 0x61, 0x6e,
+	0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2f, 0x70, 0x62, 0x3b, 0x70, 0x62, 0x62, 0x06, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -9,10 +9,10 @@ import (
 
 // ChainAccelerator handles chain compression and acceleration
 type ChainAccelerator struct {
-	chains      map[string]*AcceleratedChain
-	vectorIndex *vectors.InfiniteVectorIndex
-	batchSize   int
-	mu          sync.RWMutex
+	chains    map[string]*AcceleratedChain
+	clusters  *vectors.Clusterer // Groups transactions for batching
+	batchSize int
+	mu        sync.RWMutex
 }
 
 func NewChain(id, endpoint, protocol string) *Chain {
@@ -21,8 +21,8 @@ func NewChain(id, endpoint, protocol string) *Chain {
 		Endpoint:            endpoint,
 		Protocol:            protocol,
 		TransactionPool:     vectors.NewInfiniteVectorIndex(),
-		streamingCompressor: NewAdaptiveCompressor(CompressorConfig{}), // Initialize with batch size 100
-		compressedBlocks:    make([]*CompressedBlock, 0),
+		streamingCompressor: NewAdaptiveCompressor(archiveCompressorConfig),
+		compressedBlocks:    make([]*ArchiveBlock, 0),
 	}
 }
 
@@ -43,11 +43,20 @@ type BatchProcessor struct {
 	mu          sync.Mutex
 }
 
-func NewChainAccelerator() *ChainAccelerator {
+// NewChainAccelerator creates an accelerator that batches transactions by
+// comparing dims vector dimensions, DefaultCompareDims if not positive
+func NewChainAccelerator(dims int) *ChainAccelerator {
+	if dims <= 0 {
+		dims = DefaultCompareDims
+	}
 	return &ChainAccelerator{
-		chains:      make(map[string]*AcceleratedChain),
-		vectorIndex: vectors.NewInfiniteVectorIndex(),
-		batchSize:   100,
+		chains: make(map[string]*AcceleratedChain),
+		clusters: vectors.NewClusterer(vectors.ClusterConfig{
+			MaxClusters: defaultMaxClusters,
+			Threshold:   defaultClusterThreshold,
+			Dimensions:  dims,
+		}),
+		batchSize: 100,
 	}
 }
 
@@ -97,29 +106,35 @@ func (ca *ChainAccelerator) ProcessTransactions(ctx context.Context, txs []*Tran
 	return nil
 }
 
+// groupTransactionsByVector batches transactions by cluster. Clusters
+// persist across calls, so similar transactions land in the same group
+// whichever arrived first; transactions without a state vector are batched
+// alone.
 func (ca *ChainAccelerator) groupTransactionsByVector(txs []*Transaction) [][]*Transaction {
-	groups := make(map[string][]*Transaction)
+	groups := make(map[int][]*Transaction)
+	var result [][]*Transaction
 
-	// Group by vector similarity
 	for _, tx := range txs {
-		similar := ca.vectorIndex.AdvancedQuery(0.8, tx.StateVector, 50)
-		if len(similar) > 0 {
-			groupID := similar[0].ID
-			groups[groupID] = append(groups[groupID], tx)
-		} else {
-			groupID := tx.ID
-			groups[groupID] = []*Transaction{tx}
+		cluster, _, ok := ca.clusters.Add("", &tx.StateVector)
+		if !ok {
+			result = append(result, []*Transaction{tx})
+			continue
 		}
+		groups[cluster] = append(groups[cluster], tx)
 	}
 
 	// Convert to slice
-	result := make([][]*Transaction, 0, len(groups))
 	for _, group := range groups {
 		result = append(result, group)
 	}
 	return result
 }
 
+// Clusters describes the clusters transactions are batched by
+func (ca *ChainAccelerator) Clusters() []vectors.ClusterInfo {
+	return ca.clusters.Clusters()
+}
+
 func (ca *ChainAccelerator) processBatch(ctx context.Context, txs []*Transaction) {
 	bp := &BatchProcessor{
 		pendingTxs:  txs,
@@ -0,0 +1,91 @@
+package agglomerator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrConfirmationsUnsupported = errors.New("chain cannot report confirmations")
+	ErrFeesUnsupported          = errors.New("chain cannot estimate fees")
+	ErrSimulationUnsupported    = errors.New("chain cannot simulate transactions")
+	ErrSimulationFailed         = errors.New("transaction simulation failed")
+)
+
+// ChainAdapter submits transactions to the network behind a chain
+type ChainAdapter interface {
+	Protocol() string
+	Submit(ctx context.Context, tx *Transaction) (*SubmitReceipt, error)
+	BlockHeight() uint64
+}
+
+// SubmitReceipt reports where a submitted transaction was included
+type SubmitReceipt struct {
+	TxID        string        `json:"txId"`
+	ChainID     string        `json:"chainId"`
+	TxHash      string        `json:"txHash,omitempty"` // The transaction's hash on the chain's network
+	BlockHeight uint64        `json:"blockHeight"`
+	BlockHash   string        `json:"blockHash,omitempty"`
+	Latency     time.Duration `json:"latency"`
+	FeePayer    string        `json:"feePayer,omitempty"` // Sponsor wallet that paid the fee, if not the sender
+}
+
+// Confirmation reports whether a transaction was found on a chain's network
+// and how deeply it is buried
+type Confirmation struct {
+	TxID          string `json:"txId"`
+	ChainID       string `json:"chainId"`
+	Found         bool   `json:"found"`
+	BlockHeight   uint64 `json:"blockHeight,omitempty"`
+	Confirmations uint64 `json:"confirmations"`
+}
+
+// ConfirmationReporter is implemented by adapters that can look up
+// transactions on their network
+type ConfirmationReporter interface {
+	Confirmation(ctx context.Context, txID string) (*Confirmation, error)
+}
+
+// FeeEstimator is implemented by adapters that can estimate the fee a
+// transaction currently pays on their network
+type FeeEstimator interface {
+	EstimateFee(ctx context.Context) (float64, error)
+}
+
+// Simulator is implemented by adapters that can dry-run a transaction
+// against their network's current state without submitting it, as
+// eth_call or simulateTransaction do. A transaction the network would
+// reject fails with an error wrapping ErrSimulationFailed.
+type Simulator interface {
+	Simulate(ctx context.Context, tx *Transaction) error
+}
+
+// ChainAdapterFactory builds an adapter for a chain from its endpoint
+type ChainAdapterFactory func(chainID, endpoint string) (ChainAdapter, error)
+
+var (
+	adaptersMu sync.RWMutex
+	adapters   = make(map[string]ChainAdapterFactory)
+)
+
+// RegisterChainAdapter makes chains of a protocol submit through an adapter
+func RegisterChainAdapter(protocol string, factory ChainAdapterFactory) {
+	adaptersMu.Lock()
+	defer adaptersMu.Unlock()
+	adapters[protocol] = factory
+}
+
+// newChainAdapter builds the adapter for a protocol, or returns nil when the
+// protocol has none and submission is left to the routing layer
+func newChainAdapter(protocol, chainID, endpoint string) (ChainAdapter, error) {
+	adaptersMu.RLock()
+	factory, exists := adapters[protocol]
+	adaptersMu.RUnlock()
+
+	if !exists {
+		return nil, nil
+	}
+	return factory(chainID, endpoint)
+}
@@ -0,0 +1,254 @@
+package agglomerator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ProtocolMock is a simulated chain for tests and demos
+const ProtocolMock = "mock"
+
+var ErrMockSubmitFailed = errors.New("mock chain rejected transaction")
+
+func init() {
+	protocolConfigs[ProtocolMock] = ChainProtocol{
+		ID:               ProtocolMock,
+		BlockTime:        1,
+		ConfirmationTime: 2,
+		TPS:              1000,
+		Finality:         2,
+		CostWeight:       0.1,
+	}
+	RegisterChainAdapter(ProtocolMock, func(chainID, endpoint string) (ChainAdapter, error) {
+		config, err := parseMockEndpoint(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		return NewMockChain(chainID, config), nil
+	})
+}
+
+// MockChainConfig controls how a mock chain behaves
+type MockChainConfig struct {
+	BlockTime     time.Duration // Interval between simulated blocks
+	FailureRate   float64       // Probability that a submission is rejected
+	LatencyJitter time.Duration // Extra random delay added to each submission
+	BaseFee       float64       // Fee estimated with no transactions in flight
+	RevertRate    float64       // Share of transactions that revert, chosen by transaction ID
+}
+
+// DefaultMockChainConfig returns a fast, reliable mock chain
+func DefaultMockChainConfig() MockChainConfig {
+	return MockChainConfig{
+		BlockTime: time.Second,
+		BaseFee:   0.001,
+	}
+}
+
+// parseMockEndpoint reads mock settings from an endpoint such as
+// mock://local?blockTime=2s&failureRate=0.1&jitter=200ms&baseFee=0.01&revertRate=0.05
+func parseMockEndpoint(endpoint string) (MockChainConfig, error) {
+	config := DefaultMockChainConfig()
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return config, fmt.Errorf("invalid mock endpoint %q: %v", endpoint, err)
+	}
+
+	query := u.Query()
+	if value := query.Get("blockTime"); value != "" {
+		if config.BlockTime, err = time.ParseDuration(value); err != nil || config.BlockTime <= 0 {
+			return config, fmt.Errorf("mock endpoint: invalid blockTime %q", value)
+		}
+	}
+	if value := query.Get("failureRate"); value != "" {
+		if config.FailureRate, err = strconv.ParseFloat(value, 64); err != nil || config.FailureRate < 0 || config.FailureRate > 1 {
+			return config, fmt.Errorf("mock endpoint: failureRate must be between 0 and 1, got %q", value)
+		}
+	}
+	if value := query.Get("jitter"); value != "" {
+		if config.LatencyJitter, err = time.ParseDuration(value); err != nil || config.LatencyJitter < 0 {
+			return config, fmt.Errorf("mock endpoint: invalid jitter %q", value)
+		}
+	}
+	if value := query.Get("baseFee"); value != "" {
+		if config.BaseFee, err = strconv.ParseFloat(value, 64); err != nil || config.BaseFee < 0 {
+			return config, fmt.Errorf("mock endpoint: invalid baseFee %q", value)
+		}
+	}
+	if value := query.Get("revertRate"); value != "" {
+		if config.RevertRate, err = strconv.ParseFloat(value, 64); err != nil || config.RevertRate < 0 || config.RevertRate > 1 {
+			return config, fmt.Errorf("mock endpoint: revertRate must be between 0 and 1, got %q", value)
+		}
+	}
+	return config, nil
+}
+
+// MockChain simulates block production and inclusion latency without RPC
+type MockChain struct {
+	chainID  string
+	config   MockChainConfig
+	genesis  time.Time
+	rng      *rand.Rand
+	included map[string]uint64 // Block height by transaction ID
+	inFlight int               // Submissions waiting for a block
+	mu       sync.Mutex
+}
+
+func NewMockChain(chainID string, config MockChainConfig) *MockChain {
+	if config.BlockTime <= 0 {
+		config.BlockTime = DefaultMockChainConfig().BlockTime
+	}
+	return &MockChain{
+		chainID:  chainID,
+		config:   config,
+		genesis:  time.Now(),
+		rng:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		included: make(map[string]uint64),
+	}
+}
+
+func (m *MockChain) Protocol() string {
+	return ProtocolMock
+}
+
+// mockHash derives a stable hex hash, standing in for the transaction and
+// block hashes of a real network
+func mockHash(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return "0x" + hex.EncodeToString(h.Sum(nil))
+}
+
+// BlockHeight returns the number of blocks produced since the chain started
+func (m *MockChain) BlockHeight() uint64 {
+	return uint64(time.Since(m.genesis) / m.config.BlockTime)
+}
+
+// Probe always succeeds; mock chains have nothing to reach
+func (m *MockChain) Probe(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// Submit waits for the next simulated block plus jitter, then includes the
+// transaction or rejects it according to the failure rate
+func (m *MockChain) Submit(ctx context.Context, tx *Transaction) (*SubmitReceipt, error) {
+	start := time.Now()
+
+	m.mu.Lock()
+	var jitter time.Duration
+	if m.config.LatencyJitter > 0 {
+		jitter = time.Duration(m.rng.Int63n(int64(m.config.LatencyJitter)))
+	}
+	failed := m.rng.Float64() < m.config.FailureRate
+	m.inFlight++
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.inFlight--
+		m.mu.Unlock()
+	}()
+
+	elapsed := time.Since(m.genesis)
+	untilBlock := m.config.BlockTime - elapsed%m.config.BlockTime
+
+	timer := time.NewTimer(untilBlock + jitter)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+	}
+
+	if failed {
+		return nil, fmt.Errorf("%w: %s on %s", ErrMockSubmitFailed, tx.ID, m.chainID)
+	}
+	if m.reverts(tx) {
+		return nil, fmt.Errorf("%w: %s reverted on %s", ErrMockSubmitFailed, tx.ID, m.chainID)
+	}
+
+	height := m.BlockHeight()
+	m.mu.Lock()
+	m.included[tx.ID] = height
+	m.mu.Unlock()
+
+	return &SubmitReceipt{
+		TxID:        tx.ID,
+		ChainID:     m.chainID,
+		TxHash:      mockHash(m.chainID, "tx", tx.ID),
+		BlockHeight: height,
+		BlockHash:   mockHash(m.chainID, "block", strconv.FormatUint(height, 10)),
+		Latency:     time.Since(start),
+		FeePayer:    tx.Sponsorship.Sponsor(m.chainID),
+	}, nil
+}
+
+// reverts reports whether tx is among the revertRate share of transactions
+// this chain reverts. The choice hashes the chain and transaction IDs, so
+// simulation agrees with submission and another chain may take the
+// transaction.
+func (m *MockChain) reverts(tx *Transaction) bool {
+	if m.config.RevertRate <= 0 {
+		return false
+	}
+	h := fnv.New64a()
+	h.Write([]byte(m.chainID))
+	h.Write([]byte{0})
+	h.Write([]byte(tx.ID))
+	return float64(h.Sum64())/math.MaxUint64 < m.config.RevertRate
+}
+
+// Simulate rejects the transactions Submit would revert, without waiting
+// for a block
+func (m *MockChain) Simulate(ctx context.Context, tx *Transaction) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if m.reverts(tx) {
+		return fmt.Errorf("%w: %s reverts on %s", ErrSimulationFailed, tx.ID, m.chainID)
+	}
+	return nil
+}
+
+// EstimateFee returns the base fee, raised by each submission waiting for a
+// block as congestion would
+func (m *MockChain) EstimateFee(ctx context.Context) (float64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.config.BaseFee * (1 + 0.1*float64(m.inFlight)), nil
+}
+
+// Confirmation reports transactions submitted to this mock chain, confirmed
+// by every block produced since their inclusion
+func (m *MockChain) Confirmation(ctx context.Context, txID string) (*Confirmation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	height, found := m.included[txID]
+	m.mu.Unlock()
+
+	confirmation := &Confirmation{TxID: txID, ChainID: m.chainID, Found: found}
+	if found {
+		confirmation.BlockHeight = height
+		confirmation.Confirmations = m.BlockHeight() - height
+	}
+	return confirmation, nil
+}
@@ -0,0 +1,69 @@
+package agglomerator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockEndpointParsing(t *testing.T) {
+	config, err := parseMockEndpoint("mock://local?blockTime=20ms&failureRate=0.25&jitter=5ms&revertRate=0.1")
+	require.NoError(t, err)
+	assert.Equal(t, 20*time.Millisecond, config.BlockTime)
+	assert.Equal(t, 0.25, config.FailureRate)
+	assert.Equal(t, 5*time.Millisecond, config.LatencyJitter)
+	assert.Equal(t, 0.1, config.RevertRate)
+
+	_, err = parseMockEndpoint("mock://local?failureRate=2")
+	assert.Error(t, err)
+	_, err = parseMockEndpoint("mock://local?revertRate=-1")
+	assert.Error(t, err)
+}
+
+func TestMockChainSubmit(t *testing.T) {
+	chain := NewMockChain("mock-a", MockChainConfig{BlockTime: 10 * time.Millisecond})
+
+	receipt, err := chain.Submit(context.Background(), &Transaction{ID: "tx-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "tx-1", receipt.TxID)
+	assert.Equal(t, "mock-a", receipt.ChainID)
+	assert.GreaterOrEqual(t, receipt.BlockHeight, uint64(1))
+
+	failing := NewMockChain("mock-b", MockChainConfig{BlockTime: time.Millisecond, FailureRate: 1})
+	_, err = failing.Submit(context.Background(), &Transaction{ID: "tx-2"})
+	assert.ErrorIs(t, err, ErrMockSubmitFailed)
+
+	slow := NewMockChain("mock-c", MockChainConfig{BlockTime: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = slow.Submit(ctx, &Transaction{ID: "tx-3"})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRegisterChainAttachesMockAdapter(t *testing.T) {
+	agg := NewAgglomerator(AgglomeratorConfig{})
+
+	chain := NewChain("mock-chain", "mock://local?blockTime=5ms", ProtocolMock)
+	require.NoError(t, agg.RegisterChain(chain))
+	require.NotNil(t, chain.Adapter())
+	assert.Equal(t, ProtocolMock, chain.Adapter().Protocol())
+
+	eth := NewChain("eth-chain", "http://localhost:8545", ProtocolEthereum)
+	require.NoError(t, agg.RegisterChain(eth))
+	assert.Nil(t, eth.Adapter())
+}
+
+func TestMockChainFeePayer(t *testing.T) {
+	chain := NewMockChain("mock-a", MockChainConfig{BlockTime: time.Millisecond})
+	tx := &Transaction{ID: "tx-1", Sponsorship: &Sponsorship{Hops: []SponsoredHop{{ChainID: "mock-a", Sponsor: "0xsponsor"}}}}
+	receipt, err := chain.Submit(context.Background(), tx)
+	require.NoError(t, err)
+	assert.Equal(t, "0xsponsor", receipt.FeePayer)
+
+	receipt, err = chain.Submit(context.Background(), &Transaction{ID: "tx-2"})
+	require.NoError(t, err)
+	assert.Empty(t, receipt.FeePayer, "unsponsored transactions pay their own fees")
+}
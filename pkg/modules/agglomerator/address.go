@@ -0,0 +1,146 @@
+package agglomerator
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidAddress is returned for peer addresses that cannot be dialed
+var ErrInvalidAddress = errors.New("invalid peer address")
+
+// ParsePeerAddress validates a peer address and returns it in canonical
+// form. Addresses are either host:port, with IPv6 hosts in brackets, or
+// multiaddrs such as /ip6/2001:db8::1/tcp/9000/p2p/<peer-id>. A bare host or
+// IP, including an unbracketed IPv6 address, takes defaultPort; 0 requires
+// a port. Unspecified and multicast IPs are rejected, as peers cannot be
+// reached at them.
+func ParsePeerAddress(address string, defaultPort int) (string, error) {
+	if strings.HasPrefix(address, "/") {
+		return parseMultiaddr(address)
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		// A bare host, or an IPv6 address whose colons are not a port
+		host = strings.TrimSuffix(strings.TrimPrefix(address, "["), "]")
+		if defaultPort == 0 {
+			return "", fmt.Errorf("%w %q: missing port", ErrInvalidAddress, address)
+		}
+		port = strconv.Itoa(defaultPort)
+	}
+
+	host, err = normalizeHost(host)
+	if err != nil {
+		return "", fmt.Errorf("%w %q: %v", ErrInvalidAddress, address, err)
+	}
+	if err := validatePort(port); err != nil {
+		return "", fmt.Errorf("%w %q: %v", ErrInvalidAddress, address, err)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// parseMultiaddr validates the network and transport parts of a multiaddr,
+// canonicalizing its IP. Parts after the port, such as /p2p/<peer-id>, are
+// kept as given.
+func parseMultiaddr(address string) (string, error) {
+	parts := strings.Split(strings.TrimPrefix(address, "/"), "/")
+	if len(parts) < 4 {
+		return "", fmt.Errorf("%w %q: multiaddr needs a network and transport", ErrInvalidAddress, address)
+	}
+
+	switch parts[0] {
+	case "ip4", "ip6":
+		ip := net.ParseIP(parts[1])
+		if ip == nil || (parts[0] == "ip4") != (ip.To4() != nil) {
+			return "", fmt.Errorf("%w %q: bad %s address", ErrInvalidAddress, address, parts[0])
+		}
+		if err := checkIP(ip); err != nil {
+			return "", fmt.Errorf("%w %q: %v", ErrInvalidAddress, address, err)
+		}
+		parts[1] = ip.String()
+	case "dns", "dns4", "dns6":
+		host, err := normalizeHost(parts[1])
+		if err != nil {
+			return "", fmt.Errorf("%w %q: %v", ErrInvalidAddress, address, err)
+		}
+		parts[1] = host
+	default:
+		return "", fmt.Errorf("%w %q: unsupported network %s", ErrInvalidAddress, address, parts[0])
+	}
+
+	if parts[2] != "tcp" && parts[2] != "udp" {
+		return "", fmt.Errorf("%w %q: unsupported transport %s", ErrInvalidAddress, address, parts[2])
+	}
+	if err := validatePort(parts[3]); err != nil {
+		return "", fmt.Errorf("%w %q: %v", ErrInvalidAddress, address, err)
+	}
+	return "/" + strings.Join(parts, "/"), nil
+}
+
+// multiaddrHostPort returns the host:port of a TCP multiaddr, for
+// transports that dial plain addresses. Other addresses are returned as is.
+func multiaddrHostPort(address string) string {
+	if !strings.HasPrefix(address, "/") {
+		return address
+	}
+	parts := strings.Split(strings.TrimPrefix(address, "/"), "/")
+	if len(parts) < 4 || parts[2] != "tcp" {
+		return address
+	}
+	return net.JoinHostPort(parts[1], parts[3])
+}
+
+// normalizeHost canonicalizes an IP or lowercases a hostname
+func normalizeHost(host string) (string, error) {
+	if host == "" {
+		return "", errors.New("missing host")
+	}
+
+	// Link-local IPv6 addresses carry the interface as a zone
+	ipPart, zone, _ := strings.Cut(host, "%")
+	if ip := net.ParseIP(ipPart); ip != nil {
+		if err := checkIP(ip); err != nil {
+			return "", err
+		}
+		if zone != "" {
+			return ip.String() + "%" + zone, nil
+		}
+		return ip.String(), nil
+	}
+
+	if len(host) > 253 {
+		return "", errors.New("hostname too long")
+	}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return "", fmt.Errorf("bad hostname %s", host)
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return "", fmt.Errorf("bad hostname %s", host)
+			}
+		}
+	}
+	return strings.ToLower(host), nil
+}
+
+func checkIP(ip net.IP) error {
+	switch {
+	case ip.IsUnspecified():
+		return fmt.Errorf("unspecified address %s", ip)
+	case ip.IsMulticast():
+		return fmt.Errorf("multicast address %s", ip)
+	}
+	return nil
+}
+
+func validatePort(port string) error {
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("bad port %s", port)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package agglomerator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePeerAddress(t *testing.T) {
+	valid := map[string]string{
+		"10.0.0.1:9000":                     "10.0.0.1:9000",
+		"[2001:DB8::1]:9000":                "[2001:db8::1]:9000",
+		"2001:db8:0:0:0:0:0:1":              "[2001:db8::1]:7000",
+		"[::ffff:10.0.0.1]":                 "10.0.0.1:7000",
+		"[fe80::1%eth0]:9000":               "[fe80::1%eth0]:9000",
+		"Node-1.Example.com:9000":           "node-1.example.com:9000",
+		"node-1":                            "node-1:7000",
+		"/ip6/2001:db8:0::1/tcp/9000":       "/ip6/2001:db8::1/tcp/9000",
+		"/ip4/10.0.0.1/tcp/9000/p2p/QmPeer": "/ip4/10.0.0.1/tcp/9000/p2p/QmPeer",
+		"/dns/Node-1.example.com/tcp/9000":  "/dns/node-1.example.com/tcp/9000",
+	}
+	for input, want := range valid {
+		got, err := ParsePeerAddress(input, 7000)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, got, input)
+	}
+
+	invalid := []string{
+		"",
+		"0.0.0.0:9000",
+		"[::]:9000",
+		"[ff02::1]:9000",
+		"10.0.0.1:0",
+		"10.0.0.1:70000",
+		"bad_host:9000",
+		"-node:9000",
+		"/ip4/2001:db8::1/tcp/9000",
+		"/ip6/::/tcp/9000",
+		"/unix/tmp/sock/9000",
+		"/ip4/10.0.0.1",
+	}
+	for _, input := range invalid {
+		_, err := ParsePeerAddress(input, 7000)
+		assert.ErrorIs(t, err, ErrInvalidAddress, input)
+	}
+
+	_, err := ParsePeerAddress("10.0.0.1", 0)
+	assert.ErrorIs(t, err, ErrInvalidAddress, "a port is required without a default")
+}
+
+func TestDialAddress(t *testing.T) {
+	node := NewP2PInfiniteVectorNode("", 7000)
+	transport, err := NewTransport(TransportConfig{Type: "tcp"})
+	require.NoError(t, err)
+
+	address, err := node.dialAddress(transport, "2001:db8::1")
+	require.NoError(t, err)
+	assert.Equal(t, "[2001:db8::1]:7000", address, "peers without a port share ours")
+
+	address, err = node.dialAddress(transport, "/ip6/2001:db8::1/tcp/9000")
+	require.NoError(t, err)
+	assert.Equal(t, "[2001:db8::1]:9000", address, "TCP dials multiaddrs as host:port")
+}
+
+func TestProcessPeerDiscovery(t *testing.T) {
+	node := NewP2PInfiniteVectorNode("", 7000)
+
+	node.processPeerDiscovery(PeerDiscoveryMessage{SenderID: "peer-a", SenderAddr: "[2001:DB8::1]:9000"})
+	node.processPeerDiscovery(PeerDiscoveryMessage{SenderID: "peer-b", SenderAddr: "0.0.0.0:9000"})
+	node.processPeerDiscovery(PeerDiscoveryMessage{SenderID: "peer-c", SenderAddr: "not an address"})
+
+	require.Contains(t, node.peers, "peer-a")
+	assert.Equal(t, "[2001:db8::1]:9000", node.peers["peer-a"].Address)
+	assert.NotContains(t, node.peers, "peer-b", "unspecified addresses cannot be dialed")
+	assert.NotContains(t, node.peers, "peer-c")
+
+	node.processPeerDiscovery(PeerDiscoveryMessage{SenderID: "peer-a", SenderAddr: "10.0.0.2:9000"})
+	assert.Equal(t, "10.0.0.2:9000", node.peers["peer-a"].Address, "a known peer's address is refreshed")
+}
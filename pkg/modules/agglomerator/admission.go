@@ -0,0 +1,266 @@
+package agglomerator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// maxAdmissionDifficulty bounds the work asked of each node ID; every bit
+// doubles it
+const maxAdmissionDifficulty = 32
+
+// stakeCacheTTL is how long a peer's stake check holds before the oracle is
+// asked again
+const stakeCacheTTL = 10 * time.Minute
+
+var ErrAdmissionRefused = errors.New("peer not admitted")
+
+// AdmissionConfig sets what joining the peer set costs, so an attacker
+// cannot take over replication by starting thousands of nodes. Every node on
+// a network should use the same settings.
+type AdmissionConfig struct {
+	Difficulty  int      // Leading zero bits of the proof of work for the node's ID and signing key; 0 asks none
+	Allowlist   []string // Node IDs admitted without work or stake; when set, no others are
+	StakeOracle string   // Registered oracle asked for each peer's stake
+	MinStake    float64  // Stake a peer must hold; 0 skips the oracle
+}
+
+// AdmissionStats counts the peers admitted and refused
+type AdmissionStats struct {
+	Admitted uint64 `json:"admitted"`
+	Refused  uint64 `json:"refused"`
+}
+
+// StakeOracle reports the stake a node has bonded, for example read from a
+// staking contract
+type StakeOracle func(ctx context.Context, nodeID string) (float64, error)
+
+var (
+	stakeOraclesMu sync.RWMutex
+	stakeOracles   = make(map[string]StakeOracle)
+)
+
+// RegisterStakeOracle makes a stake oracle selectable by name
+func RegisterStakeOracle(name string, oracle StakeOracle) {
+	stakeOraclesMu.Lock()
+	defer stakeOraclesMu.Unlock()
+	stakeOracles[name] = oracle
+}
+
+// Admission decides which peers may enter the routing and replication
+// tables
+type Admission struct {
+	mu        sync.Mutex
+	config    AdmissionConfig
+	allowlist map[string]bool
+	oracle    StakeOracle
+	staked    map[string]time.Time // When each peer last passed the stake check
+	stats     AdmissionStats
+}
+
+// NewAdmission checks config and resolves its stake oracle
+func NewAdmission(config AdmissionConfig) (*Admission, error) {
+	if config.Difficulty < 0 || config.Difficulty > maxAdmissionDifficulty {
+		return nil, fmt.Errorf("admission difficulty must be between 0 and %d", maxAdmissionDifficulty)
+	}
+	if config.MinStake < 0 {
+		return nil, errors.New("admission stake must not be negative")
+	}
+
+	a := openAdmission()
+	a.config = config
+	for _, nodeID := range config.Allowlist {
+		a.allowlist[nodeID] = true
+	}
+	if config.MinStake > 0 {
+		stakeOraclesMu.RLock()
+		a.oracle = stakeOracles[config.StakeOracle]
+		stakeOraclesMu.RUnlock()
+		if a.oracle == nil {
+			return nil, fmt.Errorf("unknown stake oracle %q", config.StakeOracle)
+		}
+	}
+	return a, nil
+}
+
+// openAdmission admits every peer
+func openAdmission() *Admission {
+	return &Admission{allowlist: make(map[string]bool), staked: make(map[string]time.Time)}
+}
+
+// Config returns the admission settings
+func (a *Admission) Config() AdmissionConfig {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.config
+}
+
+// Stats returns the admission counters
+func (a *Admission) Stats() AdmissionStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.stats
+}
+
+// Admit checks a peer's node ID against the allowlist, its proof of work for
+// the ID and signing key, and its stake, in that order
+func (a *Admission) Admit(ctx context.Context, nodeID string, signingKey []byte, nonce uint64) error {
+	err := a.check(ctx, nodeID, signingKey, nonce)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err != nil {
+		a.stats.Refused++
+		return err
+	}
+	a.stats.Admitted++
+	return nil
+}
+
+func (a *Admission) check(ctx context.Context, nodeID string, signingKey []byte, nonce uint64) error {
+	a.mu.Lock()
+	config, oracle := a.config, a.oracle
+	allowed := a.allowlist[nodeID]
+	stakedAt, staked := a.staked[nodeID]
+	a.mu.Unlock()
+
+	if allowed {
+		return nil
+	}
+	if len(config.Allowlist) > 0 {
+		return fmt.Errorf("%w: %s is not on the allowlist", ErrAdmissionRefused, nodeID)
+	}
+	if !VerifyAdmission(nodeID, signingKey, nonce, config.Difficulty) {
+		return fmt.Errorf("%w: %s has no proof of work at difficulty %d", ErrAdmissionRefused, nodeID, config.Difficulty)
+	}
+	if oracle == nil || (staked && time.Since(stakedAt) < stakeCacheTTL) {
+		return nil
+	}
+
+	stake, err := oracle(ctx, nodeID)
+	if err != nil {
+		return fmt.Errorf("%w: stake of %s: %v", ErrAdmissionRefused, nodeID, err)
+	}
+	if stake < config.MinStake {
+		return fmt.Errorf("%w: %s stakes %g, below %g", ErrAdmissionRefused, nodeID, stake, config.MinStake)
+	}
+	a.mu.Lock()
+	a.staked[nodeID] = time.Now()
+	a.mu.Unlock()
+	return nil
+}
+
+// admissionWork returns the number of leading zero bits in the hash of a
+// node ID, signing key and nonce. Covering the key means a proof seen on the
+// wire cannot be reused by a node holding another key.
+func admissionWork(nodeID string, signingKey []byte, nonce uint64) int {
+	h := sha256.New()
+	h.Write([]byte("hydap admission "))
+	for _, field := range [][]byte{[]byte(nodeID), signingKey} {
+		var size [4]byte
+		binary.BigEndian.PutUint32(size[:], uint32(len(field)))
+		h.Write(size[:])
+		h.Write(field)
+	}
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], nonce)
+	h.Write(n[:])
+	sum := h.Sum(nil)
+
+	zeros := 0
+	for _, b := range sum {
+		if b != 0 {
+			return zeros + bits.LeadingZeros8(b)
+		}
+		zeros += 8
+	}
+	return zeros
+}
+
+// VerifyAdmission reports whether nonce is a proof of work for nodeID and
+// signingKey at difficulty. Nodes without keys prove work for their ID
+// alone, with an empty signingKey.
+func VerifyAdmission(nodeID string, signingKey []byte, nonce uint64, difficulty int) bool {
+	return difficulty <= 0 || admissionWork(nodeID, signingKey, nonce) >= difficulty
+}
+
+// SolveAdmission finds a proof of work for nodeID and signingKey. Each
+// difficulty bit doubles the expected work: 20 takes about a million hashes.
+func SolveAdmission(nodeID string, signingKey []byte, difficulty int) uint64 {
+	var nonce uint64
+	for !VerifyAdmission(nodeID, signingKey, nonce, difficulty) {
+		nonce++
+	}
+	return nonce
+}
+
+// SetAdmission sets what peers must show to be admitted, and solves this
+// node's own proof of work at the same difficulty. It must be called before
+// UseTransport.
+func (node *P2PInfiniteVectorNode) SetAdmission(config AdmissionConfig) error {
+	admission, err := NewAdmission(config)
+	if err != nil {
+		return err
+	}
+
+	node.connMu.Lock()
+	node.admission = admission
+	node.connMu.Unlock()
+	node.solveAdmission()
+	return nil
+}
+
+// solveAdmission solves this node's proof of work for its ID and, once
+// UseKeys was called, its signing key
+func (node *P2PInfiniteVectorNode) solveAdmission() {
+	node.connMu.Lock()
+	admission, keys := node.admission, node.keys
+	node.connMu.Unlock()
+
+	var signingKey []byte
+	if keys != nil {
+		signingKey = keys.SigningKey()
+	}
+	nonce := SolveAdmission(node.NodeID, signingKey, admission.Config().Difficulty)
+
+	node.connMu.Lock()
+	defer node.connMu.Unlock()
+	node.admissionNonce = nonce
+}
+
+// Admission returns the check peers pass before joining the peer set
+func (node *P2PInfiniteVectorNode) Admission() *Admission {
+	node.connMu.Lock()
+	defer node.connMu.Unlock()
+	return node.admission
+}
+
+func (node *P2PInfiniteVectorNode) localAdmissionNonce() uint64 {
+	node.connMu.Lock()
+	defer node.connMu.Unlock()
+	return node.admissionNonce
+}
+
+// localSigningKey returns the key this node's proof of work covers, nil
+// without keys
+func (node *P2PInfiniteVectorNode) localSigningKey() []byte {
+	if keys := node.nodeKeys(); keys != nil {
+		return keys.SigningKey()
+	}
+	return nil
+}
+
+// admitPeer checks a peer before it may join the peer set. The peer ID and
+// signing key are claims until a key exchange has verified them, so a refusal
+// only rejects the message carrying them and leaves any peer already known
+// by that ID in place.
+func (node *P2PInfiniteVectorNode) admitPeer(peerID string, signingKey []byte, nonce uint64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	return node.Admission().Admit(ctx, peerID, signingKey, nonce)
+}
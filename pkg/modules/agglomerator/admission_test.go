@@ -0,0 +1,150 @@
+package agglomerator
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdmissionProofOfWork(t *testing.T) {
+	key := []byte("key-a")
+	nonce := SolveAdmission("node-a", key, 12)
+	assert.True(t, VerifyAdmission("node-a", key, nonce, 12))
+	assert.GreaterOrEqual(t, admissionWork("node-a", key, nonce), 12)
+	assert.False(t, VerifyAdmission("node-b", key, nonce, 12) && VerifyAdmission("node-c", key, nonce, 12), "a proof is bound to its node ID")
+	assert.False(t, VerifyAdmission("node-a", []byte("key-b"), nonce, 12) && VerifyAdmission("node-a", []byte("key-c"), nonce, 12),
+		"a proof is bound to its signing key")
+	assert.True(t, VerifyAdmission("node-b", nil, 0, 0), "difficulty 0 asks no work")
+
+	admission, err := NewAdmission(AdmissionConfig{Difficulty: 12})
+	require.NoError(t, err)
+	require.NoError(t, admission.Admit(context.Background(), "node-a", key, nonce))
+	assert.ErrorIs(t, admission.Admit(context.Background(), "node-a", key, failingNonce("node-a", key, 12)), ErrAdmissionRefused)
+	assert.Equal(t, AdmissionStats{Admitted: 1, Refused: 1}, admission.Stats())
+
+	_, err = NewAdmission(AdmissionConfig{Difficulty: maxAdmissionDifficulty + 1})
+	assert.Error(t, err)
+}
+
+func TestAdmissionAllowlistAndStake(t *testing.T) {
+	admission, err := NewAdmission(AdmissionConfig{Allowlist: []string{"node-a"}, Difficulty: 30})
+	require.NoError(t, err)
+	assert.NoError(t, admission.Admit(context.Background(), "node-a", nil, 0), "allowlisted nodes do no work")
+	assert.ErrorIs(t, admission.Admit(context.Background(), "node-b", nil, 0), ErrAdmissionRefused)
+
+	_, err = NewAdmission(AdmissionConfig{MinStake: 10, StakeOracle: "missing"})
+	assert.Error(t, err)
+
+	calls := 0
+	RegisterStakeOracle("test", func(ctx context.Context, nodeID string) (float64, error) {
+		calls++
+		switch nodeID {
+		case "whale":
+			return 100, nil
+		case "broken":
+			return 0, errors.New("chain unreachable")
+		}
+		return 1, nil
+	})
+	admission, err = NewAdmission(AdmissionConfig{MinStake: 10, StakeOracle: "test"})
+	require.NoError(t, err)
+	require.NoError(t, admission.Admit(context.Background(), "whale", nil, 0))
+	require.NoError(t, admission.Admit(context.Background(), "whale", nil, 0))
+	assert.Equal(t, 1, calls, "passed stake checks are cached")
+	assert.ErrorIs(t, admission.Admit(context.Background(), "minnow", nil, 0), ErrAdmissionRefused)
+	assert.ErrorIs(t, admission.Admit(context.Background(), "broken", nil, 0), ErrAdmissionRefused)
+}
+
+func TestHandshakeRefusesUnadmittedPeers(t *testing.T) {
+	newNode := func(difficulty int) *P2PInfiniteVectorNode {
+		node := NewP2PInfiniteVectorNode("127.0.0.1", 0)
+		require.NoError(t, node.SetAdmission(AdmissionConfig{Difficulty: difficulty}))
+		transport, err := NewTransport(TransportConfig{Type: "tcp"})
+		require.NoError(t, err)
+		require.NoError(t, node.UseTransport(transport))
+		t.Cleanup(func() { node.CloseTransport() })
+		return node
+	}
+	dial := func(from, to *P2PInfiniteVectorNode) error {
+		from.connectToPeer(&PeerInfo{NodeID: to.NodeID, Address: to.listener.Addr()})
+		_, err := from.peerConn(from.transport, to.NodeID)
+		return err
+	}
+	hasPeer := func(node *P2PInfiniteVectorNode, peerID string) bool {
+		node.peerMutex.RLock()
+		defer node.peerMutex.RUnlock()
+		_, exists := node.peers[peerID]
+		return exists
+	}
+
+	a, b := newNode(8), newNode(8)
+	require.NoError(t, dial(a, b))
+
+	// A node that skipped the work is refused both ways
+	free := newNode(0)
+	free.admissionNonce = failingNonce(free.NodeID, nil, 8)
+	assert.Error(t, dial(free, a))
+	assert.ErrorIs(t, dial(a, free), ErrAdmissionRefused)
+
+	// A refusal rejects the message only: a hello or discovery message in
+	// b's name without b's proof leaves b in place
+	spoofed := Handshake{NodeID: b.NodeID, Algorithms: SignatureAlgorithms, Version: WireVersion, MinVersion: MinWireVersion, AdmissionNonce: failingNonce(b.NodeID, nil, 8)}
+	_, err := a.acceptHandshake(io.Discard, spoofed)
+	assert.ErrorIs(t, err, ErrAdmissionRefused)
+	a.processPeerDiscovery(PeerDiscoveryMessage{SenderID: b.NodeID, SenderAddr: "127.0.0.1:9000", AdmissionNonce: spoofed.AdmissionNonce})
+	assert.True(t, hasPeer(a, b.NodeID), "refusals leave known peers in the peer set")
+	require.NoError(t, dial(a, b))
+
+	a.processPeerDiscovery(PeerDiscoveryMessage{SenderID: "sybil", SenderAddr: "127.0.0.1:9000", AdmissionNonce: failingNonce("sybil", nil, 8)})
+	assert.False(t, hasPeer(a, "sybil"), "discovered peers without proof are not added")
+}
+
+func TestHandshakeAdmitsVerifiedSigningKeys(t *testing.T) {
+	newNode := func(keys NodeKeys) *P2PInfiniteVectorNode {
+		node := NewP2PInfiniteVectorNode("127.0.0.1", 0)
+		require.NoError(t, node.SetAdmission(AdmissionConfig{Difficulty: 8}))
+		node.UseKeys(keys)
+		transport, err := NewTransport(TransportConfig{Type: "tcp"})
+		require.NoError(t, err)
+		require.NoError(t, node.UseTransport(transport))
+		t.Cleanup(func() { node.CloseTransport() })
+		return node
+	}
+
+	aKeys, bKeys := newTestKeys(t), newTestKeys(t)
+	a, b := newNode(aKeys), newNode(bKeys)
+	assert.True(t, VerifyAdmission(b.NodeID, bKeys.SigningKey(), b.admissionNonce, 8), "UseKeys solves the proof for the signing key")
+	_, err := dialKeyed(a, b)
+	require.NoError(t, err)
+
+	// b's proof replayed by a node holding another key is refused both ways,
+	// and the key is not pinned
+	thief := newNode(newTestKeys(t))
+	thief.NodeID = b.NodeID
+	thief.admissionNonce = b.admissionNonce
+	c, d := newNode(newTestKeys(t)), newNode(newTestKeys(t))
+	_, err = dialKeyed(c, thief)
+	assert.ErrorIs(t, err, ErrAdmissionRefused)
+	_, err = dialKeyed(thief, d)
+	require.NoError(t, err, "the dialer learns of the refusal only when it sends")
+	assert.Eventually(t, func() bool { return d.Admission().Stats().Refused == 1 }, time.Second, 10*time.Millisecond)
+	for _, node := range []*P2PInfiniteVectorNode{c, d} {
+		_, pinned := node.PeerSigningKey(b.NodeID)
+		assert.False(t, pinned, "refused keys are not pinned")
+	}
+}
+
+// failingNonce returns a nonce that is not a proof of work for nodeID and
+// signingKey
+func failingNonce(nodeID string, signingKey []byte, difficulty int) uint64 {
+	var nonce uint64
+	for VerifyAdmission(nodeID, signingKey, nonce, difficulty) {
+		nonce++
+	}
+	return nonce
+}
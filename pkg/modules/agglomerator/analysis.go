@@ -0,0 +1,25 @@
+package agglomerator
+
+import "github.com/theaxiomverse/hydap-api/pkg/vectors"
+
+// AnalyzeVectors reports which of the first dims dimensions decide the
+// similarity of transaction vectors to chain vectors in the routing index,
+// and how many leading dimensions keep similarity within tolerance. dims
+// defaults to the dimensions compared for similarity.
+func (a *Agglomerator) AnalyzeVectors(dims int, tolerance float64) vectors.DimensionAnalysis {
+	a.mu.RLock()
+	if dims <= 0 {
+		dims = a.compareDims
+	}
+	var chains, transactions []vectors.DatabaseRecord
+	for _, record := range a.vectorIndex.Records() {
+		if _, isChain := a.chains[record.ID]; isChain {
+			chains = append(chains, record)
+		} else {
+			transactions = append(transactions, record)
+		}
+	}
+	a.mu.RUnlock()
+
+	return vectors.AnalyzeDimensions(transactions, chains, dims, tolerance)
+}
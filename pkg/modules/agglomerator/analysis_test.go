@@ -0,0 +1,71 @@
+package agglomerator
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+// leadingVector varies in its first three dimensions and is flat after them
+func leadingVector(seed float64) vectors.InfiniteVector {
+	return vectors.InfiniteVector{Generator: func(dim int) float64 {
+		if dim < 3 {
+			return math.Sin(seed + float64(dim)*2)
+		}
+		return 0
+	}}
+}
+
+func TestAnalyzeDimensions(t *testing.T) {
+	var queries, references []vectors.DatabaseRecord
+	for i := 0; i < 6; i++ {
+		queries = append(queries, vectors.DatabaseRecord{Vector: leadingVector(float64(i))})
+	}
+	for i := 0; i < 3; i++ {
+		references = append(references, vectors.DatabaseRecord{Vector: leadingVector(float64(i) + 0.5)})
+	}
+	queries = append(queries, vectors.DatabaseRecord{ID: "unsampled"})
+
+	analysis := vectors.AnalyzeDimensions(queries, references, 10, 0)
+	assert.Equal(t, 9, analysis.Samples)
+	assert.Equal(t, 18, analysis.Pairs)
+	assert.Equal(t, vectors.DefaultAnalysisTolerance, analysis.Tolerance)
+	assert.ElementsMatch(t, []int{0, 1, 2}, analysis.Ranked[:3], "only the leading dimensions vary")
+
+	var importance, share float64
+	for _, stats := range analysis.Stats {
+		importance += stats.Importance
+		share += stats.VarianceShare
+		if stats.Dimension >= 3 {
+			assert.Zero(t, stats.Variance)
+		}
+	}
+	assert.InDelta(t, 1, importance, 1e-9)
+	assert.InDelta(t, 1, share, 1e-9)
+
+	require.Len(t, analysis.Prefixes, 9)
+	assert.InDelta(t, 0, analysis.Prefixes[8].MeanError, 1e-9, "comparing every dimension changes nothing")
+	assert.LessOrEqual(t, analysis.Recommended, 10)
+	for _, prefix := range analysis.Prefixes {
+		if prefix.Dimensions == analysis.Recommended {
+			assert.LessOrEqual(t, prefix.MeanError, analysis.Tolerance)
+		}
+	}
+}
+
+func TestAgglomeratorAnalyzeVectors(t *testing.T) {
+	agg := NewAgglomerator(AgglomeratorConfig{CompareDims: 8})
+
+	chain := NewChain("eth", "http://localhost:8545", ProtocolEthereum)
+	chain.StateVector = leadingVector(0)
+	require.NoError(t, agg.RegisterChain(chain))
+	require.NoError(t, agg.vectorIndex.Insert(vectors.DatabaseRecord{ID: "tx-1", Vector: leadingVector(1)}))
+
+	analysis := agg.AnalyzeVectors(0, 0)
+	assert.Equal(t, 8, analysis.Dimensions)
+	assert.Equal(t, 2, analysis.Samples)
+	assert.Equal(t, 1, analysis.Pairs, "transactions are compared with chains only")
+}
@@ -0,0 +1,297 @@
+package agglomerator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+var (
+	ErrTransactionHeld = errors.New("transaction held for anomaly review")
+	ErrAnomalyNotFound = errors.New("anomaly not found")
+	ErrAnomalyReviewed = errors.New("anomaly already reviewed")
+)
+
+// Anomaly review statuses
+const (
+	AnomalyPending  = "pending"
+	AnomalyApproved = "approved"
+	AnomalyRejected = "rejected"
+)
+
+const (
+	// anomalyMetadataKey tags flagged transactions so they can be found with
+	// the meta.anomaly:* history query
+	anomalyMetadataKey = "anomaly"
+
+	// anomalyAlertTimeout bounds delivery of an alert to the webhook
+	anomalyAlertTimeout = 5 * time.Second
+)
+
+// AnomalyConfig controls detection of transactions whose state vectors are
+// unlike any learned cluster
+type AnomalyConfig struct {
+	Clusters     int     // Maximum number of clusters learned
+	Threshold    float64 // Minimum similarity to the nearest cluster
+	MinSamples   int     // Transactions learned before any are flagged
+	Dimensions   int     // Vector dimensions compared
+	Hold         bool    // Hold flagged transactions from routing until approved
+	AlertWebhook string  // URL that receives each anomaly as JSON
+}
+
+func DefaultAnomalyConfig() AnomalyConfig {
+	return AnomalyConfig{
+		Clusters:   8,
+		Threshold:  0.5,
+		MinSamples: 50,
+		Dimensions: 50,
+	}
+}
+
+// AnomalyDetector flags vectors unlike any cluster learned from earlier
+// transactions
+type AnomalyDetector struct {
+	config   AnomalyConfig
+	clusters *vectors.Clusterer
+	learned  int
+	mu       sync.RWMutex
+}
+
+// AnomalyDetectorStats summarizes what the detector has learned
+type AnomalyDetectorStats struct {
+	Clusters  int     `json:"clusters"`
+	Learned   int     `json:"learned"`
+	WarmedUp  bool    `json:"warmedUp"` // Learned at least MinSamples
+	Threshold float64 `json:"threshold"`
+}
+
+func NewAnomalyDetector(config AnomalyConfig) *AnomalyDetector {
+	return &AnomalyDetector{
+		config: config,
+		clusters: vectors.NewClusterer(vectors.ClusterConfig{
+			MaxClusters: config.Clusters,
+			Threshold:   config.Threshold,
+			Dimensions:  config.Dimensions,
+		}),
+	}
+}
+
+// Check scores a vector against the learned clusters. It reports false until
+// the detector has warmed up, and for transactions without a state vector.
+func (d *AnomalyDetector) Check(vector *vectors.InfiniteVector) (anomalous bool, cluster int, similarity float64) {
+	if vector.Generator == nil {
+		return false, -1, 0
+	}
+
+	d.mu.RLock()
+	warmedUp := d.learned >= d.config.MinSamples
+	d.mu.RUnlock()
+
+	cluster, similarity, ok := d.clusters.Nearest(vector)
+	if !warmedUp {
+		return false, cluster, similarity
+	}
+	return !ok || similarity < d.config.Threshold, cluster, similarity
+}
+
+// Learn adds a vector to its nearest cluster
+func (d *AnomalyDetector) Learn(vector *vectors.InfiniteVector) {
+	if _, _, ok := d.clusters.Add("", vector); ok {
+		d.mu.Lock()
+		d.learned++
+		d.mu.Unlock()
+	}
+}
+
+// Train replaces the learned clusters with ones built from records, such as
+// the transaction vectors in the routing index
+func (d *AnomalyDetector) Train(records []vectors.DatabaseRecord) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.clusters.Reset()
+	d.learned = 0
+	for i := range records {
+		if _, _, ok := d.clusters.Add("", &records[i].Vector); ok {
+			d.learned++
+		}
+	}
+}
+
+// Stats reports the detector's state
+func (d *AnomalyDetector) Stats() AnomalyDetectorStats {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return AnomalyDetectorStats{
+		Clusters:  len(d.clusters.Clusters()),
+		Learned:   d.learned,
+		WarmedUp:  d.learned >= d.config.MinSamples,
+		Threshold: d.config.Threshold,
+	}
+}
+
+// Anomaly is a transaction flagged for manual review
+type Anomaly struct {
+	TxID       string    `json:"txId"`
+	FromChain  string    `json:"fromChain"`
+	ToChain    string    `json:"toChain"`
+	Cluster    int       `json:"cluster"`    // Nearest cluster, -1 if none
+	Similarity float64   `json:"similarity"` // Similarity to the nearest cluster
+	Held       bool      `json:"held"`       // Not routed until approved
+	Status     string    `json:"status"`
+	DetectedAt time.Time `json:"detectedAt"`
+	ReviewedAt time.Time `json:"reviewedAt,omitempty"`
+}
+
+type anomalyEntry struct {
+	anomaly Anomaly
+	tx      *Transaction // Set while the transaction is held
+}
+
+// AnomalyQueue holds flagged transactions awaiting review
+type AnomalyQueue struct {
+	entries map[string]*anomalyEntry
+	mu      sync.RWMutex
+}
+
+func NewAnomalyQueue() *AnomalyQueue {
+	return &AnomalyQueue{entries: make(map[string]*anomalyEntry)}
+}
+
+// Add queues an anomaly, with the transaction when it is held
+func (q *AnomalyQueue) Add(anomaly Anomaly, tx *Transaction) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entry := &anomalyEntry{anomaly: anomaly}
+	if anomaly.Held {
+		entry.tx = tx
+	}
+	q.entries[anomaly.TxID] = entry
+}
+
+// Get returns the anomaly recorded for a transaction
+func (q *AnomalyQueue) Get(txID string) (Anomaly, bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	entry, exists := q.entries[txID]
+	if !exists {
+		return Anomaly{}, false
+	}
+	return entry.anomaly, true
+}
+
+// List returns anomalies with the given status, or all when status is
+// empty, oldest first
+func (q *AnomalyQueue) List(status string) []Anomaly {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	anomalies := make([]Anomaly, 0, len(q.entries))
+	for _, entry := range q.entries {
+		if status == "" || entry.anomaly.Status == status {
+			anomalies = append(anomalies, entry.anomaly)
+		}
+	}
+	sort.Slice(anomalies, func(i, j int) bool {
+		if !anomalies[i].DetectedAt.Equal(anomalies[j].DetectedAt) {
+			return anomalies[i].DetectedAt.Before(anomalies[j].DetectedAt)
+		}
+		return anomalies[i].TxID < anomalies[j].TxID
+	})
+	return anomalies
+}
+
+// Review records a decision on a pending anomaly and returns the held
+// transaction, if any
+func (q *AnomalyQueue) Review(txID, status string) (Anomaly, *Transaction, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entry, exists := q.entries[txID]
+	if !exists {
+		return Anomaly{}, nil, ErrAnomalyNotFound
+	}
+	if entry.anomaly.Status != AnomalyPending {
+		return entry.anomaly, nil, ErrAnomalyReviewed
+	}
+
+	entry.anomaly.Status = status
+	entry.anomaly.ReviewedAt = time.Now()
+	tx := entry.tx
+	entry.tx = nil
+	return entry.anomaly, tx, nil
+}
+
+// Pending reports whether a transaction awaits review
+func (q *AnomalyQueue) Pending(txID string) bool {
+	anomaly, exists := q.Get(txID)
+	return exists && anomaly.Status == AnomalyPending
+}
+
+// Collect removes reviewed anomalies detected before cutoff. Pending ones
+// are kept until reviewed.
+func (q *AnomalyQueue) Collect(cutoff time.Time) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	removed := 0
+	for id, entry := range q.entries {
+		if entry.anomaly.Status != AnomalyPending && entry.anomaly.DetectedAt.Before(cutoff) {
+			delete(q.entries, id)
+			removed++
+		}
+	}
+	return removed
+}
+
+// TransactionRecords returns the transaction vectors in the routing index,
+// leaving out chain registrations
+func (a *Agglomerator) TransactionRecords() []vectors.DatabaseRecord {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var records []vectors.DatabaseRecord
+	for _, record := range a.vectorIndex.Records() {
+		if _, isChain := a.chains[record.ID]; !isChain {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// sendAnomalyAlert posts an anomaly to the alert webhook
+func sendAnomalyAlert(webhook string, anomaly Anomaly) error {
+	body, err := json.Marshal(anomaly)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), anomalyAlertTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("alert webhook returned %s", resp.Status)
+	}
+	return nil
+}
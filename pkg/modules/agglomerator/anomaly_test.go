@@ -0,0 +1,91 @@
+package agglomerator
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+// waveVector is a sine wave with a small per-sample phase shift
+func waveVector(sign, shift float64) *vectors.InfiniteVector {
+	return &vectors.InfiniteVector{Generator: func(dim int) float64 {
+		return sign * math.Sin(float64(dim)/3+shift)
+	}}
+}
+
+func TestAnomalyDetector(t *testing.T) {
+	config := DefaultAnomalyConfig()
+	config.MinSamples = 10
+	detector := NewAnomalyDetector(config)
+
+	outlier := waveVector(-1, 0)
+	anomalous, _, _ := detector.Check(outlier)
+	assert.False(t, anomalous, "nothing is flagged before warm-up")
+
+	for i := 0; i < 10; i++ {
+		detector.Learn(waveVector(1, float64(i)*0.01))
+	}
+	stats := detector.Stats()
+	assert.True(t, stats.WarmedUp)
+	assert.Equal(t, 1, stats.Clusters)
+
+	anomalous, cluster, similarity := detector.Check(waveVector(1, 0.05))
+	assert.False(t, anomalous)
+	assert.Equal(t, 0, cluster)
+	assert.Greater(t, similarity, 0.99)
+
+	anomalous, _, similarity = detector.Check(outlier)
+	assert.True(t, anomalous)
+	assert.Less(t, similarity, config.Threshold)
+
+	anomalous, _, _ = detector.Check(&vectors.InfiniteVector{})
+	assert.False(t, anomalous, "transactions without a state vector are not screened")
+}
+
+func TestAnomalyDetectorTrain(t *testing.T) {
+	config := DefaultAnomalyConfig()
+	config.MinSamples = 2
+	detector := NewAnomalyDetector(config)
+
+	detector.Train([]vectors.DatabaseRecord{
+		{ID: "a", Vector: *waveVector(1, 0)},
+		{ID: "b", Vector: *waveVector(-1, 0)},
+		{ID: "c", Vector: *waveVector(1, 0.02)},
+	})
+	stats := detector.Stats()
+	assert.Equal(t, 3, stats.Learned)
+	assert.Equal(t, 2, stats.Clusters, "opposite waves form separate clusters")
+
+	anomalous, _, _ := detector.Check(waveVector(-1, 0.01))
+	assert.False(t, anomalous)
+}
+
+func TestAnomalyQueueReview(t *testing.T) {
+	queue := NewAnomalyQueue()
+	held := &Transaction{ID: "held"}
+	queue.Add(Anomaly{TxID: "held", Held: true, Status: AnomalyPending, DetectedAt: time.Now()}, held)
+	queue.Add(Anomaly{TxID: "flagged", Status: AnomalyPending, DetectedAt: time.Now()}, &Transaction{ID: "flagged"})
+
+	anomaly, tx, err := queue.Review("held", AnomalyApproved)
+	require.NoError(t, err)
+	assert.Equal(t, AnomalyApproved, anomaly.Status)
+	assert.Same(t, held, tx)
+
+	_, _, err = queue.Review("held", AnomalyRejected)
+	assert.ErrorIs(t, err, ErrAnomalyReviewed)
+	_, _, err = queue.Review("missing", AnomalyRejected)
+	assert.ErrorIs(t, err, ErrAnomalyNotFound)
+
+	_, tx, err = queue.Review("flagged", AnomalyRejected)
+	require.NoError(t, err)
+	assert.Nil(t, tx, "only held transactions are kept")
+
+	queue.Add(Anomaly{TxID: "pending", Status: AnomalyPending, DetectedAt: time.Now()}, nil)
+	assert.Len(t, queue.List(AnomalyPending), 1)
+	assert.Equal(t, 2, queue.Collect(time.Now().Add(time.Second)), "pending anomalies are kept until reviewed")
+	assert.True(t, queue.Pending("pending"))
+}
@@ -1,10 +1,21 @@
 package agglomerator
 
 import (
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/theaxiomverse/hydap-api/pkg/modules/base"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+	"io"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type API struct {
@@ -17,18 +28,136 @@ func NewAPI(module *AgglomeratorModule) *API {
 
 func (api *API) Routes() chi.Router {
 	r := chi.NewRouter()
+	r.Use(api.readOnly)
 
-	r.Post("/transaction", api.ProcessTransaction)
-	r.Get("/chains", api.ListChains)
+	r.With(api.relayToRouter).Post("/transaction", api.ProcessTransaction)
+	r.With(api.relayToRouter).Post("/transaction/stream", api.StreamTransaction)
+	r.With(api.relayToRouter).Post("/transactions/batch", api.ProcessTransactionBatch)
+	r.Get("/transactions", api.QueryTransactions)
+	r.Get("/transactions/{id}/route", api.GetTransactionRoute)
+	r.Get("/transactions/{id}/receipt", api.GetTransactionReceipt)
+	r.Post("/transactions/{id}/reroute", api.RerouteTransaction)
+	r.Get("/transactions/{id}/reroutes", api.ListReroutes)
+	r.Post("/blobs", api.PutBlob)
+	r.Get("/blobs/{hash}", api.GetBlob)
+	r.With(core.ETag).Get("/chains", api.ListChains)
 	r.Post("/chains", api.RegisterChain)
+	r.Get("/chain-registry", api.GetChainRegistry)
+	r.Post("/chain-registry/import", api.ImportChains)
 	r.Get("/chains/{id}", api.GetChain)
+	r.Get("/chains/{id}/pool", api.GetChainPool)
+	r.Put("/chains/{id}/maintenance", api.SetChainMaintenance)
+	r.Put("/chains/{id}/vector", api.ImportChainVector)
+	r.Put("/chains/{id}/canary", api.SetChainCanary)
+	r.Delete("/chains/{id}/maintenance", api.ClearChainMaintenance)
+	r.Get("/chains/{id}/transactions/{txID}", api.GetChainTransaction)
+	r.Get("/topology", api.GetTopology)
+	r.Get("/prerouting", api.GetPreRouting)
+	r.Get("/experiment", api.GetExperiment)
+	r.Put("/experiment", api.StartExperiment)
+	r.Delete("/experiment", api.StopExperiment)
+	r.Get("/clusters", api.ListClusters)
+	r.Post("/clusters/refit", api.RefitClusters)
+	r.Get("/vectors/analysis", api.GetVectorAnalysis)
+	r.Get("/vectors/export", api.ExportVectors)
+	r.Get("/clusters/{id}", api.GetCluster)
+	r.Get("/anomalies", api.ListAnomalies)
+	r.Post("/anomalies/retrain", api.RetrainAnomalies)
+	r.Post("/anomalies/{id}/approve", api.ApproveAnomaly)
+	r.Post("/anomalies/{id}/reject", api.RejectAnomaly)
+	r.Get("/assets", api.ListAssets)
+	r.Post("/assets", api.RegisterAsset)
+	r.Delete("/assets/{chain}/{symbol}", api.RemoveAsset)
+	r.Get("/assets/{chain}/{symbol}/value", api.GetAssetValue)
+	r.Get("/events", api.ListEvents)
+	r.Get("/events/stream", api.StreamEvents)
+	r.Get("/events/state", api.GetEventState)
+	r.Get("/events/replay", api.ReplayEvents)
+	r.Get("/reconciliation/reports", api.ListReconciliationReports)
+	r.Get("/reconciliation/reports/{date}", api.GetReconciliationReport)
+	r.Post("/reconciliation/run", api.RunReconciliation)
+	r.Get("/sponsors", api.GetSponsors)
+	r.Get("/policy", api.GetPolicy)
+	r.Put("/policy", api.UpdatePolicy)
+	r.Post("/policy/reload", api.ReloadPolicy)
+	r.Post("/policy/evaluate", api.EvaluatePolicy)
+	r.Get("/policy/decisions", api.ListPolicyDecisions)
+	r.Get("/metrics/history", api.GetMetricsHistory)
 	r.Get("/status", api.GetStatus)
 	r.Post("/pause", api.PauseModule)
 	r.Post("/resume", api.ResumeModule)
+	r.Get("/gc", api.GetGCStats)
+	r.Post("/gc", api.TriggerGC)
 
 	return r
 }
 
+// readOnly refuses requests that change state on a replica
+func (api *API) readOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if api.module.GetReplica() != nil {
+				respondError(w, http.StatusForbidden, ErrReadOnlyReplica.Error())
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// relayedByHeader names the gateway that relayed a transaction submission
+const relayedByHeader = "X-Relayed-By"
+
+// relayToRouter hands transaction submissions to a router peer when this
+// node does not route them. Submissions from clients, rather than relayed
+// by a gateway, need the gateway role.
+func (api *API) relayToRouter(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		roles := api.module.GetRoles()
+		relayed := r.Header.Get(relayedByHeader) != ""
+		switch {
+		case !relayed && !roles.Has(RoleGateway):
+			respondError(w, http.StatusMisdirectedRequest, fmt.Sprintf("%v: %s", ErrRoleNotServed, RoleGateway))
+			return
+		case roles.Has(RoleRouter):
+			next.ServeHTTP(w, r)
+			return
+		case relayed:
+			// Never relay twice
+			respondError(w, http.StatusMisdirectedRequest, fmt.Sprintf("%v: %s", ErrRoleNotServed, RoleRouter))
+			return
+		}
+
+		p2p := api.module.GetP2P()
+		routers := api.module.RouterURLs()
+		if p2p == nil || len(routers) == 0 {
+			respondError(w, http.StatusServiceUnavailable, ErrNoRouter.Error())
+			return
+		}
+		target, err := url.Parse(routers[0])
+		if err != nil {
+			respondError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		proxy := httputil.NewSingleHostReverseProxy(target)
+		proxy.FlushInterval = -1 // Stream submissions answer as they go
+		director := proxy.Director
+		proxy.Director = func(req *http.Request) {
+			director(req)
+			req.Host = target.Host
+			// This node compresses the response for the client
+			req.Header.Del("Accept-Encoding")
+			req.Header.Set(relayedByHeader, p2p.p2pNode.NodeID)
+		}
+		proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			respondError(w, http.StatusBadGateway, fmt.Sprintf("relay to %s failed: %v", target.Host, err))
+		}
+		proxy.ServeHTTP(w, r)
+	})
+}
+
 // respondJSON is a helper function to send JSON responses
 func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -53,21 +182,78 @@ func (api *API) ListChains(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if core.IsWatch(r) {
+		api.watchChains(w, r, agg)
+		return
+	}
+
+	// Ordered by ID so the list, and its ETag, only change with the chains
 	chains := agg.ListChains()
-	// Convert chains to a response format
+	sort.Slice(chains, func(i, j int) bool { return chains[i].ID < chains[j].ID })
 	response := make([]map[string]interface{}, 0)
 	for _, chain := range chains {
-		chainData := map[string]interface{}{
-			"id":       chain.ID,
-			"endpoint": chain.Endpoint,
-			"protocol": chain.Protocol,
-		}
-		response = append(response, chainData)
+		response = append(response, chainSummary(agg, chain))
 	}
 
 	respondJSON(w, http.StatusOK, response)
 }
 
+// chainSummary is a chain as GET /chains lists it
+func chainSummary(agg *Agglomerator, chain *Chain) map[string]interface{} {
+	summary := map[string]interface{}{
+		"id":        chain.ID,
+		"endpoint":  chain.Endpoint,
+		"endpoints": chain.AllEndpoints(),
+		"protocol":  chain.Protocol,
+	}
+	if chain.Zone != "" {
+		summary["zone"] = chain.Zone
+	}
+	if !chain.Capabilities.IsZero() {
+		summary["capabilities"] = chain.Capabilities
+	}
+	if maintenance := chain.Maintenance(); maintenance != nil {
+		summary["maintenance"] = maintenance
+	}
+	if canary := chain.Canary(); canary != nil {
+		summary["canary"] = canary
+	}
+	if cluster, ok := agg.ClusterOf(chain.ID); ok {
+		summary["cluster"] = cluster
+	}
+	return summary
+}
+
+// watchChains streams changes to the chain list as JSON lines. Chain
+// events wake the watch so registrations show at once.
+func (api *API) watchChains(w http.ResponseWriter, r *http.Request, agg *Agglomerator) {
+	var changed chan struct{}
+	if log := agg.EventLog(); log != nil {
+		events, cancel := log.Subscribe(64)
+		defer cancel()
+		changed = make(chan struct{}, 1)
+		go func() {
+			for event := range events {
+				if !strings.HasPrefix(event.Type, "chain.") {
+					continue
+				}
+				select {
+				case changed <- struct{}{}:
+				default: // A wake-up is already pending
+				}
+			}
+		}()
+	}
+
+	core.ServeWatch(w, r, func() (map[string]interface{}, error) {
+		chains := make(map[string]interface{})
+		for _, chain := range agg.ListChains() {
+			chains[chain.ID] = chainSummary(agg, chain)
+		}
+		return chains, nil
+	}, changed)
+}
+
 func (api *API) GetChain(w http.ResponseWriter, r *http.Request) {
 	chainID := chi.URLParam(r, "id")
 	agg := api.module.GetAgglomerator()
@@ -82,15 +268,189 @@ func (api *API) GetChain(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	archiveBlocks, archivedRecords := chain.ArchiveStats()
 	response := map[string]interface{}{
-		"id":       chain.ID,
-		"endpoint": chain.Endpoint,
-		"protocol": chain.Protocol,
+		"id":              chain.ID,
+		"endpoint":        chain.Endpoint,
+		"protocol":        chain.Protocol,
+		"archiveBlocks":   archiveBlocks,
+		"archivedRecords": archivedRecords,
+	}
+	if chain.Zone != "" {
+		response["zone"] = chain.Zone
+	}
+	if !chain.Capabilities.IsZero() {
+		response["capabilities"] = chain.Capabilities
+	}
+	if maintenance := chain.Maintenance(); maintenance != nil {
+		response["maintenance"] = maintenance
+	}
+	if canary := chain.Canary(); canary != nil {
+		response["canary"] = canary
+	}
+	if cluster, ok := agg.ClusterOf(chain.ID); ok {
+		response["cluster"] = cluster
+	}
+	if stats, ok := chain.PoolStats(); ok {
+		response["pool"] = stats
+	}
+	if adapter := chain.Adapter(); adapter != nil {
+		response["blockHeight"] = adapter.BlockHeight()
+	}
+	if pool := chain.EndpointPool(); pool != nil {
+		response["activeEndpoint"] = pool.Select()
+		response["endpoints"] = pool.Status()
+		response["health"] = chain.Health()
 	}
 
 	respondJSON(w, http.StatusOK, response)
 }
 
+// GetChainPool returns a chain's pool occupancy and the transactions it
+// holds, highest priority and fee first. limit caps the transactions listed.
+// SetChainMaintenance takes a chain out of routing for a window, draining
+// or holding the transactions routed to it once the window begins
+func (api *API) SetChainMaintenance(w http.ResponseWriter, r *http.Request) {
+	var maintenance ChainMaintenance
+	if err := json.NewDecoder(r.Body).Decode(&maintenance); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if api.module.GetAgglomerator() == nil {
+		respondError(w, http.StatusServiceUnavailable, "agglomerator not initialized")
+		return
+	}
+
+	chainID := chi.URLParam(r, "id")
+	maintenance, result, err := api.module.SetChainMaintenance(chainID, maintenance)
+	switch {
+	case errors.Is(err, ErrChainNotFound):
+		respondError(w, http.StatusNotFound, "chain not found")
+	case errors.Is(err, ErrInvalidMaintenance):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+	default:
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"chainId":     chainID,
+			"maintenance": maintenance,
+			"drain":       result,
+		})
+	}
+}
+
+// SetChainCanary promotes or quarantines a chain, or warms it up again
+func (api *API) SetChainCanary(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		State  string `json:"state"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if api.module.GetAgglomerator() == nil {
+		respondError(w, http.StatusServiceUnavailable, "agglomerator not initialized")
+		return
+	}
+
+	chainID := chi.URLParam(r, "id")
+	canary, err := api.module.SetChainCanary(chainID, request.State, request.Reason)
+	switch {
+	case errors.Is(err, ErrChainNotFound):
+		respondError(w, http.StatusNotFound, "chain not found")
+	case errors.Is(err, ErrInvalidCanary):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+	default:
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"chainId": chainID,
+			"canary":  canary,
+		})
+	}
+}
+
+// ClearChainMaintenance returns a chain to routing
+func (api *API) ClearChainMaintenance(w http.ResponseWriter, r *http.Request) {
+	if api.module.GetAgglomerator() == nil {
+		respondError(w, http.StatusServiceUnavailable, "agglomerator not initialized")
+		return
+	}
+
+	chainID := chi.URLParam(r, "id")
+	switch err := api.module.ClearChainMaintenance(chainID); {
+	case errors.Is(err, ErrChainNotFound):
+		respondError(w, http.StatusNotFound, "chain not found")
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+	default:
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"chainId": chainID,
+			"status":  "active",
+		})
+	}
+}
+
+func (api *API) GetChainPool(w http.ResponseWriter, r *http.Request) {
+	agg := api.module.GetAgglomerator()
+	if agg == nil {
+		respondError(w, http.StatusServiceUnavailable, "agglomerator not initialized")
+		return
+	}
+
+	chain, err := agg.GetChain(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "chain not found")
+		return
+	}
+
+	limit := 100
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			respondError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = parsed
+	}
+
+	stats, _ := chain.PoolStats()
+	entries := chain.PoolEntries()
+	if entries == nil {
+		entries = []PoolEntry{}
+	}
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"chainId":      chain.ID,
+		"stats":        stats,
+		"transactions": entries,
+	})
+}
+
+// GetChainTransaction looks up a transaction in a chain's pool, including
+// records that have been compacted into archives
+func (api *API) GetChainTransaction(w http.ResponseWriter, r *http.Request) {
+	agg := api.module.GetAgglomerator()
+	if agg == nil {
+		respondError(w, http.StatusServiceUnavailable, "agglomerator not initialized")
+		return
+	}
+
+	record, err := agg.LookupTransaction(chi.URLParam(r, "id"), chi.URLParam(r, "txID"))
+	if err != nil {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"id":       record.ID,
+		"metadata": record.Metadata,
+	})
+}
+
 func (api *API) GetStatus(w http.ResponseWriter, r *http.Request) {
 	status := map[string]interface{}{
 		"state":   api.module.GetState().String(),
@@ -98,18 +458,195 @@ func (api *API) GetStatus(w http.ResponseWriter, r *http.Request) {
 		"version": api.module.Version(),
 		"config":  api.module.GetConfig(),
 	}
+	if agg := api.module.GetAgglomerator(); agg != nil {
+		status["elementCache"] = agg.ElementCacheStats()
+		status["queryCache"] = agg.QueryCacheStats()
+		if tiers, ok := agg.TierStats(); ok {
+			status["vectorTiers"] = tiers
+		}
+		if log := agg.EventLog(); log != nil {
+			status["events"] = log.Stats()
+		}
+		status["routePlanning"] = agg.PlanningStats()
+	}
+	if replica := api.module.GetReplica(); replica != nil {
+		status["replica"] = replica.Status()
+	}
+	if shedder := api.module.GetLoadShedder(); shedder != nil {
+		status["overload"] = shedder.Stats()
+	}
 
 	respondJSON(w, http.StatusOK, status)
 }
 
 func (api *API) ProcessTransaction(w http.ResponseWriter, r *http.Request) {
+	limits := api.module.GetPayloadLimits()
+
+	// Data is base64 in JSON; leave room for the encoding and other fields
+	r.Body = http.MaxBytesReader(w, r.Body, limits.InlineSize*4/3+jsonEnvelopeSize)
+
 	var tx Transaction
 	if err := json.NewDecoder(r.Body).Decode(&tx); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondInlineTooLarge(w, limits)
+			return
+		}
 		respondError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
+	if int64(len(tx.Data)) > limits.InlineSize {
+		respondInlineTooLarge(w, limits)
+		return
+	}
+
+	api.processTransaction(w, &tx)
+}
+
+// StreamTransaction accepts transaction data as a raw request body for
+// payloads above the inline limit. Routing fields are passed as query
+// parameters: id (generated when omitted), fromChain, toChain, similarity, fee, priority,
+// dimensions, asset and amount.
+func (api *API) StreamTransaction(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	tx := Transaction{
+		ID:        query.Get("id"),
+		FromChain: query.Get("fromChain"),
+		ToChain:   query.Get("toChain"),
+		Asset:     query.Get("asset"),
+		Amount:    query.Get("amount"),
+	}
+	if tx.FromChain == "" || tx.ToChain == "" {
+		respondError(w, http.StatusBadRequest, "fromChain and toChain are required")
+		return
+	}
+	if value := query.Get("similarity"); value != "" {
+		similarity, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid similarity")
+			return
+		}
+		tx.Similarity = similarity
+	}
+	if value := query.Get("fee"); value != "" {
+		fee, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid fee")
+			return
+		}
+		tx.Fee = fee
+	}
+	if value := query.Get("priority"); value != "" {
+		priority, err := strconv.Atoi(value)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid priority")
+			return
+		}
+		tx.Priority = priority
+	}
+	if value := query.Get("dimensions"); value != "" {
+		dims, err := strconv.Atoi(value)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid dimensions")
+			return
+		}
+		tx.Dimensions = dims
+	}
+
+	limit := api.module.GetPayloadLimits().MaxSize
+	if r.ContentLength > limit {
+		respondError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("payload exceeds %d bytes", limit))
+		return
+	}
+
+	// With a blob store the body is streamed to disk instead of memory
+	if api.module.GetBlobs() != nil {
+		info, err := api.module.PutBlob(r.Body)
+		if err != nil {
+			respondBlobError(w, err)
+			return
+		}
+		tx.BlobRef = info.Hash
+		api.processTransaction(w, &tx)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("payload exceeds %d bytes", limit))
+			return
+		}
+		respondError(w, http.StatusBadRequest, "failed to read payload")
+		return
+	}
+	tx.Data = data
+
+	api.processTransaction(w, &tx)
+}
+
+// maxCompareDims bounds the vector dimensions a request may ask to compare
+const maxCompareDims = 4096
 
-	if err := api.module.ProcessTransaction(&tx); err != nil {
+func (api *API) processTransaction(w http.ResponseWriter, tx *Transaction) {
+	if tx.Dimensions < 0 || tx.Dimensions > maxCompareDims {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("dimensions must be between 0 and %d", maxCompareDims))
+		return
+	}
+	if err := api.module.ProcessTransaction(tx); err != nil {
+		if errors.Is(err, ErrTransactionHeld) {
+			anomaly, _ := api.module.GetAnomalies().Get(tx.ID)
+			respondJSON(w, http.StatusAccepted, map[string]interface{}{
+				"id":      tx.ID,
+				"status":  TxStatusHeld,
+				"anomaly": anomaly,
+			})
+			return
+		}
+		if errors.Is(err, ErrPayloadTooLarge) {
+			respondError(w, http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+		if errors.Is(err, ErrBlobNotFound) {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, ErrPolicyDenied) || errors.Is(err, ErrReadOnlyReplica) || errors.Is(err, ErrZoneMismatch) {
+			respondError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		if errors.Is(err, ErrRoleNotServed) {
+			respondError(w, http.StatusMisdirectedRequest, err.Error())
+			return
+		}
+		if errors.Is(err, ErrChainIncapable) || errors.Is(err, ErrFeeBudgetExceeded) || errors.Is(err, ErrSimulationFailed) ||
+			errors.Is(err, ErrSponsorBudgetExceeded) {
+			respondError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		if errors.Is(err, ErrUnknownAsset) || errors.Is(err, ErrInvalidAmount) || errors.Is(err, ErrInvalidFeeBudget) {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, ErrNoPrice) {
+			respondError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		if errors.Is(err, ErrOverloaded) {
+			// Retry once the next check may have cleared the overload
+			retry := time.Second
+			if shedder := api.module.GetLoadShedder(); shedder != nil {
+				retry = max(retry, shedder.Config().CheckInterval)
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(int(retry.Round(time.Second)/time.Second)))
+			respondError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		if errors.Is(err, ErrPoolFull) {
+			respondError(w, http.StatusTooManyRequests, err.Error())
+			return
+		}
 		respondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -117,10 +654,85 @@ func (api *API) ProcessTransaction(w http.ResponseWriter, r *http.Request) {
 	response := map[string]interface{}{
 		"id":     tx.ID,
 		"status": "accepted",
+		"route":  tx.Route,
+	}
+	if queue := api.module.GetAnomalies(); queue != nil {
+		if anomaly, flagged := queue.Get(tx.ID); flagged {
+			response["anomaly"] = anomaly
+		}
 	}
 	respondJSON(w, http.StatusAccepted, response)
 }
 
+// ProcessTransactionBatch accepts a JSON array of transactions and routes
+// them together, so transactions between the same chains share their route
+// planning. Results are returned in request order.
+func (api *API) ProcessTransactionBatch(w http.ResponseWriter, r *http.Request) {
+	limits := api.module.GetPayloadLimits()
+	maxBatch := api.module.MaxBatchSize()
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBatch)*(limits.InlineSize*4/3+jsonEnvelopeSize))
+
+	var txs []*Transaction
+	if err := json.NewDecoder(r.Body).Decode(&txs); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondError(w, http.StatusRequestEntityTooLarge, "batch too large")
+			return
+		}
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(txs) == 0 {
+		respondError(w, http.StatusBadRequest, "no transactions")
+		return
+	}
+	if len(txs) > maxBatch {
+		respondError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("batch exceeds %d transactions", maxBatch))
+		return
+	}
+	for i, tx := range txs {
+		if tx == nil {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("transaction %d is null", i))
+			return
+		}
+		if int64(len(tx.Data)) > limits.InlineSize {
+			respondInlineTooLarge(w, limits)
+			return
+		}
+		if tx.Dimensions < 0 || tx.Dimensions > maxCompareDims {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("dimensions must be between 0 and %d", maxCompareDims))
+			return
+		}
+	}
+
+	errs := api.module.ProcessTransactions(txs)
+	anomalies := api.module.GetAnomalies()
+	results := make([]map[string]interface{}, len(txs))
+	for i, tx := range txs {
+		result := map[string]interface{}{"id": tx.ID}
+		switch err := errs[i]; {
+		case err == nil:
+			result["status"] = "accepted"
+			result["route"] = tx.Route
+		case errors.Is(err, ErrTransactionHeld):
+			result["status"] = TxStatusHeld
+		case errors.Is(err, ErrOverloaded):
+			result["status"] = "shed"
+			result["error"] = err.Error()
+		default:
+			result["status"] = TxStatusFailed
+			result["error"] = err.Error()
+		}
+		if anomalies != nil {
+			if anomaly, flagged := anomalies.Get(tx.ID); flagged {
+				result["anomaly"] = anomaly
+			}
+		}
+		results[i] = result
+	}
+	respondJSON(w, http.StatusOK, results)
+}
+
 func (api *API) RegisterChain(w http.ResponseWriter, r *http.Request) {
 	var chain Chain
 	if err := json.NewDecoder(r.Body).Decode(&chain); err != nil {
@@ -134,7 +746,20 @@ func (api *API) RegisterChain(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := agg.RegisterChain(&chain); err != nil {
+	if err := api.module.RegisterChain(&chain); err != nil {
+		if errors.Is(err, ErrInvalidCapabilities) || errors.Is(err, ErrUnknownZone) {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, ErrConsistencyNotMet) {
+			// Registering again retries the replication
+			if p2p := api.module.GetP2P(); p2p != nil {
+				retry := p2p.p2pNode.WriteConfig().Timeout
+				w.Header().Set("Retry-After", strconv.Itoa(max(1, int(retry.Round(time.Second)/time.Second))))
+			}
+			respondError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
 		respondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -147,22 +772,1138 @@ func (api *API) RegisterChain(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusCreated, response)
 }
 
-func (api *API) PauseModule(w http.ResponseWriter, r *http.Request) {
-	if api.module.GetState() != base.StateRunning {
-		respondError(w, http.StatusBadRequest, "module not running")
+// GetPreRouting returns the traffic predicted per chain pair, the fees
+// estimated ahead of it and how often routes were warm when bursts began
+func (api *API) GetPreRouting(w http.ResponseWriter, r *http.Request) {
+	agg := api.module.GetAgglomerator()
+	if agg == nil {
+		respondError(w, http.StatusServiceUnavailable, "agglomerator not initialized")
+		return
+	}
+	router := agg.PreRouter()
+	if router == nil {
+		respondError(w, http.StatusServiceUnavailable, "pre-routing not enabled")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"stats":       router.Stats(),
+		"predictions": router.Predictions(),
+		"fees":        router.FeeQuotes(),
+	})
+}
+
+// GetTransactionRoute returns the routing explanation recorded when a
+// transaction was accepted
+func (api *API) GetTransactionRoute(w http.ResponseWriter, r *http.Request) {
+	store := api.module.GetTransactionStore()
+	if store == nil {
+		respondError(w, http.StatusServiceUnavailable, "transaction history not configured")
 		return
 	}
 
-	api.module.SetState(base.StatePaused)
-	respondJSON(w, http.StatusOK, map[string]string{"status": "paused"})
+	route, err := store.Route(chi.URLParam(r, "id"))
+	if errors.Is(err, ErrTransactionNotFound) {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, route)
 }
 
-func (api *API) ResumeModule(w http.ResponseWriter, r *http.Request) {
-	if api.module.GetState() != base.StatePaused {
-		respondError(w, http.StatusBadRequest, "module not paused")
+// GetTransactionReceipt returns the receipt bundle assembled when a
+// transaction completed
+func (api *API) GetTransactionReceipt(w http.ResponseWriter, r *http.Request) {
+	store := api.module.GetTransactionStore()
+	if store == nil {
+		respondError(w, http.StatusServiceUnavailable, "transaction history not configured")
 		return
 	}
 
-	api.module.SetState(base.StateRunning)
-	respondJSON(w, http.StatusOK, map[string]string{"status": "running"})
+	bundle, err := store.Receipt(chi.URLParam(r, "id"))
+	if errors.Is(err, ErrTransactionNotFound) {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, bundle)
+}
+
+// RerouteTransaction moves a transaction that failed on its destination to
+// another chain. With API tokens the operator is the token's name.
+func (api *API) RerouteTransaction(w http.ResponseWriter, r *http.Request) {
+	if api.module.GetTransactionStore() == nil {
+		respondError(w, http.StatusServiceUnavailable, "transaction history not configured")
+		return
+	}
+	var request RerouteRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if token, ok := core.TokenFromContext(r.Context()); ok {
+		request.Operator = token.Name
+	}
+
+	reroute, err := api.module.RerouteTransaction(chi.URLParam(r, "id"), request)
+	switch {
+	case err == nil:
+		respondJSON(w, http.StatusOK, reroute)
+	case errors.Is(err, ErrInvalidReroute):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrTransactionNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrNotStuck):
+		respondError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrReadOnlyReplica) || errors.Is(err, ErrZoneMismatch):
+		respondError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, ErrRoleNotServed):
+		respondError(w, http.StatusMisdirectedRequest, err.Error())
+	case errors.Is(err, ErrNoRouteFound) || errors.Is(err, ErrChainNotFound) || errors.Is(err, ErrChainIncapable):
+		respondError(w, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, ErrPoolFull):
+		respondError(w, http.StatusTooManyRequests, err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// ListReroutes returns the audited re-routes of a transaction, newest first
+func (api *API) ListReroutes(w http.ResponseWriter, r *http.Request) {
+	store := api.module.GetTransactionStore()
+	if store == nil {
+		respondError(w, http.StatusServiceUnavailable, "transaction history not configured")
+		return
+	}
+	reroutes, err := store.Reroutes(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"reroutes": reroutes})
+}
+
+// GetExperiment compares the arms of the running routing experiment
+func (api *API) GetExperiment(w http.ResponseWriter, r *http.Request) {
+	agg := api.module.GetAgglomerator()
+	if agg == nil {
+		respondError(w, http.StatusServiceUnavailable, "agglomerator not initialized")
+		return
+	}
+	report, err := agg.Experiment()
+	if errors.Is(err, ErrNoExperiment) {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, report)
+}
+
+// StartExperiment routes a percentage of transactions by other weights,
+// replacing any experiment running
+func (api *API) StartExperiment(w http.ResponseWriter, r *http.Request) {
+	var config ExperimentConfig
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	agg := api.module.GetAgglomerator()
+	if agg == nil {
+		respondError(w, http.StatusServiceUnavailable, "agglomerator not initialized")
+		return
+	}
+	report, err := agg.StartExperiment(config)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, report)
+}
+
+// StopExperiment ends the running experiment with its final report
+func (api *API) StopExperiment(w http.ResponseWriter, r *http.Request) {
+	agg := api.module.GetAgglomerator()
+	if agg == nil {
+		respondError(w, http.StatusServiceUnavailable, "agglomerator not initialized")
+		return
+	}
+	report, err := agg.StopExperiment()
+	if errors.Is(err, ErrNoExperiment) {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, report)
+}
+
+// ListAnomalies returns the detector state and flagged transactions,
+// optionally filtered by ?status=pending|approved|rejected
+func (api *API) ListAnomalies(w http.ResponseWriter, r *http.Request) {
+	detector, queue := api.module.GetAnomalyDetector(), api.module.GetAnomalies()
+	if detector == nil {
+		respondError(w, http.StatusServiceUnavailable, "anomaly detection not enabled")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"detector":  detector.Stats(),
+		"anomalies": queue.List(r.URL.Query().Get("status")),
+	})
+}
+
+// ApproveAnomaly clears a flagged transaction, routing it if it was held
+func (api *API) ApproveAnomaly(w http.ResponseWriter, r *http.Request) {
+	anomaly, err := api.module.ApproveAnomaly(chi.URLParam(r, "id"))
+	respondAnomalyReview(w, anomaly, err)
+}
+
+// RejectAnomaly confirms a flagged transaction, dropping it if it was held
+func (api *API) RejectAnomaly(w http.ResponseWriter, r *http.Request) {
+	anomaly, err := api.module.RejectAnomaly(chi.URLParam(r, "id"))
+	respondAnomalyReview(w, anomaly, err)
+}
+
+func respondAnomalyReview(w http.ResponseWriter, anomaly Anomaly, err error) {
+	switch {
+	case errors.Is(err, ErrAnomalyNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrAnomalyReviewed):
+		respondError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+	default:
+		respondJSON(w, http.StatusOK, anomaly)
+	}
+}
+
+// RetrainAnomalies rebuilds the detector's clusters from the transaction
+// vectors in the routing index
+func (api *API) RetrainAnomalies(w http.ResponseWriter, r *http.Request) {
+	stats, err := api.module.RetrainAnomalyDetector()
+	if err != nil {
+		respondError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, stats)
+}
+
+// ListClusters returns the clusters over chain and transaction vectors
+func (api *API) ListClusters(w http.ResponseWriter, r *http.Request) {
+	agg := api.module.GetAgglomerator()
+	if agg == nil {
+		respondError(w, http.StatusServiceUnavailable, "agglomerator not initialized")
+		return
+	}
+	respondJSON(w, http.StatusOK, agg.Clusters())
+}
+
+// GetCluster returns the chains and transactions assigned to a cluster
+func (api *API) GetCluster(w http.ResponseWriter, r *http.Request) {
+	agg := api.module.GetAgglomerator()
+	if agg == nil {
+		respondError(w, http.StatusServiceUnavailable, "agglomerator not initialized")
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid cluster id")
+		return
+	}
+	for _, cluster := range agg.Clusters() {
+		if cluster.ID == id {
+			respondJSON(w, http.StatusOK, map[string]interface{}{
+				"cluster": cluster,
+				"members": agg.ClusterMembers(id),
+			})
+			return
+		}
+	}
+	respondError(w, http.StatusNotFound, "cluster not found")
+}
+
+// RefitClusters reclusters the routing index immediately
+func (api *API) RefitClusters(w http.ResponseWriter, r *http.Request) {
+	agg := api.module.GetAgglomerator()
+	if agg == nil {
+		respondError(w, http.StatusServiceUnavailable, "agglomerator not initialized")
+		return
+	}
+	agg.RefitClusters()
+	respondJSON(w, http.StatusOK, agg.Clusters())
+}
+
+// GetVectorAnalysis reports the variance and routing importance of each
+// vector dimension. dims (default the configured vector dimensions) sets how
+// many are analyzed; tolerance sets the similarity error allowed when
+// recommending fewer.
+func (api *API) GetVectorAnalysis(w http.ResponseWriter, r *http.Request) {
+	agg := api.module.GetAgglomerator()
+	if agg == nil {
+		respondError(w, http.StatusServiceUnavailable, "agglomerator not initialized")
+		return
+	}
+
+	query := r.URL.Query()
+	var dims int
+	if value := query.Get("dims"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 2 || parsed > maxCompareDims {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("dims must be between 2 and %d", maxCompareDims))
+			return
+		}
+		dims = parsed
+	}
+	var tolerance float64
+	if value := query.Get("tolerance"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed <= 0 || parsed > 2 {
+			respondError(w, http.StatusBadRequest, "tolerance must be in (0, 2]")
+			return
+		}
+		tolerance = parsed
+	}
+
+	respondJSON(w, http.StatusOK, agg.AnalyzeVectors(dims, tolerance))
+}
+
+// ExportVectors downloads chain state vectors materialized to dims
+// dimensions (default the compared dimensions) as CSV, or as Parquet with
+// ?format=parquet. chain (comma-separated) and protocol select the chains.
+func (api *API) ExportVectors(w http.ResponseWriter, r *http.Request) {
+	agg := api.module.GetAgglomerator()
+	if agg == nil {
+		respondError(w, http.StatusServiceUnavailable, "agglomerator not initialized")
+		return
+	}
+
+	query := r.URL.Query()
+	dims := agg.CompareDims()
+	if value := query.Get("dims"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxCompareDims {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("dims must be between 1 and %d", maxCompareDims))
+			return
+		}
+		dims = parsed
+	}
+	format := query.Get("format")
+	if format == "" {
+		format = VectorFormatCSV
+	}
+	if format != VectorFormatCSV && format != VectorFormatParquet {
+		respondError(w, http.StatusBadRequest, "format must be csv or parquet")
+		return
+	}
+	var ids []string
+	if value := query.Get("chain"); value != "" {
+		ids = strings.Split(value, ",")
+	}
+
+	exported, err := agg.ChainVectors(ids, query.Get("protocol"), dims)
+	if errors.Is(err, ErrChainNotFound) {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if format == VectorFormatParquet {
+		w.Header().Set("Content-Type", "application/vnd.apache.parquet")
+		w.Header().Set("Content-Disposition", `attachment; filename="chain-vectors.parquet"`)
+		WriteVectorsParquet(w, exported, dims)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="chain-vectors.csv"`)
+	WriteVectorsCSV(w, exported, dims)
+}
+
+// ImportChainVector seeds a chain's state vector from externally computed
+// features: CSV in the export's columns, or JSON {"values": [...]}
+func (api *API) ImportChainVector(w http.ResponseWriter, r *http.Request) {
+	if api.module.GetAgglomerator() == nil {
+		respondError(w, http.StatusServiceUnavailable, "agglomerator not initialized")
+		return
+	}
+
+	chainID := chi.URLParam(r, "id")
+	var values []float64
+	if mediaType, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";"); strings.TrimSpace(mediaType) == "text/csv" {
+		parsed, err := ReadVectorCSV(r.Body, chainID)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		values = parsed
+	} else {
+		var body struct {
+			Values []float64 `json:"values"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			respondError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		values = body.Values
+	}
+
+	switch err := api.module.SeedChainVector(chainID, values); {
+	case errors.Is(err, ErrChainNotFound):
+		respondError(w, http.StatusNotFound, "chain not found")
+	case errors.Is(err, ErrInvalidVector):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrReadOnlyReplica):
+		respondError(w, http.StatusForbidden, err.Error())
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+	default:
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"chainId":    chainID,
+			"dimensions": len(values),
+		})
+	}
+}
+
+// GetSponsors reports the fees sponsor wallets paid this period, by tenant
+// and by wallet
+func (api *API) GetSponsors(w http.ResponseWriter, r *http.Request) {
+	ledger := api.module.GetSponsors()
+	if ledger == nil {
+		respondError(w, http.StatusServiceUnavailable, "sponsorship not enabled")
+		return
+	}
+	respondJSON(w, http.StatusOK, ledger.Report())
+}
+
+// GetPolicy returns the compliance rules in force
+func (api *API) GetPolicy(w http.ResponseWriter, r *http.Request) {
+	policy := api.module.GetPolicy()
+	if policy == nil {
+		respondError(w, http.StatusServiceUnavailable, "policy not enabled")
+		return
+	}
+	respondJSON(w, http.StatusOK, policy.Config())
+}
+
+// UpdatePolicy replaces the compliance rules and stores them in the module
+// configuration
+func (api *API) UpdatePolicy(w http.ResponseWriter, r *http.Request) {
+	if api.module.GetPolicy() == nil {
+		respondError(w, http.StatusServiceUnavailable, "policy not enabled")
+		return
+	}
+
+	var config PolicyConfig
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if _, err := compilePolicy(config); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := api.module.UpdatePolicy(config); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, config)
+}
+
+// ReloadPolicy reloads the compliance rules from the stored configuration
+func (api *API) ReloadPolicy(w http.ResponseWriter, r *http.Request) {
+	if api.module.GetPolicy() == nil {
+		respondError(w, http.StatusServiceUnavailable, "policy not enabled")
+		return
+	}
+	config, err := api.module.ReloadPolicy()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, config)
+}
+
+// EvaluatePolicy reports the decision the rules would make for a
+// transaction without processing or auditing it
+func (api *API) EvaluatePolicy(w http.ResponseWriter, r *http.Request) {
+	policy := api.module.GetPolicy()
+	if policy == nil {
+		respondError(w, http.StatusServiceUnavailable, "policy not enabled")
+		return
+	}
+
+	limits := api.module.GetPayloadLimits()
+	r.Body = http.MaxBytesReader(w, r.Body, limits.InlineSize*4/3+jsonEnvelopeSize)
+
+	var tx Transaction
+	if err := json.NewDecoder(r.Body).Decode(&tx); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := api.module.valueTransaction(&tx); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, policy.Evaluate(&tx))
+}
+
+// ListPolicyDecisions returns audited policy decisions, newest first,
+// optionally for one transaction with ?txId=
+func (api *API) ListPolicyDecisions(w http.ResponseWriter, r *http.Request) {
+	store := api.module.GetTransactionStore()
+	if store == nil {
+		respondError(w, http.StatusServiceUnavailable, "transaction history not configured")
+		return
+	}
+
+	query := r.URL.Query()
+	limit := 0
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			respondError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = parsed
+	}
+
+	decisions, err := store.PolicyDecisions(query.Get("txId"), limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, decisions)
+}
+
+// GetTopology returns the routing graph as {nodes, links} for D3 or graphviz
+func (api *API) GetTopology(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, api.module.GetTopology())
+}
+
+// QueryTransactions searches the transaction history. The q parameter takes
+// the syntax accepted by ParseTransactionQuery; limit and offset page results.
+func (api *API) QueryTransactions(w http.ResponseWriter, r *http.Request) {
+	store := api.module.GetTransactionStore()
+	if store == nil {
+		respondError(w, http.StatusServiceUnavailable, "transaction history not configured")
+		return
+	}
+
+	query, err := ParseTransactionQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	for param, target := range map[string]*int{"limit": &query.Limit, "offset": &query.Offset} {
+		if value := r.URL.Query().Get(param); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				respondError(w, http.StatusBadRequest, "invalid "+param)
+				return
+			}
+			*target = n
+		}
+	}
+
+	records, err := store.Query(query)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"transactions": records,
+		"count":        len(records),
+	})
+}
+
+// PutBlob stores a raw request body in the blob store and returns its hash
+func (api *API) PutBlob(w http.ResponseWriter, r *http.Request) {
+	if api.module.GetBlobs() == nil {
+		respondError(w, http.StatusServiceUnavailable, "blob store not configured")
+		return
+	}
+
+	info, err := api.module.PutBlob(r.Body)
+	if err != nil {
+		respondBlobError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, info)
+}
+
+// GetBlob streams a stored blob
+func (api *API) GetBlob(w http.ResponseWriter, r *http.Request) {
+	blobs := api.module.GetBlobs()
+	if blobs == nil {
+		respondError(w, http.StatusServiceUnavailable, "blob store not configured")
+		return
+	}
+
+	blob, size, err := blobs.Open(chi.URLParam(r, "hash"))
+	if err != nil {
+		respondBlobError(w, err)
+		return
+	}
+	defer blob.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, blob)
+}
+
+func respondBlobError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrPayloadTooLarge):
+		respondError(w, http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, ErrBlobNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrInvalidBlobHash):
+		respondError(w, http.StatusBadRequest, err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// jsonEnvelopeSize allows for the non-data fields of a transaction request
+const jsonEnvelopeSize = 64 << 10
+
+func respondInlineTooLarge(w http.ResponseWriter, limits PayloadLimits) {
+	respondError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf(
+		"inline payload exceeds %d bytes; upload data to /transaction/stream (up to %d bytes)",
+		limits.InlineSize, limits.MaxSize,
+	))
+}
+
+func (api *API) PauseModule(w http.ResponseWriter, r *http.Request) {
+	if api.module.GetState() != base.StateRunning {
+		respondError(w, http.StatusBadRequest, "module not running")
+		return
+	}
+
+	api.module.SetState(base.StatePaused)
+	respondJSON(w, http.StatusOK, map[string]string{"status": "paused"})
+}
+
+func (api *API) ResumeModule(w http.ResponseWriter, r *http.Request) {
+	if api.module.GetState() != base.StatePaused {
+		respondError(w, http.StatusBadRequest, "module not paused")
+		return
+	}
+
+	api.module.SetState(base.StateRunning)
+	respondJSON(w, http.StatusOK, map[string]string{"status": "running"})
+}
+
+func (api *API) GetGCStats(w http.ResponseWriter, r *http.Request) {
+	gc := api.module.GetGC()
+	if gc == nil {
+		respondError(w, http.StatusServiceUnavailable, "garbage collection not configured")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, gc.Stats())
+}
+
+// GetMetricsHistory returns sampled metric series. series is a comma-separated
+// list of names (all when omitted); from and to take RFC 3339 times, unix
+// seconds or a duration ago such as "6h", defaulting to the last hour; step
+// averages samples into buckets of that width.
+func (api *API) GetMetricsHistory(w http.ResponseWriter, r *http.Request) {
+	history := api.module.GetMetricsHistory()
+	if history == nil {
+		respondError(w, http.StatusServiceUnavailable, "metrics history not enabled")
+		return
+	}
+
+	now := time.Now()
+	query := r.URL.Query()
+	from, err := parseHistoryTime(query.Get("from"), now, now.Add(-time.Hour))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid from")
+		return
+	}
+	to, err := parseHistoryTime(query.Get("to"), now, now)
+	if err != nil || to.Before(from) {
+		respondError(w, http.StatusBadRequest, "invalid to")
+		return
+	}
+	var step time.Duration
+	if value := query.Get("step"); value != "" {
+		step, err = parseDuration(value)
+		if err != nil || step <= 0 {
+			respondError(w, http.StatusBadRequest, "invalid step")
+			return
+		}
+	}
+	var names []string
+	if value := query.Get("series"); value != "" {
+		names = strings.Split(value, ",")
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"from":   from,
+		"to":     to,
+		"series": history.Query(names, from, to, step),
+	})
+}
+
+// parseHistoryTime accepts an RFC 3339 time, unix seconds or a duration
+// before now
+func parseHistoryTime(value string, now, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	ago, err := parseDuration(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return now.Add(-ago), nil
+}
+
+// TriggerGC runs garbage collection immediately, for emergencies
+func (api *API) TriggerGC(w http.ResponseWriter, r *http.Request) {
+	gc := api.module.GetGC()
+	if gc == nil {
+		respondError(w, http.StatusServiceUnavailable, "garbage collection not configured")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"collected": gc.RunOnce(),
+	})
+}
+
+// ListAssets returns the registered assets and the currency they are valued
+// in
+func (api *API) ListAssets(w http.ResponseWriter, r *http.Request) {
+	assets := api.module.GetAssets()
+	if assets == nil {
+		respondError(w, http.StatusServiceUnavailable, "agglomerator not initialized")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"currency":  assets.Currency(),
+		"assets":    assets.Assets(),
+		"feeAssets": assets.FeeAssets(),
+	})
+}
+
+// RegisterAsset adds an asset, or replaces the one with the same chain and
+// symbol. Assets registered here are not saved to the module config.
+func (api *API) RegisterAsset(w http.ResponseWriter, r *http.Request) {
+	assets := api.module.GetAssets()
+	if assets == nil {
+		respondError(w, http.StatusServiceUnavailable, "agglomerator not initialized")
+		return
+	}
+
+	var asset Asset
+	if err := json.NewDecoder(r.Body).Decode(&asset); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := assets.Register(asset); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusCreated, asset)
+}
+
+func (api *API) RemoveAsset(w http.ResponseWriter, r *http.Request) {
+	assets := api.module.GetAssets()
+	if assets == nil {
+		respondError(w, http.StatusServiceUnavailable, "agglomerator not initialized")
+		return
+	}
+
+	if !assets.Remove(chi.URLParam(r, "chain"), chi.URLParam(r, "symbol")) {
+		respondError(w, http.StatusNotFound, "asset not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAssetValue values ?amount= base units of an asset
+func (api *API) GetAssetValue(w http.ResponseWriter, r *http.Request) {
+	assets := api.module.GetAssets()
+	if assets == nil {
+		respondError(w, http.StatusServiceUnavailable, "agglomerator not initialized")
+		return
+	}
+
+	valuation, err := assets.Value(r.Context(), chi.URLParam(r, "chain"), chi.URLParam(r, "symbol"), r.URL.Query().Get("amount"))
+	switch {
+	case errors.Is(err, ErrUnknownAsset):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrInvalidAmount):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case err != nil:
+		respondError(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		respondJSON(w, http.StatusOK, valuation)
+	}
+}
+
+// ListReconciliationReports returns the summaries of the latest daily
+// reports, newest first
+func (api *API) ListReconciliationReports(w http.ResponseWriter, r *http.Request) {
+	store := api.module.GetTransactionStore()
+	if store == nil {
+		respondError(w, http.StatusServiceUnavailable, "transaction history not configured")
+		return
+	}
+
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			respondError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = parsed
+	}
+
+	reports, err := store.ReconciliationReports(limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, reports)
+}
+
+// GetReconciliationReport returns the report for a day as JSON, or as a CSV
+// download of its mismatches with ?format=csv
+func (api *API) GetReconciliationReport(w http.ResponseWriter, r *http.Request) {
+	store := api.module.GetTransactionStore()
+	if store == nil {
+		respondError(w, http.StatusServiceUnavailable, "transaction history not configured")
+		return
+	}
+
+	date := chi.URLParam(r, "date")
+	if _, err := time.Parse(ReportDateLayout, date); err != nil {
+		respondError(w, http.StatusBadRequest, "date must be YYYY-MM-DD")
+		return
+	}
+	report, err := store.ReconciliationReport(date)
+	if errors.Is(err, ErrReportNotFound) {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		respondJSON(w, http.StatusOK, report)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="reconciliation-%s.csv"`, date))
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"txId", "fromChain", "toChain", "status", "blockHeight", "confirmations", "completedAt", "error"})
+		for _, entry := range report.Mismatches {
+			writer.Write([]string{
+				entry.TxID,
+				entry.FromChain,
+				entry.ToChain,
+				entry.Status,
+				strconv.FormatUint(entry.BlockHeight, 10),
+				strconv.FormatUint(entry.Confirmations, 10),
+				entry.CompletedAt.UTC().Format(time.RFC3339),
+				entry.Error,
+			})
+		}
+		writer.Flush()
+	default:
+		respondError(w, http.StatusBadRequest, "format must be json or csv")
+	}
+}
+
+// RunReconciliation reconciles a day now, ?date=YYYY-MM-DD or today
+func (api *API) RunReconciliation(w http.ResponseWriter, r *http.Request) {
+	day := time.Now()
+	if value := r.URL.Query().Get("date"); value != "" {
+		parsed, err := time.Parse(ReportDateLayout, value)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "date must be YYYY-MM-DD")
+			return
+		}
+		day = parsed
+	}
+
+	report, err := api.module.RunReconciliation(day)
+	if err != nil {
+		respondError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, report)
+}
+
+// GetChainRegistry returns the chain registry settings and the last import
+func (api *API) GetChainRegistry(w http.ResponseWriter, r *http.Request) {
+	importer := api.chainImporter(w)
+	if importer == nil {
+		return
+	}
+	config := importer.Config()
+	response := map[string]interface{}{
+		"url":          config.URL,
+		"interval":     config.Interval.String(),
+		"chainIds":     config.ChainIDs,
+		"protocol":     config.Protocol,
+		"protocols":    config.Protocols,
+		"idPrefix":     config.IDPrefix,
+		"maxEndpoints": config.MaxEndpoints,
+	}
+	if report, ok := importer.LastReport(); ok {
+		response["lastImport"] = report
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// ImportChains imports the chain registry now
+func (api *API) ImportChains(w http.ResponseWriter, r *http.Request) {
+	importer := api.chainImporter(w)
+	if importer == nil {
+		return
+	}
+	report, err := importer.Import(r.Context())
+	if errors.Is(err, ErrChainRegistryUnavailable) {
+		respondError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, report)
+}
+
+// chainImporter returns the chain registry importer, responding with an
+// error when there is none
+func (api *API) chainImporter(w http.ResponseWriter) *ChainImporter {
+	importer := api.module.GetChainImporter()
+	if importer == nil {
+		respondError(w, http.StatusNotFound, "chain registry not configured")
+	}
+	return importer
+}
+
+// eventLog returns the agglomerator's event log, responding with an error
+// when there is none
+func (api *API) eventLog(w http.ResponseWriter) *EventLog {
+	agg := api.module.GetAgglomerator()
+	if agg == nil || agg.EventLog() == nil {
+		respondError(w, http.StatusServiceUnavailable, "agglomerator not initialized")
+		return nil
+	}
+	return agg.EventLog()
+}
+
+// parseEventSince reads ?since=, the sequence number after which events are
+// returned
+func parseEventSince(r *http.Request) (uint64, error) {
+	value := r.URL.Query().Get("since")
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// parseEventLimit reads ?limit=, the most events returned in one response
+func parseEventLimit(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return defaultQueryLimit, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 || limit > maxQueryLimit {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxQueryLimit)
+	}
+	return limit, nil
+}
+
+// ListEvents returns events after ?since= in order, up to ?limit=, and
+// optionally only those of ?type=
+func (api *API) ListEvents(w http.ResponseWriter, r *http.Request) {
+	log := api.eventLog(w)
+	if log == nil {
+		return
+	}
+
+	since, err := parseEventSince(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid since")
+		return
+	}
+	limit, err := parseEventLimit(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	events, err := log.Events(since, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if eventType := r.URL.Query().Get("type"); eventType != "" {
+		filtered := events[:0]
+		for _, event := range events {
+			if event.Type == eventType {
+				filtered = append(filtered, event)
+			}
+		}
+		events = filtered
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"events": events,
+		"stats":  log.Stats(),
+	})
+}
+
+// ReplayEvents returns a page of events after the cursor ?from=, for
+// consumers catching up on events they missed. ?type= takes a comma-separated
+// list of event types. A cursor the log no longer covers answers 410, and
+// the consumer must resync from /events/state.
+func (api *API) ReplayEvents(w http.ResponseWriter, r *http.Request) {
+	log := api.eventLog(w)
+	if log == nil {
+		return
+	}
+
+	var from uint64
+	if value := r.URL.Query().Get("from"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid from cursor")
+			return
+		}
+		from = parsed
+	}
+	limit, err := parseEventLimit(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var types []string
+	if value := r.URL.Query().Get("type"); value != "" {
+		types = strings.Split(value, ",")
+	}
+
+	replay, err := log.Replay(from, limit, types)
+	switch {
+	case errors.Is(err, ErrCursorGone):
+		respondError(w, http.StatusGone, err.Error())
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+	default:
+		respondJSON(w, http.StatusOK, replay)
+	}
+}
+
+// StreamEvents sends events as server-sent events: those after ?since=,
+// then each new event until the client disconnects
+func (api *API) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	log := api.eventLog(w)
+	if log == nil {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+	since, err := parseEventSince(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid since")
+		return
+	}
+
+	// Subscribe before reading the backlog so no event falls between them
+	live, cancel := log.Subscribe(256)
+	defer cancel()
+	backlog, err := log.Events(since, 0)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event Event) bool {
+		if event.Seq <= since {
+			return true
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			return false
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, data); err != nil {
+			return false
+		}
+		since = event.Seq
+		return true
+	}
+	for _, event := range backlog {
+		if !send(event) {
+			return
+		}
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-live:
+			if !ok || !send(event) {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// GetEventState returns the chain registry and transaction state rebuilt
+// from the event log as of ?at= (RFC 3339), or now
+func (api *API) GetEventState(w http.ResponseWriter, r *http.Request) {
+	log := api.eventLog(w)
+	if log == nil {
+		return
+	}
+
+	var at time.Time
+	if value := r.URL.Query().Get("at"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "at must be an RFC 3339 time")
+			return
+		}
+		at = parsed
+	}
+
+	state, err := log.State(at)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, state)
 }
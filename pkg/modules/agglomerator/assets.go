@@ -0,0 +1,325 @@
+package agglomerator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownAsset  = errors.New("unknown asset")
+	ErrNoPrice       = errors.New("no price for asset")
+	ErrInvalidAmount = errors.New("invalid amount")
+)
+
+const (
+	DefaultValueCurrency = "USD"
+	DefaultPriceTTL      = time.Minute
+
+	maxAssetDecimals = 36
+	priceFeedTimeout = 5 * time.Second
+
+	// Transaction metadata keys recording the normalized value, so valued
+	// transactions can be found with meta.value and meta.currency
+	valueMetadataKey    = "value"
+	currencyMetadataKey = "currency"
+)
+
+// Asset is a token held on a chain. Amounts are given in base units and
+// scaled by 10^Decimals.
+type Asset struct {
+	Symbol      string `json:"symbol"`
+	Chain       string `json:"chain"`
+	Decimals    int    `json:"decimals"`
+	PriceSymbol string `json:"priceSymbol,omitempty"` // Symbol quoted by the price feed; Symbol if empty
+}
+
+func (a Asset) validate() error {
+	if a.Symbol == "" || a.Chain == "" {
+		return fmt.Errorf("symbol and chain required")
+	}
+	if a.Decimals < 0 || a.Decimals > maxAssetDecimals {
+		return fmt.Errorf("asset %s: decimals must be between 0 and %d", a.Symbol, maxAssetDecimals)
+	}
+	return nil
+}
+
+func (a Asset) priceSymbol() string {
+	if a.PriceSymbol != "" {
+		return a.PriceSymbol
+	}
+	return a.Symbol
+}
+
+// PriceFeed quotes the price of one unit of an asset in the registry's
+// currency
+type PriceFeed interface {
+	Price(ctx context.Context, symbol string) (float64, error)
+}
+
+// StaticPrices is a fixed price list by symbol
+type StaticPrices map[string]float64
+
+func (p StaticPrices) Price(_ context.Context, symbol string) (float64, error) {
+	price, exists := p[symbol]
+	if !exists {
+		return 0, fmt.Errorf("%w %s", ErrNoPrice, symbol)
+	}
+	return price, nil
+}
+
+// PriceFeeds tries each feed in order, returning the first quote found
+type PriceFeeds []PriceFeed
+
+func (feeds PriceFeeds) Price(ctx context.Context, symbol string) (float64, error) {
+	errs := make([]error, 0, len(feeds))
+	for _, feed := range feeds {
+		price, err := feed.Price(ctx, symbol)
+		if err == nil {
+			return price, nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return 0, fmt.Errorf("%w %s", ErrNoPrice, symbol)
+	}
+	return 0, errors.Join(errs...)
+}
+
+// HTTPPriceFeed fetches prices from a URL serving a JSON object of prices
+// by symbol, refetching them once they are older than the TTL
+type HTTPPriceFeed struct {
+	url     string
+	ttl     time.Duration
+	client  *http.Client
+	prices  map[string]float64
+	fetched time.Time
+	mu      sync.Mutex
+}
+
+func NewHTTPPriceFeed(url string, ttl time.Duration) *HTTPPriceFeed {
+	if ttl <= 0 {
+		ttl = DefaultPriceTTL
+	}
+	return &HTTPPriceFeed{
+		url:    url,
+		ttl:    ttl,
+		client: &http.Client{Timeout: priceFeedTimeout},
+	}
+}
+
+func (f *HTTPPriceFeed) Price(ctx context.Context, symbol string) (float64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.prices == nil || time.Since(f.fetched) > f.ttl {
+		prices, err := f.fetch(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("%w %s: %v", ErrNoPrice, symbol, err)
+		}
+		f.prices = prices
+		f.fetched = time.Now()
+	}
+
+	price, exists := f.prices[symbol]
+	if !exists {
+		return 0, fmt.Errorf("%w %s", ErrNoPrice, symbol)
+	}
+	return price, nil
+}
+
+func (f *HTTPPriceFeed) fetch(ctx context.Context) (map[string]float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("price feed returned %s", resp.Status)
+	}
+
+	var prices map[string]float64
+	if err := json.NewDecoder(resp.Body).Decode(&prices); err != nil {
+		return nil, fmt.Errorf("invalid price feed response: %w", err)
+	}
+	return prices, nil
+}
+
+// Valuation is an asset amount converted to the registry's currency
+type Valuation struct {
+	Asset    Asset   `json:"asset"`
+	Amount   string  `json:"amount"` // Base units
+	Price    float64 `json:"price"`  // Per whole unit
+	Value    float64 `json:"value"`
+	Currency string  `json:"currency"`
+}
+
+// AssetRegistry knows the assets on each chain and values amounts of them
+// in a single currency
+type AssetRegistry struct {
+	currency string
+	feed     PriceFeed
+	assets   map[string]Asset  // Keyed by chain and symbol
+	fees     map[string]string // Symbol of the asset each chain's fees are paid in
+	mu       sync.RWMutex
+}
+
+func NewAssetRegistry(currency string, feed PriceFeed) *AssetRegistry {
+	if currency == "" {
+		currency = DefaultValueCurrency
+	}
+	if feed == nil {
+		feed = PriceFeeds(nil)
+	}
+	return &AssetRegistry{
+		currency: currency,
+		feed:     feed,
+		assets:   make(map[string]Asset),
+		fees:     make(map[string]string),
+	}
+}
+
+func assetKey(chain, symbol string) string {
+	return chain + "/" + symbol
+}
+
+// Currency returns the currency values are expressed in
+func (r *AssetRegistry) Currency() string {
+	return r.currency
+}
+
+// Register adds an asset or replaces the one with the same chain and symbol
+func (r *AssetRegistry) Register(asset Asset) error {
+	if err := asset.validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.assets[assetKey(asset.Chain, asset.Symbol)] = asset
+	return nil
+}
+
+// Remove forgets an asset, returning whether it was registered
+func (r *AssetRegistry) Remove(chain, symbol string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := assetKey(chain, symbol)
+	_, exists := r.assets[key]
+	delete(r.assets, key)
+	if r.fees[chain] == symbol {
+		delete(r.fees, chain)
+	}
+	return exists
+}
+
+// SetFeeAsset names the asset, registered on chain, that the chain's fees
+// are paid and estimated in
+func (r *AssetRegistry) SetFeeAsset(chain, symbol string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.assets[assetKey(chain, symbol)]; !exists {
+		return fmt.Errorf("%w %s on %s", ErrUnknownAsset, symbol, chain)
+	}
+	r.fees[chain] = symbol
+	return nil
+}
+
+// FeeAsset returns the asset a chain's fees are paid in
+func (r *AssetRegistry) FeeAsset(chain string) (Asset, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	symbol, exists := r.fees[chain]
+	if !exists {
+		return Asset{}, false
+	}
+	asset, exists := r.assets[assetKey(chain, symbol)]
+	return asset, exists
+}
+
+// FeeAssets returns the symbol of the fee asset of each chain that has one
+func (r *AssetRegistry) FeeAssets() map[string]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	fees := make(map[string]string, len(r.fees))
+	for chain, symbol := range r.fees {
+		fees[chain] = symbol
+	}
+	return fees
+}
+
+func (r *AssetRegistry) Get(chain, symbol string) (Asset, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	asset, exists := r.assets[assetKey(chain, symbol)]
+	return asset, exists
+}
+
+// Assets returns the registered assets ordered by chain and symbol
+func (r *AssetRegistry) Assets() []Asset {
+	r.mu.RLock()
+	assets := make([]Asset, 0, len(r.assets))
+	for _, asset := range r.assets {
+		assets = append(assets, asset)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(assets, func(i, j int) bool {
+		if assets[i].Chain != assets[j].Chain {
+			return assets[i].Chain < assets[j].Chain
+		}
+		return assets[i].Symbol < assets[j].Symbol
+	})
+	return assets
+}
+
+// Price returns the price of one whole unit of an asset on chain in the
+// registry's currency. The currency itself is priced at 1 on every chain.
+func (r *AssetRegistry) Price(ctx context.Context, chain, symbol string) (float64, error) {
+	if symbol == r.currency {
+		return 1, nil
+	}
+	asset, exists := r.Get(chain, symbol)
+	if !exists {
+		return 0, fmt.Errorf("%w %s on %s", ErrUnknownAsset, symbol, chain)
+	}
+	return r.feed.Price(ctx, asset.priceSymbol())
+}
+
+// Value converts amount, in base units of the asset on chain, to the
+// registry's currency
+func (r *AssetRegistry) Value(ctx context.Context, chain, symbol, amount string) (Valuation, error) {
+	asset, exists := r.Get(chain, symbol)
+	if !exists {
+		return Valuation{}, fmt.Errorf("%w %s on %s", ErrUnknownAsset, symbol, chain)
+	}
+
+	units, ok := new(big.Int).SetString(amount, 10)
+	if !ok || units.Sign() < 0 {
+		return Valuation{}, fmt.Errorf("%w %q", ErrInvalidAmount, amount)
+	}
+
+	price, err := r.feed.Price(ctx, asset.priceSymbol())
+	if err != nil {
+		return Valuation{}, err
+	}
+
+	scale := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(asset.Decimals)), nil))
+	value, _ := new(big.Float).Quo(new(big.Float).SetInt(units), scale).Float64()
+	return Valuation{
+		Asset:    asset,
+		Amount:   amount,
+		Price:    price,
+		Value:    value * price,
+		Currency: r.currency,
+	}, nil
+}
@@ -0,0 +1,115 @@
+package agglomerator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+func TestAssetRegistryValue(t *testing.T) {
+	registry := NewAssetRegistry("", PriceFeeds{StaticPrices{"ETH": 2000}, StaticPrices{"USDC": 1}})
+	require.NoError(t, registry.Register(Asset{Symbol: "ETH", Chain: "eth", Decimals: 18}))
+	require.NoError(t, registry.Register(Asset{Symbol: "WETH", Chain: "sol", Decimals: 8, PriceSymbol: "ETH"}))
+	require.NoError(t, registry.Register(Asset{Symbol: "USDC", Chain: "eth", Decimals: 6}))
+	assert.Error(t, registry.Register(Asset{Symbol: "BAD", Chain: "eth", Decimals: -1}))
+
+	ctx := context.Background()
+	valuation, err := registry.Value(ctx, "eth", "ETH", "1500000000000000000")
+	require.NoError(t, err)
+	assert.InDelta(t, 3000, valuation.Value, 1e-9)
+	assert.Equal(t, DefaultValueCurrency, valuation.Currency)
+
+	valuation, err = registry.Value(ctx, "sol", "WETH", "50000000")
+	require.NoError(t, err)
+	assert.InDelta(t, 1000, valuation.Value, 1e-9, "wrapped assets are priced by their price symbol")
+
+	valuation, err = registry.Value(ctx, "eth", "USDC", "2500000")
+	require.NoError(t, err)
+	assert.InDelta(t, 2.5, valuation.Value, 1e-9, "later feeds are tried when earlier ones have no quote")
+
+	_, err = registry.Value(ctx, "sol", "ETH", "1")
+	assert.ErrorIs(t, err, ErrUnknownAsset, "assets are registered per chain")
+	_, err = registry.Value(ctx, "eth", "ETH", "-1")
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+	_, err = registry.Value(ctx, "eth", "ETH", "1.5")
+	assert.ErrorIs(t, err, ErrInvalidAmount, "amounts are whole base units")
+
+	require.NoError(t, registry.Register(Asset{Symbol: "DAI", Chain: "eth", Decimals: 18}))
+	_, err = registry.Value(ctx, "eth", "DAI", "1")
+	assert.ErrorIs(t, err, ErrNoPrice)
+
+	assert.True(t, registry.Remove("eth", "DAI"))
+	assert.False(t, registry.Remove("eth", "DAI"))
+	var symbols []string
+	for _, asset := range registry.Assets() {
+		symbols = append(symbols, asset.Chain+"/"+asset.Symbol)
+	}
+	assert.Equal(t, []string{"eth/ETH", "eth/USDC", "sol/WETH"}, symbols)
+}
+
+func TestHTTPPriceFeedCachesPrices(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		json.NewEncoder(w).Encode(map[string]float64{"ETH": 2000})
+	}))
+	defer server.Close()
+
+	feed := NewHTTPPriceFeed(server.URL, 0)
+	for i := 0; i < 3; i++ {
+		price, err := feed.Price(context.Background(), "ETH")
+		require.NoError(t, err)
+		assert.Equal(t, 2000.0, price)
+	}
+	assert.Equal(t, 1, requests, "prices are reused until the TTL passes")
+
+	_, err := feed.Price(context.Background(), "BTC")
+	assert.ErrorIs(t, err, ErrNoPrice)
+
+	server.Close()
+	_, err = NewHTTPPriceFeed(server.URL, 0).Price(context.Background(), "ETH")
+	assert.ErrorIs(t, err, ErrNoPrice, "an unreachable feed has no prices")
+}
+
+func TestValuedTransactionRoutingAndPolicy(t *testing.T) {
+	chain := NewChain("eth", "http://localhost:8545", ProtocolEthereum)
+	chain.StateVector = vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}
+	tx := &Transaction{ID: "tx-1", FromChain: "eth", ToChain: "eth", Asset: "ETH",
+		StateVector: vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}}
+
+	unvalued := calculateRouteMetrics(chain, tx, DefaultCompareDims)
+	assert.Len(t, unvalued.factors(), 5, "unvalued transactions are scored as before")
+
+	tx.Value = 50000
+	valued := calculateRouteMetrics(chain, tx, DefaultCompareDims)
+	factors := valued.factors()
+	require.Len(t, factors, 6)
+	assert.Equal(t, "valueAtRisk", factors[5].Name)
+	assert.Greater(t, evaluateRoute(valued), evaluateRoute(unvalued))
+
+	tx.Value = 500
+	assert.Less(t, calculateRouteMetrics(chain, tx, DefaultCompareDims).factors()[5].Value, factors[5].Value,
+		"the term grows with the value at risk")
+
+	engine, err := NewPolicyEngine(PolicyConfig{Rules: []PolicyRule{
+		{Name: "small", Action: PolicyAllow, MaxValue: 1000},
+		{Name: "large", Action: PolicyDeny, MinValue: 10000},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, "small", engine.Evaluate(tx).Rule)
+	assert.Equal(t, "", engine.Evaluate(&Transaction{FromChain: "eth", ToChain: "eth"}).Rule,
+		"value bounds only match valued transactions")
+	tx.Value = 5000
+	assert.Equal(t, "", engine.Evaluate(tx).Rule)
+	tx.Value = 50000
+	assert.Equal(t, "large", engine.Evaluate(tx).Rule)
+
+	_, err = compilePolicy(PolicyConfig{Rules: []PolicyRule{{Name: "a", Action: PolicyDeny, MinValue: 10, MaxValue: 5}}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,221 @@
+package agglomerator
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrAttestationRequired  = errors.New("peer attestation required")
+	ErrUntrustedAttestation = errors.New("attestation not signed by a trusted anchor")
+	ErrAttestationExpired   = errors.New("attestation expired")
+	ErrTrustAnchorNotFound  = errors.New("trust anchor not found")
+)
+
+// Attestation binds a node ID to its public keys. It is signed with an
+// Ed25519 deployment CA key whose public half peers hold as a trust anchor.
+type Attestation struct {
+	NodeID           string    `json:"nodeId"`
+	SigningAlgorithm string    `json:"signingAlgorithm"`
+	SigningKey       string    `json:"signingKey"` // Base64
+	KEMAlgorithm     string    `json:"kemAlgorithm,omitempty"`
+	KEMKey           string    `json:"kemKey,omitempty"` // Base64
+	IssuedAt         time.Time `json:"issuedAt"`
+	ExpiresAt        time.Time `json:"expiresAt"`
+	Issuer           string    `json:"issuer"` // Fingerprint of the CA key
+	Signature        []byte    `json:"signature"`
+}
+
+// payload is the signed content: every field but the signature, with times
+// in UTC so the payload survives the wire's Unix timestamps
+func (a Attestation) payload() []byte {
+	a.Signature = nil
+	a.IssuedAt = a.IssuedAt.UTC()
+	a.ExpiresAt = a.ExpiresAt.UTC()
+	data, _ := json.Marshal(a)
+	return data
+}
+
+// SignAttestation fills in the issuer and signs an attestation with a CA key
+func SignAttestation(attestation Attestation, caKey ed25519.PrivateKey) Attestation {
+	attestation.Issuer = KeyFingerprint(caKey.Public().(ed25519.PublicKey))
+	attestation.Signature = ed25519.Sign(caKey, attestation.payload())
+	return attestation
+}
+
+// LoadAttestation reads a signed attestation from a JSON file
+func LoadAttestation(path string) (*Attestation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attestation: %w", err)
+	}
+	var attestation Attestation
+	if err := json.Unmarshal(data, &attestation); err != nil {
+		return nil, fmt.Errorf("invalid attestation %s: %w", path, err)
+	}
+	return &attestation, nil
+}
+
+// KeyFingerprint identifies a CA key by the first 16 bytes of its SHA-256
+func KeyFingerprint(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:16])
+}
+
+// TrustAnchor is a CA public key whose attestations are accepted
+type TrustAnchor struct {
+	Name        string `json:"name"`
+	PublicKey   string `json:"publicKey"` // Base64 Ed25519 key
+	Fingerprint string `json:"fingerprint"`
+}
+
+// parseTrustAnchor decodes and fingerprints an anchor's public key
+func parseTrustAnchor(name, publicKey string) (TrustAnchor, ed25519.PublicKey, error) {
+	if name == "" {
+		return TrustAnchor{}, nil, fmt.Errorf("trust anchor name is required")
+	}
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return TrustAnchor{}, nil, fmt.Errorf("trust anchor %s: public key must be a base64 Ed25519 key", name)
+	}
+	anchor := TrustAnchor{Name: name, PublicKey: publicKey, Fingerprint: KeyFingerprint(key)}
+	return anchor, key, nil
+}
+
+// TrustAnchors holds the CA keys a node accepts peer attestations from.
+// Attestations are only required once an anchor has been added.
+type TrustAnchors struct {
+	anchors map[string]TrustAnchor
+	keys    map[string]ed25519.PublicKey // By fingerprint
+	mu      sync.RWMutex
+}
+
+func NewTrustAnchors() *TrustAnchors {
+	return &TrustAnchors{
+		anchors: make(map[string]TrustAnchor),
+		keys:    make(map[string]ed25519.PublicKey),
+	}
+}
+
+// Add trusts a CA public key under a name, replacing any anchor of that name
+func (t *TrustAnchors) Add(name, publicKey string) (TrustAnchor, error) {
+	anchor, key, err := parseTrustAnchor(name, publicKey)
+	if err != nil {
+		return anchor, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if previous, exists := t.anchors[name]; exists {
+		delete(t.keys, previous.Fingerprint)
+	}
+	t.anchors[name] = anchor
+	t.keys[anchor.Fingerprint] = key
+	return anchor, nil
+}
+
+func (t *TrustAnchors) Remove(name string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	anchor, exists := t.anchors[name]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrTrustAnchorNotFound, name)
+	}
+	delete(t.anchors, name)
+	delete(t.keys, anchor.Fingerprint)
+	return nil
+}
+
+// List returns the anchors sorted by name
+func (t *TrustAnchors) List() []TrustAnchor {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	anchors := make([]TrustAnchor, 0, len(t.anchors))
+	for _, anchor := range t.anchors {
+		anchors = append(anchors, anchor)
+	}
+	sort.Slice(anchors, func(i, j int) bool { return anchors[i].Name < anchors[j].Name })
+	return anchors
+}
+
+// Required reports whether peers must present an attestation
+func (t *TrustAnchors) Required() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.anchors) > 0
+}
+
+// Verify checks that an attestation is for nodeID, current, and signed by a
+// trusted anchor
+func (t *TrustAnchors) Verify(attestation *Attestation, nodeID string, now time.Time) error {
+	if attestation == nil {
+		return ErrAttestationRequired
+	}
+	if attestation.NodeID != nodeID {
+		return fmt.Errorf("%w: issued for node %s", ErrUntrustedAttestation, attestation.NodeID)
+	}
+
+	t.mu.RLock()
+	key, exists := t.keys[attestation.Issuer]
+	t.mu.RUnlock()
+	if !exists || !ed25519.Verify(key, attestation.payload(), attestation.Signature) {
+		return ErrUntrustedAttestation
+	}
+	if now.Before(attestation.IssuedAt) || !now.Before(attestation.ExpiresAt) {
+		return ErrAttestationExpired
+	}
+	return nil
+}
+
+// SetAttestation sets the attestation this node presents in handshakes. It
+// must be called before UseTransport.
+func (node *P2PInfiniteVectorNode) SetAttestation(attestation *Attestation) {
+	node.connMu.Lock()
+	defer node.connMu.Unlock()
+	node.attestation = attestation
+}
+
+// Attestation returns the attestation this node presents, or nil
+func (node *P2PInfiniteVectorNode) Attestation() *Attestation {
+	node.connMu.Lock()
+	defer node.connMu.Unlock()
+	return node.attestation
+}
+
+// TrustAnchors returns the CA keys peers' attestations are checked against
+func (node *P2PInfiniteVectorNode) TrustAnchors() *TrustAnchors {
+	return node.trustAnchors
+}
+
+// verifyPeer checks a peer's attestation when trust anchors are configured
+func (node *P2PInfiniteVectorNode) verifyPeer(peerID string, attestation *Attestation) error {
+	if !node.trustAnchors.Required() {
+		return nil
+	}
+	if err := node.trustAnchors.Verify(attestation, peerID, time.Now()); err != nil {
+		return fmt.Errorf("peer %s: %w", peerID, err)
+	}
+	return nil
+}
+
+// dropPeer removes a peer from the peer set along with what is kept about
+// it, so it is not chosen for replication or queried
+func (node *P2PInfiniteVectorNode) dropPeer(peerID string) {
+	node.peerMutex.Lock()
+	delete(node.peers, peerID)
+	node.peerMutex.Unlock()
+	node.bandwidth.Forget(peerID)
+	node.filters.Forget(peerID)
+	node.zones.Forget(peerID)
+	node.broadcaster.Forget(peerID)
+	node.peerRoles.Forget(peerID)
+}
@@ -0,0 +1,116 @@
+package agglomerator
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCAKey(t *testing.T) (string, ed25519.PrivateKey) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(publicKey), privateKey
+}
+
+func attestNode(nodeID string, caKey ed25519.PrivateKey, issuedAt time.Time) *Attestation {
+	attestation := SignAttestation(Attestation{
+		NodeID:           nodeID,
+		SigningAlgorithm: "DILITHIUM5",
+		SigningKey:       base64.StdEncoding.EncodeToString([]byte("signing-" + nodeID)),
+		KEMAlgorithm:     "KYBER1024",
+		KEMKey:           base64.StdEncoding.EncodeToString([]byte("kem-" + nodeID)),
+		IssuedAt:         issuedAt,
+		ExpiresAt:        issuedAt.Add(time.Hour),
+	}, caKey)
+	return &attestation
+}
+
+func TestTrustAnchorsVerify(t *testing.T) {
+	caPublic, caKey := newCAKey(t)
+	_, otherKey := newCAKey(t)
+	now := time.Now()
+
+	anchors := NewTrustAnchors()
+	assert.False(t, anchors.Required())
+	anchor, err := anchors.Add("deployment", caPublic)
+	require.NoError(t, err)
+	assert.True(t, anchors.Required())
+	assert.Equal(t, anchor.Fingerprint, attestNode("node-a", caKey, now).Issuer)
+
+	assert.NoError(t, anchors.Verify(attestNode("node-a", caKey, now), "node-a", now))
+	assert.ErrorIs(t, anchors.Verify(nil, "node-a", now), ErrAttestationRequired)
+	assert.ErrorIs(t, anchors.Verify(attestNode("node-a", caKey, now), "node-b", now), ErrUntrustedAttestation, "attestation replayed by another node")
+	assert.ErrorIs(t, anchors.Verify(attestNode("node-a", otherKey, now), "node-a", now), ErrUntrustedAttestation)
+	assert.ErrorIs(t, anchors.Verify(attestNode("node-a", caKey, now.Add(-2*time.Hour)), "node-a", now), ErrAttestationExpired)
+
+	tampered := attestNode("node-a", caKey, now)
+	tampered.SigningKey = base64.StdEncoding.EncodeToString([]byte("swapped"))
+	assert.ErrorIs(t, anchors.Verify(tampered, "node-a", now), ErrUntrustedAttestation)
+
+	_, err = anchors.Add("broken", "not-a-key")
+	assert.Error(t, err)
+	require.NoError(t, anchors.Remove("deployment"))
+	assert.ErrorIs(t, anchors.Remove("deployment"), ErrTrustAnchorNotFound)
+	assert.ErrorIs(t, anchors.Verify(attestNode("node-a", caKey, now), "node-a", now), ErrUntrustedAttestation)
+}
+
+func TestHandshakeRefusesUnattestedPeers(t *testing.T) {
+	caPublic, caKey := newCAKey(t)
+	newNode := func(attested bool) *P2PInfiniteVectorNode {
+		node := NewP2PInfiniteVectorNode("127.0.0.1", 0)
+		_, err := node.TrustAnchors().Add("deployment", caPublic)
+		require.NoError(t, err)
+		if attested {
+			node.SetAttestation(attestNode(node.NodeID, caKey, time.Now()))
+		}
+		transport, err := NewTransport(TransportConfig{Type: "tcp"})
+		require.NoError(t, err)
+		require.NoError(t, node.UseTransport(transport))
+		t.Cleanup(func() { node.CloseTransport() })
+		return node
+	}
+	dial := func(from, to *P2PInfiniteVectorNode) error {
+		from.connectToPeer(&PeerInfo{NodeID: to.NodeID, Address: to.listener.Addr()})
+		_, err := from.peerConn(from.transport, to.NodeID)
+		return err
+	}
+	hasPeer := func(node *P2PInfiniteVectorNode, peerID string) bool {
+		node.peerMutex.RLock()
+		defer node.peerMutex.RUnlock()
+		_, exists := node.peers[peerID]
+		return exists
+	}
+
+	a := newNode(true)
+	b := newNode(true)
+	require.NoError(t, dial(a, b))
+	assert.True(t, hasPeer(a, b.NodeID))
+
+	rogue := newNode(false)
+	assert.Error(t, dial(rogue, a), "an attested node refuses an unattested dialer")
+
+	assert.ErrorIs(t, dial(a, rogue), ErrAttestationRequired)
+	a.connMu.Lock()
+	_, connected := a.conns[rogue.NodeID]
+	a.connMu.Unlock()
+	assert.False(t, connected, "refused peers get no channel")
+
+	// A hello sent in b's name without its attestation is refused, and b
+	// keeps its place and its channel
+	spoofed := Handshake{NodeID: b.NodeID, Algorithms: SignatureAlgorithms, Version: WireVersion, MinVersion: MinWireVersion}
+	_, err := a.acceptHandshake(io.Discard, spoofed)
+	assert.ErrorIs(t, err, ErrAttestationRequired)
+	assert.True(t, hasPeer(a, b.NodeID))
+	_, err = a.peerConn(a.transport, b.NodeID)
+	assert.NoError(t, err)
+	a.connMu.Lock()
+	_, connected = a.conns[b.NodeID]
+	a.connMu.Unlock()
+	assert.True(t, connected)
+}
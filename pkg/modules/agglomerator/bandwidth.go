@@ -0,0 +1,142 @@
+package agglomerator
+
+import (
+	"sync"
+	"time"
+)
+
+// MessagePriority orders outbound P2P traffic
+type MessagePriority int
+
+const (
+	// PriorityControl is used for small gossip such as chain registrations
+	PriorityControl MessagePriority = iota
+	// PriorityBulk is used for record replication
+	PriorityBulk
+)
+
+// BandwidthConfig caps outbound traffic per peer
+type BandwidthConfig struct {
+	PeerBytesPerSecond int64 // 0 disables throttling
+	BurstBytes         int64 // Bucket capacity; defaults to one second of traffic
+}
+
+// PeerBandwidth holds traffic counters for a single peer
+type PeerBandwidth struct {
+	BytesIn     uint64 `json:"bytesIn"`
+	BytesOut    uint64 `json:"bytesOut"`
+	MessagesIn  uint64 `json:"messagesIn"`
+	MessagesOut uint64 `json:"messagesOut"`
+	Throttled   uint64 `json:"throttled"`
+}
+
+type peerBucket struct {
+	PeerBandwidth
+	tokens float64
+	last   time.Time
+}
+
+// BandwidthManager accounts per-peer traffic and enforces the outbound cap
+type BandwidthManager struct {
+	mu     sync.Mutex
+	config BandwidthConfig
+	peers  map[string]*peerBucket
+}
+
+func NewBandwidthManager(config BandwidthConfig) *BandwidthManager {
+	return &BandwidthManager{
+		config: config,
+		peers:  make(map[string]*peerBucket),
+	}
+}
+
+// SetConfig replaces the bandwidth limits
+func (bm *BandwidthManager) SetConfig(config BandwidthConfig) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bm.config = config
+}
+
+// RecordIn accounts an inbound message from a peer
+func (bm *BandwidthManager) RecordIn(peerID string, size int) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	bucket := bm.bucket(peerID)
+	bucket.BytesIn += uint64(size)
+	bucket.MessagesIn++
+}
+
+// Reserve tries to spend size bytes of a peer's outbound budget. It returns
+// zero when the message may be sent now, or how long to wait otherwise.
+// Control traffic is never delayed; it borrows against future budget instead.
+func (bm *BandwidthManager) Reserve(peerID string, size int, priority MessagePriority) time.Duration {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	bucket := bm.bucket(peerID)
+	rate := float64(bm.config.PeerBytesPerSecond)
+	if rate <= 0 {
+		bm.recordOut(bucket, size)
+		return 0
+	}
+
+	capacity := float64(bm.config.BurstBytes)
+	if capacity <= 0 {
+		capacity = rate
+	}
+
+	now := time.Now()
+	if bucket.last.IsZero() {
+		bucket.tokens = capacity
+	} else {
+		bucket.tokens += now.Sub(bucket.last).Seconds() * rate
+		if bucket.tokens > capacity {
+			bucket.tokens = capacity
+		}
+	}
+	bucket.last = now
+
+	if priority == PriorityControl || bucket.tokens >= float64(size) {
+		bucket.tokens -= float64(size)
+		bm.recordOut(bucket, size)
+		return 0
+	}
+
+	bucket.Throttled++
+	deficit := float64(size) - bucket.tokens
+	return time.Duration(deficit / rate * float64(time.Second))
+}
+
+// Stats returns a snapshot of per-peer counters
+func (bm *BandwidthManager) Stats() map[string]PeerBandwidth {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	stats := make(map[string]PeerBandwidth, len(bm.peers))
+	for peerID, bucket := range bm.peers {
+		stats[peerID] = bucket.PeerBandwidth
+	}
+	return stats
+}
+
+// Forget drops the counters of a disconnected peer
+func (bm *BandwidthManager) Forget(peerID string) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	delete(bm.peers, peerID)
+}
+
+func (bm *BandwidthManager) bucket(peerID string) *peerBucket {
+	bucket, exists := bm.peers[peerID]
+	if !exists {
+		bucket = &peerBucket{}
+		bm.peers[peerID] = bucket
+	}
+	return bucket
+}
+
+func (bm *BandwidthManager) recordOut(bucket *peerBucket, size int) {
+	bucket.BytesOut += uint64(size)
+	bucket.MessagesOut++
+}
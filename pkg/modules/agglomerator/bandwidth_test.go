@@ -0,0 +1,52 @@
+package agglomerator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBandwidthThrottlesBulkTraffic(t *testing.T) {
+	bm := NewBandwidthManager(BandwidthConfig{PeerBytesPerSecond: 1000, BurstBytes: 2000})
+
+	// The burst is available at once, then bulk sends wait for the rate
+	assert.Zero(t, bm.Reserve("peer", 2000, PriorityBulk))
+	wait := bm.Reserve("peer", 500, PriorityBulk)
+	assert.InDelta(t, 500*time.Millisecond, wait, float64(50*time.Millisecond))
+
+	// Control traffic is never delayed, and borrows against later budget
+	assert.Zero(t, bm.Reserve("peer", 300, PriorityControl))
+	wait = bm.Reserve("peer", 500, PriorityBulk)
+	assert.InDelta(t, 800*time.Millisecond, wait, float64(50*time.Millisecond))
+
+	// Each peer has its own budget
+	assert.Zero(t, bm.Reserve("other", 2000, PriorityBulk))
+
+	bm.RecordIn("peer", 64)
+	stats := bm.Stats()["peer"]
+	assert.Equal(t, uint64(2300), stats.BytesOut)
+	assert.Equal(t, uint64(2), stats.MessagesOut)
+	assert.Equal(t, uint64(2), stats.Throttled)
+	assert.Equal(t, uint64(64), stats.BytesIn)
+	assert.Equal(t, uint64(1), stats.MessagesIn)
+
+	bm.Forget("peer")
+	assert.NotContains(t, bm.Stats(), "peer")
+}
+
+func TestBandwidthRefillsAndDisables(t *testing.T) {
+	bm := NewBandwidthManager(BandwidthConfig{PeerBytesPerSecond: 100000})
+
+	// The burst defaults to one second of traffic
+	assert.Zero(t, bm.Reserve("peer", 100000, PriorityBulk))
+	assert.NotZero(t, bm.Reserve("peer", 1000, PriorityBulk))
+	time.Sleep(20 * time.Millisecond)
+	assert.Zero(t, bm.Reserve("peer", 1000, PriorityBulk))
+
+	bm.SetConfig(BandwidthConfig{})
+	for range 10 {
+		assert.Zero(t, bm.Reserve("peer", 1<<20, PriorityBulk))
+	}
+	assert.Equal(t, uint64(1), bm.Stats()["peer"].Throttled)
+}
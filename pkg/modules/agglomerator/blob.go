@@ -0,0 +1,229 @@
+package agglomerator
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/zeebo/blake3"
+)
+
+var (
+	ErrBlobNotFound    = errors.New("blob not found")
+	ErrInvalidBlobHash = errors.New("invalid blob hash")
+)
+
+// blobHashLength is the hex length of a Blake3-256 digest
+const blobHashLength = 64
+
+// BlobInfo describes a stored blob
+type BlobInfo struct {
+	Hash     string    `json:"hash"`
+	Size     int64     `json:"size"`
+	StoredAt time.Time `json:"storedAt"`
+}
+
+// BlobStats summarizes the contents of a blob store
+type BlobStats struct {
+	Blobs int   `json:"blobs"`
+	Bytes int64 `json:"bytes"`
+}
+
+// BlobStore keeps large payloads on disk addressed by their Blake3 hash.
+// Blobs are written once and shared by every transaction that references
+// them; unreferenced blobs are removed by Collect.
+type BlobStore struct {
+	dir        string
+	referenced func() map[string]bool
+	mu         sync.RWMutex
+}
+
+// NewBlobStore opens a blob store rooted at dir, creating it if needed
+func NewBlobStore(dir string) (*BlobStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	return &BlobStore{dir: dir}, nil
+}
+
+// SetReferenced sets the function reporting which blobs are still in use
+func (s *BlobStore) SetReferenced(referenced func() map[string]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.referenced = referenced
+}
+
+// Put streams r into the store and returns its hash. Reading stops with an
+// error once more than maxSize bytes have been read; zero means no limit.
+func (s *BlobStore) Put(r io.Reader, maxSize int64) (BlobInfo, error) {
+	tmp, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return BlobInfo{}, fmt.Errorf("failed to create blob: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if maxSize > 0 {
+		r = io.LimitReader(r, maxSize+1)
+	}
+
+	hasher := blake3.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), r)
+	if err != nil {
+		return BlobInfo{}, fmt.Errorf("failed to write blob: %w", err)
+	}
+	if maxSize > 0 && size > maxSize {
+		return BlobInfo{}, fmt.Errorf("%w: blob exceeds %d bytes", ErrPayloadTooLarge, maxSize)
+	}
+	if err := tmp.Close(); err != nil {
+		return BlobInfo{}, fmt.Errorf("failed to write blob: %w", err)
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	path := s.path(hash)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Identical content is already stored; just refresh its age for GC
+	if _, err := os.Stat(path); err == nil {
+		now := time.Now()
+		os.Chtimes(path, now, now)
+		return BlobInfo{Hash: hash, Size: size, StoredAt: now}, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return BlobInfo{}, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return BlobInfo{}, fmt.Errorf("failed to store blob: %w", err)
+	}
+	return BlobInfo{Hash: hash, Size: size, StoredAt: time.Now()}, nil
+}
+
+// PutBytes stores data and returns its hash
+func (s *BlobStore) PutBytes(data []byte) (string, error) {
+	info, err := s.Put(bytes.NewReader(data), 0)
+	return info.Hash, err
+}
+
+// Open returns a reader for a blob and its size
+func (s *BlobStore) Open(hash string) (io.ReadCloser, int64, error) {
+	if !validBlobHash(hash) {
+		return nil, 0, ErrInvalidBlobHash
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	file, err := os.Open(s.path(hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, 0, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+	return file, info.Size(), nil
+}
+
+// Get reads a whole blob into memory
+func (s *BlobStore) Get(hash string) ([]byte, error) {
+	r, _, err := s.Open(hash)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// Has reports whether a blob is stored
+func (s *BlobStore) Has(hash string) bool {
+	if !validBlobHash(hash) {
+		return false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, err := os.Stat(s.path(hash))
+	return err == nil
+}
+
+// Stats counts the stored blobs and their total size
+func (s *BlobStore) Stats() BlobStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var stats BlobStats
+	s.walk(func(hash string, info fs.FileInfo) {
+		stats.Blobs++
+		stats.Bytes += info.Size()
+	})
+	return stats
+}
+
+// Collect removes blobs stored before cutoff that no transaction references
+func (s *BlobStore) Collect(cutoff time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Without a reference source every blob is considered in use
+	if s.referenced == nil {
+		return 0
+	}
+	referenced := s.referenced()
+
+	removed := 0
+	s.walk(func(hash string, info fs.FileInfo) {
+		if referenced[hash] || !info.ModTime().Before(cutoff) {
+			return
+		}
+		if os.Remove(s.path(hash)) == nil {
+			removed++
+		}
+	})
+	return removed
+}
+
+// walk visits every stored blob
+func (s *BlobStore) walk(fn func(hash string, info fs.FileInfo)) {
+	filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !validBlobHash(d.Name()) {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			fn(d.Name(), info)
+		}
+		return nil
+	})
+}
+
+// path shards blobs by the first byte of their hash
+func (s *BlobStore) path(hash string) string {
+	return filepath.Join(s.dir, hash[:2], hash)
+}
+
+// blobHash returns the hex Blake3 hash that addresses data
+func blobHash(data []byte) string {
+	sum := blake3.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func validBlobHash(hash string) bool {
+	if len(hash) != blobHashLength {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
@@ -0,0 +1,57 @@
+package agglomerator
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlobStoreContentAddressing(t *testing.T) {
+	store, err := NewBlobStore(t.TempDir())
+	require.NoError(t, err)
+
+	hash, err := store.PutBytes([]byte("payload"))
+	require.NoError(t, err)
+	assert.Equal(t, blobHash([]byte("payload")), hash)
+	assert.True(t, store.Has(hash))
+
+	again, err := store.PutBytes([]byte("payload"))
+	require.NoError(t, err)
+	assert.Equal(t, hash, again)
+	assert.Equal(t, BlobStats{Blobs: 1, Bytes: 7}, store.Stats())
+
+	data, err := store.Get(hash)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("payload"), data)
+
+	_, err = store.Get(blobHash([]byte("missing")))
+	assert.ErrorIs(t, err, ErrBlobNotFound)
+	_, err = store.Get("../etc/passwd")
+	assert.ErrorIs(t, err, ErrInvalidBlobHash)
+
+	_, err = store.Put(bytes.NewReader(make([]byte, 100)), 50)
+	assert.ErrorIs(t, err, ErrPayloadTooLarge)
+	assert.Equal(t, 1, store.Stats().Blobs)
+}
+
+func TestBlobStoreCollectsUnreferenced(t *testing.T) {
+	store, err := NewBlobStore(t.TempDir())
+	require.NoError(t, err)
+
+	kept, err := store.PutBytes([]byte("kept"))
+	require.NoError(t, err)
+	orphan, err := store.PutBytes([]byte("orphan"))
+	require.NoError(t, err)
+
+	// Nothing is collected until references can be checked
+	assert.Zero(t, store.Collect(time.Now().Add(time.Second)))
+
+	store.SetReferenced(func() map[string]bool { return map[string]bool{kept: true} })
+	assert.Zero(t, store.Collect(time.Now().Add(-time.Hour)), "recent blobs are kept")
+	assert.Equal(t, 1, store.Collect(time.Now().Add(time.Second)))
+	assert.True(t, store.Has(kept))
+	assert.False(t, store.Has(orphan))
+}
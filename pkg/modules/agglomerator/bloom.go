@@ -0,0 +1,399 @@
+package agglomerator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/theaxiomverse/hydap-api/pkg/keymanagement/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// bloomDataID marks data transfers that carry a node's record filter
+const bloomDataID = "bloom"
+
+// bloomWireVersion is the first wire version that carries record filters;
+// older peers would read them as records
+const bloomWireVersion uint32 = 3
+
+const (
+	maxBloomHashes = 16
+	maxBloomBytes  = 1 << 20
+)
+
+var ErrInvalidBloomFilter = errors.New("invalid bloom filter")
+
+// BloomConfig sizes the record filter a node advertises to its peers
+type BloomConfig struct {
+	Bits     int           // Filter size; more bits mean fewer false positives
+	Hashes   int           // Bits set per record ID
+	Interval time.Duration // How often a changed filter is sent to peers
+}
+
+// DefaultBloomConfig returns the filter used when none is configured: about
+// a 2% false positive rate at 8000 records
+func DefaultBloomConfig() BloomConfig {
+	return BloomConfig{
+		Bits:     1 << 16,
+		Hashes:   4,
+		Interval: 30 * time.Second,
+	}
+}
+
+// BloomStats reports the record filters held and the peer queries they saved
+type BloomStats struct {
+	LocalRecords   uint64 `json:"localRecords"`
+	Generation     uint64 `json:"generation"`
+	PeerFilters    int    `json:"peerFilters"`
+	QueriesSent    uint64 `json:"queriesSent"`
+	QueriesSkipped uint64 `json:"queriesSkipped"`
+}
+
+// BloomFilter is a snapshot of the record IDs a node holds. It may report
+// IDs the node does not hold, never the reverse.
+type BloomFilter struct {
+	Bits       []byte
+	Hashes     int
+	Records    uint64
+	Generation uint64
+}
+
+// MayContain reports whether id may be in the filter
+func (f *BloomFilter) MayContain(id string) bool {
+	if len(f.Bits) == 0 {
+		return false
+	}
+	for _, bit := range bloomBits(id, f.Hashes, uint64(len(f.Bits))*8) {
+		if f.Bits[bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomBits returns the bits an ID sets by double hashing its FNV-1a hash
+func bloomBits(id string, hashes int, size uint64) []uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	sum := h.Sum64()
+	h1, h2 := sum&0xffffffff, sum>>32|1
+
+	bits := make([]uint64, hashes)
+	for k := range bits {
+		bits[k] = (h1 + uint64(k)*h2) % size
+	}
+	return bits
+}
+
+// CountingBloomFilter tracks a changing set of record IDs. Each bit keeps a
+// count so IDs can be removed; counts that saturate are never decremented,
+// which keeps the filter free of false negatives.
+type CountingBloomFilter struct {
+	counts     []uint8
+	hashes     int
+	records    uint64
+	generation uint64
+}
+
+// NewCountingBloomFilter creates an empty filter. Generations start at the
+// current time so a restarted node's filter supersedes the one peers hold.
+func NewCountingBloomFilter(bits, hashes int) *CountingBloomFilter {
+	if bits <= 0 || hashes <= 0 {
+		defaults := DefaultBloomConfig()
+		bits, hashes = defaults.Bits, defaults.Hashes
+	}
+	// Round up to whole bytes, as advertised filters are byte arrays
+	bits = (bits + 7) / 8 * 8
+	return &CountingBloomFilter{
+		counts:     make([]uint8, bits),
+		hashes:     hashes,
+		generation: uint64(time.Now().UnixNano()),
+	}
+}
+
+// Add records an ID
+func (f *CountingBloomFilter) Add(id string) {
+	for _, bit := range bloomBits(id, f.hashes, uint64(len(f.counts))) {
+		if f.counts[bit] < 255 {
+			f.counts[bit]++
+		}
+	}
+	f.records++
+	f.generation++
+}
+
+// Remove forgets an ID previously added
+func (f *CountingBloomFilter) Remove(id string) {
+	for _, bit := range bloomBits(id, f.hashes, uint64(len(f.counts))) {
+		if count := f.counts[bit]; count > 0 && count < 255 {
+			f.counts[bit]--
+		}
+	}
+	if f.records > 0 {
+		f.records--
+	}
+	f.generation++
+}
+
+// Generation changes whenever the filter does
+func (f *CountingBloomFilter) Generation() uint64 {
+	return f.generation
+}
+
+// Filter returns a snapshot of the IDs recorded
+func (f *CountingBloomFilter) Filter() *BloomFilter {
+	bits := make([]byte, len(f.counts)/8)
+	for i, count := range f.counts {
+		if count > 0 {
+			bits[i/8] |= 1 << (i % 8)
+		}
+	}
+	return &BloomFilter{Bits: bits, Hashes: f.hashes, Records: f.records, Generation: f.generation}
+}
+
+func (f *BloomFilter) proto() *pb.BloomFilter {
+	return &pb.BloomFilter{
+		Bits:       f.Bits,
+		Hashes:     uint32(f.Hashes),
+		Records:    f.Records,
+		Generation: f.Generation,
+	}
+}
+
+func bloomFilterFromProto(m *pb.BloomFilter) (*BloomFilter, error) {
+	if n := len(m.GetBits()); n == 0 || n > maxBloomBytes {
+		return nil, fmt.Errorf("%w: %d bytes", ErrInvalidBloomFilter, n)
+	}
+	if hashes := m.GetHashes(); hashes == 0 || hashes > maxBloomHashes {
+		return nil, fmt.Errorf("%w: %d hashes", ErrInvalidBloomFilter, hashes)
+	}
+	return &BloomFilter{
+		Bits:       m.GetBits(),
+		Hashes:     int(m.GetHashes()),
+		Records:    m.GetRecords(),
+		Generation: m.GetGeneration(),
+	}, nil
+}
+
+// PeerFilters holds the record filters peers have advertised and decides
+// which peers a query is sent to
+type PeerFilters struct {
+	mu      sync.Mutex
+	config  BloomConfig
+	filters map[string]*BloomFilter
+	sent    map[string]uint64 // Local filter generation last sent per peer
+	stats   BloomStats
+}
+
+func NewPeerFilters(config BloomConfig) *PeerFilters {
+	return &PeerFilters{
+		config:  config,
+		filters: make(map[string]*BloomFilter),
+		sent:    make(map[string]uint64),
+	}
+}
+
+// Set keeps a peer's filter unless a newer one is already held
+func (p *PeerFilters) Set(peerID string, filter *BloomFilter) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if current, exists := p.filters[peerID]; exists && current.Generation >= filter.Generation {
+		return false
+	}
+	p.filters[peerID] = filter
+	return true
+}
+
+// Get returns the filter a peer advertised
+func (p *PeerFilters) Get(peerID string) (*BloomFilter, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	filter, exists := p.filters[peerID]
+	return filter, exists
+}
+
+// Forget drops a peer's filter
+func (p *PeerFilters) Forget(peerID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.filters, peerID)
+	delete(p.sent, peerID)
+}
+
+// shouldQuery reports whether a peer may hold any of ids, or any records at
+// all when ids is empty. Peers that have not advertised a filter are
+// queried.
+func (p *PeerFilters) shouldQuery(peerID string, ids []string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	query := true
+	if filter, exists := p.filters[peerID]; exists {
+		query = filter.Records > 0 && len(ids) == 0
+		for _, id := range ids {
+			if filter.MayContain(id) {
+				query = true
+				break
+			}
+		}
+	}
+	if query {
+		p.stats.QueriesSent++
+	} else {
+		p.stats.QueriesSkipped++
+	}
+	return query
+}
+
+// needsFilter reports whether a peer has not been sent this generation
+func (p *PeerFilters) needsFilter(peerID string, generation uint64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.sent[peerID] != generation
+}
+
+func (p *PeerFilters) markSent(peerID string, generation uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sent[peerID] = generation
+}
+
+// Config returns the filter settings
+func (p *PeerFilters) Config() BloomConfig {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.config
+}
+
+// SetBloomConfig resizes the node's record filter, rebuilding it from the
+// records held
+func (node *P2PInfiniteVectorNode) SetBloomConfig(config BloomConfig) {
+	node.filters.mu.Lock()
+	node.filters.config = config
+	node.filters.mu.Unlock()
+
+	db := node.localDatabase
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.filter = NewCountingBloomFilter(config.Bits, config.Hashes)
+	for id := range db.records {
+		if !isPrivate(db.records[id].Metadata) {
+			db.filter.Add(id)
+		}
+	}
+}
+
+// PeerFilters returns the record filters advertised by peers
+func (node *P2PInfiniteVectorNode) PeerFilters() *PeerFilters {
+	return node.filters
+}
+
+// BloomStats reports the node's record filter and the queries peer filters
+// saved
+func (node *P2PInfiniteVectorNode) BloomStats() BloomStats {
+	node.filters.mu.Lock()
+	stats := node.filters.stats
+	stats.PeerFilters = len(node.filters.filters)
+	node.filters.mu.Unlock()
+
+	node.localDatabase.mu.RLock()
+	stats.LocalRecords = node.localDatabase.filter.records
+	stats.Generation = node.localDatabase.filter.Generation()
+	node.localDatabase.mu.RUnlock()
+	return stats
+}
+
+// LocateData returns the peers whose filters may hold a record. Peers that
+// have not advertised a filter are included.
+func (node *P2PInfiniteVectorNode) LocateData(id string) []string {
+	node.peerMutex.RLock()
+	defer node.peerMutex.RUnlock()
+
+	var peers []string
+	for peerID := range node.peers {
+		filter, exists := node.filters.Get(peerID)
+		if !exists || filter.MayContain(id) {
+			peers = append(peers, peerID)
+		}
+	}
+	return peers
+}
+
+// advertiseFilters sends the node's record filter to peers whenever it
+// changes
+func (node *P2PInfiniteVectorNode) advertiseFilters(ctx context.Context) {
+	interval := node.filters.Config().Interval
+	if interval <= 0 {
+		interval = DefaultBloomConfig().Interval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			node.sendFilters()
+		}
+	}
+}
+
+// sendFilters sends the current record filter to every peer that has not
+// received it
+func (node *P2PInfiniteVectorNode) sendFilters() {
+	node.localDatabase.mu.RLock()
+	generation := node.localDatabase.filter.Generation()
+	node.localDatabase.mu.RUnlock()
+
+	node.peerMutex.RLock()
+	peers := make([]string, 0, len(node.peers))
+	for peerID := range node.peers {
+		peers = append(peers, peerID)
+	}
+	node.peerMutex.RUnlock()
+
+	var targets []string
+	for _, peerID := range peers {
+		if version, known := node.PeerVersion(peerID); known && version < bloomWireVersion {
+			continue
+		}
+		if node.filters.needsFilter(peerID, generation) {
+			targets = append(targets, peerID)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	node.localDatabase.mu.RLock()
+	filter := node.localDatabase.filter.Filter()
+	node.localDatabase.mu.RUnlock()
+	payload, err := proto.Marshal(filter.proto())
+	if err != nil {
+		return
+	}
+	for _, peerID := range targets {
+		node.send(peerID, bloomDataID, payload, PriorityControl)
+		node.filters.markSent(peerID, filter.Generation)
+	}
+}
+
+// receiveFilter keeps a record filter advertised by a peer
+func (node *P2PInfiniteVectorNode) receiveFilter(peerID string, payload []byte) {
+	var wire pb.BloomFilter
+	if err := proto.Unmarshal(payload, &wire); err != nil {
+		return
+	}
+	filter, err := bloomFilterFromProto(&wire)
+	if err != nil {
+		return
+	}
+	if node.filters.Set(peerID, filter) {
+		// The peer's records changed, so cached answers may be stale
+		node.queryCache.Invalidate()
+	}
+}
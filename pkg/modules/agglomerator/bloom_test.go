@@ -0,0 +1,110 @@
+package agglomerator
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/keymanagement/pb"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCountingBloomFilterAddRemove(t *testing.T) {
+	filter := NewCountingBloomFilter(1024, 4)
+	generation := filter.Generation()
+
+	filter.Add("record-1")
+	filter.Add("record-2")
+	assert.Equal(t, generation+2, filter.Generation(), "each change bumps the generation")
+	snapshot := filter.Filter()
+	assert.Equal(t, uint64(2), snapshot.Records)
+	assert.True(t, snapshot.MayContain("record-1"))
+	assert.True(t, snapshot.MayContain("record-2"))
+
+	filter.Remove("record-1")
+	snapshot = filter.Filter()
+	assert.Equal(t, uint64(1), snapshot.Records)
+	assert.False(t, snapshot.MayContain("record-1"), "removal clears bits no other ID shares")
+	assert.True(t, snapshot.MayContain("record-2"))
+}
+
+func TestBloomFilterFalsePositiveRate(t *testing.T) {
+	config := DefaultBloomConfig()
+	filter := NewCountingBloomFilter(config.Bits, config.Hashes)
+	for i := 0; i < 8000; i++ {
+		filter.Add(fmt.Sprintf("record-%d", i))
+	}
+	snapshot := filter.Filter()
+
+	for i := 0; i < 8000; i++ {
+		require.True(t, snapshot.MayContain(fmt.Sprintf("record-%d", i)), "no false negatives")
+	}
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if snapshot.MayContain(fmt.Sprintf("absent-%d", i)) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, 400, "about 2%% expected")
+}
+
+func TestPeerFiltersSkipPeers(t *testing.T) {
+	filters := NewPeerFilters(DefaultBloomConfig())
+	holder := NewCountingBloomFilter(1024, 4)
+	holder.Add("record-1")
+	require.True(t, filters.Set("holder", holder.Filter()))
+	require.True(t, filters.Set("empty", NewCountingBloomFilter(1024, 4).Filter()))
+
+	assert.True(t, filters.shouldQuery("holder", []string{"record-1"}))
+	assert.False(t, filters.shouldQuery("holder", []string{"record-2"}))
+	assert.False(t, filters.shouldQuery("empty", nil), "peers with no records are skipped")
+	assert.True(t, filters.shouldQuery("holder", nil))
+	assert.True(t, filters.shouldQuery("unknown", []string{"record-2"}), "peers without a filter are queried")
+	assert.Equal(t, uint64(3), filters.stats.QueriesSent)
+	assert.Equal(t, uint64(2), filters.stats.QueriesSkipped)
+
+	stale := holder.Filter()
+	stale.Generation--
+	assert.False(t, filters.Set("holder", stale), "older filters are ignored")
+
+	filters.Forget("holder")
+	_, exists := filters.Get("holder")
+	assert.False(t, exists)
+}
+
+func TestNodeFilterFollowsRecords(t *testing.T) {
+	node := NewP2PInfiniteVectorNode("127.0.0.1", 0)
+	node.SetBloomConfig(BloomConfig{Bits: 1024, Hashes: 4})
+	node.localDatabase.put(vectors.DatabaseRecord{ID: "record-1"})
+	node.localDatabase.put(vectors.DatabaseRecord{ID: "record-1"})
+
+	stats := node.BloomStats()
+	assert.Equal(t, uint64(1), stats.LocalRecords, "re-storing a record does not add it twice")
+
+	node.localDatabase.Collect(time.Now().Add(time.Minute))
+	assert.Zero(t, node.BloomStats().LocalRecords)
+}
+
+func TestReceiveFilter(t *testing.T) {
+	node := NewP2PInfiniteVectorNode("127.0.0.1", 0)
+	node.peers["peer-a"] = &PeerInfo{NodeID: "peer-a"}
+	node.peers["peer-b"] = &PeerInfo{NodeID: "peer-b"}
+
+	advertised := NewCountingBloomFilter(1024, 4)
+	advertised.Add("record-1")
+	payload, err := proto.Marshal(advertised.Filter().proto())
+	require.NoError(t, err)
+	node.receiveFilter("peer-a", payload)
+
+	assert.ElementsMatch(t, []string{"peer-a", "peer-b"}, node.LocateData("record-1"))
+	assert.Equal(t, []string{"peer-b"}, node.LocateData("record-2"), "only peer-b may hold it")
+
+	invalid, err := proto.Marshal(&pb.BloomFilter{Hashes: 4})
+	require.NoError(t, err)
+	node.receiveFilter("peer-b", invalid)
+	_, exists := node.PeerFilters().Get("peer-b")
+	assert.False(t, exists, "filters without bits are rejected")
+}
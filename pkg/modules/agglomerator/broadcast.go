@@ -0,0 +1,349 @@
+package agglomerator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/theaxiomverse/hydap-api/pkg/keymanagement/pb"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+// chainBatchDataID marks data transfers that carry a batch of chain
+// registration records
+const chainBatchDataID = "chains"
+
+// BroadcastConfig paces chain registration gossip
+type BroadcastConfig struct {
+	Interval time.Duration // How long registrations coalesce before a flush
+	MaxBatch int           // Records per batch message
+	Rate     float64       // Batches per second each peer is sent; 0 disables the limit
+	Burst    int           // Batches a peer may be sent back to back
+}
+
+func DefaultBroadcastConfig() BroadcastConfig {
+	return BroadcastConfig{
+		Interval: 250 * time.Millisecond,
+		MaxBatch: 64,
+		Rate:     4,
+		Burst:    4,
+	}
+}
+
+// BroadcastStats counts chain registration gossip
+type BroadcastStats struct {
+	Scheduled uint64 `json:"scheduled"` // Records queued, once per peer
+	Coalesced uint64 `json:"coalesced"` // Queued records replaced before they were sent
+	Batches   uint64 `json:"batches"`
+	Records   uint64 `json:"records"`   // Records sent, once per peer
+	Urgent    uint64 `json:"urgent"`    // Batches sent on the priority lane
+	Throttled uint64 `json:"throttled"` // Flushes that left records queued for a peer's rate
+	Pending   int    `json:"pending"`
+}
+
+// broadcastQueue holds records in the order first queued, keeping only the
+// latest version of each
+type broadcastQueue struct {
+	ids     []string
+	records map[string]*vectors.DatabaseRecord
+}
+
+// put queues a record, reporting whether it replaced a queued version
+func (q *broadcastQueue) put(record *vectors.DatabaseRecord) bool {
+	if q.records == nil {
+		q.records = make(map[string]*vectors.DatabaseRecord)
+	}
+	_, replaced := q.records[record.ID]
+	if !replaced {
+		q.ids = append(q.ids, record.ID)
+	}
+	q.records[record.ID] = record
+	return replaced
+}
+
+// remove drops a queued record, reporting whether there was one
+func (q *broadcastQueue) remove(id string) bool {
+	if _, exists := q.records[id]; !exists {
+		return false
+	}
+	delete(q.records, id)
+	for i, queued := range q.ids {
+		if queued == id {
+			q.ids = append(q.ids[:i], q.ids[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// take removes and returns up to n records from the front of the queue
+func (q *broadcastQueue) take(n int) []*vectors.DatabaseRecord {
+	if n > len(q.ids) {
+		n = len(q.ids)
+	}
+	records := make([]*vectors.DatabaseRecord, 0, n)
+	for _, id := range q.ids[:n] {
+		records = append(records, q.records[id])
+		delete(q.records, id)
+	}
+	q.ids = q.ids[n:]
+	return records
+}
+
+func (q *broadcastQueue) has(id string) bool {
+	_, exists := q.records[id]
+	return exists
+}
+
+func (q *broadcastQueue) len() int {
+	return len(q.ids)
+}
+
+// peerBroadcast is the gossip queued for one peer and its batch budget
+type peerBroadcast struct {
+	urgent  broadcastQueue
+	regular broadcastQueue
+	tokens  float64
+	last    time.Time
+}
+
+// broadcastBatch is a set of records due to be sent to a peer in one message
+type broadcastBatch struct {
+	peerID  string
+	records []*vectors.DatabaseRecord
+	urgent  bool
+}
+
+// ChainBroadcaster coalesces chain registrations into per-peer batches and
+// limits how many batches each peer is sent. Corrections go on a priority
+// lane that is flushed at once and never held back by the limit; like
+// control traffic in the BandwidthManager, they borrow against the peer's
+// future budget instead.
+type ChainBroadcaster struct {
+	mu     sync.Mutex
+	config BroadcastConfig
+	clock  core.Clock
+	peers  map[string]*peerBroadcast
+	stats  BroadcastStats
+	wake   chan struct{} // Signalled when urgent records are queued
+}
+
+func NewChainBroadcaster(config BroadcastConfig) *ChainBroadcaster {
+	return &ChainBroadcaster{
+		config: config,
+		clock:  core.SystemClock,
+		peers:  make(map[string]*peerBroadcast),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// SetConfig replaces the broadcast pacing
+func (b *ChainBroadcaster) SetConfig(config BroadcastConfig) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.config = config
+}
+
+// Config returns the broadcast pacing
+func (b *ChainBroadcaster) Config() BroadcastConfig {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.config
+}
+
+// UseClock sets the time source of the batch budget
+func (b *ChainBroadcaster) UseClock(clock core.Clock) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clock = clock
+}
+
+// Schedule queues a record for each peer, replacing any version of it not
+// yet sent. An urgent record moves to the priority lane and wakes the
+// flush loop.
+func (b *ChainBroadcaster) Schedule(peerIDs []string, record *vectors.DatabaseRecord, urgent bool) {
+	b.mu.Lock()
+	for _, peerID := range peerIDs {
+		peer, exists := b.peers[peerID]
+		if !exists {
+			peer = &peerBroadcast{}
+			b.peers[peerID] = peer
+		}
+
+		var replaced bool
+		switch {
+		case urgent:
+			replaced = peer.regular.remove(record.ID)
+			replaced = peer.urgent.put(record) || replaced
+		case peer.urgent.has(record.ID):
+			// A correction still waiting keeps its place on the priority lane
+			replaced = peer.urgent.put(record)
+		default:
+			replaced = peer.regular.put(record)
+		}
+		b.stats.Scheduled++
+		if replaced {
+			b.stats.Coalesced++
+		}
+	}
+	b.mu.Unlock()
+
+	if urgent {
+		select {
+		case b.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Forget drops the records queued for a peer that has left
+func (b *ChainBroadcaster) Forget(peerID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.peers, peerID)
+}
+
+// due takes the batches that may be sent now: every urgent record and, unless
+// urgentOnly is set, as many regular batches as each peer's budget allows
+func (b *ChainBroadcaster) due(urgentOnly bool) []broadcastBatch {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	maxBatch := b.config.MaxBatch
+	if maxBatch <= 0 {
+		maxBatch = DefaultBroadcastConfig().MaxBatch
+	}
+	now := b.clock.Now()
+
+	var batches []broadcastBatch
+	for peerID, peer := range b.peers {
+		b.refill(peer, now)
+
+		for peer.urgent.len() > 0 {
+			batches = append(batches, broadcastBatch{peerID: peerID, records: peer.urgent.take(maxBatch), urgent: true})
+			peer.tokens--
+			b.stats.Urgent++
+		}
+		if urgentOnly {
+			continue
+		}
+
+		for peer.regular.len() > 0 && (b.config.Rate <= 0 || peer.tokens >= 1) {
+			batches = append(batches, broadcastBatch{peerID: peerID, records: peer.regular.take(maxBatch)})
+			peer.tokens--
+		}
+		if peer.regular.len() > 0 {
+			b.stats.Throttled++
+		}
+	}
+
+	for _, batch := range batches {
+		b.stats.Batches++
+		b.stats.Records += uint64(len(batch.records))
+	}
+	return batches
+}
+
+// refill tops up a peer's batch budget for the time since it was last spent
+func (b *ChainBroadcaster) refill(peer *peerBroadcast, now time.Time) {
+	capacity := float64(b.config.Burst)
+	if capacity <= 0 {
+		capacity = 1
+	}
+	if peer.last.IsZero() {
+		peer.tokens = capacity
+	} else {
+		peer.tokens += now.Sub(peer.last).Seconds() * b.config.Rate
+		if peer.tokens > capacity {
+			peer.tokens = capacity
+		}
+	}
+	peer.last = now
+}
+
+// Stats returns the broadcast counters and the number of records queued
+func (b *ChainBroadcaster) Stats() BroadcastStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := b.stats
+	for _, peer := range b.peers {
+		stats.Pending += peer.urgent.len() + peer.regular.len()
+	}
+	return stats
+}
+
+// Broadcaster returns the node's chain registration scheduler
+func (node *P2PInfiniteVectorNode) Broadcaster() *ChainBroadcaster {
+	return node.broadcaster
+}
+
+// BroadcastRecord stores a chain registration locally and schedules it for
+// the replication peers. Corrections to a record peers may already hold are
+// sent on the priority lane.
+func (node *P2PInfiniteVectorNode) BroadcastRecord(record *vectors.DatabaseRecord, correction bool) {
+	if node.flagEnabled(FlagReplication) {
+		selectedPeers := node.selectReplicationPeers(replicationFactor)
+		peerIDs := make([]string, 0, len(selectedPeers))
+		for _, peer := range selectedPeers {
+			peerIDs = append(peerIDs, peer.NodeID)
+		}
+		node.broadcaster.Schedule(peerIDs, record, correction)
+	}
+
+	node.localDatabase.put(vectors.DatabaseRecord{ID: record.ID, Metadata: record.Metadata, Vector: record.Vector.Copy()})
+}
+
+// broadcastChains sends queued registrations every interval, and urgent ones
+// as soon as they are scheduled
+func (node *P2PInfiniteVectorNode) broadcastChains(ctx context.Context) {
+	interval := node.broadcaster.Config().Interval
+	if interval <= 0 {
+		interval = DefaultBroadcastConfig().Interval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-node.broadcaster.wake:
+			node.flushBroadcast(true)
+		case <-ticker.C:
+			node.flushBroadcast(false)
+		}
+	}
+}
+
+// flushBroadcast sends the batches that are due, one message per batch
+func (node *P2PInfiniteVectorNode) flushBroadcast(urgentOnly bool) {
+	for _, batch := range node.broadcaster.due(urgentOnly) {
+		var payload bytes.Buffer
+		for _, record := range batch.records {
+			wire, err := RecordProto(record, 0)
+			if err != nil {
+				continue
+			}
+			if err := writeFrame(&payload, wire); err != nil {
+				continue
+			}
+		}
+		node.send(batch.peerID, chainBatchDataID, payload.Bytes(), PriorityControl)
+	}
+}
+
+// receiveRecordBatch stores each record of a chain registration batch
+func (node *P2PInfiniteVectorNode) receiveRecordBatch(payload []byte) {
+	reader := bufio.NewReader(bytes.NewReader(payload))
+	for {
+		var replica pb.DatabaseRecord
+		// The payload ends at EOF; a malformed frame ends it early
+		if err := readFrame(reader, &replica, int64(len(payload))); err != nil {
+			return
+		}
+		node.storeReplica(&replica)
+	}
+}
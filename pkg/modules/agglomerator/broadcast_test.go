@@ -0,0 +1,123 @@
+package agglomerator
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+func chainRecord(id, endpoint string) *vectors.DatabaseRecord {
+	return &vectors.DatabaseRecord{ID: id, Metadata: map[string]interface{}{
+		"type":     "chain_registration",
+		"endpoint": endpoint,
+	}}
+}
+
+func batchIDs(batch broadcastBatch) []string {
+	ids := make([]string, len(batch.records))
+	for i, record := range batch.records {
+		ids[i] = record.ID
+	}
+	return ids
+}
+
+func TestChainBroadcasterCoalescesAndPaces(t *testing.T) {
+	clock := core.NewFakeClock(time.Unix(0, 0))
+	broadcaster := NewChainBroadcaster(BroadcastConfig{Interval: time.Second, MaxBatch: 2, Rate: 1, Burst: 2})
+	broadcaster.UseClock(clock)
+
+	for i := 0; i < 5; i++ {
+		broadcaster.Schedule([]string{"peer-a"}, chainRecord(fmt.Sprintf("chain-%d", i), "v1"), false)
+	}
+	broadcaster.Schedule([]string{"peer-a"}, chainRecord("chain-0", "v2"), false)
+	stats := broadcaster.Stats()
+	assert.Equal(t, uint64(1), stats.Coalesced, "a registration replaces its queued version")
+	assert.Equal(t, 5, stats.Pending)
+
+	batches := broadcaster.due(false)
+	require.Len(t, batches, 2, "the burst allows two batches")
+	assert.Equal(t, []string{"chain-0", "chain-1"}, batchIDs(batches[0]), "records keep the order first queued")
+	assert.Equal(t, "v2", batches[0].records[0].Metadata["endpoint"], "only the latest version is sent")
+	assert.Equal(t, []string{"chain-2", "chain-3"}, batchIDs(batches[1]))
+	assert.Equal(t, uint64(1), broadcaster.Stats().Throttled)
+
+	assert.Empty(t, broadcaster.due(false), "the budget is spent")
+	clock.Advance(time.Second)
+	batches = broadcaster.due(false)
+	require.Len(t, batches, 1)
+	assert.Equal(t, []string{"chain-4"}, batchIDs(batches[0]))
+
+	stats = broadcaster.Stats()
+	assert.Equal(t, uint64(3), stats.Batches)
+	assert.Equal(t, uint64(5), stats.Records)
+	assert.Zero(t, stats.Pending)
+}
+
+func TestChainBroadcasterUrgentLane(t *testing.T) {
+	clock := core.NewFakeClock(time.Unix(0, 0))
+	broadcaster := NewChainBroadcaster(BroadcastConfig{Interval: time.Second, MaxBatch: 1, Rate: 1, Burst: 1})
+	broadcaster.UseClock(clock)
+
+	broadcaster.Schedule([]string{"peer-a"}, chainRecord("chain-0", "v1"), false)
+	broadcaster.Schedule([]string{"peer-a"}, chainRecord("chain-1", "v1"), false)
+	require.Len(t, broadcaster.due(false), 1, "spends the only token")
+
+	broadcaster.Schedule([]string{"peer-a"}, chainRecord("chain-2", "v1"), false)
+	broadcaster.Schedule([]string{"peer-a"}, chainRecord("chain-2", "v2"), true)
+	select {
+	case <-broadcaster.wake:
+	default:
+		t.Fatal("a correction wakes the flush loop")
+	}
+
+	batches := broadcaster.due(true)
+	require.Len(t, batches, 1, "corrections are not held back by the rate")
+	assert.True(t, batches[0].urgent)
+	assert.Equal(t, []string{"chain-2"}, batchIDs(batches[0]))
+	assert.Equal(t, "v2", batches[0].records[0].Metadata["endpoint"])
+	assert.Empty(t, broadcaster.due(false), "the correction borrowed the next token")
+
+	clock.Advance(2 * time.Second)
+	batches = broadcaster.due(false)
+	require.Len(t, batches, 1)
+	assert.Equal(t, []string{"chain-1"}, batchIDs(batches[0]), "the queued registration is not sent twice")
+	assert.Equal(t, uint64(1), broadcaster.Stats().Urgent)
+}
+
+func TestBroadcastRecordBatchesToPeers(t *testing.T) {
+	sender := NewP2PInfiniteVectorNode("127.0.0.1", 0)
+	sender.NodeID = "node-a"
+	sender.peers["node-b"] = &PeerInfo{NodeID: "node-b"}
+	receiver := NewP2PInfiniteVectorNode("127.0.0.1", 0)
+	receiver.NodeID = "node-b"
+
+	for i := 0; i < 3; i++ {
+		record := chainRecord(fmt.Sprintf("chain-%d", i), "v1")
+		record.Metadata["zone"] = "claimed"
+		sender.BroadcastRecord(record, false)
+	}
+	assert.Empty(t, sender.controlQueue, "registrations wait for the flush")
+	sender.localDatabase.mu.RLock()
+	assert.Len(t, sender.localDatabase.records, 3, "registrations are stored locally at once")
+	sender.localDatabase.mu.RUnlock()
+
+	sender.flushBroadcast(false)
+	require.Len(t, sender.controlQueue, 1, "one message carries the batch")
+	msg := <-sender.controlQueue
+	assert.Equal(t, chainBatchDataID, msg.DataID)
+
+	receiver.processDataTransfer(msg)
+	receiver.localDatabase.mu.RLock()
+	defer receiver.localDatabase.mu.RUnlock()
+	require.Len(t, receiver.localDatabase.records, 3)
+	for i := 0; i < 3; i++ {
+		metadata := receiver.localDatabase.records[fmt.Sprintf("chain-%d", i)].Metadata
+		assert.Equal(t, "v1", metadata["endpoint"])
+		assert.NotContains(t, metadata, "zone", "plain records cannot claim a zone")
+	}
+}
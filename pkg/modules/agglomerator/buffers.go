@@ -0,0 +1,38 @@
+package agglomerator
+
+import (
+	"sync"
+)
+
+// maxPooledBuffer is the largest buffer returned to the pool; bigger ones,
+// from rare oversized records, are left to the garbage collector so the
+// pool does not pin their memory
+const maxPooledBuffer = 1 << 20
+
+// bufferPool recycles the byte slices frames are encoded into and read
+// from, so replicating records does not allocate a buffer per message
+var bufferPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 0, 4<<10)
+		return &buf
+	},
+}
+
+// getBuffer returns an empty buffer with room for at least size bytes
+func getBuffer(size int) *[]byte {
+	buf := bufferPool.Get().(*[]byte)
+	if cap(*buf) < size {
+		*buf = make([]byte, 0, size)
+	}
+	*buf = (*buf)[:0]
+	return buf
+}
+
+// putBuffer returns a buffer to the pool. Nothing may hold on to its
+// contents afterwards.
+func putBuffer(buf *[]byte) {
+	if cap(*buf) > maxPooledBuffer {
+		return
+	}
+	bufferPool.Put(buf)
+}
@@ -0,0 +1,152 @@
+package agglomerator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/keymanagement/pb"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+func TestFramesDoNotShareBuffers(t *testing.T) {
+	var stream bytes.Buffer
+	reader := bufio.NewReader(&stream)
+
+	require.NoError(t, writeFrame(&stream, DataTransferMessage{DataID: "first", Payload: bytes.Repeat([]byte{1}, 2048)}.proto()))
+	var first pb.Envelope
+	require.NoError(t, readFrame(reader, &first, frameOverhead))
+
+	// The second frame is encoded and read through the buffers the first
+	// one returned to the pool
+	require.NoError(t, writeFrame(&stream, DataTransferMessage{DataID: "second", Payload: bytes.Repeat([]byte{2}, 2048)}.proto()))
+	var second pb.Envelope
+	require.NoError(t, readFrame(reader, &second, frameOverhead))
+
+	assert.Equal(t, bytes.Repeat([]byte{1}, 2048), first.GetPayload(), "decoded payloads do not alias pooled buffers")
+	assert.Equal(t, bytes.Repeat([]byte{2}, 2048), second.GetPayload())
+
+	stream.Write([]byte{0x80})
+	assert.ErrorIs(t, readFrame(reader, &second, frameOverhead), io.ErrUnexpectedEOF, "truncated length prefix")
+}
+
+// benchmarkRecord returns a replicated record carrying about size bytes of
+// metadata
+func benchmarkRecord(id string, size int) vectors.DatabaseRecord {
+	return vectors.DatabaseRecord{
+		ID:     id,
+		Vector: leadingVector(float64(len(id))),
+		Metadata: map[string]interface{}{
+			"type":    "chain_state",
+			"payload": strings.Repeat("x", size),
+		},
+	}
+}
+
+// BenchmarkStoreData replicates records to three peers, encoding each frame
+// as the send loop would
+func BenchmarkStoreData(b *testing.B) {
+	for _, size := range []int{1 << 10, 256 << 10} {
+		b.Run(fmt.Sprintf("%dKB", size>>10), func(b *testing.B) {
+			node := NewP2PInfiniteVectorNode("127.0.0.1", 0)
+			for i := 0; i < 3; i++ {
+				peerID := fmt.Sprintf("peer-%d", i)
+				node.peers[peerID] = &PeerInfo{NodeID: peerID}
+			}
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for msg := range node.bulkQueue {
+					writeFrame(io.Discard, msg.proto())
+				}
+			}()
+
+			record := benchmarkRecord("record", size)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				node.StoreData(record)
+			}
+			b.StopTimer()
+			close(node.bulkQueue)
+			<-done
+		})
+	}
+}
+
+// wireQuerier answers peer queries with records that have been through a
+// frame encode and decode, as they would over a channel
+type wireQuerier struct {
+	records []vectors.DatabaseRecord
+}
+
+func (q *wireQuerier) QueryPeer(ctx context.Context, msg DataTransferMessage) ([]vectors.DatabaseRecord, error) {
+	var stream bytes.Buffer
+	for i := range q.records {
+		wire, err := RecordProto(&q.records[i], 8)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeFrame(&stream, wire); err != nil {
+			return nil, err
+		}
+	}
+	reader := bufio.NewReader(&stream)
+	answers := make([]vectors.DatabaseRecord, 0, len(q.records))
+	for range q.records {
+		var wire pb.DatabaseRecord
+		if err := readFrame(reader, &wire, frameOverhead); err != nil {
+			return nil, err
+		}
+		answers = append(answers, RecordFromProto(&wire))
+	}
+	return answers, nil
+}
+
+// BenchmarkQueryData queries a thousand local records and a peer answering
+// with fifty
+func BenchmarkQueryData(b *testing.B) {
+	node := NewP2PInfiniteVectorNode("127.0.0.1", 0)
+	for i := 0; i < 1000; i++ {
+		require.NoError(b, node.localDatabase.indexSpace.Insert(benchmarkRecord(fmt.Sprintf("local-%d", i), 256)))
+	}
+	querier := &wireQuerier{}
+	for i := 0; i < 50; i++ {
+		querier.records = append(querier.records, benchmarkRecord(fmt.Sprintf("remote-%d", i), 256))
+	}
+	node.UsePeerQuerier(querier)
+	node.peers["peer-a"] = &PeerInfo{NodeID: "peer-a", LastSeen: time.Now()}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		node.QueryData(leadingVector(float64(i%7)), 3)
+	}
+}
+
+// BenchmarkFrameRoundTrip encodes and decodes a full-size chunk envelope
+func BenchmarkFrameRoundTrip(b *testing.B) {
+	envelope := DataTransferMessage{SenderID: "node-a", RecipientID: "node-b", DataID: "record",
+		Payload: make([]byte, DefaultChunkSize), Timestamp: time.Now()}.proto()
+	var stream bytes.Buffer
+	reader := bufio.NewReader(&stream)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(DefaultChunkSize))
+	for i := 0; i < b.N; i++ {
+		if err := writeFrame(&stream, envelope); err != nil {
+			b.Fatal(err)
+		}
+		var decoded pb.Envelope
+		if err := readFrame(reader, &decoded, DefaultChunkSize+frameOverhead); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,388 @@
+package agglomerator
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+// Canary states of a newly registered chain
+const (
+	CanaryWarming     = "warming"     // Offered a fraction of scored routes
+	CanaryPromoted    = "promoted"    // Routed like any other chain
+	CanaryQuarantined = "quarantined" // Out of routing until an operator decides
+)
+
+// Canary defaults
+const (
+	DefaultCanaryFraction       = 0.1
+	DefaultCanaryWarmUp         = 10 * time.Minute
+	DefaultCanaryMinSamples     = 20
+	DefaultCanaryMinSuccessRate = 0.95
+	DefaultCanaryMinHealth      = 0.8
+	DefaultCanaryCheckInterval  = 10 * time.Second
+)
+
+var ErrInvalidCanary = errors.New("invalid chain canary")
+
+// CanaryConfig controls the warm-up of newly registered chains
+type CanaryConfig struct {
+	Fraction       float64       // Share of scored routes offered to a warming chain
+	WarmUp         time.Duration // Least time a chain warms up for
+	MinSamples     int           // Submissions observed before the success rate is judged
+	MinSuccessRate float64       // Below it the chain is quarantined
+	MinHealth      float64       // Chain health below which the chain is quarantined
+	CheckInterval  time.Duration // How often warm-ups are checked for having ended
+}
+
+// DefaultCanaryConfig warms chains up for 10 minutes on a tenth of the
+// traffic
+func DefaultCanaryConfig() CanaryConfig {
+	return CanaryConfig{
+		Fraction:       DefaultCanaryFraction,
+		WarmUp:         DefaultCanaryWarmUp,
+		MinSamples:     DefaultCanaryMinSamples,
+		MinSuccessRate: DefaultCanaryMinSuccessRate,
+		MinHealth:      DefaultCanaryMinHealth,
+		CheckInterval:  DefaultCanaryCheckInterval,
+	}
+}
+
+// parseCanaryConfig reads the canary settings, falling back to the
+// defaults for those unset
+func parseCanaryConfig(moduleConfig *ModuleConfig) (CanaryConfig, error) {
+	settings := moduleConfig.Canary
+	config := DefaultCanaryConfig()
+	if settings.Fraction > 0 {
+		config.Fraction = settings.Fraction
+	}
+	if settings.MinSamples > 0 {
+		config.MinSamples = settings.MinSamples
+	}
+	if settings.MinSuccessRate > 0 {
+		config.MinSuccessRate = settings.MinSuccessRate
+	}
+	if settings.MinHealth > 0 {
+		config.MinHealth = settings.MinHealth
+	}
+	if settings.WarmUp != "" {
+		warmUp, err := parseDuration(settings.WarmUp)
+		if err != nil || warmUp <= 0 {
+			return config, fmt.Errorf("invalid canary warmUp: %s", settings.WarmUp)
+		}
+		config.WarmUp = warmUp
+	}
+	if settings.CheckInterval != "" {
+		interval, err := parseDuration(settings.CheckInterval)
+		if err != nil || interval <= 0 {
+			return config, fmt.Errorf("invalid canary checkInterval: %s", settings.CheckInterval)
+		}
+		config.CheckInterval = interval
+	}
+	return config, nil
+}
+
+// ChainCanary is the warm-up of a newly registered chain and its outcome
+type ChainCanary struct {
+	State       string    `json:"state"`
+	Fraction    float64   `json:"fraction"` // Share of scored routes offered while warming
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"` // When the warm-up may end
+	Submitted   uint64    `json:"submitted"`
+	Failed      uint64    `json:"failed"`
+	SuccessRate float64   `json:"successRate"`
+	Reason      string    `json:"reason,omitempty"` // Why the chain was promoted or quarantined
+}
+
+func (c ChainCanary) String() string {
+	if c.Reason == "" {
+		return c.State
+	}
+	return fmt.Sprintf("%s: %s", c.State, c.Reason)
+}
+
+// chainCanary guards a chain's canary, which submissions update outside
+// the agglomerator's lock
+type chainCanary struct {
+	mu     sync.Mutex
+	canary ChainCanary
+	config CanaryConfig
+	clock  core.Clock // The agglomerator's, judging submissions
+}
+
+func newChainCanary(config CanaryConfig, clock core.Clock) *chainCanary {
+	now := clock.Now()
+	return &chainCanary{
+		canary: ChainCanary{
+			State:       CanaryWarming,
+			Fraction:    config.Fraction,
+			Start:       now,
+			End:         now.Add(config.WarmUp),
+			SuccessRate: 1,
+		},
+		config: config,
+		clock:  clock,
+	}
+}
+
+// Canary returns the chain's warm-up, or nil if it had none
+func (c *Chain) Canary() *ChainCanary {
+	if c.canary == nil {
+		return nil
+	}
+	c.canary.mu.Lock()
+	defer c.canary.mu.Unlock()
+	canary := c.canary.canary
+	return &canary
+}
+
+// offered reports whether a warming chain is offered tx. The choice hashes
+// the transaction and chain IDs, so a transaction planned twice gets the
+// same answer.
+func (c *Chain) offered(tx *Transaction) bool {
+	canary := c.Canary()
+	if canary == nil || canary.State != CanaryWarming {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(tx.ID))
+	h.Write([]byte{0})
+	h.Write([]byte(c.ID))
+	return float64(h.Sum32())/math.MaxUint32 < canary.Fraction
+}
+
+// recordCanary counts a submission to a warming chain, quarantining it at
+// once if enough have failed
+func (c *Chain) recordCanary(err error) {
+	if c.canary == nil {
+		return
+	}
+	c.canary.mu.Lock()
+	canary := &c.canary.canary
+	if canary.State != CanaryWarming {
+		c.canary.mu.Unlock()
+		return
+	}
+	canary.Submitted++
+	if err != nil {
+		canary.Failed++
+	}
+	canary.SuccessRate = 1 - float64(canary.Failed)/float64(canary.Submitted)
+	c.canary.mu.Unlock()
+
+	c.judgeCanary(c.canary.clock.Now())
+}
+
+// judgeCanary promotes or quarantines a warming chain on its success rate
+// and health, reporting whether it did. Chains fail as soon as enough
+// submissions have been observed, but pass only once the warm-up has ended;
+// chains that take submissions wait for enough of them, and chains without
+// an adapter are judged on health alone.
+func (c *Chain) judgeCanary(now time.Time) bool {
+	if c.canary == nil {
+		return false
+	}
+	health := c.Health().Score
+
+	c.canary.mu.Lock()
+	defer c.canary.mu.Unlock()
+
+	canary, config := &c.canary.canary, c.canary.config
+	if canary.State != CanaryWarming {
+		return false
+	}
+	judged := canary.Submitted >= uint64(config.MinSamples)
+	switch {
+	case judged && canary.SuccessRate < config.MinSuccessRate:
+		canary.State = CanaryQuarantined
+		canary.Reason = fmt.Sprintf("success rate %.2f over %d submissions is below %.2f", canary.SuccessRate, canary.Submitted, config.MinSuccessRate)
+	case now.Before(canary.End):
+		return false
+	case health < config.MinHealth:
+		canary.State = CanaryQuarantined
+		canary.Reason = fmt.Sprintf("health %.2f is below %.2f", health, config.MinHealth)
+	case judged:
+		canary.State = CanaryPromoted
+		canary.Reason = fmt.Sprintf("success rate %.2f over %d submissions", canary.SuccessRate, canary.Submitted)
+	case c.adapter == nil:
+		canary.State = CanaryPromoted
+		canary.Reason = fmt.Sprintf("health %.2f; the chain takes no submissions", health)
+	default:
+		return false
+	}
+	decided := *canary
+	c.events.record(EventChainCanary, c.ID, "", ChainCanaryData{Canary: &decided})
+	return true
+}
+
+// holdBackCanaries leaves out of a scored choice the quarantined chains and
+// the warming chains tx is not offered to. Warming chains are kept when no
+// other chain is left. Candidates may be copies of the chains, as when
+// gossiped; the local chains are looked up by ID. The caller holds a.mu.
+func (a *Agglomerator) holdBackCanaries(candidates []*Chain, tx *Transaction) ([]*Chain, []RouteExclusion) {
+	var kept, warming []*Chain
+	var exclusions, heldBack []RouteExclusion
+	for _, candidate := range candidates {
+		chain, exists := a.chains[candidate.ID]
+		if !exists {
+			kept = append(kept, candidate)
+			continue
+		}
+		switch canary := chain.Canary(); {
+		case canary == nil:
+			kept = append(kept, candidate)
+		case canary.State == CanaryQuarantined:
+			exclusions = append(exclusions, RouteExclusion{ChainID: chain.ID, Reason: fmt.Sprintf("%v: %s", ErrChainIncapable, canary)})
+		case !chain.offered(tx):
+			warming = append(warming, candidate)
+			heldBack = append(heldBack, RouteExclusion{ChainID: chain.ID, Reason: fmt.Sprintf("canary warming up on %.0f%% of routes", canary.Fraction*100)})
+		default:
+			kept = append(kept, candidate)
+		}
+	}
+	if len(kept) == 0 {
+		return warming, exclusions
+	}
+	return kept, append(exclusions, heldBack...)
+}
+
+// SetCanary records an operator's decision on a chain: promoted or
+// quarantined, or warming to start its warm-up again with fresh counts
+func (a *Agglomerator) SetCanary(chainID, state, reason string) (ChainCanary, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	chain, exists := a.chains[chainID]
+	if !exists {
+		return ChainCanary{}, ErrChainNotFound
+	}
+	switch state {
+	case CanaryWarming:
+		config := DefaultCanaryConfig()
+		if a.canaries != nil {
+			config = a.canaries.config
+		}
+		chain.canary = newChainCanary(config, a.clock)
+	case CanaryPromoted, CanaryQuarantined:
+		if chain.canary == nil {
+			chain.canary = newChainCanary(DefaultCanaryConfig(), a.clock)
+		}
+	default:
+		return ChainCanary{}, fmt.Errorf("%w: unknown state %q", ErrInvalidCanary, state)
+	}
+
+	chain.canary.mu.Lock()
+	if state != CanaryWarming {
+		chain.canary.canary.State = state
+		chain.canary.canary.Reason = reason
+		if reason == "" {
+			chain.canary.canary.Reason = "set by an operator"
+		}
+	}
+	canary := chain.canary.canary
+	chain.canary.mu.Unlock()
+
+	a.events.record(EventChainCanary, chainID, "", ChainCanaryData{Canary: &canary})
+	return canary, nil
+}
+
+// restoreCanary puts back a warm-up read from the event log. Warming chains
+// resume with the current settings and no counts.
+func (a *Agglomerator) restoreCanary(chainID string, canary ChainCanary) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	chain, exists := a.chains[chainID]
+	if !exists {
+		return
+	}
+	config := DefaultCanaryConfig()
+	if a.canaries != nil {
+		config = a.canaries.config
+	}
+	canary.Submitted, canary.Failed, canary.SuccessRate = 0, 0, 1
+	chain.canary = &chainCanary{canary: canary, config: config, clock: a.clock}
+}
+
+// ApplyCanaries judges the warming chains, returning those promoted or
+// quarantined
+func (a *Agglomerator) ApplyCanaries() map[string]ChainCanary {
+	now := a.clock.Now()
+	decided := make(map[string]ChainCanary)
+	for _, chain := range a.ListChains() {
+		if chain.judgeCanary(now) {
+			decided[chain.ID] = *chain.Canary()
+		}
+	}
+	return decided
+}
+
+// canaryChecks holds the canary settings and stops the background checks
+type canaryChecks struct {
+	config CanaryConfig
+	stop   chan struct{}
+}
+
+// StartCanaries warms up the chains registered from now on, judging them
+// every config.CheckInterval
+func (a *Agglomerator) StartCanaries(config CanaryConfig) error {
+	if config.Fraction <= 0 || config.Fraction > 1 {
+		return fmt.Errorf("canary fraction must be above 0 and at most 1")
+	}
+	if config.CheckInterval <= 0 {
+		return fmt.Errorf("canary check interval must be positive")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.canaries != nil {
+		return fmt.Errorf("canaries already running")
+	}
+	checks := &canaryChecks{config: config, stop: make(chan struct{})}
+	a.canaries = checks
+	go a.runCanaryChecks(checks, a.clock.NewTicker(config.CheckInterval))
+	return nil
+}
+
+// StopCanaries stops warming up new chains and judging warming ones
+func (a *Agglomerator) StopCanaries() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.canaries != nil {
+		close(a.canaries.stop)
+		a.canaries = nil
+	}
+}
+
+func (a *Agglomerator) runCanaryChecks(checks *canaryChecks, ticker core.Ticker) {
+	defer ticker.Stop()
+	for {
+		select {
+		case <-checks.stop:
+			return
+		case <-ticker.C():
+			a.ApplyCanaries()
+		}
+	}
+}
+
+// startCanary puts a newly registered chain in warm-up if canaries are
+// running
+func (a *Agglomerator) startCanary(chain *Chain) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.canaries == nil || a.chains[chain.ID] != chain {
+		return
+	}
+	chain.canary = newChainCanary(a.canaries.config, a.clock)
+	canary := chain.canary.canary
+	a.events.record(EventChainCanary, chain.ID, "", ChainCanaryData{Canary: &canary})
+}
@@ -0,0 +1,111 @@
+package agglomerator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+func newCanaryAgglomerator(t *testing.T, clock core.Clock) *Agglomerator {
+	agg := newMaintenanceAgglomerator(t, clock)
+	log, err := NewEventLog(nil)
+	require.NoError(t, err)
+	agg.SetEventLog(log)
+	require.NoError(t, agg.StartCanaries(CanaryConfig{
+		Fraction:       0.25,
+		WarmUp:         time.Hour,
+		MinSamples:     5,
+		MinSuccessRate: 0.9,
+		MinHealth:      0.8,
+		CheckInterval:  time.Minute,
+	}))
+	t.Cleanup(agg.StopCanaries)
+	return agg
+}
+
+func TestCanaryWarmUp(t *testing.T) {
+	clock := core.NewFakeClock(time.Unix(0, 0))
+	agg := newCanaryAgglomerator(t, clock)
+	require.NoError(t, agg.RegisterChain(NewChain("fresh", "http://localhost:8546", ProtocolEthereum)))
+
+	fresh, err := agg.GetChain("fresh")
+	require.NoError(t, err)
+	require.NotNil(t, fresh.Canary())
+	assert.Equal(t, CanaryWarming, fresh.Canary().State)
+	assert.Equal(t, clock.Now().Add(time.Hour), fresh.Canary().End)
+	spare, _ := agg.GetChain("spare")
+	assert.Nil(t, spare.Canary(), "chains registered before canaries started are established")
+
+	agg.mu.RLock()
+	offered := 0
+	for i := 0; i < 1000; i++ {
+		candidates, _ := agg.holdBackCanaries([]*Chain{spare, fresh}, &Transaction{ID: fmt.Sprintf("tx-%d", i)})
+		if len(candidates) == 2 {
+			offered++
+		}
+	}
+	alone, heldBack := agg.holdBackCanaries([]*Chain{fresh}, &Transaction{ID: "tx-alone"})
+	agg.mu.RUnlock()
+	assert.InDelta(t, 250, offered, 50, "a quarter of the routes are offered to the canary")
+	assert.Equal(t, []*Chain{fresh}, alone, "a canary is kept when no other chain is left")
+	assert.Empty(t, heldBack)
+	assert.NoError(t, routeTo(agg, "tx-requested", "fresh"), "requested routes are not held back")
+
+	// Re-registering keeps the warm-up going
+	require.NoError(t, agg.RegisterChain(NewChain("fresh", "http://localhost:8547", ProtocolEthereum)))
+	fresh, _ = agg.GetChain("fresh")
+	assert.Equal(t, CanaryWarming, fresh.Canary().State)
+
+	assert.Empty(t, agg.ApplyCanaries(), "the warm-up has not ended")
+	clock.Advance(time.Hour)
+	decided := agg.ApplyCanaries()
+	require.Contains(t, decided, "fresh")
+	assert.Equal(t, CanaryPromoted, decided["fresh"].State)
+	assert.Contains(t, decided["fresh"].Reason, "takes no submissions", "chains without an adapter are judged on health")
+
+	state, err := agg.EventLog().State(time.Time{})
+	require.NoError(t, err)
+	require.NotNil(t, state.Chains["fresh"].Canary)
+	assert.Equal(t, CanaryPromoted, state.Chains["fresh"].Canary.State)
+}
+
+func TestCanaryQuarantine(t *testing.T) {
+	agg := newCanaryAgglomerator(t, core.NewFakeClock(time.Unix(0, 0)))
+	require.NoError(t, agg.RegisterChain(NewChain("flaky", "mock://flaky?blockTime=1ms&failureRate=1", ProtocolMock)))
+
+	for i := 0; i < 5; i++ {
+		err := agg.ProcessTransaction(context.Background(), &Transaction{ID: fmt.Sprintf("tx-%d", i), FromChain: "source", ToChain: "flaky"})
+		assert.ErrorIs(t, err, ErrMockSubmitFailed)
+	}
+	flaky, _ := agg.GetChain("flaky")
+	canary := flaky.Canary()
+	assert.Equal(t, CanaryQuarantined, canary.State, "failing chains are quarantined before the warm-up ends")
+	assert.Equal(t, uint64(5), canary.Failed)
+	assert.Contains(t, canary.Reason, "success rate 0.00 over 5 submissions")
+	assert.ErrorIs(t, routeTo(agg, "tx-next", "flaky"), ErrChainIncapable)
+
+	spare, _ := agg.GetChain("spare")
+	agg.mu.RLock()
+	candidates, exclusions := agg.holdBackCanaries([]*Chain{spare, flaky}, &Transaction{ID: "tx-next"})
+	agg.mu.RUnlock()
+	assert.Equal(t, []*Chain{spare}, candidates)
+	require.Len(t, exclusions, 1)
+	assert.Equal(t, "flaky", exclusions[0].ChainID)
+
+	_, err := agg.SetCanary("flaky", "paused", "")
+	assert.ErrorIs(t, err, ErrInvalidCanary)
+	_, err = agg.SetCanary("missing", CanaryPromoted, "")
+	assert.ErrorIs(t, err, ErrChainNotFound)
+	warming, err := agg.SetCanary("flaky", CanaryWarming, "")
+	require.NoError(t, err)
+	assert.Zero(t, warming.Submitted, "a new warm-up starts with fresh counts")
+	promoted, err := agg.SetCanary("flaky", CanaryPromoted, "fixed the RPC node")
+	require.NoError(t, err)
+	assert.Equal(t, "fixed the RPC node", promoted.Reason)
+	assert.NoError(t, routeTo(agg, "tx-next", "flaky"))
+}
@@ -0,0 +1,139 @@
+package agglomerator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrChainIncapable      = errors.New("chain cannot serve transaction")
+	ErrInvalidCapabilities = errors.New("invalid chain capabilities")
+)
+
+// MaintenanceWindow is a period in which a chain takes no transactions
+type MaintenanceWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Active reports whether now falls within the window
+func (w MaintenanceWindow) Active(now time.Time) bool {
+	return !now.Before(w.Start) && now.Before(w.End)
+}
+
+// ChainCapabilities describes the transactions a chain can serve. They are
+// gossiped with its registration so peers exclude it from routes it would
+// only fail on.
+type ChainCapabilities struct {
+	Assets      []string            `json:"assets,omitempty"`    // Asset symbols accepted; empty accepts any
+	MinTxSize   int                 `json:"minTxSize,omitempty"` // Bytes of transaction data
+	MaxTxSize   int                 `json:"maxTxSize,omitempty"` // 0 is unlimited
+	Maintenance []MaintenanceWindow `json:"maintenance,omitempty"`
+}
+
+// IsZero reports whether the chain declared no capabilities, so serves
+// every transaction
+func (c ChainCapabilities) IsZero() bool {
+	return len(c.Assets) == 0 && c.MinTxSize == 0 && c.MaxTxSize == 0 && len(c.Maintenance) == 0
+}
+
+// Validate checks the sizes and windows are well formed
+func (c ChainCapabilities) Validate() error {
+	if c.MinTxSize < 0 || c.MaxTxSize < 0 {
+		return fmt.Errorf("%w: negative transaction size", ErrInvalidCapabilities)
+	}
+	if c.MaxTxSize > 0 && c.MaxTxSize < c.MinTxSize {
+		return fmt.Errorf("%w: maxTxSize %d below minTxSize %d", ErrInvalidCapabilities, c.MaxTxSize, c.MinTxSize)
+	}
+	for _, window := range c.Maintenance {
+		if !window.End.After(window.Start) {
+			return fmt.Errorf("%w: maintenance window ends before it starts", ErrInvalidCapabilities)
+		}
+	}
+	return nil
+}
+
+// Serves returns nil if a chain with these capabilities can take tx at now,
+// or an error wrapping ErrChainIncapable saying why not
+func (c ChainCapabilities) Serves(tx *Transaction, now time.Time) error {
+	if tx.Asset != "" && len(c.Assets) > 0 && !c.supportsAsset(tx.Asset) {
+		return fmt.Errorf("%w: asset %s not supported", ErrChainIncapable, tx.Asset)
+	}
+	if size := len(tx.Data); size < c.MinTxSize {
+		return fmt.Errorf("%w: %d bytes below minimum of %d", ErrChainIncapable, size, c.MinTxSize)
+	} else if c.MaxTxSize > 0 && size > c.MaxTxSize {
+		return fmt.Errorf("%w: %d bytes above maximum of %d", ErrChainIncapable, size, c.MaxTxSize)
+	}
+	for _, window := range c.Maintenance {
+		if window.Active(now) {
+			return fmt.Errorf("%w: in maintenance until %s", ErrChainIncapable, window.End.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+func (c ChainCapabilities) supportsAsset(symbol string) bool {
+	for _, asset := range c.Assets {
+		if strings.EqualFold(asset, symbol) {
+			return true
+		}
+	}
+	return false
+}
+
+// metadata returns the capabilities as a record metadata value
+func (c ChainCapabilities) metadata() map[string]interface{} {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil
+	}
+	var value map[string]interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil
+	}
+	return value
+}
+
+// capabilitiesFromMetadata reads the capabilities a peer gossiped with a
+// chain registration. Records from peers that send none, or send them
+// malformed, leave the chain unrestricted.
+func capabilitiesFromMetadata(metadata map[string]interface{}) ChainCapabilities {
+	var capabilities ChainCapabilities
+	value, exists := metadata["capabilities"]
+	if !exists {
+		return capabilities
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return capabilities
+	}
+	if err := json.Unmarshal(data, &capabilities); err != nil || capabilities.Validate() != nil {
+		return ChainCapabilities{}
+	}
+	return capabilities
+}
+
+// RouteExclusion is a chain left out of routing because it cannot serve
+// the transaction
+type RouteExclusion struct {
+	ChainID string `json:"chainId"`
+	Reason  string `json:"reason"`
+}
+
+// excludeIncapable returns the chains able to serve tx at now, and why each
+// of the others, lacking the capabilities or in maintenance, was left out
+func excludeIncapable(chains []*Chain, tx *Transaction, now time.Time) ([]*Chain, []RouteExclusion) {
+	var exclusions []RouteExclusion
+	capable := chains[:0]
+	for _, chain := range chains {
+		if err := chain.serves(tx, now); err != nil {
+			exclusions = append(exclusions, RouteExclusion{ChainID: chain.ID, Reason: err.Error()})
+			continue
+		}
+		capable = append(capable, chain)
+	}
+	return capable, exclusions
+}
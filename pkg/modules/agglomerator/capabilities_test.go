@@ -0,0 +1,117 @@
+package agglomerator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+func TestChainCapabilitiesServes(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	capabilities := ChainCapabilities{
+		Assets:    []string{"USDC", "eth"},
+		MinTxSize: 2,
+		MaxTxSize: 8,
+		Maintenance: []MaintenanceWindow{
+			{Start: now.Add(time.Hour), End: now.Add(2 * time.Hour)},
+		},
+	}
+	require.NoError(t, capabilities.Validate())
+
+	for name, test := range map[string]struct {
+		tx      Transaction
+		at      time.Time
+		serves  bool
+		message string
+	}{
+		"fits":              {tx: Transaction{Asset: "ETH", Data: []byte("data")}, at: now, serves: true},
+		"no asset":          {tx: Transaction{Data: []byte("data")}, at: now, serves: true},
+		"unsupported asset": {tx: Transaction{Asset: "DOT", Data: []byte("data")}, at: now, message: "asset DOT not supported"},
+		"too small":         {tx: Transaction{Data: []byte("d")}, at: now, message: "1 bytes below minimum of 2"},
+		"too large":         {tx: Transaction{Data: []byte("too much data")}, at: now, message: "13 bytes above maximum of 8"},
+		"in maintenance":    {tx: Transaction{Data: []byte("data")}, at: now.Add(90 * time.Minute), message: "in maintenance until 2026-01-01T14:00:00Z"},
+		"after maintenance": {tx: Transaction{Data: []byte("data")}, at: now.Add(2 * time.Hour), serves: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := capabilities.Serves(&test.tx, test.at)
+			if test.serves {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrChainIncapable)
+			assert.ErrorContains(t, err, test.message)
+		})
+	}
+
+	assert.NoError(t, ChainCapabilities{}.Serves(&Transaction{Asset: "DOT", Data: make([]byte, 1<<20)}, now), "chains without capabilities serve anything")
+	assert.ErrorIs(t, ChainCapabilities{MinTxSize: 8, MaxTxSize: 4}.Validate(), ErrInvalidCapabilities)
+	assert.ErrorIs(t, ChainCapabilities{Maintenance: []MaintenanceWindow{{Start: now, End: now}}}.Validate(), ErrInvalidCapabilities)
+}
+
+func TestCapabilitiesGossipedWithRegistration(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	capabilities := ChainCapabilities{
+		Assets:      []string{"USDC"},
+		MaxTxSize:   1024,
+		Maintenance: []MaintenanceWindow{{Start: start, End: start.Add(time.Hour)}},
+	}
+	record := vectors.DatabaseRecord{ID: "eth-main", Metadata: map[string]interface{}{
+		"protocol":     ProtocolEthereum,
+		"endpoint":     "http://localhost:8545",
+		"type":         "chain_registration",
+		"capabilities": capabilities.metadata(),
+	}}
+
+	wire, err := RecordProto(&record, 0)
+	require.NoError(t, err)
+	chain := chainFromRecord(RecordFromProto(wire))
+	assert.Equal(t, capabilities, chain.Capabilities)
+
+	record.Metadata["capabilities"] = map[string]interface{}{"minTxSize": 10.0, "maxTxSize": 5.0}
+	assert.True(t, chainFromRecord(record).Capabilities.IsZero(), "malformed capabilities are ignored")
+	delete(record.Metadata, "capabilities")
+	assert.True(t, chainFromRecord(record).Capabilities.IsZero(), "registrations from older peers carry none")
+}
+
+func TestRoutingExcludesIncapableChains(t *testing.T) {
+	agg := NewAgglomerator(AgglomeratorConfig{})
+	for id, capabilities := range map[string]ChainCapabilities{
+		"source": {},
+		"small":  {MaxTxSize: 4},
+		"usdc":   {Assets: []string{"USDC"}},
+	} {
+		chain := NewChain(id, "http://localhost:8545", ProtocolEthereum)
+		chain.Capabilities = capabilities
+		require.NoError(t, agg.RegisterChain(chain))
+	}
+	newTx := func(id, to, asset string, data []byte) *Transaction {
+		return &Transaction{ID: id, FromChain: "source", ToChain: to, Asset: asset, Data: data,
+			StateVector: vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}}
+	}
+
+	_, err := agg.recordTransaction(newTx("tx-1", "small", "", []byte("too large")))
+	assert.ErrorIs(t, err, ErrChainIncapable)
+	_, err = agg.recordTransaction(newTx("tx-2", "usdc", "ETH", nil))
+	assert.ErrorIs(t, err, ErrChainIncapable)
+	_, err = agg.recordTransaction(newTx("tx-3", "usdc", "USDC", []byte("too large")))
+	assert.NoError(t, err)
+
+	chains := agg.ListChains()
+	capable, excluded := excludeIncapable(chains, newTx("tx-4", "", "ETH", []byte("too large")), time.Now())
+	require.Len(t, capable, 1)
+	assert.Equal(t, "source", capable[0].ID)
+	require.Len(t, excluded, 2)
+	assert.ElementsMatch(t, []string{"small", "usdc"}, []string{excluded[0].ChainID, excluded[1].ChainID})
+}
+
+func TestRegisterChainRejectsInvalidCapabilities(t *testing.T) {
+	agg := NewAgglomerator(AgglomeratorConfig{})
+	chain := NewChain("eth", "http://localhost:8545", ProtocolEthereum)
+	chain.Capabilities = ChainCapabilities{MinTxSize: -1}
+	assert.ErrorIs(t, agg.RegisterChain(chain), ErrInvalidCapabilities)
+	_, err := agg.GetChain("eth")
+	assert.ErrorIs(t, err, ErrChainNotFound)
+}
@@ -0,0 +1,67 @@
+package agglomerator
+
+import (
+	"math"
+)
+
+const (
+	// headLagGrace is how many block times a head may go without advancing
+	// before the chain loses health for it; past the grace, health falls by
+	// a factor of e every headLagScale block times
+	headLagGrace = 3
+	headLagScale = 10
+)
+
+// ChainHealth scores how well a chain's endpoints are serving it, from the
+// RPC error rate and how far the head lags behind the blocks the protocol
+// should have produced. Routing weighs it in so that transactions drain
+// away from degraded chains before their endpoints are taken out of
+// rotation.
+type ChainHealth struct {
+	Score     float64 `json:"score"`     // 1 when healthy, falling toward 0
+	Endpoint  string  `json:"endpoint"`  // The healthiest endpoint, which the score is of
+	ErrorRate float64 `json:"errorRate"` // Moving average of its failed requests and probes
+	HeadLag   float64 `json:"headLag"`   // Block times its head went without advancing
+}
+
+// Health scores the chain by its healthiest endpoint, as failover submits
+// through the others. Chains without endpoints are healthy.
+func (c *Chain) Health() ChainHealth {
+	if c.endpoints == nil {
+		return ChainHealth{Score: 1}
+	}
+	protocol := c.Protocol
+	if protocol == "" {
+		protocol = determineProtocol(c.ID)
+	}
+	var blockTime float64
+	if config, exists := getProtocolConfig(protocol); exists {
+		blockTime = config.BlockTime
+	}
+	return c.endpoints.health(blockTime)
+}
+
+// health scores the pool's healthiest endpoint. Head lag is only measured
+// when blockTime, in seconds, is known.
+func (p *EndpointPool) health(blockTime float64) ChainHealth {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if len(p.endpoints) == 0 {
+		return ChainHealth{Score: 1}
+	}
+	best := ChainHealth{Score: -1}
+	for _, e := range p.endpoints {
+		health := ChainHealth{Endpoint: e.status.URL, ErrorRate: e.status.ErrorRate}
+		if blockTime > 0 {
+			health.HeadLag = e.headSampledAt.Sub(e.headAdvancedAt).Seconds() / blockTime
+		}
+		if e.status.Healthy {
+			health.Score = (1 - health.ErrorRate) * math.Exp(-math.Max(0, health.HeadLag-headLagGrace)/headLagScale)
+		}
+		if health.Score > best.Score {
+			best = health
+		}
+	}
+	return best
+}
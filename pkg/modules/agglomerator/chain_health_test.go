@@ -0,0 +1,70 @@
+package agglomerator
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+func TestChainHealth(t *testing.T) {
+	pool := NewEndpointPool([]string{"mock://primary", "mock://backup"}, func(context.Context, string) error { return nil })
+	pool.endpoints[0].adapter = &stubAdapter{}
+	pool.endpoints[1].adapter = &stubAdapter{}
+	assert.Equal(t, ChainHealth{Score: 1, Endpoint: "mock://primary"}, pool.health(1))
+
+	// Failures lower health before the endpoint leaves rotation
+	pool.Report("mock://primary", time.Millisecond, errors.New("timeout"))
+	pool.Report("mock://backup", time.Millisecond, errors.New("timeout"))
+	pool.Report("mock://backup", time.Millisecond, nil)
+	health := pool.health(1)
+	assert.Equal(t, "mock://backup", health.Endpoint, "the healthiest endpoint scores the chain")
+	assert.InDelta(t, 0.16, health.ErrorRate, 1e-9)
+	assert.InDelta(t, 0.84, health.Score, 1e-9)
+	assert.True(t, pool.Status()[0].Healthy)
+
+	// Probes watch the head, which the stub never advances
+	pool.Probe(context.Background(), time.Second)
+	assert.Equal(t, uint64(1), pool.Status()[0].BlockHeight)
+	for _, e := range pool.endpoints {
+		e.headAdvancedAt = e.headSampledAt.Add(-13 * time.Second)
+	}
+	health = pool.health(1)
+	assert.InDelta(t, 13, health.HeadLag, 1e-9)
+	assert.InDelta(t, (1-health.ErrorRate)*math.Exp(-1), health.Score, 1e-9, "lag past the grace costs health")
+	assert.Zero(t, pool.health(0).HeadLag, "lag is not judged without a block time")
+
+	pool.SetFailureThreshold(1)
+	pool.Report("mock://primary", time.Millisecond, errors.New("timeout"))
+	pool.Report("mock://backup", time.Millisecond, errors.New("timeout"))
+	assert.Zero(t, pool.health(1).Score, "endpoints out of rotation have no health")
+}
+
+func TestHealthWeightedRouting(t *testing.T) {
+	vector := vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}
+	degraded := NewChain("eth-a", "http://localhost:8545", ProtocolEthereum)
+	healthy := NewChain("eth-b", "http://localhost:8546", ProtocolEthereum)
+	degraded.StateVector, healthy.StateVector = vector, vector
+	require.NoError(t, degraded.attachEndpoints())
+	require.NoError(t, healthy.attachEndpoints())
+	tx := &Transaction{ID: "tx-1", FromChain: "eth-a", ToChain: "eth-b", StateVector: vector}
+
+	assert.Equal(t, evaluateRoute(calculateRouteMetrics(degraded, tx, DefaultCompareDims)),
+		evaluateRoute(calculateRouteMetrics(healthy, tx, DefaultCompareDims)))
+
+	degraded.EndpointPool().Report("http://localhost:8545", time.Millisecond, errors.New("timeout"))
+	require.True(t, degraded.EndpointPool().Status()[0].Healthy)
+	route := findOptimalRoute([]*Chain{degraded, healthy}, tx, DefaultCompareDims)
+	require.Len(t, route, 1)
+	assert.Equal(t, "eth-b", route[0].ID, "routing drains away from the degraded chain")
+
+	explanation := explainRoute(tx, RouteModeScored, []*Chain{degraded, healthy}, []string{"eth-b"}, DefaultCompareDims,
+		func(string) bool { return true })
+	factors := explanation.Candidates[1].Factors
+	assert.Equal(t, RouteFactor{Name: "health", Value: 0.8, Weight: healthWeight, Contribution: 0.8 * healthWeight}, factors[len(factors)-1])
+}
@@ -0,0 +1,361 @@
+package agglomerator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Chain registry defaults
+const (
+	DefaultChainRegistryInterval = 6 * time.Hour
+	DefaultChainRegistryProtocol = ProtocolEthereum // chainlist lists EVM chains
+	DefaultRegistryEndpoints     = 3
+
+	chainRegistryTimeout = 30 * time.Second
+	maxChainRegistrySize = 64 << 20 // chainlist's full list is a few MB
+)
+
+// ErrChainRegistryUnavailable is returned when the registry cannot be
+// fetched or read
+var ErrChainRegistryUnavailable = errors.New("chain registry unavailable")
+
+// ChainRegistryConfig selects the chains imported from a chainlist-style
+// registry and how they are registered
+type ChainRegistryConfig struct {
+	URL          string
+	Interval     time.Duration
+	ChainIDs     []int64           // Imported entries; every entry when empty
+	Protocol     string            // Of entries naming none
+	Protocols    map[string]string // Overrides by numeric chain ID
+	IDPrefix     string            // Prepended to each entry's short name
+	MaxEndpoints int               // RPC endpoints kept per chain
+}
+
+// parseChainRegistryConfig reads the chain registry settings, falling back
+// to the defaults for those unset
+func parseChainRegistryConfig(moduleConfig *ModuleConfig) (ChainRegistryConfig, error) {
+	settings := moduleConfig.ChainRegistry
+	config := ChainRegistryConfig{
+		URL:          settings.URL,
+		Interval:     DefaultChainRegistryInterval,
+		ChainIDs:     settings.ChainIDs,
+		Protocol:     settings.Protocol,
+		Protocols:    settings.Protocols,
+		IDPrefix:     settings.IDPrefix,
+		MaxEndpoints: settings.MaxEndpoints,
+	}
+	if settings.Interval != "" {
+		interval, err := parseDuration(settings.Interval)
+		if err != nil {
+			return config, fmt.Errorf("invalid chainRegistry interval: %w", err)
+		}
+		config.Interval = interval
+	}
+	if config.Protocol == "" {
+		config.Protocol = DefaultChainRegistryProtocol
+	}
+	if config.MaxEndpoints <= 0 {
+		config.MaxEndpoints = DefaultRegistryEndpoints
+	}
+	return config, nil
+}
+
+// RegistryChain is one entry of a chainlist-style registry, such as
+// https://chainid.network/chains.json or https://chainlist.org/rpcs.json
+type RegistryChain struct {
+	Name      string        `json:"name"`
+	ChainID   int64         `json:"chainId"`
+	ShortName string        `json:"shortName"`
+	RPC       []registryRPC `json:"rpc"`
+	Status    string        `json:"status,omitempty"`   // Deprecated entries are skipped
+	Protocol  string        `json:"protocol,omitempty"` // Not in chainlist; lets other registries list non-EVM chains
+}
+
+// registryRPC is an RPC URL, listed as a string or as an object with a url
+type registryRPC string
+
+func (r *registryRPC) UnmarshalJSON(data []byte) error {
+	var rpc string
+	if err := json.Unmarshal(data, &rpc); err == nil {
+		*r = registryRPC(rpc)
+		return nil
+	}
+	var object struct {
+		URL string `json:"url"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return fmt.Errorf("rpc must be a URL or an object with a url")
+	}
+	*r = registryRPC(object.URL)
+	return nil
+}
+
+// ChainImportSkip is a registry entry that was not imported
+type ChainImportSkip struct {
+	ChainID int64  `json:"chainId"`
+	Name    string `json:"name"`
+	Reason  string `json:"reason"`
+}
+
+// ChainImportReport is the outcome of one import from a chain registry
+type ChainImportReport struct {
+	Source      string            `json:"source"`
+	ImportedAt  time.Time         `json:"importedAt"`
+	NotModified bool              `json:"notModified,omitempty"` // The registry was unchanged since the last import
+	Entries     int               `json:"entries"`               // Entries selected from the registry
+	Registered  []string          `json:"registered"`
+	Updated     []string          `json:"updated"`
+	Unchanged   int               `json:"unchanged"`
+	Skipped     []ChainImportSkip `json:"skipped,omitempty"`
+}
+
+// ChainImporter registers the chains of a chainlist-style registry, and
+// registers them again when their protocol or RPC endpoints change. Chains
+// keep the zone and capabilities they were registered with, and chains
+// that leave the registry stay registered.
+type ChainImporter struct {
+	module *AgglomeratorModule
+	config ChainRegistryConfig
+	client *http.Client
+
+	mu           sync.Mutex // Held for the length of an import
+	etag         string
+	lastModified string
+	last         *ChainImportReport
+	stop         chan struct{}
+}
+
+func NewChainImporter(module *AgglomeratorModule, config ChainRegistryConfig) *ChainImporter {
+	return &ChainImporter{
+		module: module,
+		config: config,
+		client: &http.Client{Timeout: chainRegistryTimeout},
+	}
+}
+
+// Config returns the importer's settings
+func (i *ChainImporter) Config() ChainRegistryConfig {
+	return i.config
+}
+
+// LastReport returns the report of the last successful import, or false
+// if there has been none
+func (i *ChainImporter) LastReport() (ChainImportReport, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.last == nil {
+		return ChainImportReport{}, false
+	}
+	return *i.last, true
+}
+
+// Import fetches the registry and registers its selected chains
+func (i *ChainImporter) Import(ctx context.Context) (ChainImportReport, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	report := ChainImportReport{
+		Source:     i.config.URL,
+		ImportedAt: time.Now().UTC(),
+		Registered: make([]string, 0),
+		Updated:    make([]string, 0),
+	}
+	entries, err := i.fetch(ctx)
+	if err != nil {
+		return report, err
+	}
+	if entries == nil {
+		report.NotModified = true
+		i.last = &report
+		return report, nil
+	}
+	agg := i.module.GetAgglomerator()
+	if agg == nil {
+		return report, errors.New("agglomerator not initialized")
+	}
+
+	for _, entry := range i.selected(entries) {
+		report.Entries++
+		declared, reason := i.chainOf(entry)
+		if reason != "" {
+			report.Skipped = append(report.Skipped, ChainImportSkip{ChainID: entry.ChainID, Name: entry.Name, Reason: reason})
+			continue
+		}
+
+		existing, err := agg.GetChain(declared.ID)
+		if err == nil {
+			actual := desiredChainOf(existing)
+			// The registry knows nothing of zones and capabilities
+			declared.Zone, declared.Capabilities = actual.Zone, actual.Capabilities
+			changes, err := diffDesiredChains(actual, declared)
+			if err != nil {
+				return report, err
+			}
+			if len(changes) == 0 {
+				report.Unchanged++
+				continue
+			}
+			if pending := poolSize(existing); pending > 0 {
+				report.Skipped = append(report.Skipped, ChainImportSkip{ChainID: entry.ChainID, Name: entry.Name,
+					Reason: fmt.Sprintf("%d transactions are pending in %s", pending, declared.ID)})
+				continue
+			}
+		}
+		if err := i.module.RegisterChain(declared.chain()); err != nil && !errors.Is(err, ErrConsistencyNotMet) {
+			report.Skipped = append(report.Skipped, ChainImportSkip{ChainID: entry.ChainID, Name: entry.Name, Reason: err.Error()})
+			continue
+		}
+		if existing != nil {
+			report.Updated = append(report.Updated, declared.ID)
+		} else {
+			report.Registered = append(report.Registered, declared.ID)
+		}
+	}
+
+	i.last = &report
+	return report, nil
+}
+
+// fetch returns the registry's entries, or nil if it has not changed since
+// the last import
+func (i *ChainImporter) fetch(ctx context.Context) ([]RegistryChain, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, i.config.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if i.etag != "" {
+		req.Header.Set("If-None-Match", i.etag)
+	}
+	if i.lastModified != "" {
+		req.Header.Set("If-Modified-Since", i.lastModified)
+	}
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrChainRegistryUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, nil
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("%w: %s returned %s", ErrChainRegistryUnavailable, i.config.URL, resp.Status)
+	}
+
+	var entries []RegistryChain
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxChainRegistrySize)).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("%w: invalid registry: %v", ErrChainRegistryUnavailable, err)
+	}
+	if entries == nil {
+		entries = make([]RegistryChain, 0)
+	}
+	i.etag = resp.Header.Get("ETag")
+	i.lastModified = resp.Header.Get("Last-Modified")
+	return entries, nil
+}
+
+// selected returns the configured entries, in chain ID order
+func (i *ChainImporter) selected(entries []RegistryChain) []RegistryChain {
+	selected := entries
+	if len(i.config.ChainIDs) > 0 {
+		wanted := make(map[int64]bool, len(i.config.ChainIDs))
+		for _, id := range i.config.ChainIDs {
+			wanted[id] = true
+		}
+		selected = make([]RegistryChain, 0, len(i.config.ChainIDs))
+		for _, entry := range entries {
+			if wanted[entry.ChainID] {
+				selected = append(selected, entry)
+			}
+		}
+	}
+	sort.SliceStable(selected, func(a, b int) bool { return selected[a].ChainID < selected[b].ChainID })
+	return selected
+}
+
+// chainOf describes the chain an entry registers, or why it registers none
+func (i *ChainImporter) chainOf(entry RegistryChain) (DesiredChain, string) {
+	if entry.Status == "deprecated" {
+		return DesiredChain{}, "deprecated"
+	}
+	name := strings.ToLower(strings.TrimSpace(entry.ShortName))
+	if name == "" {
+		name = strconv.FormatInt(entry.ChainID, 10)
+	}
+
+	chain := DesiredChain{ID: i.config.IDPrefix + name, Protocol: i.config.Protocol}
+	if entry.Protocol != "" {
+		chain.Protocol = entry.Protocol
+	}
+	if protocol, exists := i.config.Protocols[strconv.FormatInt(entry.ChainID, 10)]; exists {
+		chain.Protocol = protocol
+	}
+
+	for _, rpc := range entry.RPC {
+		endpoint := strings.TrimSpace(string(rpc))
+		// Templated URLs need an API key
+		if strings.Contains(endpoint, "${") {
+			continue
+		}
+		// Adapters speak JSON-RPC over HTTP, not websockets
+		if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			continue
+		}
+		if chain.Endpoint == "" {
+			chain.Endpoint = endpoint
+		} else {
+			chain.Endpoints = append(chain.Endpoints, endpoint)
+		}
+		if 1+len(chain.Endpoints) == i.config.MaxEndpoints {
+			break
+		}
+	}
+	if chain.Endpoint == "" {
+		return chain, "no public HTTP RPC endpoint"
+	}
+	return chain, ""
+}
+
+// start imports at once and then every interval, until stop
+func (i *ChainImporter) start() {
+	i.stop = make(chan struct{})
+	go i.run(i.stop)
+}
+
+func (i *ChainImporter) run(stop chan struct{}) {
+	ticker := time.NewTicker(i.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := i.Import(context.Background())
+		if err != nil {
+			i.module.logger.Log(i.module.Name(), "ERROR", fmt.Sprintf("Failed to import chains from %s: %v", i.config.URL, err))
+		} else if len(report.Registered)+len(report.Updated)+len(report.Skipped) > 0 {
+			i.module.logger.Log(i.module.Name(), "INFO", fmt.Sprintf("Imported chains from %s: %d registered, %d updated, %d unchanged, %d skipped",
+				i.config.URL, len(report.Registered), len(report.Updated), report.Unchanged, len(report.Skipped)))
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop ends scheduled imports, letting one in progress finish
+func (i *ChainImporter) Stop() {
+	if i.stop != nil {
+		close(i.stop)
+		i.stop = nil
+	}
+}
@@ -0,0 +1,119 @@
+package agglomerator
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chainRegistryServer serves a registry document with an ETag
+type chainRegistryServer struct {
+	mu       sync.Mutex
+	document string
+	etag     string
+}
+
+func (s *chainRegistryServer) set(document, etag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.document, s.etag = document, etag
+}
+
+func (s *chainRegistryServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Header.Get("If-None-Match") == s.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", s.etag)
+	w.Write([]byte(s.document))
+}
+
+const testChainRegistry = `[
+	{"name": "Ethereum Mainnet", "chainId": 1, "shortName": "ETH", "rpc": [
+		"https://mainnet.infura.io/v3/${INFURA_API_KEY}",
+		"wss://ethereum.example",
+		{"url": "https://rpc-a.example", "tracking": "none"},
+		"https://rpc-b.example",
+		"https://rpc-c.example",
+		"https://rpc-d.example"
+	]},
+	{"name": "Solana", "chainId": 101, "shortName": "sol", "protocol": "sol", "rpc": ["https://solana.example"]},
+	{"name": "Polygon Mainnet", "chainId": 137, "shortName": "matic", "rpc": ["https://polygon.example"]},
+	{"name": "Goerli", "chainId": 5, "shortName": "gor", "status": "deprecated", "rpc": ["https://goerli.example"]},
+	{"name": "Private", "chainId": 999, "shortName": "private", "rpc": ["${PRIVATE_RPC}"]},
+	{"name": "Not selected", "chainId": 42, "shortName": "other", "rpc": ["https://other.example"]}
+]`
+
+func TestChainImporter(t *testing.T) {
+	registry := &chainRegistryServer{}
+	registry.set(testChainRegistry, `"v1"`)
+	server := httptest.NewServer(registry)
+	defer server.Close()
+
+	module := newRerouteModule(t)
+	importer := NewChainImporter(module, ChainRegistryConfig{
+		URL:          server.URL,
+		ChainIDs:     []int64{1, 5, 101, 137, 999},
+		Protocol:     DefaultChainRegistryProtocol,
+		Protocols:    map[string]string{"137": ProtocolPolkadot},
+		IDPrefix:     "cl-",
+		MaxEndpoints: DefaultRegistryEndpoints,
+	})
+
+	report, err := importer.Import(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 5, report.Entries)
+	assert.Equal(t, []string{"cl-eth", "cl-sol", "cl-matic"}, report.Registered)
+	assert.Equal(t, []ChainImportSkip{
+		{ChainID: 5, Name: "Goerli", Reason: "deprecated"},
+		{ChainID: 999, Name: "Private", Reason: "no public HTTP RPC endpoint"},
+	}, report.Skipped)
+
+	agg := module.GetAgglomerator()
+	eth, err := agg.GetChain("cl-eth")
+	require.NoError(t, err)
+	assert.Equal(t, ProtocolEthereum, eth.Protocol)
+	assert.Equal(t, "https://rpc-a.example", eth.Endpoint, "templated and websocket endpoints are left out")
+	assert.Equal(t, []string{"https://rpc-b.example", "https://rpc-c.example"}, eth.Endpoints)
+	sol, err := agg.GetChain("cl-sol")
+	require.NoError(t, err)
+	assert.Equal(t, ProtocolSolana, sol.Protocol, "entries may name their protocol")
+	matic, err := agg.GetChain("cl-matic")
+	require.NoError(t, err)
+	assert.Equal(t, ProtocolPolkadot, matic.Protocol, "configured protocols win")
+
+	report, err = importer.Import(context.Background())
+	require.NoError(t, err)
+	assert.True(t, report.NotModified)
+	last, ok := importer.LastReport()
+	require.True(t, ok)
+	assert.True(t, last.NotModified)
+
+	// A changed registry updates the chains whose endpoints changed
+	registry.set(`[
+		{"name": "Ethereum Mainnet", "chainId": 1, "shortName": "eth", "rpc": ["https://rpc-e.example"]},
+		{"name": "Polygon Mainnet", "chainId": 137, "shortName": "matic", "rpc": ["https://polygon.example"]}
+	]`, `"v2"`)
+	report, err = importer.Import(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cl-eth"}, report.Updated)
+	assert.Empty(t, report.Registered)
+	assert.Equal(t, 1, report.Unchanged)
+	eth, err = agg.GetChain("cl-eth")
+	require.NoError(t, err)
+	assert.Equal(t, "https://rpc-e.example", eth.Endpoint)
+	assert.Empty(t, eth.Endpoints)
+	_, err = agg.GetChain("cl-sol")
+	assert.NoError(t, err, "chains leaving the registry stay registered")
+
+	registry.set(`{"not": "a list"}`, `"v3"`)
+	_, err = importer.Import(context.Background())
+	assert.ErrorIs(t, err, ErrChainRegistryUnavailable)
+}
@@ -0,0 +1,185 @@
+package agglomerator
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxPayloadSize bounds Transaction.Data when no limit is configured
+	DefaultMaxPayloadSize = 4 << 20
+	// DefaultInlinePayloadSize is the largest payload accepted inside a JSON body
+	DefaultInlinePayloadSize = 64 << 10
+	// DefaultChunkSize is the largest payload sent in a single P2P message
+	DefaultChunkSize = 256 << 10
+
+	// maxChunkCount bounds the chunks one payload may be split into
+	maxChunkCount = 1 << 16
+)
+
+// splitPayload breaks a message whose payload exceeds chunkSize into
+// numbered chunks sharing the original DataID
+func splitPayload(msg DataTransferMessage, chunkSize int) []DataTransferMessage {
+	if chunkSize <= 0 || len(msg.Payload) <= chunkSize {
+		return []DataTransferMessage{msg}
+	}
+
+	count := (len(msg.Payload) + chunkSize - 1) / chunkSize
+	chunks := make([]DataTransferMessage, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * chunkSize
+		if end > len(msg.Payload) {
+			end = len(msg.Payload)
+		}
+
+		chunk := msg
+		chunk.Payload = msg.Payload[i*chunkSize : end]
+		chunk.ChunkIndex = i
+		chunk.ChunkCount = count
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// pendingPayload collects the chunks of one payload as they arrive
+type pendingPayload struct {
+	chunks    [][]byte
+	received  int
+	size      int
+	firstSeen time.Time
+}
+
+// Reassembler rebuilds chunked payloads and drops any that would exceed the
+// payload limit
+type Reassembler struct {
+	maxPayload int64
+	pending    map[string]*pendingPayload
+	dropped    map[string]time.Time // Refused payloads whose later chunks are ignored
+	mu         sync.Mutex
+}
+
+func NewReassembler(maxPayload int64) *Reassembler {
+	if maxPayload <= 0 {
+		maxPayload = DefaultMaxPayloadSize
+	}
+	return &Reassembler{
+		maxPayload: maxPayload,
+		pending:    make(map[string]*pendingPayload),
+		dropped:    make(map[string]time.Time),
+	}
+}
+
+// SetMaxPayload changes the largest payload that will be reassembled
+func (r *Reassembler) SetMaxPayload(maxPayload int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if maxPayload > 0 {
+		r.maxPayload = maxPayload
+	}
+}
+
+// MaxPayload returns the largest payload that will be reassembled
+func (r *Reassembler) MaxPayload() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.maxPayload
+}
+
+// Add stores a chunk and returns the complete message once every chunk of
+// its payload has arrived
+func (r *Reassembler) Add(msg DataTransferMessage) (DataTransferMessage, bool) {
+	if msg.ChunkCount <= 1 {
+		return msg, true
+	}
+	if msg.ChunkIndex < 0 || msg.ChunkIndex >= msg.ChunkCount {
+		return DataTransferMessage{}, false
+	}
+	// The chunk table is allocated from the count, so a peer may not claim
+	// more chunks than a payload could need
+	if msg.ChunkCount > maxChunkCount || len(msg.Payload) == 0 {
+		return DataTransferMessage{}, false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := msg.SenderID + "/" + msg.DataID
+	if _, refused := r.dropped[key]; refused {
+		return DataTransferMessage{}, false
+	}
+
+	// Every chunk but the last is full size, so oversized payloads can be
+	// refused before any of them is buffered
+	if msg.ChunkIndex < msg.ChunkCount-1 && int64(msg.ChunkCount-1)*int64(len(msg.Payload)) >= r.maxPayload {
+		r.drop(key)
+		return DataTransferMessage{}, false
+	}
+
+	pending, exists := r.pending[key]
+	if !exists {
+		pending = &pendingPayload{
+			chunks:    make([][]byte, msg.ChunkCount),
+			firstSeen: time.Now(),
+		}
+		r.pending[key] = pending
+	}
+	if len(pending.chunks) != msg.ChunkCount || pending.chunks[msg.ChunkIndex] != nil {
+		return DataTransferMessage{}, false
+	}
+
+	pending.size += len(msg.Payload)
+	if int64(pending.size) > r.maxPayload {
+		r.drop(key)
+		return DataTransferMessage{}, false
+	}
+	pending.chunks[msg.ChunkIndex] = msg.Payload
+	pending.received++
+	if pending.received < msg.ChunkCount {
+		return DataTransferMessage{}, false
+	}
+
+	delete(r.pending, key)
+	payload := make([]byte, 0, pending.size)
+	for _, chunk := range pending.chunks {
+		payload = append(payload, chunk...)
+	}
+
+	complete := msg
+	complete.Payload = payload
+	complete.ChunkIndex = 0
+	complete.ChunkCount = 0
+	return complete, true
+}
+
+// drop discards a payload and ignores any of its chunks still in flight
+func (r *Reassembler) drop(key string) {
+	delete(r.pending, key)
+	r.dropped[key] = time.Now()
+}
+
+// Pending returns the number of partially received payloads
+func (r *Reassembler) Pending() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.pending)
+}
+
+// Collect drops partial payloads whose first chunk arrived before cutoff
+func (r *Reassembler) Collect(cutoff time.Time) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	removed := 0
+	for key, pending := range r.pending {
+		if pending.firstSeen.Before(cutoff) {
+			delete(r.pending, key)
+			removed++
+		}
+	}
+	for key, droppedAt := range r.dropped {
+		if droppedAt.Before(cutoff) {
+			delete(r.dropped, key)
+		}
+	}
+	return removed
+}
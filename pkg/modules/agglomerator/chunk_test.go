@@ -0,0 +1,57 @@
+package agglomerator
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkRoundTrip(t *testing.T) {
+	payload := bytes.Repeat([]byte("hydap"), 1000)
+	msg := DataTransferMessage{SenderID: "peer-a", DataID: "tx-1", Payload: payload}
+
+	chunks := splitPayload(msg, 1024)
+	require.Len(t, chunks, 5)
+	for i, chunk := range chunks {
+		assert.Equal(t, i, chunk.ChunkIndex)
+		assert.Equal(t, 5, chunk.ChunkCount)
+	}
+
+	r := NewReassembler(int64(len(payload)))
+
+	// Deliver out of order; only the last chunk completes the payload
+	order := []int{3, 0, 4, 1}
+	for _, i := range order {
+		_, complete := r.Add(chunks[i])
+		assert.False(t, complete)
+	}
+	_, complete := r.Add(chunks[3])
+	assert.False(t, complete, "duplicate chunks are ignored")
+
+	rebuilt, complete := r.Add(chunks[2])
+	require.True(t, complete)
+	assert.Equal(t, payload, rebuilt.Payload)
+	assert.Zero(t, rebuilt.ChunkCount)
+	assert.Zero(t, r.Pending())
+}
+
+func TestReassemblerLimits(t *testing.T) {
+	payload := bytes.Repeat([]byte{1}, 4096)
+	chunks := splitPayload(DataTransferMessage{SenderID: "peer-a", DataID: "big", Payload: payload}, 1024)
+
+	r := NewReassembler(2048)
+	for _, chunk := range chunks {
+		_, complete := r.Add(chunk)
+		assert.False(t, complete)
+	}
+	assert.Zero(t, r.Pending(), "oversized payloads are not buffered")
+
+	partial := splitPayload(DataTransferMessage{SenderID: "peer-b", DataID: "slow", Payload: payload[:2048]}, 1024)
+	r.Add(partial[0])
+	assert.Equal(t, 1, r.Pending())
+	assert.Equal(t, 1, r.Collect(time.Now().Add(time.Second)))
+	assert.Zero(t, r.Pending())
+}
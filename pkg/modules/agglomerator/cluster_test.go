@@ -0,0 +1,78 @@
+package agglomerator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+func TestClustererFitKeepsIDs(t *testing.T) {
+	clusterer := vectors.NewClusterer(vectors.ClusterConfig{MaxClusters: 4, Threshold: 0.8, Dimensions: 50})
+
+	up, _, ok := clusterer.Add("a", waveVector(1, 0))
+	require.True(t, ok)
+	down, _, _ := clusterer.Add("b", waveVector(-1, 0))
+	assert.NotEqual(t, up, down)
+
+	cluster, _, _ := clusterer.Add("c", waveVector(1, 0.02))
+	assert.Equal(t, up, cluster, "similar vectors join the same cluster")
+	cluster, _, _ = clusterer.Add("c", waveVector(-1, 0))
+	assert.Equal(t, up, cluster, "assigned IDs keep their cluster")
+
+	clusterer.Fit([]vectors.DatabaseRecord{
+		{ID: "a", Vector: *waveVector(1, 0)},
+		{ID: "b", Vector: *waveVector(-1, 0)},
+		{ID: "d", Vector: *waveVector(-1, 0.01)},
+	}, 10)
+	assert.Equal(t, []string{"a"}, clusterer.Members(up))
+	assert.Equal(t, []string{"b", "d"}, clusterer.Members(down))
+	_, assigned := clusterer.Assignment("c")
+	assert.False(t, assigned, "refits replace assignments")
+	assert.Len(t, clusterer.Clusters(), 2)
+
+	_, _, ok = clusterer.Add("", &vectors.InfiniteVector{})
+	assert.False(t, ok, "vectors without a generator are not clustered")
+}
+
+func TestAcceleratorGroupsByCluster(t *testing.T) {
+	ca := NewChainAccelerator(DefaultCompareDims)
+	txs := []*Transaction{
+		{ID: "up-1", StateVector: *waveVector(1, 0)},
+		{ID: "down-1", StateVector: *waveVector(-1, 0)},
+		{ID: "up-2", StateVector: *waveVector(1, 0.02)},
+		{ID: "none"},
+	}
+
+	groups := ca.groupTransactionsByVector(txs)
+	require.Len(t, groups, 3)
+	sizes := map[string]int{}
+	for _, group := range groups {
+		for _, tx := range group {
+			sizes[tx.ID] = len(group)
+		}
+	}
+	assert.Equal(t, map[string]int{"up-1": 2, "up-2": 2, "down-1": 1, "none": 1}, sizes)
+	assert.Len(t, ca.Clusters(), 2)
+}
+
+func TestRegisterChainAssignsCluster(t *testing.T) {
+	agg := NewAgglomerator(AgglomeratorConfig{VectorDims: 50, SimThreshold: 0.9})
+
+	eth := NewChain("eth", "http://localhost:8545", ProtocolEthereum)
+	eth.StateVector = *waveVector(1, 0)
+	require.NoError(t, agg.RegisterChain(eth))
+	bsc := NewChain("bsc", "http://localhost:8546", ProtocolEthereum)
+	bsc.StateVector = *waveVector(1, 0.01)
+	require.NoError(t, agg.RegisterChain(bsc))
+
+	cluster, ok := agg.ClusterOf("eth")
+	require.True(t, ok)
+	assert.Equal(t, []string{"bsc", "eth"}, agg.ClusterMembers(cluster))
+
+	agg.RefitClusters()
+	refit, ok := agg.ClusterOf("eth")
+	require.True(t, ok)
+	assert.Equal(t, cluster, refit)
+}
@@ -0,0 +1,107 @@
+package agglomerator
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+const (
+	// defaultMaxClusters bounds the clusters kept over chain and transaction
+	// vectors unless configured
+	defaultMaxClusters = 16
+
+	// defaultClusterThreshold is the similarity needed to join a cluster
+	// unless configured
+	defaultClusterThreshold = 0.8
+
+	// clusterRefitIterations bounds the k-means passes of each refit
+	clusterRefitIterations = 10
+)
+
+// clusterConfig fills in defaults for the agglomerator's clustering
+func clusterConfig(config AgglomeratorConfig) vectors.ClusterConfig {
+	clustering := config.Clustering
+	if clustering.MaxClusters <= 0 {
+		clustering.MaxClusters = defaultMaxClusters
+	}
+	if clustering.Threshold == 0 {
+		clustering.Threshold = config.SimThreshold
+	}
+	if clustering.Threshold == 0 {
+		clustering.Threshold = defaultClusterThreshold
+	}
+	if clustering.Dimensions <= 0 {
+		clustering.Dimensions = compareDims(config)
+	}
+	return clustering
+}
+
+// Clusters describes the clusters over chain and transaction vectors
+func (a *Agglomerator) Clusters() []vectors.ClusterInfo {
+	return a.clusters.Clusters()
+}
+
+// ClusterOf returns the cluster a chain or transaction is assigned to
+func (a *Agglomerator) ClusterOf(id string) (int, bool) {
+	return a.clusters.Assignment(id)
+}
+
+// ClusterMembers returns the chains and transactions assigned to a cluster
+func (a *Agglomerator) ClusterMembers(cluster int) []string {
+	return a.clusters.Members(cluster)
+}
+
+// RefitClusters reclusters every vector in the routing index with k-means,
+// keeping cluster IDs where clusters persist
+func (a *Agglomerator) RefitClusters() {
+	a.clusters.Fit(a.vectorIndex.Records(), clusterRefitIterations)
+}
+
+type clusterRefit struct {
+	interval time.Duration
+	stop     chan struct{}
+}
+
+// StartClusterRefit refits the clusters every interval in the background
+func (a *Agglomerator) StartClusterRefit(interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("cluster refit interval must be positive")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.refit != nil {
+		return fmt.Errorf("cluster refit already running")
+	}
+	a.refit = &clusterRefit{interval: interval, stop: make(chan struct{})}
+	go a.runClusterRefit(a.refit)
+	return nil
+}
+
+// StopClusterRefit stops background cluster refits
+func (a *Agglomerator) StopClusterRefit() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.refit != nil {
+		close(a.refit.stop)
+		a.refit = nil
+	}
+}
+
+func (a *Agglomerator) runClusterRefit(refit *clusterRefit) {
+	ticker := time.NewTicker(refit.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-refit.stop:
+			return
+		case <-ticker.C:
+			a.RefitClusters()
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package agglomerator
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+// ColdVectorStore holds vector records demoted from the routing index in
+// SQLite. Records are rebuilt from the chains and transactions on start, so
+// the store is cleared when opened.
+type ColdVectorStore struct {
+	db *sql.DB
+}
+
+func NewColdVectorStore(dbPath string) (*ColdVectorStore, error) {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if _, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS cold_vectors (
+            id TEXT PRIMARY KEY,
+            metadata BLOB NOT NULL,
+            elements BLOB NOT NULL,
+            inserted_at INTEGER NOT NULL
+        );
+        DELETE FROM cold_vectors;
+    `); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	return &ColdVectorStore{db: db}, nil
+}
+
+// Put implements vectors.ColdStore
+func (s *ColdVectorStore) Put(record vectors.ColdRecord) error {
+	metadata, err := encodeColdMetadata(record.Metadata)
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`
+        INSERT OR REPLACE INTO cold_vectors (id, metadata, elements, inserted_at) VALUES (?, ?, ?, ?)
+    `, record.ID, metadata, encodeColdElements(record.Elements), record.InsertedAt.UnixNano()); err != nil {
+		return fmt.Errorf("failed to store cold vector: %w", err)
+	}
+	return nil
+}
+
+// Get implements vectors.ColdStore
+func (s *ColdVectorStore) Get(id string) (vectors.ColdRecord, bool, error) {
+	row := s.db.QueryRow(`SELECT id, metadata, elements, inserted_at FROM cold_vectors WHERE id = ?`, id)
+	record, err := scanColdRecord(row)
+	if err == sql.ErrNoRows {
+		return vectors.ColdRecord{}, false, nil
+	}
+	if err != nil {
+		return vectors.ColdRecord{}, false, err
+	}
+	return record, true, nil
+}
+
+// Delete implements vectors.ColdStore
+func (s *ColdVectorStore) Delete(id string) (bool, error) {
+	result, err := s.db.Exec(`DELETE FROM cold_vectors WHERE id = ?`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete cold vector: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete cold vector: %w", err)
+	}
+	return n > 0, nil
+}
+
+// Scan implements vectors.ColdStore
+func (s *ColdVectorStore) Scan(fn func(vectors.ColdRecord) bool) error {
+	rows, err := s.db.Query(`SELECT id, metadata, elements, inserted_at FROM cold_vectors`)
+	if err != nil {
+		return fmt.Errorf("failed to query cold vectors: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		record, err := scanColdRecord(rows)
+		if err != nil {
+			return err
+		}
+		if !fn(record) {
+			return nil
+		}
+	}
+	return rows.Err()
+}
+
+func (s *ColdVectorStore) Close() error {
+	if s.db != nil {
+		return s.db.Close()
+	}
+	return nil
+}
+
+func scanColdRecord(row interface{ Scan(...any) error }) (vectors.ColdRecord, error) {
+	var record vectors.ColdRecord
+	var metadata, elements []byte
+	var insertedAt int64
+	if err := row.Scan(&record.ID, &metadata, &elements, &insertedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return record, err
+		}
+		return record, fmt.Errorf("failed to read cold vector: %w", err)
+	}
+	if len(metadata) > 0 {
+		if err := gob.NewDecoder(bytes.NewReader(metadata)).Decode(&record.Metadata); err != nil {
+			return record, fmt.Errorf("failed to decode cold vector metadata: %w", err)
+		}
+	}
+	record.Elements = decodeColdElements(elements)
+	record.InsertedAt = time.Unix(0, insertedAt)
+	return record, nil
+}
+
+// encodeColdMetadata uses gob so metadata values keep their Go types; JSON
+// would turn priorities into floats
+func encodeColdMetadata(metadata map[string]interface{}) ([]byte, error) {
+	if len(metadata) == 0 {
+		return []byte{}, nil
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(metadata); err != nil {
+		return nil, fmt.Errorf("failed to encode cold vector metadata: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeColdElements(elements []float64) []byte {
+	data := make([]byte, 8*len(elements))
+	for i, element := range elements {
+		binary.LittleEndian.PutUint64(data[8*i:], math.Float64bits(element))
+	}
+	return data
+}
+
+func decodeColdElements(data []byte) []float64 {
+	elements := make([]float64, len(data)/8)
+	for i := range elements {
+		elements[i] = math.Float64frombits(binary.LittleEndian.Uint64(data[8*i:]))
+	}
+	return elements
+}
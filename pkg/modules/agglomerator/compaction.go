@@ -0,0 +1,263 @@
+package agglomerator
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+const (
+	// archiveBlockRecords caps how many records are folded into one archive block
+	archiveBlockRecords = 64
+	defaultArchiveDims  = 50
+)
+
+// archiveCompressorConfig favours fidelity over ratio since archives are queried
+var archiveCompressorConfig = CompressorConfig{
+	Tolerance:       0.001,
+	MaxRank:         32,
+	EnergyThreshold: 0.999,
+	MinSparsity:     0.5,
+}
+
+// CompactionConfig controls how transaction pools are folded into archives
+type CompactionConfig struct {
+	Interval         time.Duration // How often pools are compacted
+	MaxAge           time.Duration // Records older than this leave the live pool
+	Dimensions       int           // Vector dimensions materialized per record
+	MaxArchiveBlocks int           // Oldest archives are dropped beyond this (0 = unlimited)
+}
+
+// ArchiveBlock holds transaction records folded out of a chain's live pool
+type ArchiveBlock struct {
+	*CompressedBlock
+	RecordIDs  []string
+	Metadata   []map[string]interface{}
+	Dimensions int
+	ArchivedAt time.Time
+}
+
+// Compact folds records older than cutoff into compressed archive blocks and
+// evicts them from the live transaction pool. It returns the number of records archived.
+func (c *Chain) Compact(cutoff time.Time, dims, maxBlocks int) (int, error) {
+	if c.TransactionPool == nil {
+		return 0, nil
+	}
+	if dims <= 0 {
+		dims = defaultArchiveDims
+	}
+
+	aged := c.TransactionPool.InsertedBefore(cutoff)
+	if len(aged) == 0 {
+		return 0, nil
+	}
+
+	c.archiveMu.Lock()
+	defer c.archiveMu.Unlock()
+
+	if c.streamingCompressor == nil {
+		c.streamingCompressor = NewAdaptiveCompressor(archiveCompressorConfig)
+	}
+
+	archived := 0
+	for start := 0; start < len(aged); start += archiveBlockRecords {
+		end := min(start+archiveBlockRecords, len(aged))
+		batch := aged[start:end]
+
+		archive, err := c.archiveRecords(batch, dims)
+		if err != nil {
+			return archived, fmt.Errorf("failed to archive chain %s: %w", c.ID, err)
+		}
+		c.compressedBlocks = append(c.compressedBlocks, archive)
+
+		for _, record := range batch {
+			c.removeFromPool(record.ID, RemovedArchived)
+		}
+		archived += len(batch)
+	}
+
+	if maxBlocks > 0 && len(c.compressedBlocks) > maxBlocks {
+		c.compressedBlocks = c.compressedBlocks[len(c.compressedBlocks)-maxBlocks:]
+	}
+
+	return archived, nil
+}
+
+// archiveRecords materializes record vectors and compresses them into one block
+func (c *Chain) archiveRecords(records []vectors.DatabaseRecord, dims int) (*ArchiveBlock, error) {
+	data := make([]float64, 0, len(records)*dims)
+	ids := make([]string, len(records))
+	metadata := make([]map[string]interface{}, len(records))
+
+	for i, record := range records {
+		ids[i] = record.ID
+		metadata[i] = record.Metadata
+		for d := 0; d < dims; d++ {
+			data = append(data, record.Vector.GetElement(d))
+		}
+	}
+
+	block, err := c.streamingCompressor.CompressBlock(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ArchiveBlock{
+		CompressedBlock: block,
+		RecordIDs:       ids,
+		Metadata:        metadata,
+		Dimensions:      dims,
+		ArchivedAt:      time.Now(),
+	}, nil
+}
+
+// LookupTransaction finds a transaction record in the live pool, falling back
+// to the compacted archives when the live pool misses
+func (c *Chain) LookupTransaction(id string) (vectors.DatabaseRecord, bool) {
+	if c.TransactionPool != nil {
+		if record, exists := c.TransactionPool.Get(id); exists {
+			return record, true
+		}
+	}
+
+	c.archiveMu.RLock()
+	defer c.archiveMu.RUnlock()
+
+	// Search newest archives first
+	for i := len(c.compressedBlocks) - 1; i >= 0; i-- {
+		archive := c.compressedBlocks[i]
+		for j, recordID := range archive.RecordIDs {
+			if recordID != id {
+				continue
+			}
+			record, err := archive.restore(j)
+			if err != nil {
+				return vectors.DatabaseRecord{}, false
+			}
+			return record, true
+		}
+	}
+
+	return vectors.DatabaseRecord{}, false
+}
+
+// ArchiveStats returns the number of archive blocks and archived records
+func (c *Chain) ArchiveStats() (blocks int, records int) {
+	c.archiveMu.RLock()
+	defer c.archiveMu.RUnlock()
+
+	for _, archive := range c.compressedBlocks {
+		records += len(archive.RecordIDs)
+	}
+	return len(c.compressedBlocks), records
+}
+
+// restore reconstructs the i-th record of an archive block. Dimensions beyond
+// the archived ones evaluate to zero.
+func (ab *ArchiveBlock) restore(i int) (vectors.DatabaseRecord, error) {
+	data, err := ab.Decompress()
+	if err != nil {
+		return vectors.DatabaseRecord{}, err
+	}
+
+	offset := i * ab.Dimensions
+	if offset+ab.Dimensions > len(data) {
+		return vectors.DatabaseRecord{}, fmt.Errorf("archive block truncated")
+	}
+	elements := make([]float64, ab.Dimensions)
+	copy(elements, data[offset:offset+ab.Dimensions])
+
+	metadata := map[string]interface{}{"archived": true}
+	for k, v := range ab.Metadata[i] {
+		metadata[k] = v
+	}
+
+	return vectors.DatabaseRecord{
+		ID:       ab.RecordIDs[i],
+		Metadata: metadata,
+		Vector:   vectors.FromElements(elements),
+	}, nil
+}
+
+// PoolCompactor periodically compacts the transaction pools of all chains
+type PoolCompactor struct {
+	agg    *Agglomerator
+	config CompactionConfig
+	stop   chan struct{}
+}
+
+// StartCompaction launches the background compaction loop
+func (a *Agglomerator) StartCompaction(config CompactionConfig) error {
+	if config.Interval <= 0 || config.MaxAge <= 0 {
+		return fmt.Errorf("compaction interval and max age must be positive")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.compactor != nil {
+		return fmt.Errorf("compaction already running")
+	}
+
+	a.compactor = &PoolCompactor{
+		agg:    a,
+		config: config,
+		stop:   make(chan struct{}),
+	}
+	go a.compactor.run()
+	return nil
+}
+
+// StopCompaction stops the background compaction loop
+func (a *Agglomerator) StopCompaction() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.compactor != nil {
+		close(a.compactor.stop)
+		a.compactor = nil
+	}
+}
+
+// CompactPools runs one compaction pass over all chains
+func (a *Agglomerator) CompactPools(maxAge time.Duration, dims, maxBlocks int) (int, error) {
+	cutoff := time.Now().Add(-maxAge)
+	total := 0
+	for _, chain := range a.ListChains() {
+		n, err := chain.Compact(cutoff, dims, maxBlocks)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func (pc *PoolCompactor) run() {
+	ticker := time.NewTicker(pc.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			pc.agg.CompactPools(pc.config.MaxAge, pc.config.Dimensions, pc.config.MaxArchiveBlocks)
+		case <-pc.stop:
+			return
+		}
+	}
+}
+
+// LookupTransaction searches a chain's live pool and archives for a transaction
+func (a *Agglomerator) LookupTransaction(chainID, txID string) (vectors.DatabaseRecord, error) {
+	chain, err := a.GetChain(chainID)
+	if err != nil {
+		return vectors.DatabaseRecord{}, err
+	}
+
+	record, exists := chain.LookupTransaction(txID)
+	if !exists {
+		return vectors.DatabaseRecord{}, ErrTransactionNotFound
+	}
+	return record, nil
+}
@@ -0,0 +1,56 @@
+package agglomerator
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+func TestChainCompaction(t *testing.T) {
+	chain := NewChain("ethereum", "http://localhost:8545", ProtocolEthereum)
+
+	for _, id := range []string{"tx-1", "tx-2", "tx-3"} {
+		require.NoError(t, chain.TransactionPool.Insert(vectors.DatabaseRecord{
+			ID:       id,
+			Metadata: map[string]interface{}{"fromChain": "ethereum"},
+			Vector: vectors.InfiniteVector{
+				Generator: func(dim int) float64 {
+					return math.Exp(-float64(dim)/10.0) * math.Sin(float64(dim))
+				},
+			},
+		}))
+	}
+
+	archived, err := chain.Compact(time.Now().Add(time.Second), 50, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 3, archived)
+	assert.Equal(t, 0, chain.TransactionPool.Len())
+
+	blocks, records := chain.ArchiveStats()
+	assert.Equal(t, 1, blocks)
+	assert.Equal(t, 3, records)
+
+	// Lookups transparently fall back to the archive
+	record, found := chain.LookupTransaction("tx-2")
+	require.True(t, found)
+	assert.Equal(t, "tx-2", record.ID)
+	assert.Equal(t, true, record.Metadata["archived"])
+	assert.Equal(t, "ethereum", record.Metadata["fromChain"])
+
+	_, found = chain.LookupTransaction("missing")
+	assert.False(t, found)
+}
+
+func TestChainCompactionSkipsFreshRecords(t *testing.T) {
+	chain := NewChain("solana", "http://localhost:8899", ProtocolSolana)
+	require.NoError(t, chain.TransactionPool.Insert(vectors.DatabaseRecord{ID: "tx-1"}))
+
+	archived, err := chain.Compact(time.Now().Add(-time.Hour), 50, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 0, archived)
+	assert.Equal(t, 1, chain.TransactionPool.Len())
+}
@@ -0,0 +1,82 @@
+package agglomerator
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/keymanagement/pb"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestNegotiateVersion(t *testing.T) {
+	version, err := NegotiateVersion(1, 2, 1, 3)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2), version)
+
+	version, err = NegotiateVersion(1, 2, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), version, "a newer node speaks down to an older one")
+
+	_, err = NegotiateVersion(1, 2, 3, 4)
+	assert.ErrorIs(t, err, ErrIncompatibleVersion)
+}
+
+func TestEnvelopeUpgradeAndDowngrade(t *testing.T) {
+	msg := DataTransferMessage{SenderID: "node-a", RecipientID: "node-b", DataID: "record-1", Payload: []byte("data")}
+
+	envelope := msg.proto()
+	assert.Equal(t, WireVersion, envelope.GetVersion())
+	require.NoError(t, downgradeEnvelope(envelope, 1))
+	assert.Zero(t, envelope.GetVersion(), "version 1 envelopes carry no version")
+
+	data, err := proto.Marshal(envelope)
+	require.NoError(t, err)
+	var received pb.Envelope
+	require.NoError(t, proto.Unmarshal(data, &received))
+	require.NoError(t, upgradeEnvelope(&received))
+	assert.Equal(t, WireVersion, received.GetVersion())
+	assert.Equal(t, msg, envelopeFromProto(&received))
+
+	assert.ErrorIs(t, downgradeEnvelope(msg.proto(), WireVersion+1), ErrIncompatibleVersion)
+	assert.ErrorIs(t, upgradeEnvelope(&pb.Envelope{Version: WireVersion + 1}), ErrIncompatibleVersion)
+}
+
+func TestUnknownEnvelopeFieldsSurvive(t *testing.T) {
+	// A field from a newer schema that this node does not know
+	unknown := protowire.AppendTag(nil, 99, protowire.BytesType)
+	unknown = protowire.AppendString(unknown, "from the future")
+
+	envelope := DataTransferMessage{SenderID: "node-a", DataID: "record-1"}.proto()
+	envelope.ProtoReflect().SetUnknown(unknown)
+	data, err := proto.Marshal(envelope)
+	require.NoError(t, err)
+
+	var received pb.Envelope
+	require.NoError(t, proto.Unmarshal(data, &received))
+	msg := envelopeFromProto(&received)
+	assert.Equal(t, "record-1", msg.DataID)
+	assert.Equal(t, []byte(unknown), []byte(msg.proto().ProtoReflect().GetUnknown()))
+}
+
+func TestHandshakeWithOlderPeer(t *testing.T) {
+	node := NewP2PInfiniteVectorNode("127.0.0.1", 0)
+
+	// Nodes from before versioning send no version fields
+	var reply bytes.Buffer
+	answer, err := node.acceptHandshake(&reply, Handshake{NodeID: "old-node", Algorithms: SignatureAlgorithms})
+	require.NoError(t, err)
+	var frame pb.Handshake
+	require.NoError(t, readFrame(bufio.NewReader(&reply), &frame, frameOverhead))
+	assert.Equal(t, uint32(1), frame.GetVersion())
+	assert.Equal(t, uint32(1), answer.Version)
+
+	reply.Reset()
+	_, err = node.acceptHandshake(&reply, Handshake{NodeID: "new-node", Algorithms: SignatureAlgorithms, Version: WireVersion + 2, MinVersion: WireVersion + 1})
+	assert.ErrorIs(t, err, ErrIncompatibleVersion)
+	require.NoError(t, readFrame(bufio.NewReader(&reply), &frame, frameOverhead))
+	assert.NotEmpty(t, frame.GetError())
+}
@@ -28,12 +28,21 @@
 package agglomerator
 
 import (
+	"errors"
 	"fmt"
 	"gonum.org/v1/gonum/mat"
 	"math"
 	"sync"
 )
 
+// DefaultMaxDecompressedSize bounds the values one decompression may
+// produce, 128 MiB of float64s
+const DefaultMaxDecompressedSize = 1 << 24
+
+// ErrDecompressionLimit is returned for blocks that would decompress past
+// the size limit
+var ErrDecompressionLimit = errors.New("decompressed size exceeds limit")
+
 type CompressionMode int
 
 const (
@@ -52,6 +61,7 @@ type AdaptiveCompressor struct {
 	energyThreshold float64
 	minSparsity     float64
 	forceMaxRank    bool
+	maxDecompressed int
 }
 
 // CompressorConfig holds configuration parameters for the compressor.
@@ -68,6 +78,10 @@ type CompressorConfig struct {
 	// MinSparsity sets threshold for sparse compression (typical range: 0.3 to 0.7)
 	MinSparsity  float64
 	ForceMaxRank bool
+
+	// MaxDecompressedSize bounds the values a decompression may produce;
+	// zero uses DefaultMaxDecompressedSize
+	MaxDecompressedSize int
 }
 
 // CompressedBlock represents compressed data and metadata.
@@ -98,7 +112,16 @@ func NewAdaptiveCompressor(config CompressorConfig) *AdaptiveCompressor {
 		energyThreshold: config.EnergyThreshold,
 		minSparsity:     config.MinSparsity,
 		forceMaxRank:    config.ForceMaxRank,
+		maxDecompressed: config.MaxDecompressedSize,
+	}
+}
+
+// MaxDecompressedSize returns the values a decompression may produce
+func (ac *AdaptiveCompressor) MaxDecompressedSize() int {
+	if ac.maxDecompressed > 0 {
+		return ac.maxDecompressed
 	}
+	return DefaultMaxDecompressedSize
 }
 
 // CompressBlock compresses the input data using either Rank-1 or Adaptive SVD.
@@ -163,6 +186,11 @@ func (ac *AdaptiveCompressor) CompressBlock(blockData []float64) (*CompressedBlo
 			rank = ac.maxRank
 		}
 	}
+	// All-zero data has no energy to retain, but still needs a component
+	// to decompress
+	if rank < 1 {
+		rank = 1
+	}
 
 	// Extract matrices
 	var u, v mat.Dense
@@ -195,7 +223,10 @@ func (ac *AdaptiveCompressor) CompressBlock(blockData []float64) (*CompressedBlo
 	return compressed, nil
 }
 
-func (ac *AdaptiveCompressor) compressRank1(matrix *mat.Dense, svd *mat.SVD) (*CompressedBlock, error) {
+// compressRank1 keeps the leading component of a block's padded matrix.
+// size is the block's logical length, so the padding is not decompressed
+// back as data.
+func (ac *AdaptiveCompressor) compressRank1(matrix *mat.Dense, svd *mat.SVD, size int) (*CompressedBlock, error) {
 	var u, v mat.Dense
 	svd.UTo(&u)
 	svd.VTo(&v)
@@ -212,15 +243,17 @@ func (ac *AdaptiveCompressor) compressRank1(matrix *mat.Dense, svd *mat.SVD) (*C
 		S:            []float64{s},
 		OriginalRows: matrix.RawMatrix().Rows,
 		OriginalCols: matrix.RawMatrix().Cols,
-		OriginalSize: matrix.RawMatrix().Rows * matrix.RawMatrix().Cols,
+		OriginalSize: size,
 		Mode:         Rank1Mode,
 	}
 
 	return compressed, nil
 }
 
-func (ac *AdaptiveCompressor) compressAdaptive(matrix *mat.Dense, svd *mat.SVD, singularValues []float64) (*CompressedBlock, error) {
-	targetSize := int(float64(matrix.RawMatrix().Rows*matrix.RawMatrix().Cols) * 0.7)
+// compressAdaptive keeps as many quantized components as fit the target
+// size. size is the block's logical length, as for compressRank1.
+func (ac *AdaptiveCompressor) compressAdaptive(matrix *mat.Dense, svd *mat.SVD, singularValues []float64, size int) (*CompressedBlock, error) {
+	targetSize := int(float64(size) * 0.7)
 	rank := ac.calculateOptimalRank(singularValues, matrix.RawMatrix().Rows, matrix.RawMatrix().Cols, targetSize)
 
 	var u, v mat.Dense
@@ -233,7 +266,7 @@ func (ac *AdaptiveCompressor) compressAdaptive(matrix *mat.Dense, svd *mat.SVD,
 		S:            make([]float64, rank),
 		OriginalRows: matrix.RawMatrix().Rows,
 		OriginalCols: matrix.RawMatrix().Cols,
-		OriginalSize: matrix.RawMatrix().Rows * matrix.RawMatrix().Cols,
+		OriginalSize: size,
 		Mode:         AdaptiveMode,
 	}
 
@@ -248,22 +281,56 @@ func (ac *AdaptiveCompressor) compressAdaptive(matrix *mat.Dense, svd *mat.SVD,
 	return compressed, nil
 }
 
-// DecompressStream handles streaming decompression of multiple blocks
+// DecompressStream decompresses multiple blocks into one slice. Every
+// header is checked, and the total size against the limit, before anything
+// is allocated.
 func (ac *AdaptiveCompressor) DecompressStream(blocks []*CompressedBlock) ([]float64, error) {
-	if len(blocks) == 0 {
-		return nil, fmt.Errorf("no blocks to decompress")
+	total, err := ac.streamSize(blocks)
+	if err != nil {
+		return nil, err
 	}
 
-	var result []float64
+	result := make([]float64, 0, total)
+	err = ac.DecompressStreamTo(blocks, func(values []float64) error {
+		result = append(result, values...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DecompressStreamTo decompresses multiple blocks a row at a time, passing
+// each row's values to emit. Only one row is held in memory; emit must not
+// keep the slice.
+func (ac *AdaptiveCompressor) DecompressStreamTo(blocks []*CompressedBlock, emit func(values []float64) error) error {
+	if _, err := ac.streamSize(blocks); err != nil {
+		return err
+	}
 	for _, block := range blocks {
-		decompressed, err := block.Decompress()
-		if err != nil {
-			return nil, fmt.Errorf("failed to decompress block: %w", err)
+		if err := block.DecompressRows(ac.MaxDecompressedSize(), emit); err != nil {
+			return fmt.Errorf("failed to decompress block: %w", err)
 		}
-		result = append(result, decompressed...)
 	}
+	return nil
+}
 
-	return result, nil
+// streamSize validates the blocks of a stream and returns their total size
+func (ac *AdaptiveCompressor) streamSize(blocks []*CompressedBlock) (int, error) {
+	if len(blocks) == 0 {
+		return 0, fmt.Errorf("no blocks to decompress")
+	}
+
+	limit := ac.MaxDecompressedSize()
+	total := 0
+	for i, block := range blocks {
+		if err := block.Validate(limit - total); err != nil {
+			return 0, fmt.Errorf("block %d: %w", i, err)
+		}
+		total += block.OriginalSize
+	}
+	return total, nil
 }
 
 // Helper functions
@@ -296,30 +363,89 @@ func (ac *AdaptiveCompressor) calculateOptimalRank(singularValues []float64, row
 	return 1
 }
 
+// Decompress reconstructs the block's data, refusing blocks larger than
+// DefaultMaxDecompressedSize
 func (cb *CompressedBlock) Decompress() ([]float64, error) {
-	if err := validateCompressedBlock(cb); err != nil {
+	return cb.DecompressLimit(DefaultMaxDecompressedSize)
+}
+
+// DecompressLimit reconstructs the block's data, refusing blocks that would
+// produce more than maxSize values
+func (cb *CompressedBlock) DecompressLimit(maxSize int) ([]float64, error) {
+	if err := cb.Validate(maxSize); err != nil {
 		return nil, err
 	}
 
-	result := make([]float64, cb.OriginalRows*cb.OriginalCols)
+	result := make([]float64, 0, cb.OriginalSize)
+	err := cb.DecompressRows(maxSize, func(values []float64) error {
+		result = append(result, values...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DecompressRows reconstructs the block a row at a time, passing each row's
+// values to emit, so the output is never held whole. Rows past OriginalSize
+// are trimmed. emit must not keep the slice, which is reused.
+func (cb *CompressedBlock) DecompressRows(maxSize int, emit func(values []float64) error) error {
+	if err := cb.Validate(maxSize); err != nil {
+		return err
+	}
 
-	// Reconstruct using available components
-	for i := 0; i < cb.OriginalRows; i++ {
-		for j := 0; j < cb.OriginalCols; j++ {
+	row := make([]float64, cb.OriginalCols)
+	remaining := cb.OriginalSize
+	for i := 0; i < cb.OriginalRows && remaining > 0; i++ {
+		for j := range row {
 			var sum float64
-			for k := 0; k < len(cb.S); k++ {
+			for k := range cb.S {
 				sum += cb.S[k] * cb.U[k][i] * cb.V[k][j]
 			}
-			result[i*cb.OriginalCols+j] = sum
+			row[j] = sum
+		}
+		n := min(len(row), remaining)
+		if err := emit(row[:n]); err != nil {
+			return err
 		}
+		remaining -= n
 	}
+	return nil
+}
 
-	// Return exact size
-	if len(result) > cb.OriginalSize {
-		result = result[:cb.OriginalSize]
+// Validate checks a block's header and components against each other and
+// against a limit on the values it decompresses to, without allocating.
+// Blocks from peers or clients must pass before they are decompressed.
+func (cb *CompressedBlock) Validate(maxSize int) error {
+	if err := validateCompressedBlock(cb); err != nil {
+		return err
 	}
 
-	return result, nil
+	rows, cols := cb.OriginalRows, cb.OriginalCols
+	if rows <= 0 || cols <= 0 {
+		return fmt.Errorf("invalid dimensions: %dx%d", rows, cols)
+	}
+	if rows > maxSize/cols {
+		return fmt.Errorf("%w: %dx%d matrix over %d values", ErrDecompressionLimit, rows, cols, maxSize)
+	}
+	if cb.OriginalSize <= 0 || cb.OriginalSize > rows*cols {
+		return fmt.Errorf("invalid original size %d for %dx%d matrix", cb.OriginalSize, rows, cols)
+	}
+	if len(cb.S) > min(rows, cols) {
+		return fmt.Errorf("rank %d exceeds %dx%d matrix", len(cb.S), rows, cols)
+	}
+
+	for k := range cb.S {
+		if len(cb.U[k]) != rows || len(cb.V[k]) != cols {
+			return fmt.Errorf("component %d has %d and %d elements for a %dx%d matrix",
+				k, len(cb.U[k]), len(cb.V[k]), rows, cols)
+		}
+		if math.IsNaN(cb.S[k]) || math.IsInf(cb.S[k], 0) {
+			return fmt.Errorf("singular value %d is not finite", k)
+		}
+	}
+	return nil
 }
 
 func evaluateRank1Quality(singularValues []float64) float64 {
@@ -443,17 +569,3 @@ func quantizeVector(vec []float64, tolerance float64) []float64 {
 
 	return result
 }
-
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
-
-func max(a, b int) int {
-	if a > b {
-		return a
-	}
-	return b
-}
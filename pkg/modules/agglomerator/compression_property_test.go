@@ -0,0 +1,165 @@
+package agglomerator
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/leanovate/gopter"
+	"github.com/leanovate/gopter/gen"
+	"github.com/leanovate/gopter/prop"
+	"gonum.org/v1/gonum/mat"
+)
+
+// propertyData returns size values, each zero with probability sparsity
+func propertyData(size int, sparsity float64, seed int64) []float64 {
+	rng := rand.New(rand.NewSource(seed))
+	data := make([]float64, size)
+	for i := range data {
+		if rng.Float64() >= sparsity {
+			data[i] = rng.NormFloat64() * 10
+		}
+	}
+	return data
+}
+
+// discardedEnergy returns the squared singular values of the block's padded
+// matrix beyond the rank it kept, and the matrix's total energy. By
+// Eckart-Young a truncated SVD misses the padded matrix by exactly the
+// discarded energy, so the logical data is never off by more.
+func discardedEnergy(data []float64, block *CompressedBlock) (discarded, total float64) {
+	padded := make([]float64, block.OriginalRows*block.OriginalCols)
+	copy(padded, data)
+	var svd mat.SVD
+	svd.Factorize(mat.NewDense(block.OriginalRows, block.OriginalCols, padded), mat.SVDNone)
+	for i, s := range svd.Values(nil) {
+		total += s * s
+		if i >= len(block.S) {
+			discarded += s * s
+		}
+	}
+	return discarded, total
+}
+
+func TestCompressionRoundTripProperties(t *testing.T) {
+	parameters := gopter.DefaultTestParameters()
+	parameters.MinSuccessfulTests = 300
+	properties := gopter.NewProperties(parameters)
+
+	sizes := gen.IntRange(1, 400)
+	ranks := gen.IntRange(1, 24)
+	sparsities := gen.Float64Range(0, 1)
+
+	properties.Property("round trip keeps the length and misses by at most the discarded energy", prop.ForAll(
+		func(size, maxRank int, sparsity float64, forceMaxRank bool, seed int64) bool {
+			data := propertyData(size, sparsity, seed)
+			compressor := NewAdaptiveCompressor(CompressorConfig{MaxRank: maxRank, EnergyThreshold: 0.95, ForceMaxRank: forceMaxRank})
+			block, err := compressor.CompressBlock(data)
+			if err != nil {
+				return false
+			}
+			values, err := block.Decompress()
+			if err != nil || len(values) != size {
+				return false
+			}
+
+			var missed float64
+			for i := range data {
+				missed += (data[i] - values[i]) * (data[i] - values[i])
+			}
+			discarded, total := discardedEnergy(data, block)
+			return missed <= discarded+1e-9*(1+total)
+		},
+		sizes, ranks, sparsities, gen.Bool(), gen.Int64(),
+	))
+
+	properties.Property("full rank reconstructs exactly, padding included", prop.ForAll(
+		func(size int, sparsity float64, seed int64) bool {
+			data := propertyData(size, sparsity, seed)
+			rows := int(math.Sqrt(float64(size)))
+			compressor := NewAdaptiveCompressor(CompressorConfig{MaxRank: rows, EnergyThreshold: 1, ForceMaxRank: true})
+			block, err := compressor.CompressBlock(data)
+			if err != nil {
+				return false
+			}
+			values, err := block.Decompress()
+			if err != nil || len(values) != size {
+				return false
+			}
+			tolerance := 1e-9 * (1 + math.Sqrt(energy(data)))
+			for i := range data {
+				if math.Abs(data[i]-values[i]) > tolerance {
+					return false
+				}
+			}
+			return true
+		},
+		sizes, sparsities, gen.Int64(),
+	))
+
+	// Sizes that do not fill their rows*cols matrix, so CompressBlock pads
+	paddedSizes := gen.IntRange(2, 400).SuchThat(func(size int) bool {
+		return size%int(math.Sqrt(float64(size))) != 0
+	})
+
+	properties.Property("padded blocks never decompress their padding", prop.ForAll(
+		func(size, maxRank int, seed int64) bool {
+			data := propertyData(size, 0, seed)
+			block, err := NewAdaptiveCompressor(CompressorConfig{MaxRank: maxRank, EnergyThreshold: 0.95}).CompressBlock(data)
+			if err != nil || block.OriginalRows*block.OriginalCols <= size {
+				return false
+			}
+
+			emitted := 0
+			err = block.DecompressRows(DefaultMaxDecompressedSize, func(values []float64) error {
+				emitted += len(values)
+				return nil
+			})
+			return err == nil && emitted == size
+		},
+		paddedSizes, ranks, gen.Int64(),
+	))
+
+	properties.Property("a stream decompresses to its blocks in order", prop.ForAll(
+		func(blockSizes []int, seed int64) bool {
+			compressor := NewAdaptiveCompressor(CompressorConfig{MaxRank: 4, EnergyThreshold: 0.95})
+			var blocks []*CompressedBlock
+			var want []float64
+			for i, size := range blockSizes {
+				block, err := compressor.CompressBlock(propertyData(size, 0.2, seed+int64(i)))
+				if err != nil {
+					return false
+				}
+				values, err := block.Decompress()
+				if err != nil {
+					return false
+				}
+				blocks = append(blocks, block)
+				want = append(want, values...)
+			}
+
+			got, err := compressor.DecompressStream(blocks)
+			if err != nil || len(got) != len(want) {
+				return false
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					return false
+				}
+			}
+			return true
+		},
+		gen.SliceOfN(5, gen.IntRange(1, 100)), gen.Int64(),
+	))
+
+	properties.TestingRun(t)
+}
+
+// energy returns the sum of squares of data
+func energy(data []float64) float64 {
+	var sum float64
+	for _, v := range data {
+		sum += v * v
+	}
+	return sum
+}
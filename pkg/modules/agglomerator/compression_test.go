@@ -1,6 +1,7 @@
 package agglomerator
 
 import (
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	_ "gonum.org/v1/gonum/mat"
@@ -205,3 +206,54 @@ func flattenMatrix(matrix [][]float64) []float64 {
 	}
 	return flattened
 }
+
+func TestDecompressRejectsHostileBlocks(t *testing.T) {
+	compressor := NewAdaptiveCompressor(CompressorConfig{Tolerance: 0.01, MaxRank: 3, EnergyThreshold: 0.95, MaxDecompressedSize: 1000})
+	valid, err := compressor.CompressBlock(generateTestData(95))
+	require.NoError(t, err)
+	require.NoError(t, valid.Validate(compressor.MaxDecompressedSize()))
+
+	tests := []struct {
+		name   string
+		modify func(cb *CompressedBlock)
+		limit  bool
+	}{
+		{"huge dimensions", func(cb *CompressedBlock) { cb.OriginalRows, cb.OriginalCols = 1<<40, 1<<40 }, true},
+		{"over the limit", func(cb *CompressedBlock) { cb.OriginalRows, cb.OriginalCols = 1001, 1 }, true},
+		{"negative dimensions", func(cb *CompressedBlock) { cb.OriginalRows = -10 }, false},
+		{"size beyond the matrix", func(cb *CompressedBlock) { cb.OriginalSize = cb.OriginalRows*cb.OriginalCols + 1 }, false},
+		{"short singular vector", func(cb *CompressedBlock) { cb.U[0] = cb.U[0][:1] }, false},
+		{"non-finite singular value", func(cb *CompressedBlock) { cb.S[0] = math.Inf(1) }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block := *valid
+			block.U = append([][]float64(nil), valid.U...)
+			block.S = append([]float64(nil), valid.S...)
+			tt.modify(&block)
+
+			_, err := compressor.DecompressStream([]*CompressedBlock{&block})
+			require.Error(t, err)
+			assert.Equal(t, tt.limit, errors.Is(err, ErrDecompressionLimit))
+			assert.NotPanics(t, func() { block.Decompress() })
+		})
+	}
+
+	// The budget covers the whole stream
+	_, err = compressor.DecompressStream([]*CompressedBlock{valid, valid, valid})
+	require.NoError(t, err)
+	_, err = compressor.DecompressStream(make([]*CompressedBlock, 12))
+	assert.Error(t, err)
+	blocks := []*CompressedBlock{valid, valid, valid, valid, valid, valid, valid, valid, valid, valid, valid, valid}
+	_, err = compressor.DecompressStream(blocks)
+	assert.ErrorIs(t, err, ErrDecompressionLimit)
+
+	var rows, values int
+	require.NoError(t, valid.DecompressRows(100, func(row []float64) error {
+		rows++
+		values += len(row)
+		return nil
+	}))
+	assert.Equal(t, valid.OriginalRows, rows)
+	assert.Equal(t, 95, values, "padding past the original size is trimmed")
+}
@@ -0,0 +1,518 @@
+package agglomerator
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigError describes a single invalid configuration field
+type ConfigError struct {
+	Field   string
+	Message string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// configValidator accumulates problems so all of them can be reported at once
+type configValidator struct {
+	errs []error
+}
+
+func (v *configValidator) fail(field, format string, args ...interface{}) {
+	v.errs = append(v.errs, &ConfigError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *configValidator) duration(field, value string, required bool) {
+	if value == "" {
+		if required {
+			v.fail(field, "required")
+		}
+		return
+	}
+	if d, err := parseDuration(value); err != nil {
+		v.fail(field, "invalid duration %q", value)
+	} else if d <= 0 {
+		v.fail(field, "must be positive")
+	}
+}
+
+func (v *configValidator) size(field, value string) {
+	if value == "" {
+		return
+	}
+	if n, err := parseByteSize(value); err != nil || n <= 0 {
+		v.fail(field, "invalid size %q", value)
+	}
+}
+
+func (v *configValidator) fraction(field string, value float64) {
+	if value < 0 || value > 1 {
+		v.fail(field, "must be between 0 and 1, got %v", value)
+	}
+}
+
+// Validate checks the configuration and returns every problem found
+func (c *ModuleConfig) Validate() []error {
+	v := &configValidator{}
+
+	if c.NodeID == "" {
+		v.fail("nodeID", "required")
+	}
+	if c.VectorDims <= 0 {
+		v.fail("vectorDims", "must be positive, got %d", c.VectorDims)
+	}
+	if c.SimThreshold < -1 || c.SimThreshold > 1 {
+		v.fail("simThreshold", "must be between -1 and 1, got %v", c.SimThreshold)
+	}
+	switch c.LogLevel {
+	case "", "debug", "info", "warn", "error":
+	default:
+		v.fail("logLevel", "unknown level %q", c.LogLevel)
+	}
+
+	seen := make(map[string]bool)
+	for i, chain := range c.EnabledChains {
+		field := fmt.Sprintf("enabledChains[%d]", i)
+		if chain.ID == "" {
+			v.fail(field+".id", "required")
+		} else if seen[chain.ID] {
+			v.fail(field+".id", "duplicate chain %q", chain.ID)
+		}
+		seen[chain.ID] = true
+
+		if _, exists := getProtocolConfig(chain.Protocol); !exists {
+			v.fail(field+".protocol", "unknown protocol %q", chain.Protocol)
+		}
+		if err := validateEndpoint(chain.Endpoint); err != nil {
+			v.fail(field+".endpoint", "%v", err)
+		} else if _, err := newChainAdapter(chain.Protocol, chain.ID, chain.Endpoint); err != nil {
+			v.fail(field+".endpoint", "%v", err)
+		}
+		for j, endpoint := range chain.Endpoints {
+			endpointField := fmt.Sprintf("%s.endpoints[%d]", field, j)
+			if err := validateEndpoint(endpoint); err != nil {
+				v.fail(endpointField, "%v", err)
+			} else if _, err := newChainAdapter(chain.Protocol, chain.ID, endpoint); err != nil {
+				v.fail(endpointField, "%v", err)
+			}
+		}
+	}
+
+	// P2P
+	if c.P2P.Port < 0 || c.P2P.Port > 65535 {
+		v.fail("p2p.port", "out of range: %d", c.P2P.Port)
+	}
+	// Unspecified addresses are allowed here, listening on every address
+	if address := c.P2P.Address; address != "" && net.ParseIP(address) == nil {
+		if _, err := normalizeHost(address); err != nil {
+			v.fail("p2p.address", "%v", err)
+		}
+	}
+	v.duration("p2p.discoveryInterval", c.P2P.DiscoveryInterval, false)
+	if c.P2P.MaxPeers < 0 {
+		v.fail("p2p.maxPeers", "must not be negative")
+	}
+	if rate := c.P2P.Reputation.DecayRate; rate != 0 {
+		v.fraction("p2p.reputation.decayRate", rate)
+	}
+	v.duration("p2p.reputation.decayInterval", c.P2P.Reputation.DecayInterval, false)
+	v.fraction("p2p.reputation.banThreshold", c.P2P.Reputation.BanThreshold)
+	v.duration("p2p.replay.maxClockSkew", c.P2P.Replay.MaxClockSkew, false)
+	if c.P2P.Bandwidth.PeerBytesPerSecond < 0 || c.P2P.Bandwidth.BurstBytes < 0 {
+		v.fail("p2p.bandwidth", "limits must not be negative")
+	}
+	if bits := c.P2P.Bloom.Bits; bits < 0 || bits > maxBloomBytes*8 {
+		v.fail("p2p.bloom.bits", "must be between 0 and %d", maxBloomBytes*8)
+	}
+	if hashes := c.P2P.Bloom.Hashes; hashes < 0 || hashes > maxBloomHashes {
+		v.fail("p2p.bloom.hashes", "must be between 0 and %d", maxBloomHashes)
+	}
+	v.duration("p2p.bloom.interval", c.P2P.Bloom.Interval, false)
+	v.duration("p2p.query.peerTimeout", c.P2P.Query.PeerTimeout, false)
+	if c.P2P.Query.Concurrency < 0 {
+		v.fail("p2p.query.concurrency", "must not be negative")
+	}
+	v.fraction("p2p.query.slowPenalty", c.P2P.Query.SlowPenalty)
+	if c.P2P.Query.CacheSize < 0 {
+		v.fail("p2p.query.cacheSize", "must not be negative")
+	}
+	if c.P2P.Query.Fanout < 0 {
+		v.fail("p2p.query.fanout", "must not be negative")
+	}
+	v.fraction("p2p.query.hedgeQuantile", c.P2P.Query.HedgeQuantile)
+	if _, err := ParseWriteConsistency(c.P2P.Writes.Consistency); err != nil {
+		v.fail("p2p.writes.consistency", "must be %s or %s", WriteFireAndForget, WriteAckQuorum)
+	}
+	if quorum := c.P2P.Writes.Quorum; quorum < 0 || quorum > replicationFactor {
+		v.fail("p2p.writes.quorum", "must be between 0 and %d", replicationFactor)
+	}
+	v.duration("p2p.writes.timeout", c.P2P.Writes.Timeout, false)
+	if c.P2P.Shards.Count < 0 {
+		v.fail("p2p.shards.count", "must not be negative")
+	}
+	if _, err := ParseShardStrategy(c.P2P.Shards.Strategy); err != nil {
+		v.fail("p2p.shards.strategy", "must be %s or %s", ShardByHash, ShardByProtocol)
+	}
+	if c.P2P.Shards.Replicas < 0 {
+		v.fail("p2p.shards.replicas", "must not be negative")
+	}
+	v.duration("p2p.shards.interval", c.P2P.Shards.Interval, false)
+	for i, peer := range c.P2P.BootstrapPeers {
+		if _, err := parseBootstrapPeer(peer); err != nil {
+			v.fail(fmt.Sprintf("p2p.bootstrapPeers[%d]", i), "%v", err)
+		}
+	}
+	if algorithms := c.P2P.SignatureAlgorithms; len(algorithms) > 0 {
+		if err := validateAlgorithms(algorithms); err != nil {
+			v.fail("p2p.signatureAlgorithms", "%v", err)
+		}
+	}
+	names := make(map[string]bool)
+	for i, anchor := range c.P2P.Attestation.TrustAnchors {
+		field := fmt.Sprintf("p2p.attestation.trustAnchors[%d]", i)
+		if _, _, err := parseTrustAnchor(anchor.Name, anchor.PublicKey); err != nil {
+			v.fail(field, "%v", err)
+		}
+		if names[anchor.Name] {
+			v.fail(field, "duplicate trust anchor %q", anchor.Name)
+		}
+		names[anchor.Name] = true
+	}
+	if a := c.P2P.Admission; a.Difficulty < 0 || a.Difficulty > maxAdmissionDifficulty {
+		v.fail("p2p.admission.difficulty", "must be between 0 and %d", maxAdmissionDifficulty)
+	}
+	if a := c.P2P.Admission; a.MinStake < 0 {
+		v.fail("p2p.admission.minStake", "must not be negative")
+	} else if a.MinStake > 0 && a.StakeOracle == "" {
+		v.fail("p2p.admission.stakeOracle", "is required with minStake")
+	}
+	zones := make(map[string]bool)
+	for i, zone := range c.P2P.PrivacyZones {
+		field := fmt.Sprintf("p2p.privacyZones[%d]", i)
+		if err := NewPrivacyZones().Add(zone.Name, zone.Key); err != nil {
+			v.fail(field, "%v", err)
+		}
+		if zones[zone.Name] {
+			v.fail(field, "duplicate privacy zone %q", zone.Name)
+		}
+		zones[zone.Name] = true
+	}
+	for i, chain := range c.EnabledChains {
+		if chain.Zone != "" && !zones[chain.Zone] {
+			v.fail(fmt.Sprintf("enabledChains[%d].zone", i), "unknown privacy zone %q", chain.Zone)
+		}
+	}
+	if t := c.P2P.Transport; t.Type != "" {
+		if _, err := NewTransport(TransportConfig{Type: t.Type, CertFile: t.CertFile, KeyFile: t.KeyFile, CAFile: t.CAFile}); err != nil {
+			v.fail("p2p.transport", "%v", err)
+		}
+	}
+
+	// Protocols
+	protocols := map[string]struct {
+		blockTime, costWeight float64
+		confirmations         int
+	}{
+		"btc": {c.Protocols.BTC.BlockTime, c.Protocols.BTC.CostWeight, c.Protocols.BTC.Confirmations},
+		"eth": {c.Protocols.ETH.BlockTime, c.Protocols.ETH.CostWeight, c.Protocols.ETH.Confirmations},
+		"sol": {c.Protocols.SOL.BlockTime, c.Protocols.SOL.CostWeight, c.Protocols.SOL.Confirmations},
+		"dot": {c.Protocols.DOT.BlockTime, c.Protocols.DOT.CostWeight, c.Protocols.DOT.Confirmations},
+	}
+	for name, p := range protocols {
+		if p.blockTime < 0 {
+			v.fail("protocols."+name+".blockTime", "must not be negative")
+		}
+		if p.confirmations < 0 {
+			v.fail("protocols."+name+".confirmations", "must not be negative")
+		}
+		v.fraction("protocols."+name+".costWeight", p.costWeight)
+	}
+
+	// Vector space
+	if c.VectorSpace.Dimensions < 0 {
+		v.fail("vectorSpace.dimensions", "must not be negative")
+	}
+	if t := c.VectorSpace.SimilarityThreshold; t < -1 || t > 1 {
+		v.fail("vectorSpace.similarityThreshold", "must be between -1 and 1, got %v", t)
+	}
+	if c.VectorSpace.Clusters < 0 {
+		v.fail("vectorSpace.clusters", "must not be negative")
+	}
+	if c.VectorSpace.CacheElements < 0 {
+		v.fail("vectorSpace.cacheElements", "must not be negative")
+	}
+	if c.VectorSpace.QueryCache < 0 {
+		v.fail("vectorSpace.queryCache", "must not be negative")
+	}
+	v.duration("vectorSpace.updateInterval", c.VectorSpace.UpdateInterval, false)
+	for protocol, source := range c.VectorSpace.Generators {
+		if _, err := CompileGenerator(source); err != nil {
+			v.fail("vectorSpace.generators."+protocol, "%v", err)
+		}
+	}
+
+	// Transactions
+	if c.Transactions.MaxBatchSize < 0 {
+		v.fail("transactions.maxBatchSize", "must not be negative")
+	}
+	if c.Transactions.RetryAttempts < 0 {
+		v.fail("transactions.retryAttempts", "must not be negative")
+	}
+	v.duration("transactions.processingTimeout", c.Transactions.ProcessingTimeout, false)
+	v.duration("transactions.retryInterval", c.Transactions.RetryInterval, false)
+	v.size("transactions.maxPayloadSize", c.Transactions.MaxPayloadSize)
+	v.size("transactions.inlinePayloadSize", c.Transactions.InlinePayloadSize)
+
+	if _, err := compilePolicy(parsePolicyConfig(c)); err != nil {
+		v.fail("policy", "%v", err)
+	}
+	v.duration("policy.reloadInterval", c.Policy.ReloadInterval, false)
+
+	assetsSeen := make(map[string]bool)
+	for i, asset := range c.Assets.Registry {
+		field := fmt.Sprintf("assets.registry[%d]", i)
+		if err := asset.validate(); err != nil {
+			v.fail(field, "%v", err)
+			continue
+		}
+		key := assetKey(asset.Chain, asset.Symbol)
+		if assetsSeen[key] {
+			v.fail(field, "duplicate asset %s", key)
+		}
+		assetsSeen[key] = true
+	}
+	for symbol, price := range c.Assets.Prices {
+		if price < 0 {
+			v.fail("assets.prices."+symbol, "must not be negative")
+		}
+	}
+	if feed := c.Assets.PriceFeed.URL; feed != "" {
+		if err := validateEndpoint(feed); err != nil {
+			v.fail("assets.priceFeed.url", "%v", err)
+		}
+	}
+	v.duration("assets.priceFeed.ttl", c.Assets.PriceFeed.TTL, false)
+
+	if registry := c.ChainRegistry.URL; registry != "" {
+		if err := validateEndpoint(registry); err != nil {
+			v.fail("chainRegistry.url", "%v", err)
+		}
+	}
+	v.duration("chainRegistry.interval", c.ChainRegistry.Interval, false)
+	if c.ChainRegistry.MaxEndpoints < 0 {
+		v.fail("chainRegistry.maxEndpoints", "must not be negative")
+	}
+
+	if c.Replica.Enabled {
+		if err := validateEndpoint(c.Replica.Primary); err != nil {
+			v.fail("replica.primary", "%v", err)
+		}
+		if c.P2P.Port > 0 {
+			v.fail("replica.enabled", "replicas do not join the P2P network; p2p.port must be 0")
+		}
+	}
+	v.duration("replica.pollInterval", c.Replica.PollInterval, false)
+
+	roles, err := ParseNodeRoles(c.Roles)
+	if err != nil {
+		v.fail("roles", "%v", err)
+	} else if !roles.Has(RoleRouter) && roles.Has(RoleGateway) && c.P2P.Port <= 0 {
+		v.fail("roles", "a gateway without the router role relays to router peers; p2p.port must be set")
+	}
+	if c.APIURL != "" {
+		if err := validateEndpoint(c.APIURL); err != nil {
+			v.fail("apiURL", "%v", err)
+		}
+	}
+
+	if c.Reconciliation.Enabled && c.Storage.Path == "" {
+		v.fail("reconciliation.enabled", "requires storage.path")
+	}
+	if c.Reconciliation.MinConfirmations < 0 {
+		v.fail("reconciliation.minConfirmations", "must not be negative")
+	}
+	v.duration("reconciliation.interval", c.Reconciliation.Interval, false)
+	v.duration("reconciliation.timeout", c.Reconciliation.Timeout, false)
+
+	v.duration("simulation.timeout", c.Simulation.Timeout, false)
+
+	// Sponsored execution
+	v.duration("sponsors.period", c.Sponsors.Period, false)
+	if c.Sponsors.Enabled && len(c.Sponsors.Wallets) == 0 {
+		v.fail("sponsors.wallets", "at least one sponsor wallet is required")
+	}
+	for chainID, address := range c.Sponsors.Wallets {
+		if address == "" {
+			v.fail("sponsors.wallets."+chainID, "address is required")
+		}
+	}
+	for tenant, budget := range c.Sponsors.Budgets {
+		if budget < 0 {
+			v.fail("sponsors.budgets."+tenant, "must not be negative")
+		}
+	}
+	if c.Sponsors.DefaultBudget < 0 {
+		v.fail("sponsors.defaultBudget", "must not be negative")
+	}
+
+	if c.Pool.MaxSize < 0 {
+		v.fail("pool.maxSize", "must not be negative")
+	}
+	switch c.Pool.Policy {
+	case "", PoolPolicyReject, PoolPolicyEvict:
+	default:
+		v.fail("pool.policy", "unknown policy %q", c.Pool.Policy)
+	}
+	for chainID, limit := range c.Pool.Limits {
+		if limit < 0 {
+			v.fail("pool.limits."+chainID, "must not be negative")
+		}
+	}
+	v.size("p2p.chunkSize", c.P2P.ChunkSize)
+
+	// Anomaly detection
+	if c.Anomaly.Clusters < 0 {
+		v.fail("anomaly.clusters", "must not be negative")
+	}
+	if t := c.Anomaly.Threshold; t < -1 || t > 1 {
+		v.fail("anomaly.threshold", "must be between -1 and 1, got %v", t)
+	}
+	if c.Anomaly.MinSamples < 0 {
+		v.fail("anomaly.minSamples", "must not be negative")
+	}
+	if webhook := c.Anomaly.AlertWebhook; webhook != "" {
+		if err := validateEndpoint(webhook); err != nil {
+			v.fail("anomaly.alertWebhook", "%v", err)
+		}
+	}
+
+	// Endpoint health
+	v.duration("endpointHealth.interval", c.EndpointHealth.Interval, false)
+	v.duration("endpointHealth.timeout", c.EndpointHealth.Timeout, false)
+	if c.EndpointHealth.FailureThreshold < 0 {
+		v.fail("endpointHealth.failureThreshold", "must not be negative")
+	}
+
+	// Canary chains
+	v.fraction("canary.fraction", c.Canary.Fraction)
+	v.duration("canary.warmUp", c.Canary.WarmUp, false)
+	v.duration("canary.checkInterval", c.Canary.CheckInterval, false)
+	if c.Canary.MinSamples < 0 {
+		v.fail("canary.minSamples", "must not be negative")
+	}
+	v.fraction("canary.minSuccessRate", c.Canary.MinSuccessRate)
+	v.fraction("canary.minHealth", c.Canary.MinHealth)
+
+	// Pre-routing
+	v.duration("preRouting.interval", c.PreRouting.Interval, false)
+	v.duration("preRouting.lead", c.PreRouting.Lead, false)
+	v.duration("preRouting.quietPeriod", c.PreRouting.QuietPeriod, false)
+	if c.PreRouting.MinRate < 0 {
+		v.fail("preRouting.minRate", "must not be negative")
+	}
+
+	// Overload shedding
+	if c.Overload.MaxInFlight < 0 {
+		v.fail("overload.maxInFlight", "must not be negative")
+	}
+	v.duration("overload.maxP99Latency", c.Overload.MaxP99Latency, false)
+	v.duration("overload.checkInterval", c.Overload.CheckInterval, false)
+	v.size("overload.maxHeap", c.Overload.MaxHeap)
+	if c.Overload.Enabled && c.Overload.MaxInFlight == 0 && c.Overload.MaxP99Latency == "" && c.Overload.MaxHeap == "" {
+		v.fail("overload", "enabled without maxInFlight, maxP99Latency or maxHeap")
+	}
+
+	// Compaction and GC
+	if c.Compaction.Interval != "" {
+		v.duration("compaction.interval", c.Compaction.Interval, true)
+		v.duration("compaction.maxAge", c.Compaction.MaxAge, true)
+	}
+	if c.GC.Interval != "" {
+		v.duration("gc.interval", c.GC.Interval, true)
+		for store, ttl := range c.GC.Retention {
+			v.duration("gc.retention."+store, ttl, true)
+		}
+	}
+
+	// Storage and metrics
+	v.duration("storage.backupInterval", c.Storage.BackupInterval, false)
+	if c.Storage.MaxSize != "" {
+		if _, err := parseByteSize(c.Storage.MaxSize); err != nil {
+			v.fail("storage.maxSize", "%v", err)
+		}
+	}
+	if c.Storage.Tiering.Enabled {
+		if c.Storage.Path == "" {
+			v.fail("storage.tiering.enabled", "requires storage.path")
+		}
+		if n, err := parseByteSize(c.Storage.Tiering.MemoryBudget); err != nil {
+			v.fail("storage.tiering.memoryBudget", "%v", err)
+		} else if n <= 0 {
+			v.fail("storage.tiering.memoryBudget", "must be positive")
+		}
+	}
+	v.duration("metrics.interval", c.Metrics.Interval, false)
+	v.duration("metrics.retention", c.Metrics.Retention, false)
+
+	return v.errs
+}
+
+// validateEndpoint checks that a chain endpoint is an absolute URL
+func validateEndpoint(endpoint string) error {
+	if endpoint == "" {
+		return fmt.Errorf("required")
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %v", endpoint, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("endpoint %q must include scheme and host", endpoint)
+	}
+	return nil
+}
+
+// parseDuration extends time.ParseDuration with a "d" (day) suffix, as used
+// by retention settings such as "7d"
+func parseDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(value)
+}
+
+// parseByteSize parses sizes such as "512MB" or "10GB"
+func parseByteSize(value string) (int64, error) {
+	units := []struct {
+		suffix string
+		factor int64
+	}{
+		{"TB", 1 << 40},
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	}
+
+	upper := strings.ToUpper(strings.TrimSpace(value))
+	for _, unit := range units {
+		if number, ok := strings.CutSuffix(upper, unit.suffix); ok {
+			n, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid size %q", value)
+			}
+			return int64(n * float64(unit.factor)), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid size %q", value)
+}
@@ -0,0 +1,388 @@
+package agglomerator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+var (
+	ErrConsistencyNotMet  = errors.New("consistency level not met")
+	ErrInvalidConsistency = errors.New("invalid consistency level")
+)
+
+// ReadConsistency is how many peers a query must hear from
+type ReadConsistency string
+
+const (
+	ReadAny    ReadConsistency = "any"    // Local records and whichever peers answer; the default
+	ReadLocal  ReadConsistency = "local"  // Local records only, without asking peers
+	ReadQuorum ReadConsistency = "quorum" // A majority of peers must answer
+	ReadAll    ReadConsistency = "all"    // Every peer must answer
+)
+
+// WriteConsistency is how many replicas a write must reach before it
+// returns
+type WriteConsistency string
+
+const (
+	WriteFireAndForget WriteConsistency = "fire-and-forget" // Queued for the replicas; the default
+	WriteAckQuorum     WriteConsistency = "ack-quorum"      // A majority of the replicas acknowledge it
+)
+
+// ParseReadConsistency parses a read level; empty is ReadAny
+func ParseReadConsistency(level string) (ReadConsistency, error) {
+	switch ReadConsistency(level) {
+	case "":
+		return ReadAny, nil
+	case ReadAny, ReadLocal, ReadQuorum, ReadAll:
+		return ReadConsistency(level), nil
+	}
+	return "", fmt.Errorf("%w: read %q", ErrInvalidConsistency, level)
+}
+
+// ParseWriteConsistency parses a write level; empty is WriteFireAndForget
+func ParseWriteConsistency(level string) (WriteConsistency, error) {
+	switch WriteConsistency(level) {
+	case "":
+		return WriteFireAndForget, nil
+	case WriteFireAndForget, WriteAckQuorum:
+		return WriteConsistency(level), nil
+	}
+	return "", fmt.Errorf("%w: write %q", ErrInvalidConsistency, level)
+}
+
+// WriteConfig sets the level the agglomerator writes chain registrations
+// and transactions at, and how acknowledged writes are counted
+type WriteConfig struct {
+	Consistency WriteConsistency
+	Quorum      int           // Acknowledgments required; 0 is a majority of the replicas
+	Timeout     time.Duration // How long each replica has to acknowledge a write
+}
+
+// DefaultWriteConfig returns fire-and-forget writes
+func DefaultWriteConfig() WriteConfig {
+	return WriteConfig{
+		Consistency: WriteFireAndForget,
+		Timeout:     2 * time.Second,
+	}
+}
+
+// SetWriteConfig replaces the write settings. It must be called before
+// Start.
+func (node *P2PInfiniteVectorNode) SetWriteConfig(config WriteConfig) {
+	node.writeConfig = config
+}
+
+// WriteConfig returns the write settings
+func (node *P2PInfiniteVectorNode) WriteConfig() WriteConfig {
+	return node.writeConfig
+}
+
+// required returns how many of n replicas must acknowledge a write
+func (config WriteConfig) required(n int) int {
+	if config.Quorum > 0 {
+		return config.Quorum
+	}
+	return quorum(n)
+}
+
+// PeerWriter stores a record on one peer, returning once the peer has
+// acknowledged it. It should return when ctx is done.
+type PeerWriter interface {
+	StorePeer(ctx context.Context, msg DataTransferMessage) error
+}
+
+// UsePeerWriter sends acknowledged writes through writer. It must be called
+// before Start; without one, writes go over the node's transport and peers
+// send back acknowledgments, or under simulated delivery every replica is
+// taken to acknowledge them.
+func (node *P2PInfiniteVectorNode) UsePeerWriter(writer PeerWriter) {
+	node.writer = writer
+}
+
+// WriteResult is the outcome of storing a record on the network
+type WriteResult struct {
+	ID           string           `json:"id"`
+	Consistency  WriteConsistency `json:"consistency"`
+	Replicas     []string         `json:"replicas"`
+	Required     int              `json:"required"` // Acknowledgments the level needs
+	Acknowledged []string         `json:"acknowledged,omitempty"`
+	Failed       []string         `json:"failed,omitempty"`
+}
+
+// quorum returns how many of n peers make a majority
+func quorum(n int) int {
+	if n == 0 {
+		return 0
+	}
+	return n/2 + 1
+}
+
+// Store adds a record to the local database and replicates it. With
+// WriteAckQuorum it returns once WriteConfig.Quorum replicas, a majority by
+// default, have acknowledged the record, each within WriteConfig.Timeout,
+// and fails with ErrConsistencyNotMet when too few do; the record stays
+// stored locally and on the replicas that took it, so the write can be
+// retried. A node without peers meets every level. When records are
+// sharded, the replicas are the other owners of the record's shard.
+func (node *P2PInfiniteVectorNode) Store(ctx context.Context, record vectors.DatabaseRecord, level WriteConsistency) (WriteResult, error) {
+	start := time.Now()
+	result, err := node.store(ctx, record, level)
+	node.consistency.observeWrite(result.Consistency, time.Since(start), err)
+	return result, err
+}
+
+func (node *P2PInfiniteVectorNode) store(ctx context.Context, record vectors.DatabaseRecord, level WriteConsistency) (WriteResult, error) {
+	level, err := ParseWriteConsistency(string(level))
+	if err != nil {
+		return WriteResult{ID: record.ID}, err
+	}
+	result := WriteResult{ID: record.ID, Consistency: level, Replicas: make([]string, 0, replicationFactor)}
+
+	// Sharded records go to the owners of their shard, and are only kept
+	// here if this node is one. Nodes without the storage role keep only
+	// what no replica can take.
+	keep := true
+	switch {
+	case node.sharded(record):
+		result.Replicas, keep = node.shardReplicas(record)
+	case node.flagEnabled(FlagReplication):
+		for _, peer := range node.selectReplicationPeers(replicationFactor) {
+			result.Replicas = append(result.Replicas, peer.NodeID)
+		}
+	}
+	if !node.roles.Roles.Has(RoleStorage) && record.Metadata["type"] != "chain_registration" {
+		keep = len(result.Replicas) == 0
+	}
+	if keep {
+		node.localDatabase.put(record)
+	}
+
+	payload := node.serializeRecord(record)
+	config := node.writeConfig
+	writer := node.writer
+	if writer == nil && node.hasTransport() {
+		writer = wireWriter{node: node, priority: recordPriority(record)}
+	}
+	if level == WriteFireAndForget {
+		for _, peerID := range result.Replicas {
+			node.send(peerID, record.ID, payload, recordPriority(record))
+		}
+		return result, nil
+	}
+
+	if len(result.Replicas) > 0 {
+		result.Required = config.required(len(result.Replicas))
+	}
+	if result.Required > len(result.Replicas) {
+		return result, fmt.Errorf("%w: %d acknowledgments of %s required, %d replicas available",
+			ErrConsistencyNotMet, result.Required, record.ID, len(result.Replicas))
+	}
+	if writer == nil {
+		// Simulated delivery reaches every replica
+		for _, peerID := range result.Replicas {
+			node.send(peerID, record.ID, payload, recordPriority(record))
+		}
+		result.Acknowledged = append([]string(nil), result.Replicas...)
+		return result, nil
+	}
+
+	acks := make(chan peerAnswer, len(result.Replicas))
+	for _, peerID := range result.Replicas {
+		msg := DataTransferMessage{
+			SenderID:    node.NodeID,
+			RecipientID: peerID,
+			DataID:      record.ID,
+			Payload:     payload,
+			Timestamp:   time.Now(),
+			Epoch:       node.replayGuard.Epoch(),
+			Sequence:    node.replayGuard.NextSequence(peerID),
+		}
+		// Replicas past the quorum still get the record after Store returns
+		peerCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), config.Timeout)
+		go func() {
+			defer cancel()
+			acks <- peerAnswer{peerID: peerID, err: writer.StorePeer(peerCtx, msg)}
+		}()
+	}
+
+	for pending := len(result.Replicas); pending > 0 && len(result.Acknowledged) < result.Required; pending-- {
+		select {
+		case ack := <-acks:
+			if ack.err != nil {
+				result.Failed = append(result.Failed, ack.peerID)
+			} else {
+				result.Acknowledged = append(result.Acknowledged, ack.peerID)
+			}
+		case <-ctx.Done():
+			return result, ctx.Err()
+		}
+	}
+	sort.Strings(result.Acknowledged)
+	sort.Strings(result.Failed)
+	if len(result.Acknowledged) < result.Required {
+		return result, fmt.Errorf("%w: %d of %d replicas acknowledged %s, %d required",
+			ErrConsistencyNotMet, len(result.Acknowledged), len(result.Replicas), record.ID, result.Required)
+	}
+	return result, nil
+}
+
+// writeDataPrefix marks records the recipient acknowledges once stored, and
+// ackDataPrefix the acknowledgments; both are followed by the write's ID.
+// Peers that predate acknowledgments store the record without answering.
+const (
+	writeDataPrefix = "write:"
+	ackDataPrefix   = "ack:"
+)
+
+// wireWriter sends acknowledged writes over the node's transport
+type wireWriter struct {
+	node     *P2PInfiniteVectorNode
+	priority MessagePriority
+}
+
+func (w wireWriter) StorePeer(ctx context.Context, msg DataTransferMessage) error {
+	writeID := core.NewID()
+	acked := w.node.expectAck(writeID, msg.RecipientID)
+	defer w.node.forgetAck(writeID)
+
+	w.node.send(msg.RecipientID, writeDataPrefix+writeID, msg.Payload, w.priority)
+	select {
+	case <-acked:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pendingAck is a write waiting for its replica's acknowledgment
+type pendingAck struct {
+	peerID string
+	acked  chan struct{}
+}
+
+func (node *P2PInfiniteVectorNode) expectAck(writeID, peerID string) <-chan struct{} {
+	node.ackMu.Lock()
+	defer node.ackMu.Unlock()
+	if node.pendingAcks == nil {
+		node.pendingAcks = make(map[string]pendingAck)
+	}
+	pending := pendingAck{peerID: peerID, acked: make(chan struct{})}
+	node.pendingAcks[writeID] = pending
+	return pending.acked
+}
+
+func (node *P2PInfiniteVectorNode) forgetAck(writeID string) {
+	node.ackMu.Lock()
+	defer node.ackMu.Unlock()
+	delete(node.pendingAcks, writeID)
+}
+
+// receiveAck completes a pending write, provided the acknowledgment comes
+// from the replica it was sent to
+func (node *P2PInfiniteVectorNode) receiveAck(senderID, writeID string) {
+	node.ackMu.Lock()
+	defer node.ackMu.Unlock()
+	pending, exists := node.pendingAcks[writeID]
+	if !exists || pending.peerID != senderID {
+		return
+	}
+	delete(node.pendingAcks, writeID)
+	close(pending.acked)
+}
+
+// recordPriority is the lane a replicated record is sent on: chain
+// registrations are gossip and must not queue behind bulk replication
+func recordPriority(record vectors.DatabaseRecord) MessagePriority {
+	if record.Metadata["type"] == "chain_registration" {
+		return PriorityControl
+	}
+	return PriorityBulk
+}
+
+// ConsistencyStats counts the operations run at one consistency level.
+// Latency quantiles are zero until enough operations have been timed.
+type ConsistencyStats struct {
+	Operations uint64        `json:"operations"`
+	Unmet      uint64        `json:"unmet"` // Operations that failed to reach the level
+	P50Latency time.Duration `json:"p50Latency"`
+	P99Latency time.Duration `json:"p99Latency"`
+}
+
+// ConsistencyReport holds the stats of reads and writes by level
+type ConsistencyReport struct {
+	Reads  map[string]ConsistencyStats `json:"reads"`
+	Writes map[string]ConsistencyStats `json:"writes"`
+}
+
+// consistencyMetrics times reads and writes by level
+type consistencyMetrics struct {
+	mu     sync.Mutex
+	reads  map[string]*levelMetrics
+	writes map[string]*levelMetrics
+}
+
+type levelMetrics struct {
+	operations uint64
+	unmet      uint64
+	latencies  latencyWindow
+}
+
+func (m *consistencyMetrics) observeRead(level ReadConsistency, latency time.Duration, err error) {
+	m.observe(&m.reads, string(level), latency, err)
+}
+
+func (m *consistencyMetrics) observeWrite(level WriteConsistency, latency time.Duration, err error) {
+	m.observe(&m.writes, string(level), latency, err)
+}
+
+func (m *consistencyMetrics) observe(levels *map[string]*levelMetrics, level string, latency time.Duration, err error) {
+	if level == "" {
+		// The level was invalid
+		return
+	}
+	m.mu.Lock()
+	if *levels == nil {
+		*levels = make(map[string]*levelMetrics)
+	}
+	metrics, exists := (*levels)[level]
+	if !exists {
+		metrics = &levelMetrics{}
+		(*levels)[level] = metrics
+	}
+	metrics.operations++
+	if errors.Is(err, ErrConsistencyNotMet) {
+		metrics.unmet++
+	}
+	m.mu.Unlock()
+	metrics.latencies.add(latency)
+}
+
+func (m *consistencyMetrics) report() ConsistencyReport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return ConsistencyReport{Reads: levelStats(m.reads), Writes: levelStats(m.writes)}
+}
+
+func levelStats(levels map[string]*levelMetrics) map[string]ConsistencyStats {
+	stats := make(map[string]ConsistencyStats, len(levels))
+	for level, metrics := range levels {
+		levelStats := ConsistencyStats{Operations: metrics.operations, Unmet: metrics.unmet}
+		levelStats.P50Latency, _ = metrics.latencies.quantile(0.5)
+		levelStats.P99Latency, _ = metrics.latencies.quantile(0.99)
+		stats[level] = levelStats
+	}
+	return stats
+}
+
+// ConsistencyStats reports reads and writes by consistency level
+func (node *P2PInfiniteVectorNode) ConsistencyStats() ConsistencyReport {
+	return node.consistency.report()
+}
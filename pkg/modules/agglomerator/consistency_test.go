@@ -0,0 +1,212 @@
+package agglomerator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+// fakeWriter acknowledges writes except from broken and slow peers
+type fakeWriter struct {
+	mu     sync.Mutex
+	stored map[string][]string // Record IDs by peer
+	slow   map[string]bool     // Wait for the deadline
+	broken map[string]bool
+}
+
+func (w *fakeWriter) StorePeer(ctx context.Context, msg DataTransferMessage) error {
+	switch peerID := msg.RecipientID; {
+	case w.slow[peerID]:
+		<-ctx.Done()
+		return ctx.Err()
+	case w.broken[peerID]:
+		return errors.New("connection reset")
+	default:
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if w.stored == nil {
+			w.stored = make(map[string][]string)
+		}
+		w.stored[peerID] = append(w.stored[peerID], msg.DataID)
+		return nil
+	}
+}
+
+func writeTestNode(writer PeerWriter, peers ...string) *P2PInfiniteVectorNode {
+	node := queryTestNode(&fakeQuerier{}, peers...)
+	node.UsePeerWriter(writer)
+	node.SetWriteConfig(WriteConfig{Timeout: 50 * time.Millisecond})
+	return node
+}
+
+func TestStoreWaitsForQuorum(t *testing.T) {
+	writer := &fakeWriter{broken: map[string]bool{"peer-c": true}}
+	node := writeTestNode(writer, "peer-a", "peer-b", "peer-c")
+	record := vectors.DatabaseRecord{ID: "record-1", Metadata: map[string]interface{}{"type": "transaction"}}
+
+	result, err := node.Store(context.Background(), record, WriteAckQuorum)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"peer-a", "peer-b", "peer-c"}, result.Replicas)
+	assert.Equal(t, 2, result.Required)
+	assert.Equal(t, []string{"peer-a", "peer-b"}, result.Acknowledged)
+	writer.mu.Lock()
+	assert.Equal(t, []string{"record-1"}, writer.stored["peer-a"])
+	writer.mu.Unlock()
+
+	local, err := node.QueryAt(context.Background(), ReadLocal, vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}, 10)
+	require.NoError(t, err)
+	assert.Zero(t, local.Queried, "local reads do not ask peers")
+	node.localDatabase.mu.RLock()
+	_, stored := node.localDatabase.records["record-1"]
+	node.localDatabase.mu.RUnlock()
+	assert.True(t, stored)
+
+	result, err = node.Store(context.Background(), record, WriteFireAndForget)
+	require.NoError(t, err, "fire-and-forget writes do not wait for replicas")
+	assert.Empty(t, result.Acknowledged)
+
+	_, err = node.Store(context.Background(), record, "eventually")
+	assert.ErrorIs(t, err, ErrInvalidConsistency)
+
+	stats := node.ConsistencyStats()
+	assert.Equal(t, uint64(1), stats.Writes[string(WriteAckQuorum)].Operations)
+	assert.Equal(t, uint64(1), stats.Writes[string(WriteFireAndForget)].Operations)
+	assert.Equal(t, uint64(1), stats.Reads[string(ReadLocal)].Operations)
+}
+
+func TestStoreFailsWithoutQuorum(t *testing.T) {
+	node := writeTestNode(&fakeWriter{slow: map[string]bool{"peer-b": true}, broken: map[string]bool{"peer-c": true}},
+		"peer-a", "peer-b", "peer-c")
+
+	result, err := node.Store(context.Background(), vectors.DatabaseRecord{ID: "record-1"}, WriteAckQuorum)
+	assert.ErrorIs(t, err, ErrConsistencyNotMet)
+	assert.Equal(t, []string{"peer-a"}, result.Acknowledged)
+	assert.Equal(t, []string{"peer-b", "peer-c"}, result.Failed)
+	assert.Equal(t, uint64(1), node.ConsistencyStats().Writes[string(WriteAckQuorum)].Unmet)
+
+	alone := writeTestNode(&fakeWriter{})
+	result, err = alone.Store(context.Background(), vectors.DatabaseRecord{ID: "record-1"}, WriteAckQuorum)
+	require.NoError(t, err, "a node without peers meets every level")
+	assert.Zero(t, result.Required)
+}
+
+func TestStoreUsesConfiguredQuorum(t *testing.T) {
+	node := writeTestNode(&fakeWriter{broken: map[string]bool{"peer-c": true}}, "peer-a", "peer-b", "peer-c")
+	node.SetWriteConfig(WriteConfig{Quorum: 3, Timeout: 50 * time.Millisecond})
+	result, err := node.Store(context.Background(), vectors.DatabaseRecord{ID: "record-1"}, WriteAckQuorum)
+	assert.ErrorIs(t, err, ErrConsistencyNotMet)
+	assert.Equal(t, 3, result.Required)
+	assert.Equal(t, []string{"peer-c"}, result.Failed)
+
+	node.SetWriteConfig(WriteConfig{Quorum: 1, Timeout: 50 * time.Millisecond})
+	result, err = node.Store(context.Background(), vectors.DatabaseRecord{ID: "record-1"}, WriteAckQuorum)
+	require.NoError(t, err)
+	assert.Len(t, result.Acknowledged, 1, "Store returns at the first acknowledgment")
+
+	single := writeTestNode(&fakeWriter{}, "peer-a")
+	single.SetWriteConfig(WriteConfig{Quorum: 2, Timeout: 50 * time.Millisecond})
+	_, err = single.Store(context.Background(), vectors.DatabaseRecord{ID: "record-1"}, WriteAckQuorum)
+	assert.ErrorIs(t, err, ErrConsistencyNotMet, "a quorum above the replicas cannot be met")
+}
+
+func TestStoreAcknowledgedOverTransport(t *testing.T) {
+	newNode := func() *P2PInfiniteVectorNode {
+		node := NewP2PInfiniteVectorNode("127.0.0.1", 0)
+		node.SetWriteConfig(WriteConfig{Timeout: 2 * time.Second})
+		node.DisableDiscovery()
+		transport, err := NewTransport(TransportConfig{Type: "tcp"})
+		require.NoError(t, err)
+		require.NoError(t, node.UseTransport(transport))
+		node.Start()
+		t.Cleanup(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			node.Stop(ctx)
+		})
+		return node
+	}
+	a, b := newNode(), newNode()
+	a.connectToPeer(&PeerInfo{NodeID: b.NodeID, Address: b.listener.Addr()})
+	b.connectToPeer(&PeerInfo{NodeID: a.NodeID, Address: a.listener.Addr()})
+
+	record := vectors.DatabaseRecord{ID: "record-1", Metadata: map[string]interface{}{"type": "transaction"}}
+	result, err := a.Store(context.Background(), record, WriteAckQuorum)
+	require.NoError(t, err)
+	assert.Equal(t, []string{b.NodeID}, result.Acknowledged)
+	b.localDatabase.mu.RLock()
+	_, stored := b.localDatabase.records["record-1"]
+	b.localDatabase.mu.RUnlock()
+	assert.True(t, stored, "the replica stored the record before acknowledging it")
+
+	// A replica shedding load drops the write and never acknowledges it
+	b.PauseIngest(true)
+	a.SetWriteConfig(WriteConfig{Timeout: 100 * time.Millisecond})
+	result, err = a.Store(context.Background(), vectors.DatabaseRecord{ID: "record-2"}, WriteAckQuorum)
+	assert.ErrorIs(t, err, ErrConsistencyNotMet)
+	assert.Equal(t, []string{b.NodeID}, result.Failed)
+
+	// Acknowledgments only count from the replica written to
+	acked := a.expectAck("write-1", b.NodeID)
+	a.receiveAck("someone-else", "write-1")
+	select {
+	case <-acked:
+		t.Fatal("acknowledgment from another peer accepted")
+	default:
+	}
+	a.forgetAck("write-1")
+}
+
+func TestQueryAtConsistencyLevels(t *testing.T) {
+	levelNode := func(slow, broken []string) *P2PInfiniteVectorNode {
+		querier := &fakeQuerier{
+			answers: map[string][]vectors.DatabaseRecord{"peer-a": {{ID: "record-1"}}},
+			slow:    make(map[string]bool),
+			broken:  make(map[string]bool),
+		}
+		for _, peerID := range slow {
+			querier.slow[peerID] = true
+		}
+		for _, peerID := range broken {
+			querier.broken[peerID] = true
+		}
+		node := queryTestNode(querier, "peer-a", "peer-b", "peer-c", "peer-d")
+		node.SetQueryConfig(QueryConfig{PeerTimeout: 50 * time.Millisecond, Concurrency: 4, Fanout: 1})
+		return node
+	}
+	vector := vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}
+
+	node := levelNode([]string{"peer-c"}, []string{"peer-d"})
+	result, err := node.QueryAt(context.Background(), ReadAny, vector, 10)
+	require.NoError(t, err, "best-effort reads succeed with whatever answers")
+	assert.Equal(t, 1, result.Queried, "only the fanout is asked")
+
+	result, err = node.QueryAt(context.Background(), ReadQuorum, vector, 10)
+	assert.ErrorIs(t, err, ErrConsistencyNotMet, "two of four peers is not a majority")
+	assert.Equal(t, 4, result.Queried, "quorum reads ask every peer")
+	assert.Equal(t, 2, result.Responded)
+	assert.Len(t, result.Records, 1, "the records gathered are still returned")
+
+	_, err = node.QueryAt(context.Background(), "strong", vector, 10)
+	assert.ErrorIs(t, err, ErrInvalidConsistency)
+
+	stats := node.ConsistencyStats().Reads
+	assert.Equal(t, uint64(1), stats[string(ReadAny)].Operations)
+	assert.Equal(t, uint64(1), stats[string(ReadQuorum)].Unmet)
+
+	node = levelNode(nil, []string{"peer-d"})
+	result, err = node.QueryAt(context.Background(), ReadQuorum, vector, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Responded)
+	_, err = node.QueryAt(context.Background(), ReadAll, vector, 10)
+	assert.ErrorIs(t, err, ErrConsistencyNotMet, "one peer still fails")
+
+	node = levelNode(nil, nil)
+	_, err = node.QueryAt(context.Background(), ReadAll, vector, 10)
+	require.NoError(t, err)
+}
@@ -0,0 +1,217 @@
+package agglomerator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+// ChainsSection is the desired state section declaring the registered chains
+const ChainsSection = "chains"
+
+// DesiredChain is a chain declared in the chains section of the desired
+// state, with the fields of a chain registration
+type DesiredChain struct {
+	ID           string             `json:"id"`
+	Protocol     string             `json:"protocol"`
+	Endpoint     string             `json:"endpoint,omitempty"`
+	Endpoints    []string           `json:"endpoints,omitempty"`
+	Zone         string             `json:"zone,omitempty"`
+	Capabilities *ChainCapabilities `json:"capabilities,omitempty"`
+}
+
+// desiredChainOf describes a registered chain as it would be declared
+func desiredChainOf(chain *Chain) DesiredChain {
+	desired := DesiredChain{
+		ID:        chain.ID,
+		Protocol:  chain.Protocol,
+		Endpoint:  chain.Endpoint,
+		Endpoints: chain.Endpoints,
+		Zone:      chain.Zone,
+	}
+	if !chain.Capabilities.IsZero() {
+		capabilities := chain.Capabilities
+		desired.Capabilities = &capabilities
+	}
+	return desired
+}
+
+// chain builds the registration of a declared chain
+func (d DesiredChain) chain() *Chain {
+	chain := &Chain{
+		ID:        d.ID,
+		Protocol:  d.Protocol,
+		Endpoint:  d.Endpoint,
+		Endpoints: d.Endpoints,
+		Zone:      d.Zone,
+	}
+	if d.Capabilities != nil {
+		chain.Capabilities = *d.Capabilities
+	}
+	return chain
+}
+
+// chainReconciler registers the chains of the chains section. Declared
+// chains that are missing are registered and those registered with other
+// settings are registered again, which replaces them, unless transactions
+// are pending in their pool. Chains that are not declared are reported as
+// unmanaged; nothing unregisters chains.
+type chainReconciler struct {
+	module *AgglomeratorModule
+}
+
+func (c chainReconciler) decode(section json.RawMessage) ([]DesiredChain, error) {
+	var chains []DesiredChain
+	if err := json.Unmarshal(section, &chains); err != nil {
+		return nil, fmt.Errorf("must list chains: %w", err)
+	}
+	seen := make(map[string]bool, len(chains))
+	for i := range chains {
+		chain := &chains[i]
+		if chain.ID == "" {
+			return nil, fmt.Errorf("chain %d: id is required", i)
+		}
+		if seen[chain.ID] {
+			return nil, fmt.Errorf("chain %s is declared more than once", chain.ID)
+		}
+		seen[chain.ID] = true
+		if chain.Protocol == "" {
+			return nil, fmt.Errorf("chain %s: protocol is required", chain.ID)
+		}
+		if chain.Capabilities != nil {
+			if err := chain.Capabilities.Validate(); err != nil {
+				return nil, fmt.Errorf("chain %s: %w", chain.ID, err)
+			}
+			// Empty capabilities are compared as none
+			if chain.Capabilities.IsZero() {
+				chain.Capabilities = nil
+			}
+		}
+	}
+	return chains, nil
+}
+
+func (c chainReconciler) Validate(section json.RawMessage) error {
+	_, err := c.decode(section)
+	return err
+}
+
+func (c chainReconciler) Reconcile(_ context.Context, section json.RawMessage, enforce bool) ([]core.Drift, error) {
+	desired, err := c.decode(section)
+	if err != nil {
+		return nil, err
+	}
+	agg := c.module.GetAgglomerator()
+	if agg == nil {
+		return nil, errors.New("agglomerator not initialized")
+	}
+
+	actual := make(map[string]*Chain)
+	for _, chain := range agg.ListChains() {
+		actual[chain.ID] = chain
+	}
+	var drift []core.Drift
+	for _, declared := range desired {
+		entry := core.Drift{Resource: declared.ID, Kind: core.DriftMissing}
+		chain, registered := actual[declared.ID]
+		delete(actual, declared.ID)
+		if registered {
+			changes, err := diffDesiredChains(desiredChainOf(chain), declared)
+			if err != nil {
+				return nil, err
+			}
+			if len(changes) == 0 {
+				continue
+			}
+			entry.Kind = core.DriftChanged
+			entry.Changes = changes
+		}
+
+		switch {
+		case !enforce:
+		case c.module.GetReplica() != nil:
+			entry.Error = ErrReadOnlyReplica.Error()
+		case registered && poolSize(chain) > 0:
+			entry.Error = fmt.Sprintf("%d transactions are pending; drain the chain to replace it", poolSize(chain))
+		default:
+			if err := c.module.RegisterChain(declared.chain()); err != nil {
+				entry.Error = err.Error()
+			} else {
+				entry.Corrected = true
+			}
+		}
+		drift = append(drift, entry)
+	}
+
+	unmanaged := make([]string, 0, len(actual))
+	for id := range actual {
+		unmanaged = append(unmanaged, id)
+	}
+	sort.Strings(unmanaged)
+	for _, id := range unmanaged {
+		drift = append(drift, core.Drift{Resource: id, Kind: core.DriftUnmanaged})
+	}
+	return drift, nil
+}
+
+func diffDesiredChains(actual, desired DesiredChain) ([]core.ConfigChange, error) {
+	from, err := json.Marshal(actual)
+	if err != nil {
+		return nil, err
+	}
+	to, err := json.Marshal(desired)
+	if err != nil {
+		return nil, err
+	}
+	return core.DiffConfig(from, to)
+}
+
+func poolSize(chain *Chain) int {
+	stats, _ := chain.PoolStats()
+	return stats.Size
+}
+
+// RegisterDesiredState adds the chains section to desired, and reconciles
+// desired while the module runs. It must be called before Initialize.
+func (m *AgglomeratorModule) RegisterDesiredState(desired *core.DesiredStateReconciler) {
+	desired.Register(ChainsSection, chainReconciler{module: m})
+	m.mu.Lock()
+	m.desiredState = desired
+	m.mu.Unlock()
+}
+
+// runDesiredState reconciles the desired state at the interval it sets,
+// logging drift that remains
+func (m *AgglomeratorModule) runDesiredState(desired *core.DesiredStateReconciler, stop chan struct{}) {
+	timer := time.NewTimer(desired.Interval())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+			report, err := desired.Reconcile(context.Background(), false)
+			switch {
+			case errors.Is(err, core.ErrNoDesiredState):
+			case err != nil:
+				m.logger.Log(m.Name(), "ERROR", fmt.Sprintf("Failed to reconcile desired state: %v", err))
+			case !report.InSync:
+				corrected := 0
+				for _, drift := range report.Drift {
+					if drift.Corrected {
+						corrected++
+					}
+				}
+				m.logger.Log(m.Name(), "WARN", fmt.Sprintf("Desired state revision %d: %d drifted, %d corrected, %d sections unchecked",
+					report.Revision, len(report.Drift), corrected, len(report.Errors)))
+			}
+			timer.Reset(desired.Interval())
+		}
+	}
+}
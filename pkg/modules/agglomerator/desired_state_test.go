@@ -0,0 +1,40 @@
+package agglomerator
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+func TestChainReconcilerKeepsPendingPools(t *testing.T) {
+	module := newRerouteModule(t)
+	require.NoError(t, module.ProcessTransaction(&Transaction{ID: "tx-1", FromChain: "source", ToChain: "up-a", Priority: 3}))
+
+	section, err := json.Marshal([]DesiredChain{
+		{ID: "down", Protocol: ProtocolMock, Endpoint: "mock://down?blockTime=1ms&failureRate=1", Capabilities: &ChainCapabilities{}},
+		{ID: "up-a", Protocol: ProtocolMock, Endpoint: "mock://up-a?blockTime=2ms"},
+		{ID: "up-b", Protocol: ProtocolMock, Endpoint: "mock://up-b?blockTime=2ms"},
+	})
+	require.NoError(t, err)
+	drift, err := chainReconciler{module: module}.Reconcile(context.Background(), section, true)
+	require.NoError(t, err)
+
+	require.Len(t, drift, 3, "down matches, as empty capabilities are none")
+	assert.Equal(t, "up-a", drift[0].Resource)
+	assert.False(t, drift[0].Corrected)
+	assert.Contains(t, drift[0].Error, "1 transactions are pending")
+	assert.Equal(t, "up-b", drift[1].Resource)
+	assert.True(t, drift[1].Corrected, drift[1].Error)
+	assert.Equal(t, core.Drift{Resource: "source", Kind: core.DriftUnmanaged}, drift[2])
+
+	chain, err := module.GetAgglomerator().GetChain("up-b")
+	require.NoError(t, err)
+	assert.Equal(t, "mock://up-b?blockTime=2ms", chain.Endpoint)
+	chain, err = module.GetAgglomerator().GetChain("up-a")
+	require.NoError(t, err)
+	assert.Equal(t, "mock://up-a?blockTime=1ms", chain.Endpoint, "the chain keeps its pool")
+}
@@ -0,0 +1,138 @@
+package agglomerator
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+// Free space at storage.path below which diagnostics warn and fail
+const (
+	diskSpaceWarn = 1 << 30
+	diskSpaceFail = 100 << 20
+)
+
+// storageDatabases are the SQLite files kept under storage.path
+var storageDatabases = []string{"transactions.db", "events.db", "vectors.db"}
+
+// RegisterDiagnostics adds checks of the storage, P2P port and bootstrap
+// peers in config to d, for a node that is not running
+func RegisterDiagnostics(d *core.Diagnostics, config *ModuleConfig) {
+	registerStorageDiagnostics(d, config)
+
+	if config.P2P.Port > 0 {
+		address := net.JoinHostPort(config.P2P.Address, strconv.Itoa(config.P2P.Port))
+		d.Register("p2p.port", core.CheckPortBindable(address))
+	}
+
+	var peers []string
+	for _, spec := range config.P2P.BootstrapPeers {
+		if peer, err := parseBootstrapPeer(spec); err == nil {
+			peers = append(peers, multiaddrHostPort(peer.Address))
+		}
+	}
+	d.Register("p2p.peers", core.CheckReachable(peers))
+}
+
+// RegisterDiagnostics adds checks of the running module's storage, P2P
+// listener, peers and their clocks to d
+func (m *AgglomeratorModule) RegisterDiagnostics(d *core.Diagnostics) {
+	if config := m.GetConfig(); config != nil {
+		registerStorageDiagnostics(d, config)
+	}
+
+	p2p := m.GetP2P()
+	if p2p == nil {
+		return
+	}
+	node := p2p.p2pNode
+	d.Register("p2p.port", node.checkListener)
+	d.Register("p2p.peers", node.checkPeers)
+	d.Register("p2p.clockSkew", node.ReplayGuard().CheckClockSkew)
+}
+
+func registerStorageDiagnostics(d *core.Diagnostics, config *ModuleConfig) {
+	if config.Storage.Path == "" {
+		return
+	}
+	d.Register("storage.disk", core.CheckDiskSpace(config.Storage.Path, diskSpaceWarn, diskSpaceFail))
+	for _, name := range storageDatabases {
+		d.Register("storage."+name, core.CheckDatabaseFile(filepath.Join(config.Storage.Path, name)))
+	}
+}
+
+// checkListener reports whether the node accepts inbound channels
+func (node *P2PInfiniteVectorNode) checkListener(ctx context.Context) (core.CheckStatus, string) {
+	node.connMu.Lock()
+	transport, listening := node.transport, node.listener != nil
+	node.connMu.Unlock()
+
+	if transport == nil {
+		return core.CheckPass, "no transport configured; delivery is simulated"
+	}
+	if !listening {
+		return core.CheckFail, fmt.Sprintf("%s transport is not listening on %s", transport.Name(), node.listenAddress())
+	}
+	return core.CheckPass, fmt.Sprintf("%s listening on %s", transport.Name(), node.listenAddress())
+}
+
+// checkPeers dials the TCP address of every known peer
+func (node *P2PInfiniteVectorNode) checkPeers(ctx context.Context) (core.CheckStatus, string) {
+	node.connMu.Lock()
+	transport := node.transport
+	node.connMu.Unlock()
+	if transport == nil {
+		return core.CheckPass, "no transport configured; delivery is simulated"
+	}
+	if transport.Name() == "libp2p" {
+		return core.CheckPass, "peer reachability is managed by libp2p"
+	}
+
+	node.peerMutex.RLock()
+	addresses := make([]string, 0, len(node.peers))
+	for _, peer := range node.peers {
+		if address, err := node.dialAddress(transport, peer.Address); err == nil {
+			addresses = append(addresses, address)
+		}
+	}
+	node.peerMutex.RUnlock()
+
+	return core.CheckReachable(addresses)(ctx)
+}
+
+// crashDumpEvents is the number of recent events in a crash bundle
+const crashDumpEvents = 100
+
+// RegisterCrashState adds the module's recent events and the pending
+// transactions per chain to crash bundles taken by d
+func (m *AgglomeratorModule) RegisterCrashState(d *core.CrashDumper) {
+	d.Register("agglomerator.events", func() (interface{}, error) {
+		agg := m.GetAgglomerator()
+		if agg == nil || agg.EventLog() == nil {
+			return []Event{}, nil
+		}
+		log := agg.EventLog()
+		var since uint64
+		if last := log.Stats().LastSeq; last > crashDumpEvents {
+			since = last - crashDumpEvents
+		}
+		return log.Events(since, crashDumpEvents)
+	})
+	d.Register("agglomerator.pools", func() (interface{}, error) {
+		pools := make(map[string]PoolStats)
+		agg := m.GetAgglomerator()
+		if agg == nil {
+			return pools, nil
+		}
+		for _, chain := range agg.ListChains() {
+			if stats, ok := chain.PoolStats(); ok {
+				pools[chain.ID] = stats
+			}
+		}
+		return pools, nil
+	})
+}
@@ -0,0 +1,92 @@
+package agglomerator
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+func TestCheckClockSkew(t *testing.T) {
+	guard := NewReplayGuard(ReplayConfig{CacheSize: 16, MaxClockSkew: time.Minute, SequenceWindow: 16})
+
+	status, _ := guard.CheckClockSkew(context.Background())
+	assert.Equal(t, core.CheckPass, status, "no senders yet")
+
+	guard.Accept(DataTransferMessage{SenderID: "peer-a", Timestamp: time.Now(), Sequence: 1})
+	status, _ = guard.CheckClockSkew(context.Background())
+	assert.Equal(t, core.CheckPass, status)
+
+	guard.Accept(DataTransferMessage{SenderID: "peer-b", Timestamp: time.Now().Add(45 * time.Second), Sequence: 1})
+	status, message := guard.CheckClockSkew(context.Background())
+	assert.Equal(t, core.CheckWarn, status, "a clock ahead by more than half the limit warns")
+	assert.Contains(t, message, "peer-b")
+
+	accepted := guard.Accept(DataTransferMessage{SenderID: "peer-c", Timestamp: time.Now().Add(-2 * time.Minute), Sequence: 1})
+	assert.False(t, accepted)
+	status, message = guard.CheckClockSkew(context.Background())
+	assert.Equal(t, core.CheckFail, status, "skew past the limit fails even though the message was dropped")
+	assert.Contains(t, message, "peer-c")
+}
+
+func TestRegisterDiagnostics(t *testing.T) {
+	var config ModuleConfig
+	config.Storage.Path = t.TempDir()
+	config.P2P.Address = "127.0.0.1"
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	config.P2P.Port = listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	diagnostics := core.NewDiagnostics()
+	RegisterDiagnostics(diagnostics, &config)
+	report := diagnostics.Run(context.Background())
+
+	statuses := make(map[string]core.CheckStatus)
+	for _, check := range report.Checks {
+		statuses[check.Name] = check.Status
+	}
+	assert.Equal(t, core.CheckPass, statuses["storage.transactions.db"], "missing databases are created on start")
+	assert.Equal(t, core.CheckPass, statuses["p2p.port"])
+	assert.Equal(t, core.CheckPass, statuses["p2p.peers"], "no bootstrap peers configured")
+	assert.Contains(t, statuses, "storage.disk")
+}
+
+func TestRegisterCrashState(t *testing.T) {
+	log, err := NewEventLog(nil)
+	require.NoError(t, err)
+	agg := NewAgglomerator(AgglomeratorConfig{Pool: PoolConfig{MaxSize: 10}})
+	agg.SetEventLog(log)
+	for _, id := range []string{"eth", "sol"} {
+		chain := NewChain(id, "http://localhost:8545", ProtocolEthereum)
+		chain.StateVector = vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}
+		require.NoError(t, agg.RegisterChain(chain))
+	}
+	_, err = agg.recordTransaction(&Transaction{ID: "tx-1", FromChain: "sol", ToChain: "eth",
+		StateVector: vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}})
+	require.NoError(t, err)
+
+	dumper := core.NewCrashDumper(t.TempDir())
+	module := &AgglomeratorModule{agglomerator: agg}
+	module.RegisterCrashState(dumper)
+	bundle := dumper.Capture("test")
+
+	assert.Empty(t, bundle.Errors)
+	assert.NotEmpty(t, bundle.Goroutines)
+	var pools map[string]PoolStats
+	require.NoError(t, json.Unmarshal(bundle.State["agglomerator.pools"], &pools))
+	assert.Equal(t, 1, pools["eth"].Size)
+	var events []Event
+	require.NoError(t, json.Unmarshal(bundle.State["agglomerator.events"], &events))
+	assert.NotEmpty(t, events)
+
+	path, err := dumper.WriteFile("test")
+	require.NoError(t, err)
+	assert.FileExists(t, path)
+}
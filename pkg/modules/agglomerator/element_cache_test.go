@@ -0,0 +1,98 @@
+package agglomerator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+// countingVector counts how often its generator runs
+func countingVector(calls *int) vectors.InfiniteVector {
+	return vectors.InfiniteVector{Generator: func(dim int) float64 {
+		*calls++
+		return float64(dim)
+	}}
+}
+
+func TestElementCacheSharesCopies(t *testing.T) {
+	cache := vectors.NewElementCache(100)
+	var calls int
+	vector := countingVector(&calls)
+	cache.Share(&vector)
+	require.True(t, vector.Shared())
+
+	records := []vectors.DatabaseRecord{{ID: "a", Vector: vector}, {ID: "b", Vector: vector}}
+	assert.Equal(t, 9.0, records[0].Vector.GetElement(9))
+	assert.Equal(t, 10, calls)
+	assert.Equal(t, 9.0, records[1].Vector.GetElement(9), "copies read elements materialized by others")
+	assert.Equal(t, 10, calls)
+
+	assert.Equal(t, 14.0, records[1].Vector.GetElement(14))
+	assert.Equal(t, 15, calls, "only elements past the cached prefix are generated")
+
+	stats := cache.Stats()
+	assert.Equal(t, 1, stats.Vectors)
+	assert.Equal(t, 15, stats.Elements)
+	assert.Equal(t, uint64(10), stats.Hits)
+	assert.Equal(t, uint64(15), stats.Misses)
+	assert.InDelta(t, 0.4, stats.HitRate, 1e-9)
+
+	// Copies taken with Copy read every element through the cache
+	copied := records[1].Vector.Copy()
+	assert.Equal(t, 14.0, copied.GetElement(14))
+	assert.Equal(t, 15, calls)
+	assert.Equal(t, uint64(25), cache.Stats().Hits)
+
+	unshared := vectors.InfiniteVector{Generator: vector.Generator}
+	unshared.GetElement(9)
+	assert.Equal(t, 25, calls, "vectors built without the handle are not cached")
+
+	vector.Release()
+	assert.Zero(t, cache.Stats().Vectors, "the last release drops the entry")
+}
+
+func TestElementCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := vectors.NewElementCache(20)
+	var calls int
+	first, second, third := countingVector(&calls), countingVector(&calls), countingVector(&calls)
+	for _, v := range []*vectors.InfiniteVector{&first, &second, &third} {
+		cache.Share(v)
+	}
+	copies := []vectors.DatabaseRecord{{Vector: first}, {Vector: first}}
+
+	first.GetElement(9)
+	second.GetElement(9)
+	copies[0].Vector.GetElement(9) // Touches first, leaving second least recently used
+	third.GetElement(9)
+
+	stats := cache.Stats()
+	assert.Equal(t, 2, stats.Vectors)
+	assert.Equal(t, uint64(1), stats.Evictions)
+	assert.LessOrEqual(t, stats.Elements, 20)
+
+	calls = 0
+	copies[1].Vector.GetElement(9)
+	assert.Zero(t, calls, "the recently used vector survives eviction")
+}
+
+func TestRegisterChainSharesStateVector(t *testing.T) {
+	agg := NewAgglomerator(AgglomeratorConfig{})
+	chain := NewChain("eth", "http://localhost:8545", ProtocolEthereum)
+	chain.StateVector = vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}
+	require.NoError(t, agg.RegisterChain(chain))
+	assert.True(t, chain.StateVector.Shared())
+	// Clustering materializes the elements compared at registration
+	before := agg.ElementCacheStats()
+	assert.NotZero(t, before.Misses)
+
+	for _, id := range []string{"tx-1", "tx-2"} {
+		tx := &Transaction{ID: id, FromChain: "eth", ToChain: "eth", StateVector: vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}}
+		_, err := agg.recordTransaction(tx)
+		require.NoError(t, err)
+	}
+	after := agg.ElementCacheStats()
+	assert.Greater(t, after.Hits, before.Hits, "later queries reuse the chain's elements")
+	assert.Equal(t, before.Misses, after.Misses)
+}
@@ -0,0 +1,499 @@
+package agglomerator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+const (
+	// DefaultEndpointFailureThreshold is the number of consecutive failures
+	// after which an endpoint is taken out of rotation
+	DefaultEndpointFailureThreshold = 3
+
+	// endpointLatencyWeight is the weight of the newest sample in the
+	// moving average used for latency-based selection
+	endpointLatencyWeight = 0.2
+
+	// endpointErrorWeight is the weight of the newest outcome in the moving
+	// error rate used for health-weighted routing
+	endpointErrorWeight = 0.2
+)
+
+var ErrNoEndpoints = errors.New("chain has no endpoints")
+
+// EndpointProbe checks that an endpoint is reachable
+type EndpointProbe func(ctx context.Context, endpoint string) error
+
+// EndpointStatus reports an endpoint's health and request metrics
+type EndpointStatus struct {
+	URL                 string    `json:"url"`
+	Healthy             bool      `json:"healthy"`
+	Requests            uint64    `json:"requests"`
+	Failures            uint64    `json:"failures"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	AvgLatencyMs        float64   `json:"avgLatencyMs"`
+	ErrorRate           float64   `json:"errorRate"`             // Moving average over requests and probes
+	BlockHeight         uint64    `json:"blockHeight,omitempty"` // Head seen by the last probe
+	LastError           string    `json:"lastError,omitempty"`
+	LastProbe           time.Time `json:"lastProbe,omitempty"`
+}
+
+type poolEndpoint struct {
+	status  EndpointStatus
+	latency time.Duration // Moving average over requests and probes
+	adapter ChainAdapter  // Nil when the protocol has no adapter
+
+	headAdvancedAt time.Time // When probes last saw the head move
+	headSampledAt  time.Time // When probes last read the head
+}
+
+// EndpointPool tracks the health of a chain's endpoints and orders them for
+// use: healthy endpoints by latency, then unhealthy ones as a last resort
+type EndpointPool struct {
+	endpoints        []*poolEndpoint
+	probe            EndpointProbe
+	failureThreshold int
+	mu               sync.RWMutex
+}
+
+func NewEndpointPool(endpoints []string, probe EndpointProbe) *EndpointPool {
+	if probe == nil {
+		probe = probeEndpoint
+	}
+	pool := &EndpointPool{
+		probe:            probe,
+		failureThreshold: DefaultEndpointFailureThreshold,
+	}
+	for _, endpoint := range endpoints {
+		pool.endpoints = append(pool.endpoints, &poolEndpoint{
+			status: EndpointStatus{URL: endpoint, Healthy: true},
+		})
+	}
+	return pool
+}
+
+// SetFailureThreshold changes how many consecutive failures mark an
+// endpoint unhealthy
+func (p *EndpointPool) SetFailureThreshold(threshold int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if threshold > 0 {
+		p.failureThreshold = threshold
+	}
+}
+
+// Select returns the preferred endpoint, or "" when the pool is empty
+func (p *EndpointPool) Select() string {
+	ordered := p.ordered()
+	if len(ordered) == 0 {
+		return ""
+	}
+	return ordered[0].status.URL
+}
+
+// ordered returns the endpoints in order of preference
+func (p *EndpointPool) ordered() []*poolEndpoint {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	ordered := make([]*poolEndpoint, len(p.endpoints))
+	copy(ordered, p.endpoints)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		if a.status.Healthy != b.status.Healthy {
+			return a.status.Healthy
+		}
+		if !a.status.Healthy {
+			return a.status.ConsecutiveFailures < b.status.ConsecutiveFailures
+		}
+		return a.latency < b.latency
+	})
+	return ordered
+}
+
+// Report records the outcome of a request made through an endpoint
+func (p *EndpointPool) Report(endpoint string, latency time.Duration, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, e := range p.endpoints {
+		if e.status.URL == endpoint {
+			e.status.Requests++
+			if err != nil {
+				e.status.Failures++
+			}
+			p.record(e, latency, err)
+			return
+		}
+	}
+}
+
+// record updates health and latency; callers hold p.mu
+func (p *EndpointPool) record(e *poolEndpoint, latency time.Duration, err error) {
+	outcome := 0.0
+	if err != nil {
+		outcome = 1
+	}
+	e.status.ErrorRate = endpointErrorWeight*outcome + (1-endpointErrorWeight)*e.status.ErrorRate
+
+	if err != nil {
+		e.status.ConsecutiveFailures++
+		e.status.LastError = err.Error()
+		if e.status.ConsecutiveFailures >= p.failureThreshold {
+			e.status.Healthy = false
+		}
+		return
+	}
+
+	e.status.ConsecutiveFailures = 0
+	e.status.Healthy = true
+	if e.latency == 0 {
+		e.latency = latency
+	} else {
+		e.latency = time.Duration(endpointLatencyWeight*float64(latency) + (1-endpointLatencyWeight)*float64(e.latency))
+	}
+	e.status.AvgLatencyMs = float64(e.latency.Microseconds()) / 1000
+}
+
+// watchHead records the head an endpoint's adapter reports; callers hold
+// p.mu
+func (p *EndpointPool) watchHead(e *poolEndpoint, now time.Time) {
+	height := e.adapter.BlockHeight()
+	if height > e.status.BlockHeight || e.headAdvancedAt.IsZero() {
+		e.status.BlockHeight = height
+		e.headAdvancedAt = now
+	}
+	e.headSampledAt = now
+}
+
+// Probe checks every endpoint, each bounded by timeout. Probes update
+// health, latency and the head seen by endpoints with an adapter but not
+// the request counters.
+func (p *EndpointPool) Probe(ctx context.Context, timeout time.Duration) {
+	p.mu.RLock()
+	endpoints := make([]*poolEndpoint, len(p.endpoints))
+	copy(endpoints, p.endpoints)
+	p.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, e := range endpoints {
+		wg.Add(1)
+		go func(e *poolEndpoint) {
+			defer wg.Done()
+
+			probeCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			start := time.Now()
+			var err error
+			if prober, ok := e.adapter.(interface{ Probe(context.Context) error }); ok {
+				err = prober.Probe(probeCtx)
+			} else {
+				err = p.probe(probeCtx, e.status.URL)
+			}
+
+			p.mu.Lock()
+			e.status.LastProbe = time.Now()
+			p.record(e, time.Since(start), err)
+			if err == nil && e.adapter != nil {
+				p.watchHead(e, e.status.LastProbe)
+			}
+			p.mu.Unlock()
+		}(e)
+	}
+	wg.Wait()
+}
+
+// Status returns a snapshot of every endpoint in configured order
+func (p *EndpointPool) Status() []EndpointStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	statuses := make([]EndpointStatus, len(p.endpoints))
+	for i, e := range p.endpoints {
+		statuses[i] = e.status
+	}
+	return statuses
+}
+
+// probeEndpoint is the default probe: any HTTP response below 500 counts as
+// reachable, and other schemes only need to accept a TCP connection
+func probeEndpoint(ctx context.Context, endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("endpoint returned %s", resp.Status)
+		}
+		return nil
+	default:
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", u.Host)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+// poolAdapter submits through the preferred endpoint's adapter and fails
+// over to the others in order of preference
+type poolAdapter struct {
+	protocol string
+	pool     *EndpointPool
+}
+
+func (a *poolAdapter) Protocol() string {
+	return a.protocol
+}
+
+func (a *poolAdapter) Submit(ctx context.Context, tx *Transaction) (*SubmitReceipt, error) {
+	var errs []error
+	for _, e := range a.pool.ordered() {
+		start := time.Now()
+		receipt, err := e.adapter.Submit(ctx, tx)
+		a.pool.Report(e.status.URL, time.Since(start), err)
+		if err == nil {
+			return receipt, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", e.status.URL, err))
+	}
+	if len(errs) == 0 {
+		return nil, ErrNoEndpoints
+	}
+	return nil, errors.Join(errs...)
+}
+
+// Confirmation asks each endpoint that can report confirmations, returning
+// the first that found the transaction
+func (a *poolAdapter) Confirmation(ctx context.Context, txID string) (*Confirmation, error) {
+	var result *Confirmation
+	var errs []error
+	for _, e := range a.pool.ordered() {
+		reporter, ok := e.adapter.(ConfirmationReporter)
+		if !ok {
+			continue
+		}
+		confirmation, err := reporter.Confirmation(ctx, txID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.status.URL, err))
+			continue
+		}
+		if confirmation.Found {
+			return confirmation, nil
+		}
+		result = confirmation
+	}
+	if result != nil {
+		return result, nil
+	}
+	if len(errs) == 0 {
+		return nil, ErrConfirmationsUnsupported
+	}
+	return nil, errors.Join(errs...)
+}
+
+// EstimateFee asks endpoints that can estimate fees in order of preference,
+// returning the first estimate
+func (a *poolAdapter) EstimateFee(ctx context.Context) (float64, error) {
+	var errs []error
+	for _, e := range a.pool.ordered() {
+		estimator, ok := e.adapter.(FeeEstimator)
+		if !ok {
+			continue
+		}
+		fee, err := estimator.EstimateFee(ctx)
+		if err == nil {
+			return fee, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", e.status.URL, err))
+	}
+	if len(errs) == 0 {
+		return 0, ErrFeesUnsupported
+	}
+	return 0, errors.Join(errs...)
+}
+
+// Simulate asks endpoints that can simulate in order of preference. The
+// first to run the simulation decides it; endpoints that could not be
+// asked are passed over.
+func (a *poolAdapter) Simulate(ctx context.Context, tx *Transaction) error {
+	var errs []error
+	for _, e := range a.pool.ordered() {
+		simulator, ok := e.adapter.(Simulator)
+		if !ok {
+			continue
+		}
+		err := simulator.Simulate(ctx, tx)
+		if err == nil || errors.Is(err, ErrSimulationFailed) {
+			return err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", e.status.URL, err))
+	}
+	if len(errs) == 0 {
+		return ErrSimulationUnsupported
+	}
+	return errors.Join(errs...)
+}
+
+// BlockHeight reports the height seen by the preferred endpoint
+func (a *poolAdapter) BlockHeight() uint64 {
+	ordered := a.pool.ordered()
+	if len(ordered) == 0 {
+		return 0
+	}
+	return ordered[0].adapter.BlockHeight()
+}
+
+// AllEndpoints returns Endpoint followed by the failover Endpoints, without
+// duplicates
+func (c *Chain) AllEndpoints() []string {
+	seen := make(map[string]bool)
+	var endpoints []string
+	for _, endpoint := range append([]string{c.Endpoint}, c.Endpoints...) {
+		if endpoint != "" && !seen[endpoint] {
+			seen[endpoint] = true
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}
+
+// EndpointPool returns the pool tracking the chain's endpoints
+func (c *Chain) EndpointPool() *EndpointPool {
+	return c.endpoints
+}
+
+// attachEndpoints builds the chain's endpoint pool and, for protocols with
+// an adapter, one adapter per endpoint behind a failover adapter
+func (c *Chain) attachEndpoints() error {
+	pool := NewEndpointPool(c.AllEndpoints(), nil)
+
+	hasAdapter := false
+	for _, e := range pool.endpoints {
+		adapter, err := newChainAdapter(c.Protocol, c.ID, e.status.URL)
+		if err != nil {
+			return err
+		}
+		e.adapter = adapter
+		hasAdapter = hasAdapter || adapter != nil
+	}
+
+	c.endpoints = pool
+	c.adapter = nil
+	if hasAdapter {
+		c.adapter = &poolAdapter{protocol: c.Protocol, pool: pool}
+	}
+	return nil
+}
+
+// EndpointHealthConfig controls background probing of chain endpoints
+type EndpointHealthConfig struct {
+	Interval         time.Duration
+	Timeout          time.Duration
+	FailureThreshold int
+}
+
+// DefaultEndpointHealthConfig probes every 30 seconds
+func DefaultEndpointHealthConfig() EndpointHealthConfig {
+	return EndpointHealthConfig{
+		Interval:         30 * time.Second,
+		Timeout:          5 * time.Second,
+		FailureThreshold: DefaultEndpointFailureThreshold,
+	}
+}
+
+type endpointHealth struct {
+	config EndpointHealthConfig
+	stop   chan struct{}
+}
+
+// StartHealthChecks probes every chain's endpoints in the background
+func (a *Agglomerator) StartHealthChecks(config EndpointHealthConfig) error {
+	if config.Interval <= 0 || config.Timeout <= 0 {
+		return fmt.Errorf("health check interval and timeout must be positive")
+	}
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = DefaultEndpointFailureThreshold
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.health != nil {
+		return fmt.Errorf("health checks already running")
+	}
+	for _, chain := range a.chains {
+		chain.endpoints.SetFailureThreshold(config.FailureThreshold)
+	}
+
+	health := &endpointHealth{config: config, stop: make(chan struct{})}
+	a.health = health
+	go a.runHealthChecks(health, a.clock.NewTicker(config.Interval))
+	return nil
+}
+
+// StopHealthChecks stops background endpoint probing
+func (a *Agglomerator) StopHealthChecks() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.health != nil {
+		close(a.health.stop)
+		a.health = nil
+	}
+}
+
+// ProbeEndpoints runs one probe pass over every chain's endpoints
+func (a *Agglomerator) ProbeEndpoints(ctx context.Context, timeout time.Duration) {
+	for _, chain := range a.ListChains() {
+		if chain.endpoints != nil {
+			chain.endpoints.Probe(ctx, timeout)
+		}
+	}
+}
+
+func (a *Agglomerator) runHealthChecks(health *endpointHealth, ticker core.Ticker) {
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-health.stop
+		cancel()
+	}()
+
+	for {
+		a.ProbeEndpoints(ctx, health.config.Timeout)
+		select {
+		case <-health.stop:
+			return
+		case <-ticker.C():
+		}
+	}
+}
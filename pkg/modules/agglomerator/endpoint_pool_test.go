@@ -0,0 +1,132 @@
+package agglomerator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+// stubAdapter fails every submission while down is set
+type stubAdapter struct {
+	down      bool
+	submitted int
+}
+
+func (s *stubAdapter) Protocol() string    { return ProtocolMock }
+func (s *stubAdapter) BlockHeight() uint64 { return 1 }
+
+func (s *stubAdapter) Submit(ctx context.Context, tx *Transaction) (*SubmitReceipt, error) {
+	s.submitted++
+	if s.down {
+		return nil, ErrMockSubmitFailed
+	}
+	return &SubmitReceipt{TxID: tx.ID}, nil
+}
+
+func TestEndpointPoolFailover(t *testing.T) {
+	primary := &stubAdapter{down: true}
+	backup := &stubAdapter{}
+
+	pool := NewEndpointPool([]string{"mock://primary", "mock://backup"}, nil)
+	pool.SetFailureThreshold(2)
+	pool.endpoints[0].adapter = primary
+	pool.endpoints[1].adapter = backup
+	adapter := &poolAdapter{protocol: ProtocolMock, pool: pool}
+
+	for i := 0; i < 2; i++ {
+		_, err := adapter.Submit(context.Background(), &Transaction{ID: "tx"})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, primary.submitted)
+	assert.Equal(t, "mock://backup", pool.Select(), "primary is out of rotation after two failures")
+
+	_, err := adapter.Submit(context.Background(), &Transaction{ID: "tx"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, primary.submitted)
+
+	status := pool.Status()
+	assert.False(t, status[0].Healthy)
+	assert.Equal(t, uint64(2), status[0].Failures)
+	assert.Equal(t, uint64(3), status[1].Requests)
+
+	backup.down = true
+	_, err = adapter.Submit(context.Background(), &Transaction{ID: "tx"})
+	assert.ErrorIs(t, err, ErrMockSubmitFailed)
+}
+
+func TestEndpointPoolProbeAndLatency(t *testing.T) {
+	down := map[string]bool{"http://a": true}
+	pool := NewEndpointPool([]string{"http://a", "http://b", "http://c"}, func(ctx context.Context, endpoint string) error {
+		if down[endpoint] {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	pool.SetFailureThreshold(1)
+
+	pool.Report("http://b", 50*time.Millisecond, nil)
+	pool.Report("http://c", 10*time.Millisecond, nil)
+	assert.Equal(t, "http://a", pool.Select(), "untried endpoints are preferred")
+
+	pool.Probe(context.Background(), time.Second)
+	assert.Equal(t, "http://c", pool.Select())
+	assert.Equal(t, uint64(0), pool.Status()[0].Requests, "probes are not counted as requests")
+	assert.Equal(t, "connection refused", pool.Status()[0].LastError)
+
+	delete(down, "http://a")
+	pool.Probe(context.Background(), time.Second)
+	assert.True(t, pool.Status()[0].Healthy)
+}
+
+func TestChainEndpoints(t *testing.T) {
+	chain := NewChain("mock-chain", "mock://a", ProtocolMock)
+	chain.Endpoints = []string{"mock://b", "mock://a"}
+	assert.Equal(t, []string{"mock://a", "mock://b"}, chain.AllEndpoints())
+
+	agg := NewAgglomerator(AgglomeratorConfig{})
+	require.NoError(t, agg.RegisterChain(chain))
+	require.NotNil(t, chain.EndpointPool())
+	assert.Len(t, chain.EndpointPool().Status(), 2)
+}
+
+// probingAdapter reports each health probe on probes
+type probingAdapter struct {
+	stubAdapter
+	probes chan struct{}
+}
+
+func (p *probingAdapter) Probe(ctx context.Context) error {
+	p.probes <- struct{}{}
+	return nil
+}
+
+func TestHealthChecksFollowClock(t *testing.T) {
+	clock := core.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	agg := NewAgglomerator(AgglomeratorConfig{Clock: clock})
+	chain := NewChain("mock-chain", "mock://a", ProtocolMock)
+	require.NoError(t, agg.RegisterChain(chain))
+	adapter := &probingAdapter{probes: make(chan struct{}, 1)}
+	chain.EndpointPool().endpoints[0].adapter = adapter
+
+	require.NoError(t, agg.StartHealthChecks(EndpointHealthConfig{Interval: time.Minute, Timeout: time.Second}))
+	defer agg.StopHealthChecks()
+
+	<-adapter.probes
+	select {
+	case <-adapter.probes:
+		t.Fatal("probed again before the interval passed")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	clock.Advance(time.Minute)
+	select {
+	case <-adapter.probes:
+	case <-time.After(time.Second):
+		t.Fatal("not probed once the interval passed")
+	}
+}
@@ -0,0 +1,573 @@
+package agglomerator
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Event types in the agglomerator event log
+const (
+	EventChainRegistered      = "chain.registered"
+	EventTransactionRouted    = "transaction.routed" // Admitted to its source and destination pools
+	EventTransactionPooled    = "transaction.pooled" // Admitted to one chain's pool by the P2P router
+	EventTransactionRemoved   = "transaction.removed"
+	EventTransactionStatus    = "transaction.status"    // Outcome recorded in the history
+	EventChainMaintenance     = "chain.maintenance"     // Window scheduled or cleared
+	EventTransactionRerouted  = "transaction.rerouted"  // Moved off a failed destination by an operator
+	EventChainVector          = "chain.vector"          // State vector seeded from imported values
+	EventChainCanary          = "chain.canary"          // Warm-up started, or the chain promoted or quarantined
+	EventTransactionSponsored = "transaction.sponsored" // Fees charged to the tenant's sponsor budget
+)
+
+// Reasons a transaction leaves a chain pool
+const (
+	RemovedEvicted   = "evicted"
+	RemovedArchived  = "archived"
+	RemovedCollected = "collected"
+)
+
+// maxMemoryEvents bounds an event log kept without a store; the oldest
+// events are dropped beyond it
+const maxMemoryEvents = 100000
+
+// ErrCursorGone reports a replay cursor the event log no longer covers: the
+// events after it were dropped, or it belongs to an earlier log
+var ErrCursorGone = errors.New("replay cursor outside the event log")
+
+// Event is an entry in the event log. Seq increases by one per event.
+type Event struct {
+	Seq     uint64          `json:"seq"`
+	Type    string          `json:"type"`
+	ChainID string          `json:"chainId,omitempty"`
+	TxID    string          `json:"txId,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Time    time.Time       `json:"time"`
+}
+
+// ChainRegisteredData is the data of EventChainRegistered
+type ChainRegisteredData struct {
+	Protocol  string   `json:"protocol"`
+	Endpoint  string   `json:"endpoint"`
+	Endpoints []string `json:"endpoints,omitempty"`
+	Zone      string   `json:"zone,omitempty"`
+
+	Capabilities *ChainCapabilities `json:"capabilities,omitempty"`
+}
+
+// ChainMaintenanceData is the data of EventChainMaintenance; a nil
+// window means it was cleared
+type ChainMaintenanceData struct {
+	Maintenance *ChainMaintenance `json:"maintenance"`
+}
+
+// ChainCanaryData is the data of EventChainCanary
+type ChainCanaryData struct {
+	Canary *ChainCanary `json:"canary"`
+}
+
+// ChainVectorData is the data of EventChainVector
+type ChainVectorData struct {
+	Values []float64 `json:"values"`
+}
+
+// TransactionRoutedData is the data of EventTransactionRouted
+type TransactionRoutedData struct {
+	FromChain string  `json:"fromChain"`
+	ToChain   string  `json:"toChain"`
+	Priority  int     `json:"priority,omitempty"`
+	Fee       float64 `json:"fee,omitempty"`
+	BlobRef   string  `json:"blobRef,omitempty"`
+}
+
+// TransactionRemovedData is the data of EventTransactionRemoved
+type TransactionRemovedData struct {
+	Reason string `json:"reason"`
+}
+
+// TransactionReroutedData is the data of EventTransactionRerouted, whose
+// chain is the new destination
+type TransactionReroutedData struct {
+	FailedChain string `json:"failedChain"`
+	Operator    string `json:"operator"`
+	Reason      string `json:"reason"`
+}
+
+// TransactionStatusData is the data of EventTransactionStatus
+type TransactionStatusData struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// EventStore persists the event log in SQLite
+type EventStore struct {
+	db *sql.DB
+}
+
+func NewEventStore(dbPath string) (*EventStore, error) {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if _, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS events (
+            seq INTEGER PRIMARY KEY,
+            type TEXT NOT NULL,
+            chain_id TEXT NOT NULL DEFAULT '',
+            tx_id TEXT NOT NULL DEFAULT '',
+            data TEXT NOT NULL DEFAULT '',
+            time INTEGER NOT NULL
+        );
+        CREATE INDEX IF NOT EXISTS idx_events_time ON events (time);
+    `); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	return &EventStore{db: db}, nil
+}
+
+func (s *EventStore) append(event Event) error {
+	if _, err := s.db.Exec(`
+        INSERT INTO events (seq, type, chain_id, tx_id, data, time) VALUES (?, ?, ?, ?, ?, ?)
+    `, event.Seq, event.Type, event.ChainID, event.TxID, string(event.Data), event.Time.UnixNano()); err != nil {
+		return fmt.Errorf("failed to append event: %w", err)
+	}
+	return nil
+}
+
+func (s *EventStore) lastSeq() (uint64, error) {
+	var seq sql.NullInt64
+	if err := s.db.QueryRow(`SELECT MAX(seq) FROM events`).Scan(&seq); err != nil {
+		return 0, fmt.Errorf("failed to query events: %w", err)
+	}
+	return uint64(seq.Int64), nil
+}
+
+// events returns up to limit events after seq recorded no later than until;
+// limit 0 returns all of them
+func (s *EventStore) events(since uint64, until time.Time, limit int) ([]Event, error) {
+	query := `SELECT seq, type, chain_id, tx_id, data, time FROM events WHERE seq > ?`
+	args := []interface{}{since}
+	if !until.IsZero() {
+		query += ` AND time <= ?`
+		args = append(args, until.UnixNano())
+	}
+	query += ` ORDER BY seq`
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]Event, 0)
+	for rows.Next() {
+		var event Event
+		var data string
+		var at int64
+		if err := rows.Scan(&event.Seq, &event.Type, &event.ChainID, &event.TxID, &data, &at); err != nil {
+			return nil, fmt.Errorf("failed to read event: %w", err)
+		}
+		if data != "" {
+			event.Data = json.RawMessage(data)
+		}
+		event.Time = time.Unix(0, at)
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// Close the database connection
+func (s *EventStore) Close() error {
+	if s.db != nil {
+		return s.db.Close()
+	}
+	return nil
+}
+
+// EventLogStats reports the size of the event log
+type EventLogStats struct {
+	LastSeq     uint64 `json:"lastSeq"`
+	Persistent  bool   `json:"persistent"`
+	Subscribers int    `json:"subscribers"`
+	Dropped     uint64 `json:"dropped"` // Events not delivered to slow subscribers
+	Failed      uint64 `json:"failed"`  // Events that could not be stored
+}
+
+// EventLog is the append-only record of agglomerator state changes. State is
+// derived from it by replay, and subscribers receive each event as it is
+// appended.
+type EventLog struct {
+	store       *EventStore // Nil keeps events in memory
+	memory      []Event
+	seq         uint64
+	subscribers map[int]chan Event
+	nextSub     int
+	dropped     uint64
+	failed      uint64
+	mu          sync.Mutex
+}
+
+// NewEventLog continues the log held in store, or starts an in-memory log
+// when store is nil
+func NewEventLog(store *EventStore) (*EventLog, error) {
+	log := &EventLog{store: store, subscribers: make(map[int]chan Event)}
+	if store != nil {
+		seq, err := store.lastSeq()
+		if err != nil {
+			return nil, err
+		}
+		log.seq = seq
+	}
+	return log, nil
+}
+
+// Append records an event, encoding data as its payload
+func (l *EventLog) Append(eventType, chainID, txID string, data interface{}) (Event, error) {
+	event := Event{Type: eventType, ChainID: chainID, TxID: txID, Time: time.Now()}
+	if data != nil {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return event, fmt.Errorf("failed to encode event: %w", err)
+		}
+		event.Data = encoded
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	event.Seq = l.seq + 1
+	if l.store != nil {
+		if err := l.store.append(event); err != nil {
+			l.failed++
+			return event, err
+		}
+	} else {
+		l.memory = append(l.memory, event)
+		if len(l.memory) > maxMemoryEvents {
+			l.memory = l.memory[len(l.memory)-maxMemoryEvents:]
+		}
+	}
+	l.seq = event.Seq
+
+	for _, ch := range l.subscribers {
+		select {
+		case ch <- event:
+		default:
+			l.dropped++
+		}
+	}
+	return event, nil
+}
+
+// record appends an event, if there is a log. A failed append is counted
+// in the log's stats rather than failing the change it records.
+func (l *EventLog) record(eventType, chainID, txID string, data interface{}) {
+	if l != nil {
+		l.Append(eventType, chainID, txID, data)
+	}
+}
+
+// Events returns up to limit events after seq, oldest first; limit 0
+// returns all of them
+func (l *EventLog) Events(since uint64, limit int) ([]Event, error) {
+	return l.events(since, time.Time{}, limit)
+}
+
+func (l *EventLog) events(since uint64, until time.Time, limit int) ([]Event, error) {
+	if l.store != nil {
+		return l.store.events(since, until, limit)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	start := sort.Search(len(l.memory), func(i int) bool { return l.memory[i].Seq > since })
+	events := make([]Event, 0)
+	for _, event := range l.memory[start:] {
+		if (!until.IsZero() && event.Time.After(until)) || (limit > 0 && len(events) == limit) {
+			break
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// EventReplay is a page of events after a replay cursor
+type EventReplay struct {
+	Events  []Event `json:"events"`
+	Cursor  uint64  `json:"cursor"` // Seq of the last event read, to replay from next
+	More    bool    `json:"more"`   // Events remain after Cursor
+	LastSeq uint64  `json:"lastSeq"`
+}
+
+// Replay returns up to limit events after the cursor from, so a consumer
+// that was offline can catch up. Only events of the given types are
+// returned, or all with none, but the cursor moves past the others too. It
+// fails with ErrCursorGone when events after from are no longer held.
+func (l *EventLog) Replay(from uint64, limit int, types []string) (EventReplay, error) {
+	l.mu.Lock()
+	last := l.seq
+	oldest := last + 1
+	if l.store != nil {
+		oldest = 1 // The store keeps every event
+	} else if len(l.memory) > 0 {
+		oldest = l.memory[0].Seq
+	}
+	l.mu.Unlock()
+
+	if from > last {
+		return EventReplay{LastSeq: last}, fmt.Errorf("%w: cursor %d is past the last event %d", ErrCursorGone, from, last)
+	}
+	if from+1 < oldest {
+		return EventReplay{LastSeq: last}, fmt.Errorf("%w: events before %d were dropped", ErrCursorGone, oldest)
+	}
+
+	events, err := l.events(from, time.Time{}, limit)
+	if err != nil {
+		return EventReplay{}, err
+	}
+	replay := EventReplay{Events: events, Cursor: from, LastSeq: last}
+	if len(events) > 0 {
+		replay.Cursor = events[len(events)-1].Seq
+	}
+	replay.More = replay.Cursor < last
+	if len(types) > 0 {
+		filtered := events[:0]
+		for _, event := range events {
+			if slices.Contains(types, event.Type) {
+				filtered = append(filtered, event)
+			}
+		}
+		replay.Events = filtered
+	}
+	return replay, nil
+}
+
+// Subscribe delivers events appended from now on. Events are dropped for a
+// subscriber whose buffer is full. Call cancel to unsubscribe.
+func (l *EventLog) Subscribe(buffer int) (events <-chan Event, cancel func()) {
+	ch := make(chan Event, buffer)
+
+	l.mu.Lock()
+	id := l.nextSub
+	l.nextSub++
+	l.subscribers[id] = ch
+	l.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			l.mu.Lock()
+			delete(l.subscribers, id)
+			l.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// State replays the log up to at, or all of it when at is zero
+func (l *EventLog) State(at time.Time) (*EventState, error) {
+	events, err := l.events(0, at, 0)
+	if err != nil {
+		return nil, err
+	}
+	return ReplayEvents(events)
+}
+
+func (l *EventLog) Stats() EventLogStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return EventLogStats{
+		LastSeq:     l.seq,
+		Persistent:  l.store != nil,
+		Subscribers: len(l.subscribers),
+		Dropped:     l.dropped,
+		Failed:      l.failed,
+	}
+}
+
+// ChainState is a registered chain as derived from the event log
+type ChainState struct {
+	ID           string    `json:"id"`
+	Protocol     string    `json:"protocol"`
+	Endpoint     string    `json:"endpoint"`
+	Endpoints    []string  `json:"endpoints,omitempty"`
+	Zone         string    `json:"zone,omitempty"`
+	RegisteredAt time.Time `json:"registeredAt"`
+
+	Capabilities *ChainCapabilities `json:"capabilities,omitempty"`
+	Maintenance  *ChainMaintenance  `json:"maintenance,omitempty"`
+	Canary       *ChainCanary       `json:"canary,omitempty"`
+	Vector       []float64          `json:"vector,omitempty"` // Seeded leading dimensions
+}
+
+// TransactionState is a transaction as derived from the event log
+type TransactionState struct {
+	ID        string    `json:"id"`
+	FromChain string    `json:"fromChain,omitempty"`
+	ToChain   string    `json:"toChain,omitempty"`
+	Status    string    `json:"status,omitempty"` // Empty until recorded in the history
+	Error     string    `json:"error,omitempty"`
+	Pools     []string  `json:"pools"` // Chains whose pools hold the transaction
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// EventState is the chain registry and transaction state at a point in the
+// event log
+type EventState struct {
+	Seq          uint64                       `json:"seq"` // Last event applied
+	At           time.Time                    `json:"at"`
+	Chains       map[string]ChainState        `json:"chains"`
+	Transactions map[string]*TransactionState `json:"transactions"`
+}
+
+// ReplayEvents derives state by applying events in order
+func ReplayEvents(events []Event) (*EventState, error) {
+	state := &EventState{
+		Chains:       make(map[string]ChainState),
+		Transactions: make(map[string]*TransactionState),
+	}
+	for _, event := range events {
+		if err := state.Apply(event); err != nil {
+			return nil, err
+		}
+	}
+	return state, nil
+}
+
+// Apply advances the state by one event. Unknown event types are skipped.
+func (s *EventState) Apply(event Event) error {
+	decode := func(v interface{}) error {
+		if err := json.Unmarshal(event.Data, v); err != nil {
+			return fmt.Errorf("event %d: invalid %s data: %w", event.Seq, event.Type, err)
+		}
+		return nil
+	}
+
+	switch event.Type {
+	case EventChainRegistered:
+		var data ChainRegisteredData
+		if err := decode(&data); err != nil {
+			return err
+		}
+		s.Chains[event.ChainID] = ChainState{
+			ID:           event.ChainID,
+			Protocol:     data.Protocol,
+			Endpoint:     data.Endpoint,
+			Endpoints:    data.Endpoints,
+			Zone:         data.Zone,
+			RegisteredAt: event.Time,
+			Capabilities: data.Capabilities,
+			// A re-registered chain stays in maintenance and keeps its
+			// warm-up
+			Maintenance: s.Chains[event.ChainID].Maintenance,
+			Canary:      s.Chains[event.ChainID].Canary,
+		}
+	case EventChainMaintenance:
+		var data ChainMaintenanceData
+		if err := decode(&data); err != nil {
+			return err
+		}
+		if chain, exists := s.Chains[event.ChainID]; exists {
+			chain.Maintenance = data.Maintenance
+			s.Chains[event.ChainID] = chain
+		}
+	case EventChainCanary:
+		var data ChainCanaryData
+		if err := decode(&data); err != nil {
+			return err
+		}
+		if chain, exists := s.Chains[event.ChainID]; exists {
+			chain.Canary = data.Canary
+			s.Chains[event.ChainID] = chain
+		}
+	case EventChainVector:
+		var data ChainVectorData
+		if err := decode(&data); err != nil {
+			return err
+		}
+		if chain, exists := s.Chains[event.ChainID]; exists {
+			chain.Vector = data.Values
+			s.Chains[event.ChainID] = chain
+		}
+	case EventTransactionRouted:
+		var data TransactionRoutedData
+		if err := decode(&data); err != nil {
+			return err
+		}
+		tx := s.transaction(event.TxID)
+		tx.FromChain, tx.ToChain = data.FromChain, data.ToChain
+		tx.addPool(data.FromChain)
+		tx.addPool(data.ToChain)
+		tx.UpdatedAt = event.Time
+	case EventTransactionPooled:
+		tx := s.transaction(event.TxID)
+		tx.addPool(event.ChainID)
+		tx.UpdatedAt = event.Time
+	case EventTransactionRemoved:
+		if tx, exists := s.Transactions[event.TxID]; exists {
+			tx.removePool(event.ChainID)
+			tx.UpdatedAt = event.Time
+		}
+	case EventTransactionRerouted:
+		tx := s.transaction(event.TxID)
+		tx.ToChain = event.ChainID
+		tx.UpdatedAt = event.Time
+	case EventTransactionStatus:
+		var data TransactionStatusData
+		if err := decode(&data); err != nil {
+			return err
+		}
+		tx := s.transaction(event.TxID)
+		tx.Status, tx.Error = data.Status, data.Error
+		tx.UpdatedAt = event.Time
+	}
+
+	s.Seq = event.Seq
+	s.At = event.Time
+	return nil
+}
+
+func (s *EventState) transaction(id string) *TransactionState {
+	tx, exists := s.Transactions[id]
+	if !exists {
+		tx = &TransactionState{ID: id, Pools: []string{}}
+		s.Transactions[id] = tx
+	}
+	return tx
+}
+
+func (t *TransactionState) addPool(chainID string) {
+	for _, id := range t.Pools {
+		if id == chainID {
+			return
+		}
+	}
+	t.Pools = append(t.Pools, chainID)
+}
+
+func (t *TransactionState) removePool(chainID string) {
+	for i, id := range t.Pools {
+		if id == chainID {
+			t.Pools = append(t.Pools[:i], t.Pools[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package agglomerator
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+func TestEventLogReplay(t *testing.T) {
+	log, err := NewEventLog(nil)
+	require.NoError(t, err)
+	live, cancel := log.Subscribe(16)
+	defer cancel()
+
+	agg := NewAgglomerator(AgglomeratorConfig{Pool: PoolConfig{MaxSize: 1, Policy: PoolPolicyEvict}})
+	agg.SetEventLog(log)
+	for _, id := range []string{"eth", "sol"} {
+		chain := NewChain(id, "http://localhost:8545", ProtocolEthereum)
+		chain.StateVector = vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}
+		require.NoError(t, agg.RegisterChain(chain))
+	}
+	record := func(id string, fee float64) {
+		tx := &Transaction{ID: id, FromChain: "sol", ToChain: "eth", Fee: fee,
+			StateVector: vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}}
+		_, err := agg.recordTransaction(tx)
+		require.NoError(t, err)
+	}
+
+	record("low", 1)
+	time.Sleep(time.Millisecond)
+	checkpoint := time.Now()
+	time.Sleep(time.Millisecond)
+	record("high", 2)
+	_, err = log.Append(EventTransactionStatus, "", "high", TransactionStatusData{Status: TxStatusCompleted})
+	require.NoError(t, err)
+
+	events, err := log.Events(0, 0)
+	require.NoError(t, err)
+	var types []string
+	for i, event := range events {
+		assert.Equal(t, uint64(i+1), event.Seq)
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []string{
+		EventChainRegistered, EventChainRegistered,
+		EventTransactionRouted,
+		EventTransactionRemoved, EventTransactionRemoved, // low evicted from sol and eth
+		EventTransactionRouted,
+		EventTransactionStatus,
+	}, types)
+	assert.Len(t, live, len(events), "subscribers receive every event")
+
+	state, err := log.State(time.Time{})
+	require.NoError(t, err)
+	assert.Len(t, state.Chains, 2)
+	assert.Equal(t, ProtocolEthereum, state.Chains["eth"].Protocol)
+	assert.Empty(t, state.Transactions["low"].Pools)
+	assert.ElementsMatch(t, []string{"sol", "eth"}, state.Transactions["high"].Pools)
+	assert.Equal(t, TxStatusCompleted, state.Transactions["high"].Status)
+
+	past, err := log.State(checkpoint)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), past.Seq)
+	assert.ElementsMatch(t, []string{"sol", "eth"}, past.Transactions["low"].Pools, "state is rebuilt as of the time given")
+	assert.NotContains(t, past.Transactions, "high")
+
+	page, err := log.Events(5, 1)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, uint64(6), page[0].Seq)
+}
+
+func TestEventStoreContinuesLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	store, err := NewEventStore(path)
+	require.NoError(t, err)
+	log, err := NewEventLog(store)
+	require.NoError(t, err)
+	_, err = log.Append(EventChainRegistered, "eth", "", ChainRegisteredData{Protocol: ProtocolEthereum, Endpoint: "http://localhost:8545"})
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	store, err = NewEventStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+	log, err = NewEventLog(store)
+	require.NoError(t, err)
+	event, err := log.Append(EventChainRegistered, "sol", "", ChainRegisteredData{Protocol: ProtocolSolana})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), event.Seq, "sequence numbers continue after a restart")
+
+	state, err := log.State(time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8545", state.Chains["eth"].Endpoint)
+	assert.Contains(t, state.Chains, "sol")
+	assert.True(t, log.Stats().Persistent)
+}
+
+func TestEventLogReplayFromCursor(t *testing.T) {
+	log, err := NewEventLog(nil)
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		eventType := EventTransactionRouted
+		if i%2 == 1 {
+			eventType = EventTransactionStatus
+		}
+		_, err := log.Append(eventType, "", "tx", nil)
+		require.NoError(t, err)
+	}
+
+	page, err := log.Replay(0, 2, nil)
+	require.NoError(t, err)
+	assert.Len(t, page.Events, 2)
+	assert.Equal(t, uint64(2), page.Cursor)
+	assert.True(t, page.More)
+
+	page, err = log.Replay(page.Cursor, 2, []string{EventTransactionStatus})
+	require.NoError(t, err)
+	require.Len(t, page.Events, 1)
+	assert.Equal(t, uint64(4), page.Events[0].Seq)
+	assert.Equal(t, uint64(4), page.Cursor, "the cursor moves past filtered events")
+
+	page, err = log.Replay(page.Cursor, 2, nil)
+	require.NoError(t, err)
+	assert.Len(t, page.Events, 1)
+	assert.False(t, page.More)
+
+	page, err = log.Replay(5, 2, nil)
+	require.NoError(t, err, "a consumer that has everything gets an empty page")
+	assert.Empty(t, page.Events)
+	assert.Equal(t, uint64(5), page.Cursor)
+
+	_, err = log.Replay(9, 2, nil)
+	assert.ErrorIs(t, err, ErrCursorGone, "a cursor from before a restart")
+
+	for i := 0; i < maxMemoryEvents; i++ {
+		log.record(EventTransactionRouted, "", "tx", nil)
+	}
+	_, err = log.Replay(0, 1, nil)
+	assert.ErrorIs(t, err, ErrCursorGone, "the in-memory log dropped the first events")
+	page, err = log.Replay(5, 1, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(6), page.Events[0].Seq)
+}
@@ -0,0 +1,316 @@
+package agglomerator
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+)
+
+// ControlArm is the experiment arm routed with the default weights, taking
+// the transactions the other arms leave
+const ControlArm = "control"
+
+var (
+	ErrInvalidExperiment = errors.New("invalid experiment")
+	ErrNoExperiment      = errors.New("no experiment running")
+)
+
+// ExperimentArm routes a percentage of transactions with its own weights
+type ExperimentArm struct {
+	Name    string       `json:"name"`
+	Percent float64      `json:"percent"`
+	Weights RouteWeights `json:"weights"`
+}
+
+// ExperimentConfig is an A/B experiment on routing: each arm routes its
+// percentage of transactions, and the control arm the rest
+type ExperimentConfig struct {
+	Name string          `json:"name"`
+	Arms []ExperimentArm `json:"arms"`
+}
+
+// Validate checks that the arms are named, weighted and leave room for the
+// control
+func (c ExperimentConfig) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidExperiment)
+	}
+	if len(c.Arms) == 0 {
+		return fmt.Errorf("%w: at least one arm is required", ErrInvalidExperiment)
+	}
+	seen := make(map[string]bool, len(c.Arms))
+	total := 0.0
+	for i, arm := range c.Arms {
+		switch {
+		case arm.Name == "":
+			return fmt.Errorf("%w: arm %d: name is required", ErrInvalidExperiment, i)
+		case arm.Name == ControlArm:
+			return fmt.Errorf("%w: %s is the name of the control arm", ErrInvalidExperiment, ControlArm)
+		case seen[arm.Name]:
+			return fmt.Errorf("%w: arm %s is listed more than once", ErrInvalidExperiment, arm.Name)
+		case arm.Percent <= 0 || math.IsNaN(arm.Percent):
+			return fmt.Errorf("%w: arm %s: percent must be positive", ErrInvalidExperiment, arm.Name)
+		}
+		if err := arm.Weights.Validate(); err != nil {
+			return fmt.Errorf("%w: arm %s: %v", ErrInvalidExperiment, arm.Name, err)
+		}
+		seen[arm.Name] = true
+		total += arm.Percent
+	}
+	if total >= 100 {
+		return fmt.Errorf("%w: arms take %.4g%% of transactions, leaving none to the control", ErrInvalidExperiment, total)
+	}
+	return nil
+}
+
+// RouteArm names the experiment arm that routed a transaction
+type RouteArm struct {
+	Experiment string `json:"experiment"`
+	Arm        string `json:"arm"`
+}
+
+// ArmStats are the outcomes of the transactions an arm routed
+type ArmStats struct {
+	Transactions uint64  `json:"transactions"`
+	Failures     uint64  `json:"failures"`
+	FailureRate  float64 `json:"failureRate"`
+	AvgLatencyMs float64 `json:"avgLatencyMs"` // Routing and submission
+	AvgCost      float64 `json:"avgCost"`      // Relative cost of the destinations' protocols, as routing weighs it
+	Estimated    uint64  `json:"estimated"`    // Transactions whose fees were estimated against a budget
+	AvgFee       float64 `json:"avgFee"`       // Mean estimated fee of those
+}
+
+// ArmComparison is an arm's stats less the control's; negative is lower
+type ArmComparison struct {
+	FailureRate  float64 `json:"failureRate"`
+	AvgLatencyMs float64 `json:"avgLatencyMs"`
+	AvgCost      float64 `json:"avgCost"`
+	AvgFee       float64 `json:"avgFee"`
+}
+
+// ArmReport is an arm with its outcomes so far
+type ArmReport struct {
+	ExperimentArm
+	Stats         ArmStats       `json:"stats"`
+	VersusControl *ArmComparison `json:"versusControl,omitempty"` // Unset for the control and until both arms routed
+}
+
+// ExperimentReport compares the arms of an experiment, control first
+type ExperimentReport struct {
+	Name      string      `json:"name"`
+	StartedAt time.Time   `json:"startedAt"`
+	Arms      []ArmReport `json:"arms"`
+}
+
+// armTotals sums the outcomes of an arm
+type armTotals struct {
+	transactions uint64
+	failures     uint64
+	latency      time.Duration
+	cost         float64
+	estimated    uint64
+	fees         float64
+}
+
+func (t armTotals) stats() ArmStats {
+	stats := ArmStats{Transactions: t.transactions, Failures: t.failures, Estimated: t.estimated}
+	if t.transactions > 0 {
+		n := float64(t.transactions)
+		stats.FailureRate = float64(t.failures) / n
+		stats.AvgLatencyMs = float64(t.latency.Microseconds()) / 1000 / n
+		stats.AvgCost = t.cost / n
+	}
+	if t.estimated > 0 {
+		stats.AvgFee = t.fees / float64(t.estimated)
+	}
+	return stats
+}
+
+// experiment is a running experiment; its config is fixed once started
+type experiment struct {
+	config    ExperimentConfig
+	arms      []ExperimentArm // Control first
+	startedAt time.Time
+
+	mu     sync.Mutex
+	totals map[string]*armTotals
+}
+
+func newExperiment(config ExperimentConfig, now time.Time) *experiment {
+	control := ExperimentArm{Name: ControlArm, Percent: 100, Weights: DefaultRouteWeights()}
+	for _, arm := range config.Arms {
+		control.Percent -= arm.Percent
+	}
+	e := &experiment{
+		config:    config,
+		arms:      append([]ExperimentArm{control}, config.Arms...),
+		startedAt: now,
+		totals:    make(map[string]*armTotals, len(config.Arms)+1),
+	}
+	for _, arm := range e.arms {
+		e.totals[arm.Name] = &armTotals{}
+	}
+	return e
+}
+
+// assign returns the arm routing a transaction. The choice hashes the
+// experiment name and transaction ID, so a transaction keeps its arm when
+// routed again and experiments are independent of each other.
+func (e *experiment) assign(txID string) ExperimentArm {
+	h := fnv.New64a()
+	h.Write([]byte(e.config.Name))
+	h.Write([]byte{0})
+	h.Write([]byte(txID))
+	point := float64(h.Sum64()%10000) / 100
+
+	// Arms take their share from the start of the range, the control the rest
+	for _, arm := range e.arms[1:] {
+		if point < arm.Percent {
+			return arm
+		}
+		point -= arm.Percent
+	}
+	return e.arms[0]
+}
+
+// record adds the outcome of a transaction routed by arm
+func (e *experiment) record(arm string, latency time.Duration, cost float64, fees *FeeEstimate, failed bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	totals, exists := e.totals[arm]
+	if !exists {
+		return
+	}
+	totals.transactions++
+	if failed {
+		totals.failures++
+	}
+	totals.latency += latency
+	totals.cost += cost
+	if fees != nil {
+		totals.estimated++
+		totals.fees += fees.Total
+	}
+}
+
+func (e *experiment) report() ExperimentReport {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	report := ExperimentReport{Name: e.config.Name, StartedAt: e.startedAt, Arms: make([]ArmReport, len(e.arms))}
+	control := e.totals[ControlArm].stats()
+	for i, arm := range e.arms {
+		stats := e.totals[arm.Name].stats()
+		report.Arms[i] = ArmReport{ExperimentArm: arm, Stats: stats}
+		if i > 0 && stats.Transactions > 0 && control.Transactions > 0 {
+			report.Arms[i].VersusControl = &ArmComparison{
+				FailureRate:  stats.FailureRate - control.FailureRate,
+				AvgLatencyMs: stats.AvgLatencyMs - control.AvgLatencyMs,
+				AvgCost:      stats.AvgCost - control.AvgCost,
+				AvgFee:       stats.AvgFee - control.AvgFee,
+			}
+		}
+	}
+	return report
+}
+
+// StartExperiment routes transactions by the arms of config from now on,
+// replacing any experiment running
+func (a *Agglomerator) StartExperiment(config ExperimentConfig) (ExperimentReport, error) {
+	if err := config.Validate(); err != nil {
+		return ExperimentReport{}, err
+	}
+	e := newExperiment(config, a.clock.Now())
+
+	a.mu.Lock()
+	a.experiment = e
+	a.mu.Unlock()
+	return e.report(), nil
+}
+
+// StopExperiment returns routing to the default weights, returning the
+// experiment's final report
+func (a *Agglomerator) StopExperiment() (ExperimentReport, error) {
+	a.mu.Lock()
+	e := a.experiment
+	a.experiment = nil
+	a.mu.Unlock()
+
+	if e == nil {
+		return ExperimentReport{}, ErrNoExperiment
+	}
+	return e.report(), nil
+}
+
+// Experiment reports the running experiment
+func (a *Agglomerator) Experiment() (ExperimentReport, error) {
+	a.mu.RLock()
+	e := a.experiment
+	a.mu.RUnlock()
+
+	if e == nil {
+		return ExperimentReport{}, ErrNoExperiment
+	}
+	return e.report(), nil
+}
+
+// routeWeights returns the weights tx is scored with and, while an
+// experiment runs, the arm they are from. The caller holds a.mu.
+func (a *Agglomerator) routeWeights(tx *Transaction) (RouteWeights, *RouteArm) {
+	if a.experiment == nil {
+		return DefaultRouteWeights(), nil
+	}
+	arm := a.experiment.assign(tx.ID)
+	return arm.Weights, &RouteArm{Experiment: a.experiment.config.Name, Arm: arm.Name}
+}
+
+// experimentArm returns the name of the arm routing tx, or "" when no
+// experiment runs
+func (a *Agglomerator) experimentArm(tx *Transaction) string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if _, arm := a.routeWeights(tx); arm != nil {
+		return arm.Arm
+	}
+	return ""
+}
+
+// recordExperiment adds the outcome of a routed transaction to the arm that
+// routed it. Transactions routed before the running experiment started are
+// left out.
+func (a *Agglomerator) recordExperiment(tx *Transaction, latency time.Duration, err error) {
+	a.mu.RLock()
+	e := a.experiment
+	a.mu.RUnlock()
+	if e == nil {
+		return
+	}
+
+	arm := e.assign(tx.ID).Name
+	destination, protocol := tx.ToChain, ""
+	if route := tx.Route; route != nil {
+		if route.Experiment == nil || route.Experiment.Experiment != e.config.Name {
+			return
+		}
+		arm = route.Experiment.Arm
+		for _, candidate := range route.Candidates {
+			if candidate.Selected {
+				destination, protocol = candidate.ChainID, candidate.Protocol
+			}
+		}
+	}
+	if protocol == "" {
+		if chain, err := a.GetChain(destination); err == nil {
+			protocol = chain.Protocol
+		}
+	}
+	var cost float64
+	if config, exists := getProtocolConfig(protocol); exists {
+		cost = config.CostWeight
+	}
+	e.record(arm, latency, cost, tx.Fees, err != nil)
+}
@@ -0,0 +1,108 @@
+package agglomerator
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+func TestExperimentConfigValidate(t *testing.T) {
+	cheap := ExperimentArm{Name: "cheap", Percent: 20, Weights: RouteWeights{Cost: 1}}
+	assert.NoError(t, ExperimentConfig{Name: "cost", Arms: []ExperimentArm{cheap}}.Validate())
+
+	for name, config := range map[string]ExperimentConfig{
+		"unnamed":     {Arms: []ExperimentArm{cheap}},
+		"no arms":     {Name: "cost"},
+		"control":     {Name: "cost", Arms: []ExperimentArm{{Name: ControlArm, Percent: 20, Weights: cheap.Weights}}},
+		"duplicate":   {Name: "cost", Arms: []ExperimentArm{cheap, cheap}},
+		"no percent":  {Name: "cost", Arms: []ExperimentArm{{Name: "cheap", Weights: cheap.Weights}}},
+		"no weights":  {Name: "cost", Arms: []ExperimentArm{{Name: "cheap", Percent: 20}}},
+		"no control":  {Name: "cost", Arms: []ExperimentArm{{Name: "cheap", Percent: 100, Weights: cheap.Weights}}},
+		"unnamed arm": {Name: "cost", Arms: []ExperimentArm{{Percent: 20, Weights: cheap.Weights}}},
+	} {
+		assert.ErrorIs(t, config.Validate(), ErrInvalidExperiment, name)
+	}
+}
+
+func TestExperimentRouting(t *testing.T) {
+	agg := newMaintenanceAgglomerator(t, core.NewFakeClock(time.Unix(0, 0)))
+	_, err := agg.Experiment()
+	assert.ErrorIs(t, err, ErrNoExperiment)
+
+	report, err := agg.StartExperiment(ExperimentConfig{Name: "cost", Arms: []ExperimentArm{
+		{Name: "cheap", Percent: 30, Weights: RouteWeights{Cost: 1}},
+	}})
+	require.NoError(t, err)
+	require.Len(t, report.Arms, 2)
+	assert.Equal(t, ControlArm, report.Arms[0].Name)
+	assert.InDelta(t, 70, report.Arms[0].Percent, 1e-9, "the control takes the rest")
+
+	cheap := 0
+	for i := 0; i < 1000; i++ {
+		if agg.experimentArm(&Transaction{ID: fmt.Sprintf("tx-%d", i)}) == "cheap" {
+			cheap++
+		}
+	}
+	assert.InDelta(t, 300, cheap, 50)
+	assert.Equal(t, agg.experimentArm(&Transaction{ID: "tx-1"}), agg.experimentArm(&Transaction{ID: "tx-1"}),
+		"a transaction keeps its arm")
+
+	// Explanations carry the arm and score with its weights
+	vector := vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}
+	for i := 0; i < 20; i++ {
+		tx := &Transaction{ID: fmt.Sprintf("tx-%d", i), FromChain: "source", ToChain: "target", StateVector: vector}
+		_, err := agg.recordTransaction(tx)
+		require.NoError(t, err)
+		require.NotNil(t, tx.Route.Experiment)
+		assert.Equal(t, "cost", tx.Route.Experiment.Experiment)
+		arm := tx.Route.Experiment.Arm
+		assert.Equal(t, agg.experimentArm(tx), arm)
+		for _, factor := range tx.Route.Candidates[0].Factors {
+			if factor.Name == "cost" && arm == "cheap" {
+				assert.Equal(t, 1.0, factor.Weight)
+			} else if factor.Name == "cost" {
+				assert.Equal(t, costWeight, factor.Weight)
+			} else if arm == "cheap" {
+				assert.Zero(t, factor.Weight, factor.Name)
+			}
+		}
+
+		var failure error
+		if arm == "cheap" {
+			failure = errors.New("rejected")
+		}
+		agg.recordExperiment(tx, time.Duration(i+1)*time.Millisecond, failure)
+	}
+
+	// Transactions routed by another experiment are left out
+	stale := &Transaction{ID: "tx-stale", ToChain: "target",
+		Route: &RouteExplanation{Experiment: &RouteArm{Experiment: "speed", Arm: ControlArm}}}
+	agg.recordExperiment(stale, time.Second, nil)
+
+	report, err = agg.StopExperiment()
+	require.NoError(t, err)
+	control, treated := report.Arms[0].Stats, report.Arms[1].Stats
+	assert.Equal(t, uint64(20), control.Transactions+treated.Transactions)
+	require.NotZero(t, treated.Transactions, "some of the 20 take the cheap arm")
+	assert.Equal(t, treated.Transactions, treated.Failures)
+	assert.Zero(t, control.Failures)
+	assert.Equal(t, 1.0, treated.FailureRate)
+	assert.InDelta(t, 0.8, control.AvgCost, 1e-9, "ethereum's cost weight")
+	assert.Nil(t, report.Arms[0].VersusControl)
+	require.NotNil(t, report.Arms[1].VersusControl)
+	assert.Equal(t, 1.0, report.Arms[1].VersusControl.FailureRate)
+	assert.InDelta(t, treated.AvgLatencyMs-control.AvgLatencyMs, report.Arms[1].VersusControl.AvgLatencyMs, 1e-9)
+
+	_, err = agg.StopExperiment()
+	assert.ErrorIs(t, err, ErrNoExperiment)
+	tx := &Transaction{ID: "tx-after", FromChain: "source", ToChain: "target", StateVector: vector}
+	_, err = agg.recordTransaction(tx)
+	require.NoError(t, err)
+	assert.Nil(t, tx.Route.Experiment, "routing returns to the default weights")
+}
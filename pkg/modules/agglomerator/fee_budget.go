@@ -0,0 +1,246 @@
+package agglomerator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrFeeBudgetExceeded = errors.New("fee budget exceeded")
+	ErrInvalidFeeBudget  = errors.New("invalid fee budget")
+
+	errNoFeeEstimate = errors.New("no fee estimate")
+	errNoFeeAsset    = errors.New("no fee asset registered")
+)
+
+// What happens to a transaction whose route is estimated to cost more than
+// its fee budget
+const (
+	FeeBudgetReject    = "reject"    // The transaction fails
+	FeeBudgetDowngrade = "downgrade" // It is routed to the best destination within budget
+)
+
+const (
+	// feeQuoteMaxAge is how old a pre-routing fee quote may be and still
+	// stand in for asking the chain
+	feeQuoteMaxAge = time.Minute
+
+	// Transaction metadata keys recording the fees estimated for a budgeted
+	// transaction, so they can be found with meta.feeEstimate
+	feeEstimateMetadataKey = "feeEstimate"
+	feeCurrencyMetadataKey = "feeCurrency"
+)
+
+// FeeBudget caps the fees a transaction may pay across its route. Currency
+// is the asset registry's currency or the symbol of an asset registered on
+// the transaction's source chain.
+type FeeBudget struct {
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency,omitempty"` // The registry's currency if empty
+	OnExceed string  `json:"onExceed,omitempty"` // FeeBudgetReject if empty
+}
+
+// validate checks the budget and fills in the default handling
+func (b *FeeBudget) validate() error {
+	if b.Amount <= 0 || math.IsInf(b.Amount, 0) || math.IsNaN(b.Amount) {
+		return fmt.Errorf("%w: amount must be positive", ErrInvalidFeeBudget)
+	}
+	switch b.OnExceed {
+	case "":
+		b.OnExceed = FeeBudgetReject
+	case FeeBudgetReject, FeeBudgetDowngrade:
+	default:
+		return fmt.Errorf("%w: unknown onExceed %q", ErrInvalidFeeBudget, b.OnExceed)
+	}
+	return nil
+}
+
+// HopFee is the fee estimated on one chain a transaction passes through
+type HopFee struct {
+	ChainID string  `json:"chainId"`
+	Fee     float64 `json:"fee"` // In whole units of Asset
+	Asset   string  `json:"asset,omitempty"`
+	Cost    float64 `json:"cost"`            // Fee in the budget's currency
+	Error   string  `json:"error,omitempty"` // Why the fee could not be estimated; the hop counts as free
+}
+
+// FeeEstimate is the fees a budgeted transaction is expected to pay on its
+// route, compared with its budget
+type FeeEstimate struct {
+	Budget         float64  `json:"budget"`
+	Currency       string   `json:"currency"`
+	Hops           []HopFee `json:"hops"`
+	Total          float64  `json:"total"`                    // Cost of every hop, in Currency
+	DowngradedFrom string   `json:"downgradedFrom,omitempty"` // Destination requested, when it was over budget
+}
+
+// Within reports whether the route fits the budget
+func (e *FeeEstimate) Within() bool {
+	return e.Total <= e.Budget
+}
+
+// destinationsByScore returns the chains other than its source that could
+// take tx in place of its requested destination, highest route score first
+func (a *Agglomerator) destinationsByScore(tx *Transaction) []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	fromChain, exists := a.chains[tx.FromChain]
+	if !exists {
+		return nil
+	}
+	now := a.clock.Now()
+	dims := a.dimsFor(tx)
+
+	type scored struct {
+		id    string
+		score float64
+	}
+	var candidates []scored
+	for _, chain := range a.chains {
+		if chain == fromChain || chain.ID == tx.ToChain || !chainVisible(chain, fromChain.Zone) || chain.serves(tx, now) != nil {
+			continue
+		}
+		candidates = append(candidates, scored{id: chain.ID, score: evaluateRoute(calculateRouteMetrics(chain, tx, dims))})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].id < candidates[j].id
+	})
+
+	ids := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		ids[i] = candidate.id
+	}
+	return ids
+}
+
+// budgetFees estimates the fees of a transaction carrying a fee budget: one
+// on its source chain and one on its destination. A route over budget fails
+// with ErrFeeBudgetExceeded, or with FeeBudgetDowngrade moves the
+// transaction to the highest-scoring destination within budget. The
+// estimate is kept with the transaction and recorded in its metadata.
+func (m *AgglomeratorModule) budgetFees(tx *Transaction) error {
+	tx.Fees = nil
+	budget := tx.FeeBudget
+	if budget == nil {
+		return nil
+	}
+	if err := budget.validate(); err != nil {
+		return err
+	}
+	assets := m.GetAssets()
+	if assets == nil {
+		return fmt.Errorf("%w: no asset registry", ErrInvalidFeeBudget)
+	}
+	currency := budget.Currency
+	if currency == "" {
+		currency = assets.Currency()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), priceFeedTimeout)
+	defer cancel()
+	budgetPrice, err := assets.Price(ctx, tx.FromChain, currency)
+	if err != nil {
+		return err
+	}
+	if budgetPrice <= 0 {
+		return fmt.Errorf("%w %s", ErrNoPrice, currency)
+	}
+
+	estimate := m.estimateFees(ctx, tx.FromChain, tx.ToChain, budget.Amount, currency, budgetPrice)
+	if !estimate.Within() && budget.OnExceed == FeeBudgetDowngrade {
+		for _, id := range m.GetAgglomerator().destinationsByScore(tx) {
+			downgraded := m.estimateFees(ctx, tx.FromChain, id, budget.Amount, currency, budgetPrice)
+			if downgraded.Within() {
+				downgraded.DowngradedFrom = tx.ToChain
+				tx.ToChain = id
+				estimate = downgraded
+				break
+			}
+		}
+	}
+
+	tx.Fees = estimate
+	if tx.Metadata == nil {
+		tx.Metadata = make(map[string]string)
+	}
+	tx.Metadata[feeEstimateMetadataKey] = strconv.FormatFloat(estimate.Total, 'f', -1, 64)
+	tx.Metadata[feeCurrencyMetadataKey] = currency
+	if !estimate.Within() {
+		return fmt.Errorf("%w: route to %s estimated at %g %s, budget %g %s",
+			ErrFeeBudgetExceeded, tx.ToChain, estimate.Total, currency, budget.Amount, currency)
+	}
+	return nil
+}
+
+// estimateFees costs the hops from fromChain to toChain in the budget's
+// currency, priced at budgetPrice in the registry's currency
+func (m *AgglomeratorModule) estimateFees(ctx context.Context, fromChain, toChain string, budget float64, currency string, budgetPrice float64) *FeeEstimate {
+	estimate := &FeeEstimate{Budget: budget, Currency: currency, Hops: make([]HopFee, 0, 2)}
+	hops := []string{fromChain}
+	if toChain != fromChain {
+		hops = append(hops, toChain)
+	}
+	for _, chainID := range hops {
+		hop := m.estimateHop(ctx, chainID, budgetPrice)
+		estimate.Total += hop.Cost
+		estimate.Hops = append(estimate.Hops, hop)
+	}
+	return estimate
+}
+
+// estimateHop estimates the fee paid on a chain, from a recent pre-routing
+// quote or else its adapter, and converts it from the chain's fee asset
+func (m *AgglomeratorModule) estimateHop(ctx context.Context, chainID string, budgetPrice float64) HopFee {
+	hop := HopFee{ChainID: chainID}
+	fee, err := m.chainFee(ctx, chainID)
+	if err != nil {
+		hop.Error = err.Error()
+		return hop
+	}
+	hop.Fee = fee
+
+	assets := m.GetAssets()
+	asset, exists := assets.FeeAsset(chainID)
+	if !exists {
+		hop.Error = errNoFeeAsset.Error()
+		return hop
+	}
+	hop.Asset = asset.Symbol
+	price, err := assets.Price(ctx, chainID, asset.Symbol)
+	if err != nil {
+		hop.Error = err.Error()
+		return hop
+	}
+	hop.Cost = fee * price / budgetPrice
+	return hop
+}
+
+// chainFee returns the fee a transaction currently pays on a chain
+func (m *AgglomeratorModule) chainFee(ctx context.Context, chainID string) (float64, error) {
+	agg := m.GetAgglomerator()
+	if router := agg.PreRouter(); router != nil {
+		if quote, exists := router.FeeQuote(chainID); exists && agg.clock.Now().Sub(quote.EstimatedAt) < feeQuoteMaxAge {
+			return quote.Fee, nil
+		}
+	}
+	chain, err := agg.GetChain(chainID)
+	if err != nil {
+		return 0, err
+	}
+	estimator, ok := chain.Adapter().(FeeEstimator)
+	if !ok {
+		return 0, errNoFeeEstimate
+	}
+	ctx, cancel := context.WithTimeout(ctx, feeEstimateTimeout)
+	defer cancel()
+	return estimator.EstimateFee(ctx)
+}
@@ -0,0 +1,105 @@
+package agglomerator
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+// newFeeModule has a source chain paying 0.001 ETH per transaction, an
+// expensive destination paying 0.01 ETH and a cheap one paying 2 SOL
+func newFeeModule(t *testing.T) *AgglomeratorModule {
+	agg := NewAgglomerator(AgglomeratorConfig{})
+	assets := NewAssetRegistry("USD", StaticPrices{"ETH": 2000, "SOL": 1})
+	for id, fee := range map[string]struct {
+		symbol string
+		base   float64
+	}{
+		"source":    {"ETH", 0.001},
+		"expensive": {"ETH", 0.01},
+		"cheap":     {"SOL", 2},
+	} {
+		endpoint := fmt.Sprintf("mock://%s?baseFee=%g", id, fee.base)
+		require.NoError(t, agg.RegisterChain(NewChain(id, endpoint, ProtocolMock)))
+		require.NoError(t, assets.Register(Asset{Symbol: fee.symbol, Chain: id, Decimals: 18}))
+		require.NoError(t, assets.SetFeeAsset(id, fee.symbol))
+	}
+	return &AgglomeratorModule{agglomerator: agg, assets: assets}
+}
+
+func budgetedTx(to string, budget FeeBudget) *Transaction {
+	return &Transaction{ID: "tx-1", FromChain: "source", ToChain: to, FeeBudget: &budget,
+		StateVector: vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolMock)}}
+}
+
+func TestFeeBudgetEstimatesHops(t *testing.T) {
+	m := newFeeModule(t)
+
+	tx := budgetedTx("expensive", FeeBudget{Amount: 25})
+	require.NoError(t, m.budgetFees(tx))
+	require.NotNil(t, tx.Fees)
+	assert.Equal(t, "USD", tx.Fees.Currency)
+	require.Len(t, tx.Fees.Hops, 2, "a fee is paid on the source and the destination")
+	assert.Equal(t, HopFee{ChainID: "source", Fee: 0.001, Asset: "ETH", Cost: 2}, tx.Fees.Hops[0])
+	assert.InDelta(t, 22, tx.Fees.Total, 1e-9)
+	assert.Equal(t, "USD", tx.Metadata["feeCurrency"])
+	assert.Equal(t, "22", tx.Metadata["feeEstimate"])
+
+	_, err := m.agglomerator.recordTransaction(tx)
+	require.NoError(t, err)
+	assert.Same(t, tx.Fees, tx.Route.Fees, "the estimate is reported with the route")
+
+	tx = budgetedTx("expensive", FeeBudget{Amount: 0.02, Currency: "ETH"})
+	require.NoError(t, m.budgetFees(tx), "budgets may be set in an asset of the source chain")
+	assert.InDelta(t, 0.011, tx.Fees.Total, 1e-9)
+	assert.Equal(t, "ETH", tx.Metadata["feeCurrency"])
+
+	tx = budgetedTx("expensive", FeeBudget{Amount: 1, Currency: "DOT"})
+	assert.ErrorIs(t, m.budgetFees(tx), ErrUnknownAsset)
+}
+
+func TestFeeBudgetRejectsOrDowngrades(t *testing.T) {
+	m := newFeeModule(t)
+
+	tx := budgetedTx("expensive", FeeBudget{Amount: 10})
+	err := m.budgetFees(tx)
+	assert.ErrorIs(t, err, ErrFeeBudgetExceeded)
+	assert.ErrorContains(t, err, "estimated at 22 USD, budget 10 USD")
+	assert.Equal(t, "22", tx.Metadata["feeEstimate"], "the estimate is recorded for the failed transaction")
+
+	tx = budgetedTx("expensive", FeeBudget{Amount: 10, OnExceed: FeeBudgetDowngrade})
+	require.NoError(t, m.budgetFees(tx))
+	assert.Equal(t, "cheap", tx.ToChain)
+	assert.Equal(t, "expensive", tx.Fees.DowngradedFrom)
+	assert.InDelta(t, 4, tx.Fees.Total, 1e-9)
+
+	tx = budgetedTx("expensive", FeeBudget{Amount: 3, OnExceed: FeeBudgetDowngrade})
+	assert.ErrorIs(t, m.budgetFees(tx), ErrFeeBudgetExceeded, "no destination is within budget")
+	assert.Equal(t, "expensive", tx.ToChain)
+
+	for name, budget := range map[string]FeeBudget{
+		"zero amount":      {},
+		"negative amount":  {Amount: -1},
+		"unknown onExceed": {Amount: 1, OnExceed: "queue"},
+	} {
+		assert.ErrorIs(t, m.budgetFees(budgetedTx("cheap", budget)), ErrInvalidFeeBudget, name)
+	}
+}
+
+func TestFeeBudgetUnestimatedHops(t *testing.T) {
+	m := newFeeModule(t)
+	require.NoError(t, m.agglomerator.RegisterChain(NewChain("plain", "http://localhost:8545", ProtocolEthereum)))
+	assert.True(t, m.assets.Remove("cheap", "SOL"))
+
+	tx := budgetedTx("plain", FeeBudget{Amount: 5})
+	require.NoError(t, m.budgetFees(tx))
+	assert.Equal(t, errNoFeeEstimate.Error(), tx.Fees.Hops[1].Error, "chains without an estimator count as free")
+
+	tx = budgetedTx("cheap", FeeBudget{Amount: 5})
+	require.NoError(t, m.budgetFees(tx))
+	assert.Equal(t, HopFee{ChainID: "cheap", Fee: 2, Error: errNoFeeAsset.Error()}, tx.Fees.Hops[1])
+	assert.Empty(t, m.assets.FeeAssets()["cheap"], "removing an asset drops it as a fee asset")
+}
@@ -0,0 +1,46 @@
+package agglomerator
+
+import "github.com/theaxiomverse/hydap-api/pkg/modules/core"
+
+// Feature flags gating P2P behavior per node, so it can be rolled out
+// gradually and switched off without a restart
+const (
+	FlagReplication = "p2p.replication"
+	FlagHedging     = "p2p.hedging"
+)
+
+// FlagGraphQL serves the GraphQL endpoint, which is off by default as one
+// query can read much of the node's state
+const FlagGraphQL = "api.graphql"
+
+func init() {
+	core.DefineFlag(FlagReplication, true, "Replicate stored records to peers")
+	core.DefineFlag(FlagHedging, true, "Re-issue slow peer queries to standby peers")
+	core.DefineFlag(FlagGraphQL, false, "Serve the GraphQL endpoint at /api/graphql")
+}
+
+// UseFlags evaluates feature flags for this node from flags. Without it
+// every flag keeps its default.
+func (node *P2PInfiniteVectorNode) UseFlags(flags *core.FeatureFlags) {
+	node.flags = flags
+}
+
+// flagEnabled reports whether a feature flag is on for this node
+func (node *P2PInfiniteVectorNode) flagEnabled(name string) bool {
+	return node.flags.Enabled(name, node.NodeID)
+}
+
+// flagEnabled reports whether a feature flag is on for the module's node
+func (m *AgglomeratorModule) flagEnabled(name string) bool {
+	var nodeID string
+	if p2p := m.GetP2P(); p2p != nil {
+		nodeID = p2p.p2pNode.NodeID
+	} else if config := m.GetConfig(); config != nil {
+		nodeID = config.NodeID
+	}
+	var flags *core.FeatureFlags // Defaults without a config store
+	if m.configManager != nil {
+		flags = m.configManager.Flags()
+	}
+	return flags.Enabled(name, nodeID)
+}
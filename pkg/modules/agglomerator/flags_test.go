@@ -0,0 +1,73 @@
+package agglomerator
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+func TestReplicationFlag(t *testing.T) {
+	flags, err := core.NewFeatureFlags(nil)
+	require.NoError(t, err)
+	node := NewP2PInfiniteVectorNode("127.0.0.1", 0)
+	node.NodeID = "node-a"
+	node.UseFlags(flags)
+	node.peers["peer-a"] = &PeerInfo{NodeID: "peer-a"}
+
+	node.StoreData(vectors.DatabaseRecord{ID: "record-1", Metadata: map[string]interface{}{}})
+	assert.Len(t, node.bulkQueue, 1, "records are replicated by default")
+	<-node.bulkQueue
+
+	require.NoError(t, flags.Set(core.FeatureFlag{Name: FlagReplication, Enabled: false}))
+	node.StoreData(vectors.DatabaseRecord{ID: "record-2", Metadata: map[string]interface{}{}})
+	assert.Empty(t, node.bulkQueue, "replication is switched off without a restart")
+	node.localDatabase.mu.RLock()
+	_, stored := node.localDatabase.records["record-2"]
+	node.localDatabase.mu.RUnlock()
+	assert.True(t, stored, "records are still stored locally")
+
+	require.NoError(t, flags.Set(core.FeatureFlag{Name: FlagReplication, Enabled: true, Nodes: []string{"node-a"}}))
+	node.StoreData(vectors.DatabaseRecord{ID: "record-3", Metadata: map[string]interface{}{}})
+	assert.Len(t, node.bulkQueue, 1, "listed nodes replicate")
+}
+
+func TestFlagRollout(t *testing.T) {
+	flags, err := core.NewFeatureFlags(nil)
+	require.NoError(t, err)
+
+	enabledAt := func(rollout int) map[string]bool {
+		require.NoError(t, flags.Set(core.FeatureFlag{Name: FlagHedging, Enabled: true, Rollout: rollout}))
+		enabled := make(map[string]bool)
+		for i := 0; i < 1000; i++ {
+			nodeID := fmt.Sprintf("node-%d", i)
+			if flags.Enabled(FlagHedging, nodeID) {
+				enabled[nodeID] = true
+			}
+		}
+		return enabled
+	}
+
+	quarter := enabledAt(25)
+	assert.InDelta(t, 250, len(quarter), 60)
+	half := enabledAt(50)
+	for nodeID := range quarter {
+		assert.True(t, half[nodeID], "raising the rollout keeps the nodes already enabled")
+	}
+
+	require.NoError(t, flags.Reset(FlagHedging))
+	assert.True(t, flags.Enabled(FlagHedging, "node-1"), "reset flags return to their default")
+	assert.False(t, flags.Enabled("undefined", "node-1"))
+	assert.ErrorIs(t, flags.Set(core.FeatureFlag{Name: "undefined", Enabled: true}), core.ErrFlagNotDefined)
+
+	node := NewP2PInfiniteVectorNode("127.0.0.1", 0)
+	node.UseFlags(flags)
+	config := QueryConfig{PeerTimeout: 100 * time.Millisecond, HedgeQuantile: 0.95}
+	assert.Equal(t, 50*time.Millisecond, node.hedgeDelay(config))
+	require.NoError(t, flags.Set(core.FeatureFlag{Name: FlagHedging, Enabled: false}))
+	assert.Zero(t, node.hedgeDelay(config), "hedging is off with its flag")
+}
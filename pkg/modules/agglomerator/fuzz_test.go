@@ -0,0 +1,126 @@
+package agglomerator
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/keymanagement/pb"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/base"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+// Inputs that once crashed a target are kept under testdata/fuzz/<target>
+// and replayed by every go test run
+
+// fuzzDecompressLimit keeps each fuzzed decompression small
+const fuzzDecompressLimit = 1 << 16
+
+func FuzzCompressedBlock(f *testing.F) {
+	compressor := NewAdaptiveCompressor(CompressorConfig{Tolerance: 0.01, MaxRank: 3, EnergyThreshold: 0.95})
+	block, err := compressor.CompressBlock(generateTestData(20))
+	require.NoError(f, err)
+	seed, err := json.Marshal(block)
+	require.NoError(f, err)
+	f.Add(seed)
+	f.Add([]byte(`{"U":[[1]],"V":[[1]],"S":[1],"OriginalRows":1,"OriginalCols":1,"OriginalSize":1}`))
+	f.Add([]byte(`{"U":[[1]],"V":[[1]],"S":[1],"OriginalRows":1099511627776,"OriginalCols":1099511627776,"OriginalSize":1}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var block CompressedBlock
+		if json.Unmarshal(data, &block) != nil {
+			return
+		}
+		values, err := block.DecompressLimit(fuzzDecompressLimit)
+		if err != nil {
+			return
+		}
+		if len(values) != block.OriginalSize {
+			t.Fatalf("decompressed %d values, header says %d", len(values), block.OriginalSize)
+		}
+	})
+}
+
+// FuzzPeerFrames feeds a peer channel's bytes through frame decoding, the
+// version upgrade and inbound message processing
+func FuzzPeerFrames(f *testing.F) {
+	var stream bytes.Buffer
+	require.NoError(f, writeFrame(&stream, Handshake{NodeID: "peer", Algorithms: []string{"DILITHIUM5"}}.proto()))
+	node := NewP2PInfiniteVectorNode("127.0.0.1", 0)
+	record := node.serializeRecord(vectors.DatabaseRecord{ID: "record", Metadata: map[string]interface{}{"type": "chain_registration"}})
+	for _, msg := range []DataTransferMessage{
+		{SenderID: "peer", DataID: "record", Payload: record},
+		{SenderID: "peer", DataID: bloomDataID, Payload: []byte{1, 2, 3}},
+		{SenderID: "peer", DataID: blobDataPrefix + "00", Payload: []byte("blob")},
+		{SenderID: "peer", DataID: zoneDataPrefix + "0123456789abcdef/" + zoneMemberSuffix, Payload: []byte("proof")},
+		{SenderID: "peer", DataID: "chunked", Payload: record[:1], ChunkIndex: 0, ChunkCount: 2},
+	} {
+		require.NoError(f, writeFrame(&stream, msg.proto()))
+	}
+	f.Add(stream.Bytes())
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		node := NewP2PInfiniteVectorNode("127.0.0.1", 0)
+		reader := bufio.NewReader(bytes.NewReader(data))
+
+		var frame pb.Handshake
+		if readFrame(reader, &frame, frameOverhead) != nil {
+			return
+		}
+		handshakeFromProto(&frame)
+
+		for {
+			var envelope pb.Envelope
+			if readFrame(reader, &envelope, node.reassembler.MaxPayload()+frameOverhead) != nil {
+				return
+			}
+			if upgradeEnvelope(&envelope) != nil {
+				return
+			}
+			msg := envelopeFromProto(&envelope)
+			msg.Timestamp = time.Now()
+			node.processDataTransfer(msg)
+		}
+	})
+}
+
+// FuzzTransactionEndpoint posts arbitrary bodies to POST /transaction
+func FuzzTransactionEndpoint(f *testing.F) {
+	f.Add([]byte(`{"id":"tx-1","fromChain":"sol","toChain":"eth","data":"aGVsbG8=","priority":1}`))
+	f.Add([]byte(`{"fromChain":"sol","toChain":"btc","dimensions":-1}`))
+	f.Add([]byte(`{"fromChain":"sol","toChain":"eth","similarity":1e308,"fee":-1}`))
+	f.Add([]byte(`[]`))
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	require.NoError(f, err)
+	defer devNull.Close()
+	module := NewAgglomeratorModule(nil, core.NewMetricsExporter(), &core.ModuleLogger{
+		Outputs: map[string]*os.File{"blockchain_agglomerator": devNull},
+	})
+	module.agglomerator = NewAgglomerator(AgglomeratorConfig{})
+	for _, id := range []string{"eth", "sol"} {
+		chain := NewChain(id, "http://localhost:8545", ProtocolEthereum)
+		chain.StateVector = vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}
+		require.NoError(f, module.agglomerator.RegisterChain(chain))
+	}
+	module.SetState(base.StateRunning)
+	router := NewAPI(module).Routes()
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		req := httptest.NewRequest(http.MethodPost, "/transaction", bytes.NewReader(body))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		var response map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("status %d with a body that is not a JSON object: %q", rec.Code, rec.Body.String())
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package agglomerator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+func TestCollectRecordsKeepsChains(t *testing.T) {
+	agg := NewAgglomerator(AgglomeratorConfig{})
+	require.NoError(t, agg.RegisterChain(NewChain("eth", "http://localhost:8545", ProtocolEthereum)))
+	tx := &Transaction{ID: "tx-1", FromChain: "eth", ToChain: "eth",
+		StateVector: vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}}
+	_, err := agg.recordTransaction(tx)
+	require.NoError(t, err)
+
+	assert.Zero(t, agg.CollectRecords(time.Now().Add(-time.Hour)), "nothing is old enough yet")
+	// The routing index and the chain's pool each hold the transaction
+	assert.Equal(t, 2, agg.CollectRecords(time.Now().Add(time.Hour)))
+	assert.Zero(t, agg.CollectRecords(time.Now().Add(time.Hour)))
+
+	_, err = agg.GetChain("eth")
+	require.NoError(t, err)
+	assert.Equal(t, 1, agg.vectorIndex.Len(), "chain registrations are retained")
+}
+
+func TestGarbageCollectorAppliesRetention(t *testing.T) {
+	module := NewAgglomeratorModule(nil, core.NewMetricsExporter(), &core.ModuleLogger{})
+	module.agglomerator = NewAgglomerator(AgglomeratorConfig{})
+	var config ModuleConfig
+	config.GC.Interval = "1m"
+	config.GC.Retention = map[string]string{"transactions": "1h", "unknown": "1h"}
+	_, err := module.newGarbageCollector(&config)
+	assert.ErrorContains(t, err, "unknown gc store: unknown")
+
+	delete(config.GC.Retention, "unknown")
+	gc, err := module.newGarbageCollector(&config)
+	require.NoError(t, err)
+	pending := module.txManager.Begin("test", "pending")
+	done := module.txManager.Begin("test", "done")
+	module.txManager.UpdateStatus(done.ID, "completed")
+
+	clock := core.NewFakeClock(time.Now())
+	gc.UseClock(clock)
+	assert.Equal(t, map[string]int{"transactions": 0}, gc.RunOnce())
+	clock.Advance(2 * time.Hour)
+	assert.Equal(t, map[string]int{"transactions": 1}, gc.RunOnce())
+	assert.Contains(t, module.txManager.Txns, pending.ID, "pending transactions are kept regardless of age")
+
+	stats := gc.Stats()
+	assert.Equal(t, uint64(2), stats.Runs)
+	assert.Equal(t, uint64(1), stats.Collected["transactions"])
+	assert.Equal(t, clock.Now(), stats.LastRun)
+}
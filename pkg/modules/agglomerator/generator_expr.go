@@ -0,0 +1,408 @@
+package agglomerator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// DefaultGeneratorKey names the generator expression used for protocols
+// without their own
+const DefaultGeneratorKey = "default"
+
+// Bounds on generator expressions, which come from configuration
+const (
+	maxGeneratorLength = 1024
+	maxGeneratorDepth  = 64
+)
+
+var ErrInvalidGenerator = errors.New("invalid generator expression")
+
+// generatorEnv holds the values of an expression's variables for one
+// dimension of one protocol's vector
+type generatorEnv struct {
+	d        float64
+	protocol ChainProtocol
+}
+
+type generatorNode func(env *generatorEnv) float64
+
+// generatorVariables are the names an expression may use: the dimension, the
+// protocol's routing parameters, the factors the built-in generators combine
+// and the built-in base oscillation
+var generatorVariables = map[string]generatorNode{
+	"d":                func(env *generatorEnv) float64 { return env.d },
+	"tps":              func(env *generatorEnv) float64 { return env.protocol.TPS },
+	"blockTime":        func(env *generatorEnv) float64 { return env.protocol.BlockTime },
+	"confirmationTime": func(env *generatorEnv) float64 { return env.protocol.ConfirmationTime },
+	"finality":         func(env *generatorEnv) float64 { return env.protocol.Finality },
+	"costWeight":       func(env *generatorEnv) float64 { return env.protocol.CostWeight },
+	"tpsFactor": func(env *generatorEnv) float64 {
+		return math.Log(1+env.protocol.TPS) / math.Log(1+65000)
+	},
+	"finalityFactor": func(env *generatorEnv) float64 { return 1 - env.protocol.Finality/3600 },
+	"costFactor":     func(env *generatorEnv) float64 { return 1 - env.protocol.CostWeight },
+	"base":           func(env *generatorEnv) float64 { return math.Exp(-env.d/10) * math.Sin(env.d) },
+	"pi":             func(*generatorEnv) float64 { return math.Pi },
+	"e":              func(*generatorEnv) float64 { return math.E },
+}
+
+// generatorFunctions are the functions an expression may call, by arity
+var generatorFunctions = map[string]struct {
+	arity int
+	call  func(args []float64) float64
+}{
+	"sin":   {1, func(a []float64) float64 { return math.Sin(a[0]) }},
+	"cos":   {1, func(a []float64) float64 { return math.Cos(a[0]) }},
+	"tan":   {1, func(a []float64) float64 { return math.Tan(a[0]) }},
+	"tanh":  {1, func(a []float64) float64 { return math.Tanh(a[0]) }},
+	"exp":   {1, func(a []float64) float64 { return math.Exp(a[0]) }},
+	"log":   {1, func(a []float64) float64 { return math.Log(a[0]) }},
+	"sqrt":  {1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"abs":   {1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"floor": {1, func(a []float64) float64 { return math.Floor(a[0]) }},
+	"ceil":  {1, func(a []float64) float64 { return math.Ceil(a[0]) }},
+	"pow":   {2, func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+	"mod":   {2, func(a []float64) float64 { return math.Mod(a[0], a[1]) }},
+	"min":   {2, func(a []float64) float64 { return math.Min(a[0], a[1]) }},
+	"max":   {2, func(a []float64) float64 { return math.Max(a[0], a[1]) }},
+}
+
+// GeneratorExpression is a compiled arithmetic expression computing a vector
+// element from its dimension d and its protocol's parameters. Expressions
+// support numbers, + - * / ^, parentheses, the variables of
+// generatorVariables and the functions of generatorFunctions; they cannot
+// loop or reach anything else, so evaluating one always terminates.
+type GeneratorExpression struct {
+	source string
+	root   generatorNode
+}
+
+// CompileGenerator parses a generator expression such as
+// "exp(-d/10)*sin(d)*tpsFactor"
+func CompileGenerator(source string) (*GeneratorExpression, error) {
+	if len(source) > maxGeneratorLength {
+		return nil, fmt.Errorf("%w: longer than %d characters", ErrInvalidGenerator, maxGeneratorLength)
+	}
+	tokens, err := tokenizeGenerator(source)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%w: empty", ErrInvalidGenerator)
+	}
+	p := &generatorParser{tokens: tokens}
+	root, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected %q", ErrInvalidGenerator, p.tokens[p.pos])
+	}
+	return &GeneratorExpression{source: source, root: root}, nil
+}
+
+// String returns the expression's source
+func (g *GeneratorExpression) String() string {
+	return g.source
+}
+
+// Eval computes the element at dimension d of protocol's vector. Results
+// that are not finite, such as log(0), are 0 so they cannot poison
+// similarities.
+func (g *GeneratorExpression) Eval(protocol ChainProtocol, d int) float64 {
+	value := g.root(&generatorEnv{d: float64(d), protocol: protocol})
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0
+	}
+	return value
+}
+
+// Generator binds the expression to a protocol's parameters
+func (g *GeneratorExpression) Generator(protocol ChainProtocol) func(int) float64 {
+	return func(d int) float64 {
+		return g.Eval(protocol, d)
+	}
+}
+
+var (
+	generatorExpressions   = make(map[string]*GeneratorExpression)
+	generatorExpressionsMu sync.RWMutex
+)
+
+// SetGenerators replaces the generator expressions, keyed by protocol ID or
+// DefaultGeneratorKey. Protocols without one keep their built-in generator.
+// Vectors created before the call keep the generator they were created
+// with.
+func SetGenerators(expressions map[string]string) error {
+	compiled := make(map[string]*GeneratorExpression, len(expressions))
+	for protocol, source := range expressions {
+		expression, err := CompileGenerator(source)
+		if err != nil {
+			return fmt.Errorf("generator %s: %w", protocol, err)
+		}
+		compiled[protocol] = expression
+	}
+
+	generatorExpressionsMu.Lock()
+	defer generatorExpressionsMu.Unlock()
+	generatorExpressions = compiled
+	return nil
+}
+
+// Generators returns the generator expressions in force, keyed by protocol
+func Generators() map[string]string {
+	generatorExpressionsMu.RLock()
+	defer generatorExpressionsMu.RUnlock()
+
+	expressions := make(map[string]string, len(generatorExpressions))
+	for protocol, expression := range generatorExpressions {
+		expressions[protocol] = expression.String()
+	}
+	return expressions
+}
+
+// generatorExpression returns the expression generating protocol's vectors,
+// or nil to use the built-in generator
+func generatorExpression(protocol string) *GeneratorExpression {
+	generatorExpressionsMu.RLock()
+	defer generatorExpressionsMu.RUnlock()
+
+	if expression, exists := generatorExpressions[protocol]; exists {
+		return expression
+	}
+	return generatorExpressions[DefaultGeneratorKey]
+}
+
+// tokenizeGenerator splits an expression into numbers, identifiers and
+// single-character operators
+func tokenizeGenerator(source string) ([]string, error) {
+	var tokens []string
+	runes := []rune(source)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || r == '.':
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			// An exponent, as in 1e-3
+			if i < len(runes) && (runes[i] == 'e' || runes[i] == 'E') {
+				j := i + 1
+				if j < len(runes) && (runes[j] == '+' || runes[j] == '-') {
+					j++
+				}
+				if j < len(runes) && unicode.IsDigit(runes[j]) {
+					for i = j; i < len(runes) && unicode.IsDigit(runes[i]); i++ {
+					}
+				}
+			}
+			tokens = append(tokens, string(runes[start:i]))
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, string(runes[start:i]))
+		case strings.ContainsRune("+-*/^(),", r):
+			tokens = append(tokens, string(r))
+			i++
+		default:
+			return nil, fmt.Errorf("%w: unexpected %q", ErrInvalidGenerator, r)
+		}
+	}
+	return tokens, nil
+}
+
+// generatorParser compiles tokens by recursive descent. Precedence from
+// lowest: + -, * /, unary -, ^ (right associative).
+type generatorParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *generatorParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *generatorParser) expect(token string) error {
+	if p.peek() != token {
+		if p.pos >= len(p.tokens) {
+			return fmt.Errorf("%w: expected %q at end", ErrInvalidGenerator, token)
+		}
+		return fmt.Errorf("%w: expected %q, got %q", ErrInvalidGenerator, token, p.peek())
+	}
+	p.pos++
+	return nil
+}
+
+func (p *generatorParser) expression(depth int) (generatorNode, error) {
+	if depth > maxGeneratorDepth {
+		return nil, fmt.Errorf("%w: nested deeper than %d", ErrInvalidGenerator, maxGeneratorDepth)
+	}
+	left, err := p.term(depth)
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == "+" || op == "-"; op = p.peek() {
+		p.pos++
+		right, err := p.term(depth)
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		if op == "+" {
+			left = func(env *generatorEnv) float64 { return l(env) + right(env) }
+		} else {
+			left = func(env *generatorEnv) float64 { return l(env) - right(env) }
+		}
+	}
+	return left, nil
+}
+
+func (p *generatorParser) term(depth int) (generatorNode, error) {
+	left, err := p.unary(depth)
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == "*" || op == "/"; op = p.peek() {
+		p.pos++
+		right, err := p.unary(depth)
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		if op == "*" {
+			left = func(env *generatorEnv) float64 { return l(env) * right(env) }
+		} else {
+			left = func(env *generatorEnv) float64 { return l(env) / right(env) }
+		}
+	}
+	return left, nil
+}
+
+func (p *generatorParser) unary(depth int) (generatorNode, error) {
+	if depth > maxGeneratorDepth {
+		return nil, fmt.Errorf("%w: nested deeper than %d", ErrInvalidGenerator, maxGeneratorDepth)
+	}
+	switch p.peek() {
+	case "-":
+		p.pos++
+		operand, err := p.unary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return func(env *generatorEnv) float64 { return -operand(env) }, nil
+	case "+":
+		p.pos++
+		return p.unary(depth + 1)
+	}
+	return p.power(depth)
+}
+
+func (p *generatorParser) power(depth int) (generatorNode, error) {
+	base, err := p.primary(depth)
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != "^" {
+		return base, nil
+	}
+	p.pos++
+	exponent, err := p.unary(depth + 1)
+	if err != nil {
+		return nil, err
+	}
+	return func(env *generatorEnv) float64 { return math.Pow(base(env), exponent(env)) }, nil
+}
+
+func (p *generatorParser) primary(depth int) (generatorNode, error) {
+	token := p.peek()
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("%w: unexpected end", ErrInvalidGenerator)
+	case token == "(":
+		p.pos++
+		inner, err := p.expression(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expect(")")
+	case unicode.IsDigit(rune(token[0])) || token[0] == '.':
+		p.pos++
+		value, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid number %q", ErrInvalidGenerator, token)
+		}
+		return func(*generatorEnv) float64 { return value }, nil
+	case unicode.IsLetter(rune(token[0])) || token[0] == '_':
+		p.pos++
+		if p.peek() == "(" {
+			return p.call(token, depth)
+		}
+		variable, exists := generatorVariables[token]
+		if !exists {
+			return nil, fmt.Errorf("%w: unknown variable %q (known: %s)", ErrInvalidGenerator, token, strings.Join(generatorNames(generatorVariables), ", "))
+		}
+		return variable, nil
+	}
+	return nil, fmt.Errorf("%w: unexpected %q", ErrInvalidGenerator, token)
+}
+
+func (p *generatorParser) call(name string, depth int) (generatorNode, error) {
+	function, exists := generatorFunctions[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: unknown function %q", ErrInvalidGenerator, name)
+	}
+	p.pos++ // (
+
+	var args []generatorNode
+	if p.peek() != ")" {
+		for {
+			arg, err := p.expression(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.peek() != "," {
+				break
+			}
+			p.pos++
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if len(args) != function.arity {
+		return nil, fmt.Errorf("%w: %s takes %d arguments, got %d", ErrInvalidGenerator, name, function.arity, len(args))
+	}
+
+	call := function.call
+	return func(env *generatorEnv) float64 {
+		values := make([]float64, len(args))
+		for i, arg := range args {
+			values[i] = arg(env)
+		}
+		return call(values)
+	}, nil
+}
+
+func generatorNames(variables map[string]generatorNode) []string {
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
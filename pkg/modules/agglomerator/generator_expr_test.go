@@ -0,0 +1,79 @@
+package agglomerator
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileGenerator(t *testing.T) {
+	eth, _ := getProtocolConfig(ProtocolEthereum)
+	for source, expected := range map[string]func(d float64) float64{
+		"exp(-d/10)*sin(d)":   func(d float64) float64 { return math.Exp(-d/10) * math.Sin(d) },
+		"base * tpsFactor":    func(d float64) float64 { return math.Exp(-d/10) * math.Sin(d) * math.Log(16) / math.Log(65001) },
+		"1 - 2 - 3":           func(float64) float64 { return -4 },
+		"2 ^ 3 ^ 2":           func(float64) float64 { return 512 },
+		"-2 ^ 2":              func(float64) float64 { return -4 },
+		"max(d, 3) / 1.5e1":   func(d float64) float64 { return math.Max(d, 3) / 15 },
+		"(blockTime + 3) * d": func(d float64) float64 { return 15 * d },
+		"log(d + 1)":          func(d float64) float64 { return math.Log(d + 1) },
+	} {
+		expression, err := CompileGenerator(source)
+		require.NoError(t, err, source)
+		for d := 0; d < 5; d++ {
+			assert.InDelta(t, expected(float64(d)), expression.Eval(eth, d), 1e-12, "%s at %d", source, d)
+		}
+	}
+
+	infinite, err := CompileGenerator("log(d)")
+	require.NoError(t, err)
+	assert.Zero(t, infinite.Eval(eth, 0), "results that are not finite are 0")
+
+	for source, message := range map[string]string{
+		"":             "empty",
+		"sin(d":        `expected ")"`,
+		"d +":          "unexpected end",
+		"tps $ 2":      "unexpected",
+		"gasPrice * d": "unknown variable",
+		"os(1)":        "unknown function",
+		"pow(d)":       "takes 2 arguments",
+		"d d":          "unexpected",
+		strings.Repeat("(", 70) + "d" + strings.Repeat(")", 70): "nested deeper",
+		strings.Repeat("d+", 600) + "d":                         "longer than",
+	} {
+		_, err := CompileGenerator(source)
+		require.ErrorIs(t, err, ErrInvalidGenerator, source)
+		assert.Contains(t, err.Error(), message, source)
+	}
+}
+
+func TestSetGenerators(t *testing.T) {
+	t.Cleanup(func() { SetGenerators(nil) })
+	builtin := getProtocolGenerator(ProtocolEthereum)
+
+	require.NoError(t, SetGenerators(map[string]string{
+		ProtocolEthereum:    "d * costWeight",
+		DefaultGeneratorKey: "1",
+	}))
+	assert.Equal(t, map[string]string{ProtocolEthereum: "d * costWeight", DefaultGeneratorKey: "1"}, Generators())
+	assert.InDelta(t, 2.4, getProtocolGenerator(ProtocolEthereum)(3), 1e-12)
+	assert.Equal(t, 1.0, getProtocolGenerator(ProtocolSolana)(3), "other protocols take the default")
+	assert.Equal(t, 1.0, getProtocolGenerator("unknown")(3))
+
+	agg := NewAgglomerator(AgglomeratorConfig{})
+	require.NoError(t, agg.RegisterChain(NewChain("eth", "http://eth:8545", ProtocolEthereum)))
+	chain, err := agg.GetChain("eth")
+	require.NoError(t, err)
+	assert.InDelta(t, 4.0, chain.StateVector.GetElement(5), 1e-12, "registered chains take the expression")
+
+	err = SetGenerators(map[string]string{ProtocolSolana: "sin("})
+	assert.ErrorIs(t, err, ErrInvalidGenerator)
+	assert.Contains(t, err.Error(), "generator sol")
+	assert.Len(t, Generators(), 2, "an invalid set leaves the generators unchanged")
+
+	require.NoError(t, SetGenerators(nil))
+	assert.Equal(t, builtin(7), getProtocolGenerator(ProtocolEthereum)(7))
+}
@@ -0,0 +1,459 @@
+package agglomerator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+var (
+	errAgglomeratorNotInitialized = errors.New("agglomerator not initialized")
+	errHistoryNotConfigured       = errors.New("transaction history not configured")
+)
+
+// GraphQLAPI serves a read-only GraphQL view of the module registry and the
+// agglomerator's state, so nested reads such as a chain's pending
+// transactions and their routes take one request. It is off unless
+// FlagGraphQL is on for the node.
+type GraphQLAPI struct {
+	module *AgglomeratorModule
+	schema *core.GraphQLSchema
+}
+
+// NewGraphQLAPI builds the GraphQL schema over module and the modules in
+// registry
+func NewGraphQLAPI(module *AgglomeratorModule, registry *core.ModuleRegistry) (*GraphQLAPI, error) {
+	api := &GraphQLAPI{module: module}
+	schema, err := core.NewGraphQLSchema("Query", api.types(registry), map[string]string{
+		"Time": "An RFC 3339 timestamp",
+		"JSON": "Any JSON value",
+	})
+	if err != nil {
+		return nil, err
+	}
+	api.schema = schema
+	return api, nil
+}
+
+func (api *GraphQLAPI) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Use(api.requireFlag)
+	r.Get("/", api.schema.ServeHTTP)
+	r.Post("/", api.schema.ServeHTTP)
+	r.Get("/schema", api.GetSchema)
+	return r
+}
+
+// requireFlag hides the endpoint while FlagGraphQL is off for the node
+func (api *GraphQLAPI) requireFlag(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !api.module.flagEnabled(FlagGraphQL) {
+			respondError(w, http.StatusNotFound, "GraphQL is disabled; enable the "+FlagGraphQL+" flag")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GetSchema returns the schema in the GraphQL schema definition language
+func (api *GraphQLAPI) GetSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(api.schema.SDL()))
+}
+
+// graphQLModule is a registered module, checking the health of every
+// module at most once per query
+type graphQLModule struct {
+	core.ModuleInfo
+	health func() map[string]core.ModuleHealth
+}
+
+// graphQLPending is a transaction waiting in a chain's pool
+type graphQLPending struct {
+	PoolEntry
+	chain *Chain
+}
+
+func (api *GraphQLAPI) types(registry *core.ModuleRegistry) map[string]*core.GraphQLObject {
+	history := func(args map[string]interface{}, terms ...string) (interface{}, error) {
+		store := api.module.GetTransactionStore()
+		if store == nil {
+			return nil, errHistoryNotConfigured
+		}
+		query, err := ParseTransactionQuery(strings.Join(append(terms, args["query"].(string)), " "))
+		if err != nil {
+			return nil, err
+		}
+		query.Limit = args["limit"].(int)
+		query.Offset = args["offset"].(int)
+		if query.Limit < 0 || query.Offset < 0 {
+			return nil, errors.New("limit and offset must not be negative")
+		}
+		return store.Query(query)
+	}
+	historyArgs := map[string]core.GraphQLArgument{
+		"query":  {Type: "String!", Default: "", Description: "History query terms, such as status:failed"},
+		"limit":  {Type: "Int!", Default: 50},
+		"offset": {Type: "Int!", Default: 0},
+	}
+	transaction := func(id string) (interface{}, error) {
+		store := api.module.GetTransactionStore()
+		if store == nil {
+			return nil, errHistoryNotConfigured
+		}
+		record, err := store.Get(id)
+		if errors.Is(err, ErrTransactionNotFound) {
+			return nil, nil
+		}
+		return record, err
+	}
+	route := func(id string) (interface{}, error) {
+		store := api.module.GetTransactionStore()
+		if store == nil {
+			return nil, errHistoryNotConfigured
+		}
+		explanation, err := store.Route(id)
+		if errors.Is(err, ErrTransactionNotFound) {
+			return nil, nil
+		}
+		return explanation, err
+	}
+	chain := func(id string) (interface{}, error) {
+		agg := api.module.GetAgglomerator()
+		if agg == nil {
+			return nil, errAgglomeratorNotInitialized
+		}
+		chain, err := agg.GetChain(id)
+		if err != nil {
+			return nil, nil
+		}
+		return chain, nil
+	}
+	optional := func(value string) interface{} {
+		if value == "" {
+			return nil
+		}
+		return value
+	}
+
+	return map[string]*core.GraphQLObject{
+		"Query": {Fields: map[string]*core.GraphQLField{
+			"modules": {
+				Type:        "[Module!]!",
+				Description: "Registered modules, by name",
+				Resolve: func(ctx context.Context, _ interface{}, _ map[string]interface{}) (interface{}, error) {
+					return graphQLModules(ctx, registry), nil
+				},
+			},
+			"module": {
+				Type: "Module",
+				Args: map[string]core.GraphQLArgument{"name": {Type: "String!"}},
+				Resolve: func(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+					for _, module := range graphQLModules(ctx, registry) {
+						if module.Name == args["name"] {
+							return module, nil
+						}
+					}
+					return nil, nil
+				},
+			},
+			"chains": {
+				Type:        "[Chain!]!",
+				Description: "Registered chains, by ID",
+				Args:        map[string]core.GraphQLArgument{"protocol": {Type: "String"}},
+				Resolve: func(_ context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+					agg := api.module.GetAgglomerator()
+					if agg == nil {
+						return nil, errAgglomeratorNotInitialized
+					}
+					chains := agg.ListChains()
+					sort.Slice(chains, func(i, j int) bool { return chains[i].ID < chains[j].ID })
+					if protocol, ok := args["protocol"].(string); ok {
+						matched := chains[:0]
+						for _, chain := range chains {
+							if chain.Protocol == protocol {
+								matched = append(matched, chain)
+							}
+						}
+						chains = matched
+					}
+					return chains, nil
+				},
+			},
+			"chain": {
+				Type: "Chain",
+				Args: map[string]core.GraphQLArgument{"id": {Type: "ID!"}},
+				Resolve: func(_ context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+					return chain(args["id"].(string))
+				},
+			},
+			"transactions": {
+				Type:        "[Transaction!]!",
+				Description: "Recorded transactions matching a history query, newest first",
+				Args:        historyArgs,
+				Resolve: func(_ context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+					return history(args)
+				},
+			},
+			"transaction": {
+				Type: "Transaction",
+				Args: map[string]core.GraphQLArgument{"id": {Type: "ID!"}},
+				Resolve: func(_ context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+					return transaction(args["id"].(string))
+				},
+			},
+			"peers": {
+				Type:        "[Peer!]!",
+				Description: "Connected P2P peers, by ID",
+				Resolve: func(_ context.Context, _ interface{}, _ map[string]interface{}) (interface{}, error) {
+					p2p := api.module.GetP2P()
+					if p2p == nil {
+						return []PeerInfo{}, nil
+					}
+					return p2p.p2pNode.Peers(), nil
+				},
+			},
+			"metrics": {
+				Type:        "[MetricSeries!]!",
+				Description: "Recorded metric history; from and to take an RFC 3339 time, unix seconds or a duration before now",
+				Args: map[string]core.GraphQLArgument{
+					"series": {Type: "[String!]", Description: "Series names; every series when omitted"},
+					"from":   {Type: "String!", Default: "1h"},
+					"to":     {Type: "String"},
+					"step":   {Type: "String", Description: "Average samples into buckets of this duration"},
+				},
+				Resolve: func(_ context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+					return api.metrics(args)
+				},
+			},
+		}},
+		"Module": {Fields: map[string]*core.GraphQLField{
+			"name":    {Type: "String!"},
+			"version": {Type: "String!"},
+			"status": {Type: "String!", Resolve: func(_ context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+				return source.(graphQLModule).Status.String(), nil
+			}},
+			"dependencies": {Type: "[String!]!"},
+			"panic":        {Type: "JSON", Description: "Why the module is in the error state"},
+			"health": {Type: "ModuleHealth", Resolve: func(_ context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+				module := source.(graphQLModule)
+				health, exists := module.health()[module.Name]
+				if !exists {
+					return nil, nil
+				}
+				return health, nil
+			}},
+		}},
+		"ModuleHealth": {Fields: map[string]*core.GraphQLField{
+			"status":      {Type: "String!"},
+			"lastChecked": {Type: "Time!", Resolve: graphQLFieldOf(func(h core.ModuleHealth) interface{} { return h.LastChecked })},
+			"error":       {Type: "String", Resolve: graphQLFieldOf(func(h core.ModuleHealth) interface{} { return optional(h.Error) })},
+			"checks":      {Type: "JSON"},
+		}},
+		"Chain": {Fields: map[string]*core.GraphQLField{
+			"id":        {Type: "ID!"},
+			"endpoint":  {Type: "String!"},
+			"endpoints": {Type: "[String!]!", Resolve: graphQLFieldOf(func(c *Chain) interface{} { return c.AllEndpoints() })},
+			"protocol":  {Type: "String!"},
+			"zone":      {Type: "String", Resolve: graphQLFieldOf(func(c *Chain) interface{} { return optional(c.Zone) })},
+			"cluster": {Type: "Int", Resolve: graphQLFieldOf(func(c *Chain) interface{} {
+				if agg := api.module.GetAgglomerator(); agg != nil {
+					if cluster, ok := agg.ClusterOf(c.ID); ok {
+						return cluster
+					}
+				}
+				return nil
+			})},
+			"blockHeight": {Type: "Int", Description: "Head height reported by the chain's adapter", Resolve: graphQLFieldOf(func(c *Chain) interface{} {
+				if adapter := c.Adapter(); adapter != nil {
+					return adapter.BlockHeight()
+				}
+				return nil
+			})},
+			"pool": {Type: "PoolStats", Resolve: graphQLFieldOf(func(c *Chain) interface{} {
+				if stats, ok := c.PoolStats(); ok {
+					return stats
+				}
+				return nil
+			})},
+			"pendingTransactions": {
+				Type:        "[PendingTransaction!]!",
+				Description: "Transactions in the chain's pool, in pool order",
+				Args:        map[string]core.GraphQLArgument{"limit": {Type: "Int!", Default: 100}},
+				Resolve: func(_ context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+					c := source.(*Chain)
+					entries := c.PoolEntries()
+					if limit := args["limit"].(int); limit >= 0 && len(entries) > limit {
+						entries = entries[:limit]
+					}
+					pending := make([]graphQLPending, len(entries))
+					for i, entry := range entries {
+						pending[i] = graphQLPending{PoolEntry: entry, chain: c}
+					}
+					return pending, nil
+				},
+			},
+			"transactions": {
+				Type:        "[Transaction!]!",
+				Description: "Recorded transactions from or to the chain, newest first",
+				Args:        historyArgs,
+				Resolve: func(_ context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+					return history(args, "chain:"+source.(*Chain).ID)
+				},
+			},
+		}},
+		"PoolStats": {Fields: map[string]*core.GraphQLField{
+			"size":      {Type: "Int!"},
+			"maxSize":   {Type: "Int!"},
+			"occupancy": {Type: "Float!"},
+			"policy":    {Type: "String!"},
+			"admitted":  {Type: "Int!"},
+			"rejected":  {Type: "Int!"},
+			"evicted":   {Type: "Int!"},
+		}},
+		"PendingTransaction": {Fields: map[string]*core.GraphQLField{
+			"id":       {Type: "ID!", Resolve: graphQLFieldOf(func(p graphQLPending) interface{} { return p.TxID })},
+			"priority": {Type: "Int!"},
+			"fee":      {Type: "Float!"},
+			"addedAt":  {Type: "Time!", Resolve: graphQLFieldOf(func(p graphQLPending) interface{} { return p.AddedAt })},
+			"chain":    {Type: "Chain!", Resolve: graphQLFieldOf(func(p graphQLPending) interface{} { return p.chain })},
+			"transaction": {Type: "Transaction", Description: "The transaction's history record", Resolve: func(_ context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+				return transaction(source.(graphQLPending).TxID)
+			}},
+			"route": {Type: "Route", Resolve: func(_ context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+				return route(source.(graphQLPending).TxID)
+			}},
+		}},
+		"Transaction": {Fields: map[string]*core.GraphQLField{
+			"id":        {Type: "ID!"},
+			"fromChain": {Type: "String!"},
+			"toChain":   {Type: "String!"},
+			"status":    {Type: "String!"},
+			"error":     {Type: "String", Resolve: graphQLFieldOf(func(t TransactionRecord) interface{} { return optional(t.Error) })},
+			"blobRef":   {Type: "String", Resolve: graphQLFieldOf(func(t TransactionRecord) interface{} { return optional(t.BlobRef) })},
+			"size":      {Type: "Int!"},
+			"metadata":  {Type: "JSON"},
+			"createdAt": {Type: "Time!", Resolve: graphQLFieldOf(func(t TransactionRecord) interface{} { return t.CreatedAt })},
+			"from": {Type: "Chain", Description: "The source chain, when registered", Resolve: func(_ context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+				return chain(source.(TransactionRecord).FromChain)
+			}},
+			"to": {Type: "Chain", Description: "The destination chain, when registered", Resolve: func(_ context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+				return chain(source.(TransactionRecord).ToChain)
+			}},
+			"route": {Type: "Route", Resolve: func(_ context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+				return route(source.(TransactionRecord).ID)
+			}},
+		}},
+		"Route": {Description: "Why a transaction took its route", Fields: map[string]*core.GraphQLField{
+			"mode":       {Type: "String!"},
+			"route":      {Type: "[String!]!", Description: "Chain IDs in route order"},
+			"candidates": {Type: "[RouteCandidate!]!", Description: "Every chain considered, highest score first"},
+			"excluded":   {Type: "JSON", Description: "Chains that could not serve the transaction"},
+			"fees":       {Type: "JSON"},
+			"createdAt":  {Type: "Time!", Resolve: graphQLFieldOf(func(r *RouteExplanation) interface{} { return r.CreatedAt })},
+		}},
+		"RouteCandidate": {Fields: map[string]*core.GraphQLField{
+			"chainId":  {Type: "ID!"},
+			"protocol": {Type: "String!"},
+			"local":    {Type: "Boolean!"},
+			"score":    {Type: "Float!"},
+			"selected": {Type: "Boolean!"},
+			"factors":  {Type: "[RouteFactor!]!"},
+			"chain": {Type: "Chain", Description: "The candidate chain, when still registered", Resolve: func(_ context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+				return chain(source.(RouteCandidate).ChainID)
+			}},
+		}},
+		"RouteFactor": {Fields: map[string]*core.GraphQLField{
+			"name":         {Type: "String!"},
+			"value":        {Type: "Float!"},
+			"weight":       {Type: "Float!"},
+			"contribution": {Type: "Float!"},
+		}},
+		"Peer": {Fields: map[string]*core.GraphQLField{
+			"id":         {Type: "ID!", Resolve: graphQLFieldOf(func(p PeerInfo) interface{} { return p.NodeID })},
+			"address":    {Type: "String!"},
+			"lastSeen":   {Type: "Time!"},
+			"reputation": {Type: "Float!"},
+		}},
+		"MetricSeries": {Fields: map[string]*core.GraphQLField{
+			"name":    {Type: "String!"},
+			"samples": {Type: "[MetricSample!]!"},
+		}},
+		"MetricSample": {Fields: map[string]*core.GraphQLField{
+			"time":  {Type: "Time!", Resolve: graphQLFieldOf(func(s MetricSample) interface{} { return s.Time })},
+			"value": {Type: "Float!", Resolve: graphQLFieldOf(func(s MetricSample) interface{} { return s.Value })},
+		}},
+	}
+}
+
+// graphQLFieldOf resolves a field computed from a source of type T
+func graphQLFieldOf[T any](field func(T) interface{}) core.GraphQLResolver {
+	return func(_ context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+		return field(source.(T)), nil
+	}
+}
+
+// graphQLModules lists the registered modules by name
+func graphQLModules(ctx context.Context, registry *core.ModuleRegistry) []graphQLModule {
+	health := sync.OnceValue(func() map[string]core.ModuleHealth {
+		return registry.GetAllHealth(ctx)
+	})
+	infos := registry.List()
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	modules := make([]graphQLModule, len(infos))
+	for i, info := range infos {
+		modules[i] = graphQLModule{ModuleInfo: info, health: health}
+	}
+	return modules
+}
+
+// metrics queries the metrics history like GET /api/metrics/history
+func (api *GraphQLAPI) metrics(args map[string]interface{}) (interface{}, error) {
+	history := api.module.GetMetricsHistory()
+	if history == nil {
+		return nil, errors.New("metrics history not enabled")
+	}
+
+	now := time.Now()
+	from, err := parseHistoryTime(args["from"].(string), now, now.Add(-time.Hour))
+	if err != nil {
+		return nil, fmt.Errorf("invalid from: %w", err)
+	}
+	to := now
+	if value, ok := args["to"].(string); ok {
+		if to, err = parseHistoryTime(value, now, now); err != nil {
+			return nil, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+	if to.Before(from) {
+		return nil, errors.New("to is before from")
+	}
+	var step time.Duration
+	if value, ok := args["step"].(string); ok {
+		if step, err = parseDuration(value); err != nil || step <= 0 {
+			return nil, fmt.Errorf("invalid step %q", value)
+		}
+	}
+	var names []string
+	if series, ok := args["series"].([]interface{}); ok {
+		for _, name := range series {
+			names = append(names, name.(string))
+		}
+	}
+
+	result := history.Query(names, from, to, step)
+	series := make([]map[string]interface{}, 0, len(result))
+	for name, samples := range result {
+		series = append(series, map[string]interface{}{"name": name, "samples": samples})
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i]["name"].(string) < series[j]["name"].(string) })
+	return series, nil
+}
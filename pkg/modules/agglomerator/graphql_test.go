@@ -0,0 +1,175 @@
+package agglomerator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+// graphQLResult is a decoded GraphQL response
+type graphQLResult struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []core.GraphQLError    `json:"errors"`
+}
+
+func executeGraphQL(t *testing.T, schema *core.GraphQLSchema, query string, variables map[string]interface{}) (graphQLResult, string) {
+	t.Helper()
+	response := schema.Execute(context.Background(), core.GraphQLRequest{Query: query, Variables: variables})
+	var result graphQLResult
+	if response.Data != nil {
+		require.NoError(t, json.Unmarshal(response.Data, &result.Data))
+	}
+	result.Errors = response.Errors
+	return result, string(response.Data)
+}
+
+func TestGraphQLNestedQuery(t *testing.T) {
+	module := newRerouteModule(t)
+	require.NoError(t, module.ProcessTransaction(&Transaction{ID: "tx-1", FromChain: "source", ToChain: "up-a", Priority: 3}))
+	api, err := NewGraphQLAPI(module, core.NewModuleRegistry(nil))
+	require.NoError(t, err)
+
+	result, _ := executeGraphQL(t, api.schema, `query Pending($chain: ID!) {
+		chain(id: $chain) {
+			id
+			pendingTransactions { id transaction { status from { id } } route { route candidates { chainId chain { protocol } } } }
+		}
+		missing: chain(id: "missing") { id }
+		transactions(query: "to:up-a") { id }
+	}`, map[string]interface{}{"chain": "up-a"})
+	require.Empty(t, result.Errors)
+
+	chain := result.Data["chain"].(map[string]interface{})
+	assert.Equal(t, "up-a", chain["id"])
+	pending := chain["pendingTransactions"].([]interface{})
+	require.Len(t, pending, 1)
+	tx := pending[0].(map[string]interface{})
+	assert.Equal(t, "tx-1", tx["id"])
+	assert.Equal(t, map[string]interface{}{"status": "completed", "from": map[string]interface{}{"id": "source"}}, tx["transaction"])
+	route := tx["route"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"up-a"}, route["route"])
+	assert.NotEmpty(t, route["candidates"])
+
+	assert.Nil(t, result.Data["missing"], "unknown chains are null")
+	assert.Equal(t, []interface{}{map[string]interface{}{"id": "tx-1"}}, result.Data["transactions"])
+}
+
+func TestGraphQLRequiresFlag(t *testing.T) {
+	api, err := NewGraphQLAPI(newRerouteModule(t), core.NewModuleRegistry(nil))
+	require.NoError(t, err)
+	server := httptest.NewServer(api.Routes())
+	defer server.Close()
+
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(`{"query": "{ chains { id } }"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "the flag is off by default")
+}
+
+// testGraphQLSchema is a schema exercising the executor without module
+// state
+func testGraphQLSchema(t *testing.T) *core.GraphQLSchema {
+	type item struct {
+		Name  string `json:"name"`
+		Count int
+	}
+	schema, err := core.NewGraphQLSchema("Query", map[string]*core.GraphQLObject{
+		"Query": {Fields: map[string]*core.GraphQLField{
+			"items": {
+				Type: "[Item!]!",
+				Args: map[string]core.GraphQLArgument{"limit": {Type: "Int!", Default: 10}},
+				Resolve: func(_ context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+					items := []item{{Name: "a", Count: 1}, {Name: "b", Count: 2}}
+					return items[:min(args["limit"].(int), len(items))], nil
+				},
+			},
+			"echo": {
+				Type: "String",
+				Args: map[string]core.GraphQLArgument{"value": {Type: "String!"}},
+				Resolve: func(_ context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+					return args["value"], nil
+				},
+			},
+			"broken": {Type: "Item", Resolve: func(context.Context, interface{}, map[string]interface{}) (interface{}, error) {
+				return nil, errors.New("resolver failed")
+			}},
+			"nullItems": {Type: "[Item!]", Resolve: func(context.Context, interface{}, map[string]interface{}) (interface{}, error) {
+				return []*item{{Name: "a"}, nil}, nil
+			}},
+		}},
+		"Item": {Fields: map[string]*core.GraphQLField{
+			"name":  {Type: "String!"},
+			"count": {Type: "Int!"},
+			"self": {Type: "Item!", Resolve: func(_ context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+				return source, nil
+			}},
+		}},
+	}, nil)
+	require.NoError(t, err)
+	return schema
+}
+
+func TestGraphQLExecution(t *testing.T) {
+	schema := testGraphQLSchema(t)
+
+	_, raw := executeGraphQL(t, schema, `{ second: echo(value: "x") items(limit: 1) { __typename count name } first: echo(value: "y") }`, nil)
+	assert.Equal(t, `{"second":"x","items":[{"__typename":"Item","count":1,"name":"a"}],"first":"y"}`, raw, "fields keep their selection order")
+
+	result, _ := executeGraphQL(t, schema, `query Q($v: String = "default") { echo(value: $v) }`, nil)
+	assert.Equal(t, "default", result.Data["echo"])
+	result, _ = executeGraphQL(t, schema, `query Q($n: Int!) { items(limit: $n) { name } }`, map[string]interface{}{"n": 1.0})
+	assert.Len(t, result.Data["items"], 1, "JSON numbers coerce to Int")
+
+	result, _ = executeGraphQL(t, schema, `{ broken { name } items { name } }`, nil)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "resolver failed", result.Errors[0].Message)
+	assert.Equal(t, []interface{}{"broken"}, result.Errors[0].Path)
+	assert.Nil(t, result.Data["broken"])
+	assert.Len(t, result.Data["items"], 2, "other fields still resolve")
+
+	result, _ = executeGraphQL(t, schema, `{ nullItems { name } }`, nil)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, []interface{}{"nullItems", 1}, result.Errors[0].Path)
+	assert.Contains(t, result.Data, "nullItems")
+	assert.Nil(t, result.Data["nullItems"], "a null element nulls the nullable list")
+}
+
+func TestGraphQLRejectsInvalidQueries(t *testing.T) {
+	schema := testGraphQLSchema(t)
+	for query, message := range map[string]string{
+		`{ items { name }`:              "syntax error at 1:17",
+		`{ items { missing } }`:         "cannot query field missing on type Item",
+		`{ items }`:                     "must have a selection of subfields",
+		`{ echo(value: "x") { name } }`: "has no subfields",
+		`{ echo }`:                      "requires argument value",
+		`{ echo(value: 1) }`:            "expected String",
+		`{ items(size: 1) { name } }`:   "unknown argument size",
+		`query Q($n: String) { items(limit: $n) { name } }`:         "cannot be used as argument limit",
+		`{ echo(value: $v) }`:                                       "variable $v is not defined",
+		`query Q($v: String!) { echo(value: $v) }`:                  "was not provided",
+		`{ a: echo(value: "x") a: echo(value: "y") }`:               "selected more than once",
+		`{ ...Fields }`:                                             "fragments are not supported",
+		`mutation { echo(value: "x") }`:                             "only queries are supported",
+		`query A { echo(value: "a") } query B { echo(value: "b") }`: "operationName is required",
+		`{ items { self { self { self { self { self { self { self { self { self { self { name } } } } } } } } } } } }`: "nested deeper than 10",
+	} {
+		response := schema.Execute(context.Background(), core.GraphQLRequest{Query: query})
+		assert.Nil(t, response.Data, "%s runs", query)
+		require.NotEmpty(t, response.Errors, query)
+		assert.Contains(t, response.Errors[0].Message, message, query)
+	}
+
+	response := schema.Execute(context.Background(), core.GraphQLRequest{
+		Query:         `query A { echo(value: "a") } query B { echo(value: "b") }`,
+		OperationName: "B",
+	})
+	assert.JSONEq(t, `{"echo": "b"}`, string(response.Data))
+}
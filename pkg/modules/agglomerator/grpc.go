@@ -0,0 +1,190 @@
+package agglomerator
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/theaxiomverse/hydap-api/pkg/keymanagement/pb"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// grpcEnvelopeSize allows for the non-data fields of a transaction,
+// including its state vector, on top of the inline payload limit
+const grpcEnvelopeSize = 1 << 20
+
+// GRPCServer serves the Agglomerator gRPC service defined in hydap.proto.
+// Chains and transactions take the same path as through the HTTP API, but
+// transactions are not relayed: the node must be both a gateway and a
+// router.
+type GRPCServer struct {
+	pb.UnimplementedAgglomeratorServer
+	module *AgglomeratorModule
+}
+
+// NewGRPCServer returns a gRPC server with the Agglomerator service
+// registered. A panicking call answers Internal and puts the module in
+// StateError through registry. With tokens set, every call needs an API
+// token scoped to the module, sent as "authorization: Bearer <token>"
+// metadata.
+func NewGRPCServer(module *AgglomeratorModule, registry *core.ModuleRegistry, tokens *core.TokenStore) *grpc.Server {
+	interceptors := []grpc.UnaryServerInterceptor{recoverCalls(registry, module.Name())}
+	if tokens != nil {
+		interceptors = append(interceptors, authenticateCalls(tokens, module.Name()))
+	}
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(interceptors...),
+		grpc.MaxRecvMsgSize(int(module.GetPayloadLimits().InlineSize)+grpcEnvelopeSize),
+	)
+	pb.RegisterAgglomeratorServer(server, &GRPCServer{module: module})
+	return server
+}
+
+// recoverCalls answers a panicking call with Internal, recording the panic
+// against the module as Recover does for its HTTP routes
+func recoverCalls(registry *core.ModuleRegistry, name string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if value := recover(); value != nil {
+				registry.RecordPanic(name, info.FullMethod, value)
+				err = status.Errorf(codes.Internal, "module %s panicked", name)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// authenticateCalls refuses calls without a valid token with Unauthenticated
+// and those whose token is not scoped to the module with PermissionDenied
+func authenticateCalls(tokens *core.TokenStore, module string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var secret string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			for _, value := range md.Get("authorization") {
+				if token, found := strings.CutPrefix(value, "Bearer "); found {
+					secret = token
+				}
+			}
+		}
+		if secret == "" {
+			return nil, status.Error(codes.Unauthenticated, "missing API token")
+		}
+
+		token, err := tokens.Authenticate(secret)
+		if err != nil {
+			if errors.Is(err, core.ErrInvalidToken) {
+				return nil, status.Error(codes.Unauthenticated, err.Error())
+			}
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		if !token.Allows(module) {
+			return nil, status.Error(codes.PermissionDenied, "token is not scoped to module "+module)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// RegisterChain registers a chain and returns it as registered
+func (s *GRPCServer) RegisterChain(ctx context.Context, req *pb.Chain) (*pb.Chain, error) {
+	agg := s.module.GetAgglomerator()
+	if agg == nil {
+		return nil, status.Error(codes.Unavailable, errAgglomeratorNotInitialized.Error())
+	}
+
+	chain := &Chain{
+		ID:        req.GetId(),
+		Endpoint:  req.GetEndpoint(),
+		Endpoints: req.GetEndpoints(),
+		Protocol:  req.GetProtocol(),
+		Zone:      req.GetZone(),
+	}
+	if err := s.module.RegisterChain(chain); err != nil {
+		return nil, grpcError(err)
+	}
+	return chainProto(chain), nil
+}
+
+// ListChains returns the registered chains ordered by ID
+func (s *GRPCServer) ListChains(ctx context.Context, req *pb.ListChainsRequest) (*pb.ListChainsResponse, error) {
+	agg := s.module.GetAgglomerator()
+	if agg == nil {
+		return nil, status.Error(codes.Unavailable, errAgglomeratorNotInitialized.Error())
+	}
+
+	chains := agg.ListChains()
+	sort.Slice(chains, func(i, j int) bool { return chains[i].ID < chains[j].ID })
+	response := &pb.ListChainsResponse{Chains: make([]*pb.Chain, 0, len(chains))}
+	for _, chain := range chains {
+		response.Chains = append(response.Chains, chainProto(chain))
+	}
+	return response, nil
+}
+
+// SubmitTransaction routes a transaction. A transaction held for anomaly
+// review is answered with status "held" rather than an error.
+func (s *GRPCServer) SubmitTransaction(ctx context.Context, req *pb.Transaction) (*pb.TransactionReceipt, error) {
+	roles := s.module.GetRoles()
+	for _, role := range []NodeRole{RoleGateway, RoleRouter} {
+		if !roles.Has(role) {
+			return nil, status.Errorf(codes.FailedPrecondition, "%v: %s", ErrRoleNotServed, role)
+		}
+	}
+	if limits := s.module.GetPayloadLimits(); int64(len(req.GetData())) > limits.InlineSize {
+		return nil, status.Errorf(codes.InvalidArgument, "inline payload exceeds %d bytes; upload larger data as a blob", limits.InlineSize)
+	}
+	if dims := req.GetDimensions(); dims < 0 || dims > maxCompareDims {
+		return nil, status.Errorf(codes.InvalidArgument, "dimensions must be between 0 and %d", maxCompareDims)
+	}
+
+	tx := TransactionFromProto(req)
+	if err := s.module.ProcessTransaction(tx); err != nil {
+		if errors.Is(err, ErrTransactionHeld) {
+			return &pb.TransactionReceipt{Id: tx.ID, Status: TxStatusHeld}, nil
+		}
+		return nil, grpcError(err)
+	}
+
+	receipt := &pb.TransactionReceipt{Id: tx.ID, Status: "accepted"}
+	if tx.Route != nil {
+		receipt.Route = tx.Route.Route
+	}
+	return receipt, nil
+}
+
+// chainProto converts a registered chain to its wire form
+func chainProto(chain *Chain) *pb.Chain {
+	return ChainState{
+		ID:        chain.ID,
+		Protocol:  chain.Protocol,
+		Endpoint:  chain.Endpoint,
+		Endpoints: chain.Endpoints,
+		Zone:      chain.Zone,
+	}.Proto()
+}
+
+// grpcError maps the errors the HTTP API answers with 4xx and 503 to their
+// gRPC codes; others are Internal
+func grpcError(err error) error {
+	code := codes.Internal
+	switch {
+	case errors.Is(err, ErrInvalidCapabilities), errors.Is(err, ErrUnknownZone),
+		errors.Is(err, ErrPayloadTooLarge), errors.Is(err, ErrBlobNotFound),
+		errors.Is(err, ErrUnknownAsset), errors.Is(err, ErrInvalidAmount), errors.Is(err, ErrInvalidFeeBudget):
+		code = codes.InvalidArgument
+	case errors.Is(err, ErrPolicyDenied), errors.Is(err, ErrReadOnlyReplica), errors.Is(err, ErrZoneMismatch):
+		code = codes.PermissionDenied
+	case errors.Is(err, ErrRoleNotServed), errors.Is(err, ErrChainIncapable), errors.Is(err, ErrFeeBudgetExceeded),
+		errors.Is(err, ErrSimulationFailed), errors.Is(err, ErrSponsorBudgetExceeded):
+		code = codes.FailedPrecondition
+	case errors.Is(err, ErrPoolFull):
+		code = codes.ResourceExhausted
+	case errors.Is(err, ErrNoPrice), errors.Is(err, ErrOverloaded), errors.Is(err, ErrConsistencyNotMet):
+		code = codes.Unavailable
+	}
+	return status.Error(code, err.Error())
+}
@@ -0,0 +1,106 @@
+package agglomerator
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/keymanagement/pb"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dialGRPC serves server on an in-memory listener and returns a client of it
+func dialGRPC(t *testing.T, server *grpc.Server) pb.AgglomeratorClient {
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewAgglomeratorClient(conn)
+}
+
+func TestGRPCServer(t *testing.T) {
+	module := newRerouteModule(t)
+	client := dialGRPC(t, NewGRPCServer(module, core.NewModuleRegistry(nil), nil))
+	ctx := context.Background()
+
+	chain, err := client.RegisterChain(ctx, &pb.Chain{Id: "eth", Protocol: ProtocolMock, Endpoint: "mock://eth?blockTime=1ms"})
+	require.NoError(t, err)
+	assert.Equal(t, "eth", chain.GetId())
+
+	chains, err := client.ListChains(ctx, &pb.ListChainsRequest{})
+	require.NoError(t, err)
+	var ids []string
+	for _, chain := range chains.GetChains() {
+		ids = append(ids, chain.GetId())
+	}
+	assert.Equal(t, []string{"down", "eth", "source", "up-a", "up-b"}, ids)
+
+	receipt, err := client.SubmitTransaction(ctx, &pb.Transaction{Id: "tx-1", FromChain: "source", ToChain: "up-a", Priority: 3})
+	require.NoError(t, err)
+	assert.Equal(t, "tx-1", receipt.GetId())
+	assert.Equal(t, "accepted", receipt.GetStatus())
+	assert.Equal(t, []string{"up-a"}, receipt.GetRoute())
+
+	_, err = client.SubmitTransaction(ctx, &pb.Transaction{Id: "tx-2", FromChain: "source", ToChain: "up-a", Dimensions: maxCompareDims + 1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	module.mu.Lock()
+	module.roles = NodeRoles{RoleGateway}
+	module.mu.Unlock()
+	_, err = client.SubmitTransaction(ctx, &pb.Transaction{Id: "tx-3", FromChain: "source", ToChain: "up-a"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err), "gRPC submissions are not relayed to routers")
+}
+
+func TestGRPCServerRequiresScopedTokens(t *testing.T) {
+	tokens, err := core.NewTokenStore(filepath.Join(t.TempDir(), "tokens.db"))
+	require.NoError(t, err)
+	defer tokens.Close()
+	_, scoped, err := tokens.Issue("agglomerator", []string{"blockchain_agglomerator"})
+	require.NoError(t, err)
+	_, other, err := tokens.Issue("compression", []string{"compression"})
+	require.NoError(t, err)
+
+	module := newRerouteModule(t)
+	client := dialGRPC(t, NewGRPCServer(module, core.NewModuleRegistry(nil), tokens))
+	list := func(secret string) error {
+		ctx := context.Background()
+		if secret != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+secret)
+		}
+		_, err := client.ListChains(ctx, &pb.ListChainsRequest{})
+		return err
+	}
+
+	assert.Equal(t, codes.Unauthenticated, status.Code(list("")))
+	assert.Equal(t, codes.Unauthenticated, status.Code(list("hydap_unknown")))
+	assert.Equal(t, codes.PermissionDenied, status.Code(list(other)))
+	assert.NoError(t, list(scoped))
+}
+
+func TestGRPCServerRecoversPanics(t *testing.T) {
+	registry := core.NewModuleRegistry(nil)
+	interceptor := recoverCalls(registry, "blockchain_agglomerator")
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: pb.Agglomerator_ListChains_FullMethodName},
+		func(ctx context.Context, req interface{}) (interface{}, error) { panic("boom") })
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	panics := registry.Panics()
+	require.Len(t, panics, 1)
+	assert.Equal(t, pb.Agglomerator_ListChains_FullMethodName, panics[0].Operation)
+	assert.Equal(t, "boom", panics[0].Value)
+}
@@ -0,0 +1,204 @@
+package agglomerator
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+// handoffVersion is bumped when AgglomeratorState changes incompatibly
+const handoffVersion = 1
+
+// AgglomeratorState is the state handed from a running module to the
+// instance replacing it during a hot reload
+type AgglomeratorState struct {
+	Version      int                  `json:"version"`
+	Chains       []ChainState         `json:"chains"`
+	Transactions []PendingTransaction `json:"transactions"`
+}
+
+// PendingTransaction is a transaction held in chain pools, with its vector
+// materialized to the compared dimensions
+type PendingTransaction struct {
+	ID        string    `json:"id"`
+	FromChain string    `json:"fromChain"`
+	ToChain   string    `json:"toChain"`
+	BlobRef   string    `json:"blobRef,omitempty"`
+	Priority  int       `json:"priority,omitempty"`
+	Fee       float64   `json:"fee,omitempty"`
+	AddedAt   time.Time `json:"addedAt"`
+	Vector    []float64 `json:"vector"`
+}
+
+// ExportState captures the registered chains and the transactions still held
+// in their pools
+func (a *Agglomerator) ExportState() AgglomeratorState {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	state := AgglomeratorState{Version: handoffVersion}
+	ids := make([]string, 0, len(a.chains))
+	for id := range a.chains {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		chain := a.chains[id]
+		state.Chains = append(state.Chains, ChainState{
+			ID:        chain.ID,
+			Protocol:  chain.Protocol,
+			Endpoint:  chain.Endpoint,
+			Endpoints: chain.Endpoints,
+			Zone:      chain.Zone,
+			Vector:    chain.vectorSeed,
+		})
+
+		added := make(map[string]time.Time)
+		for _, entry := range chain.PoolEntries() {
+			added[entry.TxID] = entry.AddedAt
+		}
+		for _, record := range chain.TransactionPool.Records() {
+			if seen[record.ID] {
+				continue
+			}
+			seen[record.ID] = true
+			state.Transactions = append(state.Transactions, a.pendingTransaction(record, added[record.ID]))
+		}
+	}
+	return state
+}
+
+func (a *Agglomerator) pendingTransaction(record vectors.DatabaseRecord, addedAt time.Time) PendingTransaction {
+	pending := PendingTransaction{ID: record.ID, AddedAt: addedAt}
+	pending.FromChain, _ = record.Metadata["fromChain"].(string)
+	pending.ToChain, _ = record.Metadata["toChain"].(string)
+	pending.BlobRef, _ = record.Metadata["blobRef"].(string)
+	pending.Priority, _ = record.Metadata["priority"].(int)
+	pending.Fee, _ = record.Metadata["fee"].(float64)
+
+	pending.Vector = vectorElements(&record.Vector, a.compareDims)
+	return pending
+}
+
+// ImportState registers the chains of a previous instance that are not
+// already registered and returns its pending transactions to their pools.
+// Nothing is logged, as the previous instance logged the changes. It returns
+// the number of chains and transactions imported.
+func (a *Agglomerator) ImportState(state AgglomeratorState) (int, int, error) {
+	if state.Version != handoffVersion {
+		return 0, 0, fmt.Errorf("unsupported state version %d", state.Version)
+	}
+
+	chains := 0
+	for _, registered := range state.Chains {
+		if _, err := a.GetChain(registered.ID); err == nil {
+			continue
+		}
+		chain := &Chain{
+			ID:        registered.ID,
+			Endpoint:  registered.Endpoint,
+			Endpoints: registered.Endpoints,
+			Protocol:  registered.Protocol,
+			Zone:      registered.Zone,
+			StateVector: vectors.InfiniteVector{
+				Generator: getProtocolGenerator(registered.Protocol),
+			},
+		}
+		if err := a.registerChain(chain); err != nil {
+			return chains, 0, fmt.Errorf("failed to import chain %s: %w", registered.ID, err)
+		}
+		if registered.Vector != nil {
+			if err := a.seedChainVector(registered.ID, registered.Vector); err != nil {
+				return chains, 0, fmt.Errorf("failed to import chain %s: %w", registered.ID, err)
+			}
+		}
+		chains++
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	transactions := 0
+	for _, pending := range state.Transactions {
+		fromChain, fromExists := a.chains[pending.FromChain]
+		toChain, toExists := a.chains[pending.ToChain]
+		if !fromExists || !toExists {
+			continue
+		}
+
+		record := vectors.DatabaseRecord{
+			ID: pending.ID,
+			Metadata: map[string]interface{}{
+				"fromChain": pending.FromChain,
+				"toChain":   pending.ToChain,
+			},
+			Vector: elementVector(pending.Vector),
+		}
+		if pending.BlobRef != "" {
+			record.Metadata["blobRef"] = pending.BlobRef
+		}
+		if pending.Priority != 0 || pending.Fee != 0 {
+			record.Metadata["priority"] = pending.Priority
+			record.Metadata["fee"] = pending.Fee
+		}
+
+		// Limits may have shrunk in the new configuration; transactions that
+		// no longer fit are dropped
+		entry := PoolEntry{TxID: pending.ID, Priority: pending.Priority, Fee: pending.Fee, AddedAt: pending.AddedAt}
+		if err := fromChain.admitToPool(entry); err != nil {
+			continue
+		}
+		if err := toChain.admitToPool(entry); err != nil {
+			if toChain != fromChain {
+				fromChain.admission.Remove(pending.ID)
+			}
+			continue
+		}
+
+		if err := a.vectorIndex.Insert(record); err != nil {
+			return chains, transactions, err
+		}
+		fromChain.TransactionPool.Insert(record)
+		toChain.TransactionPool.Insert(record)
+		a.clusters.Add(pending.ID, &record.Vector)
+		transactions++
+	}
+	return chains, transactions, nil
+}
+
+// ExportState serializes the agglomerator's chains and pending transactions
+// for the instance replacing this one
+func (m *AgglomeratorModule) ExportState() ([]byte, error) {
+	agg := m.GetAgglomerator()
+	if agg == nil {
+		return nil, fmt.Errorf("agglomerator not initialized")
+	}
+	return json.Marshal(agg.ExportState())
+}
+
+// ImportState restores the state exported by the instance this module
+// replaces. It is called after Initialize, so configured chains keep their
+// new configuration.
+func (m *AgglomeratorModule) ImportState(data []byte) error {
+	agg := m.GetAgglomerator()
+	if agg == nil {
+		return fmt.Errorf("agglomerator not initialized")
+	}
+
+	var state AgglomeratorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("invalid state: %w", err)
+	}
+	chains, transactions, err := agg.ImportState(state)
+	if err != nil {
+		return err
+	}
+
+	m.logger.Log(m.Name(), "INFO", fmt.Sprintf("Imported %d chains and %d pending transactions from the previous instance", chains, transactions))
+	return nil
+}
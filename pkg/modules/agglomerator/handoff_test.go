@@ -0,0 +1,61 @@
+package agglomerator
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+func TestAgglomeratorStateHandoff(t *testing.T) {
+	old := NewAgglomerator(AgglomeratorConfig{})
+	for _, id := range []string{"eth", "sol"} {
+		chain := NewChain(id, "http://"+id+":8545", ProtocolEthereum)
+		chain.StateVector = vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}
+		require.NoError(t, old.RegisterChain(chain))
+	}
+	tx := &Transaction{ID: "tx-1", FromChain: "sol", ToChain: "eth", Priority: 2, Fee: 0.5, BlobRef: "abc",
+		StateVector: vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}}
+	_, err := old.recordTransaction(tx)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(old.ExportState())
+	require.NoError(t, err)
+	var state AgglomeratorState
+	require.NoError(t, json.Unmarshal(data, &state))
+	require.Len(t, state.Chains, 2)
+	require.Len(t, state.Transactions, 1, "transactions in both pools are exported once")
+
+	upgraded := NewAgglomerator(AgglomeratorConfig{})
+	configured := NewChain("eth", "http://eth-new:8545", ProtocolEthereum)
+	configured.StateVector = vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}
+	require.NoError(t, upgraded.RegisterChain(configured))
+
+	chains, transactions, err := upgraded.ImportState(state)
+	require.NoError(t, err)
+	assert.Equal(t, 1, chains)
+	assert.Equal(t, 1, transactions)
+
+	eth, err := upgraded.GetChain("eth")
+	require.NoError(t, err)
+	assert.Equal(t, "http://eth-new:8545", eth.Endpoint, "configured chains keep the new configuration")
+	sol, err := upgraded.GetChain("sol")
+	require.NoError(t, err)
+	assert.Equal(t, "http://sol:8545", sol.Endpoint)
+
+	for _, chain := range []*Chain{eth, sol} {
+		record, pooled := chain.TransactionPool.Get("tx-1")
+		require.True(t, pooled)
+		assert.Equal(t, "abc", record.Metadata["blobRef"])
+		assert.Equal(t, tx.StateVector.GetElement(3), record.Vector.GetElement(3))
+		entries := chain.PoolEntries()
+		require.Len(t, entries, 1)
+		assert.Equal(t, 2, entries[0].Priority)
+	}
+
+	state.Version++
+	_, _, err = upgraded.ImportState(state)
+	assert.Error(t, err)
+}
@@ -0,0 +1,228 @@
+package agglomerator
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/theaxiomverse/hydap-api/pkg/keymanagement/pb"
+)
+
+var ErrNoCommonAlgorithm = errors.New("no signature algorithm in common with peer")
+
+// SignatureAlgorithms are the signature algorithms peers can negotiate,
+// strongest first. Names match the keymanagement algorithm enum.
+var SignatureAlgorithms = []string{"DILITHIUM5", "FALCON1024", "DILITHIUM3", "DILITHIUM2", "FALCON512"}
+
+// Handshake is the first message on a peer channel. The dialing node offers
+// its algorithms; the accepting node answers with its own and the one chosen.
+type Handshake struct {
+	NodeID     string
+	Algorithms []string
+	Algorithm  string // Empty when nothing is in common
+
+	// Attestation proves the node's keys were issued by a deployment CA
+	Attestation *Attestation
+	// Error explains a refused handshake
+	Error string
+
+	// Version is the highest wire version offered, or the one chosen in a
+	// reply; MinVersion is the oldest the node speaks. Both are 0 from nodes
+	// before versioning.
+	Version    uint32
+	MinVersion uint32
+
+	// AdmissionNonce is the node's proof of work for its ID and signing key
+	AdmissionNonce uint64
+}
+
+// NegotiateAlgorithm returns the strongest signature algorithm both sides
+// support, so both ends pick the same one whatever order they list them in
+func NegotiateAlgorithm(local, remote []string) (string, error) {
+	offered := make(map[string]bool, len(remote))
+	for _, name := range remote {
+		offered[name] = true
+	}
+	for _, name := range SignatureAlgorithms {
+		if offered[name] && contains(local, name) {
+			return name, nil
+		}
+	}
+	return "", ErrNoCommonAlgorithm
+}
+
+// validateAlgorithms checks that every name is a known signature algorithm
+func validateAlgorithms(names []string) error {
+	if len(names) == 0 {
+		return fmt.Errorf("at least one signature algorithm is required")
+	}
+	for _, name := range names {
+		if !contains(SignatureAlgorithms, name) {
+			return fmt.Errorf("unknown signature algorithm %q (have %v)", name, SignatureAlgorithms)
+		}
+	}
+	return nil
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SetSignatureAlgorithms limits the algorithms offered in handshakes. It
+// must be called before UseTransport.
+func (node *P2PInfiniteVectorNode) SetSignatureAlgorithms(names []string) error {
+	if err := validateAlgorithms(names); err != nil {
+		return err
+	}
+	node.connMu.Lock()
+	defer node.connMu.Unlock()
+	node.algorithms = append([]string(nil), names...)
+	return nil
+}
+
+// PeerAlgorithm returns the signature algorithm negotiated with a peer
+func (node *P2PInfiniteVectorNode) PeerAlgorithm(peerID string) (string, bool) {
+	node.connMu.Lock()
+	defer node.connMu.Unlock()
+	algorithm, exists := node.peerAlgorithms[peerID]
+	return algorithm, exists
+}
+
+// localAlgorithms returns the algorithms offered in handshakes. A node with
+// keys signs with one algorithm, so it offers only that one.
+func (node *P2PInfiniteVectorNode) localAlgorithms() []string {
+	node.connMu.Lock()
+	defer node.connMu.Unlock()
+	if node.keys != nil {
+		return []string{node.keys.SigningAlgorithm()}
+	}
+	if len(node.algorithms) == 0 {
+		return SignatureAlgorithms
+	}
+	return node.algorithms
+}
+
+func (node *P2PInfiniteVectorNode) setPeerAlgorithm(peerID, algorithm string) {
+	node.connMu.Lock()
+	defer node.connMu.Unlock()
+	node.peerAlgorithms[peerID] = algorithm
+}
+
+// dialHandshake offers this node's algorithms on a new outbound channel and
+// records the one the peer chose, running the key exchange when the agreed
+// version calls for it. It returns the channel to send envelopes on. A peer
+// whose attestation does not verify is refused; the peer set is left as it
+// is, since nothing in the reply is authenticated yet.
+func (node *P2PInfiniteVectorNode) dialHandshake(conn Conn, peerID string) (Conn, error) {
+	// The channel has no deadlines; closing it unblocks a silent peer
+	timer := time.AfterFunc(dialTimeout, func() { conn.Close() })
+	defer timer.Stop()
+
+	local := node.localAlgorithms()
+	minVersion, maxVersion := node.wireVersions()
+	hello := Handshake{
+		NodeID:      node.NodeID,
+		Algorithms:  local,
+		Attestation: node.Attestation(),
+		Version:     maxVersion,
+		MinVersion:  minVersion,
+
+		AdmissionNonce: node.localAdmissionNonce(),
+	}
+	if err := writeFrame(conn, hello.proto()); err != nil {
+		return nil, fmt.Errorf("handshake with %s: %w", peerID, err)
+	}
+	reader := bufio.NewReader(conn)
+	var frame pb.Handshake
+	if err := readFrame(reader, &frame, frameOverhead); err != nil {
+		return nil, fmt.Errorf("handshake with %s: %w", peerID, err)
+	}
+	reply := handshakeFromProto(&frame)
+	if reply.Error != "" {
+		return nil, fmt.Errorf("handshake with %s: refused: %s", peerID, reply.Error)
+	}
+	if err := node.verifyPeer(peerID, reply.Attestation); err != nil {
+		return nil, fmt.Errorf("handshake with %s: %w", peerID, err)
+	}
+	if reply.Algorithm == "" {
+		return nil, fmt.Errorf("handshake with %s: %w", peerID, ErrNoCommonAlgorithm)
+	}
+	if !contains(local, reply.Algorithm) {
+		return nil, fmt.Errorf("handshake with %s: peer chose unoffered algorithm %q", peerID, reply.Algorithm)
+	}
+	version := wireVersion(reply.Version)
+	if version < minVersion || version > maxVersion {
+		return nil, fmt.Errorf("handshake with %s: %w: peer chose %d", peerID, ErrIncompatibleVersion, version)
+	}
+
+	// Peers with keys are admitted in the key exchange, once their key is
+	// verified
+	if version < secureWireVersion {
+		if err := node.admitPeer(peerID, nil, reply.AdmissionNonce); err != nil {
+			return nil, fmt.Errorf("handshake with %s: %w", peerID, err)
+		}
+	} else {
+		secure, err := node.dialKeyExchange(conn, reader, peerID, reply)
+		if err != nil {
+			return nil, fmt.Errorf("handshake with %s: %w", peerID, err)
+		}
+		conn = secure
+	}
+
+	node.setPeerAlgorithm(peerID, reply.Algorithm)
+	node.setPeerVersion(peerID, version)
+	return conn, nil
+}
+
+// acceptHandshake answers the handshake read from an inbound channel,
+// refusing peers whose attestation does not verify or who are not admitted.
+// Anyone can send a hello in another node's name, so a refusal only closes
+// the channel and never touches the peer already known by that ID. It
+// returns the reply sent; the caller records the agreed algorithm and
+// version once any key exchange has authenticated the peer.
+func (node *P2PInfiniteVectorNode) acceptHandshake(conn io.Writer, hello Handshake) (Handshake, error) {
+	if err := node.verifyPeer(hello.NodeID, hello.Attestation); err != nil {
+		writeFrame(conn, Handshake{NodeID: node.NodeID, Error: err.Error()}.proto())
+		return Handshake{}, err
+	}
+
+	minVersion, maxVersion := node.wireVersions()
+	version, err := NegotiateVersion(minVersion, maxVersion, wireVersion(hello.MinVersion), wireVersion(hello.Version))
+	if err != nil {
+		writeFrame(conn, Handshake{NodeID: node.NodeID, Error: err.Error()}.proto())
+		return Handshake{}, err
+	}
+	// Peers with keys are admitted in the key exchange, once their key is
+	// verified
+	if version < secureWireVersion {
+		if err := node.admitPeer(hello.NodeID, nil, hello.AdmissionNonce); err != nil {
+			writeFrame(conn, Handshake{NodeID: node.NodeID, Error: err.Error()}.proto())
+			return Handshake{}, err
+		}
+	}
+
+	local := node.localAlgorithms()
+	algorithm, err := NegotiateAlgorithm(local, hello.Algorithms)
+
+	reply := Handshake{
+		NodeID:      node.NodeID,
+		Algorithms:  local,
+		Algorithm:   algorithm,
+		Attestation: node.Attestation(),
+		Version:     version,
+		MinVersion:  minVersion,
+
+		AdmissionNonce: node.localAdmissionNonce(),
+	}
+	if writeErr := writeFrame(conn, reply.proto()); writeErr != nil {
+		return Handshake{}, writeErr
+	}
+	return reply, err
+}
@@ -0,0 +1,58 @@
+package agglomerator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateAlgorithm(t *testing.T) {
+	algorithm, err := NegotiateAlgorithm([]string{"FALCON512", "FALCON1024"}, []string{"DILITHIUM2", "FALCON1024", "FALCON512"})
+	require.NoError(t, err)
+	assert.Equal(t, "FALCON1024", algorithm, "the strongest common algorithm wins")
+
+	reversed, err := NegotiateAlgorithm([]string{"DILITHIUM2", "FALCON1024", "FALCON512"}, []string{"FALCON512", "FALCON1024"})
+	require.NoError(t, err)
+	assert.Equal(t, algorithm, reversed, "both sides choose the same algorithm")
+
+	_, err = NegotiateAlgorithm([]string{"DILITHIUM5"}, []string{"FALCON512"})
+	assert.ErrorIs(t, err, ErrNoCommonAlgorithm)
+
+	assert.Error(t, validateAlgorithms([]string{"KYBER512"}), "KEMs do not sign")
+	assert.Error(t, validateAlgorithms(nil))
+}
+
+func TestHandshakeNegotiatesOverTransport(t *testing.T) {
+	newNode := func(algorithms ...string) *P2PInfiniteVectorNode {
+		node := NewP2PInfiniteVectorNode("127.0.0.1", 0)
+		require.NoError(t, node.SetSignatureAlgorithms(algorithms))
+		transport, err := NewTransport(TransportConfig{Type: "tcp"})
+		require.NoError(t, err)
+		require.NoError(t, node.UseTransport(transport))
+		t.Cleanup(func() { node.CloseTransport() })
+		return node
+	}
+	dial := func(from, to *P2PInfiniteVectorNode) error {
+		from.connectToPeer(&PeerInfo{NodeID: to.NodeID, Address: to.listener.Addr()})
+		_, err := from.peerConn(from.transport, to.NodeID)
+		return err
+	}
+
+	a := newNode("FALCON512", "FALCON1024")
+	b := newNode("FALCON1024", "DILITHIUM2")
+	require.NoError(t, dial(a, b))
+	algorithm, ok := a.PeerAlgorithm(b.NodeID)
+	require.True(t, ok)
+	assert.Equal(t, "FALCON1024", algorithm)
+	assert.Eventually(t, func() bool {
+		algorithm, ok := b.PeerAlgorithm(a.NodeID)
+		return ok && algorithm == "FALCON1024"
+	}, time.Second, 10*time.Millisecond)
+
+	c := newNode("DILITHIUM5")
+	assert.ErrorIs(t, dial(a, c), ErrNoCommonAlgorithm)
+	_, ok = a.PeerAlgorithm(c.NodeID)
+	assert.False(t, ok)
+}
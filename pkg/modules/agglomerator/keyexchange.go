@@ -0,0 +1,474 @@
+package agglomerator
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/theaxiomverse/hydap-api/pkg/keymanagement/pb"
+	"google.golang.org/protobuf/encoding/protodelim"
+)
+
+// Channels at secureWireVersion run a key exchange right after the
+// handshake, before any envelope is sent. It follows the Noise KK idea with a
+// KEM in place of Diffie-Hellman:
+//
+//  1. The dialing node sends its signing key, a fresh KEM public key and a
+//     nonce.
+//  2. The accepting node encapsulates a secret to that KEM key and signs the
+//     transcript of both messages, sending its signing key, the ciphertext,
+//     its own nonce and the signature.
+//  3. The dialing node decapsulates the secret, checks the signature and
+//     signs the transcript in turn.
+//
+// Each direction is then sealed with AES-256-GCM under a key derived from
+// the secret and the transcript. The KEM key lives for one channel, so a
+// stolen signing key does not expose past traffic.
+
+// secureWireVersion is the first wire version whose channels are encrypted
+const secureWireVersion uint32 = 4
+
+// maxRecordSize bounds the plaintext sealed in one record
+const maxRecordSize = 64 << 10
+
+// maxKeyExchangeSize bounds a key exchange frame; Kyber1024 and Dilithium5
+// keys, ciphertexts and signatures are each a few kilobytes
+const maxKeyExchangeSize = 64 << 10
+
+var (
+	ErrKeyExchange    = errors.New("key exchange failed")
+	ErrPeerKeyChanged = errors.New("peer signing key changed")
+)
+
+// NodeKeys are a node's long-term signing key and the KEM it uses to open
+// peer channels. Algorithm names match the keymanagement algorithm enum, as
+// in attestations. The implementation backed by liboqs lives in
+// pkg/keymanagement and is installed with RegisterNodeKeysLoader.
+type NodeKeys interface {
+	SigningAlgorithm() string
+	SigningKey() []byte
+	Sign(message []byte) ([]byte, error)
+	// Verify checks a peer's signature, which may use another algorithm
+	Verify(algorithm string, key, message, signature []byte) error
+
+	KEMAlgorithm() string
+	// GenerateKEM returns a fresh KEM key pair for one channel
+	GenerateKEM() (public, secret []byte, err error)
+	Encapsulate(algorithm string, public []byte) (ciphertext, shared []byte, err error)
+	Decapsulate(algorithm string, secret, ciphertext []byte) ([]byte, error)
+}
+
+// NodeKeysLoader reads a node's keys from the file at p2p.keyFile
+type NodeKeysLoader func(path string) (NodeKeys, error)
+
+var (
+	nodeKeysLoaderMu sync.RWMutex
+	nodeKeysLoader   NodeKeysLoader
+)
+
+// RegisterNodeKeysLoader sets how p2p.keyFile is read. Binaries linking
+// liboqs register it at startup.
+func RegisterNodeKeysLoader(loader NodeKeysLoader) {
+	nodeKeysLoaderMu.Lock()
+	defer nodeKeysLoaderMu.Unlock()
+	nodeKeysLoader = loader
+}
+
+// LoadNodeKeys reads a node's keys with the registered loader
+func LoadNodeKeys(path string) (NodeKeys, error) {
+	nodeKeysLoaderMu.RLock()
+	loader := nodeKeysLoader
+	nodeKeysLoaderMu.RUnlock()
+	if loader == nil {
+		return nil, errors.New("node keys are not supported by this build")
+	}
+	return loader(path)
+}
+
+// UseKeys has every channel encrypted and authenticated with keys; peers
+// that cannot run the key exchange are refused. The node's admission proof
+// of work is solved again to cover the signing key. It must be called before
+// UseTransport.
+func (node *P2PInfiniteVectorNode) UseKeys(keys NodeKeys) {
+	node.connMu.Lock()
+	node.keys = keys
+	node.connMu.Unlock()
+	node.solveAdmission()
+}
+
+// PeerSigningKey returns the signing key a peer authenticated with
+func (node *P2PInfiniteVectorNode) PeerSigningKey(peerID string) ([]byte, bool) {
+	node.connMu.Lock()
+	defer node.connMu.Unlock()
+	key, exists := node.peerKeys[peerID]
+	return key, exists
+}
+
+func (node *P2PInfiniteVectorNode) nodeKeys() NodeKeys {
+	node.connMu.Lock()
+	defer node.connMu.Unlock()
+	return node.keys
+}
+
+// wireVersions returns the range of wire versions this node speaks. Nodes
+// with keys speak only encrypted versions; nodes without stop short of them.
+func (node *P2PInfiniteVectorNode) wireVersions() (uint32, uint32) {
+	if node.nodeKeys() != nil {
+		return secureWireVersion, WireVersion
+	}
+	return MinWireVersion, secureWireVersion - 1
+}
+
+func writeKeyExchange(w io.Writer, m *pb.KeyExchange) error {
+	return writeFrame(w, m)
+}
+
+// readKeyExchange reads one key exchange message, turning a refusal into an
+// error
+func readKeyExchange(r *bufio.Reader) (*pb.KeyExchange, error) {
+	m := &pb.KeyExchange{}
+	if err := readFrame(r, m, maxKeyExchangeSize); err != nil {
+		var tooLarge *protodelim.SizeTooLargeError
+		if errors.As(err, &tooLarge) {
+			return nil, fmt.Errorf("%w: %d byte message", ErrKeyExchange, tooLarge.Size)
+		}
+		return nil, err
+	}
+	if m.Error != "" {
+		return m, fmt.Errorf("%w: refused: %s", ErrKeyExchange, m.Error)
+	}
+	return m, nil
+}
+
+// transcript hashes everything both sides sent, so each signature covers
+// the whole exchange and a relayed or altered message fails it
+func transcript(dialerID, acceptorID string, hello, reply *pb.KeyExchange) []byte {
+	h := sha256.New()
+	for _, field := range [][]byte{
+		[]byte("hydap key exchange v1"),
+		[]byte(dialerID), []byte(hello.GetSigningAlgorithm()), hello.GetSigningKey(),
+		[]byte(hello.GetKemAlgorithm()), hello.GetKemKey(), hello.GetNonce(),
+		[]byte(acceptorID), []byte(reply.GetSigningAlgorithm()), reply.GetSigningKey(),
+		reply.GetCiphertext(), reply.GetNonce(),
+	} {
+		var size [4]byte
+		binary.BigEndian.PutUint32(size[:], uint32(len(field)))
+		h.Write(size[:])
+		h.Write(field)
+	}
+	return h.Sum(nil)
+}
+
+// Signatures are labelled with the signer's role, so the dialer's cannot be
+// replayed as the acceptor's
+func signedTranscript(role string, transcript []byte) []byte {
+	return append([]byte("hydap "+role+" "), transcript...)
+}
+
+func newNonce() ([]byte, error) {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return nonce, nil
+}
+
+// checkPeerKey checks the signing key a peer presented before its
+// signature is verified. The peer must sign with the algorithm the handshake
+// agreed on. A peer with an attestation must use the key it attests to;
+// otherwise a key other than the one pinned for the peer is refused.
+func (node *P2PInfiniteVectorNode) checkPeerKey(peerID string, attestation *Attestation, negotiated, algorithm string, key []byte) error {
+	if len(key) == 0 {
+		return fmt.Errorf("%w: peer %s sent no signing key", ErrKeyExchange, peerID)
+	}
+	if algorithm != negotiated {
+		return fmt.Errorf("%w: peer %s signs with %s, not the negotiated %s", ErrKeyExchange, peerID, algorithm, negotiated)
+	}
+	if attestation != nil && node.trustAnchors.Required() {
+		attested, err := base64.StdEncoding.DecodeString(attestation.SigningKey)
+		if err != nil || attestation.SigningAlgorithm != algorithm || !bytes.Equal(attested, key) {
+			return fmt.Errorf("%w: peer %s signing key does not match its attestation", ErrKeyExchange, peerID)
+		}
+	}
+
+	node.connMu.Lock()
+	defer node.connMu.Unlock()
+	if pinned, exists := node.peerKeys[peerID]; exists && !bytes.Equal(pinned, key) {
+		return fmt.Errorf("%w: peer %s", ErrPeerKeyChanged, peerID)
+	}
+	return nil
+}
+
+// pinPeerKey pins the first signing key a peer authenticated with, once its
+// signature has been verified. Pinning before then would let anyone claiming
+// a peer's ID lock the real peer out.
+func (node *P2PInfiniteVectorNode) pinPeerKey(peerID string, key []byte) error {
+	node.connMu.Lock()
+	defer node.connMu.Unlock()
+	if pinned, exists := node.peerKeys[peerID]; exists {
+		if !bytes.Equal(pinned, key) {
+			return fmt.Errorf("%w: peer %s", ErrPeerKeyChanged, peerID)
+		}
+		return nil
+	}
+	node.peerKeys[peerID] = append([]byte(nil), key...)
+	return nil
+}
+
+// checkLocalAlgorithm checks that this node signs with the algorithm the
+// handshake agreed on
+func checkLocalAlgorithm(keys NodeKeys, negotiated string) error {
+	if algorithm := keys.SigningAlgorithm(); algorithm != negotiated {
+		return fmt.Errorf("%w: negotiated %s but this node signs with %s", ErrKeyExchange, negotiated, algorithm)
+	}
+	return nil
+}
+
+// dialKeyExchange runs the dialing side of the key exchange and returns the
+// channel sealed with the agreed keys. The peer is admitted once its signing
+// key is verified, so its proof of work must cover that key.
+func (node *P2PInfiniteVectorNode) dialKeyExchange(conn Conn, reader *bufio.Reader, peerID string, reply Handshake) (Conn, error) {
+	keys := node.nodeKeys()
+	if err := checkLocalAlgorithm(keys, reply.Algorithm); err != nil {
+		return nil, err
+	}
+	kemPublic, kemSecret, err := keys.GenerateKEM()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKeyExchange, err)
+	}
+	nonce, err := newNonce()
+	if err != nil {
+		return nil, err
+	}
+	hello := &pb.KeyExchange{
+		SigningAlgorithm: keys.SigningAlgorithm(),
+		SigningKey:       keys.SigningKey(),
+		KemAlgorithm:     keys.KEMAlgorithm(),
+		KemKey:           kemPublic,
+		Nonce:            nonce,
+	}
+	if err := writeKeyExchange(conn, hello); err != nil {
+		return nil, err
+	}
+
+	answer, err := readKeyExchange(reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := node.checkPeerKey(peerID, reply.Attestation, reply.Algorithm, answer.SigningAlgorithm, answer.SigningKey); err != nil {
+		return nil, err
+	}
+	shared, err := keys.Decapsulate(hello.KemAlgorithm, kemSecret, answer.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKeyExchange, err)
+	}
+	hash := transcript(node.NodeID, reply.NodeID, hello, answer)
+	if err := keys.Verify(answer.SigningAlgorithm, answer.SigningKey, signedTranscript("acceptor", hash), answer.Signature); err != nil {
+		return nil, fmt.Errorf("%w: peer %s signature: %v", ErrKeyExchange, peerID, err)
+	}
+	if err := node.admitPeer(peerID, answer.SigningKey, reply.AdmissionNonce); err != nil {
+		return nil, err
+	}
+	if err := node.pinPeerKey(peerID, answer.SigningKey); err != nil {
+		return nil, err
+	}
+
+	signature, err := keys.Sign(signedTranscript("dialer", hash))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKeyExchange, err)
+	}
+	if err := writeKeyExchange(conn, &pb.KeyExchange{Signature: signature}); err != nil {
+		return nil, err
+	}
+	return newSecureConn(conn, reader, shared, hash, true)
+}
+
+// acceptKeyExchange runs the accepting side of the key exchange on a channel
+// whose handshake was answered with algorithm, refusing peers that fail to
+// authenticate or are not admitted with their verified signing key
+func (node *P2PInfiniteVectorNode) acceptKeyExchange(conn Conn, reader *bufio.Reader, hello Handshake, algorithm string) (Conn, error) {
+	refuse := func(err error) (Conn, error) {
+		writeKeyExchange(conn, &pb.KeyExchange{Error: err.Error()})
+		return nil, err
+	}
+
+	keys := node.nodeKeys()
+	offer, err := readKeyExchange(reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkLocalAlgorithm(keys, algorithm); err != nil {
+		return refuse(err)
+	}
+	if err := node.checkPeerKey(hello.NodeID, hello.Attestation, algorithm, offer.SigningAlgorithm, offer.SigningKey); err != nil {
+		return refuse(err)
+	}
+	ciphertext, shared, err := keys.Encapsulate(offer.KemAlgorithm, offer.KemKey)
+	if err != nil {
+		return refuse(fmt.Errorf("%w: %v", ErrKeyExchange, err))
+	}
+	nonce, err := newNonce()
+	if err != nil {
+		return nil, err
+	}
+	answer := &pb.KeyExchange{
+		SigningAlgorithm: keys.SigningAlgorithm(),
+		SigningKey:       keys.SigningKey(),
+		Ciphertext:       ciphertext,
+		Nonce:            nonce,
+	}
+	hash := transcript(hello.NodeID, node.NodeID, offer, answer)
+	answer.Signature, err = keys.Sign(signedTranscript("acceptor", hash))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKeyExchange, err)
+	}
+	if err := writeKeyExchange(conn, answer); err != nil {
+		return nil, err
+	}
+
+	finish, err := readKeyExchange(reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := keys.Verify(offer.SigningAlgorithm, offer.SigningKey, signedTranscript("dialer", hash), finish.Signature); err != nil {
+		return nil, fmt.Errorf("%w: peer %s signature: %v", ErrKeyExchange, hello.NodeID, err)
+	}
+	if err := node.admitPeer(hello.NodeID, offer.SigningKey, hello.AdmissionNonce); err != nil {
+		return nil, err
+	}
+	if err := node.pinPeerKey(hello.NodeID, offer.SigningKey); err != nil {
+		return nil, err
+	}
+	return newSecureConn(conn, reader, shared, hash, false)
+}
+
+// deriveKey expands the shared secret into a key for one direction
+// (HKDF-SHA256, RFC 5869, with the transcript as salt)
+func deriveKey(shared, salt []byte, info string) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(shared)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write([]byte(info))
+	expand.Write([]byte{1})
+	return expand.Sum(nil)
+}
+
+// secureConn seals each write into an AES-GCM record: a 4-byte length, then
+// the ciphertext. Records are numbered per direction and the number is the
+// nonce, so dropped, reordered or replayed records fail to open.
+type secureConn struct {
+	Conn
+	reader io.Reader // Holds any bytes buffered past the key exchange
+
+	writeMu   sync.Mutex
+	seal      cipher.AEAD
+	sent      uint64
+	sealed    []byte // Reused for each outgoing record
+	sealNonce []byte
+
+	open      cipher.AEAD
+	received  uint64
+	record    []byte // Reused for each incoming record once pending is read
+	openNonce []byte
+	pending   []byte // Opened plaintext not yet read
+}
+
+func newSecureConn(conn Conn, reader io.Reader, shared, transcript []byte, dialer bool) (*secureConn, error) {
+	dialerKey := deriveKey(shared, transcript, "hydap dialer to acceptor")
+	acceptorKey := deriveKey(shared, transcript, "hydap acceptor to dialer")
+	if !dialer {
+		dialerKey, acceptorKey = acceptorKey, dialerKey
+	}
+	seal, err := newAEAD(dialerKey)
+	if err != nil {
+		return nil, err
+	}
+	open, err := newAEAD(acceptorKey)
+	if err != nil {
+		return nil, err
+	}
+	return &secureConn{
+		Conn:      conn,
+		reader:    reader,
+		seal:      seal,
+		sealNonce: make([]byte, seal.NonceSize()),
+		open:      open,
+		openNonce: make([]byte, open.NonceSize()),
+	}, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// recordNonce writes a record number into the nonce buffer
+func recordNonce(nonce []byte, sequence uint64) []byte {
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], sequence)
+	return nonce
+}
+
+func (c *secureConn) Write(p []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > maxRecordSize {
+			chunk = chunk[:maxRecordSize]
+		}
+		record := c.seal.Seal(append(c.sealed[:0], 0, 0, 0, 0), recordNonce(c.sealNonce, c.sent), chunk, nil)
+		c.sealed = record
+		binary.BigEndian.PutUint32(record[:4], uint32(len(record)-4))
+		c.sent++
+		if _, err := c.Conn.Write(record); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+func (c *secureConn) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		var size [4]byte
+		if _, err := io.ReadFull(c.reader, size[:]); err != nil {
+			return 0, err
+		}
+		length := binary.BigEndian.Uint32(size[:])
+		if length > maxRecordSize+uint32(c.open.Overhead()) {
+			return 0, fmt.Errorf("%w: %d byte record", ErrKeyExchange, length)
+		}
+		if cap(c.record) < int(length) {
+			c.record = make([]byte, length)
+		}
+		record := c.record[:length]
+		if _, err := io.ReadFull(c.reader, record); err != nil {
+			return 0, err
+		}
+		plain, err := c.open.Open(record[:0], recordNonce(c.openNonce, c.received), record, nil)
+		if err != nil {
+			return 0, fmt.Errorf("record %d failed to open: %w", c.received, err)
+		}
+		c.received++
+		c.pending = plain
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
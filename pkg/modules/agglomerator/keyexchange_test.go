@@ -0,0 +1,221 @@
+package agglomerator
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/keymanagement/pb"
+)
+
+// testKeys stands in for the liboqs keys with Ed25519 signatures, named
+// after a handshake algorithm, and an X25519 exchange used as a KEM
+type testKeys struct {
+	private   ed25519.PrivateKey
+	algorithm string
+	forge     bool // Sign returns signatures that do not verify
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return &testKeys{private: private, algorithm: "DILITHIUM5"}
+}
+
+func (k *testKeys) SigningAlgorithm() string { return k.algorithm }
+func (k *testKeys) SigningKey() []byte       { return k.private.Public().(ed25519.PublicKey) }
+func (k *testKeys) KEMAlgorithm() string     { return "X25519" }
+
+func (k *testKeys) Sign(message []byte) ([]byte, error) {
+	if k.forge {
+		return make([]byte, ed25519.SignatureSize), nil
+	}
+	return ed25519.Sign(k.private, message), nil
+}
+
+func (k *testKeys) Verify(algorithm string, key, message, signature []byte) error {
+	if !contains(SignatureAlgorithms, algorithm) || len(key) != ed25519.PublicKeySize || !ed25519.Verify(key, message, signature) {
+		return errors.New("bad signature")
+	}
+	return nil
+}
+
+func (k *testKeys) GenerateKEM() ([]byte, []byte, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key.PublicKey().Bytes(), key.Bytes(), nil
+}
+
+func (k *testKeys) Encapsulate(algorithm string, public []byte) ([]byte, []byte, error) {
+	peer, err := ecdh.X25519().NewPublicKey(public)
+	if err != nil {
+		return nil, nil, err
+	}
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	shared, err := ephemeral.ECDH(peer)
+	return ephemeral.PublicKey().Bytes(), shared, err
+}
+
+func (k *testKeys) Decapsulate(algorithm string, secret, ciphertext []byte) ([]byte, error) {
+	key, err := ecdh.X25519().NewPrivateKey(secret)
+	if err != nil {
+		return nil, err
+	}
+	peer, err := ecdh.X25519().NewPublicKey(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	return key.ECDH(peer)
+}
+
+// bufferConn is a Conn over an in-memory buffer
+type bufferConn struct {
+	bytes.Buffer
+}
+
+func (c *bufferConn) Close() error       { return nil }
+func (c *bufferConn) RemotePeer() string { return "buffer" }
+
+// newKeyedNode starts a node listening over TCP, with keys unless nil
+func newKeyedNode(t *testing.T, keys NodeKeys) *P2PInfiniteVectorNode {
+	node := NewP2PInfiniteVectorNode("127.0.0.1", 0)
+	if keys != nil {
+		node.UseKeys(keys)
+	}
+	transport, err := NewTransport(TransportConfig{Type: "tcp"})
+	require.NoError(t, err)
+	require.NoError(t, node.UseTransport(transport))
+	t.Cleanup(func() { node.CloseTransport() })
+	return node
+}
+
+// dialKeyed opens a channel from one node to another, as the node listed
+// under to's ID
+func dialKeyed(from, to *P2PInfiniteVectorNode) (Conn, error) {
+	from.connectToPeer(&PeerInfo{NodeID: to.NodeID, Address: to.listener.Addr()})
+	return from.peerConn(from.transport, to.NodeID)
+}
+
+func TestKeyExchangeEncryptsChannel(t *testing.T) {
+	newNode := func(keys NodeKeys) *P2PInfiniteVectorNode { return newKeyedNode(t, keys) }
+	dial := dialKeyed
+
+	aKeys, bKeys := newTestKeys(t), newTestKeys(t)
+	a, b := newNode(aKeys), newNode(bKeys)
+	conn, err := dial(a, b)
+	require.NoError(t, err)
+	assert.IsType(t, &secureConn{}, conn)
+	version, _ := a.PeerVersion(b.NodeID)
+	assert.Equal(t, secureWireVersion, version)
+	key, ok := a.PeerSigningKey(b.NodeID)
+	require.True(t, ok)
+	assert.Equal(t, bKeys.SigningKey(), key)
+	algorithm, _ := a.PeerAlgorithm(b.NodeID)
+	assert.Equal(t, "DILITHIUM5", algorithm, "the handshake agrees on the algorithm both sign with")
+
+	msg := DataTransferMessage{SenderID: a.NodeID, RecipientID: b.NodeID, DataID: "record-1", Payload: []byte("data"), Sequence: 1}
+	require.NoError(t, writeFrame(conn, msg.proto()))
+	select {
+	case received := <-b.dataChannel:
+		assert.Equal(t, msg.Payload, received.Payload)
+	case <-time.After(time.Second):
+		t.Fatal("message not delivered over the encrypted channel")
+	}
+	key, ok = b.PeerSigningKey(a.NodeID)
+	require.True(t, ok)
+	assert.Equal(t, aKeys.SigningKey(), key)
+
+	plain := newNode(nil)
+	_, err = dial(plain, a)
+	assert.Error(t, err, "nodes with keys refuse peers without")
+	_, err = dial(a, plain)
+	assert.Error(t, err)
+}
+
+func TestKeyExchangePinsPeerKeys(t *testing.T) {
+	node := NewP2PInfiniteVectorNode("127.0.0.1", 0)
+	first, second := newTestKeys(t), newTestKeys(t)
+
+	require.NoError(t, node.checkPeerKey("peer", nil, "DILITHIUM5", "DILITHIUM5", first.SigningKey()))
+	require.NoError(t, node.checkPeerKey("peer", nil, "DILITHIUM5", "DILITHIUM5", second.SigningKey()), "checking does not pin")
+	require.NoError(t, node.pinPeerKey("peer", first.SigningKey()))
+	require.NoError(t, node.pinPeerKey("peer", first.SigningKey()))
+	assert.ErrorIs(t, node.checkPeerKey("peer", nil, "DILITHIUM5", "DILITHIUM5", second.SigningKey()), ErrPeerKeyChanged)
+	assert.ErrorIs(t, node.pinPeerKey("peer", second.SigningKey()), ErrPeerKeyChanged)
+	assert.ErrorIs(t, node.checkPeerKey("other", nil, "DILITHIUM5", "DILITHIUM5", nil), ErrKeyExchange)
+	assert.ErrorIs(t, node.checkPeerKey("other", nil, "DILITHIUM5", "FALCON512", first.SigningKey()), ErrKeyExchange,
+		"peers sign with the negotiated algorithm")
+}
+
+func TestKeyExchangePinsOnlyVerifiedKeys(t *testing.T) {
+	a := newKeyedNode(t, newTestKeys(t))
+	victim := newTestKeys(t)
+
+	// A dialer and a listener claiming the victim's ID present their own
+	// keys but cannot sign the transcript
+	forged := newTestKeys(t)
+	forged.forge = true
+	spoofer := newKeyedNode(t, forged)
+	spoofer.NodeID = "victim"
+	_, err := dialKeyed(spoofer, a)
+	require.NoError(t, err, "the dialer learns of the refusal only when it sends")
+	_, err = dialKeyed(a, spoofer)
+	assert.ErrorIs(t, err, ErrKeyExchange)
+	time.Sleep(50 * time.Millisecond)
+	_, pinned := a.PeerSigningKey("victim")
+	assert.False(t, pinned, "keys are pinned only once their signature verifies")
+
+	real := newKeyedNode(t, victim)
+	real.NodeID = "victim"
+	_, err = dialKeyed(real, a)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		key, pinned := a.PeerSigningKey("victim")
+		return pinned && bytes.Equal(victim.SigningKey(), key)
+	}, time.Second, 10*time.Millisecond, "the real peer is not locked out")
+}
+
+func TestKeyExchangeNeedsCommonSigningAlgorithm(t *testing.T) {
+	falcon := newTestKeys(t)
+	falcon.algorithm = "FALCON512"
+	a, b := newKeyedNode(t, newTestKeys(t)), newKeyedNode(t, falcon)
+	_, err := dialKeyed(a, b)
+	assert.ErrorIs(t, err, ErrNoCommonAlgorithm, "nodes with keys offer only the algorithm they sign with")
+}
+
+func TestSecureConnRejectsTamperedRecords(t *testing.T) {
+	shared := bytes.Repeat([]byte{7}, 32)
+	hash := transcript("node-a", "node-b", &pb.KeyExchange{}, &pb.KeyExchange{})
+	var wire bufferConn
+	sender, err := newSecureConn(&wire, &wire, shared, hash, true)
+	require.NoError(t, err)
+	receiver, err := newSecureConn(&wire, &wire, shared, hash, false)
+	require.NoError(t, err)
+
+	payload := bytes.Repeat([]byte("x"), maxRecordSize+10)
+	_, err = sender.Write(payload)
+	require.NoError(t, err)
+	assert.NotContains(t, wire.String(), "xxxx")
+	received := make([]byte, len(payload))
+	_, err = io.ReadFull(receiver, received)
+	require.NoError(t, err)
+	assert.Equal(t, payload, received)
+
+	_, err = sender.Write([]byte("record"))
+	require.NoError(t, err)
+	wire.Bytes()[len(wire.Bytes())-1] ^= 1
+	_, err = receiver.Read(make([]byte, 16))
+	assert.Error(t, err)
+}
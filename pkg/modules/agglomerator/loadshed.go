@@ -0,0 +1,199 @@
+package agglomerator
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrOverloaded = errors.New("node overloaded")
+
+// OverloadConfig sets the signals that mark the node overloaded. Signals
+// left at zero are ignored.
+type OverloadConfig struct {
+	MaxInFlight   int           // Transactions being processed at once
+	MaxP99Latency time.Duration // 99th percentile of recent transaction latencies
+	MaxHeapBytes  uint64        // Live heap
+	MinPriority   int           // Transactions below this priority are shed while overloaded
+	CheckInterval time.Duration // How often latency and memory are checked
+}
+
+// DefaultOverloadConfig checks every second and sheds transactions without
+// a positive priority
+func DefaultOverloadConfig() OverloadConfig {
+	return OverloadConfig{MinPriority: 1, CheckInterval: time.Second}
+}
+
+// OverloadStats reports the overload signals and what was shed
+type OverloadStats struct {
+	Overloaded bool          `json:"overloaded"`
+	Reasons    []string      `json:"reasons,omitempty"`
+	InFlight   int64         `json:"inFlight"`
+	P99Latency time.Duration `json:"p99Latency"`
+	HeapBytes  uint64        `json:"heapBytes"`
+	Shed       uint64        `json:"shed"` // Transactions refused while overloaded
+}
+
+// LoadShedder watches queue depth, latency and memory, and while any is over
+// its limit refuses low-priority transactions so the node can drain. Health
+// and admin endpoints do not pass through it.
+type LoadShedder struct {
+	config   OverloadConfig
+	inFlight atomic.Int64
+	shed     atomic.Uint64
+
+	latencies latencyWindow // Recent transaction latencies
+
+	mu       sync.Mutex
+	p99      time.Duration
+	heap     uint64
+	reasons  []string // Signals over their limit at the last check, queue depth aside
+	onChange func(overloaded bool)
+	was      bool
+	stop     chan struct{}
+}
+
+// NewLoadShedder creates a shedder; Start runs its checks
+func NewLoadShedder(config OverloadConfig) (*LoadShedder, error) {
+	if config.MaxInFlight < 0 || config.MaxP99Latency < 0 {
+		return nil, errors.New("overload limits must not be negative")
+	}
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = DefaultOverloadConfig().CheckInterval
+	}
+	return &LoadShedder{config: config}, nil
+}
+
+// OnChange calls fn whenever the node enters or leaves overload, as seen by
+// the periodic check
+func (s *LoadShedder) OnChange(fn func(overloaded bool)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onChange = fn
+}
+
+// Config returns the overload settings
+func (s *LoadShedder) Config() OverloadConfig {
+	return s.config
+}
+
+// Admit refuses a transaction below the minimum priority while the node is
+// overloaded. Admitted transactions must be passed to Done.
+func (s *LoadShedder) Admit(tx *Transaction) error {
+	if tx.Priority < s.config.MinPriority {
+		if reasons := s.overloadReasons(); len(reasons) > 0 {
+			s.shed.Add(1)
+			return fmt.Errorf("%w: %v", ErrOverloaded, reasons)
+		}
+	}
+	s.inFlight.Add(1)
+	return nil
+}
+
+// Done records the latency of an admitted transaction
+func (s *LoadShedder) Done(latency time.Duration) {
+	s.inFlight.Add(-1)
+	s.latencies.add(latency)
+}
+
+// Overloaded reports whether any signal is over its limit
+func (s *LoadShedder) Overloaded() bool {
+	return len(s.overloadReasons()) > 0
+}
+
+func (s *LoadShedder) overloadReasons() []string {
+	s.mu.Lock()
+	reasons := append([]string(nil), s.reasons...)
+	s.mu.Unlock()
+	if limit := s.config.MaxInFlight; limit > 0 && s.inFlight.Load() >= int64(limit) {
+		reasons = append(reasons, "queue depth")
+	}
+	return reasons
+}
+
+// Check samples latency and memory, returning whether the node is
+// overloaded
+func (s *LoadShedder) Check() bool {
+	var heap uint64
+	if s.config.MaxHeapBytes > 0 {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		heap = stats.HeapAlloc
+	}
+
+	p99, _ := s.latencies.quantile(0.99)
+
+	s.mu.Lock()
+	var reasons []string
+	if limit := s.config.MaxP99Latency; limit > 0 && p99 > limit {
+		reasons = append(reasons, "p99 latency")
+	}
+	if limit := s.config.MaxHeapBytes; limit > 0 && heap > limit {
+		reasons = append(reasons, "memory")
+	}
+	s.p99, s.heap, s.reasons = p99, heap, reasons
+	onChange := s.onChange
+	s.mu.Unlock()
+
+	overloaded := s.Overloaded()
+	s.mu.Lock()
+	changed := overloaded != s.was
+	s.was = overloaded
+	s.mu.Unlock()
+	if changed && onChange != nil {
+		onChange(overloaded)
+	}
+	return overloaded
+}
+
+// Stats returns the overload signals and shed counts
+func (s *LoadShedder) Stats() OverloadStats {
+	reasons := s.overloadReasons()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return OverloadStats{
+		Overloaded: len(reasons) > 0,
+		Reasons:    reasons,
+		InFlight:   s.inFlight.Load(),
+		P99Latency: s.p99,
+		HeapBytes:  s.heap,
+		Shed:       s.shed.Load(),
+	}
+}
+
+// Start checks the signals every CheckInterval until Stop
+func (s *LoadShedder) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	go s.run(s.stop)
+}
+
+// Stop ends the periodic checks
+func (s *LoadShedder) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
+
+func (s *LoadShedder) run(stop chan struct{}) {
+	ticker := time.NewTicker(s.config.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.Check()
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package agglomerator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+func TestLoadShedderShedsLowPriorityUnderOverload(t *testing.T) {
+	shedder, err := NewLoadShedder(OverloadConfig{MaxInFlight: 2, MaxP99Latency: 100 * time.Millisecond, MinPriority: 1})
+	require.NoError(t, err)
+	var changes []bool
+	shedder.OnChange(func(overloaded bool) { changes = append(changes, overloaded) })
+
+	low, high := &Transaction{ID: "low"}, &Transaction{ID: "high", Priority: 5}
+	require.NoError(t, shedder.Admit(low))
+	require.NoError(t, shedder.Admit(low))
+	assert.ErrorIs(t, shedder.Admit(low), ErrOverloaded, "queue depth sheds low priority")
+	assert.NoError(t, shedder.Admit(high), "high priority is still admitted")
+	for i := 0; i < 3; i++ {
+		shedder.Done(time.Millisecond)
+	}
+	assert.False(t, shedder.Overloaded())
+
+	// Latency is only judged once enough transactions have been timed
+	for i := 0; i < minLatencySamples; i++ {
+		require.NoError(t, shedder.Admit(low))
+		shedder.Done(time.Second)
+	}
+	assert.True(t, shedder.Check())
+	assert.ErrorIs(t, shedder.Admit(low), ErrOverloaded)
+	stats := shedder.Stats()
+	assert.Equal(t, []string{"p99 latency"}, stats.Reasons)
+	assert.Equal(t, time.Second, stats.P99Latency)
+	assert.Equal(t, uint64(2), stats.Shed)
+
+	for i := 0; i < latencyWindowSize; i++ {
+		require.NoError(t, shedder.Admit(high))
+		shedder.Done(time.Millisecond)
+	}
+	assert.False(t, shedder.Check())
+	assert.Equal(t, []bool{true, false}, changes)
+}
+
+func TestPausedIngestDropsReplicatedRecords(t *testing.T) {
+	node := NewP2PInfiniteVectorNode("127.0.0.1", 0)
+	record := func(id string) DataTransferMessage {
+		return DataTransferMessage{SenderID: "peer", RecipientID: node.NodeID, DataID: id,
+			Payload: node.serializeRecord(vectors.DatabaseRecord{ID: id, Metadata: map[string]interface{}{"type": "test"}}), Timestamp: time.Now()}
+	}
+
+	node.PauseIngest(true)
+	node.processDataTransfer(record("paused"))
+	assert.NotContains(t, node.localDatabase.records, "paused")
+	assert.Equal(t, uint64(1), node.IngestDropped())
+	assert.True(t, isControlData(zoneDataPrefix+"0123456789abcdef/"+zoneMemberSuffix), "zone proofs still flow")
+
+	node.PauseIngest(false)
+	node.processDataTransfer(record("resumed"))
+	assert.Contains(t, node.localDatabase.records, "resumed")
+}
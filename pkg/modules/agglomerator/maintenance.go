@@ -0,0 +1,349 @@
+package agglomerator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+// Chain maintenance modes
+const (
+	ChainModeDraining    = "draining"    // Takes no new transactions while its pool empties
+	ChainModeMaintenance = "maintenance" // Takes no transactions at all
+)
+
+// What happens to the transactions routed to a chain when its window begins
+const (
+	PendingReroute = "reroute" // Moved to the best chain still taking transactions
+	PendingHold    = "hold"    // Kept in the pool until the window ends
+)
+
+// RemovedRerouted is the reason a transaction leaves the pool of a chain
+// that was drained
+const RemovedRerouted = "rerouted"
+
+// DefaultMaintenanceCheckInterval is how often windows are checked for
+// having begun when maintenance.checkInterval is not set
+const DefaultMaintenanceCheckInterval = 10 * time.Second
+
+var ErrInvalidMaintenance = errors.New("invalid chain maintenance")
+
+// ChainMaintenance takes a chain out of routing from Start until End, or
+// until it is cleared when End is zero
+type ChainMaintenance struct {
+	Mode    string    `json:"mode"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Pending string    `json:"pending"`
+	Reason  string    `json:"reason,omitempty"`
+
+	drained bool // The pool was handled when the window began
+}
+
+// Active reports whether the chain is out of routing at now
+func (m *ChainMaintenance) Active(now time.Time) bool {
+	return !now.Before(m.Start) && (m.End.IsZero() || now.Before(m.End))
+}
+
+// expired reports whether the window has ended by now
+func (m *ChainMaintenance) expired(now time.Time) bool {
+	return !m.End.IsZero() && !now.Before(m.End)
+}
+
+// normalize fills in the defaults, starting the window at now and choosing
+// how pending transactions are handled by mode, and checks the result
+func (m *ChainMaintenance) normalize(now time.Time) error {
+	switch m.Mode {
+	case "":
+		m.Mode = ChainModeMaintenance
+	case ChainModeDraining, ChainModeMaintenance:
+	default:
+		return fmt.Errorf("%w: unknown mode %q", ErrInvalidMaintenance, m.Mode)
+	}
+	switch m.Pending {
+	case "":
+		m.Pending = PendingHold
+		if m.Mode == ChainModeDraining {
+			m.Pending = PendingReroute
+		}
+	case PendingReroute, PendingHold:
+	default:
+		return fmt.Errorf("%w: unknown pending handling %q", ErrInvalidMaintenance, m.Pending)
+	}
+	if m.Start.IsZero() {
+		m.Start = now
+	}
+	if !m.End.IsZero() && !m.End.After(m.Start) {
+		return fmt.Errorf("%w: window ends before it starts", ErrInvalidMaintenance)
+	}
+	if m.expired(now) {
+		return fmt.Errorf("%w: window has already ended", ErrInvalidMaintenance)
+	}
+	return nil
+}
+
+func (m *ChainMaintenance) String() string {
+	if m.End.IsZero() {
+		return m.Mode
+	}
+	return fmt.Sprintf("%s until %s", m.Mode, m.End.Format(time.RFC3339))
+}
+
+// metadata returns the window as a record metadata value
+func (m *ChainMaintenance) metadata() map[string]interface{} {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil
+	}
+	var value map[string]interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil
+	}
+	return value
+}
+
+// maintenanceFromMetadata reads the maintenance window a peer gossiped with
+// a chain registration, or nil if it sent none or sent it malformed
+func maintenanceFromMetadata(metadata map[string]interface{}) *ChainMaintenance {
+	value, exists := metadata["maintenance"]
+	if !exists {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	var maintenance ChainMaintenance
+	if err := json.Unmarshal(data, &maintenance); err != nil || maintenance.Start.IsZero() {
+		return nil
+	}
+	return &maintenance
+}
+
+// DrainResult counts what happened to the transactions routed to a chain
+// when its window began
+type DrainResult struct {
+	Rerouted int `json:"rerouted"`
+	Held     int `json:"held"`
+}
+
+// serves returns nil if the chain can take tx at now, or an error wrapping
+// ErrChainIncapable saying why not
+func (c *Chain) serves(tx *Transaction, now time.Time) error {
+	if c.maintenance != nil && c.maintenance.Active(now) {
+		return fmt.Errorf("%w: %s", ErrChainIncapable, c.maintenance)
+	}
+	if canary := c.Canary(); canary != nil && canary.State == CanaryQuarantined {
+		return fmt.Errorf("%w: %s", ErrChainIncapable, canary)
+	}
+	return c.Capabilities.Serves(tx, now)
+}
+
+// Maintenance returns the chain's maintenance window, or nil if it has none
+func (c *Chain) Maintenance() *ChainMaintenance {
+	if c.maintenance == nil {
+		return nil
+	}
+	maintenance := *c.maintenance
+	return &maintenance
+}
+
+// SetMaintenance schedules a maintenance window for a chain, replacing any
+// it had. Routing stops selecting the chain once the window begins; if it
+// already has, the transactions routed to the chain are handled at once.
+func (a *Agglomerator) SetMaintenance(chainID string, maintenance ChainMaintenance) (ChainMaintenance, DrainResult, error) {
+	now := a.clock.Now()
+	if err := maintenance.normalize(now); err != nil {
+		return maintenance, DrainResult{}, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	chain, exists := a.chains[chainID]
+	if !exists {
+		return maintenance, DrainResult{}, ErrChainNotFound
+	}
+	chain.maintenance = &maintenance
+	a.events.record(EventChainMaintenance, chainID, "", ChainMaintenanceData{Maintenance: &maintenance})
+
+	var result DrainResult
+	if maintenance.Active(now) {
+		result = a.drain(chain, now)
+	}
+	return maintenance, result, nil
+}
+
+// ClearMaintenance ends a chain's maintenance window, returning it to
+// routing
+func (a *Agglomerator) ClearMaintenance(chainID string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	chain, exists := a.chains[chainID]
+	if !exists {
+		return ErrChainNotFound
+	}
+	if chain.maintenance != nil {
+		chain.maintenance = nil
+		a.events.record(EventChainMaintenance, chainID, "", ChainMaintenanceData{})
+	}
+	return nil
+}
+
+// restoreMaintenance puts back a window read from the event log, leaving
+// the chain's pool to the next ApplyMaintenance
+func (a *Agglomerator) restoreMaintenance(chainID string, maintenance ChainMaintenance) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if chain, exists := a.chains[chainID]; exists {
+		chain.maintenance = &maintenance
+	}
+}
+
+// ApplyMaintenance handles the pools of chains whose windows have begun
+// since the last call, and drops windows that have ended
+func (a *Agglomerator) ApplyMaintenance() map[string]DrainResult {
+	now := a.clock.Now()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	results := make(map[string]DrainResult)
+	for id, chain := range a.chains {
+		switch maintenance := chain.maintenance; {
+		case maintenance == nil:
+		case maintenance.expired(now):
+			chain.maintenance = nil
+		case maintenance.Active(now) && !maintenance.drained:
+			results[id] = a.drain(chain, now)
+		}
+	}
+	return results
+}
+
+// drain handles the transactions routed to a chain whose window has begun:
+// with PendingReroute each moves to the best chain that can serve it, and
+// stays otherwise. The caller holds a.mu.
+func (a *Agglomerator) drain(chain *Chain, now time.Time) DrainResult {
+	chain.maintenance.drained = true
+
+	var result DrainResult
+	for _, entry := range chain.PoolEntries() {
+		record, exists := chain.TransactionPool.Get(entry.TxID)
+		if !exists || record.Metadata["toChain"] != chain.ID {
+			continue
+		}
+		if chain.maintenance.Pending != PendingReroute || !a.reroute(chain, entry, record, now) {
+			result.Held++
+			continue
+		}
+		result.Rerouted++
+	}
+	return result
+}
+
+// reroute moves a transaction from a drained chain's pool to the best
+// chain that can take it, reporting whether there was one. The caller holds
+// a.mu.
+func (a *Agglomerator) reroute(from *Chain, entry PoolEntry, record vectors.DatabaseRecord, now time.Time) bool {
+	fromChainID, _ := record.Metadata["fromChain"].(string)
+	tx := &Transaction{
+		ID:          entry.TxID,
+		FromChain:   fromChainID,
+		StateVector: record.Vector,
+		Priority:    entry.Priority,
+		Fee:         entry.Fee,
+	}
+	zone := ""
+	if source, exists := a.chains[fromChainID]; exists {
+		zone = source.Zone
+	}
+
+	candidates := make([]*Chain, 0, len(a.chains))
+	for _, chain := range a.chains {
+		if chain != from && chain.ID != fromChainID && chainVisible(chain, zone) && chain.serves(tx, now) == nil {
+			candidates = append(candidates, chain)
+		}
+	}
+	candidates, _ = a.holdBackCanaries(candidates, tx)
+	route := findOptimalRoute(candidates, tx, a.dimsFor(tx))
+	if len(route) == 0 {
+		return false
+	}
+	return a.movePooled(from, route[0], entry, record) == nil
+}
+
+// movePooled moves a transaction from one chain's pool to another's,
+// pointing its record at the new destination. from may be nil when the
+// transaction is in no pool. The caller holds a.mu.
+func (a *Agglomerator) movePooled(from, to *Chain, entry PoolEntry, record vectors.DatabaseRecord) error {
+	if err := to.admitToPool(entry); err != nil {
+		return err
+	}
+
+	rerouted := vectors.DatabaseRecord{ID: record.ID, Metadata: make(map[string]interface{}, len(record.Metadata)), Vector: record.Vector}
+	for key, value := range record.Metadata {
+		rerouted.Metadata[key] = value
+	}
+	rerouted.Metadata["toChain"] = to.ID
+	to.TransactionPool.Insert(rerouted)
+	a.events.record(EventTransactionPooled, to.ID, entry.TxID, nil)
+	if from != nil {
+		from.removeFromPool(entry.TxID, RemovedRerouted)
+	}
+	return nil
+}
+
+// maintenanceChecks runs ApplyMaintenance in the background
+type maintenanceChecks struct {
+	stop chan struct{}
+}
+
+// StartMaintenanceChecks applies maintenance windows every interval, so
+// pools are drained when a scheduled window begins
+func (a *Agglomerator) StartMaintenanceChecks(interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("maintenance check interval must be positive")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.maintenanceChecks != nil {
+		return fmt.Errorf("maintenance checks already running")
+	}
+	checks := &maintenanceChecks{stop: make(chan struct{})}
+	a.maintenanceChecks = checks
+	go a.runMaintenanceChecks(checks, a.clock.NewTicker(interval))
+	return nil
+}
+
+// StopMaintenanceChecks stops applying maintenance windows in the
+// background
+func (a *Agglomerator) StopMaintenanceChecks() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.maintenanceChecks != nil {
+		close(a.maintenanceChecks.stop)
+		a.maintenanceChecks = nil
+	}
+}
+
+func (a *Agglomerator) runMaintenanceChecks(checks *maintenanceChecks, ticker core.Ticker) {
+	defer ticker.Stop()
+	for {
+		select {
+		case <-checks.stop:
+			return
+		case <-ticker.C():
+			a.ApplyMaintenance()
+		}
+	}
+}
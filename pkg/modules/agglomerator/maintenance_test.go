@@ -0,0 +1,118 @@
+package agglomerator
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+func newMaintenanceAgglomerator(t *testing.T, clock core.Clock) *Agglomerator {
+	agg := NewAgglomerator(AgglomeratorConfig{Clock: clock})
+	for _, id := range []string{"source", "target", "spare"} {
+		require.NoError(t, agg.RegisterChain(NewChain(id, "http://localhost:8545", ProtocolEthereum)))
+	}
+	return agg
+}
+
+func routeTo(agg *Agglomerator, id, to string) error {
+	_, err := agg.recordTransaction(&Transaction{ID: id, FromChain: "source", ToChain: to,
+		StateVector: vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}})
+	return err
+}
+
+func TestDrainReroutesPool(t *testing.T) {
+	agg := newMaintenanceAgglomerator(t, core.NewFakeClock(time.Unix(0, 0)))
+	for i := 0; i < 3; i++ {
+		require.NoError(t, routeTo(agg, fmt.Sprintf("tx-%d", i), "target"))
+	}
+
+	maintenance, result, err := agg.SetMaintenance("target", ChainMaintenance{Mode: ChainModeDraining})
+	require.NoError(t, err)
+	assert.Equal(t, PendingReroute, maintenance.Pending, "draining reroutes by default")
+	assert.Equal(t, DrainResult{Rerouted: 3}, result)
+
+	target, _ := agg.GetChain("target")
+	spare, _ := agg.GetChain("spare")
+	assert.Empty(t, target.PoolEntries())
+	require.Len(t, spare.PoolEntries(), 3)
+	record, exists := spare.TransactionPool.Get("tx-0")
+	require.True(t, exists)
+	assert.Equal(t, "spare", record.Metadata["toChain"])
+
+	assert.ErrorIs(t, routeTo(agg, "tx-new", "target"), ErrChainIncapable, "a draining chain takes no new transactions")
+	require.NoError(t, agg.ClearMaintenance("target"))
+	assert.NoError(t, routeTo(agg, "tx-new", "target"))
+	assert.ErrorIs(t, agg.ClearMaintenance("missing"), ErrChainNotFound)
+}
+
+func TestScheduledMaintenanceHoldsPool(t *testing.T) {
+	clock := core.NewFakeClock(time.Unix(0, 0))
+	agg := newMaintenanceAgglomerator(t, clock)
+	require.NoError(t, routeTo(agg, "tx-1", "target"))
+
+	start := clock.Now().Add(time.Hour)
+	_, result, err := agg.SetMaintenance("target", ChainMaintenance{Start: start, End: start.Add(time.Hour), Reason: "upgrade"})
+	require.NoError(t, err)
+	assert.Zero(t, result, "the window has not begun")
+	assert.NoError(t, routeTo(agg, "tx-2", "target"), "routing continues until the window")
+
+	clock.Advance(time.Hour)
+	assert.Equal(t, map[string]DrainResult{"target": {Held: 2}}, agg.ApplyMaintenance())
+	assert.Empty(t, agg.ApplyMaintenance(), "a pool is handled once per window")
+	target, _ := agg.GetChain("target")
+	assert.Len(t, target.PoolEntries(), 2, "maintenance holds the pool")
+	assert.ErrorIs(t, routeTo(agg, "tx-3", "target"), ErrChainIncapable)
+
+	clock.Advance(time.Hour)
+	agg.ApplyMaintenance()
+	assert.Nil(t, target.Maintenance(), "ended windows are dropped")
+	assert.NoError(t, routeTo(agg, "tx-3", "target"))
+}
+
+func TestMaintenanceValidation(t *testing.T) {
+	agg := newMaintenanceAgglomerator(t, core.NewFakeClock(time.Unix(0, 0)))
+	now := time.Unix(0, 0)
+	for name, maintenance := range map[string]ChainMaintenance{
+		"unknown mode":    {Mode: "offline"},
+		"unknown pending": {Pending: "drop"},
+		"inverted window": {Start: now.Add(time.Hour), End: now},
+		"ended window":    {Start: now.Add(-2 * time.Hour), End: now.Add(-time.Hour)},
+	} {
+		_, _, err := agg.SetMaintenance("target", maintenance)
+		assert.ErrorIs(t, err, ErrInvalidMaintenance, name)
+	}
+	_, _, err := agg.SetMaintenance("missing", ChainMaintenance{})
+	assert.ErrorIs(t, err, ErrChainNotFound)
+}
+
+func TestMaintenanceGossipedToPeers(t *testing.T) {
+	clock := core.NewFakeClock(time.Unix(0, 0))
+	node := NewP2PInfiniteVectorNode("127.0.0.1", 0)
+	node.peers["peer-a"] = &PeerInfo{NodeID: "peer-a"}
+	p2p := NewP2PAgglomeratorWithNode(AgglomeratorConfig{Clock: clock}, node)
+	require.NoError(t, p2p.RegisterChain(NewChain("eth-main", "http://localhost:8545", ProtocolEthereum)))
+	node.broadcaster.due(false)
+
+	_, _, err := p2p.SetMaintenance("eth-main", ChainMaintenance{End: clock.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	batches := node.broadcaster.due(true)
+	require.Len(t, batches, 1, "maintenance is a correction, sent at once")
+	require.Len(t, batches[0].records, 1)
+
+	wire, err := RecordProto(batches[0].records[0], 0)
+	require.NoError(t, err)
+	chain := chainFromRecord(RecordFromProto(wire))
+	tx := &Transaction{ID: "tx-1"}
+	assert.ErrorIs(t, chain.serves(tx, clock.Now()), ErrChainIncapable, "peers stop routing to the chain")
+	assert.NoError(t, chain.serves(tx, clock.Now().Add(time.Hour)), "and resume when the window ends")
+
+	require.NoError(t, p2p.ClearMaintenance("eth-main"))
+	batches = node.broadcaster.due(true)
+	require.Len(t, batches, 1)
+	assert.NotContains(t, batches[0].records[0].Metadata, "maintenance")
+}
@@ -0,0 +1,374 @@
+package agglomerator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// metricsHistoryPersistInterval is how often sampled history is written
+	// to disk
+	metricsHistoryPersistInterval = time.Minute
+
+	// Series recorded by the module's sampler
+	SeriesTxThroughput = "tx_throughput" // Processed transactions per second
+	SeriesTxFailures   = "tx_failures"   // Failed transactions per second
+	SeriesPeerCount    = "peer_count"
+	SeriesChainCount   = "chain_count"
+	SeriesCacheHitRate = "element_cache_hit_rate" // Hits over lookups since the last sample
+	SeriesQueryHitRate = "query_cache_hit_rate"   // Routing query cache hits over lookups since the last sample
+	SeriesTxShed       = "tx_shed"                // Transactions shed under overload per second
+	SeriesIngestDrops  = "ingest_drops"           // Replicated payloads dropped under overload per second
+
+	// seriesRouteScorePrefix is followed by "<from>-><to>"
+	seriesRouteScorePrefix = "route_score:"
+
+	// seriesPoolOccupancyPrefix is followed by a chain ID with a limited pool
+	seriesPoolOccupancyPrefix = "pool_occupancy:"
+)
+
+// MetricSample is one point of a metric series
+type MetricSample struct {
+	Time  time.Time `json:"t"`
+	Value float64   `json:"v"`
+}
+
+// sampleRing keeps the newest capacity samples of a series. It grows until
+// full, then overwrites the oldest sample at next.
+type sampleRing struct {
+	samples  []MetricSample
+	capacity int
+	next     int
+}
+
+func (r *sampleRing) add(sample MetricSample) {
+	if len(r.samples) < r.capacity {
+		r.samples = append(r.samples, sample)
+		return
+	}
+	r.samples[r.next] = sample
+	r.next = (r.next + 1) % len(r.samples)
+}
+
+// ordered returns the samples oldest first
+func (r *sampleRing) ordered() []MetricSample {
+	return append(append([]MetricSample(nil), r.samples[r.next:]...), r.samples[:r.next]...)
+}
+
+func (r *sampleRing) newest() (MetricSample, bool) {
+	if len(r.samples) == 0 {
+		return MetricSample{}, false
+	}
+	return r.samples[(r.next+len(r.samples)-1)%len(r.samples)], true
+}
+
+// MetricsHistory is a fixed-size time-series store: each series is a ring
+// buffer holding the most recent capacity samples
+type MetricsHistory struct {
+	capacity int
+	series   map[string]*sampleRing
+	mu       sync.RWMutex
+}
+
+func NewMetricsHistory(capacity int) *MetricsHistory {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &MetricsHistory{
+		capacity: capacity,
+		series:   make(map[string]*sampleRing),
+	}
+}
+
+// Record appends a sample to the named series
+func (h *MetricsHistory) Record(name string, at time.Time, value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ring, exists := h.series[name]
+	if !exists {
+		ring = &sampleRing{capacity: h.capacity}
+		h.series[name] = ring
+	}
+	ring.add(MetricSample{Time: at, Value: value})
+}
+
+// Series returns the names of all recorded series
+func (h *MetricsHistory) Series() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	names := make([]string, 0, len(h.series))
+	for name := range h.series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Query returns the samples of the named series between from and to,
+// inclusive. All series are returned when names is empty. A positive step
+// averages the samples into buckets of that width.
+func (h *MetricsHistory) Query(names []string, from, to time.Time, step time.Duration) map[string][]MetricSample {
+	if len(names) == 0 {
+		names = h.Series()
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	result := make(map[string][]MetricSample, len(names))
+	for _, name := range names {
+		ring, exists := h.series[name]
+		if !exists {
+			continue
+		}
+		samples := make([]MetricSample, 0)
+		for _, sample := range ring.ordered() {
+			if !sample.Time.Before(from) && !sample.Time.After(to) {
+				samples = append(samples, sample)
+			}
+		}
+		if step > 0 {
+			samples = downsample(samples, from, step)
+		}
+		result[name] = samples
+	}
+	return result
+}
+
+// downsample averages samples into step-wide buckets aligned to from; each
+// bucket is reported at its start time
+func downsample(samples []MetricSample, from time.Time, step time.Duration) []MetricSample {
+	buckets := make([]MetricSample, 0)
+	count := 0
+	for _, sample := range samples {
+		start := from.Add(sample.Time.Sub(from) / step * step)
+		if count > 0 && buckets[len(buckets)-1].Time.Equal(start) {
+			last := &buckets[len(buckets)-1]
+			count++
+			last.Value += (sample.Value - last.Value) / float64(count)
+			continue
+		}
+		buckets = append(buckets, MetricSample{Time: start, Value: sample.Value})
+		count = 1
+	}
+	return buckets
+}
+
+// Collect drops series whose newest sample is older than cutoff, such as
+// routes that are no longer used
+func (h *MetricsHistory) Collect(cutoff time.Time) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	removed := 0
+	for name, ring := range h.series {
+		if newest, ok := ring.newest(); !ok || newest.Time.Before(cutoff) {
+			delete(h.series, name)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Save writes the history to path, replacing the previous file atomically
+func (h *MetricsHistory) Save(path string) error {
+	h.mu.RLock()
+	series := make(map[string][]MetricSample, len(h.series))
+	for name, ring := range h.series {
+		series[name] = ring.ordered()
+	}
+	h.mu.RUnlock()
+
+	data, err := json.Marshal(series)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create metrics history directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write metrics history: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadMetricsHistory reads history saved by Save. A missing file yields an
+// empty history; series longer than capacity keep their newest samples.
+func LoadMetricsHistory(path string, capacity int) (*MetricsHistory, error) {
+	history := NewMetricsHistory(capacity)
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metrics history: %w", err)
+	}
+
+	var series map[string][]MetricSample
+	if err := json.Unmarshal(data, &series); err != nil {
+		return nil, fmt.Errorf("failed to parse metrics history: %w", err)
+	}
+	for name, samples := range series {
+		for _, sample := range samples {
+			history.Record(name, sample.Time, sample.Value)
+		}
+	}
+	return history, nil
+}
+
+// MetricsHistoryConfig controls sampling of the module's metrics history
+type MetricsHistoryConfig struct {
+	Interval  time.Duration
+	Retention time.Duration
+	Path      string // Empty keeps history in memory only
+}
+
+// metricsSampler periodically records the module's key metrics
+type metricsSampler struct {
+	module    *AgglomeratorModule
+	history   *MetricsHistory
+	config    MetricsHistoryConfig
+	processed atomic.Uint64
+	failed    atomic.Uint64
+	stop      chan struct{}
+	done      chan struct{}
+
+	// Cache counters at the last sample
+	cacheHits, cacheMisses uint64
+	queryHits, queryMisses uint64
+
+	// Overload counters at the last sample
+	shed, ingestDropped uint64
+}
+
+func newMetricsSampler(module *AgglomeratorModule, config MetricsHistoryConfig) (*metricsSampler, error) {
+	capacity := int(config.Retention / config.Interval)
+	var history *MetricsHistory
+	var err error
+	if config.Path != "" {
+		history, err = LoadMetricsHistory(config.Path, capacity)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		history = NewMetricsHistory(capacity)
+	}
+
+	return &metricsSampler{
+		module:  module,
+		history: history,
+		config:  config,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// count records a processed transaction for the throughput series
+func (s *metricsSampler) count(failed bool) {
+	s.processed.Add(1)
+	if failed {
+		s.failed.Add(1)
+	}
+}
+
+func (s *metricsSampler) start() {
+	go s.run()
+}
+
+// close stops sampling and persists the history one last time
+func (s *metricsSampler) close() error {
+	close(s.stop)
+	<-s.done
+	if s.config.Path == "" {
+		return nil
+	}
+	return s.history.Save(s.config.Path)
+}
+
+func (s *metricsSampler) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	last := time.Now()
+	lastSaved := last
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.sample(now, now.Sub(last))
+			last = now
+
+			if s.config.Path != "" && now.Sub(lastSaved) >= metricsHistoryPersistInterval {
+				if err := s.history.Save(s.config.Path); err != nil {
+					s.module.logger.Log(s.module.Name(), "ERROR", err.Error())
+				}
+				lastSaved = now
+			}
+		}
+	}
+}
+
+// sample records one point of every series; elapsed is the time since the
+// previous sample and turns counters into rates
+func (s *metricsSampler) sample(now time.Time, elapsed time.Duration) {
+	seconds := elapsed.Seconds()
+	if seconds <= 0 {
+		return
+	}
+	s.history.Record(SeriesTxThroughput, now, float64(s.processed.Swap(0))/seconds)
+	s.history.Record(SeriesTxFailures, now, float64(s.failed.Swap(0))/seconds)
+
+	if agg := s.module.GetAgglomerator(); agg != nil {
+		chains := agg.ListChains()
+		s.history.Record(SeriesChainCount, now, float64(len(chains)))
+		for _, chain := range chains {
+			if stats, ok := chain.PoolStats(); ok && stats.MaxSize > 0 {
+				s.history.Record(seriesPoolOccupancyPrefix+chain.ID, now, stats.Occupancy)
+			}
+		}
+
+		stats := agg.ElementCacheStats()
+		hits, misses := stats.Hits-s.cacheHits, stats.Misses-s.cacheMisses
+		s.cacheHits, s.cacheMisses = stats.Hits, stats.Misses
+		if hits+misses > 0 {
+			s.history.Record(SeriesCacheHitRate, now, float64(hits)/float64(hits+misses))
+		}
+
+		queries := agg.QueryCacheStats()
+		hits, misses = queries.Hits-s.queryHits, queries.Misses-s.queryMisses
+		s.queryHits, s.queryMisses = queries.Hits, queries.Misses
+		if hits+misses > 0 {
+			s.history.Record(SeriesQueryHitRate, now, float64(hits)/float64(hits+misses))
+		}
+	}
+	if shedder := s.module.GetLoadShedder(); shedder != nil {
+		shed := shedder.Stats().Shed
+		s.history.Record(SeriesTxShed, now, float64(shed-s.shed)/seconds)
+		s.shed = shed
+	}
+	if p2p := s.module.GetP2P(); p2p != nil {
+		s.history.Record(SeriesPeerCount, now, float64(p2p.p2pNode.PeerCount()))
+		dropped := p2p.p2pNode.IngestDropped()
+		s.history.Record(SeriesIngestDrops, now, float64(dropped-s.ingestDropped)/seconds)
+		s.ingestDropped = dropped
+	}
+	for _, link := range s.module.routes.Links() {
+		s.history.Record(seriesRouteScorePrefix+link.Source+"->"+link.Target, now, link.AvgScore)
+	}
+
+	s.history.Collect(now.Add(-s.config.Retention))
+}
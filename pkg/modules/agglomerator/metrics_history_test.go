@@ -0,0 +1,78 @@
+package agglomerator
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsHistoryRingAndQuery(t *testing.T) {
+	history := NewMetricsHistory(3)
+	start := time.Unix(1700000000, 0)
+	for i := 0; i < 5; i++ {
+		history.Record(SeriesTxThroughput, start.Add(time.Duration(i)*time.Second), float64(i))
+	}
+	history.Record(SeriesPeerCount, start, 2)
+
+	all := history.Query(nil, start, start.Add(time.Minute), 0)
+	require.Len(t, all[SeriesTxThroughput], 3, "oldest samples are overwritten")
+	assert.Equal(t, []float64{2, 3, 4}, sampleValues(all[SeriesTxThroughput]))
+	assert.Len(t, all[SeriesPeerCount], 1)
+
+	ranged := history.Query([]string{SeriesTxThroughput, "unknown"}, start.Add(3*time.Second), start.Add(4*time.Second), 0)
+	assert.Equal(t, []float64{3, 4}, sampleValues(ranged[SeriesTxThroughput]))
+	assert.NotContains(t, ranged, "unknown")
+}
+
+func TestMetricsHistoryDownsample(t *testing.T) {
+	history := NewMetricsHistory(10)
+	start := time.Unix(1700000000, 0)
+	for i, value := range []float64{1, 3, 5, 7, 9} {
+		history.Record(SeriesTxThroughput, start.Add(time.Duration(i)*10*time.Second), value)
+	}
+
+	samples := history.Query([]string{SeriesTxThroughput}, start, start.Add(time.Minute), 20*time.Second)[SeriesTxThroughput]
+	assert.Equal(t, []float64{2, 6, 9}, sampleValues(samples))
+	assert.Equal(t, start.Add(20*time.Second), samples[1].Time)
+}
+
+func TestMetricsHistoryCollect(t *testing.T) {
+	history := NewMetricsHistory(10)
+	now := time.Now()
+	history.Record(seriesRouteScorePrefix+"a->b", now.Add(-2*time.Hour), 0.5)
+	history.Record(SeriesTxThroughput, now, 1)
+
+	assert.Equal(t, 1, history.Collect(now.Add(-time.Hour)))
+	assert.Equal(t, []string{SeriesTxThroughput}, history.Series())
+}
+
+func TestMetricsHistoryPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics_history.json")
+	start := time.Unix(1700000000, 0).UTC()
+
+	history := NewMetricsHistory(10)
+	for i := 0; i < 4; i++ {
+		history.Record(SeriesTxThroughput, start.Add(time.Duration(i)*time.Second), float64(i))
+	}
+	require.NoError(t, history.Save(path))
+
+	loaded, err := LoadMetricsHistory(path, 2)
+	require.NoError(t, err)
+	samples := loaded.Query(nil, start, start.Add(time.Minute), 0)[SeriesTxThroughput]
+	assert.Equal(t, []float64{2, 3}, sampleValues(samples), "loading into a smaller store keeps the newest samples")
+
+	missing, err := LoadMetricsHistory(filepath.Join(t.TempDir(), "missing.json"), 2)
+	require.NoError(t, err)
+	assert.Empty(t, missing.Series())
+}
+
+func sampleValues(samples []MetricSample) []float64 {
+	values := make([]float64, len(samples))
+	for i, sample := range samples {
+		values[i] = sample.Value
+	}
+	return values
+}
@@ -3,8 +3,13 @@ package agglomerator
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/theaxiomverse/hydap-api/pkg/modules/base"
 	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
@@ -13,18 +18,24 @@ import (
 
 // ChainConfig represents the configuration for a single chain
 type ChainConfig struct {
-	ID       string `json:"id"`
-	Protocol string `json:"protocol"`
-	Endpoint string `json:"endpoint"`
+	ID        string   `json:"id"`
+	Protocol  string   `json:"protocol"`
+	Endpoint  string   `json:"endpoint"`
+	Endpoints []string `json:"endpoints"` // Failover endpoints
+	Zone      string   `json:"zone"`      // Privacy zone; empty for public chains
+
+	Capabilities ChainCapabilities `json:"capabilities"`
 }
 
 // ModuleConfig represents the module's configuration structure
 type ModuleConfig struct {
 	NodeID        string        `json:"nodeID"`
+	Version       string        `json:"version"`
 	VectorDims    int           `json:"vectorDims"`
 	SimThreshold  float64       `json:"simThreshold"`
 	EnabledChains []ChainConfig `json:"enabledChains"`
 	LogPath       string        `json:"logPath"`
+	LogLevel      string        `json:"logLevel"`
 
 	// P2P configuration
 	P2P struct {
@@ -32,6 +43,128 @@ type ModuleConfig struct {
 		Port              int    `json:"port"`
 		DiscoveryInterval string `json:"discoveryInterval"`
 		MaxPeers          int    `json:"maxPeers"`
+
+		// Peers dialled at startup, as "nodeID@host:port"
+		BootstrapPeers   []string `json:"bootstrapPeers"`
+		DisableDiscovery bool     `json:"disableDiscovery"`
+
+		// Payloads above chunkSize are split across messages
+		ChunkSize string `json:"chunkSize"`
+
+		Reputation struct {
+			DecayRate     float64 `json:"decayRate"`
+			DecayInterval string  `json:"decayInterval"`
+			BanThreshold  float64 `json:"banThreshold"`
+			HistorySize   int     `json:"historySize"`
+		} `json:"reputation"`
+
+		Replay struct {
+			CacheSize      int    `json:"cacheSize"`
+			MaxClockSkew   string `json:"maxClockSkew"`
+			SequenceWindow uint64 `json:"sequenceWindow"`
+			MaxSenders     int    `json:"maxSenders"`
+		} `json:"replay"`
+
+		Bandwidth struct {
+			PeerBytesPerSecond int64 `json:"peerBytesPerSecond"`
+			BurstBytes         int64 `json:"burstBytes"`
+		} `json:"bandwidth"`
+
+		// Broadcast paces chain registration gossip: registrations
+		// coalesce for interval, then go out in batches of up to maxBatch
+		// records, at most rate batches per second to each peer
+		Broadcast struct {
+			Interval string  `json:"interval"`
+			MaxBatch int     `json:"maxBatch"`
+			Rate     float64 `json:"rate"`
+			Burst    int     `json:"burst"`
+		} `json:"broadcast"`
+
+		// Bloom sizes the record filter advertised to peers so queries skip
+		// peers that hold nothing relevant
+		Bloom struct {
+			Bits     int    `json:"bits"`
+			Hashes   int    `json:"hashes"`
+			Interval string `json:"interval"`
+		} `json:"bloom"`
+
+		// Query bounds scatter-gather queries; peers missing peerTimeout
+		// lose slowPenalty reputation
+		Query struct {
+			PeerTimeout string  `json:"peerTimeout"`
+			Concurrency int     `json:"concurrency"`
+			SlowPenalty float64 `json:"slowPenalty"`
+			CacheSize   int     `json:"cacheSize"`
+
+			// Only the fanout best peers are asked; one that has not
+			// answered within the hedgeQuantile of recent answer times
+			// is hedged to the next best
+			Fanout        int     `json:"fanout"`
+			HedgeQuantile float64 `json:"hedgeQuantile"`
+		} `json:"query"`
+
+		// Writes sets whether chain registrations and transactions wait
+		// for quorum replicas, a majority if 0, to acknowledge them within
+		// timeout
+		Writes struct {
+			Consistency string `json:"consistency"`
+			Quorum      int    `json:"quorum"`
+			Timeout     string `json:"timeout"`
+		} `json:"writes"`
+
+		// Shards partitions the vector space into count shards, each held
+		// by replicas nodes, assigning records by hash of their ID or by
+		// protocol. Membership is checked every interval for a rebalance.
+		Shards struct {
+			Count    int    `json:"count"`
+			Strategy string `json:"strategy"`
+			Replicas int    `json:"replicas"`
+			Interval string `json:"interval"`
+		} `json:"shards"`
+
+		// Signature algorithms offered to peers; every supported one if empty
+		SignatureAlgorithms []string `json:"signatureAlgorithms"`
+
+		// Attestation is this node's CA-signed key bundle; once trust anchors
+		// are set, peers without an attestation from one are refused
+		Attestation struct {
+			File         string `json:"file"`
+			TrustAnchors []struct {
+				Name      string `json:"name"`
+				PublicKey string `json:"publicKey"`
+			} `json:"trustAnchors"`
+		} `json:"attestation"`
+
+		// KeyFile holds the node's signing key, written by "agglomerator
+		// attestation node-keygen". When set, every channel is encrypted after
+		// a Kyber key exchange and peers without keys are refused.
+		KeyFile string `json:"keyFile"`
+
+		// Admission is the cost a peer pays to join the peer set: a proof
+		// of work for its node ID and signing key, a place on the
+		// allowlist, or stake reported by a registered oracle
+		Admission struct {
+			Difficulty  int      `json:"difficulty"`
+			Allowlist   []string `json:"allowlist"`
+			StakeOracle string   `json:"stakeOracle"`
+			MinStake    float64  `json:"minStake"`
+		} `json:"admission"`
+
+		// PrivacyZones are the zones whose private chains this node may
+		// see and route to, each named with the base64 256-bit key its
+		// members share
+		PrivacyZones []struct {
+			Name string `json:"name"`
+			Key  string `json:"key"`
+		} `json:"privacyZones"`
+
+		// Transport selects how peers are reached; empty keeps delivery simulated
+		Transport struct {
+			Type     string `json:"type"`
+			CertFile string `json:"certFile"`
+			KeyFile  string `json:"keyFile"`
+			CAFile   string `json:"caFile"`
+		} `json:"transport"`
 	} `json:"p2p"`
 
 	// Protocol configurations
@@ -58,11 +191,20 @@ type ModuleConfig struct {
 		} `json:"dot"`
 	} `json:"protocols"`
 
-	// Vector space configuration
+	// Vector space configuration; chain and transaction vectors are grouped
+	// into at most clusters clusters, refit every updateInterval
 	VectorSpace struct {
 		Dimensions          int     `json:"dimensions"`
 		SimilarityThreshold float64 `json:"similarityThreshold"`
+		Clusters            int     `json:"clusters"`
+		CacheElements       int     `json:"cacheElements"`
+		QueryCache          int     `json:"queryCache"`
 		UpdateInterval      string  `json:"updateInterval"`
+
+		// Generators are expressions computing vector elements, keyed by
+		// protocol ID or "default"; protocols without one keep their
+		// built-in generator
+		Generators map[string]string `json:"generators"`
 	} `json:"vectorSpace"`
 
 	// Transaction configuration
@@ -71,13 +213,198 @@ type ModuleConfig struct {
 		ProcessingTimeout string `json:"processingTimeout"`
 		RetryAttempts     int    `json:"retryAttempts"`
 		RetryInterval     string `json:"retryInterval"`
+
+		// Size limits for Transaction.Data; larger inline payloads must be
+		// streamed to the upload endpoint
+		MaxPayloadSize    string `json:"maxPayloadSize"`
+		InlinePayloadSize string `json:"inlinePayloadSize"`
 	} `json:"transactions"`
 
+	// Transaction pool limits per chain; when a pool is full, policy either
+	// rejects new transactions or evicts the lowest priority and fee
+	Pool struct {
+		MaxSize int            `json:"maxSize"`
+		Policy  string         `json:"policy"`
+		Limits  map[string]int `json:"limits"` // maxSize overrides by chain ID
+	} `json:"pool"`
+
+	// Anomaly detection on transaction state vectors; flagged transactions
+	// are queued for review and, with hold, not routed until approved
+	Anomaly struct {
+		Enabled      bool    `json:"enabled"`
+		Clusters     int     `json:"clusters"`
+		Threshold    float64 `json:"threshold"`
+		MinSamples   int     `json:"minSamples"`
+		Hold         bool    `json:"hold"`
+		AlertWebhook string  `json:"alertWebhook"`
+	} `json:"anomaly"`
+
+	// Compliance rules evaluated before routing. Rules are reloaded from the
+	// stored configuration when its revision changes, checked every
+	// reloadInterval.
+	Policy struct {
+		Enabled        bool         `json:"enabled"`
+		DryRun         bool         `json:"dryRun"`
+		DefaultAction  string       `json:"defaultAction"`
+		ReloadInterval string       `json:"reloadInterval"`
+		Rules          []PolicyRule `json:"rules"`
+	} `json:"policy"`
+
+	// Assets valued in currency so transactions carry a normalized value.
+	// Prices are fetched from priceFeed.url, falling back to prices.
+	Assets struct {
+		Currency  string             `json:"currency"`
+		Registry  []Asset            `json:"registry"`
+		FeeAssets map[string]string  `json:"feeAssets"` // Symbol of the asset each chain's fees are paid in
+		Prices    map[string]float64 `json:"prices"`
+		PriceFeed struct {
+			URL string `json:"url"`
+			TTL string `json:"ttl"`
+		} `json:"priceFeed"`
+	} `json:"assets"`
+
+	// Chains imported from a chainlist-style JSON registry at url, such as
+	// https://chainid.network/chains.json, every interval. Only chainIds
+	// are imported, or every entry if empty, each registered as
+	// idPrefix+shortName under protocols[chainId], the entry's protocol or
+	// protocol (eth by default), with up to maxEndpoints RPC endpoints.
+	ChainRegistry struct {
+		URL          string            `json:"url"`
+		Interval     string            `json:"interval"`
+		ChainIDs     []int64           `json:"chainIds"`
+		Protocol     string            `json:"protocol"`
+		Protocols    map[string]string `json:"protocols"`
+		IDPrefix     string            `json:"idPrefix"`
+		MaxEndpoints int               `json:"maxEndpoints"`
+	} `json:"chainRegistry"`
+
+	// Read-only replica mode: the node follows the primary's event log and
+	// serves queries, refusing transactions and other writes
+	Replica struct {
+		Enabled      bool   `json:"enabled"`
+		Primary      string `json:"primary"`
+		PollInterval string `json:"pollInterval"`
+	} `json:"replica"`
+
+	// Roles the node serves: router (routes transactions through chain
+	// adapters), storage (holds replicated records) and gateway (takes
+	// client transactions, relaying them to routers); empty serves all
+	// three. apiURL is this node's API, advertised so gateways can relay
+	// transactions to it.
+	Roles  []string `json:"roles"`
+	APIURL string   `json:"apiURL"`
+
+	// Reconciliation of completed transactions against confirmations from
+	// chain adapters, producing a daily report; requires storage.path
+	Reconciliation struct {
+		Enabled          bool   `json:"enabled"`
+		Interval         string `json:"interval"`
+		MinConfirmations int    `json:"minConfirmations"`
+		Timeout          string `json:"timeout"`
+	} `json:"reconciliation"`
+
+	// Background health probing of chain endpoints
+	EndpointHealth struct {
+		Interval         string `json:"interval"`
+		Timeout          string `json:"timeout"`
+		FailureThreshold int    `json:"failureThreshold"`
+	} `json:"endpointHealth"`
+
+	// How often scheduled chain maintenance windows are checked for having
+	// begun, so the chains' pools are drained
+	Maintenance struct {
+		CheckInterval string `json:"checkInterval"`
+	} `json:"maintenance"`
+
+	// Warm-up of chains registered while the node runs: each is offered
+	// fraction of the scored routes for at least warmUp, then promoted or
+	// quarantined on its submission success rate and health. Warm-ups are
+	// checked for having ended every checkInterval.
+	Canary struct {
+		Enabled        bool    `json:"enabled"`
+		Fraction       float64 `json:"fraction"`
+		WarmUp         string  `json:"warmUp"`
+		MinSamples     int     `json:"minSamples"`
+		MinSuccessRate float64 `json:"minSuccessRate"`
+		MinHealth      float64 `json:"minHealth"`
+		CheckInterval  string  `json:"checkInterval"`
+	} `json:"canary"`
+
+	// Pre-flight simulation of transactions on their destination's network
+	// through adapters that can dry-run them; a destination whose
+	// simulation fails is not routed to. With reroute, the
+	// highest-scoring destinations whose simulation passes are tried
+	// instead.
+	Simulation struct {
+		Enabled bool   `json:"enabled"`
+		Timeout string `json:"timeout"`
+		Reroute bool   `json:"reroute"`
+	} `json:"simulation"`
+
+	// Sponsored execution: the wallet configured for a chain pays the fees
+	// there of transactions from tenants (meta.tenant) with a budget, in
+	// assets.currency per period. Tenants not listed get defaultBudget.
+	Sponsors struct {
+		Enabled       bool               `json:"enabled"`
+		Wallets       map[string]string  `json:"wallets"`
+		Budgets       map[string]float64 `json:"budgets"`
+		DefaultBudget float64            `json:"defaultBudget"`
+		Period        string             `json:"period"`
+	} `json:"sponsors"`
+
+	// Warming of routes and fee estimates ahead of the traffic predicted for
+	// each chain pair by hour of the week
+	PreRouting struct {
+		Enabled     bool    `json:"enabled"`
+		Interval    string  `json:"interval"`
+		Lead        string  `json:"lead"`
+		MinRate     float64 `json:"minRate"`
+		QuietPeriod string  `json:"quietPeriod"`
+	} `json:"preRouting"`
+
+	// Shedding of low-priority transactions, and pausing of replication
+	// ingest, while queue depth, latency or memory is over its limit
+	Overload struct {
+		Enabled       bool   `json:"enabled"`
+		MaxInFlight   int    `json:"maxInFlight"`
+		MaxP99Latency string `json:"maxP99Latency"`
+		MaxHeap       string `json:"maxHeap"`
+		MinPriority   int    `json:"minPriority"`
+		CheckInterval string `json:"checkInterval"`
+	} `json:"overload"`
+
+	// Transaction pool compaction configuration
+	Compaction struct {
+		Interval         string `json:"interval"`
+		MaxAge           string `json:"maxAge"`
+		Dimensions       int    `json:"dimensions"`
+		MaxArchiveBlocks int    `json:"maxArchiveBlocks"`
+	} `json:"compaction"`
+
+	// Garbage collection configuration; retention maps store names to TTLs
+	GC struct {
+		Interval  string            `json:"interval"`
+		Retention map[string]string `json:"retention"`
+	} `json:"gc"`
+
+	// Blob store for payloads above the inline size; path defaults to
+	// storage.path/blobs
+	Blobs struct {
+		Path      string `json:"path"`
+		Replicate bool   `json:"replicate"`
+	} `json:"blobs"`
+
 	// Storage configuration
 	Storage struct {
 		Path           string `json:"path"`
 		MaxSize        string `json:"maxSize"`
 		BackupInterval string `json:"backupInterval"`
+
+		// Tiering demotes routing index records to disk past a memory budget
+		Tiering struct {
+			Enabled      bool   `json:"enabled"`
+			MemoryBudget string `json:"memoryBudget"`
+		} `json:"tiering"`
 	} `json:"storage"`
 
 	// Metrics configuration
@@ -107,15 +434,253 @@ func (m *AgglomeratorModule) Initialize() error {
 		m.state = base.StateError
 		return fmt.Errorf("failed to parse config: %w", err)
 	}
+	if errs := moduleConfig.Validate(); len(errs) > 0 {
+		m.state = base.StateError
+		return fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
 	m.config = &moduleConfig
 
+	roles, err := ParseNodeRoles(moduleConfig.Roles)
+	if err != nil {
+		m.state = base.StateError
+		return err
+	}
+	m.mu.Lock()
+	m.roles = roles
+	m.mu.Unlock()
+
+	// Chains registered from here on take the configured generators
+	if err := SetGenerators(moduleConfig.VectorSpace.Generators); err != nil {
+		m.state = base.StateError
+		return err
+	}
+
+	limits, err := parsePayloadLimits(&moduleConfig)
+	if err != nil {
+		m.state = base.StateError
+		return err
+	}
+	blobs, err := newBlobStore(&moduleConfig)
+	if err != nil {
+		m.state = base.StateError
+		return err
+	}
+
+	var txStore *TransactionStore
+	var eventStore *EventStore
+	if moduleConfig.Storage.Path != "" {
+		txStore, err = NewTransactionStore(filepath.Join(moduleConfig.Storage.Path, "transactions.db"))
+		if err != nil {
+			m.state = base.StateError
+			return err
+		}
+		// Replicas rebuild their log from the primary on each start
+		if !moduleConfig.Replica.Enabled {
+			eventStore, err = NewEventStore(filepath.Join(moduleConfig.Storage.Path, "events.db"))
+			if err != nil {
+				m.state = base.StateError
+				return err
+			}
+		}
+	}
+	events, err := NewEventLog(eventStore)
+	if err != nil {
+		m.state = base.StateError
+		return err
+	}
+
+	m.mu.Lock()
+	m.limits = limits
+	m.blobs = blobs
+	m.txStore = txStore
+	m.eventStore = eventStore
+	m.mu.Unlock()
+
 	// Initialize agglomerator
 	aggConfig := AgglomeratorConfig{
-		NodeID:       moduleConfig.NodeID,
-		VectorDims:   moduleConfig.VectorDims,
-		SimThreshold: moduleConfig.SimThreshold,
+		NodeID:        moduleConfig.NodeID,
+		VectorDims:    moduleConfig.VectorDims,
+		SimThreshold:  moduleConfig.SimThreshold,
+		CompareDims:   moduleCompareDims(&moduleConfig),
+		CacheElements: moduleConfig.VectorSpace.CacheElements,
+		QueryCache:    moduleConfig.VectorSpace.QueryCache,
+		Pool: PoolConfig{
+			MaxSize: moduleConfig.Pool.MaxSize,
+			Policy:  moduleConfig.Pool.Policy,
+			Limits:  moduleConfig.Pool.Limits,
+		},
+		Clustering: vectors.ClusterConfig{
+			MaxClusters: moduleConfig.VectorSpace.Clusters,
+			Threshold:   moduleConfig.VectorSpace.SimilarityThreshold,
+		},
+	}
+	if moduleConfig.P2P.Port > 0 {
+		reputationConfig, err := parseReputationConfig(&moduleConfig)
+		if err != nil {
+			m.state = base.StateError
+			return err
+		}
+		replayConfig, err := parseReplayConfig(&moduleConfig)
+		if err != nil {
+			m.state = base.StateError
+			return err
+		}
+		bloomConfig, err := parseBloomConfig(&moduleConfig)
+		if err != nil {
+			m.state = base.StateError
+			return err
+		}
+		queryConfig, err := parseQueryConfig(&moduleConfig)
+		if err != nil {
+			m.state = base.StateError
+			return err
+		}
+		writeConfig, err := parseWriteConfig(&moduleConfig)
+		if err != nil {
+			m.state = base.StateError
+			return err
+		}
+		shardConfig, err := parseShardConfig(&moduleConfig)
+		if err != nil {
+			m.state = base.StateError
+			return err
+		}
+		broadcastConfig, err := parseBroadcastConfig(&moduleConfig)
+		if err != nil {
+			m.state = base.StateError
+			return err
+		}
+		node := NewP2PInfiniteVectorNode(moduleConfig.P2P.Address, moduleConfig.P2P.Port)
+		if moduleConfig.NodeID != "" {
+			node.NodeID = moduleConfig.NodeID
+		}
+		node.Reputation().SetConfig(reputationConfig)
+		node.ReplayGuard().SetConfig(replayConfig)
+		node.SetBloomConfig(bloomConfig)
+		node.SetQueryConfig(queryConfig)
+		node.SetWriteConfig(writeConfig)
+		node.SetShardConfig(shardConfig)
+		node.SetRoles(RoleConfig{Roles: roles, APIURL: moduleConfig.APIURL})
+		node.Broadcaster().SetConfig(broadcastConfig)
+		node.UseFlags(m.configManager.Flags())
+		node.Bandwidth().SetConfig(BandwidthConfig{
+			PeerBytesPerSecond: moduleConfig.P2P.Bandwidth.PeerBytesPerSecond,
+			BurstBytes:         moduleConfig.P2P.Bandwidth.BurstBytes,
+		})
+		node.SetChunkSize(limits.ChunkSize)
+		if blobs != nil {
+			node.UseBlobStore(blobs)
+		}
+		node.Reassembler().SetMaxPayload(limits.MaxSize)
+		if moduleConfig.P2P.DisableDiscovery {
+			node.DisableDiscovery()
+		}
+		for _, peer := range moduleConfig.P2P.BootstrapPeers {
+			if err := node.AddBootstrapPeer(peer); err != nil {
+				m.state = base.StateError
+				return err
+			}
+		}
+
+		if algorithms := moduleConfig.P2P.SignatureAlgorithms; len(algorithms) > 0 {
+			if err := node.SetSignatureAlgorithms(algorithms); err != nil {
+				m.state = base.StateError
+				return err
+			}
+		}
+
+		if attestationConfig := moduleConfig.P2P.Attestation; attestationConfig.File != "" {
+			attestation, err := LoadAttestation(attestationConfig.File)
+			if err != nil {
+				m.state = base.StateError
+				return err
+			}
+			node.SetAttestation(attestation)
+		}
+		for _, anchor := range moduleConfig.P2P.Attestation.TrustAnchors {
+			if _, err := node.TrustAnchors().Add(anchor.Name, anchor.PublicKey); err != nil {
+				m.state = base.StateError
+				return err
+			}
+		}
+
+		admission := moduleConfig.P2P.Admission
+		if err := node.SetAdmission(AdmissionConfig{
+			Difficulty:  admission.Difficulty,
+			Allowlist:   admission.Allowlist,
+			StakeOracle: admission.StakeOracle,
+			MinStake:    admission.MinStake,
+		}); err != nil {
+			m.state = base.StateError
+			return err
+		}
+
+		for _, zone := range moduleConfig.P2P.PrivacyZones {
+			if err := node.Zones().Add(zone.Name, zone.Key); err != nil {
+				m.state = base.StateError
+				return err
+			}
+		}
+
+		if keyFile := moduleConfig.P2P.KeyFile; keyFile != "" {
+			keys, err := LoadNodeKeys(keyFile)
+			if err != nil {
+				m.state = base.StateError
+				return fmt.Errorf("failed to load node keys: %w", err)
+			}
+			// Handshakes offer only the algorithm the node signs with
+			if algorithms := node.localAlgorithms(); !contains(algorithms, keys.SigningAlgorithm()) {
+				m.state = base.StateError
+				return fmt.Errorf("node keys sign with %s, which is not among the signature algorithms %v", keys.SigningAlgorithm(), algorithms)
+			}
+			node.UseKeys(keys)
+		}
+
+		if transportConfig := moduleConfig.P2P.Transport; transportConfig.Type != "" {
+			transport, err := NewTransport(TransportConfig{
+				Type:     transportConfig.Type,
+				CertFile: transportConfig.CertFile,
+				KeyFile:  transportConfig.KeyFile,
+				CAFile:   transportConfig.CAFile,
+			})
+			if err != nil {
+				m.state = base.StateError
+				return err
+			}
+			if err := node.UseTransport(transport); err != nil {
+				m.state = base.StateError
+				return fmt.Errorf("failed to start %s transport: %w", transport.Name(), err)
+			}
+		}
+
+		m.p2p = NewP2PAgglomeratorWithNode(aggConfig, node)
+		m.agglomerator = m.p2p.Agglomerator
+	} else {
+		m.agglomerator = NewAgglomerator(aggConfig)
+	}
+
+	m.agglomerator.SetEventLog(events)
+
+	if moduleConfig.Storage.Tiering.Enabled {
+		budget, err := parseByteSize(moduleConfig.Storage.Tiering.MemoryBudget)
+		if err != nil {
+			m.state = base.StateError
+			return fmt.Errorf("invalid storage tiering memoryBudget: %w", err)
+		}
+		coldStore, err := NewColdVectorStore(filepath.Join(moduleConfig.Storage.Path, "vectors.db"))
+		if err != nil {
+			m.state = base.StateError
+			return err
+		}
+		m.agglomerator.EnableTiering(coldStore, budget)
+		m.mu.Lock()
+		m.coldStore = coldStore
+		m.mu.Unlock()
+	}
+
+	if blobs != nil {
+		blobs.SetReferenced(m.blobRefs)
 	}
-	m.agglomerator = NewAgglomerator(aggConfig)
 
 	// Register metrics
 	m.metrics.RegisterModule(m.Name())
@@ -123,116 +688,1876 @@ func (m *AgglomeratorModule) Initialize() error {
 	// Initialize chains
 	for _, chainID := range moduleConfig.EnabledChains {
 		chain := &Chain{
-			ID:       chainID.ID,
-			Protocol: determineProtocol(chainID.ID),
+			ID:           chainID.ID,
+			Endpoint:     chainID.Endpoint,
+			Endpoints:    chainID.Endpoints,
+			Protocol:     chainID.Protocol,
+			Zone:         chainID.Zone,
+			Capabilities: chainID.Capabilities,
 			StateVector: vectors.InfiniteVector{
-				Generator: getDefaultGenerator(chainID.ID),
+				Generator: getProtocolGenerator(chainID.Protocol),
 			},
 		}
 		if err := m.agglomerator.RegisterChain(chain); err != nil {
-			m.logger.Log(m.Name(), "ERROR", fmt.Sprintf("Failed to register chain %s: %v", chainID, err))
+			m.logger.Log(m.Name(), "ERROR", fmt.Sprintf("Failed to register chain %s: %v", chainID.ID, err))
 			m.state = base.StateError
 			return err
 		}
-		m.logger.Log(m.Name(), "INFO", fmt.Sprintf("Registered chain: %s", chainID))
+		m.logger.Log(m.Name(), "INFO", fmt.Sprintf("Registered chain: %s", chainID.ID))
+	}
+	// Configured chains are established; chains registered from now on warm
+	// up. Replicas take their chains from the primary and route nothing.
+	if moduleConfig.Canary.Enabled && roles.Has(RoleRouter) && !moduleConfig.Replica.Enabled {
+		canaryConfig, err := parseCanaryConfig(&moduleConfig)
+		if err != nil {
+			m.state = base.StateError
+			return err
+		}
+		if err := m.agglomerator.StartCanaries(canaryConfig); err != nil {
+			m.state = base.StateError
+			return err
+		}
+	}
+	if err := m.restoreChains(&moduleConfig); err != nil {
+		m.state = base.StateError
+		return err
 	}
 
-	m.state = base.StateRunning
-	return nil
-}
-
-type AgglomeratorModule struct {
-	base.BaseModule
-	agglomerator  *Agglomerator
-	config        *ModuleConfig
-	configManager *core.ConfigManager
-	metrics       *core.MetricsExporter
-	logger        *core.ModuleLogger
-	txManager     *core.TransactionManager
-	mu            sync.RWMutex
-	moduleState   base.ModuleState // renamed from state to moduleState
-	state         base.ModuleState
-}
-
-// GetAgglomerator returns the underlying agglomerator instance
-func (m *AgglomeratorModule) GetAgglomerator() *Agglomerator {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	return m.agglomerator
-}
-
-// GetConfig returns the current module configuration
-func (m *AgglomeratorModule) GetConfig() *ModuleConfig {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	return m.config
-}
-
-func NewAgglomeratorModule(
-	configManager *core.ConfigManager,
-	metrics *core.MetricsExporter,
-	logger *core.ModuleLogger,
-) *AgglomeratorModule {
-	metadata := base.NewModuleMetadata(
-		"blockchain_agglomerator",
-		"1.0.0",
-		"Blockchain Agglomerator with Vector-based Chain Analysis",
-		"HyDAP Team",
-		"MIT",
-	)
+	if moduleConfig.Replica.Enabled {
+		replicaConfig, err := parseReplicaConfig(&moduleConfig)
+		if err != nil {
+			m.state = base.StateError
+			return err
+		}
+		replica := NewReplicaFollower(replicaConfig, m.agglomerator, txStore)
+		m.mu.Lock()
+		m.replica = replica
+		m.mu.Unlock()
+		replica.Start()
+		m.logger.Log(m.Name(), "INFO", fmt.Sprintf("Running as a read-only replica of %s", replicaConfig.Primary))
+	}
 
-	baseModule := base.CreateNewModule(metadata, nil).(*base.BaseModule)
+	healthConfig, err := parseEndpointHealthConfig(&moduleConfig)
+	if err != nil {
+		m.state = base.StateError
+		return err
+	}
+	// Only routers call chain adapters, so only they probe endpoints
+	if roles.Has(RoleRouter) {
+		if err := m.agglomerator.StartHealthChecks(healthConfig); err != nil {
+			m.state = base.StateError
+			return err
+		}
+	}
 
-	return &AgglomeratorModule{
-		BaseModule:    *baseModule,
-		configManager: configManager,
-		metrics:       metrics,
-		logger:        logger,
-		txManager: &core.TransactionManager{
-			Txns: make(map[string]*core.Transaction),
-		},
-		moduleState: base.StateUninitialized,
+	maintenanceInterval := DefaultMaintenanceCheckInterval
+	if interval := moduleConfig.Maintenance.CheckInterval; interval != "" {
+		maintenanceInterval, err = time.ParseDuration(interval)
+		if err != nil || maintenanceInterval <= 0 {
+			m.state = base.StateError
+			return fmt.Errorf("invalid maintenance checkInterval: %s", interval)
+		}
+	}
+	if err := m.agglomerator.StartMaintenanceChecks(maintenanceInterval); err != nil {
+		m.state = base.StateError
+		return err
 	}
-}
 
-// State returns the current module state
-func (m *AgglomeratorModule) GetState() base.ModuleState {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	return m.moduleState
-}
+	if moduleConfig.Compaction.Interval != "" {
+		compactionConfig, err := parseCompactionConfig(&moduleConfig)
+		if err != nil {
+			m.state = base.StateError
+			return err
+		}
+		if err := m.agglomerator.StartCompaction(compactionConfig); err != nil {
+			m.state = base.StateError
+			return err
+		}
+	}
 
-// SetState is a helper method to update module state
-func (m *AgglomeratorModule) SetState(state base.ModuleState) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.moduleState = state
-}
+	if interval := moduleConfig.VectorSpace.UpdateInterval; interval != "" {
+		refitInterval, err := time.ParseDuration(interval)
+		if err != nil {
+			m.state = base.StateError
+			return fmt.Errorf("invalid vectorSpace updateInterval: %w", err)
+		}
+		if err := m.agglomerator.StartClusterRefit(refitInterval); err != nil {
+			m.state = base.StateError
+			return err
+		}
+	}
 
-// ProcessTransaction handles a cross-chain transaction
-func (m *AgglomeratorModule) ProcessTransaction(tx *Transaction) error {
-	// Start transaction tracking
-	txn := m.txManager.Begin(m.Name(), "process_transaction")
-	defer func() {
-		if txn.Status == "pending" {
-			txn.Status = "completed"
+	if moduleConfig.Overload.Enabled {
+		overloadConfig, err := parseOverloadConfig(&moduleConfig)
+		if err != nil {
+			m.state = base.StateError
+			return err
 		}
-	}()
+		shedder, err := NewLoadShedder(overloadConfig)
+		if err != nil {
+			m.state = base.StateError
+			return err
+		}
+		shedder.OnChange(func(overloaded bool) {
+			if p2p := m.GetP2P(); p2p != nil {
+				p2p.p2pNode.PauseIngest(overloaded)
+			}
+			if overloaded {
+				m.logger.Log(m.Name(), "WARN", "Node overloaded, shedding low-priority transactions")
+			} else {
+				m.logger.Log(m.Name(), "INFO", "Node recovered from overload")
+			}
+		})
+		shedder.Start()
+		m.mu.Lock()
+		m.shedder = shedder
+		m.mu.Unlock()
+	}
 
-	m.logger.Log(m.Name(), "INFO", fmt.Sprintf("Processing transaction: %s", txn.ID))
+	if moduleConfig.PreRouting.Enabled {
+		preRoutingConfig, err := parsePreRoutingConfig(&moduleConfig)
+		if err != nil {
+			m.state = base.StateError
+			return err
+		}
+		if err := m.agglomerator.StartPreRouting(preRoutingConfig); err != nil {
+			m.state = base.StateError
+			return err
+		}
+	}
 
-	if m.GetState() != base.StateRunning {
-		txn.Status = "failed"
-		return fmt.Errorf("module not in running state: %s", m.GetState())
+	if moduleConfig.Anomaly.Enabled {
+		detector := NewAnomalyDetector(parseAnomalyConfig(&moduleConfig))
+		detector.Train(m.agglomerator.TransactionRecords())
+		m.mu.Lock()
+		m.detector = detector
+		m.anomalies = NewAnomalyQueue()
+		m.mu.Unlock()
 	}
 
-	err := m.agglomerator.ProcessTransaction(context.Background(), tx)
+	assets, err := newAssetRegistry(&moduleConfig)
 	if err != nil {
-		txn.Status = "failed"
-		m.logger.Log(m.Name(), "ERROR", fmt.Sprintf("Transaction failed: %v", err))
+		m.state = base.StateError
 		return err
 	}
+	m.mu.Lock()
+	m.assets = assets
+	m.mu.Unlock()
 
-	m.logger.Log(m.Name(), "INFO", fmt.Sprintf("Transaction completed: %s", txn.ID))
-	return nil
+	simulation, err := parseSimulationConfig(&moduleConfig)
+	if err != nil {
+		m.state = base.StateError
+		return err
+	}
+	m.mu.Lock()
+	m.simulation = simulation
+	m.mu.Unlock()
+
+	if moduleConfig.Sponsors.Enabled {
+		sponsorConfig, err := parseSponsorConfig(&moduleConfig)
+		if err != nil {
+			m.state = base.StateError
+			return err
+		}
+		ledger := NewSponsorLedger(sponsorConfig, assets.Currency(), m.agglomerator.clock)
+		events, err := m.agglomerator.EventLog().Events(0, 0)
+		if err == nil {
+			err = ledger.restore(events)
+		}
+		if err != nil {
+			m.state = base.StateError
+			return fmt.Errorf("failed to restore sponsor spending: %w", err)
+		}
+		m.mu.Lock()
+		m.sponsors = ledger
+		m.mu.Unlock()
+	}
+
+	if moduleConfig.Reconciliation.Enabled {
+		reconcileConfig, err := parseReconciliationConfig(&moduleConfig)
+		if err != nil {
+			m.state = base.StateError
+			return err
+		}
+		stop := make(chan struct{})
+		m.mu.Lock()
+		m.reconcile = reconcileConfig
+		m.reconcileStop = stop
+		m.mu.Unlock()
+		go m.runReconciliation(reconcileConfig.Interval, stop)
+	}
+
+	if moduleConfig.Policy.Enabled {
+		policy, err := NewPolicyEngine(parsePolicyConfig(&moduleConfig))
+		if err != nil {
+			m.state = base.StateError
+			return fmt.Errorf("invalid policy: %w", err)
+		}
+		m.mu.Lock()
+		m.policy = policy
+		m.mu.Unlock()
+
+		if interval := moduleConfig.Policy.ReloadInterval; interval != "" {
+			reloadInterval, err := parseDuration(interval)
+			if err != nil {
+				m.state = base.StateError
+				return fmt.Errorf("invalid policy reloadInterval: %w", err)
+			}
+			stop := make(chan struct{})
+			m.mu.Lock()
+			m.policyStop = stop
+			m.mu.Unlock()
+			go m.runPolicyReload(reloadInterval, stop)
+		}
+	}
+
+	if moduleConfig.Metrics.Enabled && moduleConfig.Metrics.Interval != "" {
+		historyConfig, err := parseMetricsHistoryConfig(&moduleConfig)
+		if err != nil {
+			m.state = base.StateError
+			return err
+		}
+		sampler, err := newMetricsSampler(m, historyConfig)
+		if err != nil {
+			m.state = base.StateError
+			return err
+		}
+		m.mu.Lock()
+		m.sampler = sampler
+		m.mu.Unlock()
+		sampler.start()
+	}
+
+	if moduleConfig.GC.Interval != "" {
+		gc, err := m.newGarbageCollector(&moduleConfig)
+		if err != nil {
+			m.state = base.StateError
+			return err
+		}
+		m.gc = gc
+		m.gc.Start()
+	}
+
+	// Replicas take their chains from the primary
+	if moduleConfig.ChainRegistry.URL != "" && m.GetReplica() == nil {
+		registryConfig, err := parseChainRegistryConfig(&moduleConfig)
+		if err != nil {
+			m.state = base.StateError
+			return err
+		}
+		importer := NewChainImporter(m, registryConfig)
+		m.mu.Lock()
+		m.chainImporter = importer
+		m.mu.Unlock()
+		importer.start()
+	}
+
+	m.mu.Lock()
+	desired := m.desiredState
+	if desired != nil {
+		m.desiredStop = make(chan struct{})
+		go m.runDesiredState(desired, m.desiredStop)
+	}
+	m.mu.Unlock()
+
+	m.state = base.StateRunning
+	// Transactions are only processed once GetState reports running
+	m.SetState(base.StateRunning)
+	return nil
+}
+
+// Terminate implements Module interface
+func (m *AgglomeratorModule) Terminate() error {
+	if agg := m.GetAgglomerator(); agg != nil {
+		agg.StopCompaction()
+		agg.StopHealthChecks()
+		agg.StopMaintenanceChecks()
+		agg.StopCanaries()
+		agg.StopClusterRefit()
+		agg.StopPreRouting()
+	}
+	if gc := m.GetGC(); gc != nil {
+		gc.Stop()
+	}
+	if shedder := m.GetLoadShedder(); shedder != nil {
+		shedder.Stop()
+	}
+	m.mu.Lock()
+	if m.policyStop != nil {
+		close(m.policyStop)
+		m.policyStop = nil
+	}
+	if m.reconcileStop != nil {
+		close(m.reconcileStop)
+		m.reconcileStop = nil
+	}
+	if m.desiredStop != nil {
+		close(m.desiredStop)
+		m.desiredStop = nil
+	}
+	if m.chainImporter != nil {
+		m.chainImporter.Stop()
+		m.chainImporter = nil
+	}
+	replica := m.replica
+	m.mu.Unlock()
+	if replica != nil {
+		replica.Stop()
+	}
+	if sampler := m.getSampler(); sampler != nil {
+		if err := sampler.close(); err != nil {
+			m.logger.Log(m.Name(), "ERROR", fmt.Sprintf("Failed to save metrics history: %v", err))
+		}
+		m.mu.Lock()
+		m.sampler = nil
+		m.mu.Unlock()
+	}
+	if p2p := m.GetP2P(); p2p != nil {
+		if err := p2p.Stop(context.Background()); err != nil {
+			return fmt.Errorf("failed to stop P2P node: %w", err)
+		}
+	}
+	if store := m.GetTransactionStore(); store != nil {
+		if err := store.Close(); err != nil {
+			return fmt.Errorf("failed to close transaction store: %w", err)
+		}
+	}
+	m.mu.RLock()
+	eventStore, coldStore := m.eventStore, m.coldStore
+	m.mu.RUnlock()
+	if eventStore != nil {
+		if err := eventStore.Close(); err != nil {
+			return fmt.Errorf("failed to close event store: %w", err)
+		}
+	}
+	if coldStore != nil {
+		if err := coldStore.Close(); err != nil {
+			return fmt.Errorf("failed to close cold vector store: %w", err)
+		}
+	}
+	m.SetState(base.StateUninitialized)
+	return m.BaseModule.Terminate()
+}
+
+// newGarbageCollector builds a collector with the retention policies from config
+func (m *AgglomeratorModule) newGarbageCollector(moduleConfig *ModuleConfig) (*core.GarbageCollector, error) {
+	interval, err := time.ParseDuration(moduleConfig.GC.Interval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gc interval: %w", err)
+	}
+
+	stores := map[string]core.Collectable{
+		"transactions": m.txManager,
+		"vectors":      core.CollectableFunc(m.agglomerator.CollectRecords),
+	}
+	if m.p2p != nil {
+		stores["peerChains"] = core.CollectableFunc(m.p2p.CollectPeerChains)
+		stores["p2pRecords"] = m.p2p.p2pNode.localDatabase
+		stores["p2pChunks"] = m.p2p.p2pNode.Reassembler()
+	}
+	if m.blobs != nil {
+		stores["blobs"] = m.blobs
+	}
+	if m.txStore != nil {
+		stores["transactionHistory"] = m.txStore
+	}
+	stores["routes"] = m.routes
+	if m.anomalies != nil {
+		stores["anomalies"] = m.anomalies
+	}
+
+	gc := core.NewGarbageCollector(m.Name(), interval, m.metrics)
+	for store, ttl := range moduleConfig.GC.Retention {
+		collectable, exists := stores[store]
+		if !exists {
+			return nil, fmt.Errorf("unknown gc store: %s", store)
+		}
+		duration, err := time.ParseDuration(ttl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retention for %s: %w", store, err)
+		}
+		if err := gc.Register(collectable, core.RetentionPolicy{Store: store, TTL: duration}); err != nil {
+			return nil, err
+		}
+	}
+	return gc, nil
+}
+
+// moduleCompareDims returns the vector dimensions compared for similarity:
+// vectorSpace.dimensions, else vectorDims, else DefaultCompareDims
+func moduleCompareDims(moduleConfig *ModuleConfig) int {
+	if dims := moduleConfig.VectorSpace.Dimensions; dims > 0 {
+		return dims
+	}
+	if moduleConfig.VectorDims > 0 {
+		return moduleConfig.VectorDims
+	}
+	return DefaultCompareDims
+}
+
+// parseAnomalyConfig applies the configured anomaly settings over the
+// defaults; vectors are compared over the module's compare dimensions
+func parseAnomalyConfig(moduleConfig *ModuleConfig) AnomalyConfig {
+	config := DefaultAnomalyConfig()
+	anomaly := moduleConfig.Anomaly
+
+	if anomaly.Clusters > 0 {
+		config.Clusters = anomaly.Clusters
+	}
+	if anomaly.Threshold != 0 {
+		config.Threshold = anomaly.Threshold
+	}
+	if anomaly.MinSamples > 0 {
+		config.MinSamples = anomaly.MinSamples
+	}
+	config.Dimensions = moduleCompareDims(moduleConfig)
+	config.Hold = anomaly.Hold
+	config.AlertWebhook = anomaly.AlertWebhook
+	return config
+}
+
+// parseMetricsHistoryConfig samples every metrics.interval and keeps
+// metrics.retention of history, 7 days by default. History is persisted under
+// storage.path when it is set.
+func parseMetricsHistoryConfig(moduleConfig *ModuleConfig) (MetricsHistoryConfig, error) {
+	config := MetricsHistoryConfig{Retention: 7 * 24 * time.Hour}
+
+	interval, err := parseDuration(moduleConfig.Metrics.Interval)
+	if err != nil || interval <= 0 {
+		return config, fmt.Errorf("invalid metrics interval: %s", moduleConfig.Metrics.Interval)
+	}
+	config.Interval = interval
+
+	if retention := moduleConfig.Metrics.Retention; retention != "" {
+		config.Retention, err = parseDuration(retention)
+		if err != nil || config.Retention < interval {
+			return config, fmt.Errorf("invalid metrics retention: %s", retention)
+		}
+	}
+	if moduleConfig.Storage.Path != "" {
+		config.Path = filepath.Join(moduleConfig.Storage.Path, "metrics_history.json")
+	}
+	return config, nil
+}
+
+func parseReputationConfig(moduleConfig *ModuleConfig) (ReputationConfig, error) {
+	config := DefaultReputationConfig()
+	reputation := moduleConfig.P2P.Reputation
+
+	if reputation.DecayRate != 0 {
+		if reputation.DecayRate < 0 || reputation.DecayRate > 1 {
+			return config, fmt.Errorf("invalid reputation decayRate: %v", reputation.DecayRate)
+		}
+		config.DecayRate = reputation.DecayRate
+	}
+	if reputation.DecayInterval != "" {
+		interval, err := time.ParseDuration(reputation.DecayInterval)
+		if err != nil || interval <= 0 {
+			return config, fmt.Errorf("invalid reputation decayInterval: %s", reputation.DecayInterval)
+		}
+		config.DecayInterval = interval
+	}
+	if reputation.HistorySize > 0 {
+		config.HistorySize = reputation.HistorySize
+	}
+	config.BanThreshold = reputation.BanThreshold
+
+	return config, nil
+}
+
+func parseReplayConfig(moduleConfig *ModuleConfig) (ReplayConfig, error) {
+	config := DefaultReplayConfig()
+	replay := moduleConfig.P2P.Replay
+
+	if replay.CacheSize > 0 {
+		config.CacheSize = replay.CacheSize
+	}
+	if replay.MaxClockSkew != "" {
+		skew, err := time.ParseDuration(replay.MaxClockSkew)
+		if err != nil || skew <= 0 {
+			return config, fmt.Errorf("invalid replay maxClockSkew: %s", replay.MaxClockSkew)
+		}
+		config.MaxClockSkew = skew
+	}
+	if replay.SequenceWindow > 0 {
+		config.SequenceWindow = replay.SequenceWindow
+	}
+	if replay.MaxSenders > 0 {
+		config.MaxSenders = replay.MaxSenders
+	}
+
+	return config, nil
+}
+
+func parseBloomConfig(moduleConfig *ModuleConfig) (BloomConfig, error) {
+	config := DefaultBloomConfig()
+	bloom := moduleConfig.P2P.Bloom
+
+	if bloom.Bits > 0 {
+		config.Bits = bloom.Bits
+	}
+	if bloom.Hashes > 0 {
+		config.Hashes = bloom.Hashes
+	}
+	if bloom.Interval != "" {
+		interval, err := time.ParseDuration(bloom.Interval)
+		if err != nil || interval <= 0 {
+			return config, fmt.Errorf("invalid bloom interval: %s", bloom.Interval)
+		}
+		config.Interval = interval
+	}
+
+	return config, nil
+}
+
+func parseBroadcastConfig(moduleConfig *ModuleConfig) (BroadcastConfig, error) {
+	config := DefaultBroadcastConfig()
+	broadcast := moduleConfig.P2P.Broadcast
+
+	if broadcast.Interval != "" {
+		interval, err := time.ParseDuration(broadcast.Interval)
+		if err != nil || interval <= 0 {
+			return config, fmt.Errorf("invalid broadcast interval: %s", broadcast.Interval)
+		}
+		config.Interval = interval
+	}
+	if broadcast.MaxBatch > 0 {
+		config.MaxBatch = broadcast.MaxBatch
+	}
+	if broadcast.Rate < 0 {
+		return config, fmt.Errorf("invalid broadcast rate: %v", broadcast.Rate)
+	}
+	if broadcast.Rate > 0 {
+		config.Rate = broadcast.Rate
+	}
+	if broadcast.Burst > 0 {
+		config.Burst = broadcast.Burst
+	}
+
+	return config, nil
+}
+
+func parseQueryConfig(moduleConfig *ModuleConfig) (QueryConfig, error) {
+	config := DefaultQueryConfig()
+	query := moduleConfig.P2P.Query
+
+	if query.PeerTimeout != "" {
+		timeout, err := time.ParseDuration(query.PeerTimeout)
+		if err != nil || timeout <= 0 {
+			return config, fmt.Errorf("invalid query peerTimeout: %s", query.PeerTimeout)
+		}
+		config.PeerTimeout = timeout
+	}
+	if query.Concurrency > 0 {
+		config.Concurrency = query.Concurrency
+	}
+	if query.SlowPenalty > 0 {
+		config.SlowPenalty = query.SlowPenalty
+	}
+	if query.CacheSize > 0 {
+		config.CacheSize = query.CacheSize
+	}
+	if query.Fanout > 0 {
+		config.Fanout = query.Fanout
+	}
+	if query.HedgeQuantile > 0 {
+		config.HedgeQuantile = query.HedgeQuantile
+	}
+
+	return config, nil
+}
+
+// parseWriteConfig builds the node's write settings from the P2P section
+func parseWriteConfig(moduleConfig *ModuleConfig) (WriteConfig, error) {
+	config := DefaultWriteConfig()
+	writes := moduleConfig.P2P.Writes
+
+	level, err := ParseWriteConsistency(writes.Consistency)
+	if err != nil {
+		return config, err
+	}
+	config.Consistency = level
+	if writes.Quorum < 0 || writes.Quorum > replicationFactor {
+		return config, fmt.Errorf("invalid writes quorum: %d", writes.Quorum)
+	}
+	config.Quorum = writes.Quorum
+	if writes.Timeout != "" {
+		timeout, err := time.ParseDuration(writes.Timeout)
+		if err != nil || timeout <= 0 {
+			return config, fmt.Errorf("invalid writes timeout: %s", writes.Timeout)
+		}
+		config.Timeout = timeout
+	}
+
+	return config, nil
+}
+
+// parseShardConfig builds the node's sharding settings from the P2P section
+func parseShardConfig(moduleConfig *ModuleConfig) (ShardConfig, error) {
+	config := DefaultShardConfig()
+	shards := moduleConfig.P2P.Shards
+
+	if shards.Count < 0 {
+		return config, fmt.Errorf("invalid shards count: %d", shards.Count)
+	}
+	config.Count = shards.Count
+	strategy, err := ParseShardStrategy(shards.Strategy)
+	if err != nil {
+		return config, err
+	}
+	config.Strategy = strategy
+	if shards.Replicas > 0 {
+		config.Replicas = shards.Replicas
+	}
+	if shards.Interval != "" {
+		interval, err := time.ParseDuration(shards.Interval)
+		if err != nil || interval <= 0 {
+			return config, fmt.Errorf("invalid shards interval: %s", shards.Interval)
+		}
+		config.Interval = interval
+	}
+
+	return config, nil
+}
+
+// PayloadLimits bounds the transaction data accepted by the module
+type PayloadLimits struct {
+	MaxSize    int64 // Largest Transaction.Data accepted
+	InlineSize int64 // Largest payload accepted inside a JSON request
+	ChunkSize  int   // Largest payload sent in one P2P message
+}
+
+// DefaultPayloadLimits returns the limits used when none are configured
+func DefaultPayloadLimits() PayloadLimits {
+	return PayloadLimits{
+		MaxSize:    DefaultMaxPayloadSize,
+		InlineSize: DefaultInlinePayloadSize,
+		ChunkSize:  DefaultChunkSize,
+	}
+}
+
+func parsePayloadLimits(moduleConfig *ModuleConfig) (PayloadLimits, error) {
+	limits := DefaultPayloadLimits()
+
+	if size := moduleConfig.Transactions.MaxPayloadSize; size != "" {
+		n, err := parseByteSize(size)
+		if err != nil || n <= 0 {
+			return limits, fmt.Errorf("invalid transactions maxPayloadSize: %s", size)
+		}
+		limits.MaxSize = n
+	}
+	if size := moduleConfig.Transactions.InlinePayloadSize; size != "" {
+		n, err := parseByteSize(size)
+		if err != nil || n <= 0 {
+			return limits, fmt.Errorf("invalid transactions inlinePayloadSize: %s", size)
+		}
+		limits.InlineSize = n
+	}
+	if limits.InlineSize > limits.MaxSize {
+		limits.InlineSize = limits.MaxSize
+	}
+	if size := moduleConfig.P2P.ChunkSize; size != "" {
+		n, err := parseByteSize(size)
+		if err != nil || n <= 0 {
+			return limits, fmt.Errorf("invalid p2p chunkSize: %s", size)
+		}
+		limits.ChunkSize = int(n)
+	}
+
+	return limits, nil
+}
+
+// newBlobStore opens the blob store, or returns nil when no path is configured
+func newBlobStore(moduleConfig *ModuleConfig) (*BlobStore, error) {
+	dir := moduleConfig.Blobs.Path
+	if dir == "" && moduleConfig.Storage.Path != "" {
+		dir = filepath.Join(moduleConfig.Storage.Path, "blobs")
+	}
+	if dir == "" {
+		return nil, nil
+	}
+	return NewBlobStore(dir)
+}
+
+func parseCompactionConfig(moduleConfig *ModuleConfig) (CompactionConfig, error) {
+	interval, err := time.ParseDuration(moduleConfig.Compaction.Interval)
+	if err != nil {
+		return CompactionConfig{}, fmt.Errorf("invalid compaction interval: %w", err)
+	}
+	maxAge, err := time.ParseDuration(moduleConfig.Compaction.MaxAge)
+	if err != nil {
+		return CompactionConfig{}, fmt.Errorf("invalid compaction maxAge: %w", err)
+	}
+
+	dims := moduleConfig.Compaction.Dimensions
+	if dims == 0 {
+		dims = moduleConfig.VectorDims
+	}
+
+	return CompactionConfig{
+		Interval:         interval,
+		MaxAge:           maxAge,
+		Dimensions:       dims,
+		MaxArchiveBlocks: moduleConfig.Compaction.MaxArchiveBlocks,
+	}, nil
+}
+
+// parsePreRoutingConfig reads the pre-routing settings, falling back to the
+// defaults for those unset
+func parsePreRoutingConfig(moduleConfig *ModuleConfig) (PreRoutingConfig, error) {
+	config := DefaultPreRoutingConfig()
+	preRouting := moduleConfig.PreRouting
+
+	for _, field := range []struct {
+		name  string
+		value string
+		dest  *time.Duration
+	}{
+		{"interval", preRouting.Interval, &config.Interval},
+		{"lead", preRouting.Lead, &config.Lead},
+		{"quietPeriod", preRouting.QuietPeriod, &config.QuietPeriod},
+	} {
+		if field.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(field.value)
+		if err != nil || d <= 0 {
+			return config, fmt.Errorf("invalid preRouting %s: %s", field.name, field.value)
+		}
+		*field.dest = d
+	}
+	if preRouting.MinRate != 0 {
+		config.MinRate = preRouting.MinRate
+	}
+	return config, nil
+}
+
+// parseOverloadConfig reads the overload limits, falling back to the
+// defaults for the check interval and minimum priority
+func parseOverloadConfig(moduleConfig *ModuleConfig) (OverloadConfig, error) {
+	config := DefaultOverloadConfig()
+	overload := moduleConfig.Overload
+	config.MaxInFlight = overload.MaxInFlight
+	if overload.MinPriority != 0 {
+		config.MinPriority = overload.MinPriority
+	}
+
+	for _, field := range []struct {
+		name  string
+		value string
+		dest  *time.Duration
+	}{
+		{"maxP99Latency", overload.MaxP99Latency, &config.MaxP99Latency},
+		{"checkInterval", overload.CheckInterval, &config.CheckInterval},
+	} {
+		if field.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(field.value)
+		if err != nil || d <= 0 {
+			return config, fmt.Errorf("invalid overload %s: %s", field.name, field.value)
+		}
+		*field.dest = d
+	}
+	if size := overload.MaxHeap; size != "" {
+		n, err := parseByteSize(size)
+		if err != nil || n <= 0 {
+			return config, fmt.Errorf("invalid overload maxHeap: %s", size)
+		}
+		config.MaxHeapBytes = uint64(n)
+	}
+	return config, nil
+}
+
+func parseEndpointHealthConfig(moduleConfig *ModuleConfig) (EndpointHealthConfig, error) {
+	config := DefaultEndpointHealthConfig()
+	health := moduleConfig.EndpointHealth
+
+	if health.Interval != "" {
+		interval, err := time.ParseDuration(health.Interval)
+		if err != nil || interval <= 0 {
+			return config, fmt.Errorf("invalid endpointHealth interval: %s", health.Interval)
+		}
+		config.Interval = interval
+	}
+	if health.Timeout != "" {
+		timeout, err := time.ParseDuration(health.Timeout)
+		if err != nil || timeout <= 0 {
+			return config, fmt.Errorf("invalid endpointHealth timeout: %s", health.Timeout)
+		}
+		config.Timeout = timeout
+	}
+	if health.FailureThreshold > 0 {
+		config.FailureThreshold = health.FailureThreshold
+	}
+
+	return config, nil
+}
+
+type AgglomeratorModule struct {
+	base.BaseModule
+	agglomerator  *Agglomerator
+	config        *ModuleConfig
+	configManager *core.ConfigManager
+	metrics       *core.MetricsExporter
+	logger        *core.ModuleLogger
+	txManager     *core.TransactionManager
+	gc            *core.GarbageCollector
+	p2p           *P2PAgglomerator
+	limits        PayloadLimits
+	blobs         *BlobStore
+	txStore       *TransactionStore
+	eventStore    *EventStore      // Nil keeps the event log in memory
+	coldStore     *ColdVectorStore // Nil unless storage tiering is enabled
+	replica       *ReplicaFollower // Nil unless the node is a read-only replica
+	roles         NodeRoles        // Empty serves every role
+	routes        *RouteUsage
+	sampler       *metricsSampler
+	detector      *AnomalyDetector
+	anomalies     *AnomalyQueue
+	shedder       *LoadShedder // Nil unless overload shedding is enabled
+	policy        *PolicyEngine
+	policyStop    chan struct{} // Stops the policy reload loop
+	assets        *AssetRegistry
+	reconcile     ReconciliationConfig
+	simulation    SimulationConfig
+	sponsors      *SponsorLedger               // Nil unless sponsored execution is enabled
+	reconcileStop chan struct{}                // Stops the reconciliation loop
+	desiredState  *core.DesiredStateReconciler // Nil unless RegisterDesiredState was called
+	chainImporter *ChainImporter               // Nil unless chainRegistry.url is set
+	desiredStop   chan struct{}                // Stops the desired state loop
+	mu            sync.RWMutex
+	moduleState   base.ModuleState // renamed from state to moduleState
+	state         base.ModuleState
+}
+
+// GetAgglomerator returns the underlying agglomerator instance
+func (m *AgglomeratorModule) GetAgglomerator() *Agglomerator {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.agglomerator
+}
+
+// GetP2P returns the P2P agglomerator, or nil when P2P is disabled
+func (m *AgglomeratorModule) GetP2P() *P2PAgglomerator {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.p2p
+}
+
+// GetRoles returns the roles the node serves
+func (m *AgglomeratorModule) GetRoles() NodeRoles {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.roles
+}
+
+// RouterURLs returns the API URLs of router peers a gateway relays
+// transactions to, best first; none without P2P
+func (m *AgglomeratorModule) RouterURLs() []string {
+	p2p := m.GetP2P()
+	if p2p == nil {
+		return nil
+	}
+	return p2p.p2pNode.RouterURLs()
+}
+
+// RegisterChain adds a chain, gossiping it to peers when P2P is enabled.
+// When writes wait for acknowledgments and too few replicas answer, it
+// fails with ErrConsistencyNotMet after registering the chain locally.
+func (m *AgglomeratorModule) RegisterChain(chain *Chain) error {
+	if p2p := m.GetP2P(); p2p != nil {
+		return p2p.RegisterChain(chain)
+	}
+	return m.GetAgglomerator().RegisterChain(chain)
+}
+
+// GetChainImporter returns the chain registry importer, or nil if no
+// registry is configured
+func (m *AgglomeratorModule) GetChainImporter() *ChainImporter {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.chainImporter
+}
+
+// SetChainMaintenance schedules a maintenance window for a local chain,
+// gossiping it to peers when P2P is enabled
+func (m *AgglomeratorModule) SetChainMaintenance(chainID string, maintenance ChainMaintenance) (ChainMaintenance, DrainResult, error) {
+	if p2p := m.GetP2P(); p2p != nil {
+		return p2p.SetMaintenance(chainID, maintenance)
+	}
+	return m.GetAgglomerator().SetMaintenance(chainID, maintenance)
+}
+
+// SetChainCanary promotes or quarantines a local chain, or starts its
+// warm-up again
+func (m *AgglomeratorModule) SetChainCanary(chainID, state, reason string) (ChainCanary, error) {
+	return m.GetAgglomerator().SetCanary(chainID, state, reason)
+}
+
+// ClearChainMaintenance returns a local chain to routing, gossiping that to
+// peers when P2P is enabled
+func (m *AgglomeratorModule) ClearChainMaintenance(chainID string) error {
+	if p2p := m.GetP2P(); p2p != nil {
+		return p2p.ClearMaintenance(chainID)
+	}
+	return m.GetAgglomerator().ClearMaintenance(chainID)
+}
+
+// SeedChainVector seeds a chain's state vector with imported values.
+// Replicas take seeds from their primary.
+func (m *AgglomeratorModule) SeedChainVector(chainID string, values []float64) error {
+	if m.GetReplica() != nil {
+		return ErrReadOnlyReplica
+	}
+	return m.GetAgglomerator().SeedChainVector(chainID, values)
+}
+
+// GetPayloadLimits returns the transaction data size limits
+func (m *AgglomeratorModule) GetPayloadLimits() PayloadLimits {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.limits
+}
+
+// GetTransactionStore returns the transaction history, or nil when storage
+// is not configured
+func (m *AgglomeratorModule) GetTransactionStore() *TransactionStore {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.txStore
+}
+
+// GetBlobs returns the blob store, or nil when it is disabled
+func (m *AgglomeratorModule) GetBlobs() *BlobStore {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.blobs
+}
+
+// PutBlob stores an uploaded payload, replicating it when configured
+func (m *AgglomeratorModule) PutBlob(r io.Reader) (BlobInfo, error) {
+	blobs := m.GetBlobs()
+	if blobs == nil {
+		return BlobInfo{}, fmt.Errorf("blob store not configured")
+	}
+
+	info, err := blobs.Put(r, m.GetPayloadLimits().MaxSize)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	if m.config.Blobs.Replicate && m.GetP2P() != nil {
+		if data, err := blobs.Get(info.Hash); err == nil {
+			m.p2p.p2pNode.ReplicateBlob(info.Hash, data)
+		}
+	}
+	return info, nil
+}
+
+// storePayload moves payloads above the inline size into the blob store and
+// checks that referenced blobs exist
+func (m *AgglomeratorModule) storePayload(tx *Transaction) error {
+	blobs := m.GetBlobs()
+	if tx.BlobRef != "" {
+		if blobs == nil || !blobs.Has(tx.BlobRef) {
+			return fmt.Errorf("%w: %s", ErrBlobNotFound, tx.BlobRef)
+		}
+		return nil
+	}
+	if blobs == nil || int64(len(tx.Data)) <= m.GetPayloadLimits().InlineSize {
+		return nil
+	}
+
+	hash, err := blobs.PutBytes(tx.Data)
+	if err != nil {
+		return err
+	}
+	if m.config.Blobs.Replicate && m.GetP2P() != nil {
+		m.p2p.p2pNode.ReplicateBlob(hash, tx.Data)
+	}
+	tx.BlobRef = hash
+	tx.Data = nil
+	return nil
+}
+
+// recordHistory adds a processed transaction to the persistent history and
+// logs its outcome
+func (m *AgglomeratorModule) recordHistory(tx *Transaction, size int, processErr error) {
+	record := TransactionRecord{
+		ID:        tx.ID,
+		FromChain: tx.FromChain,
+		ToChain:   tx.ToChain,
+		Status:    TxStatusCompleted,
+		BlobRef:   tx.BlobRef,
+		Size:      size,
+		Metadata:  tx.Metadata,
+		Route:     tx.Route,
+	}
+	switch {
+	case errors.Is(processErr, ErrTransactionHeld):
+		record.Status = TxStatusHeld
+	case errors.Is(processErr, errAnomalyRejected):
+		record.Status = TxStatusRejected
+	case errors.Is(processErr, ErrPolicyDenied):
+		record.Status = TxStatusDenied
+		record.Error = processErr.Error()
+	case errors.Is(processErr, ErrSimulationFailed):
+		record.Status = TxStatusReverted
+		record.Error = processErr.Error()
+	case processErr != nil:
+		record.Status = TxStatusFailed
+		record.Error = processErr.Error()
+	}
+
+	if agg := m.GetAgglomerator(); agg != nil {
+		agg.EventLog().record(EventTransactionStatus, "", tx.ID, TransactionStatusData{
+			Status: record.Status,
+			Error:  record.Error,
+		})
+	}
+
+	store := m.GetTransactionStore()
+	if store == nil {
+		return
+	}
+	if err := store.Record(record); err != nil {
+		m.logger.Log(m.Name(), "ERROR", fmt.Sprintf("Failed to record transaction %s: %v", tx.ID, err))
+		return
+	}
+
+	// Completed transactions keep the evidence of their completion
+	if record.Status != TxStatusCompleted {
+		return
+	}
+	bundle, err := m.assembleReceipt(tx, time.Now())
+	if err == nil {
+		err = store.RecordReceipt(bundle)
+	}
+	if err != nil {
+		m.logger.Log(m.Name(), "ERROR", fmt.Sprintf("Failed to record receipt of transaction %s: %v", tx.ID, err))
+	}
+}
+
+// recordRoute adds a processed transaction to the route usage shown in the
+// topology
+func (m *AgglomeratorModule) recordRoute(tx *Transaction, latency time.Duration, processErr error) {
+	var metrics RouteMetrics
+	if toChain, err := m.agglomerator.GetChain(tx.ToChain); err == nil {
+		metrics = calculateRouteMetrics(toChain, tx, m.agglomerator.dimsFor(tx))
+	}
+	m.routes.Record(tx.FromChain, tx.ToChain, metrics, latency, processErr != nil)
+}
+
+// GetTopology returns the routing graph: local and peer-announced chains,
+// with recently used routes as weighted links
+func (m *AgglomeratorModule) GetTopology() Topology {
+	var peerChains map[string][]*Chain
+	if p2p := m.GetP2P(); p2p != nil {
+		peerChains = p2p.PeerChains()
+	}
+	return buildTopology(m.GetAgglomerator().ListChains(), peerChains, m.routes.Links())
+}
+
+// errAnomalyRejected records a held transaction rejected in review
+var errAnomalyRejected = errors.New("rejected in anomaly review")
+
+// screenTransaction checks a transaction against the learned clusters. A
+// flagged transaction is tagged, queued for review and alerted on; others
+// are learned.
+func (m *AgglomeratorModule) screenTransaction(tx *Transaction) *Anomaly {
+	m.mu.RLock()
+	detector, queue := m.detector, m.anomalies
+	m.mu.RUnlock()
+	if detector == nil {
+		return nil
+	}
+
+	anomalous, cluster, similarity := detector.Check(&tx.StateVector)
+	if !anomalous {
+		detector.Learn(&tx.StateVector)
+		return nil
+	}
+
+	anomaly := Anomaly{
+		TxID:       tx.ID,
+		FromChain:  tx.FromChain,
+		ToChain:    tx.ToChain,
+		Cluster:    cluster,
+		Similarity: similarity,
+		Held:       detector.config.Hold,
+		Status:     AnomalyPending,
+		DetectedAt: time.Now(),
+	}
+	if tx.Metadata == nil {
+		tx.Metadata = make(map[string]string)
+	}
+	tx.Metadata[anomalyMetadataKey] = "flagged"
+	queue.Add(anomaly, tx)
+
+	m.logger.Log(m.Name(), "WARN", fmt.Sprintf("Anomalous transaction %s: similarity %.3f to nearest cluster (held: %t)",
+		tx.ID, similarity, anomaly.Held))
+	if webhook := detector.config.AlertWebhook; webhook != "" {
+		go func() {
+			if err := sendAnomalyAlert(webhook, anomaly); err != nil {
+				m.logger.Log(m.Name(), "ERROR", fmt.Sprintf("Failed to send anomaly alert for %s: %v", tx.ID, err))
+			}
+		}()
+	}
+	return &anomaly
+}
+
+// ApproveAnomaly clears a flagged transaction. A held transaction is routed
+// now, and its vector is learned as normal.
+func (m *AgglomeratorModule) ApproveAnomaly(txID string) (Anomaly, error) {
+	queue := m.GetAnomalies()
+	if queue == nil {
+		return Anomaly{}, ErrAnomalyNotFound
+	}
+	anomaly, tx, err := queue.Review(txID, AnomalyApproved)
+	if err != nil {
+		return anomaly, err
+	}
+
+	if tx != nil {
+		if err := m.processTransaction(tx, false); err != nil {
+			return anomaly, err
+		}
+		if detector := m.GetAnomalyDetector(); detector != nil {
+			detector.Learn(&tx.StateVector)
+		}
+	}
+	return anomaly, nil
+}
+
+// RejectAnomaly confirms a flagged transaction as anomalous; a held
+// transaction is dropped
+func (m *AgglomeratorModule) RejectAnomaly(txID string) (Anomaly, error) {
+	queue := m.GetAnomalies()
+	if queue == nil {
+		return Anomaly{}, ErrAnomalyNotFound
+	}
+	anomaly, tx, err := queue.Review(txID, AnomalyRejected)
+	if err != nil {
+		return anomaly, err
+	}
+
+	if tx != nil {
+		m.recordHistory(tx, len(tx.Data), errAnomalyRejected)
+	}
+	return anomaly, nil
+}
+
+// newAssetRegistry builds the asset registry and its price feeds from the
+// configuration
+func newAssetRegistry(moduleConfig *ModuleConfig) (*AssetRegistry, error) {
+	var feeds PriceFeeds
+	if feed := moduleConfig.Assets.PriceFeed; feed.URL != "" {
+		var ttl time.Duration
+		if feed.TTL != "" {
+			var err error
+			if ttl, err = parseDuration(feed.TTL); err != nil {
+				return nil, fmt.Errorf("invalid assets priceFeed ttl: %w", err)
+			}
+		}
+		feeds = append(feeds, NewHTTPPriceFeed(feed.URL, ttl))
+	}
+	if len(moduleConfig.Assets.Prices) > 0 {
+		feeds = append(feeds, StaticPrices(moduleConfig.Assets.Prices))
+	}
+
+	registry := NewAssetRegistry(moduleConfig.Assets.Currency, feeds)
+	for _, asset := range moduleConfig.Assets.Registry {
+		if err := registry.Register(asset); err != nil {
+			return nil, fmt.Errorf("invalid asset: %w", err)
+		}
+	}
+	for chain, symbol := range moduleConfig.Assets.FeeAssets {
+		if err := registry.SetFeeAsset(chain, symbol); err != nil {
+			return nil, fmt.Errorf("invalid fee asset: %w", err)
+		}
+	}
+	return registry, nil
+}
+
+// GetAssets returns the asset registry
+func (m *AgglomeratorModule) GetAssets() *AssetRegistry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.assets
+}
+
+// GetSponsors returns the sponsor ledger, or nil when sponsored execution
+// is disabled
+func (m *AgglomeratorModule) GetSponsors() *SponsorLedger {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.sponsors
+}
+
+// valueTransaction sets the value of a transaction naming an asset and
+// records it in the metadata
+func (m *AgglomeratorModule) valueTransaction(tx *Transaction) error {
+	tx.Value = 0
+	assets := m.GetAssets()
+	if assets == nil || tx.Asset == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), priceFeedTimeout)
+	defer cancel()
+	valuation, err := assets.Value(ctx, tx.FromChain, tx.Asset, tx.Amount)
+	if err != nil {
+		return err
+	}
+
+	tx.Value = valuation.Value
+	if tx.Metadata == nil {
+		tx.Metadata = make(map[string]string)
+	}
+	tx.Metadata[valueMetadataKey] = strconv.FormatFloat(valuation.Value, 'f', 2, 64)
+	tx.Metadata[currencyMetadataKey] = valuation.Currency
+	return nil
+}
+
+// parsePolicyConfig returns the configured compliance rules
+func parsePolicyConfig(moduleConfig *ModuleConfig) PolicyConfig {
+	return PolicyConfig{
+		DryRun:        moduleConfig.Policy.DryRun,
+		DefaultAction: moduleConfig.Policy.DefaultAction,
+		Rules:         moduleConfig.Policy.Rules,
+	}
+}
+
+// checkPolicy evaluates a transaction against the compliance rules. Denials
+// and rule matches are audited; a denial outside dry-run mode returns
+// ErrPolicyDenied.
+func (m *AgglomeratorModule) checkPolicy(tx *Transaction) error {
+	policy := m.GetPolicy()
+	if policy == nil {
+		return nil
+	}
+
+	decision := policy.Evaluate(tx)
+	if decision.Rule == "" && decision.Action == PolicyAllow {
+		return nil
+	}
+
+	if decision.Action == PolicyDeny {
+		verb := "Denied"
+		if decision.DryRun {
+			verb = "Would deny"
+		}
+		m.logger.Log(m.Name(), "WARN", fmt.Sprintf("%s transaction %s (%s -> %s) by policy rule %q",
+			verb, tx.ID, tx.FromChain, tx.ToChain, decision.Rule))
+	}
+	if store := m.GetTransactionStore(); store != nil {
+		if err := store.RecordPolicyDecision(decision); err != nil {
+			m.logger.Log(m.Name(), "ERROR", fmt.Sprintf("Failed to audit policy decision for %s: %v", tx.ID, err))
+		}
+	}
+
+	if decision.Blocked {
+		if decision.Rule == "" {
+			return fmt.Errorf("%w: no rule allows %s", ErrPolicyDenied, tx.ID)
+		}
+		return fmt.Errorf("%w: rule %s", ErrPolicyDenied, decision.Rule)
+	}
+	return nil
+}
+
+// GetPolicy returns the compliance policy engine, or nil when the policy is
+// disabled
+func (m *AgglomeratorModule) GetPolicy() *PolicyEngine {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.policy
+}
+
+// ReloadPolicy replaces the compliance rules with those in the stored
+// configuration
+func (m *AgglomeratorModule) ReloadPolicy() (PolicyConfig, error) {
+	policy := m.GetPolicy()
+	if policy == nil {
+		return PolicyConfig{}, fmt.Errorf("policy not enabled")
+	}
+
+	configData, err := m.configManager.GetConfig(m.Name())
+	if err != nil {
+		return PolicyConfig{}, fmt.Errorf("failed to load config: %w", err)
+	}
+	var moduleConfig ModuleConfig
+	if err := json.Unmarshal(configData, &moduleConfig); err != nil {
+		return PolicyConfig{}, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	config := parsePolicyConfig(&moduleConfig)
+	if err := policy.Reload(config); err != nil {
+		return PolicyConfig{}, fmt.Errorf("invalid policy: %w", err)
+	}
+	return config, nil
+}
+
+// UpdatePolicy applies new compliance rules and stores them as a new
+// revision of the module configuration
+func (m *AgglomeratorModule) UpdatePolicy(config PolicyConfig) error {
+	policy := m.GetPolicy()
+	if policy == nil {
+		return fmt.Errorf("policy not enabled")
+	}
+	if _, err := compilePolicy(config); err != nil {
+		return fmt.Errorf("invalid policy: %w", err)
+	}
+
+	configData, err := m.configManager.GetConfig(m.Name())
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	var stored map[string]json.RawMessage
+	if err := json.Unmarshal(configData, &stored); err != nil {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	// Keep the other policy settings, such as enabled and reloadInterval
+	section := make(map[string]interface{})
+	if raw, exists := stored["policy"]; exists {
+		if err := json.Unmarshal(raw, &section); err != nil {
+			return fmt.Errorf("failed to parse policy config: %w", err)
+		}
+	}
+	section["dryRun"] = config.DryRun
+	section["defaultAction"] = config.DefaultAction
+	section["rules"] = config.Rules
+	if stored["policy"], err = json.Marshal(section); err != nil {
+		return fmt.Errorf("failed to encode policy config: %w", err)
+	}
+
+	updated, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := m.configManager.SetConfig(m.Name(), updated); err != nil {
+		return err
+	}
+	return policy.Reload(config)
+}
+
+// runPolicyReload reloads the compliance rules whenever the stored
+// configuration gains a revision
+func (m *AgglomeratorModule) runPolicyReload(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	revision, _ := m.configManager.LatestConfigRevision(m.Name())
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			latest, err := m.configManager.LatestConfigRevision(m.Name())
+			if err != nil || latest == revision {
+				continue
+			}
+			revision = latest
+			if _, err := m.ReloadPolicy(); err != nil {
+				m.logger.Log(m.Name(), "ERROR", fmt.Sprintf("Failed to reload policy from revision %d: %v", latest, err))
+				continue
+			}
+			m.logger.Log(m.Name(), "INFO", fmt.Sprintf("Reloaded policy from config revision %d", latest))
+		}
+	}
+}
+
+// parseReplicaConfig reads the primary followed by a replica
+func parseReplicaConfig(moduleConfig *ModuleConfig) (ReplicaConfig, error) {
+	config := ReplicaConfig{Primary: moduleConfig.Replica.Primary}
+	if interval := moduleConfig.Replica.PollInterval; interval != "" {
+		pollInterval, err := parseDuration(interval)
+		if err != nil {
+			return config, fmt.Errorf("invalid replica pollInterval: %w", err)
+		}
+		config.PollInterval = pollInterval
+	}
+	return config, nil
+}
+
+// GetReplica returns the follower of the primary, or nil if the node is not
+// a replica
+func (m *AgglomeratorModule) GetReplica() *ReplicaFollower {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.replica
+}
+
+// restoreChains registers the chains in the event log that are not in the
+// configuration, such as those added through the API, as last registered
+func (m *AgglomeratorModule) restoreChains(moduleConfig *ModuleConfig) error {
+	state, err := m.agglomerator.EventLog().State(time.Time{})
+	if err != nil {
+		return fmt.Errorf("failed to replay event log: %w", err)
+	}
+
+	configured := make(map[string]bool, len(moduleConfig.EnabledChains))
+	for _, chain := range moduleConfig.EnabledChains {
+		configured[chain.ID] = true
+	}
+	now := m.agglomerator.clock.Now()
+	for id, registered := range state.Chains {
+		if configured[id] {
+			m.restoreMaintenance(id, registered, now)
+			m.restoreCanary(id, registered)
+			if err := m.restoreChainVector(id, registered); err != nil {
+				return err
+			}
+			continue
+		}
+		chain := &Chain{
+			ID:        registered.ID,
+			Endpoint:  registered.Endpoint,
+			Endpoints: registered.Endpoints,
+			Protocol:  registered.Protocol,
+			Zone:      registered.Zone,
+			StateVector: vectors.InfiniteVector{
+				Generator: getProtocolGenerator(registered.Protocol),
+			},
+		}
+		if registered.Capabilities != nil {
+			chain.Capabilities = *registered.Capabilities
+		}
+		if err := m.agglomerator.registerChain(chain); err != nil {
+			return fmt.Errorf("failed to restore chain %s: %w", id, err)
+		}
+		m.logger.Log(m.Name(), "INFO", fmt.Sprintf("Restored chain from event log: %s", id))
+		m.restoreMaintenance(id, registered, now)
+		m.restoreCanary(id, registered)
+		if err := m.restoreChainVector(id, registered); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreChainVector reseeds a chain's state vector with the values last
+// imported for it
+func (m *AgglomeratorModule) restoreChainVector(id string, registered ChainState) error {
+	if registered.Vector == nil {
+		return nil
+	}
+	if err := m.agglomerator.seedChainVector(id, registered.Vector); err != nil {
+		return fmt.Errorf("failed to restore state vector of chain %s: %w", id, err)
+	}
+	return nil
+}
+
+// restoreMaintenance keeps a chain in the maintenance window it was in
+// before a restart, unless the window has since ended
+func (m *AgglomeratorModule) restoreMaintenance(id string, registered ChainState, now time.Time) {
+	if maintenance := registered.Maintenance; maintenance != nil && !maintenance.expired(now) {
+		m.agglomerator.restoreMaintenance(id, *maintenance)
+	}
+}
+
+// restoreCanary keeps a chain quarantined across a restart, and warming if
+// chains still warm up
+func (m *AgglomeratorModule) restoreCanary(id string, registered ChainState) {
+	canary := registered.Canary
+	if canary == nil || canary.State == CanaryPromoted {
+		return
+	}
+	if canary.State == CanaryWarming && m.agglomerator.canaries == nil {
+		return
+	}
+	m.agglomerator.restoreCanary(id, *canary)
+}
+
+// parseReconciliationConfig reads the reconciliation settings, falling back
+// to the defaults for those unset
+func parseReconciliationConfig(moduleConfig *ModuleConfig) (ReconciliationConfig, error) {
+	config := DefaultReconciliationConfig()
+	settings := moduleConfig.Reconciliation
+	if settings.Interval != "" {
+		interval, err := parseDuration(settings.Interval)
+		if err != nil {
+			return config, fmt.Errorf("invalid reconciliation interval: %w", err)
+		}
+		config.Interval = interval
+	}
+	if settings.Timeout != "" {
+		timeout, err := parseDuration(settings.Timeout)
+		if err != nil {
+			return config, fmt.Errorf("invalid reconciliation timeout: %w", err)
+		}
+		config.Timeout = timeout
+	}
+	if settings.MinConfirmations > 0 {
+		config.MinConfirmations = uint64(settings.MinConfirmations)
+	}
+	return config, nil
+}
+
+// RunReconciliation reconciles the UTC day containing day and stores its
+// report
+func (m *AgglomeratorModule) RunReconciliation(day time.Time) (ReconciliationReport, error) {
+	store := m.GetTransactionStore()
+	agg := m.GetAgglomerator()
+	if store == nil || agg == nil {
+		return ReconciliationReport{}, fmt.Errorf("transaction history not configured")
+	}
+
+	m.mu.RLock()
+	config := m.reconcile
+	m.mu.RUnlock()
+	if config.Interval == 0 {
+		config = DefaultReconciliationConfig()
+	}
+
+	report, err := agg.Reconcile(context.Background(), store, day, config)
+	if err != nil {
+		return report, err
+	}
+	if mismatches := report.Checked - report.Matched; mismatches > 0 {
+		m.logger.Log(m.Name(), "WARN", fmt.Sprintf("Reconciliation %s: %d of %d transactions mismatched (%d pending, %d missing, %d unverifiable)",
+			report.Date, mismatches, report.Checked, report.Pending, report.Missing, report.Unverifiable))
+	}
+	return report, nil
+}
+
+// runReconciliation reconciles the current day every interval, and the
+// previous day until a final report has been made for it
+func (m *AgglomeratorModule) runReconciliation(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			now := time.Now().UTC()
+			yesterday := now.Add(-24 * time.Hour)
+			if report, err := m.GetTransactionStore().ReconciliationReport(yesterday.Format(ReportDateLayout)); err != nil || !report.Final {
+				if _, err := m.RunReconciliation(yesterday); err != nil {
+					m.logger.Log(m.Name(), "ERROR", fmt.Sprintf("Failed to reconcile %s: %v", yesterday.Format(ReportDateLayout), err))
+				}
+			}
+			if _, err := m.RunReconciliation(now); err != nil {
+				m.logger.Log(m.Name(), "ERROR", fmt.Sprintf("Failed to reconcile %s: %v", now.Format(ReportDateLayout), err))
+			}
+		}
+	}
+}
+
+// RetrainAnomalyDetector rebuilds the clusters from the transaction vectors
+// in the routing index, leaving out transactions awaiting review
+func (m *AgglomeratorModule) RetrainAnomalyDetector() (AnomalyDetectorStats, error) {
+	detector, queue := m.GetAnomalyDetector(), m.GetAnomalies()
+	if detector == nil {
+		return AnomalyDetectorStats{}, fmt.Errorf("anomaly detection not enabled")
+	}
+
+	var records []vectors.DatabaseRecord
+	for _, record := range m.GetAgglomerator().TransactionRecords() {
+		if !queue.Pending(record.ID) {
+			records = append(records, record)
+		}
+	}
+	detector.Train(records)
+	return detector.Stats(), nil
+}
+
+// GetAnomalyDetector returns the detector, or nil when anomaly detection is
+// disabled
+func (m *AgglomeratorModule) GetAnomalyDetector() *AnomalyDetector {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.detector
+}
+
+// GetAnomalies returns the review queue, or nil when anomaly detection is
+// disabled
+func (m *AgglomeratorModule) GetAnomalies() *AnomalyQueue {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.anomalies
+}
+
+// GetLoadShedder returns the overload shedder, or nil when shedding is
+// disabled
+func (m *AgglomeratorModule) GetLoadShedder() *LoadShedder {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.shedder
+}
+
+// blobRefs lists blobs referenced by local transactions and peer replicas
+func (m *AgglomeratorModule) blobRefs() map[string]bool {
+	refs := m.GetAgglomerator().BlobRefs()
+	if p2p := m.GetP2P(); p2p != nil {
+		for hash := range p2p.p2pNode.localDatabase.BlobRefs() {
+			refs[hash] = true
+		}
+	}
+	return refs
+}
+
+// GetMetricsHistory returns the sampled metrics history, or nil when metrics
+// are disabled
+func (m *AgglomeratorModule) GetMetricsHistory() *MetricsHistory {
+	if sampler := m.getSampler(); sampler != nil {
+		return sampler.history
+	}
+	return nil
+}
+
+func (m *AgglomeratorModule) getSampler() *metricsSampler {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.sampler
+}
+
+// GetGC returns the module's garbage collector, or nil when GC is not configured
+func (m *AgglomeratorModule) GetGC() *core.GarbageCollector {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.gc
+}
+
+// GetConfig returns the current module configuration
+func (m *AgglomeratorModule) GetConfig() *ModuleConfig {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.config
+}
+
+func NewAgglomeratorModule(
+	configManager *core.ConfigManager,
+	metrics *core.MetricsExporter,
+	logger *core.ModuleLogger,
+) *AgglomeratorModule {
+	metadata := base.NewModuleMetadata(
+		"blockchain_agglomerator",
+		"1.0.0",
+		"Blockchain Agglomerator with Vector-based Chain Analysis",
+		"HyDAP Team",
+		"MIT",
+	)
+
+	baseModule := base.CreateNewModule(metadata, nil).(*base.BaseModule)
+
+	return &AgglomeratorModule{
+		BaseModule:    *baseModule,
+		configManager: configManager,
+		metrics:       metrics,
+		logger:        logger,
+		txManager: &core.TransactionManager{
+			Txns: make(map[string]*core.Transaction),
+		},
+		limits:      DefaultPayloadLimits(),
+		routes:      NewRouteUsage(),
+		moduleState: base.StateUninitialized,
+	}
+}
+
+// State returns the current module state
+func (m *AgglomeratorModule) GetState() base.ModuleState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.moduleState
+}
+
+// SetState is a helper method to update module state
+func (m *AgglomeratorModule) SetState(state base.ModuleState) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.moduleState = state
+}
+
+// ProcessTransaction handles a cross-chain transaction
+func (m *AgglomeratorModule) ProcessTransaction(tx *Transaction) error {
+	return m.processTransaction(tx, true)
+}
+
+// DefaultMaxBatchSize is the largest transaction batch accepted when
+// transactions.maxBatchSize is not set
+const DefaultMaxBatchSize = 1000
+
+// MaxBatchSize returns the largest number of transactions accepted in one
+// batch
+func (m *AgglomeratorModule) MaxBatchSize() int {
+	if config := m.GetConfig(); config != nil && config.Transactions.MaxBatchSize > 0 {
+		return config.Transactions.MaxBatchSize
+	}
+	return DefaultMaxBatchSize
+}
+
+// ProcessTransactions handles a batch of cross-chain transactions, letting
+// alike transactions share their route planning. It returns an error per
+// transaction, nil for those routed.
+func (m *AgglomeratorModule) ProcessTransactions(txs []*Transaction) []error {
+	return m.processTransactions(txs, true)
+}
+
+// processTransaction routes a transaction, first screening it for anomalies
+// unless it has already been reviewed
+func (m *AgglomeratorModule) processTransaction(tx *Transaction, screen bool) error {
+	return m.processTransactions([]*Transaction{tx}, screen)[0]
+}
+
+// processTransactions routes transactions, first screening each for
+// anomalies unless they have already been reviewed
+func (m *AgglomeratorModule) processTransactions(txs []*Transaction, screen bool) []error {
+	errs := make([]error, len(txs))
+	txns := make([]*core.Transaction, len(txs))
+	sizes := make([]int, len(txs))
+	defer func() {
+		for _, txn := range txns {
+			if txn.Status == "pending" {
+				txn.Status = "completed"
+			}
+		}
+	}()
+
+	// Admitted transactions count towards queue depth until they finish
+	shedder := m.GetLoadShedder()
+	admitted := make([]time.Time, len(txs))
+	if shedder != nil {
+		defer func() {
+			for _, at := range admitted {
+				if !at.IsZero() {
+					shedder.Done(time.Since(at))
+				}
+			}
+		}()
+	}
+
+	var ready []int
+	for i, tx := range txs {
+		// Start transaction tracking
+		txns[i] = m.txManager.Begin(m.Name(), "process_transaction")
+		if tx.ID == "" {
+			tx.ID = core.NewID()
+		}
+		m.logger.Log(m.Name(), "INFO", fmt.Sprintf("Processing transaction: %s", txns[i].ID))
+
+		if shedder != nil {
+			if err := shedder.Admit(tx); err != nil {
+				txns[i].Status = "failed"
+				errs[i] = err
+				continue
+			}
+			admitted[i] = time.Now()
+		}
+		sizes[i], errs[i] = m.prepareTransaction(tx, txns[i], screen)
+		if errs[i] == nil {
+			ready = append(ready, i)
+		}
+	}
+	if len(ready) == 0 {
+		return errs
+	}
+
+	batch := make([]*Transaction, len(ready))
+	for j, i := range ready {
+		batch[j] = txs[i]
+	}
+	start := time.Now()
+	var routed []error
+	if len(batch) == 1 {
+		routed = []error{m.agglomerator.ProcessTransaction(context.Background(), batch[0])}
+	} else {
+		routed = m.agglomerator.ProcessTransactions(context.Background(), batch)
+	}
+	latency := time.Since(start) / time.Duration(len(batch))
+
+	for j, i := range ready {
+		tx, txn, err := txs[i], txns[i], routed[j]
+		m.recordRoute(tx, latency, err)
+		m.agglomerator.recordExperiment(tx, latency, err)
+		m.settleSponsorship(tx, err)
+		m.recordHistory(tx, sizes[i], err)
+		if sampler := m.getSampler(); sampler != nil {
+			sampler.count(err != nil)
+		}
+		if err != nil {
+			txn.Status = "failed"
+			m.logger.Log(m.Name(), "ERROR", fmt.Sprintf("Transaction failed: %v", err))
+			errs[i] = err
+			continue
+		}
+		m.logger.Log(m.Name(), "INFO", fmt.Sprintf("Transaction completed: %s", txn.ID))
+	}
+	return errs
+}
+
+// prepareTransaction runs the checks a transaction passes before it is
+// routed, returning the size of its payload
+func (m *AgglomeratorModule) prepareTransaction(tx *Transaction, txn *core.Transaction, screen bool) (int, error) {
+	if m.GetState() != base.StateRunning {
+		txn.Status = "failed"
+		return 0, fmt.Errorf("module not in running state: %s", m.GetState())
+	}
+	if m.GetReplica() != nil {
+		txn.Status = "failed"
+		return 0, ErrReadOnlyReplica
+	}
+	if !m.GetRoles().Has(RoleRouter) {
+		txn.Status = "failed"
+		return 0, fmt.Errorf("%w: %s", ErrRoleNotServed, RoleRouter)
+	}
+
+	if limit := m.GetPayloadLimits().MaxSize; int64(len(tx.Data)) > limit {
+		txn.Status = "failed"
+		return 0, fmt.Errorf("%w: %d bytes exceeds %d", ErrPayloadTooLarge, len(tx.Data), limit)
+	}
+	size := len(tx.Data)
+
+	if err := m.valueTransaction(tx); err != nil {
+		txn.Status = "failed"
+		return size, err
+	}
+	if err := m.budgetFees(tx); err != nil {
+		txn.Status = "failed"
+		if errors.Is(err, ErrFeeBudgetExceeded) {
+			m.recordHistory(tx, size, err)
+		}
+		return size, err
+	}
+
+	// Payload rules see the data before it is moved to the blob store
+	if screen {
+		if err := m.checkPolicy(tx); err != nil {
+			txn.Status = "failed"
+			m.recordHistory(tx, size, err)
+			return size, err
+		}
+	}
+
+	// Destinations that would reject the transaction are not routed to
+	if err := m.simulateRoute(tx); err != nil {
+		txn.Status = "failed"
+		m.recordHistory(tx, size, err)
+		return size, err
+	}
+
+	if err := m.storePayload(tx); err != nil {
+		txn.Status = "failed"
+		return size, err
+	}
+
+	if screen {
+		if anomaly := m.screenTransaction(tx); anomaly != nil && anomaly.Held {
+			txn.Status = "held"
+			m.recordHistory(tx, size, ErrTransactionHeld)
+			return size, fmt.Errorf("%w: %s", ErrTransactionHeld, tx.ID)
+		}
+	}
+
+	// Sponsored fees are reserved last, as the transaction is routed next
+	if err := m.sponsorFees(tx); err != nil {
+		txn.Status = "failed"
+		if errors.Is(err, ErrSponsorBudgetExceeded) {
+			m.recordHistory(tx, size, err)
+		}
+		return size, err
+	}
+	return size, nil
 }
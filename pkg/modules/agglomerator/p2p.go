@@ -1,15 +1,23 @@
 package agglomerator
 
 import (
+	"bufio"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"math"
 	"math/rand"
+	"net"
 	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
 
+	"github.com/theaxiomverse/hydap-api/pkg/keymanagement/pb"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
 	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+	"google.golang.org/protobuf/proto"
 	"sync"
 	"time"
 )
@@ -19,37 +27,109 @@ type P2PAgglomerator struct {
 	*Agglomerator
 	p2pNode    *P2PInfiniteVectorNode
 	mu         sync.RWMutex
-	peerChains map[string][]*Chain // Chains known by peers
+	peerChains map[string]map[string]*peerChain // Chains known by peers, keyed by peer then chain ID
+	lifecycle  *core.LifecycleManager           // Chain sync, ended by Stop
+}
+
+// peerChain records a chain advertised by a peer and when it was last seen
+type peerChain struct {
+	chain    *Chain
+	lastSeen time.Time
 }
 
 // NewP2PAgglomerator creates a new P2P-enabled agglomerator
 func NewP2PAgglomerator(config AgglomeratorConfig, address string, port int) *P2PAgglomerator {
+	return NewP2PAgglomeratorWithNode(config, NewP2PInfiniteVectorNode(address, port))
+}
+
+// NewP2PAgglomeratorWithNode creates a P2P agglomerator around a node that
+// has already been configured, and starts it
+func NewP2PAgglomeratorWithNode(config AgglomeratorConfig, p2pNode *P2PInfiniteVectorNode) *P2PAgglomerator {
 	baseAgg := NewAgglomerator(config)
-	p2pNode := NewP2PInfiniteVectorNode(address, port)
 
 	p2pAgg := &P2PAgglomerator{
 		Agglomerator: baseAgg,
 		p2pNode:      p2pNode,
-		peerChains:   make(map[string][]*Chain),
+		peerChains:   make(map[string]map[string]*peerChain),
+		lifecycle:    core.NewLifecycleManager(),
+	}
+	if config.Clock != nil {
+		p2pNode.UseClock(config.Clock)
 	}
 
 	// Start P2P node
-	go p2pNode.Start()
+	p2pNode.Start()
 
 	// Start chain sync
-	go p2pAgg.syncChains()
+	ticker := baseAgg.clock.NewTicker(chainSyncInterval)
+	p2pAgg.lifecycle.Go(func(ctx context.Context) {
+		p2pAgg.syncChains(ctx, ticker)
+	})
 
 	return p2pAgg
 }
 
-// RegisterChain adds a chain and broadcasts it to the P2P network
+// Stop ends chain sync and stops the P2P node, waiting until their
+// goroutines have returned or ctx is done
+func (p *P2PAgglomerator) Stop(ctx context.Context) error {
+	if err := p.lifecycle.Stop(ctx); err != nil {
+		return err
+	}
+	return p.p2pNode.Stop(ctx)
+}
+
+// RegisterChain adds a chain and broadcasts it to the P2P network. A
+// private chain is only sent to members of its zone; other registrations
+// are batched and paced by the node's ChainBroadcaster, unless writes wait
+// for acknowledgments. The chain stays registered locally when too few
+// replicas acknowledge it, and registering it again retries.
 func (p *P2PAgglomerator) RegisterChain(chain *Chain) error {
+	if chain.Zone != "" && !p.p2pNode.Zones().Has(chain.Zone) {
+		return fmt.Errorf("%w: %s", ErrUnknownZone, chain.Zone)
+	}
+
 	// Register locally first
+	_, err := p.Agglomerator.GetChain(chain.ID)
+	known := err == nil
 	if err := p.Agglomerator.RegisterChain(chain); err != nil {
 		return err
 	}
 
-	// Create database record for P2P distribution
+	// Distribute through P2P network; re-registering a chain corrects what
+	// peers hold, so it skips the queue
+	return p.gossipChain(chain, known)
+}
+
+// SetMaintenance schedules a maintenance window for a local chain and
+// gossips it to peers, which stop routing to the chain for the window
+func (p *P2PAgglomerator) SetMaintenance(chainID string, maintenance ChainMaintenance) (ChainMaintenance, DrainResult, error) {
+	maintenance, result, err := p.Agglomerator.SetMaintenance(chainID, maintenance)
+	if err != nil {
+		return maintenance, result, err
+	}
+	chain, err := p.GetChain(chainID)
+	if err != nil {
+		return maintenance, result, err
+	}
+	return maintenance, result, p.gossipChain(chain, true)
+}
+
+// ClearMaintenance returns a local chain to routing and gossips that to
+// peers
+func (p *P2PAgglomerator) ClearMaintenance(chainID string) error {
+	if err := p.Agglomerator.ClearMaintenance(chainID); err != nil {
+		return err
+	}
+	chain, err := p.GetChain(chainID)
+	if err != nil {
+		return err
+	}
+	return p.gossipChain(chain, true)
+}
+
+// gossipChain sends a chain's registration to peers, on the priority lane
+// when it corrects one they may hold
+func (p *P2PAgglomerator) gossipChain(chain *Chain, correction bool) error {
 	record := vectors.DatabaseRecord{
 		ID: chain.ID,
 		Metadata: map[string]interface{}{
@@ -59,20 +139,40 @@ func (p *P2PAgglomerator) RegisterChain(chain *Chain) error {
 		},
 		Vector: chain.StateVector,
 	}
+	if !chain.Capabilities.IsZero() {
+		record.Metadata["capabilities"] = chain.Capabilities.metadata()
+	}
+	if maintenance := chain.Maintenance(); maintenance != nil {
+		record.Metadata["maintenance"] = maintenance.metadata()
+	}
 
-	// Distribute through P2P network
-	p.p2pNode.StoreData(record)
-
+	if chain.Zone != "" {
+		return p.p2pNode.StorePrivateData(chain.Zone, &record)
+	}
+	if p.p2pNode.WriteConfig().Consistency == WriteAckQuorum {
+		// Registrations that wait for acknowledgments skip the batch
+		return p.replicate(context.Background(), record)
+	}
+	p.p2pNode.BroadcastRecord(&record, correction)
 	return nil
 }
 
+// replicate stores a record on the network at the node's configured write
+// level, failing with ErrConsistencyNotMet when too few replicas
+// acknowledge it
+func (p *P2PAgglomerator) replicate(ctx context.Context, record vectors.DatabaseRecord) error {
+	_, err := p.p2pNode.Store(ctx, record, p.p2pNode.WriteConfig().Consistency)
+	return err
+}
+
 // ProcessTransaction handles cross-chain transactions through P2P network
 func (p *P2PAgglomerator) ProcessTransaction(ctx context.Context, tx *Transaction) error {
 	// Find optimal route including peer chains
-	route, err := p.findP2POptimalRoute(tx)
+	route, explanation, err := p.findP2POptimalRoute(tx)
 	if err != nil {
 		return err
 	}
+	tx.Route = explanation
 
 	// Create database record for transaction
 	record := vectors.DatabaseRecord{
@@ -81,45 +181,66 @@ func (p *P2PAgglomerator) ProcessTransaction(ctx context.Context, tx *Transactio
 			"fromChain": tx.FromChain,
 			"toChain":   tx.ToChain,
 			"type":      "transaction",
+			"data":      tx.Data,
+			"blobRef":   tx.BlobRef,
 		},
 		Vector: tx.StateVector,
 	}
 
 	// Distribute transaction through P2P network
-	p.p2pNode.StoreData(record)
+	if err := p.replicate(ctx, record); err != nil {
+		return err
+	}
 
 	// Process through route
 	return p.executeP2PTransaction(ctx, tx, route)
 }
 
-// findP2POptimalRoute finds the best route including peer chains
-func (p *P2PAgglomerator) findP2POptimalRoute(tx *Transaction) ([]string, error) {
+// findP2POptimalRoute finds the best route including peer chains, with the
+// scores of every candidate considered
+func (p *P2PAgglomerator) findP2POptimalRoute(tx *Transaction) ([]string, *RouteExplanation, error) {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
 	// Query similar chains across the P2P network
 	queryVector := tx.StateVector
-	results := p.p2pNode.QueryData(queryVector)
+	results := p.p2pNode.QueryData(queryVector, p.dimsFor(tx))
 
 	var candidateChains []*Chain
 
 	// Collect all potential chains
 	for _, result := range results {
 		if result.Metadata["type"] == "chain_registration" {
-			chain := &Chain{
-				ID:          result.ID,
-				Protocol:    result.Metadata["protocol"].(string),
-				Endpoint:    result.Metadata["endpoint"].(string),
-				StateVector: result.Vector,
-			}
-			candidateChains = append(candidateChains, chain)
+			candidateChains = append(candidateChains, chainFromRecord(result))
 		}
 	}
 
+	// Private chains are only candidates for transactions from their zone
+	zone := p.transactionZone(tx, candidateChains)
+	visible := candidateChains[:0]
+	for _, chain := range candidateChains {
+		if chainVisible(chain, zone) {
+			visible = append(visible, chain)
+		}
+	}
+	candidateChains = visible
+
+	// Chains that would reject the transaction are left out
+	candidateChains, exclusions := excludeIncapable(candidateChains, tx, p.clock.Now())
+
+	// New chains warm up on a fraction of the routes
+	// New chains warm up on a fraction of the routes, and experiments may
+	// score with other weights
+	p.Agglomerator.mu.RLock()
+	candidateChains, heldBack := p.holdBackCanaries(candidateChains, tx)
+	weights, arm := p.routeWeights(tx)
+	p.Agglomerator.mu.RUnlock()
+	exclusions = append(exclusions, heldBack...)
+
 	// Find optimal route
-	route := findOptimalRoute(candidateChains, tx)
+	route := findWeightedRoute(candidateChains, tx, p.dimsFor(tx), weights)
 	if len(route) == 0 {
-		return nil, ErrNoRouteFound
+		return nil, nil, ErrNoRouteFound
 	}
 
 	// Convert route to chain IDs
@@ -128,7 +249,13 @@ func (p *P2PAgglomerator) findP2POptimalRoute(tx *Transaction) ([]string, error)
 		routeIDs[i] = chain.ID
 	}
 
-	return routeIDs, nil
+	explanation := explainWeightedRoute(tx, RouteModeScored, candidateChains, routeIDs, p.dimsFor(tx), weights, func(id string) bool {
+		_, err := p.GetChain(id)
+		return err == nil
+	})
+	explanation.Excluded = exclusions
+	explanation.Experiment = arm
+	return routeIDs, explanation, nil
 }
 
 // executeP2PTransaction executes transaction across P2P network
@@ -164,8 +291,14 @@ func (p *P2PAgglomerator) processLocalChain(ctx context.Context, tx *Transaction
 		},
 	}
 
+	entry := PoolEntry{TxID: record.ID, Priority: tx.Priority, Fee: tx.Fee, AddedAt: time.Now()}
+	if err := chain.admitToPool(entry); err != nil {
+		return err
+	}
 	chain.TransactionPool.Insert(record)
-	return nil
+	p.EventLog().record(EventTransactionPooled, chain.ID, record.ID, nil)
+
+	return submitTransaction(ctx, chain, tx)
 }
 
 func (p *P2PAgglomerator) processPeerChain(ctx context.Context, tx *Transaction, chainID string) error {
@@ -181,14 +314,22 @@ func (p *P2PAgglomerator) processPeerChain(ctx context.Context, tx *Transaction,
 	}
 
 	// Distribute through P2P network
-	p.p2pNode.StoreData(record)
-	return nil
+	return p.replicate(ctx, record)
 }
 
+// chainSyncInterval is how often chains registered by peers are fetched
+const chainSyncInterval = 5 * time.Minute
+
 // syncChains periodically syncs chain information with peers
-func (p *P2PAgglomerator) syncChains() {
-	ticker := time.NewTicker(time.Minute * 5)
-	for range ticker.C {
+func (p *P2PAgglomerator) syncChains(ctx context.Context, ticker core.Ticker) {
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+
 		// Query network for chain registrations
 		queryVector := vectors.InfiniteVector{
 			Generator: func(dim int) float64 {
@@ -196,26 +337,75 @@ func (p *P2PAgglomerator) syncChains() {
 			},
 		}
 
-		results := p.p2pNode.QueryData(queryVector)
+		results := p.p2pNode.Query(ctx, queryVector, p.compareDims).Records
 
 		p.mu.Lock()
 		// Update peer chains
+		now := p.clock.Now()
 		for _, result := range results {
 			if result.Metadata["type"] == "chain_registration" {
 				peerID := result.Metadata["peer_id"].(string)
-				chain := &Chain{
-					ID:          result.ID,
-					Protocol:    result.Metadata["protocol"].(string),
-					Endpoint:    result.Metadata["endpoint"].(string),
-					StateVector: result.Vector,
+				chain := chainFromRecord(result)
+				chains, exists := p.peerChains[peerID]
+				if !exists {
+					chains = make(map[string]*peerChain)
+					p.peerChains[peerID] = chains
 				}
-				p.peerChains[peerID] = append(p.peerChains[peerID], chain)
+				chains[chain.ID] = &peerChain{chain: chain, lastSeen: now}
 			}
 		}
 		p.mu.Unlock()
 	}
 }
 
+// chainFromRecord builds a peer's chain from its gossiped registration
+func chainFromRecord(record vectors.DatabaseRecord) *Chain {
+	chain := &Chain{
+		ID:           record.ID,
+		Protocol:     record.Metadata["protocol"].(string),
+		Endpoint:     record.Metadata["endpoint"].(string),
+		StateVector:  record.Vector,
+		Capabilities: capabilitiesFromMetadata(record.Metadata),
+		maintenance:  maintenanceFromMetadata(record.Metadata),
+	}
+	chain.Zone, _ = record.Metadata["zone"].(string)
+	return chain
+}
+
+// CollectPeerChains drops peer chain entries not seen since cutoff
+func (p *P2PAgglomerator) CollectPeerChains(cutoff time.Time) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	removed := 0
+	for peerID, chains := range p.peerChains {
+		for chainID, entry := range chains {
+			if entry.lastSeen.Before(cutoff) {
+				delete(chains, chainID)
+				removed++
+			}
+		}
+		if len(chains) == 0 {
+			delete(p.peerChains, peerID)
+		}
+	}
+	return removed
+}
+
+// PeerChains returns the chains announced by each peer, keyed by peer ID
+func (p *P2PAgglomerator) PeerChains() map[string][]*Chain {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	peerChains := make(map[string][]*Chain, len(p.peerChains))
+	for peerID, chains := range p.peerChains {
+		for _, entry := range chains {
+			peerChains[peerID] = append(peerChains[peerID], entry.chain)
+		}
+	}
+	return peerChains
+}
+
 // P2PInfiniteVectorNode represents a node in the decentralized network
 type P2PInfiniteVectorNode struct {
 	// Unique node identifier
@@ -229,20 +419,114 @@ type P2PInfiniteVectorNode struct {
 	localDatabase *InfiniteVectorDatabase
 
 	// Peer discovery and connection management
-	peers     map[string]*PeerInfo
-	peerMutex sync.RWMutex
+	peers             map[string]*PeerInfo
+	peerMutex         sync.RWMutex
+	discoveryDisabled bool // Only bootstrap peers are used when set
 
 	// Routing and content discovery
 	routingVector vectors.InfiniteVector
 
 	// Network communication channels
 	discoveryChannel chan PeerDiscoveryMessage
-	dataChannel      chan DataTransferMessage
+	dataChannel      chan DataTransferMessage // Inbound data
+	controlQueue     chan DataTransferMessage // Outbound gossip, sent first
+	bulkQueue        chan DataTransferMessage // Outbound replication
+
+	// Per-peer traffic accounting and throttling
+	bandwidth *BandwidthManager
+
+	// Batches and paces chain registration gossip
+	broadcaster *ChainBroadcaster
+
+	// Large payloads are split into chunks and rebuilt on receipt
+	chunkSize   int
+	reassembler *Reassembler
+
+	// Destination for blobs replicated by peers; nil drops them
+	blobs *BlobStore
+
+	// Replicated records and blobs are dropped while ingest is paused, as
+	// under overload; gossip and control traffic still flow
+	ingestPaused  atomic.Bool
+	ingestDropped atomic.Uint64
+
+	// Network transport; nil means delivery is simulated
+	transport Transport
+	listener  Listener
+	conns     map[string]Conn
+	connMu    sync.Mutex
+
+	// Signature algorithms offered in handshakes, and those agreed per peer
+	algorithms     []string
+	peerAlgorithms map[string]string
+	peerVersions   map[string]uint32 // Wire version agreed per peer
+
+	// Attestation presented to peers, and the CA keys theirs must chain to
+	attestation  *Attestation
+	trustAnchors *TrustAnchors
+
+	// Keys for the key exchange that encrypts every channel, and the signing
+	// key each peer first authenticated with; nil keys leave channels plain
+	keys     NodeKeys
+	peerKeys map[string][]byte
+
+	// Check peers pass before joining the peer set, and this node's own
+	// proof of work
+	admission      *Admission
+	admissionNonce uint64
+
+	// Privacy zones this node belongs to, and the peers proven to share them
+	zones *PrivacyZones
 
 	// Reputation and trust system
 	reputation *ReputationManager
+
+	// Duplicate and replay protection for inbound data
+	replayGuard *ReplayGuard
+
+	// Record filters advertised by peers, consulted before querying them
+	filters *PeerFilters
+
+	// Owners of each shard of the vector space when records are partitioned
+	shards *ShardMap
+
+	// Roles this node serves and advertises, and those peers advertised
+	roles     RoleConfig
+	peerRoles *PeerRoles
+
+	// Scatter-gather queries across peers; a nil querier simulates them
+	querier     PeerQuerier
+	writer      PeerWriter // Acknowledged writes; nil sends them over the transport
+	writeConfig WriteConfig
+	pendingAcks map[string]pendingAck // Acknowledged writes in flight, by write ID
+	ackMu       sync.Mutex
+	queryConfig QueryConfig
+	queryMu     sync.Mutex
+	queryStats  QueryStats
+	queryCache  *vectors.QueryCache
+	latencies   latencyWindow // Recent peer answer times, for hedging
+	consistency consistencyMetrics
+
+	// Time source of discovery, reputation decay and record collection
+	clock core.Clock
+
+	// Feature flags; nil keeps every flag at its default
+	flags *core.FeatureFlags
+
+	// Background loops and connection handlers, ended by Stop
+	lifecycle *core.LifecycleManager
 }
 
+// blobDataPrefix marks data transfers that carry blob content
+const blobDataPrefix = "blob:"
+
+// bootstrapReputation is the starting score of operator-configured peers
+const bootstrapReputation = 1.0
+
+// discoveredReputation is the starting score of peers that announced
+// themselves in discovery
+const discoveredReputation = 0.5
+
 // PeerInfo contains information about connected peers
 type PeerInfo struct {
 	NodeID     string
@@ -255,15 +539,82 @@ type PeerInfo struct {
 type InfiniteVectorDatabase struct {
 	mu         sync.RWMutex
 	records    map[string]vectors.DatabaseRecord
+	storedAt   map[string]time.Time
 	indexSpace *vectors.InfiniteVectorIndex
+	filter     *CountingBloomFilter // IDs in records, advertised to peers
+	cache      *vectors.QueryCache  // Network query results, which include local matches
+	clock      core.Clock           // Timestamps records for collection
+}
+
+// Collect removes records stored before cutoff
+func (db *InfiniteVectorDatabase) Collect(cutoff time.Time) int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	removed := 0
+	for id, storedAt := range db.storedAt {
+		if storedAt.Before(cutoff) {
+			if !isPrivate(db.records[id].Metadata) {
+				db.filter.Remove(id)
+			}
+			delete(db.records, id)
+			delete(db.storedAt, id)
+			db.indexSpace.Delete(id)
+			removed++
+		}
+	}
+	if removed > 0 && db.cache != nil {
+		db.cache.Invalidate()
+	}
+	return removed
+}
+
+// remove deletes records by ID, returning how many were held
+func (db *InfiniteVectorDatabase) remove(ids []string) int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	removed := 0
+	for _, id := range ids {
+		if _, exists := db.storedAt[id]; !exists {
+			continue
+		}
+		if !isPrivate(db.records[id].Metadata) {
+			db.filter.Remove(id)
+		}
+		delete(db.records, id)
+		delete(db.storedAt, id)
+		db.indexSpace.Delete(id)
+		removed++
+	}
+	if removed > 0 && db.cache != nil {
+		db.cache.Invalidate()
+	}
+	return removed
+}
+
+// BlobRefs returns the blob hashes referenced by replicated records
+func (db *InfiniteVectorDatabase) BlobRefs() map[string]bool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	refs := make(map[string]bool)
+	for _, record := range db.records {
+		if hash, ok := record.Metadata["blobRef"].(string); ok && hash != "" {
+			refs[hash] = true
+		}
+	}
+	return refs
 }
 
 // PeerDiscoveryMessage handles peer discovery and network topology
 type PeerDiscoveryMessage struct {
-	SenderID    string
-	SenderAddr  string
-	MessageType string
-	Payload     []byte
+	SenderID       string
+	SenderAddr     string
+	MessageType    string
+	Payload        []byte
+	AdmissionNonce uint64 // Sender's proof of work for its ID and SigningKey
+	SigningKey     []byte // Sender's signing key; nil without keys
 }
 
 // DataTransferMessage manages data exchange between nodes
@@ -274,12 +625,12 @@ type DataTransferMessage struct {
 	VectorHash  string
 	Payload     []byte
 	Timestamp   time.Time
-}
+	Epoch       uint64 // Sender's session; sequences restart with each
+	Sequence    uint64 // Per-recipient sequence number assigned by the sender
+	ChunkIndex  int    // Position of this chunk when the payload was split
+	ChunkCount  int    // Number of chunks; 0 or 1 for unsplit payloads
 
-// ReputationManager tracks peer reliability and performance
-type ReputationManager struct {
-	mu             sync.RWMutex
-	peerReputation map[string]float64
+	unknown []byte // Envelope fields from newer wire versions
 }
 
 // NewP2PInfiniteVectorNode creates a new P2P node
@@ -293,14 +644,35 @@ func NewP2PInfiniteVectorNode(address string, port int) *P2PInfiniteVectorNode {
 		Port:    port,
 		localDatabase: &InfiniteVectorDatabase{
 			records:    make(map[string]vectors.DatabaseRecord),
+			storedAt:   make(map[string]time.Time),
 			indexSpace: vectors.NewInfiniteVectorIndex(),
+			filter:     NewCountingBloomFilter(DefaultBloomConfig().Bits, DefaultBloomConfig().Hashes),
+			clock:      core.SystemClock,
 		},
+		clock:            core.SystemClock,
 		peers:            make(map[string]*PeerInfo),
 		discoveryChannel: make(chan PeerDiscoveryMessage, 100),
 		dataChannel:      make(chan DataTransferMessage, 100),
-		reputation: &ReputationManager{
-			peerReputation: make(map[string]float64),
-		},
+		controlQueue:     make(chan DataTransferMessage, 100),
+		bulkQueue:        make(chan DataTransferMessage, 100),
+		bandwidth:        NewBandwidthManager(BandwidthConfig{}),
+		broadcaster:      NewChainBroadcaster(DefaultBroadcastConfig()),
+		chunkSize:        DefaultChunkSize,
+		reassembler:      NewReassembler(DefaultMaxPayloadSize),
+		conns:            make(map[string]Conn),
+		peerAlgorithms:   make(map[string]string),
+		peerVersions:     make(map[string]uint32),
+		peerKeys:         make(map[string][]byte),
+		admission:        openAdmission(),
+		zones:            NewPrivacyZones(),
+		trustAnchors:     NewTrustAnchors(),
+		reputation:       NewReputationManager(DefaultReputationConfig()),
+		replayGuard:      NewReplayGuard(DefaultReplayConfig()),
+		filters:          NewPeerFilters(DefaultBloomConfig()),
+		shards:           NewShardMap(DefaultShardConfig()),
+		peerRoles:        NewPeerRoles(),
+		writeConfig:      DefaultWriteConfig(),
+		lifecycle:        core.NewLifecycleManager(),
 		// Create routing vector with unique generation strategy
 		routingVector: vectors.InfiniteVector{
 			Generator: func(dim int) float64 {
@@ -310,6 +682,7 @@ func NewP2PInfiniteVectorNode(address string, port int) *P2PInfiniteVectorNode {
 			},
 		},
 	}
+	node.SetQueryConfig(DefaultQueryConfig())
 
 	return node
 }
@@ -322,7 +695,11 @@ func generateNodeID() string {
 }
 
 // DiscoverPeers implements a novel peer discovery mechanism
-func (node *P2PInfiniteVectorNode) DiscoverPeers() {
+func (node *P2PInfiniteVectorNode) DiscoverPeers(ctx context.Context) {
+	if node.discoveryDisabled {
+		return
+	}
+
 	// Use routing vector for probabilistic peer selection
 	for {
 		// Simulate peer discovery
@@ -330,17 +707,23 @@ func (node *P2PInfiniteVectorNode) DiscoverPeers() {
 
 		// Send discovery message
 		discoveryMsg := PeerDiscoveryMessage{
-			SenderID:    node.NodeID,
-			SenderAddr:  fmt.Sprintf("%s:%d", node.Address, node.Port),
-			MessageType: "DISCOVER",
-			Payload:     node.serializeRoutingVector(),
+			SenderID:       node.NodeID,
+			SenderAddr:     node.listenAddress(),
+			MessageType:    "DISCOVER",
+			Payload:        node.serializeRoutingVector(),
+			AdmissionNonce: node.localAdmissionNonce(),
+			SigningKey:     node.localSigningKey(),
 		}
 
 		// Probabilistic routing based on vector similarity
 		node.routePeerDiscovery(discoveryMsg, candidatePeer)
 
 		// Wait before next discovery attempt
-		time.Sleep(time.Duration(rand.Intn(30)) * time.Second)
+		select {
+		case <-ctx.Done():
+			return
+		case <-node.clock.After(time.Duration(rand.Intn(30)) * time.Second):
+		}
 	}
 }
 
@@ -353,7 +736,7 @@ func (node *P2PInfiniteVectorNode) generateCandidatePeer() *PeerInfo {
 	return &PeerInfo{
 		NodeID:     peerID,
 		Address:    fmt.Sprintf("192.168.1.%d", rand.Intn(255)),
-		LastSeen:   time.Now(),
+		LastSeen:   node.clock.Now(),
 		Reputation: node.routingVector.GetElement(candidateDimension),
 	}
 }
@@ -383,35 +766,121 @@ func (node *P2PInfiniteVectorNode) connectToPeer(peer *PeerInfo) {
 
 	// Simulate connection (in real implementation, would use actual network connection)
 	node.peers[peer.NodeID] = peer
+	node.reputation.Track(peer.NodeID, peer.Reputation)
 	fmt.Printf("Connected to peer: %s\n", peer.NodeID)
 }
 
-// StoreData adds data to the distributed database
+// DisableDiscovery restricts the node to its bootstrap peers. It must be
+// called before Start.
+func (node *P2PInfiniteVectorNode) DisableDiscovery() {
+	node.discoveryDisabled = true
+}
+
+// AddBootstrapPeer connects to a peer given as "nodeID@host:port"
+func (node *P2PInfiniteVectorNode) AddBootstrapPeer(spec string) error {
+	peer, err := parseBootstrapPeer(spec)
+	if err != nil {
+		return err
+	}
+	if peer.NodeID == node.NodeID {
+		return nil
+	}
+	node.connectToPeer(peer)
+	return nil
+}
+
+// parseBootstrapPeer parses a "nodeID@host:port" peer address
+func parseBootstrapPeer(spec string) (*PeerInfo, error) {
+	nodeID, address, ok := strings.Cut(spec, "@")
+	if !ok || nodeID == "" {
+		return nil, fmt.Errorf("bootstrap peer %q must be nodeID@host:port", spec)
+	}
+	address, err := ParsePeerAddress(address, 0)
+	if err != nil {
+		return nil, fmt.Errorf("bootstrap peer %q: %w", spec, err)
+	}
+
+	return &PeerInfo{
+		NodeID:     nodeID,
+		Address:    address,
+		LastSeen:   time.Now(),
+		Reputation: bootstrapReputation,
+	}, nil
+}
+
+// replicationFactor is how many peers a record or blob is replicated to
+const replicationFactor = 3
+
+// StoreData adds data to the distributed database, replicating it without
+// waiting for the replicas; use Store to wait for acknowledgments
 func (node *P2PInfiniteVectorNode) StoreData(record vectors.DatabaseRecord) {
-	// Replicate data across multiple peers
-	replicationFactor := 3
-	selectedPeers := node.selectReplicationPeers(replicationFactor)
-
-	// Create data transfer messages
-	for _, peer := range selectedPeers {
-		dataMsg := DataTransferMessage{
-			SenderID:    node.NodeID,
-			RecipientID: peer.NodeID,
-			DataID:      record.ID,
-			Payload:     node.serializeRecord(record),
-		}
+	node.Store(context.Background(), record, WriteFireAndForget)
+}
+
+// put stores a record, adding new IDs to the filter
+func (db *InfiniteVectorDatabase) put(record vectors.DatabaseRecord) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if _, exists := db.records[record.ID]; !exists {
+		db.filter.Add(record.ID)
+	}
+	db.records[record.ID] = record
+	db.storedAt[record.ID] = db.clock.Now()
+}
+
+// send queues a payload for a peer, splitting it above the chunk size
+func (node *P2PInfiniteVectorNode) send(peerID, dataID string, payload []byte, priority MessagePriority) {
+	dataMsg := DataTransferMessage{
+		SenderID:    node.NodeID,
+		RecipientID: peerID,
+		DataID:      dataID,
+		Payload:     payload,
+		Timestamp:   time.Now(),
+	}
+
+	for _, chunk := range splitPayload(dataMsg, node.chunkSize) {
+		chunk.Epoch = node.replayGuard.Epoch()
+		chunk.Sequence = node.replayGuard.NextSequence(peerID)
 
-		// Send to data channel for processing
-		node.dataChannel <- dataMsg
+		// Queue for the send loop
+		node.enqueue(chunk, priority)
 	}
+}
 
-	// Store locally
+// UseClock sets the clock that drives discovery, reputation decay and
+// record collection. It must be called before Start.
+func (node *P2PInfiniteVectorNode) UseClock(clock core.Clock) {
+	node.clock = clock
+	node.reputation.UseClock(clock)
+	node.broadcaster.UseClock(clock)
 	node.localDatabase.mu.Lock()
-	node.localDatabase.records[record.ID] = record
+	node.localDatabase.clock = clock
 	node.localDatabase.mu.Unlock()
 }
 
-// selectReplicationPeers chooses peers for data replication
+// UseBlobStore keeps blobs replicated by peers in store. It must be called
+// before Start.
+func (node *P2PInfiniteVectorNode) UseBlobStore(store *BlobStore) {
+	node.blobs = store
+}
+
+// ReplicateBlob sends a blob's content to the replication peers
+func (node *P2PInfiniteVectorNode) ReplicateBlob(hash string, data []byte) {
+	for _, peer := range node.selectReplicationPeers(replicationFactor) {
+		node.send(peer.NodeID, blobDataPrefix+hash, data, PriorityBulk)
+	}
+}
+
+// storeBlob keeps a replicated blob if its content matches the hash
+func (node *P2PInfiniteVectorNode) storeBlob(hash string, data []byte) {
+	if node.blobs == nil || !node.roles.Roles.Has(RoleStorage) || blobHash(data) != hash {
+		return
+	}
+	node.blobs.PutBytes(data)
+}
+
+// selectReplicationPeers chooses peers for data replication among those
+// that hold replicas
 func (node *P2PInfiniteVectorNode) selectReplicationPeers(count int) []*PeerInfo {
 	node.peerMutex.RLock()
 	defer node.peerMutex.RUnlock()
@@ -421,7 +890,9 @@ func (node *P2PInfiniteVectorNode) selectReplicationPeers(count int) []*PeerInfo
 	// Convert peers to slice for sorting
 	peerList := make([]*PeerInfo, 0, len(node.peers))
 	for _, peer := range node.peers {
-		peerList = append(peerList, peer)
+		if node.peerRoles.Has(peer.NodeID, RoleStorage) {
+			peerList = append(peerList, peer)
+		}
 	}
 
 	// Sort peers by similarity to routing vector
@@ -452,45 +923,52 @@ func (node *P2PInfiniteVectorNode) computePeerSimilarity(peer *PeerInfo) float64
 	return similarity / 10.0
 }
 
-// QueryData retrieves data across the network
-func (node *P2PInfiniteVectorNode) QueryData(queryVector vectors.InfiniteVector) []vectors.DatabaseRecord {
-	var results []vectors.DatabaseRecord
-
-	// Local search
-	localResults := node.localDatabase.indexSpace.AdvancedQuery(
-		0.7,
-		queryVector,
-		50,
-	)
-	results = append(results, localResults...)
-
-	// Distributed search
-	for _, peer := range node.peers {
-		// Send query to peers
-		queryMsg := DataTransferMessage{
-			SenderID:    node.NodeID,
-			RecipientID: peer.NodeID,
-			Payload:     node.serializeVector(queryVector),
-		}
-
-		// Simulate distributed query (would use network in real implementation)
-		peerResults := node.queryPeer(queryMsg)
-		results = append(results, peerResults...)
-	}
-
-	return results
+// QueryData retrieves data across the network, comparing the first dims
+// dimensions of each vector. Peers whose record filters hold none of ids,
+// or no records at all when ids is empty, are not queried. Peers that miss
+// their deadline are left out; use Query to learn which.
+func (node *P2PInfiniteVectorNode) QueryData(queryVector vectors.InfiniteVector, dims int, ids ...string) []vectors.DatabaseRecord {
+	return node.Query(context.Background(), queryVector, dims, ids...).Records
 }
 
 // Main network initialization and startup
 func (node *P2PInfiniteVectorNode) Start() {
 	// Start peer discovery
-	go node.DiscoverPeers()
+	node.lifecycle.Go(node.DiscoverPeers)
 
 	// Start data transfer handler
-	go node.handleDataTransfer()
+	node.lifecycle.Go(node.handleDataTransfer)
+
+	// Start outbound scheduler
+	node.lifecycle.Go(node.sendLoop)
 
 	// Start reputation management
-	go node.manageReputation()
+	node.lifecycle.Go(node.manageReputation)
+
+	// Start advertising the record filter
+	node.lifecycle.Go(node.advertiseFilters)
+
+	// Start sending batched chain registrations
+	node.lifecycle.Go(node.broadcastChains)
+
+	// Start proving privacy zone membership to peers
+	node.lifecycle.Go(node.announceZones)
+
+	// Start rebalancing shards as peers join and leave
+	node.lifecycle.Go(node.rebalanceShards)
+
+	// Start advertising the node's roles
+	node.lifecycle.Go(node.advertiseRoles)
+}
+
+// Stop closes the transport and ends the node's loops and connection
+// handlers, waiting until they have returned or ctx is done. Messages sent
+// after Stop are dropped.
+func (node *P2PInfiniteVectorNode) Stop(ctx context.Context) error {
+	if err := node.CloseTransport(); err != nil {
+		return fmt.Errorf("failed to close transport: %w", err)
+	}
+	return node.lifecycle.Stop(ctx)
 }
 
 // Placeholder methods for serialization and other network operations
@@ -500,8 +978,17 @@ func (node *P2PInfiniteVectorNode) serializeRoutingVector() []byte {
 }
 
 func (node *P2PInfiniteVectorNode) serializeRecord(record vectors.DatabaseRecord) []byte {
-	// Serialize database record
-	return []byte{}
+	// Serialize database record; vectors are generated, so only identity
+	// and metadata travel
+	wire, err := RecordProto(&record, 0)
+	if err != nil {
+		return []byte{}
+	}
+	data, err := proto.Marshal(wire)
+	if err != nil {
+		return []byte{}
+	}
+	return data
 }
 
 func (node *P2PInfiniteVectorNode) serializeVector(vector vectors.InfiniteVector) []byte {
@@ -509,14 +996,19 @@ func (node *P2PInfiniteVectorNode) serializeVector(vector vectors.InfiniteVector
 	return []byte{}
 }
 
-func (node *P2PInfiniteVectorNode) queryPeer(msg DataTransferMessage) []vectors.DatabaseRecord {
+func (node *P2PInfiniteVectorNode) queryPeer(ctx context.Context, msg DataTransferMessage) ([]vectors.DatabaseRecord, error) {
+	if node.querier != nil {
+		return node.querier.QueryPeer(ctx, msg)
+	}
 	// Simulate peer querying
-	return []vectors.DatabaseRecord{}
+	return []vectors.DatabaseRecord{}, nil
 }
 
-func (node *P2PInfiniteVectorNode) handleDataTransfer() {
+func (node *P2PInfiniteVectorNode) handleDataTransfer(ctx context.Context) {
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case discoveryMsg := <-node.discoveryChannel:
 			// Handle peer discovery messages
 			node.processPeerDiscovery(discoveryMsg)
@@ -527,26 +1019,448 @@ func (node *P2PInfiniteVectorNode) handleDataTransfer() {
 	}
 }
 
+// processPeerDiscovery records the address a peer advertises. Messages
+// with an address that cannot be dialed are dropped.
 func (node *P2PInfiniteVectorNode) processPeerDiscovery(msg PeerDiscoveryMessage) {
-	// Process peer discovery logic
+	if msg.SenderID == "" || msg.SenderID == node.NodeID {
+		return
+	}
+	address, err := ParsePeerAddress(msg.SenderAddr, node.Port)
+	if err != nil {
+		return
+	}
+
+	node.peerMutex.Lock()
+	if peer, exists := node.peers[msg.SenderID]; exists {
+		peer.Address = address
+		peer.LastSeen = node.clock.Now()
+		node.peerMutex.Unlock()
+		return
+	}
+	node.peerMutex.Unlock()
+
+	// New peers pay the admission cost before entering the peer set. The
+	// key is only claimed here; the handshake admits the peer again with the
+	// key it proves it holds.
+	if err := node.admitPeer(msg.SenderID, msg.SigningKey, msg.AdmissionNonce); err != nil {
+		return
+	}
+	node.connectToPeer(&PeerInfo{
+		NodeID:     msg.SenderID,
+		Address:    address,
+		LastSeen:   node.clock.Now(),
+		Reputation: discoveredReputation,
+	})
+}
+
+// enqueue schedules an outbound message at the given priority, dropping it
+// once the node has stopped
+func (node *P2PInfiniteVectorNode) enqueue(msg DataTransferMessage, priority MessagePriority) {
+	queue := node.bulkQueue
+	if priority == PriorityControl {
+		queue = node.controlQueue
+	}
+	select {
+	case queue <- msg:
+	case <-node.lifecycle.Done():
+	}
+}
+
+// sendLoop drains the outbound queues, always preferring control traffic
+// and holding bulk messages until the recipient's bandwidth budget allows
+func (node *P2PInfiniteVectorNode) sendLoop(ctx context.Context) {
+	for {
+		var msg DataTransferMessage
+		priority := PriorityControl
+
+		select {
+		case msg = <-node.controlQueue:
+		default:
+			select {
+			case <-ctx.Done():
+				return
+			case msg = <-node.controlQueue:
+			case msg = <-node.bulkQueue:
+				priority = PriorityBulk
+			}
+		}
+
+		for {
+			delay := node.bandwidth.Reserve(msg.RecipientID, len(msg.Payload), priority)
+			if delay == 0 {
+				break
+			}
+			// Keep gossip flowing while bulk traffic waits for budget
+			select {
+			case <-ctx.Done():
+				return
+			case control := <-node.controlQueue:
+				node.bandwidth.Reserve(control.RecipientID, len(control.Payload), PriorityControl)
+				node.transmit(control)
+			case <-time.After(delay):
+			}
+		}
+		node.transmit(msg)
+	}
+}
+
+// transmit hands a message to the network
+func (node *P2PInfiniteVectorNode) transmit(msg DataTransferMessage) {
+	node.connMu.Lock()
+	transport := node.transport
+	node.connMu.Unlock()
+
+	// Simulated transport when none is configured
+	if transport == nil {
+		return
+	}
+
+	conn, err := node.peerConn(transport, msg.RecipientID)
+	if err != nil {
+		return
+	}
+
+	envelope := msg.proto()
+	if version, exists := node.PeerVersion(msg.RecipientID); exists {
+		if err := downgradeEnvelope(envelope, version); err != nil {
+			return
+		}
+	}
+	if err := writeFrame(conn, envelope); err != nil {
+		node.connMu.Lock()
+		delete(node.conns, msg.RecipientID)
+		node.connMu.Unlock()
+		conn.Close()
+	}
+}
+
+// hasTransport reports whether messages go over a real network
+func (node *P2PInfiniteVectorNode) hasTransport() bool {
+	node.connMu.Lock()
+	defer node.connMu.Unlock()
+	return node.transport != nil
+}
+
+// UseTransport switches the node from simulated delivery to a real
+// transport and starts accepting inbound channels
+func (node *P2PInfiniteVectorNode) UseTransport(transport Transport) error {
+	listener, err := transport.Listen(node.listenAddress())
+	if err != nil {
+		return err
+	}
+
+	node.connMu.Lock()
+	node.transport = transport
+	node.listener = listener
+	node.connMu.Unlock()
+
+	node.lifecycle.Go(func(ctx context.Context) {
+		node.acceptLoop(ctx, listener)
+	})
+	return nil
+}
+
+// CloseTransport closes the listener and all peer channels
+func (node *P2PInfiniteVectorNode) CloseTransport() error {
+	node.connMu.Lock()
+	defer node.connMu.Unlock()
+
+	if node.transport == nil {
+		return nil
+	}
+	for peerID, conn := range node.conns {
+		conn.Close()
+		delete(node.conns, peerID)
+	}
+	node.listener.Close()
+	err := node.transport.Close()
+	node.transport = nil
+	node.listener = nil
+	return err
+}
+
+// listenAddress is the node's host:port. An empty or unspecified host
+// listens on every IPv4 and IPv6 address.
+func (node *P2PInfiniteVectorNode) listenAddress() string {
+	return net.JoinHostPort(node.Address, strconv.Itoa(node.Port))
+}
+
+// dialAddress returns the address transport dials to reach a peer. Peers
+// advertised without a port listen on the same port as this node; libp2p
+// addresses are passed through, as they name the peer.
+func (node *P2PInfiniteVectorNode) dialAddress(transport Transport, address string) (string, error) {
+	if transport.Name() == "libp2p" {
+		return address, nil
+	}
+	address, err := ParsePeerAddress(address, node.Port)
+	if err != nil {
+		return "", err
+	}
+	return multiaddrHostPort(address), nil
+}
+
+// peerConn returns the open channel to a peer, dialing it if needed
+func (node *P2PInfiniteVectorNode) peerConn(transport Transport, peerID string) (Conn, error) {
+	node.connMu.Lock()
+	conn, exists := node.conns[peerID]
+	node.connMu.Unlock()
+	if exists {
+		return conn, nil
+	}
+
+	node.peerMutex.RLock()
+	peer, known := node.peers[peerID]
+	node.peerMutex.RUnlock()
+	if !known {
+		return nil, fmt.Errorf("unknown peer %s", peerID)
+	}
+
+	address, err := node.dialAddress(transport, peer.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	conn, err = transport.Dial(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	channel, err := node.dialHandshake(conn, peerID)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn = channel
+
+	node.connMu.Lock()
+	node.conns[peerID] = conn
+	node.connMu.Unlock()
+	return conn, nil
+}
+
+func (node *P2PInfiniteVectorNode) acceptLoop(ctx context.Context, listener Listener) {
+	defer context.AfterFunc(ctx, func() { listener.Close() })()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		node.lifecycle.Go(func(ctx context.Context) {
+			node.readLoop(ctx, conn)
+		})
+	}
+}
+
+// readLoop answers the peer's handshake and runs the key exchange if the
+// agreed version calls for it, then decodes inbound messages and hands them
+// to the data handler. The connection is closed when ctx is done.
+func (node *P2PInfiniteVectorNode) readLoop(ctx context.Context, conn Conn) {
+	defer conn.Close()
+	defer context.AfterFunc(ctx, func() { conn.Close() })()
+
+	reader := bufio.NewReader(conn)
+	var frame pb.Handshake
+	if err := readFrame(reader, &frame, frameOverhead); err != nil || frame.GetNodeId() == "" {
+		return
+	}
+	hello := handshakeFromProto(&frame)
+	reply, err := node.acceptHandshake(conn, hello)
+	if err != nil {
+		return
+	}
+	if reply.Version >= secureWireVersion {
+		secure, err := node.acceptKeyExchange(conn, reader, hello, reply.Algorithm)
+		if err != nil {
+			return
+		}
+		reader = bufio.NewReader(secure)
+	}
+	node.setPeerAlgorithm(hello.NodeID, reply.Algorithm)
+	node.setPeerVersion(hello.NodeID, reply.Version)
+
+	for {
+		var envelope pb.Envelope
+		if err := readFrame(reader, &envelope, node.reassembler.MaxPayload()+frameOverhead); err != nil {
+			return
+		}
+		if err := upgradeEnvelope(&envelope); err != nil {
+			return
+		}
+		select {
+		case node.dataChannel <- envelopeFromProto(&envelope):
+		case <-ctx.Done():
+			return
+		}
+	}
 }
 
 func (node *P2PInfiniteVectorNode) processDataTransfer(msg DataTransferMessage) {
-	// Process data transfer logic
+	node.bandwidth.RecordIn(msg.SenderID, len(msg.Payload))
+
+	// Drop duplicates and replays before they can re-insert records
+	if !node.replayGuard.Accept(msg) {
+		return
+	}
+
+	// Hold chunks until the whole payload has arrived
+	msg, complete := node.reassembler.Add(msg)
+	if !complete {
+		return
+	}
+
+	if msg.DataID == bloomDataID {
+		node.receiveFilter(msg.SenderID, msg.Payload)
+		return
+	}
+
+	if msg.DataID == rolesDataID {
+		node.receiveRoles(msg.SenderID, msg.Payload)
+		return
+	}
+
+	if writeID, isAck := strings.CutPrefix(msg.DataID, ackDataPrefix); isAck {
+		node.receiveAck(msg.SenderID, writeID)
+		return
+	}
+
+	if node.ingestPaused.Load() && !isControlData(msg.DataID) {
+		node.ingestDropped.Add(1)
+		return
+	}
+
+	if msg.DataID == chainBatchDataID {
+		node.receiveRecordBatch(msg.Payload)
+		return
+	}
+
+	if hash, isBlob := strings.CutPrefix(msg.DataID, blobDataPrefix); isBlob {
+		node.storeBlob(hash, msg.Payload)
+		return
+	}
+
+	if path, isZone := strings.CutPrefix(msg.DataID, zoneDataPrefix); isZone {
+		node.receiveZoneData(msg, path)
+		return
+	}
+
+	// Store the replicated record, acknowledging it if the writer waits.
+	// Nodes without the storage role only keep chain registrations.
+	var replica pb.DatabaseRecord
+	if err := proto.Unmarshal(msg.Payload, &replica); err != nil {
+		return
+	}
+	if !node.roles.Roles.Has(RoleStorage) && replica.GetMetadata().AsMap()["type"] != "chain_registration" {
+		return
+	}
+	if node.storeReplica(&replica) {
+		if writeID, acked := strings.CutPrefix(msg.DataID, writeDataPrefix); acked {
+			node.send(msg.SenderID, ackDataPrefix+writeID, nil, PriorityControl)
+		}
+	}
+}
+
+// storeReplica stores a record replicated in the clear by a peer, reporting
+// whether it was valid
+func (node *P2PInfiniteVectorNode) storeReplica(replica *pb.DatabaseRecord) bool {
+	if replica.GetId() == "" {
+		return false
+	}
+
+	// Private records only arrive sealed, so a plain record claiming a zone
+	// is stripped of it
+	metadata := replica.GetMetadata().AsMap()
+	delete(metadata, "zone")
+	node.localDatabase.put(vectors.DatabaseRecord{
+		ID:       replica.GetId(),
+		Metadata: metadata,
+	})
+	return true
+}
+
+// isControlData reports whether a payload is gossip the node keeps taking
+// while ingest is paused: filters, which are handled before the check, and
+// zone membership proofs
+func isControlData(dataID string) bool {
+	path, isZone := strings.CutPrefix(dataID, zoneDataPrefix)
+	return isZone && strings.HasSuffix(path, "/"+zoneMemberSuffix)
+}
+
+// PauseIngest stops or resumes storing records and blobs replicated by
+// peers
+func (node *P2PInfiniteVectorNode) PauseIngest(paused bool) {
+	node.ingestPaused.Store(paused)
+}
+
+// IngestPaused reports whether replicated payloads are being dropped
+func (node *P2PInfiniteVectorNode) IngestPaused() bool {
+	return node.ingestPaused.Load()
+}
+
+// IngestDropped returns how many replicated payloads were dropped while
+// ingest was paused
+func (node *P2PInfiniteVectorNode) IngestDropped() uint64 {
+	return node.ingestDropped.Load()
 }
 
-func (node *P2PInfiniteVectorNode) manageReputation() {
+// SetChunkSize sets the largest payload sent in one message; zero disables
+// chunking
+func (node *P2PInfiniteVectorNode) SetChunkSize(size int) {
+	node.chunkSize = size
+}
+
+// Reassembler returns the node's inbound chunk reassembler
+func (node *P2PInfiniteVectorNode) Reassembler() *Reassembler {
+	return node.reassembler
+}
+
+// ReplayGuard returns the node's inbound replay protection
+func (node *P2PInfiniteVectorNode) ReplayGuard() *ReplayGuard {
+	return node.replayGuard
+}
+
+func (node *P2PInfiniteVectorNode) manageReputation(ctx context.Context) {
 	// Periodic reputation updates
 	for {
-		node.reputation.mu.Lock()
-		// Update peer reputations based on performance
-		for peerID := range node.reputation.peerReputation {
-			// Adjust reputation calculations
-			node.reputation.peerReputation[peerID] *= 0.9 // Decay factor
+		// Decay reputations and drop peers that fall below the ban threshold
+		for _, peerID := range node.reputation.Decay() {
+			node.dropPeer(peerID)
 		}
-		node.reputation.mu.Unlock()
 
 		// Wait before next update
-		time.Sleep(10 * time.Minute)
+		select {
+		case <-ctx.Done():
+			return
+		case <-node.clock.After(node.reputation.Config().DecayInterval):
+		}
 	}
 }
+
+// PeerCount returns the number of connected peers
+func (node *P2PInfiniteVectorNode) PeerCount() int {
+	node.peerMutex.RLock()
+	defer node.peerMutex.RUnlock()
+	return len(node.peers)
+}
+
+// Peers returns the connected peers, sorted by ID
+func (node *P2PInfiniteVectorNode) Peers() []PeerInfo {
+	node.peerMutex.RLock()
+	peers := make([]PeerInfo, 0, len(node.peers))
+	for _, peer := range node.peers {
+		peers = append(peers, *peer)
+	}
+	node.peerMutex.RUnlock()
+	sort.Slice(peers, func(i, j int) bool { return peers[i].NodeID < peers[j].NodeID })
+	return peers
+}
+
+// Bandwidth returns the node's bandwidth manager
+func (node *P2PInfiniteVectorNode) Bandwidth() *BandwidthManager {
+	return node.bandwidth
+}
+
+// Reputation returns the node's reputation manager
+func (node *P2PInfiniteVectorNode) Reputation() *ReputationManager {
+	return node.reputation
+}
@@ -0,0 +1,201 @@
+package agglomerator
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// P2PAPI exposes the P2P node's peer management over HTTP
+type P2PAPI struct {
+	p2p *P2PAgglomerator
+}
+
+func NewP2PAPI(p2p *P2PAgglomerator) *P2PAPI {
+	return &P2PAPI{p2p: p2p}
+}
+
+func (api *P2PAPI) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/stats", api.GetStats)
+	r.Get("/reputation", api.ListReputation)
+	r.Route("/reputation/{peerID}", func(r chi.Router) {
+		r.Put("/pin", api.PinPeer)
+		r.Delete("/pin", api.UnpinPeer)
+		r.Put("/boost", api.BoostPeer)
+		r.Put("/penalize", api.PenalizePeer)
+	})
+	r.Get("/attestation", api.GetAttestation)
+	r.Get("/trust-anchors", api.ListTrustAnchors)
+	r.Post("/trust-anchors", api.AddTrustAnchor)
+	r.Delete("/trust-anchors/{name}", api.RemoveTrustAnchor)
+	r.Get("/roles", api.GetRoles)
+	r.Get("/shards", api.GetShards)
+	r.Post("/shards/rebalance", api.RebalanceShards)
+
+	return r
+}
+
+// reputationRequest is the body accepted by the pin/boost/penalize endpoints
+type reputationRequest struct {
+	Score  float64 `json:"score"`
+	Amount float64 `json:"amount"`
+}
+
+// GetStats reports node-level P2P counters
+func (api *P2PAPI) GetStats(w http.ResponseWriter, r *http.Request) {
+	node := api.p2p.p2pNode
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"nodeId":      node.NodeID,
+		"peers":       node.PeerCount(),
+		"replay":      node.ReplayGuard().Stats(),
+		"bandwidth":   node.Bandwidth().Stats(),
+		"broadcast":   node.Broadcaster().Stats(),
+		"bloom":       node.BloomStats(),
+		"queries":     node.QueryStats(),
+		"queryCache":  node.QueryCacheStats(),
+		"consistency": node.ConsistencyStats(),
+		"admission":   node.Admission().Stats(),
+		"ingest": map[string]interface{}{
+			"paused":  node.IngestPaused(),
+			"dropped": node.IngestDropped(),
+		},
+	})
+}
+
+func (api *P2PAPI) ListReputation(w http.ResponseWriter, r *http.Request) {
+	reputation := api.p2p.p2pNode.Reputation()
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"config": reputation.Config(),
+		"peers":  reputation.List(),
+	})
+}
+
+func (api *P2PAPI) PinPeer(w http.ResponseWriter, r *http.Request) {
+	var req reputationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	rep, err := api.p2p.p2pNode.Reputation().Pin(chi.URLParam(r, "peerID"), req.Score)
+	respondReputation(w, rep, err)
+}
+
+func (api *P2PAPI) UnpinPeer(w http.ResponseWriter, r *http.Request) {
+	rep, err := api.p2p.p2pNode.Reputation().Unpin(chi.URLParam(r, "peerID"))
+	respondReputation(w, rep, err)
+}
+
+func (api *P2PAPI) BoostPeer(w http.ResponseWriter, r *http.Request) {
+	api.adjustPeer(w, r, 1)
+}
+
+func (api *P2PAPI) PenalizePeer(w http.ResponseWriter, r *http.Request) {
+	api.adjustPeer(w, r, -1)
+}
+
+func (api *P2PAPI) adjustPeer(w http.ResponseWriter, r *http.Request, sign float64) {
+	var req reputationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Amount <= 0 {
+		respondError(w, http.StatusBadRequest, "amount must be positive")
+		return
+	}
+
+	rep, err := api.p2p.p2pNode.Reputation().Adjust(chi.URLParam(r, "peerID"), sign*req.Amount)
+	respondReputation(w, rep, err)
+}
+
+// GetAttestation returns the attestation this node presents to peers
+func (api *P2PAPI) GetAttestation(w http.ResponseWriter, r *http.Request) {
+	attestation := api.p2p.p2pNode.Attestation()
+	if attestation == nil {
+		respondError(w, http.StatusNotFound, "node has no attestation")
+		return
+	}
+	respondJSON(w, http.StatusOK, attestation)
+}
+
+// ListTrustAnchors returns the CA keys peers' attestations must chain to
+func (api *P2PAPI) ListTrustAnchors(w http.ResponseWriter, r *http.Request) {
+	anchors := api.p2p.p2pNode.TrustAnchors()
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"required": anchors.Required(),
+		"anchors":  anchors.List(),
+	})
+}
+
+// AddTrustAnchor trusts a CA key until restart; add it to the config to keep it
+func (api *P2PAPI) AddTrustAnchor(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name      string `json:"name"`
+		PublicKey string `json:"publicKey"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	anchor, err := api.p2p.p2pNode.TrustAnchors().Add(req.Name, req.PublicKey)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusCreated, anchor)
+}
+
+func (api *P2PAPI) RemoveTrustAnchor(w http.ResponseWriter, r *http.Request) {
+	if err := api.p2p.p2pNode.TrustAnchors().Remove(chi.URLParam(r, "name")); err != nil {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func respondReputation(w http.ResponseWriter, rep PeerReputation, err error) {
+	if errors.Is(err, ErrPeerNotFound) {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, rep)
+}
+
+// GetRoles reports the roles this node serves and those its peers
+// advertised
+func (api *P2PAPI) GetRoles(w http.ResponseWriter, r *http.Request) {
+	node := api.p2p.p2pNode
+	roles := node.Roles()
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"nodeId": node.NodeID,
+		"roles":  roles.Roles.All(),
+		"apiURL": roles.APIURL,
+		"peers":  node.ListPeerRoles(),
+	})
+}
+
+// GetShards reports the shard map and the records this node holds per shard
+func (api *P2PAPI) GetShards(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, api.p2p.p2pNode.ShardStatus())
+}
+
+// RebalanceShards reassigns shards to the current peers now, rather than at
+// the next membership check
+func (api *P2PAPI) RebalanceShards(w http.ResponseWriter, r *http.Request) {
+	result, err := api.p2p.p2pNode.RebalanceShards()
+	if errors.Is(err, ErrShardingDisabled) {
+		respondError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,223 @@
+package agglomerator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// A minimal Parquet writer for exports read by analysis tools: one row
+// group of required columns, PLAIN encoded and uncompressed, with one data
+// page per column. Only the types exports need are supported.
+
+const parquetMagic = "PAR1"
+
+// Parquet physical types, encodings and the other enums the writer uses
+const (
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetRequired  = 0
+	parquetUTF8      = 0
+	parquetPlain     = 0
+	parquetRLE       = 3
+	parquetDataPage  = 0
+	parquetCodecNone = 0
+)
+
+// parquetColumn is a column of a Parquet export; either Strings or Doubles
+// holds its values
+type parquetColumn struct {
+	Name    string
+	Strings []string
+	Doubles []float64
+}
+
+func (c parquetColumn) physicalType() int32 {
+	if c.Strings != nil {
+		return parquetByteArray
+	}
+	return parquetDouble
+}
+
+// plain encodes the column's values with the PLAIN encoding
+func (c parquetColumn) plain() []byte {
+	var buf bytes.Buffer
+	if c.Strings != nil {
+		for _, value := range c.Strings {
+			binary.Write(&buf, binary.LittleEndian, uint32(len(value)))
+			buf.WriteString(value)
+		}
+		return buf.Bytes()
+	}
+	for _, value := range c.Doubles {
+		binary.Write(&buf, binary.LittleEndian, math.Float64bits(value))
+	}
+	return buf.Bytes()
+}
+
+// writeParquet writes rows of columns, each holding rows values, as a
+// Parquet file
+func writeParquet(w io.Writer, columns []parquetColumn, rows int) error {
+	var file bytes.Buffer
+	file.WriteString(parquetMagic)
+
+	type chunk struct {
+		offset, size int64
+	}
+	chunks := make([]chunk, len(columns))
+	var total int64
+	for i, column := range columns {
+		data := column.plain()
+		var header thriftWriter
+		header.field(1, thriftI32, parquetDataPage)
+		header.field(2, thriftI32, int64(len(data)))
+		header.field(3, thriftI32, int64(len(data)))
+		header.beginStruct(5)
+		header.field(1, thriftI32, int64(rows))
+		header.field(2, thriftI32, parquetPlain)
+		header.field(3, thriftI32, parquetRLE)
+		header.field(4, thriftI32, parquetRLE)
+		header.endStruct()
+		header.stop()
+
+		chunks[i] = chunk{offset: int64(file.Len()), size: int64(header.buf.Len() + len(data))}
+		total += chunks[i].size
+		file.Write(header.buf.Bytes())
+		file.Write(data)
+	}
+
+	var meta thriftWriter
+	meta.field(1, thriftI32, 1)
+	meta.beginList(2, thriftStruct, len(columns)+1)
+	meta.beginElement()
+	meta.binaryField(4, "schema")
+	meta.field(5, thriftI32, int64(len(columns)))
+	meta.endElement()
+	for _, column := range columns {
+		meta.beginElement()
+		meta.field(1, thriftI32, int64(column.physicalType()))
+		meta.field(3, thriftI32, parquetRequired)
+		meta.binaryField(4, column.Name)
+		if column.Strings != nil {
+			meta.field(6, thriftI32, parquetUTF8)
+		}
+		meta.endElement()
+	}
+	meta.field(3, thriftI64, int64(rows))
+	meta.beginList(4, thriftStruct, 1)
+	meta.beginElement()
+	meta.beginList(1, thriftStruct, len(columns))
+	for i, column := range columns {
+		meta.beginElement()
+		meta.field(2, thriftI64, chunks[i].offset)
+		meta.beginStruct(3)
+		meta.field(1, thriftI32, int64(column.physicalType()))
+		meta.beginList(2, thriftI32, 1)
+		meta.varint(parquetPlain)
+		meta.beginList(3, thriftBinary, 1)
+		meta.binary(column.Name)
+		meta.field(4, thriftI32, parquetCodecNone)
+		meta.field(5, thriftI64, int64(rows))
+		meta.field(6, thriftI64, chunks[i].size)
+		meta.field(7, thriftI64, chunks[i].size)
+		meta.field(9, thriftI64, chunks[i].offset)
+		meta.endStruct()
+		meta.endElement()
+	}
+	meta.field(2, thriftI64, total)
+	meta.field(3, thriftI64, int64(rows))
+	meta.endElement()
+	meta.binaryField(6, "hydap")
+	meta.stop()
+
+	file.Write(meta.buf.Bytes())
+	binary.Write(&file, binary.LittleEndian, uint32(meta.buf.Len()))
+	file.WriteString(parquetMagic)
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+// Thrift compact protocol field types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs with the Thrift compact protocol, which
+// Parquet uses for its page headers and footer
+type thriftWriter struct {
+	buf  bytes.Buffer
+	last []int16 // Last field ID written, per open struct
+	id   int16
+}
+
+func (t *thriftWriter) header(id int16, kind byte) {
+	if delta := id - t.id; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | kind)
+	} else {
+		t.buf.WriteByte(kind)
+		t.varint(int64(id))
+	}
+	t.id = id
+}
+
+// varint writes a zigzag-encoded integer
+func (t *thriftWriter) varint(v int64) {
+	t.buf.Write(binary.AppendUvarint(nil, uint64((v<<1)^(v>>63))))
+}
+
+func (t *thriftWriter) binary(s string) {
+	t.buf.Write(binary.AppendUvarint(nil, uint64(len(s))))
+	t.buf.WriteString(s)
+}
+
+// field writes an integer field
+func (t *thriftWriter) field(id int16, kind byte, v int64) {
+	t.header(id, kind)
+	t.varint(v)
+}
+
+func (t *thriftWriter) binaryField(id int16, s string) {
+	t.header(id, thriftBinary)
+	t.binary(s)
+}
+
+func (t *thriftWriter) beginStruct(id int16) {
+	t.header(id, thriftStruct)
+	t.beginElement()
+}
+
+func (t *thriftWriter) endStruct() {
+	t.endElement()
+}
+
+// beginList writes a list field's header; its size elements follow
+func (t *thriftWriter) beginList(id int16, kind byte, size int) {
+	t.header(id, 9)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | kind)
+	} else {
+		t.buf.WriteByte(0xf0 | kind)
+		t.buf.Write(binary.AppendUvarint(nil, uint64(size)))
+	}
+}
+
+// beginElement opens a struct whose field IDs restart from zero
+func (t *thriftWriter) beginElement() {
+	t.last = append(t.last, t.id)
+	t.id = 0
+}
+
+func (t *thriftWriter) endElement() {
+	t.stop()
+	t.id = t.last[len(t.last)-1]
+	t.last = t.last[:len(t.last)-1]
+}
+
+func (t *thriftWriter) stop() {
+	t.buf.WriteByte(0)
+}
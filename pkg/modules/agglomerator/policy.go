@@ -0,0 +1,224 @@
+package agglomerator
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrPolicyDenied = errors.New("transaction denied by policy")
+
+// Policy actions
+const (
+	PolicyAllow = "allow"
+	PolicyDeny  = "deny"
+)
+
+// Transaction metadata keys read by policy rules
+const (
+	policyFromAddressKey = "fromAddress"
+	policyToAddressKey   = "toAddress"
+	policyTenantKey      = "tenant"
+)
+
+// PolicyRule allows or denies the transactions it matches. A rule matches
+// when every criterion it sets matches; a rule without criteria matches all
+// transactions.
+type PolicyRule struct {
+	Name       string   `json:"name"`
+	Action     string   `json:"action"`               // PolicyAllow or PolicyDeny
+	Addresses  []string `json:"addresses,omitempty"`  // Either metadata address, case-insensitive
+	ChainPairs []string `json:"chainPairs,omitempty"` // "from->to", either side may be "*"
+	Payload    string   `json:"payload,omitempty"`    // Regular expression over transaction data
+	Tenants    []string `json:"tenants,omitempty"`    // Metadata tenant
+	MinValue   float64  `json:"minValue,omitempty"`   // Valued at least this much; unset if 0
+	MaxValue   float64  `json:"maxValue,omitempty"`   // Valued at most this much; unset if 0
+}
+
+// PolicyConfig is an ordered rule set; the first matching rule decides
+type PolicyConfig struct {
+	DryRun        bool         `json:"dryRun"`        // Report denials without blocking
+	DefaultAction string       `json:"defaultAction"` // When no rule matches; allow if empty
+	Rules         []PolicyRule `json:"rules"`
+}
+
+// PolicyDecision is the outcome of evaluating a transaction
+type PolicyDecision struct {
+	TxID      string    `json:"txId"`
+	FromChain string    `json:"fromChain"`
+	ToChain   string    `json:"toChain"`
+	Rule      string    `json:"rule,omitempty"` // Empty when the default action applied
+	Action    string    `json:"action"`
+	DryRun    bool      `json:"dryRun"`
+	Blocked   bool      `json:"blocked"` // Denied outside dry-run mode
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type compiledRule struct {
+	PolicyRule
+	addresses map[string]bool
+	tenants   map[string]bool
+	pairs     [][2]string
+	payload   *regexp.Regexp
+}
+
+// PolicyEngine evaluates transactions against a rule set that can be
+// replaced while transactions are processed
+type PolicyEngine struct {
+	config PolicyConfig
+	rules  []compiledRule
+	mu     sync.RWMutex
+}
+
+func NewPolicyEngine(config PolicyConfig) (*PolicyEngine, error) {
+	engine := &PolicyEngine{}
+	if err := engine.Reload(config); err != nil {
+		return nil, err
+	}
+	return engine, nil
+}
+
+// Reload replaces the rule set. The current rules are kept if the new ones
+// do not compile.
+func (e *PolicyEngine) Reload(config PolicyConfig) error {
+	rules, err := compilePolicy(config)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.config = config
+	e.rules = rules
+	return nil
+}
+
+// Config returns the rule set in force
+func (e *PolicyEngine) Config() PolicyConfig {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.config
+}
+
+func compilePolicy(config PolicyConfig) ([]compiledRule, error) {
+	switch config.DefaultAction {
+	case "", PolicyAllow, PolicyDeny:
+	default:
+		return nil, fmt.Errorf("unknown default action %q", config.DefaultAction)
+	}
+
+	names := make(map[string]bool)
+	rules := make([]compiledRule, 0, len(config.Rules))
+	for i, rule := range config.Rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rule %d: name required", i)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("rule %s: duplicate name", rule.Name)
+		}
+		names[rule.Name] = true
+		if rule.Action != PolicyAllow && rule.Action != PolicyDeny {
+			return nil, fmt.Errorf("rule %s: unknown action %q", rule.Name, rule.Action)
+		}
+
+		if rule.MinValue < 0 || rule.MaxValue < 0 || (rule.MaxValue > 0 && rule.MaxValue < rule.MinValue) {
+			return nil, fmt.Errorf("rule %s: invalid value range", rule.Name)
+		}
+
+		compiled := compiledRule{PolicyRule: rule}
+		if len(rule.Addresses) > 0 {
+			compiled.addresses = make(map[string]bool, len(rule.Addresses))
+			for _, address := range rule.Addresses {
+				compiled.addresses[strings.ToLower(address)] = true
+			}
+		}
+		if len(rule.Tenants) > 0 {
+			compiled.tenants = make(map[string]bool, len(rule.Tenants))
+			for _, tenant := range rule.Tenants {
+				compiled.tenants[tenant] = true
+			}
+		}
+		for _, pair := range rule.ChainPairs {
+			from, to, ok := strings.Cut(pair, "->")
+			if !ok || from == "" || to == "" {
+				return nil, fmt.Errorf("rule %s: chain pair %q must be \"from->to\"", rule.Name, pair)
+			}
+			compiled.pairs = append(compiled.pairs, [2]string{from, to})
+		}
+		if rule.Payload != "" {
+			payload, err := regexp.Compile(rule.Payload)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: invalid payload pattern: %w", rule.Name, err)
+			}
+			compiled.payload = payload
+		}
+		rules = append(rules, compiled)
+	}
+	return rules, nil
+}
+
+func (r *compiledRule) matches(tx *Transaction) bool {
+	if r.addresses != nil &&
+		!r.addresses[strings.ToLower(tx.Metadata[policyFromAddressKey])] &&
+		!r.addresses[strings.ToLower(tx.Metadata[policyToAddressKey])] {
+		return false
+	}
+	if r.tenants != nil && !r.tenants[tx.Metadata[policyTenantKey]] {
+		return false
+	}
+	if r.pairs != nil {
+		matched := false
+		for _, pair := range r.pairs {
+			if (pair[0] == "*" || pair[0] == tx.FromChain) && (pair[1] == "*" || pair[1] == tx.ToChain) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if (r.MinValue > 0 || r.MaxValue > 0) && tx.Asset == "" {
+		return false // Only valued transactions are in range
+	}
+	if r.MinValue > 0 && tx.Value < r.MinValue {
+		return false
+	}
+	if r.MaxValue > 0 && tx.Value > r.MaxValue {
+		return false
+	}
+	if r.payload != nil && !r.payload.Match(tx.Data) {
+		return false
+	}
+	return true
+}
+
+// Evaluate decides whether tx may be routed
+func (e *PolicyEngine) Evaluate(tx *Transaction) PolicyDecision {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	decision := PolicyDecision{
+		TxID:      tx.ID,
+		FromChain: tx.FromChain,
+		ToChain:   tx.ToChain,
+		Action:    PolicyAllow,
+		DryRun:    e.config.DryRun,
+		CreatedAt: time.Now(),
+	}
+	if e.config.DefaultAction != "" {
+		decision.Action = e.config.DefaultAction
+	}
+	for i := range e.rules {
+		if e.rules[i].matches(tx) {
+			decision.Rule = e.rules[i].Name
+			decision.Action = e.rules[i].Action
+			break
+		}
+	}
+	decision.Blocked = decision.Action == PolicyDeny && !decision.DryRun
+	return decision
+}
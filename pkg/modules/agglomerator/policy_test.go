@@ -0,0 +1,105 @@
+package agglomerator
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPolicyConfig() PolicyConfig {
+	return PolicyConfig{
+		Rules: []PolicyRule{
+			{Name: "trusted-tenant", Action: PolicyAllow, Tenants: []string{"acme"}},
+			{Name: "sanctioned", Action: PolicyDeny, Addresses: []string{"0xABCDEF"}},
+			{Name: "no-btc-to-sol", Action: PolicyDeny, ChainPairs: []string{"btc->sol"}},
+			{Name: "no-scripts", Action: PolicyDeny, ChainPairs: []string{"*->eth"}, Payload: `(?i)<script`},
+		},
+	}
+}
+
+func TestPolicyEngineEvaluate(t *testing.T) {
+	engine, err := NewPolicyEngine(testPolicyConfig())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		tx     Transaction
+		rule   string
+		action string
+	}{
+		{"no rule matches", Transaction{FromChain: "eth", ToChain: "sol"}, "", PolicyAllow},
+		{"address list", Transaction{FromChain: "eth", ToChain: "sol", Metadata: map[string]string{"toAddress": "0xabcdef"}}, "sanctioned", PolicyDeny},
+		{"chain pair", Transaction{FromChain: "btc", ToChain: "sol"}, "no-btc-to-sol", PolicyDeny},
+		{"payload pattern", Transaction{FromChain: "sol", ToChain: "eth", Data: []byte("<SCRIPT>")}, "no-scripts", PolicyDeny},
+		{"payload on another pair", Transaction{FromChain: "eth", ToChain: "sol", Data: []byte("<script>")}, "", PolicyAllow},
+		{"earlier allow wins", Transaction{FromChain: "btc", ToChain: "sol", Metadata: map[string]string{"tenant": "acme"}}, "trusted-tenant", PolicyAllow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := engine.Evaluate(&tt.tx)
+			assert.Equal(t, tt.rule, decision.Rule)
+			assert.Equal(t, tt.action, decision.Action)
+			assert.Equal(t, tt.action == PolicyDeny, decision.Blocked)
+		})
+	}
+}
+
+func TestPolicyEngineDryRunAndReload(t *testing.T) {
+	config := testPolicyConfig()
+	config.DryRun = true
+	config.DefaultAction = PolicyDeny
+	engine, err := NewPolicyEngine(config)
+	require.NoError(t, err)
+
+	decision := engine.Evaluate(&Transaction{FromChain: "eth", ToChain: "sol"})
+	assert.Equal(t, PolicyDeny, decision.Action, "the default action applies when no rule matches")
+	assert.True(t, decision.DryRun)
+	assert.False(t, decision.Blocked, "dry runs report denials without blocking")
+
+	err = engine.Reload(PolicyConfig{Rules: []PolicyRule{{Name: "bad", Action: PolicyDeny, Payload: "("}}})
+	assert.Error(t, err)
+	assert.Equal(t, config, engine.Config(), "invalid rules leave the current ones in force")
+
+	require.NoError(t, engine.Reload(PolicyConfig{}))
+	assert.Equal(t, PolicyAllow, engine.Evaluate(&Transaction{FromChain: "btc", ToChain: "sol"}).Action)
+
+	for _, invalid := range []PolicyConfig{
+		{Rules: []PolicyRule{{Action: PolicyDeny}}},
+		{Rules: []PolicyRule{{Name: "a", Action: "block"}}},
+		{Rules: []PolicyRule{{Name: "a", Action: PolicyDeny}, {Name: "a", Action: PolicyAllow}}},
+		{Rules: []PolicyRule{{Name: "a", Action: PolicyDeny, ChainPairs: []string{"btc"}}}},
+		{DefaultAction: "block"},
+	} {
+		_, err := compilePolicy(invalid)
+		assert.Error(t, err)
+	}
+}
+
+func TestTransactionStorePolicyDecisions(t *testing.T) {
+	store, err := NewTransactionStore(filepath.Join(t.TempDir(), "transactions.db"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, store.RecordPolicyDecision(PolicyDecision{TxID: "tx-1", FromChain: "btc", ToChain: "sol", Rule: "no-btc-to-sol", Action: PolicyDeny, Blocked: true, CreatedAt: old}))
+	require.NoError(t, store.RecordPolicyDecision(PolicyDecision{TxID: "tx-2", FromChain: "eth", ToChain: "sol", Rule: "trusted-tenant", Action: PolicyAllow, CreatedAt: time.Now()}))
+
+	decisions, err := store.PolicyDecisions("", 0)
+	require.NoError(t, err)
+	require.Len(t, decisions, 2)
+	assert.Equal(t, "tx-2", decisions[0].TxID, "newest first")
+	assert.True(t, decisions[1].Blocked)
+
+	decisions, err = store.PolicyDecisions("tx-1", 0)
+	require.NoError(t, err)
+	require.Len(t, decisions, 1)
+	assert.Equal(t, "no-btc-to-sol", decisions[0].Rule)
+
+	store.Collect(time.Now().Add(-time.Minute))
+	decisions, err = store.PolicyDecisions("", 0)
+	require.NoError(t, err)
+	assert.Len(t, decisions, 1, "old decisions are collected")
+}
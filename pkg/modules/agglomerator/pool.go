@@ -0,0 +1,216 @@
+package agglomerator
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrPoolFull = errors.New("transaction pool full")
+
+// Pool admission policies, applied when a chain's pool is full
+const (
+	PoolPolicyReject = "reject" // Turn away new transactions
+	PoolPolicyEvict  = "evict"  // Evict the lowest-ranked transaction if the new one outranks it
+)
+
+// PoolConfig limits each chain's transaction pool
+type PoolConfig struct {
+	MaxSize int            // Transactions held per chain; 0 is unlimited
+	Policy  string         // PoolPolicyReject or PoolPolicyEvict; reject if empty
+	Limits  map[string]int // MaxSize overrides by chain ID
+}
+
+// limitFor returns the pool size limit of a chain
+func (c PoolConfig) limitFor(chainID string) int {
+	if limit, exists := c.Limits[chainID]; exists {
+		return limit
+	}
+	return c.MaxSize
+}
+
+// PoolEntry ranks a transaction held in a chain's pool
+type PoolEntry struct {
+	TxID     string    `json:"txId"`
+	Priority int       `json:"priority"`
+	Fee      float64   `json:"fee"`
+	AddedAt  time.Time `json:"addedAt"`
+}
+
+// outranks orders entries by priority, then fee, then age
+func (e PoolEntry) outranks(other PoolEntry) bool {
+	if e.Priority != other.Priority {
+		return e.Priority > other.Priority
+	}
+	if e.Fee != other.Fee {
+		return e.Fee > other.Fee
+	}
+	return e.AddedAt.Before(other.AddedAt)
+}
+
+// PoolStats reports a chain pool's occupancy and admission decisions
+type PoolStats struct {
+	Size      int     `json:"size"`
+	MaxSize   int     `json:"maxSize"`   // 0 is unlimited
+	Occupancy float64 `json:"occupancy"` // Size over MaxSize, 0 when unlimited
+	Policy    string  `json:"policy"`
+	Admitted  uint64  `json:"admitted"`
+	Rejected  uint64  `json:"rejected"`
+	Evicted   uint64  `json:"evicted"`
+}
+
+// PoolAdmission decides which transactions a chain's pool holds
+type PoolAdmission struct {
+	maxSize  int
+	policy   string
+	entries  map[string]PoolEntry
+	admitted uint64
+	rejected uint64
+	evicted  uint64
+	mu       sync.Mutex
+}
+
+func NewPoolAdmission(maxSize int, policy string) *PoolAdmission {
+	if policy == "" {
+		policy = PoolPolicyReject
+	}
+	return &PoolAdmission{
+		maxSize: maxSize,
+		policy:  policy,
+		entries: make(map[string]PoolEntry),
+	}
+}
+
+// Admit adds an entry, returning the IDs it evicted. It fails with
+// ErrPoolFull when the pool is full and no entry can be evicted for it.
+// Admitting an ID already held updates its ranking.
+func (p *PoolAdmission) Admit(entry PoolEntry) ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, exists := p.entries[entry.TxID]; exists || p.maxSize <= 0 || len(p.entries) < p.maxSize {
+		p.entries[entry.TxID] = entry
+		p.admitted++
+		return nil, nil
+	}
+
+	if p.policy == PoolPolicyEvict {
+		lowest, found := p.lowest()
+		if found && entry.outranks(lowest) {
+			delete(p.entries, lowest.TxID)
+			p.entries[entry.TxID] = entry
+			p.admitted++
+			p.evicted++
+			return []string{lowest.TxID}, nil
+		}
+	}
+
+	p.rejected++
+	return nil, ErrPoolFull
+}
+
+// lowest returns the lowest-ranked entry; callers hold p.mu
+func (p *PoolAdmission) lowest() (PoolEntry, bool) {
+	var lowest PoolEntry
+	found := false
+	for _, entry := range p.entries {
+		if !found || lowest.outranks(entry) {
+			lowest, found = entry, true
+		}
+	}
+	return lowest, found
+}
+
+// Remove forgets an entry once its transaction leaves the pool
+func (p *PoolAdmission) Remove(txID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.entries, txID)
+}
+
+// Entries returns the held entries, highest-ranked first
+func (p *PoolAdmission) Entries() []PoolEntry {
+	p.mu.Lock()
+	entries := make([]PoolEntry, 0, len(p.entries))
+	for _, entry := range p.entries {
+		entries = append(entries, entry)
+	}
+	p.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].outranks(entries[j]) != entries[j].outranks(entries[i]) {
+			return entries[i].outranks(entries[j])
+		}
+		return entries[i].TxID < entries[j].TxID
+	})
+	return entries
+}
+
+// Stats reports occupancy and admission counts
+func (p *PoolAdmission) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := PoolStats{
+		Size:     len(p.entries),
+		MaxSize:  p.maxSize,
+		Policy:   p.policy,
+		Admitted: p.admitted,
+		Rejected: p.rejected,
+		Evicted:  p.evicted,
+	}
+	if p.maxSize > 0 {
+		stats.Occupancy = float64(stats.Size) / float64(p.maxSize)
+	}
+	return stats
+}
+
+// admitToPool makes room in the chain's pool for a transaction, deleting
+// any transactions evicted for it
+func (c *Chain) admitToPool(entry PoolEntry) error {
+	if c.admission == nil {
+		return nil
+	}
+	evicted, err := c.admission.Admit(entry)
+	if err != nil {
+		return fmt.Errorf("chain %s: %w", c.ID, err)
+	}
+	for _, id := range evicted {
+		if c.TransactionPool.Delete(id) {
+			c.events.record(EventTransactionRemoved, c.ID, id, TransactionRemovedData{Reason: RemovedEvicted})
+		}
+	}
+	return nil
+}
+
+// removeFromPool deletes a transaction from the chain's pool, logging why
+func (c *Chain) removeFromPool(txID, reason string) bool {
+	if c.admission != nil {
+		c.admission.Remove(txID)
+	}
+	removed := c.TransactionPool.Delete(txID)
+	if removed {
+		c.events.record(EventTransactionRemoved, c.ID, txID, TransactionRemovedData{Reason: reason})
+	}
+	return removed
+}
+
+// PoolStats reports the occupancy of the chain's pool, or false when it is
+// not registered with an agglomerator
+func (c *Chain) PoolStats() (PoolStats, bool) {
+	if c.admission == nil {
+		return PoolStats{}, false
+	}
+	return c.admission.Stats(), true
+}
+
+// PoolEntries returns the transactions in the chain's pool, highest-ranked
+// first
+func (c *Chain) PoolEntries() []PoolEntry {
+	if c.admission == nil {
+		return nil
+	}
+	return c.admission.Entries()
+}
@@ -0,0 +1,99 @@
+package agglomerator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+func TestPoolAdmissionReject(t *testing.T) {
+	pool := NewPoolAdmission(2, "")
+	now := time.Now()
+
+	_, err := pool.Admit(PoolEntry{TxID: "a", AddedAt: now})
+	require.NoError(t, err)
+	_, err = pool.Admit(PoolEntry{TxID: "b", AddedAt: now})
+	require.NoError(t, err)
+	_, err = pool.Admit(PoolEntry{TxID: "c", Priority: 10, AddedAt: now})
+	assert.ErrorIs(t, err, ErrPoolFull, "the reject policy turns away even higher priorities")
+
+	_, err = pool.Admit(PoolEntry{TxID: "a", Priority: 1, AddedAt: now})
+	assert.NoError(t, err, "held transactions can be re-admitted")
+
+	stats := pool.Stats()
+	assert.Equal(t, PoolStats{Size: 2, MaxSize: 2, Occupancy: 1, Policy: PoolPolicyReject, Admitted: 3, Rejected: 1}, stats)
+}
+
+func TestPoolAdmissionEvict(t *testing.T) {
+	pool := NewPoolAdmission(3, PoolPolicyEvict)
+	now := time.Now()
+	for _, entry := range []PoolEntry{
+		{TxID: "cheap", Fee: 1, AddedAt: now},
+		{TxID: "urgent", Priority: 1, AddedAt: now},
+		{TxID: "old-cheap", Fee: 1, AddedAt: now.Add(-time.Minute)},
+	} {
+		_, err := pool.Admit(entry)
+		require.NoError(t, err)
+	}
+
+	evicted, err := pool.Admit(PoolEntry{TxID: "pricey", Fee: 5, AddedAt: now})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cheap"}, evicted, "at equal fees the newest goes first")
+
+	_, err = pool.Admit(PoolEntry{TxID: "free", AddedAt: now})
+	assert.ErrorIs(t, err, ErrPoolFull, "nothing ranks below a zero fee")
+
+	var order []string
+	for _, entry := range pool.Entries() {
+		order = append(order, entry.TxID)
+	}
+	assert.Equal(t, []string{"urgent", "pricey", "old-cheap"}, order)
+	assert.Equal(t, uint64(1), pool.Stats().Evicted)
+}
+
+func TestRecordTransactionPoolLimits(t *testing.T) {
+	agg := NewAgglomerator(AgglomeratorConfig{Pool: PoolConfig{
+		MaxSize: 1,
+		Policy:  PoolPolicyEvict,
+		Limits:  map[string]int{"sol": 0},
+	}})
+	for _, id := range []string{"eth", "sol"} {
+		chain := NewChain(id, "http://localhost:8545", ProtocolEthereum)
+		chain.StateVector = vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}
+		require.NoError(t, agg.RegisterChain(chain))
+	}
+	newTx := func(id string, fee float64) *Transaction {
+		return &Transaction{ID: id, FromChain: "sol", ToChain: "eth", Fee: fee,
+			StateVector: vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}}
+	}
+
+	_, err := agg.recordTransaction(newTx("low", 1))
+	require.NoError(t, err)
+	_, err = agg.recordTransaction(newTx("lower", 0.5))
+	assert.ErrorIs(t, err, ErrPoolFull)
+
+	_, err = agg.recordTransaction(newTx("high", 2))
+	require.NoError(t, err)
+
+	eth, _ := agg.GetChain("eth")
+	sol, _ := agg.GetChain("sol")
+	_, exists := eth.TransactionPool.Get("low")
+	assert.False(t, exists, "evicted transactions leave the pool")
+	_, exists = eth.TransactionPool.Get("high")
+	assert.True(t, exists)
+	_, exists = sol.TransactionPool.Get("lower")
+	assert.False(t, exists, "a transaction rejected by one pool is kept out of both")
+
+	stats, ok := sol.PoolStats()
+	require.True(t, ok)
+	assert.Equal(t, 2, stats.Size, "limits override maxSize per chain")
+	assert.Zero(t, stats.Occupancy)
+
+	// low and high from the routing index, high from eth and both from sol
+	assert.Equal(t, 5, agg.CollectRecords(time.Now().Add(time.Second)))
+	stats, _ = eth.PoolStats()
+	assert.Zero(t, stats.Size, "collected transactions are released from admission")
+}
@@ -0,0 +1,347 @@
+package agglomerator
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+const (
+	// trafficSlots is the number of hours of the week traffic is learned by
+	trafficSlots = 7 * 24
+
+	// trafficSmoothing weights the latest week of an hour against the
+	// weeks before it
+	trafficSmoothing = 0.3
+
+	// feeEstimateTimeout bounds each fee estimate made while warming
+	feeEstimateTimeout = 5 * time.Second
+)
+
+// PreRoutingConfig controls when routes are warmed ahead of predicted
+// traffic
+type PreRoutingConfig struct {
+	Interval    time.Duration // How often predictions are checked
+	Lead        time.Duration // How far ahead of a predicted burst routes are warmed
+	MinRate     float64       // Predicted transactions per hour that make a burst
+	QuietPeriod time.Duration // Pairs idle for this long are cold
+}
+
+// DefaultPreRoutingConfig checks every minute and warms pairs idle for ten
+// minutes ten minutes before an hour expected to carry ten transactions
+func DefaultPreRoutingConfig() PreRoutingConfig {
+	return PreRoutingConfig{
+		Interval:    time.Minute,
+		Lead:        10 * time.Minute,
+		MinRate:     10,
+		QuietPeriod: 10 * time.Minute,
+	}
+}
+
+// pairTraffic is the learned traffic of one chain pair
+type pairTraffic struct {
+	rates    [trafficSlots]float64 // Smoothed transactions per hour, by hour of the week
+	hour     time.Time             // Start of the hour being counted
+	count    float64               // Transactions so far in hour
+	lastSeen time.Time
+	warmedAt time.Time
+	template *Transaction // Routing fields of the latest transaction, replayed to warm its route
+}
+
+func hourOfWeek(t time.Time) int {
+	t = t.UTC()
+	return int(t.Weekday())*24 + t.Hour()
+}
+
+// roll folds the hours finished before now, quiet ones as zero, into their
+// slots
+func (p *pairTraffic) roll(now time.Time) {
+	hour := now.Truncate(time.Hour)
+	for steps := 0; p.hour.Before(hour) && steps < trafficSlots; steps++ {
+		slot := hourOfWeek(p.hour)
+		p.rates[slot] = trafficSmoothing*p.count + (1-trafficSmoothing)*p.rates[slot]
+		p.count = 0
+		p.hour = p.hour.Add(time.Hour)
+	}
+	p.hour = hour
+}
+
+// PairPrediction is the traffic expected on a chain pair
+type PairPrediction struct {
+	FromChain string    `json:"fromChain"`
+	ToChain   string    `json:"toChain"`
+	Rate      float64   `json:"rate"` // Transactions per hour expected after the lead time
+	LastSeen  time.Time `json:"lastSeen"`
+	WarmedAt  time.Time `json:"warmedAt,omitempty"`
+}
+
+// FeeQuote is a fee estimated ahead of predicted traffic
+type FeeQuote struct {
+	ChainID     string    `json:"chainId"`
+	Fee         float64   `json:"fee"`
+	EstimatedAt time.Time `json:"estimatedAt"`
+}
+
+// PreRoutingStats counts the routes warmed and whether the first
+// transactions after quiet periods found them warm
+type PreRoutingStats struct {
+	Warmed       uint64 `json:"warmed"`
+	WarmStarts   uint64 `json:"warmStarts"` // First transactions after a quiet period on a warmed pair
+	ColdStarts   uint64 `json:"coldStarts"`
+	FeeEstimates uint64 `json:"feeEstimates"`
+}
+
+// PreRouter learns how many transactions each chain pair carries in each
+// hour of the week, and before an hour expected to be busy on a pair that
+// has gone quiet, replays the pair's latest transaction through routing and
+// estimates the destination's fee. The routing index and element caches are
+// then warm when the burst starts.
+type PreRouter struct {
+	agg    *Agglomerator
+	config PreRoutingConfig
+	clock  core.Clock
+	stop   chan struct{}
+
+	mu    sync.Mutex
+	pairs map[[2]string]*pairTraffic
+	fees  map[string]FeeQuote
+	stats PreRoutingStats
+}
+
+// NewPreRouter creates a pre-router for agg; Start runs it
+func NewPreRouter(agg *Agglomerator, config PreRoutingConfig) (*PreRouter, error) {
+	if config.Interval <= 0 || config.Lead < 0 || config.MinRate <= 0 || config.QuietPeriod <= 0 {
+		return nil, errors.New("pre-routing interval, minRate and quietPeriod must be positive")
+	}
+	return &PreRouter{
+		agg:    agg,
+		config: config,
+		clock:  agg.clock,
+		pairs:  make(map[[2]string]*pairTraffic),
+		fees:   make(map[string]FeeQuote),
+	}, nil
+}
+
+// observe learns from a routed transaction
+func (r *PreRouter) observe(tx *Transaction) {
+	now := r.clock.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := [2]string{tx.FromChain, tx.ToChain}
+	pair, exists := r.pairs[key]
+	if !exists {
+		pair = &pairTraffic{hour: now.Truncate(time.Hour)}
+		r.pairs[key] = pair
+	}
+	pair.roll(now)
+	if exists && now.Sub(pair.lastSeen) >= r.config.QuietPeriod {
+		if !pair.warmedAt.IsZero() && now.Sub(pair.warmedAt) < time.Hour {
+			r.stats.WarmStarts++
+		} else {
+			r.stats.ColdStarts++
+		}
+	}
+	pair.count++
+	pair.lastSeen = now
+	pair.template = &Transaction{
+		FromChain:   tx.FromChain,
+		ToChain:     tx.ToChain,
+		Similarity:  tx.Similarity,
+		Dimensions:  tx.Dimensions,
+		StateVector: tx.StateVector,
+	}
+}
+
+// Predict returns the transactions per hour a pair is expected to carry at
+// a time
+func (r *PreRouter) Predict(fromChain, toChain string, at time.Time) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pair, exists := r.pairs[[2]string{fromChain, toChain}]
+	if !exists {
+		return 0
+	}
+	pair.roll(r.clock.Now())
+	return pair.rates[hourOfWeek(at)]
+}
+
+// Predictions returns the traffic expected on each pair after the lead
+// time, busiest first
+func (r *PreRouter) Predictions() []PairPrediction {
+	now := r.clock.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	predictions := make([]PairPrediction, 0, len(r.pairs))
+	for key, pair := range r.pairs {
+		pair.roll(now)
+		predictions = append(predictions, PairPrediction{
+			FromChain: key[0],
+			ToChain:   key[1],
+			Rate:      pair.rates[hourOfWeek(now.Add(r.config.Lead))],
+			LastSeen:  pair.lastSeen,
+			WarmedAt:  pair.warmedAt,
+		})
+	}
+	sort.Slice(predictions, func(i, j int) bool {
+		if predictions[i].Rate != predictions[j].Rate {
+			return predictions[i].Rate > predictions[j].Rate
+		}
+		return predictions[i].FromChain+"->"+predictions[i].ToChain < predictions[j].FromChain+"->"+predictions[j].ToChain
+	})
+	return predictions
+}
+
+// FeeQuote returns the latest fee estimated ahead of traffic to a chain
+func (r *PreRouter) FeeQuote(chainID string) (FeeQuote, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	quote, exists := r.fees[chainID]
+	return quote, exists
+}
+
+// FeeQuotes returns the latest fee estimated for each chain
+func (r *PreRouter) FeeQuotes() []FeeQuote {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	quotes := make([]FeeQuote, 0, len(r.fees))
+	for _, quote := range r.fees {
+		quotes = append(quotes, quote)
+	}
+	sort.Slice(quotes, func(i, j int) bool { return quotes[i].ChainID < quotes[j].ChainID })
+	return quotes
+}
+
+// Stats returns the pre-routing counters
+func (r *PreRouter) Stats() PreRoutingStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
+
+// Config returns the pre-routing settings
+func (r *PreRouter) Config() PreRoutingConfig {
+	return r.config
+}
+
+// Warm warms the routes of the quiet pairs expected to be busy after the
+// lead time, returning how many it warmed. Each pair is warmed at most once
+// an hour.
+func (r *PreRouter) Warm(ctx context.Context) int {
+	now := r.clock.Now()
+	slot := hourOfWeek(now.Add(r.config.Lead))
+
+	r.mu.Lock()
+	var due []*pairTraffic
+	for _, pair := range r.pairs {
+		pair.roll(now)
+		if pair.rates[slot] < r.config.MinRate || pair.template == nil {
+			continue
+		}
+		if now.Sub(pair.lastSeen) < r.config.QuietPeriod {
+			continue // Live traffic keeps it warm
+		}
+		if !pair.warmedAt.IsZero() && now.Sub(pair.warmedAt) < time.Hour {
+			continue
+		}
+		pair.warmedAt = now
+		due = append(due, pair)
+	}
+	r.stats.Warmed += uint64(len(due))
+	r.mu.Unlock()
+
+	for _, pair := range due {
+		r.agg.warmRoute(pair.template)
+		r.estimateFee(ctx, pair.template.ToChain)
+	}
+	return len(due)
+}
+
+// estimateFee asks a chain's adapter for its fee, if it can estimate one
+func (r *PreRouter) estimateFee(ctx context.Context, chainID string) {
+	chain, err := r.agg.GetChain(chainID)
+	if err != nil {
+		return
+	}
+	estimator, ok := chain.Adapter().(FeeEstimator)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, feeEstimateTimeout)
+	defer cancel()
+	fee, err := estimator.EstimateFee(ctx)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fees[chainID] = FeeQuote{ChainID: chainID, Fee: fee, EstimatedAt: r.clock.Now()}
+	r.stats.FeeEstimates++
+}
+
+func (r *PreRouter) run(ticker core.Ticker, stop chan struct{}) {
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C():
+			r.Warm(context.Background())
+		}
+	}
+}
+
+// warmRoute runs the routing work for tx without recording it, filling the
+// query and element caches as a real transaction would
+func (a *Agglomerator) warmRoute(tx *Transaction) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	dims := a.dimsFor(tx)
+	a.vectorIndex.AdvancedQuery(tx.Similarity, tx.StateVector, dims)
+	for _, chain := range a.chains {
+		calculateRouteMetrics(chain, tx, dims)
+	}
+}
+
+// StartPreRouting learns traffic from routed transactions and warms routes
+// ahead of predicted bursts
+func (a *Agglomerator) StartPreRouting(config PreRoutingConfig) error {
+	router, err := NewPreRouter(a, config)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.preRouter != nil {
+		return errors.New("pre-routing already running")
+	}
+	router.stop = make(chan struct{})
+	a.preRouter = router
+	go router.run(a.clock.NewTicker(config.Interval), router.stop)
+	return nil
+}
+
+// StopPreRouting stops learning traffic and warming routes
+func (a *Agglomerator) StopPreRouting() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.preRouter != nil {
+		close(a.preRouter.stop)
+		a.preRouter = nil
+	}
+}
+
+// PreRouter returns the running pre-router, or nil
+func (a *Agglomerator) PreRouter() *PreRouter {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.preRouter
+}
@@ -0,0 +1,60 @@
+package agglomerator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+func TestPreRoutingWarmsAheadOfPredictedBursts(t *testing.T) {
+	// A Monday morning
+	clock := core.NewFakeClock(time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC))
+	agg := NewAgglomerator(AgglomeratorConfig{Clock: clock})
+	for _, id := range []string{"eth", "settle"} {
+		chain := NewChain(id, "mock://local?baseFee=0.5", ProtocolMock)
+		chain.StateVector = vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}
+		require.NoError(t, agg.RegisterChain(chain))
+	}
+	// Warmed by hand rather than on the ticker
+	router, err := NewPreRouter(agg, PreRoutingConfig{Interval: time.Hour, Lead: 15 * time.Minute, MinRate: 10, QuietPeriod: 10 * time.Minute})
+	require.NoError(t, err)
+	agg.preRouter = router
+
+	send := func(id, from, to string) {
+		_, err := agg.recordTransaction(&Transaction{ID: id, FromChain: from, ToChain: to,
+			StateVector: vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}})
+		require.NoError(t, err)
+	}
+	for i := 0; i < 50; i++ {
+		send(fmt.Sprintf("burst-%d", i), "eth", "settle")
+	}
+	send("trickle", "settle", "eth")
+
+	// A week later, shortly before the same hour
+	clock.Advance(7*24*time.Hour - 15*time.Minute)
+	assert.InDelta(t, 15, router.Predict("eth", "settle", clock.Now().Add(15*time.Minute)), 0.001, "the first week counts for the smoothing weight")
+	assert.Zero(t, router.Predict("eth", "settle", clock.Now()), "quiet hours are learned as quiet")
+
+	predictions := router.Predictions()
+	require.Len(t, predictions, 2)
+	assert.Equal(t, "settle", predictions[0].ToChain, "busiest pair first")
+
+	assert.Equal(t, 1, router.Warm(context.Background()), "only the pair expected to burst is warmed")
+	assert.Zero(t, router.Warm(context.Background()), "pairs are warmed once an hour")
+	quote, ok := router.FeeQuote("settle")
+	require.True(t, ok)
+	assert.Equal(t, 0.5, quote.Fee)
+
+	clock.Advance(15 * time.Minute)
+	send("first", "eth", "settle")
+	send("second", "eth", "settle")
+	send("cold", "settle", "eth")
+	stats := router.Stats()
+	assert.Equal(t, PreRoutingStats{Warmed: 1, WarmStarts: 1, ColdStarts: 1, FeeEstimates: 1}, stats)
+}
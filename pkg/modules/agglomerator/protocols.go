@@ -1,6 +1,7 @@
 package agglomerator
 
 import (
+	"fmt"
 	"math"
 )
 
@@ -62,6 +63,24 @@ func getProtocolConfig(protocol string) (ChainProtocol, bool) {
 	return config, exists
 }
 
+// ProtocolConfig returns the routing parameters of a protocol
+func ProtocolConfig(protocol string) (ChainProtocol, bool) {
+	return getProtocolConfig(protocol)
+}
+
+// RegisterProtocol adds or replaces a protocol's routing parameters. It is
+// not safe for concurrent use and should be called before modules start.
+func RegisterProtocol(config ChainProtocol) error {
+	if config.ID == "" {
+		return fmt.Errorf("protocol id is required")
+	}
+	if config.BlockTime <= 0 || config.Finality <= 0 {
+		return fmt.Errorf("protocol %s: blockTime and finality must be positive", config.ID)
+	}
+	protocolConfigs[config.ID] = config
+	return nil
+}
+
 // determineProtocol gets the protocol identifier for a chain
 func determineProtocol(chainID string) string {
 	protocols := map[string]string{
@@ -80,10 +99,14 @@ func determineProtocol(chainID string) string {
 	return "unknown"
 }
 
-// getDefaultGenerator returns a protocol-specific vector generator
-func getDefaultGenerator(chainID string) func(int) float64 {
-	protocol := determineProtocol(chainID)
+// getProtocolGenerator returns a protocol-specific vector generator: the
+// configured generator expression if there is one, else a built-in one
+func getProtocolGenerator(protocol string) func(int) float64 {
 	config, exists := getProtocolConfig(protocol)
+	if expression := generatorExpression(protocol); expression != nil {
+		config.ID = protocol
+		return expression.Generator(config)
+	}
 	if !exists {
 		// Return default generator if protocol not found
 		return func(dim int) float64 {
@@ -0,0 +1,509 @@
+package agglomerator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+// QueryConfig bounds scatter-gather queries across peers
+type QueryConfig struct {
+	PeerTimeout   time.Duration // Deadline for each peer's answer
+	Concurrency   int           // Peers queried at once
+	SlowPenalty   float64       // Reputation lost by a peer that misses its deadline
+	CacheSize     int           // Complete results cached, vectors.DefaultQueryCacheEntries if unset
+	Fanout        int           // Best-reputation peers asked; the rest stand by for hedging. 0 asks every peer
+	HedgeQuantile float64       // Quantile of recent answer times after which a peer is hedged; 0 disables hedging
+}
+
+// DefaultQueryConfig returns the query limits used when none are configured
+func DefaultQueryConfig() QueryConfig {
+	return QueryConfig{
+		PeerTimeout:   2 * time.Second,
+		Concurrency:   16,
+		SlowPenalty:   0.05,
+		CacheSize:     vectors.DefaultQueryCacheEntries,
+		Fanout:        8,
+		HedgeQuantile: 0.95,
+	}
+}
+
+// PeerQuerier asks one peer for the records matching a query. It should
+// return when ctx is done; a querier that does not is abandoned at the
+// deadline.
+type PeerQuerier interface {
+	QueryPeer(ctx context.Context, msg DataTransferMessage) ([]vectors.DatabaseRecord, error)
+}
+
+// QueryResult is the outcome of a query across the network. Records holds
+// every answer that arrived; Partial is set when a queried peer timed out
+// or failed, so records it holds may be missing.
+type QueryResult struct {
+	ID         string                   `json:"id"` // Sent to peers as the DataID of the query
+	Records    []vectors.DatabaseRecord `json:"-"`
+	Queried    int                      `json:"queried"`
+	Responded  int                      `json:"responded"`
+	Skipped    int                      `json:"skipped"` // Ruled out by record filters
+	TimedOut   []string                 `json:"timedOut,omitempty"`
+	Failed     []string                 `json:"failed,omitempty"`
+	Duplicates int                      `json:"duplicates"`
+	Hedged     int                      `json:"hedged"` // Queries re-issued to a standby peer
+	Partial    bool                     `json:"partial"`
+	Cached     bool                     `json:"cached"`
+}
+
+// QueryStats counts the outcome of network queries
+type QueryStats struct {
+	Queries    uint64 `json:"queries"`
+	Partial    uint64 `json:"partial"`
+	Timeouts   uint64 `json:"timeouts"`
+	Failures   uint64 `json:"failures"`
+	Duplicates uint64 `json:"duplicates"`
+	Hedges     uint64 `json:"hedges"`
+	HedgeWins  uint64 `json:"hedgeWins"` // Hedged queries the standby peer answered first
+}
+
+type peerAnswer struct {
+	peerID  string
+	records []vectors.DatabaseRecord
+	err     error
+}
+
+// slotAnswer is the outcome of asking one peer, and possibly its hedge
+type slotAnswer struct {
+	answers  []peerAnswer // Peers that failed, then the peer that answered if one did
+	hedged   bool
+	hedgeWon bool
+}
+
+// standbyPeers hands out the peers held back from a query, best first
+type standbyPeers struct {
+	mu    sync.Mutex
+	peers []string
+}
+
+func (s *standbyPeers) next() (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.peers) == 0 {
+		return "", false
+	}
+	peerID := s.peers[0]
+	s.peers = s.peers[1:]
+	return peerID, true
+}
+
+const (
+	latencyWindowSize = 256 // Peer answer times kept for hedging
+	minLatencySamples = 20  // Answers timed before their quantile is trusted
+)
+
+// latencyWindow keeps the most recent peer answer times
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+func (w *latencyWindow) add(latency time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.samples) < latencyWindowSize {
+		w.samples = append(w.samples, latency)
+		return
+	}
+	w.samples[w.next] = latency
+	w.next = (w.next + 1) % latencyWindowSize
+}
+
+// quantile returns the q quantile of the recent answer times; false until
+// enough answers have been timed
+func (w *latencyWindow) quantile(q float64) (time.Duration, bool) {
+	w.mu.Lock()
+	samples := append([]time.Duration(nil), w.samples...)
+	w.mu.Unlock()
+
+	if len(samples) < minLatencySamples {
+		return 0, false
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	index := int(q * float64(len(samples)-1))
+	return samples[min(max(index, 0), len(samples)-1)], true
+}
+
+// SetQueryConfig sets the limits of network queries. It must be called
+// before Start.
+func (node *P2PInfiniteVectorNode) SetQueryConfig(config QueryConfig) {
+	node.queryConfig = config
+	cacheSize := config.CacheSize
+	if !node.roles.Roles.Has(RoleStorage) {
+		cacheSize = thinQueryCacheEntries
+		if config.CacheSize > 0 {
+			cacheSize = min(config.CacheSize, thinQueryCacheEntries)
+		}
+	}
+	node.queryCache = vectors.NewQueryCache(cacheSize)
+	node.localDatabase.mu.Lock()
+	node.localDatabase.cache = node.queryCache
+	node.localDatabase.mu.Unlock()
+}
+
+// UsePeerQuerier sends peer queries through querier. It must be called
+// before Start; without one, peer queries are simulated and return nothing.
+func (node *P2PInfiniteVectorNode) UsePeerQuerier(querier PeerQuerier) {
+	node.querier = querier
+}
+
+// QueryStats reports the outcome of network queries so far
+func (node *P2PInfiniteVectorNode) QueryStats() QueryStats {
+	node.queryMu.Lock()
+	defer node.queryMu.Unlock()
+	return node.queryStats
+}
+
+// QueryCacheStats reports the cache of complete network query results
+func (node *P2PInfiniteVectorNode) QueryCacheStats() vectors.QueryCacheStats {
+	return node.queryCache.Stats()
+}
+
+// Query searches the local database and every peer that may hold matching
+// records in parallel, comparing the first dims dimensions of each vector.
+// Each peer has QueryConfig.PeerTimeout to answer; the result reports peers
+// that did not, and records seen from several peers are returned once.
+//
+// Only the QueryConfig.Fanout peers with the best reputation are asked. A
+// peer that fails, or has not answered within the HedgeQuantile of recent
+// answer times, is hedged: the query is re-issued to the best peer held
+// back, the first answer is kept and the other request is cancelled.
+//
+// When every peer queried has advertised a record filter, a complete result
+// is cached until a peer advertises a new filter or local records are
+// collected, and repeating the query returns it without asking the peers.
+func (node *P2PInfiniteVectorNode) Query(ctx context.Context, queryVector vectors.InfiniteVector, dims int, ids ...string) QueryResult {
+	result, _ := node.QueryAt(ctx, ReadAny, queryVector, dims, ids...)
+	return result
+}
+
+// QueryAt runs a query at a consistency level. ReadAny is Query. ReadLocal
+// searches only the local database. ReadQuorum and ReadAll ask every peer,
+// without consulting record filters or the cache, which may predate a
+// recent write, and fail with ErrConsistencyNotMet, along with the records
+// gathered, unless a majority or all of them answer.
+func (node *P2PInfiniteVectorNode) QueryAt(ctx context.Context, level ReadConsistency, queryVector vectors.InfiniteVector, dims int, ids ...string) (QueryResult, error) {
+	level, err := ParseReadConsistency(string(level))
+	if err != nil {
+		return QueryResult{}, err
+	}
+	start := time.Now()
+	result, err := node.query(ctx, level, queryVector, dims, ids...)
+	node.consistency.observeRead(level, time.Since(start), err)
+	return result, err
+}
+
+func (node *P2PInfiniteVectorNode) query(ctx context.Context, level ReadConsistency, queryVector vectors.InfiniteVector, dims int, ids ...string) (QueryResult, error) {
+	result := QueryResult{ID: core.NewID()}
+	strict := level == ReadQuorum || level == ReadAll
+
+	// Peers whose record filters rule them out are not queried. Sharded
+	// queries only ask the owners of the shards sought.
+	var targets []string
+	standby := &standbyPeers{}
+	sharded := level != ReadLocal && node.shards.Enabled()
+	if sharded {
+		targets, standby.peers, result.Skipped = node.shardTargets(ids, strict)
+	} else if level != ReadLocal {
+		node.peerMutex.RLock()
+		for peerID := range node.peers {
+			if !node.peerRoles.Has(peerID, RoleStorage) {
+				// The peer holds no records to answer with
+				continue
+			}
+			if strict || node.filters.shouldQuery(peerID, ids) {
+				targets = append(targets, peerID)
+			} else {
+				result.Skipped++
+			}
+		}
+		node.peerMutex.RUnlock()
+	}
+
+	config := node.queryConfig
+	sort.Slice(targets, func(i, j int) bool {
+		a, _ := node.reputation.Score(targets[i])
+		b, _ := node.reputation.Score(targets[j])
+		if a != b {
+			return a > b
+		}
+		return targets[i] < targets[j]
+	})
+	if !sharded && !strict && config.Fanout > 0 && len(targets) > config.Fanout {
+		standby.peers = targets[config.Fanout:]
+		targets = targets[:config.Fanout]
+	}
+	sort.Strings(targets)
+
+	cacheable := !strict && level != ReadLocal
+	for _, peerID := range targets {
+		if _, exists := node.filters.Get(peerID); !exists {
+			cacheable = false
+			break
+		}
+	}
+	var key [32]byte
+	if cacheable {
+		key = queryKey(queryVector, dims, ids, targets)
+		if records, hit := node.queryCache.Get(key); hit {
+			result.Records = records
+			result.Cached = true
+			node.queryMu.Lock()
+			node.queryStats.Queries++
+			node.queryMu.Unlock()
+			return result, nil
+		}
+	}
+
+	seen := make(map[[32]byte]bool)
+	gather := func(records []vectors.DatabaseRecord) {
+		for _, record := range records {
+			hash := recordHash(record)
+			if seen[hash] {
+				result.Duplicates++
+				continue
+			}
+			seen[hash] = true
+			result.Records = append(result.Records, record)
+		}
+	}
+
+	// Local search
+	gather(node.localDatabase.indexSpace.AdvancedQuery(0.7, queryVector, dims))
+	result.Queried = len(targets)
+
+	// Scatter
+	delay := node.hedgeDelay(config)
+	payload := node.serializeVector(queryVector)
+	answers := make(chan slotAnswer, len(targets))
+	slots := make(chan struct{}, max(config.Concurrency, 1))
+	for _, peerID := range targets {
+		go func() {
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			answers <- node.askSlot(ctx, result.ID, peerID, payload, standby, delay, config.PeerTimeout)
+		}()
+	}
+
+	// Gather
+	unanswered := 0
+	hedgeWins := 0
+	for range targets {
+		slot := <-answers
+		if slot.hedged {
+			result.Queried++
+			result.Hedged++
+		}
+		if slot.hedgeWon {
+			hedgeWins++
+		}
+		answered := false
+		for _, answer := range slot.answers {
+			switch {
+			case answer.err == nil:
+				answered = true
+				result.Responded++
+				gather(answer.records)
+			case errors.Is(answer.err, context.DeadlineExceeded) && ctx.Err() == nil:
+				// The peer's own deadline passed, not the caller's
+				result.TimedOut = append(result.TimedOut, answer.peerID)
+				node.reputation.Penalize(answer.peerID, "slow", config.SlowPenalty)
+			default:
+				result.Failed = append(result.Failed, answer.peerID)
+			}
+		}
+		if !answered {
+			unanswered++
+		}
+	}
+	sort.Strings(result.TimedOut)
+	sort.Strings(result.Failed)
+	result.Partial = unanswered > 0
+
+	node.queryMu.Lock()
+	node.queryStats.Queries++
+	if result.Partial {
+		node.queryStats.Partial++
+	}
+	node.queryStats.Timeouts += uint64(len(result.TimedOut))
+	node.queryStats.Failures += uint64(len(result.Failed))
+	node.queryStats.Duplicates += uint64(result.Duplicates)
+	node.queryStats.Hedges += uint64(result.Hedged)
+	node.queryStats.HedgeWins += uint64(hedgeWins)
+	node.queryMu.Unlock()
+
+	// Hedged answers come from peers outside the key
+	if cacheable && !result.Partial && result.Hedged == 0 {
+		node.queryCache.Put(key, result.Records)
+	}
+
+	required := 0
+	switch level {
+	case ReadQuorum:
+		required = quorum(len(targets))
+	case ReadAll:
+		required = len(targets)
+	}
+	if result.Responded < required {
+		return result, fmt.Errorf("%w: %d of %d peers answered, %d required",
+			ErrConsistencyNotMet, result.Responded, len(targets), required)
+	}
+	return result, nil
+}
+
+// queryKey fingerprints a network query by the elements compared, the IDs
+// sought and the peers asked
+func queryKey(queryVector vectors.InfiniteVector, dims int, ids, targets []string) [32]byte {
+	elements := make([]float64, max(dims, 0))
+	for d := range elements {
+		elements[d] = queryVector.GetElement(d)
+	}
+	params := make([]string, 0, len(ids)+len(targets))
+	for _, id := range ids {
+		params = append(params, "id:"+id)
+	}
+	sort.Strings(params)
+	for _, peerID := range targets {
+		params = append(params, "peer:"+peerID)
+	}
+	return vectors.QueryFingerprint(0.7, elements, params...)
+}
+
+// hedgeDelay is how long a peer may take before it is hedged: the
+// configured quantile of recent answer times, or half the peer timeout until
+// enough answers have been timed. Zero disables hedging.
+func (node *P2PInfiniteVectorNode) hedgeDelay(config QueryConfig) time.Duration {
+	if config.HedgeQuantile <= 0 || !node.flagEnabled(FlagHedging) {
+		return 0
+	}
+	if delay, ok := node.latencies.quantile(config.HedgeQuantile); ok {
+		if config.PeerTimeout > 0 && delay > config.PeerTimeout {
+			return config.PeerTimeout
+		}
+		return delay
+	}
+	return config.PeerTimeout / 2
+}
+
+// askSlot asks a peer, re-issuing the query to the next standby peer when
+// it fails or has not answered after delay, and returns once either answers
+// or both fail. The request still running is cancelled.
+func (node *P2PInfiniteVectorNode) askSlot(ctx context.Context, queryID, peerID string, payload []byte, standby *standbyPeers, delay, timeout time.Duration) slotAnswer {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan peerAnswer, 2)
+	ask := func(peerID string) {
+		msg := DataTransferMessage{
+			SenderID:    node.NodeID,
+			RecipientID: peerID,
+			DataID:      queryID,
+			Payload:     payload,
+			Timestamp:   time.Now(),
+			Epoch:       node.replayGuard.Epoch(),
+			Sequence:    node.replayGuard.NextSequence(peerID),
+		}
+		go func() {
+			start := time.Now()
+			records, err := node.askPeer(ctx, msg, timeout)
+			if err == nil {
+				node.latencies.add(time.Since(start))
+			}
+			results <- peerAnswer{peerID: peerID, records: records, err: err}
+		}()
+	}
+
+	var slot slotAnswer
+	var hedge <-chan time.Time
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		hedge = timer.C
+	}
+	pending := 1
+	hedgeNow := func() {
+		hedge = nil
+		if standbyID, ok := standby.next(); ok {
+			ask(standbyID)
+			pending++
+			slot.hedged = true
+		}
+	}
+
+	ask(peerID)
+	for pending > 0 {
+		select {
+		case <-hedge:
+			hedgeNow()
+		case answer := <-results:
+			pending--
+			slot.answers = append(slot.answers, answer)
+			if answer.err == nil {
+				slot.hedgeWon = answer.peerID != peerID
+				return slot
+			}
+			if hedge != nil && ctx.Err() == nil {
+				// Failed before its hedge was due
+				hedgeNow()
+			}
+		}
+	}
+	return slot
+}
+
+// askPeer queries one peer, giving up when its deadline passes even if the
+// querier does not
+func (node *P2PInfiniteVectorNode) askPeer(ctx context.Context, msg DataTransferMessage, timeout time.Duration) ([]vectors.DatabaseRecord, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	answer := make(chan peerAnswer, 1)
+	go func() {
+		records, err := node.queryPeer(ctx, msg)
+		answer <- peerAnswer{records: records, err: err}
+	}()
+
+	select {
+	case a := <-answer:
+		if a.err == nil && ctx.Err() != nil {
+			// Answers that arrive after the deadline are discarded
+			return nil, ctx.Err()
+		}
+		return a.records, a.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// recordHash identifies a record by its ID and metadata, so copies held by
+// several peers are recognised
+func recordHash(record vectors.DatabaseRecord) [32]byte {
+	h := sha256.New()
+	h.Write([]byte(record.ID))
+	h.Write([]byte{0})
+	// Map keys are marshaled in sorted order
+	metadata, _ := json.Marshal(record.Metadata)
+	h.Write(metadata)
+
+	var sum [32]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
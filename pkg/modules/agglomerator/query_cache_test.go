@@ -0,0 +1,111 @@
+package agglomerator
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+	"google.golang.org/protobuf/proto"
+)
+
+func rising(d int) float64  { return float64(d) }
+func falling(d int) float64 { return -float64(d) }
+
+func TestIndexQueryCacheInvalidation(t *testing.T) {
+	index := vectors.NewInfiniteVectorIndex()
+	index.EnableQueryCache(8)
+	require.NoError(t, index.Insert(vectors.DatabaseRecord{ID: "match-1", Vector: vectors.InfiniteVector{Generator: rising}}))
+
+	query := vectors.InfiniteVector{Generator: rising}
+	require.Len(t, index.AdvancedQuery(0.9, query, 10), 1)
+	require.Len(t, index.AdvancedQuery(0.9, query, 10), 1)
+	stats, ok := index.QueryCacheStats()
+	require.True(t, ok)
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+
+	// Records the query would not return leave it cached
+	require.NoError(t, index.Insert(vectors.DatabaseRecord{ID: "other", Vector: vectors.InfiniteVector{Generator: falling}}))
+	stats, _ = index.QueryCacheStats()
+	assert.Equal(t, 1, stats.Entries)
+	assert.Zero(t, stats.Invalidations)
+
+	require.NoError(t, index.Insert(vectors.DatabaseRecord{ID: "match-2", Vector: vectors.InfiniteVector{Generator: rising}}))
+	stats, _ = index.QueryCacheStats()
+	assert.Equal(t, uint64(1), stats.Invalidations, "a matching insert drops the entry")
+	assert.Len(t, index.AdvancedQuery(0.9, query, 10), 2)
+
+	index.Delete("match-1")
+	assert.Len(t, index.AdvancedQuery(0.9, query, 10), 1, "deleting a returned record drops the entry")
+	stats, _ = index.QueryCacheStats()
+	assert.Equal(t, uint64(2), stats.Invalidations)
+	assert.Equal(t, uint64(3), stats.Misses)
+}
+
+func TestQueryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := vectors.NewQueryCache(2)
+	first := vectors.QueryFingerprint(0.7, []float64{1})
+	second := vectors.QueryFingerprint(0.7, []float64{2})
+	third := vectors.QueryFingerprint(0.7, []float64{3})
+
+	cache.Put(first, []vectors.DatabaseRecord{{ID: "a"}})
+	cache.Put(second, []vectors.DatabaseRecord{{ID: "b"}})
+	_, hit := cache.Get(first)
+	require.True(t, hit)
+	cache.Put(third, nil)
+
+	_, hit = cache.Get(second)
+	assert.False(t, hit, "the least recently used entry is evicted")
+	_, hit = cache.Get(first)
+	assert.True(t, hit)
+	assert.Equal(t, uint64(1), cache.Stats().Evictions)
+
+	cache.InvalidateRecord("a")
+	_, hit = cache.Get(first)
+	assert.False(t, hit)
+}
+
+// countingQuerier answers every peer query with one record
+type countingQuerier struct {
+	calls atomic.Int64
+}
+
+func (q *countingQuerier) QueryPeer(ctx context.Context, msg DataTransferMessage) ([]vectors.DatabaseRecord, error) {
+	q.calls.Add(1)
+	return []vectors.DatabaseRecord{{ID: "record-" + msg.RecipientID}}, nil
+}
+
+func TestNetworkQueryCache(t *testing.T) {
+	querier := &countingQuerier{}
+	node := queryTestNode(querier, "peer-a")
+	advertised := NewCountingBloomFilter(1024, 4)
+	advertised.Add("record-peer-a")
+	require.True(t, node.filters.Set("peer-a", advertised.Filter()))
+
+	query := vectors.InfiniteVector{Generator: rising}
+	first := node.Query(context.Background(), query, 10)
+	require.Len(t, first.Records, 1)
+	assert.False(t, first.Cached)
+
+	second := node.Query(context.Background(), query, 10)
+	assert.True(t, second.Cached)
+	assert.Equal(t, first.Records, second.Records)
+	assert.Equal(t, int64(1), querier.calls.Load(), "cached answers are not asked for again")
+
+	// A new filter means the peer's records changed
+	advertised.Add("record-2")
+	payload, err := proto.Marshal(advertised.Filter().proto())
+	require.NoError(t, err)
+	node.receiveFilter("peer-a", payload)
+	assert.False(t, node.Query(context.Background(), query, 10).Cached)
+	assert.Equal(t, int64(2), querier.calls.Load())
+
+	// Peers without a filter may hold anything, so their answers are not cached
+	node.peers["peer-b"] = &PeerInfo{NodeID: "peer-b"}
+	node.Query(context.Background(), query, 10)
+	assert.False(t, node.Query(context.Background(), query, 10).Cached)
+	assert.Equal(t, uint64(1), node.QueryCacheStats().Hits)
+}
@@ -0,0 +1,185 @@
+package agglomerator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+// fakeQuerier answers peer queries from a table
+type fakeQuerier struct {
+	answers map[string][]vectors.DatabaseRecord
+	slow    map[string]bool // Wait for the deadline
+	stuck   map[string]bool // Ignore the deadline until release is closed
+	broken  map[string]bool
+	release chan struct{}
+}
+
+func (q *fakeQuerier) QueryPeer(ctx context.Context, msg DataTransferMessage) ([]vectors.DatabaseRecord, error) {
+	switch peerID := msg.RecipientID; {
+	case q.slow[peerID]:
+		<-ctx.Done()
+		return nil, ctx.Err()
+	case q.stuck[peerID]:
+		<-q.release
+		return nil, nil
+	case q.broken[peerID]:
+		return nil, errors.New("connection reset")
+	default:
+		return q.answers[peerID], nil
+	}
+}
+
+func queryTestNode(querier PeerQuerier, peers ...string) *P2PInfiniteVectorNode {
+	node := NewP2PInfiniteVectorNode("127.0.0.1", 0)
+	node.UsePeerQuerier(querier)
+	node.SetQueryConfig(QueryConfig{PeerTimeout: 50 * time.Millisecond, Concurrency: 2, SlowPenalty: 0.1})
+	for _, peerID := range peers {
+		node.peers[peerID] = &PeerInfo{NodeID: peerID}
+		node.Reputation().Track(peerID, 0.5)
+	}
+	return node
+}
+
+func TestQueryGathersPartialResults(t *testing.T) {
+	shared := vectors.DatabaseRecord{ID: "record-1", Metadata: map[string]interface{}{"type": "chain_registration"}}
+	querier := &fakeQuerier{
+		answers: map[string][]vectors.DatabaseRecord{
+			"peer-a": {shared},
+			"peer-b": {shared, {ID: "record-2"}},
+		},
+		slow:    map[string]bool{"peer-slow": true},
+		stuck:   map[string]bool{"peer-stuck": true},
+		broken:  map[string]bool{"peer-broken": true},
+		release: make(chan struct{}),
+	}
+	defer close(querier.release)
+	node := queryTestNode(querier, "peer-a", "peer-b", "peer-slow", "peer-stuck", "peer-broken")
+
+	start := time.Now()
+	result := node.Query(context.Background(), vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}, 10)
+	assert.Less(t, time.Since(start), time.Second, "slow peers do not hold up the query")
+
+	assert.Equal(t, 5, result.Queried)
+	assert.Equal(t, 2, result.Responded)
+	assert.Len(t, result.Records, 2)
+	assert.Equal(t, 1, result.Duplicates, "records held by several peers are returned once")
+	assert.Equal(t, []string{"peer-slow", "peer-stuck"}, result.TimedOut)
+	assert.Equal(t, []string{"peer-broken"}, result.Failed)
+	assert.True(t, result.Partial)
+
+	slow, _ := node.Reputation().Score("peer-slow")
+	assert.InDelta(t, 0.4, slow, 1e-9, "peers that miss their deadline are penalized")
+	broken, _ := node.Reputation().Score("peer-broken")
+	assert.InDelta(t, 0.5, broken, 1e-9)
+
+	stats := node.QueryStats()
+	assert.Equal(t, uint64(1), stats.Partial)
+	assert.Equal(t, uint64(2), stats.Timeouts)
+}
+
+func TestQueryCancelledByCaller(t *testing.T) {
+	querier := &fakeQuerier{slow: map[string]bool{"peer-slow": true}}
+	node := queryTestNode(querier, "peer-slow")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result := node.Query(ctx, vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}, 10)
+	require.Equal(t, []string{"peer-slow"}, result.Failed)
+	assert.Empty(t, result.TimedOut)
+
+	score, _ := node.Reputation().Score("peer-slow")
+	assert.InDelta(t, 0.5, score, 1e-9, "peers are not penalized for the caller giving up")
+}
+
+// idQuerier records the DataID of each query it answers
+type idQuerier struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+func (q *idQuerier) QueryPeer(ctx context.Context, msg DataTransferMessage) ([]vectors.DatabaseRecord, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.ids = append(q.ids, msg.DataID)
+	return nil, nil
+}
+
+func TestQueryIDsAreTimeOrdered(t *testing.T) {
+	querier := &idQuerier{}
+	node := queryTestNode(querier, "peer-a", "peer-b")
+	vector := vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}
+
+	first := node.Query(context.Background(), vector, 10)
+	second := node.Query(context.Background(), vector, 10)
+	require.NotEmpty(t, first.ID)
+	assert.True(t, first.ID < second.ID, "later queries sort after earlier ones")
+	assert.ElementsMatch(t, []string{first.ID, first.ID, second.ID, second.ID}, querier.ids,
+		"every peer is sent the query's ID")
+
+	issued, err := core.IDTime(first.ID)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), issued, time.Minute)
+}
+
+func TestQueryHedgesSlowAndFailedPeers(t *testing.T) {
+	querier := &fakeQuerier{
+		answers: map[string][]vectors.DatabaseRecord{
+			"standby-1": {{ID: "record-1"}},
+			"standby-2": {{ID: "record-2"}},
+		},
+		slow:   map[string]bool{"peer-slow": true},
+		broken: map[string]bool{"peer-broken": true},
+	}
+	node := queryTestNode(querier, "peer-slow", "peer-broken", "standby-1", "standby-2")
+	node.SetQueryConfig(QueryConfig{PeerTimeout: time.Second, Concurrency: 4, SlowPenalty: 0.1, Fanout: 2, HedgeQuantile: 0.95})
+	for peerID, score := range map[string]float64{"peer-slow": 0.9, "peer-broken": 0.8, "standby-1": 0.2, "standby-2": 0.1} {
+		_, err := node.Reputation().Pin(peerID, score)
+		require.NoError(t, err)
+	}
+
+	start := time.Now()
+	result := node.Query(context.Background(), vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}, 10)
+	assert.Less(t, time.Since(start), time.Second, "the hedge answers before the slow peer's deadline")
+
+	assert.Equal(t, 4, result.Queried)
+	assert.Equal(t, 2, result.Hedged)
+	assert.Equal(t, 2, result.Responded)
+	assert.Len(t, result.Records, 2)
+	assert.Equal(t, []string{"peer-broken"}, result.Failed)
+	assert.Empty(t, result.TimedOut, "the slow peer is cancelled once its hedge answers")
+	assert.False(t, result.Partial)
+
+	stats := node.QueryStats()
+	assert.Equal(t, uint64(2), stats.Hedges)
+	assert.Equal(t, uint64(2), stats.HedgeWins)
+}
+
+func TestLatencyWindowQuantile(t *testing.T) {
+	var window latencyWindow
+	for i := 1; i < minLatencySamples; i++ {
+		window.add(time.Duration(i) * time.Millisecond)
+	}
+	_, ok := window.quantile(0.95)
+	assert.False(t, ok, "too few answers timed")
+
+	for i := minLatencySamples; i <= 100; i++ {
+		window.add(time.Duration(i) * time.Millisecond)
+	}
+	p95, ok := window.quantile(0.95)
+	require.True(t, ok)
+	assert.Equal(t, 95*time.Millisecond, p95)
+
+	for i := 0; i < latencyWindowSize; i++ {
+		window.add(time.Millisecond)
+	}
+	p95, _ = window.quantile(0.95)
+	assert.Equal(t, time.Millisecond, p95, "older answers fall out of the window")
+}
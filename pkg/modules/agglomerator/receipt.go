@@ -0,0 +1,157 @@
+package agglomerator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// receiptConfirmationTimeout bounds each confirmation looked up while a
+// receipt bundle is assembled
+const receiptConfirmationTimeout = 5 * time.Second
+
+var ErrReceiptTampered = errors.New("receipt bundle does not match its digest")
+
+// Roles of the chains a transaction passes through
+const (
+	HopSource      = "source"
+	HopDestination = "destination"
+)
+
+// ReceiptHop is the evidence of a transaction on one chain: the receipt of
+// its submission there, if it was submitted, and the chain adapter's
+// confirmation of it
+type ReceiptHop struct {
+	ChainID      string        `json:"chainId"`
+	Role         string        `json:"role"`
+	TxHash       string        `json:"txHash,omitempty"`
+	BlockHeight  uint64        `json:"blockHeight,omitempty"`
+	BlockHash    string        `json:"blockHash,omitempty"`
+	FeePayer     string        `json:"feePayer,omitempty"`
+	Confirmation *Confirmation `json:"confirmation,omitempty"`
+	Error        string        `json:"error,omitempty"` // Why the confirmation could not be looked up
+}
+
+// ReceiptSignature is a node's signature over a receipt bundle's digest
+type ReceiptSignature struct {
+	NodeID    string `json:"nodeId"`
+	Algorithm string `json:"algorithm"`
+	Key       []byte `json:"key"`
+	Signature []byte `json:"signature"`
+}
+
+// ReceiptBundle is the evidence that a transaction completed, assembled
+// when it does, for downstream systems to verify. Digest is the SHA-256 of
+// the bundle without its digest and signatures; the routing node signs it
+// when it has node keys.
+type ReceiptBundle struct {
+	TxID        string             `json:"txId"`
+	FromChain   string             `json:"fromChain"`
+	ToChain     string             `json:"toChain"`
+	Hops        []ReceiptHop       `json:"hops"`
+	CompletedAt time.Time          `json:"completedAt"`
+	Digest      string             `json:"digest"`
+	Signatures  []ReceiptSignature `json:"signatures,omitempty"`
+}
+
+// digest hashes the bundle's content
+func (b ReceiptBundle) digest() (string, error) {
+	b.Digest, b.Signatures = "", nil
+	encoded, err := json.Marshal(b)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode receipt bundle: %w", err)
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Verify checks the bundle against its digest and, with keys, each
+// signature over it
+func (b ReceiptBundle) Verify(keys NodeKeys) error {
+	digest, err := b.digest()
+	if err != nil {
+		return err
+	}
+	if digest != b.Digest {
+		return ErrReceiptTampered
+	}
+	if keys == nil {
+		return nil
+	}
+	for _, signature := range b.Signatures {
+		if err := keys.Verify(signature.Algorithm, signature.Key, []byte(b.Digest), signature.Signature); err != nil {
+			return fmt.Errorf("signature of %s: %w", signature.NodeID, err)
+		}
+	}
+	return nil
+}
+
+// receiptHop assembles the evidence on one chain, looking up the
+// transaction through the chain's adapter
+func (a *Agglomerator) receiptHop(ctx context.Context, chainID, role string, tx *Transaction, receipt *SubmitReceipt) ReceiptHop {
+	hop := ReceiptHop{ChainID: chainID, Role: role}
+	if receipt != nil && receipt.ChainID == chainID {
+		hop.TxHash = receipt.TxHash
+		hop.BlockHeight = receipt.BlockHeight
+		hop.BlockHash = receipt.BlockHash
+		hop.FeePayer = receipt.FeePayer
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, receiptConfirmationTimeout)
+	defer cancel()
+	confirmation, err := a.ConfirmTransaction(ctx, chainID, tx.ID)
+	if err != nil {
+		hop.Error = err.Error()
+		return hop
+	}
+	hop.Confirmation = confirmation
+	return hop
+}
+
+// assembleReceipt builds the receipt bundle of a completed transaction:
+// the evidence on its source chain and on its destination, signed with the
+// node's keys when it has them
+func (m *AgglomeratorModule) assembleReceipt(tx *Transaction, completedAt time.Time) (*ReceiptBundle, error) {
+	agg := m.GetAgglomerator()
+	ctx := context.Background()
+	bundle := &ReceiptBundle{
+		TxID:        tx.ID,
+		FromChain:   tx.FromChain,
+		ToChain:     tx.ToChain,
+		Hops:        []ReceiptHop{agg.receiptHop(ctx, tx.FromChain, HopSource, tx, tx.Receipt)},
+		CompletedAt: completedAt,
+	}
+	if tx.ToChain != tx.FromChain {
+		bundle.Hops = append(bundle.Hops, agg.receiptHop(ctx, tx.ToChain, HopDestination, tx, tx.Receipt))
+	}
+
+	digest, err := bundle.digest()
+	if err != nil {
+		return nil, err
+	}
+	bundle.Digest = digest
+
+	p2p := m.GetP2P()
+	if p2p == nil {
+		return bundle, nil
+	}
+	keys := p2p.p2pNode.nodeKeys()
+	if keys == nil {
+		return bundle, nil
+	}
+	signature, err := keys.Sign([]byte(digest))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign receipt bundle: %w", err)
+	}
+	bundle.Signatures = []ReceiptSignature{{
+		NodeID:    p2p.p2pNode.NodeID,
+		Algorithm: keys.SigningAlgorithm(),
+		Key:       keys.SigningKey(),
+		Signature: signature,
+	}}
+	return bundle, nil
+}
@@ -0,0 +1,59 @@
+package agglomerator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReceiptRecordedOnCompletion(t *testing.T) {
+	module := newRerouteModule(t)
+	require.NoError(t, module.ProcessTransaction(&Transaction{ID: "tx-1", FromChain: "source", ToChain: "up-a", Priority: 3}))
+
+	bundle, err := module.txStore.Receipt("tx-1")
+	require.NoError(t, err)
+	assert.Equal(t, "source", bundle.FromChain)
+	assert.Equal(t, "up-a", bundle.ToChain)
+	require.Len(t, bundle.Hops, 2)
+
+	source, destination := bundle.Hops[0], bundle.Hops[1]
+	assert.Equal(t, HopSource, source.Role)
+	assert.Empty(t, source.TxHash, "only the destination is submitted to")
+	assert.False(t, source.Confirmation.Found)
+	assert.Equal(t, HopDestination, destination.Role)
+	assert.Equal(t, mockHash("up-a", "tx", "tx-1"), destination.TxHash)
+	assert.NotEmpty(t, destination.BlockHash)
+	require.NotNil(t, destination.Confirmation)
+	assert.True(t, destination.Confirmation.Found)
+	assert.Equal(t, destination.BlockHeight, destination.Confirmation.BlockHeight)
+	require.NoError(t, bundle.Verify(nil))
+
+	// Failed transactions have no receipt
+	require.ErrorIs(t, module.ProcessTransaction(&Transaction{ID: "tx-2", FromChain: "source", ToChain: "down", Priority: 3}), ErrMockSubmitFailed)
+	_, err = module.txStore.Receipt("tx-2")
+	assert.ErrorIs(t, err, ErrTransactionNotFound)
+}
+
+func TestReceiptSignedAndVerified(t *testing.T) {
+	module := newRerouteModule(t)
+	keys := newTestKeys(t)
+	module.p2p = &P2PAgglomerator{p2pNode: &P2PInfiniteVectorNode{NodeID: "node-1", keys: keys}}
+
+	bundle, err := module.assembleReceipt(&Transaction{ID: "tx-1", FromChain: "source", ToChain: "source"}, time.Now())
+	require.NoError(t, err)
+	require.Len(t, bundle.Hops, 1, "a transaction within one chain has one hop")
+	require.Len(t, bundle.Signatures, 1)
+	assert.Equal(t, "node-1", bundle.Signatures[0].NodeID)
+	require.NoError(t, bundle.Verify(keys))
+
+	forged := *bundle
+	forged.Signatures = []ReceiptSignature{bundle.Signatures[0]}
+	forged.Signatures[0].Signature = make([]byte, len(bundle.Signatures[0].Signature))
+	assert.Error(t, forged.Verify(keys))
+
+	tampered := *bundle
+	tampered.ToChain = "elsewhere"
+	assert.ErrorIs(t, tampered.Verify(keys), ErrReceiptTampered)
+}
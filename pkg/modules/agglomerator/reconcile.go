@@ -0,0 +1,181 @@
+package agglomerator
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrReportNotFound = errors.New("reconciliation report not found")
+
+// Reconciliation outcomes for a completed transaction
+const (
+	ReconcileMatched      = "matched"      // Found with enough confirmations
+	ReconcilePending      = "pending"      // Found with fewer confirmations than required
+	ReconcileMissing      = "missing"      // Not found on the destination chain
+	ReconcileUnverifiable = "unverifiable" // The destination chain could not be asked
+)
+
+const (
+	// ReportDateLayout names daily reports, in UTC
+	ReportDateLayout = "2006-01-02"
+
+	// reconciliationMetadataKey tags mismatched transactions so they can be
+	// found with meta.reconciliation
+	reconciliationMetadataKey = "reconciliation"
+)
+
+// ReconciliationConfig controls the reconciliation job
+type ReconciliationConfig struct {
+	Interval         time.Duration // Between runs over the current day
+	MinConfirmations uint64        // Required for a transaction to match
+	Timeout          time.Duration // Per confirmation lookup
+}
+
+// DefaultReconciliationConfig reconciles hourly with one confirmation
+func DefaultReconciliationConfig() ReconciliationConfig {
+	return ReconciliationConfig{
+		Interval:         time.Hour,
+		MinConfirmations: 1,
+		Timeout:          10 * time.Second,
+	}
+}
+
+// ReconciliationEntry is the outcome for one completed transaction
+type ReconciliationEntry struct {
+	TxID          string    `json:"txId"`
+	FromChain     string    `json:"fromChain"`
+	ToChain       string    `json:"toChain"`
+	Status        string    `json:"status"`
+	Error         string    `json:"error,omitempty"`
+	BlockHeight   uint64    `json:"blockHeight,omitempty"`
+	Confirmations uint64    `json:"confirmations"`
+	CompletedAt   time.Time `json:"completedAt"`
+}
+
+// ReconciliationReport compares a day's completed transactions with the
+// confirmations reported by their destination chains
+type ReconciliationReport struct {
+	Date         string                `json:"date"`
+	GeneratedAt  time.Time             `json:"generatedAt"`
+	Final        bool                  `json:"final"` // Generated after the day ended
+	Checked      int                   `json:"checked"`
+	Matched      int                   `json:"matched"`
+	Pending      int                   `json:"pending"`
+	Missing      int                   `json:"missing"`
+	Unverifiable int                   `json:"unverifiable"`
+	Mismatches   []ReconciliationEntry `json:"mismatches,omitempty"` // Every transaction not matched
+}
+
+// add counts an entry, keeping it if it is a mismatch
+func (r *ReconciliationReport) add(entry ReconciliationEntry) {
+	r.Checked++
+	switch entry.Status {
+	case ReconcileMatched:
+		r.Matched++
+		return
+	case ReconcilePending:
+		r.Pending++
+	case ReconcileMissing:
+		r.Missing++
+	default:
+		r.Unverifiable++
+	}
+	r.Mismatches = append(r.Mismatches, entry)
+}
+
+// ConfirmTransaction asks a chain's adapter whether a transaction was
+// included on its network
+func (a *Agglomerator) ConfirmTransaction(ctx context.Context, chainID, txID string) (*Confirmation, error) {
+	chain, err := a.GetChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+	reporter, ok := chain.adapter.(ConfirmationReporter)
+	if !ok {
+		return nil, ErrConfirmationsUnsupported
+	}
+	return reporter.Confirmation(ctx, txID)
+}
+
+// reconcileRecord checks one completed transaction against its destination
+// chain
+func (a *Agglomerator) reconcileRecord(ctx context.Context, record TransactionRecord, config ReconciliationConfig) ReconciliationEntry {
+	entry := ReconciliationEntry{
+		TxID:        record.ID,
+		FromChain:   record.FromChain,
+		ToChain:     record.ToChain,
+		CompletedAt: record.CreatedAt,
+	}
+
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
+	}
+	confirmation, err := a.ConfirmTransaction(ctx, record.ToChain, record.ID)
+	switch {
+	case err != nil:
+		entry.Status = ReconcileUnverifiable
+		entry.Error = err.Error()
+	case !confirmation.Found:
+		entry.Status = ReconcileMissing
+	case confirmation.Confirmations < config.MinConfirmations:
+		entry.Status = ReconcilePending
+	default:
+		entry.Status = ReconcileMatched
+	}
+	if confirmation != nil {
+		entry.BlockHeight = confirmation.BlockHeight
+		entry.Confirmations = confirmation.Confirmations
+	}
+	return entry
+}
+
+// Reconcile builds the report for the UTC day containing day from the
+// transactions recorded as completed in store. Each mismatched transaction
+// is tagged with its status in the history; tags are cleared once a
+// transaction matches.
+func (a *Agglomerator) Reconcile(ctx context.Context, store *TransactionStore, day time.Time, config ReconciliationConfig) (ReconciliationReport, error) {
+	start := day.UTC().Truncate(24 * time.Hour)
+	end := start.Add(24 * time.Hour)
+	report := ReconciliationReport{Date: start.Format(ReportDateLayout)}
+
+	query := TransactionQuery{
+		Statuses: []string{TxStatusCompleted},
+		After:    start,
+		Before:   end,
+		Limit:    maxQueryLimit,
+	}
+	for {
+		records, err := store.Query(query)
+		if err != nil {
+			return report, err
+		}
+		for _, record := range records {
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+			entry := a.reconcileRecord(ctx, record, config)
+			report.add(entry)
+
+			tag := entry.Status
+			if tag == ReconcileMatched {
+				tag = ""
+			}
+			if tag != record.Metadata[reconciliationMetadataKey] {
+				if err := store.SetMetadata(record.ID, reconciliationMetadataKey, tag); err != nil {
+					return report, err
+				}
+			}
+		}
+		if len(records) < query.Limit {
+			break
+		}
+		query.Offset += len(records)
+	}
+
+	report.GeneratedAt = time.Now()
+	report.Final = !report.GeneratedAt.Before(end)
+	return report, store.SaveReconciliationReport(report)
+}
@@ -0,0 +1,97 @@
+package agglomerator
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+func TestMockChainConfirmation(t *testing.T) {
+	chain := NewMockChain("mock-a", MockChainConfig{BlockTime: 5 * time.Millisecond})
+	receipt, err := chain.Submit(context.Background(), &Transaction{ID: "tx-1"})
+	require.NoError(t, err)
+
+	time.Sleep(20 * time.Millisecond)
+	confirmation, err := chain.Confirmation(context.Background(), "tx-1")
+	require.NoError(t, err)
+	assert.True(t, confirmation.Found)
+	assert.Equal(t, receipt.BlockHeight, confirmation.BlockHeight)
+	assert.GreaterOrEqual(t, confirmation.Confirmations, uint64(2))
+
+	confirmation, err = chain.Confirmation(context.Background(), "tx-2")
+	require.NoError(t, err)
+	assert.False(t, confirmation.Found)
+}
+
+func TestReconcile(t *testing.T) {
+	store, err := NewTransactionStore(filepath.Join(t.TempDir(), "transactions.db"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	agg := NewAgglomerator(AgglomeratorConfig{})
+	mock := NewChain("mock-chain", "mock://local?blockTime=5ms", ProtocolMock)
+	mock.StateVector = vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolMock)}
+	require.NoError(t, agg.RegisterChain(mock))
+	eth := NewChain("eth-chain", "http://localhost:8545", ProtocolEthereum)
+	eth.StateVector = vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}
+	require.NoError(t, agg.RegisterChain(eth))
+
+	_, err = mock.Adapter().Submit(context.Background(), &Transaction{ID: "included"})
+	require.NoError(t, err)
+
+	now := time.Now()
+	for _, record := range []TransactionRecord{
+		{ID: "included", FromChain: "eth-chain", ToChain: "mock-chain", Status: TxStatusCompleted, CreatedAt: now},
+		{ID: "lost", FromChain: "eth-chain", ToChain: "mock-chain", Status: TxStatusCompleted, CreatedAt: now},
+		{ID: "offchain", FromChain: "mock-chain", ToChain: "eth-chain", Status: TxStatusCompleted, CreatedAt: now},
+		{ID: "failed", FromChain: "eth-chain", ToChain: "mock-chain", Status: TxStatusFailed, CreatedAt: now},
+		{ID: "yesterday", FromChain: "eth-chain", ToChain: "mock-chain", Status: TxStatusCompleted, CreatedAt: now.Add(-24 * time.Hour)},
+	} {
+		require.NoError(t, store.Record(record))
+	}
+
+	config := ReconciliationConfig{MinConfirmations: 1000, Timeout: time.Second}
+	report, err := agg.Reconcile(context.Background(), store, now, config)
+	require.NoError(t, err)
+	assert.Equal(t, now.UTC().Format(ReportDateLayout), report.Date)
+	assert.False(t, report.Final)
+	assert.Equal(t, 3, report.Checked, "only the day's completed transactions are checked")
+	assert.Equal(t, 1, report.Pending)
+	assert.Equal(t, 1, report.Missing)
+	assert.Equal(t, 1, report.Unverifiable)
+	require.Len(t, report.Mismatches, 3)
+
+	flagged, err := store.Query(TransactionQuery{Metadata: map[string]string{reconciliationMetadataKey: ReconcileMissing}})
+	require.NoError(t, err)
+	require.Len(t, flagged, 1)
+	assert.Equal(t, "lost", flagged[0].ID)
+
+	time.Sleep(10 * time.Millisecond)
+	config.MinConfirmations = 1
+	report, err = agg.Reconcile(context.Background(), store, now, config)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Matched)
+	assert.Len(t, report.Mismatches, 2)
+
+	flagged, err = store.Query(TransactionQuery{Metadata: map[string]string{reconciliationMetadataKey: "*"}})
+	require.NoError(t, err)
+	assert.Len(t, flagged, 2, "the tag is cleared once a transaction matches")
+
+	stored, err := store.ReconciliationReport(report.Date)
+	require.NoError(t, err)
+	assert.Equal(t, report.Matched, stored.Matched)
+	assert.Len(t, stored.Mismatches, 2)
+
+	reports, err := store.ReconciliationReports(0)
+	require.NoError(t, err)
+	require.Len(t, reports, 1, "later runs replace the day's report")
+	assert.Nil(t, reports[0].Mismatches)
+
+	_, err = store.ReconciliationReport("2000-01-01")
+	assert.ErrorIs(t, err, ErrReportNotFound)
+}
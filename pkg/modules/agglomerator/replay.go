@@ -0,0 +1,310 @@
+package agglomerator
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+// ReplayConfig controls duplicate and replay detection for inbound messages
+type ReplayConfig struct {
+	CacheSize      int           // Number of unsequenced message hashes remembered
+	MaxClockSkew   time.Duration // Messages timestamped further from now are dropped
+	SequenceWindow uint64        // Sequences this far behind a sender's highest are dropped
+	MaxSenders     int           // Senders whose windows and clock skew are remembered, 4096 if unset
+}
+
+// DefaultReplayConfig returns the replay protection used when none is configured
+func DefaultReplayConfig() ReplayConfig {
+	return ReplayConfig{
+		CacheSize:      4096,
+		MaxClockSkew:   2 * time.Minute,
+		SequenceWindow: 1024,
+		MaxSenders:     4096,
+	}
+}
+
+// ReplayStats counts the outcome of inbound message checks
+type ReplayStats struct {
+	Accepted   uint64 `json:"accepted"`
+	Duplicates uint64 `json:"duplicates"`
+	Replays    uint64 `json:"replays"`
+	Expired    uint64 `json:"expired"`
+}
+
+// sequenceWindow tracks the sequences accepted from one sender in its
+// current session: the highest, and which of those just below it
+type sequenceWindow struct {
+	epoch   uint64
+	highest uint64
+	bits    []uint64 // Bit seq%size is set once seq is accepted
+}
+
+func newSequenceWindow(epoch, size uint64) *sequenceWindow {
+	return &sequenceWindow{epoch: epoch, bits: make([]uint64, (max(size, 1)+63)/64)}
+}
+
+func (w *sequenceWindow) size() uint64 {
+	return uint64(len(w.bits)) * 64
+}
+
+func (w *sequenceWindow) has(seq uint64) bool {
+	i := seq % w.size()
+	return w.bits[i/64]&(1<<(i%64)) != 0
+}
+
+func (w *sequenceWindow) set(seq uint64, on bool) {
+	i := seq % w.size()
+	if on {
+		w.bits[i/64] |= 1 << (i % 64)
+	} else {
+		w.bits[i/64] &^= 1 << (i % 64)
+	}
+}
+
+// accept records seq, reporting whether it falls behind the window and
+// whether it was already accepted
+func (w *sequenceWindow) accept(seq uint64) (stale, duplicate bool) {
+	if seq > w.highest {
+		// Slots of the sequences skipped over no longer hold older ones
+		if seq-w.highest >= w.size() {
+			clear(w.bits)
+		} else {
+			for s := w.highest + 1; s < seq; s++ {
+				w.set(s, false)
+			}
+		}
+		w.highest = seq
+		w.set(seq, true)
+		return false, false
+	}
+	if w.highest-seq >= w.size() {
+		return true, false
+	}
+	if w.has(seq) {
+		return false, true
+	}
+	w.set(seq, true)
+	return false, false
+}
+
+// senderState is what a guard remembers about one sender
+type senderState struct {
+	id     string
+	window *sequenceWindow // Nil until the sender's first sequenced message
+	skew   time.Duration   // Clock skew observed on its latest message
+}
+
+// ReplayGuard drops duplicate, replayed, and stale data transfer messages.
+// Sequenced messages are checked against a window of the sender's recent
+// sequences in its current session, so a peer that restarts starts afresh;
+// messages without a sequence are remembered by hash. Both are bounded:
+// the least recently heard senders and oldest hashes are forgotten first.
+type ReplayGuard struct {
+	mu          sync.Mutex
+	config      ReplayConfig
+	seen        map[[32]byte]*list.Element
+	order       *list.List
+	senders     map[string]*list.Element
+	senderOrder *list.List // Front is most recently heard
+	stats       ReplayStats
+
+	// epoch identifies this node's session to peers; it is taken from the
+	// start time so that a restarted node's is higher
+	epoch    uint64
+	seqMu    sync.Mutex
+	outbound map[string]uint64 // Last sequence sent per recipient
+}
+
+func NewReplayGuard(config ReplayConfig) *ReplayGuard {
+	return &ReplayGuard{
+		config:      config,
+		seen:        make(map[[32]byte]*list.Element),
+		order:       list.New(),
+		senders:     make(map[string]*list.Element),
+		senderOrder: list.New(),
+		epoch:       uint64(time.Now().UnixNano()),
+		outbound:    make(map[string]uint64),
+	}
+}
+
+// Epoch returns the session outbound sequences are numbered in
+func (g *ReplayGuard) Epoch() uint64 {
+	return g.epoch
+}
+
+// NextSequence returns the next outbound sequence number for a recipient
+func (g *ReplayGuard) NextSequence(recipientID string) uint64 {
+	g.seqMu.Lock()
+	defer g.seqMu.Unlock()
+
+	g.outbound[recipientID]++
+	return g.outbound[recipientID]
+}
+
+// SetConfig replaces the replay protection settings. A new sequence window
+// applies to sessions that start afterwards.
+func (g *ReplayGuard) SetConfig(config ReplayConfig) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.config = config
+}
+
+// Accept reports whether an inbound message should be processed
+func (g *ReplayGuard) Accept(msg DataTransferMessage) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	sender := g.sender(msg.SenderID)
+	skew := time.Since(msg.Timestamp)
+	sender.skew = skew
+	if skew > g.config.MaxClockSkew || skew < -g.config.MaxClockSkew {
+		g.stats.Expired++
+		return false
+	}
+
+	if msg.Sequence == 0 {
+		return g.acceptUnsequenced(msg)
+	}
+
+	window := sender.window
+	switch {
+	case window == nil || msg.Epoch > window.epoch:
+		// The sender's first message, or its first since restarting
+		window = newSequenceWindow(msg.Epoch, g.config.SequenceWindow)
+		sender.window = window
+	case msg.Epoch < window.epoch:
+		// Sent before the sender restarted
+		g.stats.Replays++
+		return false
+	}
+
+	stale, duplicate := window.accept(msg.Sequence)
+	switch {
+	case stale:
+		g.stats.Replays++
+		return false
+	case duplicate:
+		g.stats.Duplicates++
+		return false
+	}
+	g.stats.Accepted++
+	return true
+}
+
+// sender returns the state of a sender, remembering it as the most
+// recently heard and forgetting the least recently heard past MaxSenders
+func (g *ReplayGuard) sender(id string) *senderState {
+	if item, exists := g.senders[id]; exists {
+		g.senderOrder.MoveToFront(item)
+		return item.Value.(*senderState)
+	}
+
+	state := &senderState{id: id}
+	g.senders[id] = g.senderOrder.PushFront(state)
+	limit := g.config.MaxSenders
+	if limit <= 0 {
+		limit = DefaultReplayConfig().MaxSenders
+	}
+	for g.senderOrder.Len() > limit {
+		oldest := g.senderOrder.Back()
+		g.senderOrder.Remove(oldest)
+		delete(g.senders, oldest.Value.(*senderState).id)
+	}
+	return state
+}
+
+// acceptUnsequenced drops messages whose hash was seen recently
+func (g *ReplayGuard) acceptUnsequenced(msg DataTransferMessage) bool {
+	hash := messageHash(msg)
+	if _, exists := g.seen[hash]; exists {
+		g.stats.Duplicates++
+		return false
+	}
+
+	g.seen[hash] = g.order.PushBack(hash)
+	for g.order.Len() > g.config.CacheSize {
+		oldest := g.order.Front()
+		g.order.Remove(oldest)
+		delete(g.seen, oldest.Value.([32]byte))
+	}
+	g.stats.Accepted++
+	return true
+}
+
+// Stats returns a snapshot of the guard's counters
+func (g *ReplayGuard) Stats() ReplayStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.stats
+}
+
+// ClockSkew returns the skew observed on each sender's latest message,
+// positive when the sender's clock is behind ours
+func (g *ReplayGuard) ClockSkew() map[string]time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	skew := make(map[string]time.Duration, len(g.senders))
+	for id, item := range g.senders {
+		skew[id] = item.Value.(*senderState).skew
+	}
+	return skew
+}
+
+// MaxClockSkew returns the skew beyond which messages are dropped
+func (g *ReplayGuard) MaxClockSkew() time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.config.MaxClockSkew
+}
+
+// CheckClockSkew warns about senders whose clocks are off by more than half
+// the allowed skew, and fails when any is off by more than the allowed skew
+func (g *ReplayGuard) CheckClockSkew(ctx context.Context) (core.CheckStatus, string) {
+	limit := g.MaxClockSkew()
+	status, worst, worstSender := core.CheckPass, time.Duration(0), ""
+	for sender, skew := range g.ClockSkew() {
+		if skew < 0 {
+			skew = -skew
+		}
+		if skew > worst {
+			worst, worstSender = skew, sender
+		}
+	}
+	switch {
+	case worstSender == "":
+		return core.CheckPass, "no messages received yet"
+	case worst > limit:
+		status = core.CheckFail
+	case worst > limit/2:
+		status = core.CheckWarn
+	}
+	return status, fmt.Sprintf("largest skew %s from %s (limit %s)", worst.Round(time.Millisecond), worstSender, limit)
+}
+
+// messageHash identifies a message by its sender, sequence, and content
+func messageHash(msg DataTransferMessage) [32]byte {
+	h := sha256.New()
+	h.Write([]byte(msg.SenderID))
+	h.Write([]byte{0})
+	h.Write([]byte(msg.RecipientID))
+	h.Write([]byte{0})
+	h.Write([]byte(msg.DataID))
+	h.Write([]byte{0})
+
+	var buf [16]byte
+	binary.BigEndian.PutUint64(buf[:8], msg.Sequence)
+	binary.BigEndian.PutUint64(buf[8:], uint64(msg.Timestamp.UnixNano()))
+	h.Write(buf[:])
+	h.Write(msg.Payload)
+
+	var sum [32]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
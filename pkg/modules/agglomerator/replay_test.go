@@ -0,0 +1,116 @@
+package agglomerator
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sequenced(sender string, epoch, seq uint64) DataTransferMessage {
+	return DataTransferMessage{
+		SenderID:    sender,
+		RecipientID: "local",
+		DataID:      "record",
+		Payload:     []byte("payload"),
+		Timestamp:   time.Now(),
+		Epoch:       epoch,
+		Sequence:    seq,
+	}
+}
+
+func TestReplayGuardRejectsDuplicates(t *testing.T) {
+	guard := NewReplayGuard(ReplayConfig{CacheSize: 1, MaxClockSkew: time.Minute, SequenceWindow: 64})
+
+	assert.True(t, guard.Accept(sequenced("peer", 1, 1)))
+	assert.True(t, guard.Accept(sequenced("peer", 1, 3)))
+	assert.True(t, guard.Accept(sequenced("peer", 1, 2)), "in-window messages may arrive out of order")
+	assert.False(t, guard.Accept(sequenced("peer", 1, 3)))
+
+	// Sequences are checked per sender
+	assert.True(t, guard.Accept(sequenced("other", 1, 3)))
+
+	// The hash cache holds one entry, so it has long forgotten these
+	for seq := uint64(4); seq < 40; seq++ {
+		assert.True(t, guard.Accept(sequenced("peer", 1, seq)))
+	}
+	assert.False(t, guard.Accept(sequenced("peer", 1, 5)))
+	assert.False(t, guard.Accept(sequenced("peer", 1, 39)))
+
+	stats := guard.Stats()
+	assert.Equal(t, uint64(3), stats.Duplicates)
+	assert.Zero(t, stats.Replays)
+}
+
+func TestReplayGuardRejectsOutOfWindow(t *testing.T) {
+	guard := NewReplayGuard(ReplayConfig{CacheSize: 16, MaxClockSkew: time.Minute, SequenceWindow: 64})
+
+	assert.True(t, guard.Accept(sequenced("peer", 1, 1)))
+	assert.True(t, guard.Accept(sequenced("peer", 1, 100)))
+	assert.False(t, guard.Accept(sequenced("peer", 1, 1)))
+	assert.False(t, guard.Accept(sequenced("peer", 1, 2)), "too far behind to tell whether it was seen")
+
+	// Sequences skipped over can still arrive while inside the window
+	assert.True(t, guard.Accept(sequenced("peer", 1, 99)))
+	assert.True(t, guard.Accept(sequenced("peer", 1, 40)))
+
+	assert.Equal(t, uint64(2), guard.Stats().Replays)
+}
+
+func TestReplayGuardResetsOnPeerRestart(t *testing.T) {
+	guard := NewReplayGuard(ReplayConfig{CacheSize: 16, MaxClockSkew: time.Minute, SequenceWindow: 64})
+
+	for seq := uint64(1); seq <= 500; seq++ {
+		guard.Accept(sequenced("peer", 1, seq))
+	}
+
+	// The restarted peer numbers its messages from one in a new session
+	assert.True(t, guard.Accept(sequenced("peer", 2, 1)))
+	assert.True(t, guard.Accept(sequenced("peer", 2, 2)))
+	assert.False(t, guard.Accept(sequenced("peer", 2, 2)))
+
+	// Messages captured from the old session are replays
+	assert.False(t, guard.Accept(sequenced("peer", 1, 501)))
+	assert.Equal(t, uint64(1), guard.Stats().Replays)
+}
+
+func TestReplayGuardChecksUnsequencedByHash(t *testing.T) {
+	guard := NewReplayGuard(ReplayConfig{CacheSize: 16, MaxClockSkew: time.Minute, SequenceWindow: 64})
+
+	msg := sequenced("peer", 0, 0)
+	assert.True(t, guard.Accept(msg))
+	assert.False(t, guard.Accept(msg))
+
+	stale := sequenced("peer", 1, 1)
+	stale.Timestamp = time.Now().Add(-time.Hour)
+	assert.False(t, guard.Accept(stale))
+	assert.Equal(t, uint64(1), guard.Stats().Expired)
+}
+
+func TestReplayGuardEpochSurvivesWire(t *testing.T) {
+	guard := NewReplayGuard(DefaultReplayConfig())
+	msg := sequenced("peer", guard.Epoch(), guard.NextSequence("local"))
+
+	envelope := msg.proto()
+	decoded := envelopeFromProto(envelope)
+	assert.Equal(t, guard.Epoch(), decoded.Epoch)
+	assert.Equal(t, uint64(1), decoded.Sequence)
+}
+
+func TestReplayGuardForgetsLeastRecentSenders(t *testing.T) {
+	guard := NewReplayGuard(ReplayConfig{CacheSize: 16, MaxClockSkew: time.Minute, SequenceWindow: 64, MaxSenders: 2})
+
+	assert.True(t, guard.Accept(sequenced("peer", 1, 1)))
+	for i := 0; i < 100; i++ {
+		assert.True(t, guard.Accept(sequenced(fmt.Sprintf("spoofed-%d", i), 1, 1)))
+		// A sender heard from keeps its window while others come and go
+		assert.True(t, guard.Accept(sequenced("peer", 1, uint64(i+2))))
+	}
+	assert.False(t, guard.Accept(sequenced("peer", 1, 2)))
+
+	skew := guard.ClockSkew()
+	assert.Len(t, skew, 2)
+	assert.Contains(t, skew, "peer")
+	assert.Contains(t, skew, "spoofed-99")
+}
@@ -0,0 +1,221 @@
+package agglomerator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+var ErrReadOnlyReplica = errors.New("node is a read-only replica")
+
+const (
+	DefaultReplicaPollInterval = 2 * time.Second
+
+	replicaBatchSize    = maxQueryLimit
+	replicaFetchTimeout = 10 * time.Second
+)
+
+// ReplicaConfig points a read-only node at the primary it follows
+type ReplicaConfig struct {
+	Primary      string        // Base URL of the primary's API, such as http://primary:8088
+	PollInterval time.Duration // Between event log fetches once caught up
+}
+
+// ReplicaStatus reports how far a replica has followed its primary
+type ReplicaStatus struct {
+	Primary   string    `json:"primary"`
+	Seq       uint64    `json:"seq"` // Last primary event applied
+	Applied   uint64    `json:"applied"`
+	LastSync  time.Time `json:"lastSync,omitempty"`
+	LastError string    `json:"lastError,omitempty"`
+}
+
+// ReplicaFollower applies the primary's event log to a read-only node:
+// chains are registered so they can be listed and searched, and transaction
+// outcomes are recorded in the local history. Transactions are never routed
+// or submitted.
+type ReplicaFollower struct {
+	config ReplicaConfig
+	agg    *Agglomerator
+	store  *TransactionStore // Nil when the replica keeps no history
+	client *http.Client
+	state  *EventState // Primary state, for the chains of each transaction
+	status ReplicaStatus
+	stop   chan struct{}
+	mu     sync.Mutex
+}
+
+func NewReplicaFollower(config ReplicaConfig, agg *Agglomerator, store *TransactionStore) *ReplicaFollower {
+	if config.PollInterval <= 0 {
+		config.PollInterval = DefaultReplicaPollInterval
+	}
+	state, _ := ReplayEvents(nil)
+	return &ReplicaFollower{
+		config: config,
+		agg:    agg,
+		store:  store,
+		client: &http.Client{Timeout: replicaFetchTimeout},
+		state:  state,
+		status: ReplicaStatus{Primary: config.Primary},
+	}
+}
+
+// Start follows the primary in the background until Stop
+func (f *ReplicaFollower) Start() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.stop != nil {
+		return
+	}
+	f.stop = make(chan struct{})
+	go f.run(f.stop)
+}
+
+func (f *ReplicaFollower) Stop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.stop != nil {
+		close(f.stop)
+		f.stop = nil
+	}
+}
+
+func (f *ReplicaFollower) Status() ReplicaStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.status
+}
+
+func (f *ReplicaFollower) run(stop chan struct{}) {
+	for {
+		applied, err := f.Sync(context.Background())
+		wait := f.config.PollInterval
+		if err == nil && applied == replicaBatchSize {
+			wait = 0 // Still catching up
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// Sync fetches and applies one batch of the primary's events, returning the
+// number applied
+func (f *ReplicaFollower) Sync(ctx context.Context) (int, error) {
+	f.mu.Lock()
+	since := f.status.Seq
+	f.mu.Unlock()
+
+	events, err := f.fetch(ctx, since)
+	if err == nil {
+		for i, event := range events {
+			if err = f.apply(event); err != nil {
+				events = events[:i]
+				break
+			}
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(events) > 0 {
+		f.status.Seq = events[len(events)-1].Seq
+		f.status.Applied += uint64(len(events))
+	}
+	if err != nil {
+		f.status.LastError = err.Error()
+		return len(events), err
+	}
+	f.status.LastSync = time.Now()
+	f.status.LastError = ""
+	return len(events), nil
+}
+
+func (f *ReplicaFollower) fetch(ctx context.Context, since uint64) ([]Event, error) {
+	query := url.Values{}
+	query.Set("since", strconv.FormatUint(since, 10))
+	query.Set("limit", strconv.Itoa(replicaBatchSize))
+	endpoint := strings.TrimSuffix(f.config.Primary, "/") + "/api/agglomerator/events?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch primary events: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("primary returned %s", resp.Status)
+	}
+
+	var body struct {
+		Events []Event `json:"events"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid primary events: %w", err)
+	}
+	return body.Events, nil
+}
+
+// apply mirrors one primary event on the replica
+func (f *ReplicaFollower) apply(event Event) error {
+	if err := f.state.Apply(event); err != nil {
+		return err
+	}
+
+	switch event.Type {
+	case EventChainRegistered:
+		registered := f.state.Chains[event.ChainID]
+		chain := &Chain{
+			ID:        registered.ID,
+			Endpoint:  registered.Endpoint,
+			Endpoints: registered.Endpoints,
+			Protocol:  registered.Protocol,
+			StateVector: vectors.InfiniteVector{
+				Generator: getProtocolGenerator(registered.Protocol),
+			},
+		}
+		return f.agg.RegisterChain(chain)
+	case EventChainVector:
+		if err := f.agg.seedChainVector(event.ChainID, f.state.Chains[event.ChainID].Vector); err != nil {
+			return err
+		}
+	case EventTransactionStatus:
+		tx := f.state.Transactions[event.TxID]
+		if f.store != nil {
+			if err := f.store.Record(TransactionRecord{
+				ID:        tx.ID,
+				FromChain: tx.FromChain,
+				ToChain:   tx.ToChain,
+				Status:    tx.Status,
+				Error:     tx.Error,
+				CreatedAt: event.Time,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The local log mirrors the primary's transaction events, so state and
+	// event queries answer as on the primary
+	if event.Type != EventChainRegistered {
+		f.agg.EventLog().record(event.Type, event.ChainID, event.TxID, event.Data)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package agglomerator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+func TestReplicaFollowerMirrorsPrimary(t *testing.T) {
+	primaryLog, err := NewEventLog(nil)
+	require.NoError(t, err)
+	primary := NewAgglomerator(AgglomeratorConfig{})
+	primary.SetEventLog(primaryLog)
+	for _, id := range []string{"eth", "sol"} {
+		chain := NewChain(id, "http://localhost:8545", ProtocolEthereum)
+		chain.StateVector = vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}
+		require.NoError(t, primary.RegisterChain(chain))
+	}
+	_, err = primary.recordTransaction(&Transaction{ID: "tx-1", FromChain: "sol", ToChain: "eth",
+		StateVector: vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}})
+	require.NoError(t, err)
+	_, err = primaryLog.Append(EventTransactionStatus, "", "tx-1", TransactionStatusData{Status: TxStatusCompleted})
+	require.NoError(t, err)
+
+	failing := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		assert.Equal(t, "/api/agglomerator/events", r.URL.Path)
+		since, _ := strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+		events, _ := primaryLog.Events(since, 0)
+		json.NewEncoder(w).Encode(map[string]interface{}{"events": events})
+	}))
+	defer server.Close()
+
+	replicaLog, err := NewEventLog(nil)
+	require.NoError(t, err)
+	replica := NewAgglomerator(AgglomeratorConfig{})
+	replica.SetEventLog(replicaLog)
+	follower := NewReplicaFollower(ReplicaConfig{Primary: server.URL + "/"}, replica, nil)
+
+	applied, err := follower.Sync(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 4, applied)
+	assert.Len(t, replica.ListChains(), 2, "chains are registered from the primary's log")
+
+	state, err := replicaLog.State(time.Time{})
+	require.NoError(t, err)
+	require.Contains(t, state.Transactions, "tx-1")
+	assert.Equal(t, TxStatusCompleted, state.Transactions["tx-1"].Status)
+	assert.Equal(t, "sol", state.Transactions["tx-1"].FromChain)
+	eth, _ := replica.GetChain("eth")
+	_, pooled := eth.TransactionPool.Get("tx-1")
+	assert.False(t, pooled, "replicas never route transactions")
+
+	applied, err = follower.Sync(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, applied, "caught up replicas fetch from their last event")
+
+	failing = true
+	_, err = follower.Sync(context.Background())
+	assert.Error(t, err)
+	status := follower.Status()
+	assert.Equal(t, uint64(4), status.Seq)
+	assert.NotEmpty(t, status.LastError)
+}
@@ -0,0 +1,260 @@
+package agglomerator
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+var ErrPeerNotFound = errors.New("peer not found")
+
+// ReputationConfig controls how peer reputations evolve
+type ReputationConfig struct {
+	DecayRate     float64       // Multiplier applied to scores every DecayInterval
+	DecayInterval time.Duration // How often reputations decay
+	BanThreshold  float64       // Unpinned peers scoring below this are dropped
+	HistorySize   int           // Number of events retained per peer
+}
+
+// DefaultReputationConfig returns the historical decay of 0.9 every 10 minutes
+func DefaultReputationConfig() ReputationConfig {
+	return ReputationConfig{
+		DecayRate:     0.9,
+		DecayInterval: 10 * time.Minute,
+		BanThreshold:  0,
+		HistorySize:   20,
+	}
+}
+
+// ReputationEvent records a change to a peer's reputation
+type ReputationEvent struct {
+	Time  time.Time `json:"time"`
+	Kind  string    `json:"kind"`
+	Delta float64   `json:"delta"`
+	Score float64   `json:"score"`
+}
+
+// PeerReputation breaks a peer's score into its components.
+// Score = Base*DecayFactor + Adjustment, clamped to [0, 1], unless pinned.
+type PeerReputation struct {
+	PeerID      string            `json:"peerId"`
+	Base        float64           `json:"base"`
+	DecayFactor float64           `json:"decayFactor"`
+	Adjustment  float64           `json:"adjustment"`
+	Pinned      bool              `json:"pinned"`
+	PinnedScore float64           `json:"pinnedScore,omitempty"`
+	Score       float64           `json:"score"`
+	History     []ReputationEvent `json:"history"`
+}
+
+// ReputationManager tracks peer reliability and performance
+type ReputationManager struct {
+	mu             sync.RWMutex
+	peerReputation map[string]*PeerReputation
+	config         ReputationConfig
+	clock          core.Clock // Timestamps history events
+}
+
+func NewReputationManager(config ReputationConfig) *ReputationManager {
+	return &ReputationManager{
+		peerReputation: make(map[string]*PeerReputation),
+		config:         config,
+		clock:          core.SystemClock,
+	}
+}
+
+// UseClock sets the clock history events are timestamped with
+func (rm *ReputationManager) UseClock(clock core.Clock) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.clock = clock
+}
+
+// Config returns the active reputation configuration
+func (rm *ReputationManager) Config() ReputationConfig {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.config
+}
+
+// SetConfig replaces the reputation configuration
+func (rm *ReputationManager) SetConfig(config ReputationConfig) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.config = config
+}
+
+// Track starts tracking a peer with an initial base score
+func (rm *ReputationManager) Track(peerID string, base float64) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if _, exists := rm.peerReputation[peerID]; exists {
+		return
+	}
+	rep := &PeerReputation{
+		PeerID:      peerID,
+		Base:        base,
+		DecayFactor: 1,
+	}
+	rm.recompute(rep)
+	rm.record(rep, "track", base)
+	rm.peerReputation[peerID] = rep
+}
+
+// Score returns a peer's current reputation score
+func (rm *ReputationManager) Score(peerID string) (float64, bool) {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+
+	rep, exists := rm.peerReputation[peerID]
+	if !exists {
+		return 0, false
+	}
+	return rep.Score, true
+}
+
+// List returns a snapshot of all tracked peers, highest score first
+func (rm *ReputationManager) List() []PeerReputation {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+
+	list := make([]PeerReputation, 0, len(rm.peerReputation))
+	for _, rep := range rm.peerReputation {
+		snapshot := *rep
+		snapshot.History = append([]ReputationEvent(nil), rep.History...)
+		list = append(list, snapshot)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Score > list[j].Score
+	})
+	return list
+}
+
+// Adjust boosts (positive delta) or penalizes (negative delta) a peer
+func (rm *ReputationManager) Adjust(peerID string, delta float64) (PeerReputation, error) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	rep, exists := rm.peerReputation[peerID]
+	if !exists {
+		return PeerReputation{}, ErrPeerNotFound
+	}
+
+	kind := "boost"
+	if delta < 0 {
+		kind = "penalize"
+	}
+	rm.adjust(rep, kind, delta)
+	return *rep, nil
+}
+
+// Penalize lowers a peer's score by amount, recording kind as the reason.
+// Untracked peers are ignored.
+func (rm *ReputationManager) Penalize(peerID, kind string, amount float64) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if rep, exists := rm.peerReputation[peerID]; exists && amount > 0 {
+		rm.adjust(rep, kind, -amount)
+	}
+}
+
+func (rm *ReputationManager) adjust(rep *PeerReputation, kind string, delta float64) {
+	rep.Adjustment += delta
+	rm.recompute(rep)
+	rm.record(rep, kind, delta)
+}
+
+// Pin fixes a peer's score, exempting it from decay and banning
+func (rm *ReputationManager) Pin(peerID string, score float64) (PeerReputation, error) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	rep, exists := rm.peerReputation[peerID]
+	if !exists {
+		return PeerReputation{}, ErrPeerNotFound
+	}
+
+	rep.Pinned = true
+	rep.PinnedScore = clampScore(score)
+	rm.recompute(rep)
+	rm.record(rep, "pin", 0)
+	return *rep, nil
+}
+
+// Unpin returns a peer to its computed score
+func (rm *ReputationManager) Unpin(peerID string) (PeerReputation, error) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	rep, exists := rm.peerReputation[peerID]
+	if !exists {
+		return PeerReputation{}, ErrPeerNotFound
+	}
+
+	rep.Pinned = false
+	rep.PinnedScore = 0
+	rm.recompute(rep)
+	rm.record(rep, "unpin", 0)
+	return *rep, nil
+}
+
+// Forget stops tracking a peer
+func (rm *ReputationManager) Forget(peerID string) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	delete(rm.peerReputation, peerID)
+}
+
+// Decay applies one decay step to all unpinned peers and returns the
+// peers that fell below the ban threshold. Banned peers stop being tracked.
+func (rm *ReputationManager) Decay() []string {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	var banned []string
+	for peerID, rep := range rm.peerReputation {
+		if rep.Pinned {
+			continue
+		}
+		before := rep.Score
+		rep.DecayFactor *= rm.config.DecayRate
+		rm.recompute(rep)
+		rm.record(rep, "decay", rep.Score-before)
+
+		if rep.Score < rm.config.BanThreshold {
+			banned = append(banned, peerID)
+			delete(rm.peerReputation, peerID)
+		}
+	}
+	return banned
+}
+
+func (rm *ReputationManager) recompute(rep *PeerReputation) {
+	if rep.Pinned {
+		rep.Score = rep.PinnedScore
+		return
+	}
+	rep.Score = clampScore(rep.Base*rep.DecayFactor + rep.Adjustment)
+}
+
+func (rm *ReputationManager) record(rep *PeerReputation, kind string, delta float64) {
+	rep.History = append(rep.History, ReputationEvent{
+		Time:  rm.clock.Now(),
+		Kind:  kind,
+		Delta: delta,
+		Score: rep.Score,
+	})
+	if limit := rm.config.HistorySize; limit > 0 && len(rep.History) > limit {
+		rep.History = rep.History[len(rep.History)-limit:]
+	}
+}
+
+func clampScore(score float64) float64 {
+	return math.Max(0, math.Min(1, score))
+}
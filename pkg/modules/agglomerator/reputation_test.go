@@ -0,0 +1,84 @@
+package agglomerator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+func TestReputationDecayAndBan(t *testing.T) {
+	config := DefaultReputationConfig()
+	config.DecayRate = 0.5
+	config.BanThreshold = 0.2
+	rm := NewReputationManager(config)
+
+	rm.Track("peer-a", 0.8)
+	rm.Track("peer-b", 0.3)
+
+	banned := rm.Decay()
+	assert.Equal(t, []string{"peer-b"}, banned)
+
+	score, ok := rm.Score("peer-a")
+	require.True(t, ok)
+	assert.InDelta(t, 0.4, score, 1e-9)
+
+	_, ok = rm.Score("peer-b")
+	assert.False(t, ok)
+}
+
+func TestReputationManualAdjustments(t *testing.T) {
+	rm := NewReputationManager(DefaultReputationConfig())
+	rm.Track("peer-a", 0.5)
+
+	rep, err := rm.Adjust("peer-a", 0.2)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.7, rep.Score, 1e-9)
+
+	rep, err = rm.Adjust("peer-a", -1)
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, rep.Score)
+
+	rep, err = rm.Pin("peer-a", 0.9)
+	require.NoError(t, err)
+	assert.Equal(t, 0.9, rep.Score)
+
+	// Pinned peers are exempt from decay
+	rm.Decay()
+	score, _ := rm.Score("peer-a")
+	assert.Equal(t, 0.9, score)
+
+	rep, err = rm.Unpin("peer-a")
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, rep.Score)
+	assert.Len(t, rep.History, 5)
+
+	_, err = rm.Adjust("unknown", 0.1)
+	assert.ErrorIs(t, err, ErrPeerNotFound)
+}
+
+func TestReputationDecaysOnClock(t *testing.T) {
+	clock := core.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	node := NewP2PInfiniteVectorNode("127.0.0.1", 0)
+	node.UseClock(clock)
+	node.Reputation().SetConfig(ReputationConfig{DecayRate: 0.5, DecayInterval: time.Minute, HistorySize: 10})
+	node.Reputation().Track("peer-a", 0.8)
+
+	node.lifecycle.Go(node.manageReputation)
+	t.Cleanup(func() { node.Stop(context.Background()) })
+	clock.BlockUntil(1)
+	score, _ := node.Reputation().Score("peer-a")
+	assert.InDelta(t, 0.4, score, 1e-9, "reputations decay once on start")
+
+	clock.Advance(time.Minute)
+	clock.BlockUntil(1)
+	score, _ = node.Reputation().Score("peer-a")
+	assert.InDelta(t, 0.2, score, 1e-9)
+
+	history := node.Reputation().List()[0].History
+	require.NotEmpty(t, history)
+	assert.Equal(t, clock.Now(), history[len(history)-1].Time, "history is stamped by the clock")
+}
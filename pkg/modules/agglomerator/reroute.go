@@ -0,0 +1,220 @@
+package agglomerator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/theaxiomverse/hydap-api/pkg/modules/base"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+var (
+	ErrInvalidReroute = errors.New("invalid reroute")
+	ErrNotStuck       = errors.New("transaction did not fail on its route")
+)
+
+// RerouteRequest is an operator's instruction to re-route a stuck
+// transaction. Without ToChain the best-scoring chain other than the failed
+// one, the source and those in Exclude is chosen.
+type RerouteRequest struct {
+	Operator string        `json:"operator"`
+	Reason   string        `json:"reason"`
+	ToChain  string        `json:"toChain,omitempty"` // Forces the new destination
+	Exclude  []string      `json:"exclude,omitempty"`
+	Weights  *RouteWeights `json:"weights,omitempty"` // Scores candidates instead of the default weights
+}
+
+func (r RerouteRequest) validate() error {
+	if r.Operator == "" || r.Reason == "" {
+		return fmt.Errorf("%w: operator and reason are required", ErrInvalidReroute)
+	}
+	if r.Weights != nil {
+		if err := r.Weights.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidReroute, err)
+		}
+	}
+	return nil
+}
+
+// Reroute is a re-route of a transaction as kept in the audit trail
+type Reroute struct {
+	TxID        string            `json:"txId"`
+	FailedChain string            `json:"failedChain"`       // Destination the transaction failed on
+	ToChain     string            `json:"toChain,omitempty"` // New destination, empty if none was found
+	Request     RerouteRequest    `json:"request"`
+	Status      string            `json:"status"` // Transaction status after the re-route
+	Error       string            `json:"error,omitempty"`
+	Route       *RouteExplanation `json:"route,omitempty"` // Kept with the transaction, not the audit trail
+	CreatedAt   time.Time         `json:"createdAt"`
+}
+
+// Reroute moves a transaction off the chain it failed on, to request.ToChain
+// or the best-scoring chain that can take it, and returns the new
+// destination with why it was chosen. tx carries the failed route.
+func (a *Agglomerator) Reroute(tx *Transaction, request RerouteRequest) (*Chain, *RouteExplanation, error) {
+	weights := DefaultRouteWeights()
+	if request.Weights != nil {
+		weights = *request.Weights
+	}
+	now := a.clock.Now()
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	source, exists := a.chains[tx.FromChain]
+	if !exists {
+		return nil, nil, fmt.Errorf("%w: %s", ErrChainNotFound, tx.FromChain)
+	}
+	excluded := map[string]bool{tx.FromChain: true, tx.ToChain: true}
+	for _, id := range request.Exclude {
+		excluded[id] = true
+	}
+
+	// The failed chain's pool keeps the transaction's vector and rank
+	failed := a.chains[tx.ToChain]
+	record := vectors.DatabaseRecord{ID: tx.ID, Metadata: map[string]interface{}{"fromChain": tx.FromChain}, Vector: tx.StateVector}
+	if failed != nil {
+		if pooled, exists := failed.TransactionPool.Get(tx.ID); exists {
+			record = pooled
+			tx.StateVector = pooled.Vector
+		}
+		for _, entry := range failed.PoolEntries() {
+			if entry.TxID == tx.ID {
+				tx.Priority, tx.Fee = entry.Priority, entry.Fee
+			}
+		}
+	}
+
+	var candidates []*Chain
+	var exclusions []RouteExclusion
+	if request.ToChain != "" {
+		to, exists := a.chains[request.ToChain]
+		switch {
+		case !exists:
+			return nil, nil, fmt.Errorf("%w: %s", ErrChainNotFound, request.ToChain)
+		case excluded[to.ID]:
+			return nil, nil, fmt.Errorf("%w: %s is the source, the failed chain or excluded", ErrInvalidReroute, to.ID)
+		case !chainVisible(to, source.Zone):
+			return nil, nil, fmt.Errorf("%w: %s", ErrZoneMismatch, to.ID)
+		}
+		if err := to.serves(tx, now); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", to.ID, err)
+		}
+		candidates = []*Chain{to}
+	} else {
+		for _, chain := range a.chains {
+			if excluded[chain.ID] || !chainVisible(chain, source.Zone) {
+				continue
+			}
+			if err := chain.serves(tx, now); err != nil {
+				exclusions = append(exclusions, RouteExclusion{ChainID: chain.ID, Reason: err.Error()})
+				continue
+			}
+			candidates = append(candidates, chain)
+		}
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].ID < candidates[j].ID })
+		var heldBack []RouteExclusion
+		candidates, heldBack = a.holdBackCanaries(candidates, tx)
+		exclusions = append(exclusions, heldBack...)
+	}
+
+	dims := a.dimsFor(tx)
+	var to *Chain
+	var best float64
+	for _, chain := range candidates {
+		if score := sumFactors(calculateRouteMetrics(chain, tx, dims).weightedFactors(weights)); to == nil || score > best {
+			to, best = chain, score
+		}
+	}
+	if to == nil {
+		return nil, nil, ErrNoRouteFound
+	}
+
+	entry := PoolEntry{TxID: tx.ID, Priority: tx.Priority, Fee: tx.Fee, AddedAt: time.Now()}
+	if err := a.movePooled(failed, to, entry, record); err != nil {
+		return nil, nil, err
+	}
+	a.events.record(EventTransactionRerouted, to.ID, tx.ID, TransactionReroutedData{
+		FailedChain: tx.ToChain,
+		Operator:    request.Operator,
+		Reason:      request.Reason,
+	})
+
+	explanation := explainWeightedRoute(tx, RouteModeRerouted, candidates, []string{to.ID}, dims, weights, func(string) bool { return true })
+	explanation.Excluded = exclusions
+	tx.ToChain = to.ID
+	return to, explanation, nil
+}
+
+// RerouteTransaction re-routes a transaction that failed on its destination
+// and submits it to the new one. Every attempt is kept in the audit trail
+// with the operator and reason; the returned re-route reports the
+// transaction's new status even when its submission fails.
+func (m *AgglomeratorModule) RerouteTransaction(txID string, request RerouteRequest) (Reroute, error) {
+	reroute := Reroute{TxID: txID, Request: request, CreatedAt: time.Now()}
+	if err := request.validate(); err != nil {
+		return reroute, err
+	}
+	if m.GetState() != base.StateRunning {
+		return reroute, fmt.Errorf("module not in running state: %s", m.GetState())
+	}
+	if m.GetReplica() != nil {
+		return reroute, ErrReadOnlyReplica
+	}
+	if !m.GetRoles().Has(RoleRouter) {
+		return reroute, fmt.Errorf("%w: %s", ErrRoleNotServed, RoleRouter)
+	}
+	store := m.GetTransactionStore()
+	if store == nil {
+		return reroute, fmt.Errorf("%w: no transaction history", ErrTransactionNotFound)
+	}
+
+	record, err := store.Get(txID)
+	if err != nil {
+		return reroute, err
+	}
+	if record.Status != TxStatusFailed {
+		return reroute, fmt.Errorf("%w: %s is %s", ErrNotStuck, txID, record.Status)
+	}
+	reroute.FailedChain = record.ToChain
+
+	tx := &Transaction{
+		ID:        record.ID,
+		FromChain: record.FromChain,
+		ToChain:   record.ToChain,
+		BlobRef:   record.BlobRef,
+		Metadata:  record.Metadata,
+	}
+	agg := m.GetAgglomerator()
+	agg.defaultStateVector(tx)
+	to, explanation, err := agg.Reroute(tx, request)
+	if err != nil {
+		reroute.Status, reroute.Error = TxStatusFailed, err.Error()
+		m.auditReroute(store, reroute)
+		return reroute, err
+	}
+	tx.Route = explanation
+
+	start := time.Now()
+	err = submitTransaction(context.Background(), to, tx)
+	m.recordRoute(tx, time.Since(start), err)
+	m.recordHistory(tx, record.Size, err)
+
+	reroute.ToChain, reroute.Route, reroute.Status = to.ID, explanation, TxStatusCompleted
+	if err != nil {
+		reroute.Status, reroute.Error = TxStatusFailed, err.Error()
+	}
+	m.auditReroute(store, reroute)
+	return reroute, nil
+}
+
+func (m *AgglomeratorModule) auditReroute(store *TransactionStore, reroute Reroute) {
+	m.logger.Log(m.Name(), "WARN", fmt.Sprintf("Transaction %s rerouted from %s to %q by %s (%s): %s",
+		reroute.TxID, reroute.FailedChain, reroute.ToChain, reroute.Request.Operator, reroute.Request.Reason, reroute.Status))
+	if err := store.RecordReroute(reroute); err != nil {
+		m.logger.Log(m.Name(), "ERROR", fmt.Sprintf("Failed to audit reroute of %s: %v", reroute.TxID, err))
+	}
+}
@@ -0,0 +1,113 @@
+package agglomerator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/base"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+func newRerouteModule(t *testing.T) *AgglomeratorModule {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	require.NoError(t, err)
+	t.Cleanup(func() { devNull.Close() })
+	module := NewAgglomeratorModule(nil, core.NewMetricsExporter(), &core.ModuleLogger{
+		Outputs: map[string]*os.File{"blockchain_agglomerator": devNull},
+	})
+	module.agglomerator = NewAgglomerator(AgglomeratorConfig{})
+	log, err := NewEventLog(nil)
+	require.NoError(t, err)
+	module.agglomerator.SetEventLog(log)
+	module.txStore, err = NewTransactionStore(filepath.Join(t.TempDir(), "transactions.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { module.txStore.Close() })
+
+	for id, endpoint := range map[string]string{
+		"source": "mock://source?blockTime=1ms",
+		"down":   "mock://down?blockTime=1ms&failureRate=1",
+		"up-a":   "mock://up-a?blockTime=1ms",
+		"up-b":   "mock://up-b?blockTime=1ms",
+	} {
+		require.NoError(t, module.agglomerator.RegisterChain(NewChain(id, endpoint, ProtocolMock)))
+	}
+	module.SetState(base.StateRunning)
+	return module
+}
+
+func TestRerouteMovesStuckTransaction(t *testing.T) {
+	module := newRerouteModule(t)
+	require.ErrorIs(t, module.ProcessTransaction(&Transaction{ID: "tx-1", FromChain: "source", ToChain: "down", Priority: 3}), ErrMockSubmitFailed)
+
+	_, err := module.RerouteTransaction("tx-1", RerouteRequest{Operator: "alice"})
+	assert.ErrorIs(t, err, ErrInvalidReroute, "a reason is required")
+	_, err = module.RerouteTransaction("missing", RerouteRequest{Operator: "alice", Reason: "outage"})
+	assert.ErrorIs(t, err, ErrTransactionNotFound)
+
+	reroute, err := module.RerouteTransaction("tx-1", RerouteRequest{Operator: "alice", Reason: "down is halted", Exclude: []string{"up-b"}})
+	require.NoError(t, err)
+	assert.Equal(t, "down", reroute.FailedChain)
+	assert.Equal(t, "up-a", reroute.ToChain)
+	assert.Equal(t, TxStatusCompleted, reroute.Status)
+	assert.Equal(t, RouteModeRerouted, reroute.Route.Mode)
+
+	down, _ := module.agglomerator.GetChain("down")
+	upA, _ := module.agglomerator.GetChain("up-a")
+	_, stuck := down.TransactionPool.Get("tx-1")
+	assert.False(t, stuck)
+	require.Len(t, upA.PoolEntries(), 1)
+	assert.Equal(t, 3, upA.PoolEntries()[0].Priority, "the transaction keeps its rank")
+
+	record, err := module.txStore.Get("tx-1")
+	require.NoError(t, err)
+	assert.Equal(t, TxStatusCompleted, record.Status)
+	assert.Equal(t, "up-a", record.ToChain)
+
+	_, err = module.RerouteTransaction("tx-1", RerouteRequest{Operator: "alice", Reason: "again"})
+	assert.ErrorIs(t, err, ErrNotStuck)
+
+	audit, err := module.txStore.Reroutes("tx-1")
+	require.NoError(t, err)
+	require.Len(t, audit, 1, "rejected requests are not audited")
+	assert.Equal(t, "alice", audit[0].Request.Operator)
+	assert.Equal(t, "down is halted", audit[0].Request.Reason)
+	assert.Equal(t, []string{"up-b"}, audit[0].Request.Exclude)
+
+	state, err := module.agglomerator.EventLog().State(time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, "up-a", state.Transactions["tx-1"].ToChain)
+	assert.ElementsMatch(t, []string{"source", "up-a"}, state.Transactions["tx-1"].Pools)
+}
+
+func TestRerouteConstraints(t *testing.T) {
+	module := newRerouteModule(t)
+	require.Error(t, module.ProcessTransaction(&Transaction{ID: "tx-1", FromChain: "source", ToChain: "down"}))
+
+	_, err := module.RerouteTransaction("tx-1", RerouteRequest{Operator: "alice", Reason: "outage", ToChain: "source"})
+	assert.ErrorIs(t, err, ErrInvalidReroute, "the source is no destination")
+	_, err = module.RerouteTransaction("tx-1", RerouteRequest{Operator: "alice", Reason: "outage", Weights: &RouteWeights{Speed: -1}})
+	assert.ErrorIs(t, err, ErrInvalidReroute)
+	_, err = module.RerouteTransaction("tx-1", RerouteRequest{Operator: "alice", Reason: "outage", Exclude: []string{"up-a", "up-b"}})
+	assert.ErrorIs(t, err, ErrNoRouteFound)
+
+	reroute, err := module.RerouteTransaction("tx-1", RerouteRequest{
+		Operator: "bob", Reason: "outage", ToChain: "up-b",
+		Weights: &RouteWeights{Similarity: 1},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "up-b", reroute.ToChain)
+	for _, candidate := range reroute.Route.Candidates {
+		assert.Equal(t, candidate.Factors[3].Value, candidate.Score, "only similarity is weighed")
+	}
+
+	audit, err := module.txStore.Reroutes("tx-1")
+	require.NoError(t, err)
+	require.Len(t, audit, 3, "attempts that found no route are audited too")
+	assert.Equal(t, "bob", audit[0].Request.Operator)
+	assert.Equal(t, TxStatusFailed, audit[1].Status)
+	assert.Contains(t, audit[1].Error, ErrNoRouteFound.Error())
+}
@@ -0,0 +1,284 @@
+package agglomerator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrRoleNotServed = errors.New("role not served by this node")
+	ErrNoRouter      = errors.New("no router peer with an API to relay to")
+)
+
+// NodeRole is a part of the work a node takes on
+type NodeRole string
+
+const (
+	RoleRouter  NodeRole = "router"  // Routes transactions through chain adapters
+	RoleStorage NodeRole = "storage" // Holds replicated records and answers queries for them
+	RoleGateway NodeRole = "gateway" // Takes client transactions, relaying them to routers
+)
+
+// NodeRoles is the set of roles a node serves; empty serves every role
+type NodeRoles []NodeRole
+
+// ParseNodeRoles parses role names, dropping duplicates
+func ParseNodeRoles(names []string) (NodeRoles, error) {
+	roles := make(NodeRoles, 0, len(names))
+	for _, name := range names {
+		role := NodeRole(name)
+		switch role {
+		case RoleRouter, RoleStorage, RoleGateway:
+		default:
+			return nil, fmt.Errorf("unknown node role %q", name)
+		}
+		if !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i] < roles[j] })
+	return roles, nil
+}
+
+// Has reports whether role is served
+func (r NodeRoles) Has(role NodeRole) bool {
+	return len(r) == 0 || slices.Contains(r, role)
+}
+
+// All returns the roles served, listing every role for a full node
+func (r NodeRoles) All() NodeRoles {
+	if len(r) == 0 {
+		return NodeRoles{RoleGateway, RoleRouter, RoleStorage}
+	}
+	return append(NodeRoles(nil), r...)
+}
+
+func (r NodeRoles) String() string {
+	names := make([]string, 0, 3)
+	for _, role := range r.All() {
+		names = append(names, string(role))
+	}
+	return strings.Join(names, ",")
+}
+
+// RoleConfig is the roles a node serves and how peers reach its API
+type RoleConfig struct {
+	Roles  NodeRoles
+	APIURL string // Base URL of the node's HTTP API, advertised so gateways can relay to it
+}
+
+// rolesDataID marks data transfers that advertise the sender's roles
+const rolesDataID = "roles"
+
+// roleAdvertiseInterval is how often roles are sent to peers that have not
+// had them
+const roleAdvertiseInterval = 5 * time.Second
+
+// thinQueryCacheEntries caps the query cache of nodes that hold no replicas
+const thinQueryCacheEntries = 64
+
+// RoleAdvertisement is the roles a peer announced
+type RoleAdvertisement struct {
+	Roles  NodeRoles `json:"roles"`
+	APIURL string    `json:"apiURL,omitempty"`
+}
+
+// PeerRole is a peer and the roles it announced
+type PeerRole struct {
+	PeerID string `json:"peerId"`
+	RoleAdvertisement
+}
+
+// PeerRoles holds the roles advertised by peers. Peers that have not
+// advertised any, including those predating roles, serve every role.
+type PeerRoles struct {
+	mu    sync.Mutex
+	roles map[string]RoleAdvertisement
+	sent  map[string]bool
+}
+
+func NewPeerRoles() *PeerRoles {
+	return &PeerRoles{
+		roles: make(map[string]RoleAdvertisement),
+		sent:  make(map[string]bool),
+	}
+}
+
+// Get returns the roles a peer advertised
+func (p *PeerRoles) Get(peerID string) (RoleAdvertisement, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ad, exists := p.roles[peerID]
+	return ad, exists
+}
+
+// Has reports whether a peer serves role
+func (p *PeerRoles) Has(peerID string, role NodeRole) bool {
+	ad, _ := p.Get(peerID)
+	return ad.Roles.Has(role)
+}
+
+func (p *PeerRoles) set(peerID string, ad RoleAdvertisement) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.roles[peerID] = ad
+}
+
+// Forget drops a departed peer's roles, so they are sent again if it returns
+func (p *PeerRoles) Forget(peerID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.roles, peerID)
+	delete(p.sent, peerID)
+}
+
+func (p *PeerRoles) needsRoles(peerID string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !p.sent[peerID]
+}
+
+func (p *PeerRoles) markSent(peerID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sent[peerID] = true
+}
+
+// SetRoles sets the roles the node serves and advertises. It must be
+// called before Start. A node without the storage role keeps a thin query
+// cache.
+func (node *P2PInfiniteVectorNode) SetRoles(config RoleConfig) {
+	node.roles = config
+	node.SetQueryConfig(node.queryConfig)
+}
+
+// Roles returns the roles the node serves
+func (node *P2PInfiniteVectorNode) Roles() RoleConfig {
+	return node.roles
+}
+
+// PeerRoles returns the roles advertised by peers
+func (node *P2PInfiniteVectorNode) PeerRoles() *PeerRoles {
+	return node.peerRoles
+}
+
+// ListPeerRoles returns the roles of every connected peer, sorted by ID
+func (node *P2PInfiniteVectorNode) ListPeerRoles() []PeerRole {
+	node.peerMutex.RLock()
+	peers := make([]string, 0, len(node.peers))
+	for peerID := range node.peers {
+		peers = append(peers, peerID)
+	}
+	node.peerMutex.RUnlock()
+	sort.Strings(peers)
+
+	roles := make([]PeerRole, 0, len(peers))
+	for _, peerID := range peers {
+		ad, _ := node.peerRoles.Get(peerID)
+		ad.Roles = ad.Roles.All()
+		roles = append(roles, PeerRole{PeerID: peerID, RoleAdvertisement: ad})
+	}
+	return roles
+}
+
+// storagePeers returns the connected peers that hold replicas
+func (node *P2PInfiniteVectorNode) storagePeers() []string {
+	node.peerMutex.RLock()
+	defer node.peerMutex.RUnlock()
+	peers := make([]string, 0, len(node.peers))
+	for peerID := range node.peers {
+		if node.peerRoles.Has(peerID, RoleStorage) {
+			peers = append(peers, peerID)
+		}
+	}
+	return peers
+}
+
+// RouterURLs returns the API URLs of the peers that route transactions,
+// best reputation first
+func (node *P2PInfiniteVectorNode) RouterURLs() []string {
+	type router struct {
+		url   string
+		score float64
+	}
+	var routers []router
+	for _, peer := range node.ListPeerRoles() {
+		if peer.APIURL == "" || !peer.Roles.Has(RoleRouter) {
+			continue
+		}
+		score, _ := node.reputation.Score(peer.PeerID)
+		routers = append(routers, router{url: peer.APIURL, score: score})
+	}
+	sort.SliceStable(routers, func(i, j int) bool { return routers[i].score > routers[j].score })
+
+	urls := make([]string, len(routers))
+	for i, r := range routers {
+		urls[i] = r.url
+	}
+	return urls
+}
+
+// advertiseRoles sends the node's roles to peers that have not had them
+func (node *P2PInfiniteVectorNode) advertiseRoles(ctx context.Context) {
+	ticker := time.NewTicker(roleAdvertiseInterval)
+	defer ticker.Stop()
+	for {
+		node.sendRoles()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (node *P2PInfiniteVectorNode) sendRoles() {
+	payload, err := json.Marshal(RoleAdvertisement{Roles: node.roles.Roles, APIURL: node.roles.APIURL})
+	if err != nil {
+		return
+	}
+	node.peerMutex.RLock()
+	peers := make([]string, 0, len(node.peers))
+	for peerID := range node.peers {
+		peers = append(peers, peerID)
+	}
+	node.peerMutex.RUnlock()
+
+	for _, peerID := range peers {
+		if node.peerRoles.needsRoles(peerID) {
+			node.send(peerID, rolesDataID, payload, PriorityControl)
+			node.peerRoles.markSent(peerID)
+		}
+	}
+}
+
+// receiveRoles keeps the roles a peer advertised, ignoring malformed ones
+func (node *P2PInfiniteVectorNode) receiveRoles(peerID string, payload []byte) {
+	var ad RoleAdvertisement
+	if err := json.Unmarshal(payload, &ad); err != nil {
+		return
+	}
+	names := make([]string, len(ad.Roles))
+	for i, role := range ad.Roles {
+		names[i] = string(role)
+	}
+	roles, err := ParseNodeRoles(names)
+	if err != nil {
+		return
+	}
+	ad.Roles = roles
+	if ad.APIURL != "" {
+		if u, err := url.Parse(ad.APIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			ad.APIURL = ""
+		}
+	}
+	node.peerRoles.set(peerID, ad)
+}
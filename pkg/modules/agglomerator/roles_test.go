@@ -0,0 +1,140 @@
+package agglomerator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+func advertise(t *testing.T, node *P2PInfiniteVectorNode, peerID string, ad RoleAdvertisement) {
+	payload, err := json.Marshal(ad)
+	require.NoError(t, err)
+	node.receiveRoles(peerID, payload)
+}
+
+func TestParseNodeRoles(t *testing.T) {
+	roles, err := ParseNodeRoles([]string{"storage", "router", "storage"})
+	require.NoError(t, err)
+	assert.Equal(t, NodeRoles{RoleRouter, RoleStorage}, roles)
+	assert.False(t, roles.Has(RoleGateway))
+	assert.Equal(t, "router,storage", roles.String())
+
+	var full NodeRoles
+	assert.True(t, full.Has(RoleGateway), "no roles serves every role")
+	assert.Equal(t, NodeRoles{RoleGateway, RoleRouter, RoleStorage}, full.All())
+
+	_, err = ParseNodeRoles([]string{"validator"})
+	assert.Error(t, err)
+}
+
+func TestPeerRolesSteerReplicasAndQueries(t *testing.T) {
+	querier := &fakeQuerier{answers: map[string][]vectors.DatabaseRecord{}}
+	node := queryTestNode(querier, "peer-a", "peer-b", "peer-c")
+	advertise(t, node, "peer-a", RoleAdvertisement{Roles: NodeRoles{RoleGateway}})
+	advertise(t, node, "peer-b", RoleAdvertisement{Roles: NodeRoles{RoleRouter}, APIURL: "http://peer-b:8080"})
+	advertise(t, node, "peer-c", RoleAdvertisement{Roles: NodeRoles{RoleStorage}, APIURL: "ftp://peer-c"})
+
+	ad, known := node.PeerRoles().Get("peer-c")
+	require.True(t, known)
+	assert.Empty(t, ad.APIURL, "only http URLs are kept")
+	advertise(t, node, "peer-d", RoleAdvertisement{Roles: NodeRoles{"validator"}})
+	_, known = node.PeerRoles().Get("peer-d")
+	assert.False(t, known, "unknown roles are ignored")
+
+	peers := node.selectReplicationPeers(3)
+	require.Len(t, peers, 1)
+	assert.Equal(t, "peer-c", peers[0].NodeID, "only storage peers take replicas")
+
+	vector := vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}
+	result := node.Query(context.Background(), vector, 10)
+	assert.Equal(t, 1, result.Queried, "only storage peers are queried")
+	assert.Zero(t, result.Skipped)
+
+	assert.Equal(t, []string{"http://peer-b:8080"}, node.RouterURLs())
+	listed := node.ListPeerRoles()
+	require.Len(t, listed, 3)
+	assert.Equal(t, NodeRoles{RoleGateway}, listed[0].Roles)
+
+	node.PeerRoles().Forget("peer-a")
+	assert.True(t, node.PeerRoles().Has("peer-a", RoleStorage), "peers that never advertised serve every role")
+}
+
+func TestNodeWithoutStorageKeepsNoReplicas(t *testing.T) {
+	node := writeTestNode(&fakeWriter{}, "peer-a")
+	node.SetRoles(RoleConfig{Roles: NodeRoles{RoleGateway, RoleRouter}})
+
+	transaction := vectors.DatabaseRecord{ID: "tx-1", Metadata: map[string]interface{}{"type": "transaction"}}
+	result, err := node.Store(context.Background(), transaction, WriteAckQuorum)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"peer-a"}, result.Acknowledged)
+	assert.NotContains(t, node.localDatabase.records, "tx-1", "the storage peer holds it")
+
+	alone := writeTestNode(&fakeWriter{})
+	alone.SetRoles(RoleConfig{Roles: NodeRoles{RoleRouter}})
+	_, err = alone.Store(context.Background(), transaction, WriteFireAndForget)
+	require.NoError(t, err)
+	assert.Contains(t, alone.localDatabase.records, "tx-1", "records no peer can hold are kept")
+
+	replica := func(id, kind string) DataTransferMessage {
+		return DataTransferMessage{SenderID: "peer-a", RecipientID: node.NodeID, DataID: id,
+			Payload: node.serializeRecord(vectors.DatabaseRecord{ID: id, Metadata: map[string]interface{}{"type": kind}}), Timestamp: time.Now()}
+	}
+	node.processDataTransfer(replica("tx-2", "transaction"))
+	node.processDataTransfer(replica("chain-eth", "chain_registration"))
+	assert.NotContains(t, node.localDatabase.records, "tx-2")
+	assert.Contains(t, node.localDatabase.records, "chain-eth", "chain registrations are kept everywhere")
+}
+
+func TestGatewayRelaysTransactionsToRouter(t *testing.T) {
+	var relayedBy string
+	router := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		relayedBy = r.Header.Get(relayedByHeader)
+		respondJSON(w, http.StatusOK, map[string]string{"path": r.URL.Path})
+	}))
+	defer router.Close()
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	require.NoError(t, err)
+	defer devNull.Close()
+	module := NewAgglomeratorModule(nil, core.NewMetricsExporter(), &core.ModuleLogger{
+		Outputs: map[string]*os.File{"blockchain_agglomerator": devNull},
+	})
+	module.agglomerator = NewAgglomerator(AgglomeratorConfig{})
+	module.roles = NodeRoles{RoleGateway}
+	node := queryTestNode(&fakeQuerier{}, "peer-router")
+	module.p2p = &P2PAgglomerator{p2pNode: node}
+	handler := NewAPI(module).Routes()
+
+	submit := func(header string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/transaction", strings.NewReader(`{}`))
+		if header != "" {
+			req.Header.Set(relayedByHeader, header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, submit("").Code, "no router has advertised an API")
+
+	advertise(t, node, "peer-router", RoleAdvertisement{Roles: NodeRoles{RoleRouter}, APIURL: router.URL})
+	rec := submit("")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"/transaction"`)
+	assert.Equal(t, node.NodeID, relayedBy)
+
+	assert.Equal(t, http.StatusMisdirectedRequest, submit("other-gateway").Code, "relayed submissions are not relayed again")
+
+	module.roles = NodeRoles{RoleStorage}
+	assert.Equal(t, http.StatusMisdirectedRequest, submit("").Code, "clients need a gateway")
+}
@@ -0,0 +1,128 @@
+package agglomerator
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// routePlan is the routing work shared by a group of transactions with the
+// same source, destination and cluster: one similarity query, and one
+// scoring of the candidates, done for the group's first transaction
+type routePlan struct {
+	made        bool
+	routable    bool // The query found similar vectors
+	explanation *RouteExplanation
+}
+
+// explain returns the plan's route explanation for tx, scoring the
+// candidates with score on first use. Later transactions of the group reuse
+// the scores, which were computed with the first one's state vector.
+func (plan *routePlan) explain(tx *Transaction, score func() *RouteExplanation) *RouteExplanation {
+	if plan.explanation == nil {
+		plan.explanation = score()
+		return plan.explanation
+	}
+	explanation := *plan.explanation
+	explanation.TxID = tx.ID
+	explanation.Route = append([]string(nil), plan.explanation.Route...)
+	explanation.Candidates = append([]RouteCandidate(nil), plan.explanation.Candidates...)
+	explanation.CreatedAt = time.Now()
+	return &explanation
+}
+
+// planKey groups transactions that can share a route plan
+type planKey struct {
+	fromChain  string
+	toChain    string
+	cluster    int
+	dims       int
+	similarity float64
+	arm        string // Experiment arm, as arms score candidates differently
+}
+
+// PlanningStats measures how much routing work batches share
+type PlanningStats struct {
+	Batches      uint64  `json:"batches"`
+	Transactions uint64  `json:"transactions"`
+	Plans        uint64  `json:"plans"`        // Similarity queries run for batched transactions
+	Amortization float64 `json:"amortization"` // Transactions per plan
+}
+
+type planningCounters struct {
+	mu    sync.Mutex
+	stats PlanningStats
+}
+
+func (c *planningCounters) record(transactions, plans int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Batches++
+	c.stats.Transactions += uint64(transactions)
+	c.stats.Plans += uint64(plans)
+}
+
+func (c *planningCounters) snapshot() PlanningStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	if stats.Plans > 0 {
+		stats.Amortization = float64(stats.Transactions) / float64(stats.Plans)
+	}
+	return stats
+}
+
+// PlanningStats returns how much route planning batched transactions shared
+func (a *Agglomerator) PlanningStats() PlanningStats {
+	return a.planning.snapshot()
+}
+
+// planKeyOf returns the group of a transaction. Transactions whose vector
+// cannot be clustered are planned alone, under a cluster unique to their
+// position in the batch.
+func (a *Agglomerator) planKeyOf(tx *Transaction, position int) planKey {
+	cluster, _, ok := a.clusters.Nearest(&tx.StateVector)
+	if !ok {
+		cluster = -1 - position
+	}
+	return planKey{
+		fromChain:  tx.FromChain,
+		toChain:    tx.ToChain,
+		cluster:    cluster,
+		dims:       a.dimsFor(tx),
+		similarity: tx.Similarity,
+		arm:        a.experimentArm(tx),
+	}
+}
+
+// ProcessTransactions handles a batch of cross-chain transactions in order.
+// Transactions between the same chains and in the same cluster share one
+// route plan, so a thousand transfers between two chains run one similarity
+// query instead of a thousand. It returns an error per transaction, nil for
+// those routed.
+func (a *Agglomerator) ProcessTransactions(ctx context.Context, txs []*Transaction) []error {
+	// Group before recording, as recording moves the cluster centroids
+	keys := make([]planKey, len(txs))
+	for i, tx := range txs {
+		a.defaultStateVector(tx)
+		keys[i] = a.planKeyOf(tx, i)
+	}
+
+	errs := make([]error, len(txs))
+	plans := make(map[planKey]*routePlan)
+	for i, tx := range txs {
+		plan, exists := plans[keys[i]]
+		if !exists {
+			plan = &routePlan{}
+			plans[keys[i]] = plan
+		}
+		toChain, err := a.recordPlanned(tx, plan)
+		if err == nil {
+			err = submitTransaction(ctx, toChain, tx)
+		}
+		errs[i] = err
+	}
+
+	a.planning.record(len(txs), len(plans))
+	return errs
+}
@@ -0,0 +1,58 @@
+package agglomerator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+func TestProcessTransactionsSharesRoutePlans(t *testing.T) {
+	agg := NewAgglomerator(AgglomeratorConfig{})
+	for _, id := range []string{"eth", "sol"} {
+		chain := NewChain(id, "http://localhost:8545", ProtocolEthereum)
+		chain.StateVector = vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}
+		require.NoError(t, agg.RegisterChain(chain))
+	}
+
+	var txs []*Transaction
+	for i := 0; i < 10; i++ {
+		txs = append(txs, &Transaction{ID: fmt.Sprintf("tx-%d", i), FromChain: "sol", ToChain: "eth",
+			StateVector: vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}})
+	}
+	txs = append(txs, &Transaction{ID: "reverse", FromChain: "eth", ToChain: "sol",
+		StateVector: vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}})
+	txs = append(txs, &Transaction{ID: "lost", FromChain: "sol", ToChain: "btc",
+		StateVector: vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}})
+
+	errs := agg.ProcessTransactions(context.Background(), txs)
+	require.Len(t, errs, len(txs))
+	for _, err := range errs[:11] {
+		assert.NoError(t, err)
+	}
+	assert.ErrorIs(t, errs[11], ErrChainNotFound, "each transaction keeps its own result")
+
+	first, later := txs[0].Route, txs[9].Route
+	require.NotNil(t, first)
+	require.NotNil(t, later)
+	assert.Equal(t, "tx-9", later.TxID)
+	assert.Equal(t, first.Candidates, later.Candidates, "later transactions reuse the scored candidates")
+	later.Candidates[0].Score = -1
+	assert.NotEqual(t, -1.0, first.Candidates[0].Score, "explanations do not share storage")
+	assert.Equal(t, []string{"sol"}, txs[10].Route.Route)
+
+	stats := agg.PlanningStats()
+	assert.Equal(t, uint64(1), stats.Batches)
+	assert.Equal(t, uint64(12), stats.Transactions)
+	assert.Equal(t, uint64(3), stats.Plans, "one plan per chain pair")
+	assert.Equal(t, 4.0, stats.Amortization)
+
+	eth, _ := agg.GetChain("eth")
+	for _, tx := range txs[:10] {
+		_, pooled := eth.TransactionPool.Get(tx.ID)
+		assert.True(t, pooled)
+	}
+}
@@ -1,8 +1,11 @@
 package agglomerator
 
 import (
+	"fmt"
 	"github.com/theaxiomverse/hydap-api/pkg/vectors"
 	"math"
+	"sort"
+	"time"
 )
 
 // RouteMetrics holds metrics for route evaluation
@@ -11,11 +14,17 @@ type RouteMetrics struct {
 	Finality   float64 // Time to finality
 	Cost       float64 // Transaction cost
 	Similarity float64 // Vector similarity score
+	Value      float64 // Value at risk of the transaction, 0 if not valued
+	Health     float64 // Chain health score, 1 when healthy
 }
 
-// calculateRouteMetrics computes metrics for a potential route
-func calculateRouteMetrics(chain *Chain, tx *Transaction) RouteMetrics {
-	protocol := determineProtocol(chain.ID)
+// calculateRouteMetrics computes metrics for a potential route, comparing
+// the first dims dimensions of the state vectors
+func calculateRouteMetrics(chain *Chain, tx *Transaction, dims int) RouteMetrics {
+	protocol := chain.Protocol
+	if protocol == "" {
+		protocol = determineProtocol(chain.ID)
+	}
 	config, exists := getProtocolConfig(protocol)
 	if !exists {
 		return RouteMetrics{}
@@ -30,7 +39,7 @@ func calculateRouteMetrics(chain *Chain, tx *Transaction) RouteMetrics {
 	similarity := vectors.ComputeVectorSimilarity(
 		chain.StateVector,
 		tx.StateVector,
-		50, // Consider parameterizing this
+		dims,
 	)
 
 	return RouteMetrics{
@@ -38,36 +47,122 @@ func calculateRouteMetrics(chain *Chain, tx *Transaction) RouteMetrics {
 		Finality:   finality,
 		Cost:       cost,
 		Similarity: similarity,
+		Value:      tx.Value,
+		Health:     chain.Health().Score,
+	}
+}
+
+// Weights for the route score factors
+const (
+	speedWeight      = 0.3
+	finalityWeight   = 0.25
+	costWeight       = 0.2
+	similarityWeight = 0.25
+	healthWeight     = 0.5
+
+	// Valued transactions add a term favouring fast finality, growing with
+	// the value at risk and approaching valueAtRiskWeight well past
+	// valueAtRiskScale
+	valueAtRiskWeight = 0.25
+	valueAtRiskScale  = 10000
+)
+
+// RouteFactor is one weighted term of a route score
+type RouteFactor struct {
+	Name         string  `json:"name"`
+	Value        float64 `json:"value"`
+	Weight       float64 `json:"weight"`
+	Contribution float64 `json:"contribution"` // Value * Weight
+}
+
+// RouteWeights are the weights of the route score factors. Operators may
+// score a re-route with their own.
+type RouteWeights struct {
+	Speed       float64 `json:"speed"`
+	Finality    float64 `json:"finality"`
+	Cost        float64 `json:"cost"`
+	Similarity  float64 `json:"similarity"`
+	ValueAtRisk float64 `json:"valueAtRisk"`
+	Health      float64 `json:"health"`
+}
+
+// DefaultRouteWeights returns the weights routes are normally scored with
+func DefaultRouteWeights() RouteWeights {
+	return RouteWeights{
+		Speed:       speedWeight,
+		Finality:    finalityWeight,
+		Cost:        costWeight,
+		Similarity:  similarityWeight,
+		ValueAtRisk: valueAtRiskWeight,
+		Health:      healthWeight,
+	}
+}
+
+// Validate checks that no weight is negative and at least one is positive
+func (w RouteWeights) Validate() error {
+	weights := []float64{w.Speed, w.Finality, w.Cost, w.Similarity, w.ValueAtRisk, w.Health}
+	total := 0.0
+	for _, weight := range weights {
+		if weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+			return fmt.Errorf("route weights must be finite and not negative")
+		}
+		total += weight
+	}
+	if total == 0 {
+		return fmt.Errorf("at least one route weight must be positive")
+	}
+	return nil
+}
+
+// factors breaks the metrics down into the weighted terms of the score
+func (m RouteMetrics) factors() []RouteFactor {
+	return m.weightedFactors(DefaultRouteWeights())
+}
+
+func (m RouteMetrics) weightedFactors(w RouteWeights) []RouteFactor {
+	factors := []RouteFactor{
+		{Name: "speed", Value: m.Speed, Weight: w.Speed},
+		{Name: "finality", Value: m.Finality, Weight: w.Finality},
+		{Name: "cost", Value: m.Cost, Weight: w.Cost},
+		{Name: "similarity", Value: m.Similarity, Weight: w.Similarity},
+		{Name: "health", Value: m.Health, Weight: w.Health},
+	}
+	if m.Value > 0 {
+		exposure := 1 - math.Exp(-m.Value/valueAtRiskScale)
+		factors = append(factors, RouteFactor{Name: "valueAtRisk", Value: m.Finality * exposure, Weight: w.ValueAtRisk})
 	}
+	for i := range factors {
+		factors[i].Contribution = factors[i].Value * factors[i].Weight
+	}
+	return factors
 }
 
 // evaluateRoute scores a potential route based on metrics
 func evaluateRoute(metrics RouteMetrics) float64 {
-	// Weights for different factors
-	const (
-		speedWeight      = 0.3
-		finalityWeight   = 0.25
-		costWeight       = 0.2
-		similarityWeight = 0.25
-	)
-
-	// Combine weighted factors
-	score := (metrics.Speed * speedWeight) +
-		(metrics.Finality * finalityWeight) +
-		(metrics.Cost * costWeight) +
-		(metrics.Similarity * similarityWeight)
+	return sumFactors(metrics.factors())
+}
 
+// sumFactors combines weighted factors into a score
+func sumFactors(factors []RouteFactor) float64 {
+	var score float64
+	for _, factor := range factors {
+		score += factor.Contribution
+	}
 	return score
 }
 
 // findOptimalRoute determines the best route for a transaction
-func findOptimalRoute(chains []*Chain, tx *Transaction) []*Chain {
+func findOptimalRoute(chains []*Chain, tx *Transaction, dims int) []*Chain {
+	return findWeightedRoute(chains, tx, dims, DefaultRouteWeights())
+}
+
+// findWeightedRoute is findOptimalRoute scoring with the given weights
+func findWeightedRoute(chains []*Chain, tx *Transaction, dims int, weights RouteWeights) []*Chain {
 	var bestRoute []*Chain
 	var bestScore float64
 
 	for _, chain := range chains {
-		metrics := calculateRouteMetrics(chain, tx)
-		score := evaluateRoute(metrics)
+		score := sumFactors(calculateRouteMetrics(chain, tx, dims).weightedFactors(weights))
 
 		if score > bestScore {
 			bestScore = score
@@ -77,3 +172,76 @@ func findOptimalRoute(chains []*Chain, tx *Transaction) []*Chain {
 
 	return bestRoute
 }
+
+// Route selection modes
+const (
+	RouteModeRequested = "requested" // The transaction named its destination
+	RouteModeScored    = "scored"    // The highest-scoring candidate was chosen
+	RouteModeRerouted  = "rerouted"  // An operator moved the transaction off a failed chain
+)
+
+// RouteCandidate is a chain considered for a transaction with the breakdown
+// of its score
+type RouteCandidate struct {
+	ChainID  string        `json:"chainId"`
+	Protocol string        `json:"protocol"`
+	Local    bool          `json:"local"`
+	Factors  []RouteFactor `json:"factors"`
+	Score    float64       `json:"score"`
+	Selected bool          `json:"selected"`
+}
+
+// RouteExplanation records why a transaction took its route: every candidate
+// considered, highest score first
+type RouteExplanation struct {
+	TxID        string            `json:"txId"`
+	Mode        string            `json:"mode"`
+	Route       []string          `json:"route"`
+	Candidates  []RouteCandidate  `json:"candidates"`
+	Excluded    []RouteExclusion  `json:"excluded,omitempty"`    // Chains that could not serve the transaction
+	Fees        *FeeEstimate      `json:"fees,omitempty"`        // Fees estimated against the transaction's budget
+	Experiment  *RouteArm         `json:"experiment,omitempty"`  // Arm whose weights scored the candidates
+	Simulation  *SimulationResult `json:"simulation,omitempty"`  // Pre-flight simulation on the destination
+	Sponsorship *Sponsorship      `json:"sponsorship,omitempty"` // Fees paid by sponsor wallets
+	CreatedAt   time.Time         `json:"createdAt"`
+}
+
+// explainRoute scores each candidate chain for tx and marks those on route
+func explainRoute(tx *Transaction, mode string, candidates []*Chain, route []string, dims int, local func(id string) bool) *RouteExplanation {
+	return explainWeightedRoute(tx, mode, candidates, route, dims, DefaultRouteWeights(), local)
+}
+
+// explainWeightedRoute is explainRoute scoring with the given weights
+func explainWeightedRoute(tx *Transaction, mode string, candidates []*Chain, route []string, dims int, weights RouteWeights, local func(id string) bool) *RouteExplanation {
+	onRoute := make(map[string]bool, len(route))
+	for _, id := range route {
+		onRoute[id] = true
+	}
+
+	explanation := &RouteExplanation{
+		TxID:       tx.ID,
+		Mode:       mode,
+		Route:      route,
+		Candidates: make([]RouteCandidate, 0, len(candidates)),
+		CreatedAt:  time.Now(),
+	}
+	for _, chain := range candidates {
+		factors := calculateRouteMetrics(chain, tx, dims).weightedFactors(weights)
+		explanation.Candidates = append(explanation.Candidates, RouteCandidate{
+			ChainID:  chain.ID,
+			Protocol: chain.Protocol,
+			Local:    local(chain.ID),
+			Factors:  factors,
+			Score:    sumFactors(factors),
+			Selected: onRoute[chain.ID],
+		})
+	}
+	sort.SliceStable(explanation.Candidates, func(i, j int) bool {
+		a, b := explanation.Candidates[i], explanation.Candidates[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.ChainID < b.ChainID
+	})
+	return explanation
+}
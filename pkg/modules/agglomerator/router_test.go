@@ -0,0 +1,93 @@
+package agglomerator
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+func TestExplainRoute(t *testing.T) {
+	chains := []*Chain{
+		testRouteChain("btc", ProtocolBitcoin),
+		testRouteChain("sol", ProtocolSolana),
+		testRouteChain("eth", ProtocolEthereum),
+	}
+	tx := &Transaction{ID: "tx-1", StateVector: vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}}
+
+	explanation := explainRoute(tx, RouteModeScored, chains, []string{"sol"}, DefaultCompareDims, func(id string) bool { return id != "btc" })
+	require.Len(t, explanation.Candidates, 3)
+	assert.Equal(t, "tx-1", explanation.TxID)
+	assert.Equal(t, []string{"sol"}, explanation.Route)
+
+	best := explanation.Candidates[0]
+	assert.Equal(t, "sol", best.ChainID, "solana's block time and cost give it the highest score")
+	assert.True(t, best.Selected)
+	assert.True(t, best.Local)
+	assert.Equal(t, findOptimalRoute(chains, tx, DefaultCompareDims)[0].ID, best.ChainID)
+
+	for i, candidate := range explanation.Candidates {
+		var total float64
+		for _, factor := range candidate.Factors {
+			assert.InDelta(t, factor.Value*factor.Weight, factor.Contribution, 1e-12)
+			total += factor.Contribution
+		}
+		assert.InDelta(t, candidate.Score, total, 1e-12)
+		if i > 0 {
+			assert.GreaterOrEqual(t, explanation.Candidates[i-1].Score, candidate.Score)
+			assert.False(t, candidate.Selected)
+		}
+	}
+	assert.False(t, explanation.Candidates[2].Local)
+}
+
+func TestTransactionStoreRoute(t *testing.T) {
+	store, err := NewTransactionStore(filepath.Join(t.TempDir(), "transactions.db"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	tx := &Transaction{ID: "tx-1", StateVector: vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}}
+	route := explainRoute(tx, RouteModeRequested, []*Chain{testRouteChain("eth", ProtocolEthereum)}, []string{"eth"}, DefaultCompareDims, func(string) bool { return true })
+	require.NoError(t, store.Record(TransactionRecord{ID: "tx-1", FromChain: "btc", ToChain: "eth", Status: TxStatusCompleted, Route: route}))
+	require.NoError(t, store.Record(TransactionRecord{ID: "tx-2", FromChain: "btc", ToChain: "eth", Status: TxStatusFailed}))
+
+	loaded, err := store.Route("tx-1")
+	require.NoError(t, err)
+	assert.Equal(t, RouteModeRequested, loaded.Mode)
+	require.Len(t, loaded.Candidates, 1)
+	assert.Equal(t, route.Candidates[0].Factors, loaded.Candidates[0].Factors)
+
+	_, err = store.Route("tx-2")
+	assert.ErrorIs(t, err, ErrTransactionNotFound)
+}
+
+func TestRouteDimensions(t *testing.T) {
+	agg := NewAgglomerator(AgglomeratorConfig{CompareDims: 20})
+	assert.Equal(t, 20, agg.CompareDims())
+	assert.Equal(t, 20, agg.dimsFor(&Transaction{}))
+	assert.Equal(t, 5, agg.dimsFor(&Transaction{Dimensions: 5}))
+	assert.Equal(t, DefaultCompareDims, NewAgglomerator(AgglomeratorConfig{}).CompareDims())
+
+	// The vectors agree on their first 5 dimensions only
+	chain := &Chain{ID: "eth", Protocol: ProtocolEthereum, StateVector: vectors.InfiniteVector{Generator: func(dim int) float64 {
+		return float64(dim % 5)
+	}}}
+	tx := &Transaction{StateVector: vectors.InfiniteVector{Generator: func(dim int) float64 {
+		if dim < 5 {
+			return float64(dim)
+		}
+		return float64(-dim)
+	}}}
+	assert.InDelta(t, 1, calculateRouteMetrics(chain, tx, 5).Similarity, 1e-9)
+	assert.Less(t, calculateRouteMetrics(chain, tx, 20).Similarity, 0.5)
+}
+
+func testRouteChain(id, protocol string) *Chain {
+	return &Chain{
+		ID:          id,
+		Protocol:    protocol,
+		StateVector: vectors.InfiniteVector{Generator: getProtocolGenerator(protocol)},
+	}
+}
@@ -0,0 +1,486 @@
+package agglomerator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+var ErrShardingDisabled = errors.New("sharding is not enabled")
+
+// ShardStrategy is how records are assigned to shards of the vector space
+type ShardStrategy string
+
+const (
+	ShardByHash     ShardStrategy = "hash"     // By a hash of the record ID; the default
+	ShardByProtocol ShardStrategy = "protocol" // By the record's protocol, keeping a protocol's records together
+)
+
+// ShardConfig partitions the vector space across the cluster. Each shard is
+// owned by Replicas nodes, chosen by rendezvous hashing over the node IDs,
+// so every node that sees the same members agrees on the owners.
+type ShardConfig struct {
+	Count    int           `json:"count"` // Shards; 0 keeps every record in one space
+	Strategy ShardStrategy `json:"strategy"`
+	Replicas int           `json:"replicas"` // Nodes owning each shard
+	Interval time.Duration `json:"interval"` // How often membership is checked for a rebalance
+}
+
+// DefaultShardConfig returns sharding disabled
+func DefaultShardConfig() ShardConfig {
+	return ShardConfig{
+		Strategy: ShardByHash,
+		Replicas: replicationFactor,
+		Interval: 10 * time.Second,
+	}
+}
+
+// ParseShardStrategy parses a strategy; empty is ShardByHash
+func ParseShardStrategy(strategy string) (ShardStrategy, error) {
+	switch ShardStrategy(strategy) {
+	case "":
+		return ShardByHash, nil
+	case ShardByHash, ShardByProtocol:
+		return ShardStrategy(strategy), nil
+	}
+	return "", fmt.Errorf("unknown shard strategy %q", strategy)
+}
+
+// ShardStats counts rebalances and the records they moved
+type ShardStats struct {
+	Rebalances uint64 `json:"rebalances"`
+	HandedOff  uint64 `json:"handedOff"` // Record copies sent to new owners
+	Released   uint64 `json:"released"`  // Local records dropped once handed off
+}
+
+// ShardRebalance is the outcome of one rebalance
+type ShardRebalance struct {
+	Version   uint64 `json:"version"`
+	Members   int    `json:"members"`
+	Changed   bool   `json:"changed"` // Membership differed from the last rebalance
+	HandedOff int    `json:"handedOff"`
+	Released  int    `json:"released"`
+}
+
+// ShardInfo describes one shard
+type ShardInfo struct {
+	ID     int      `json:"id"`
+	Owners []string `json:"owners"`
+	Local  int      `json:"local"` // Records this node holds in the shard
+}
+
+// ShardStatus is the shard map as this node sees it
+type ShardStatus struct {
+	Enabled bool        `json:"enabled"`
+	Config  ShardConfig `json:"config"`
+	Version uint64      `json:"version"` // Incremented by every rebalance that changed membership
+	Members []string    `json:"members"`
+	Shards  []ShardInfo `json:"shards"`
+	Stats   ShardStats  `json:"stats"`
+}
+
+// ShardMap assigns shards to the nodes that own them
+type ShardMap struct {
+	mu      sync.RWMutex
+	config  ShardConfig
+	members []string   // Sorted node IDs the owners were chosen from
+	owners  [][]string // Owners by shard, best first
+	version uint64
+	stats   ShardStats
+}
+
+// NewShardMap creates a shard map with no members
+func NewShardMap(config ShardConfig) *ShardMap {
+	m := &ShardMap{}
+	m.SetConfig(config)
+	return m
+}
+
+// SetConfig replaces the sharding settings, clearing the owners until the
+// next rebalance
+func (m *ShardMap) SetConfig(config ShardConfig) {
+	if config.Replicas <= 0 {
+		config.Replicas = replicationFactor
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultShardConfig().Interval
+	}
+	if config.Strategy == "" {
+		config.Strategy = ShardByHash
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.config = config
+	m.members = nil
+	m.owners = nil
+}
+
+// Config returns the sharding settings
+func (m *ShardMap) Config() ShardConfig {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.config
+}
+
+// Enabled reports whether records are partitioned
+func (m *ShardMap) Enabled() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.config.Count > 0
+}
+
+// ShardOf returns the shard a record belongs to
+func (m *ShardMap) ShardOf(record vectors.DatabaseRecord) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	key := record.ID
+	if protocol, ok := record.Metadata["protocol"].(string); ok && protocol != "" && m.config.Strategy == ShardByProtocol {
+		key = protocol
+	}
+	return shardOfKey(key, m.config.Count)
+}
+
+func shardOfKey(key string, count int) int {
+	if count <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return int(h.Sum64() % uint64(count))
+}
+
+// Owners returns the nodes owning a shard, best first; none until the
+// first rebalance
+func (m *ShardMap) Owners(shard int) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if shard < 0 || shard >= len(m.owners) {
+		return nil
+	}
+	return append([]string(nil), m.owners[shard]...)
+}
+
+// queryShards returns the shards a query for ids may find records in: the
+// shards of the IDs when records are sharded by ID, and every shard
+// otherwise
+func (m *ShardMap) queryShards(ids []string) []int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(ids) > 0 && m.config.Strategy == ShardByHash {
+		seen := make(map[int]bool, len(ids))
+		shards := make([]int, 0, len(ids))
+		for _, id := range ids {
+			shard := shardOfKey(id, m.config.Count)
+			if !seen[shard] {
+				seen[shard] = true
+				shards = append(shards, shard)
+			}
+		}
+		sort.Ints(shards)
+		return shards
+	}
+	shards := make([]int, m.config.Count)
+	for i := range shards {
+		shards[i] = i
+	}
+	return shards
+}
+
+// assign chooses the owners of every shard from members, reporting whether
+// membership changed since the last assignment. The previous owners are
+// returned so records can be handed to the new ones.
+func (m *ShardMap) assign(members []string) (previous [][]string, changed bool) {
+	sort.Strings(members)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previous = m.owners
+	if m.owners != nil && slices.Equal(m.members, members) {
+		return previous, false
+	}
+	owners := make([][]string, m.config.Count)
+	for shard := range owners {
+		owners[shard] = rendezvousOwners(shard, members, m.config.Replicas)
+	}
+	m.members = members
+	m.owners = owners
+	m.version++
+	return previous, true
+}
+
+// rendezvousOwners ranks members by their hash with the shard and keeps the
+// top n, so a member joining or leaving only moves the shards it ranks in
+func rendezvousOwners(shard int, members []string, n int) []string {
+	type ranked struct {
+		nodeID string
+		score  uint64
+	}
+	ranks := make([]ranked, len(members))
+	suffix := ":" + strconv.Itoa(shard)
+	for i, nodeID := range members {
+		sum := sha256.Sum256([]byte(nodeID + suffix))
+		ranks[i] = ranked{nodeID: nodeID, score: binary.BigEndian.Uint64(sum[:8])}
+	}
+	sort.Slice(ranks, func(i, j int) bool {
+		if ranks[i].score != ranks[j].score {
+			return ranks[i].score > ranks[j].score
+		}
+		return ranks[i].nodeID < ranks[j].nodeID
+	})
+	owners := make([]string, 0, min(n, len(ranks)))
+	for _, rank := range ranks[:min(n, len(ranks))] {
+		owners = append(owners, rank.nodeID)
+	}
+	return owners
+}
+
+// SetShardConfig replaces the sharding settings. It must be called before
+// Start.
+func (node *P2PInfiniteVectorNode) SetShardConfig(config ShardConfig) {
+	node.shards.SetConfig(config)
+}
+
+// Shards returns the node's shard map
+func (node *P2PInfiniteVectorNode) Shards() *ShardMap {
+	return node.shards
+}
+
+// sharded reports whether a record is partitioned. Chain registrations
+// are needed by every node to route, and private records only travel within
+// their zone, so neither is.
+func (node *P2PInfiniteVectorNode) sharded(record vectors.DatabaseRecord) bool {
+	return node.shards.Enabled() && record.Metadata["type"] != "chain_registration" && !isPrivate(record.Metadata)
+}
+
+// shardReplicas returns the connected owners of a record's shard other than
+// this node, and whether this node owns the shard. Before the first
+// rebalance, or with no owner reachable, the node keeps the record itself.
+func (node *P2PInfiniteVectorNode) shardReplicas(record vectors.DatabaseRecord) ([]string, bool) {
+	owners := node.shards.Owners(node.shards.ShardOf(record))
+	replicas := make([]string, 0, len(owners))
+	local := false
+	node.peerMutex.RLock()
+	for _, owner := range owners {
+		if owner == node.NodeID {
+			local = true
+		} else if _, connected := node.peers[owner]; connected {
+			replicas = append(replicas, owner)
+		}
+	}
+	node.peerMutex.RUnlock()
+	return replicas, local || len(replicas) == 0
+}
+
+// shardTargets picks the peers a sharded query asks. Shards this node owns
+// are covered by the local search. Otherwise the best-reputation owner of
+// each shard is asked and the other owners stand by for hedging; strict
+// reads ask every other owner of every shard.
+func (node *P2PInfiniteVectorNode) shardTargets(ids []string, strict bool) (targets, standby []string, skipped int) {
+	picked := make(map[string]bool)
+	held := make(map[string]bool)
+	ruledOut := make(map[string]bool)
+	for _, shard := range node.shards.queryShards(ids) {
+		owners := node.shards.Owners(shard)
+		if !strict && slices.Contains(owners, node.NodeID) {
+			continue
+		}
+
+		node.peerMutex.RLock()
+		peers := make([]string, 0, len(owners))
+		for _, owner := range owners {
+			if _, connected := node.peers[owner]; connected {
+				peers = append(peers, owner)
+			}
+		}
+		node.peerMutex.RUnlock()
+		sort.Slice(peers, func(i, j int) bool {
+			a, _ := node.reputation.Score(peers[i])
+			b, _ := node.reputation.Score(peers[j])
+			if a != b {
+				return a > b
+			}
+			return peers[i] < peers[j]
+		})
+
+		covered := false
+		for _, peerID := range peers {
+			switch {
+			case strict:
+				picked[peerID] = true
+			case picked[peerID] && !covered:
+				covered = true
+			case !covered && node.filters.shouldQuery(peerID, ids):
+				picked[peerID] = true
+				covered = true
+			case !covered:
+				ruledOut[peerID] = true
+			default:
+				held[peerID] = true
+			}
+		}
+	}
+
+	for peerID := range picked {
+		targets = append(targets, peerID)
+	}
+	for peerID := range held {
+		if !picked[peerID] {
+			standby = append(standby, peerID)
+		}
+	}
+	for peerID := range ruledOut {
+		if !picked[peerID] {
+			skipped++
+		}
+	}
+	sort.Strings(targets)
+	sort.Slice(standby, func(i, j int) bool {
+		a, _ := node.reputation.Score(standby[i])
+		b, _ := node.reputation.Score(standby[j])
+		if a != b {
+			return a > b
+		}
+		return standby[i] < standby[j]
+	})
+	return targets, standby, skipped
+}
+
+// RebalanceShards reassigns shards to this node and its connected storage
+// peers, sends every local record to the owners its shard gained, and drops
+// the records of shards this node no longer owns once they are sent. Peers
+// that see the same members reach the same assignment.
+func (node *P2PInfiniteVectorNode) RebalanceShards() (ShardRebalance, error) {
+	if !node.shards.Enabled() {
+		return ShardRebalance{}, ErrShardingDisabled
+	}
+
+	members := node.shardMembers()
+	previous, changed := node.shards.assign(members)
+	result := ShardRebalance{Members: len(members), Changed: changed}
+
+	node.localDatabase.mu.RLock()
+	records := make([]vectors.DatabaseRecord, 0, len(node.localDatabase.records))
+	for _, record := range node.localDatabase.records {
+		if node.sharded(record) {
+			records = append(records, record)
+		}
+	}
+	node.localDatabase.mu.RUnlock()
+
+	var released []string
+	for _, record := range records {
+		shard := node.shards.ShardOf(record)
+		var before []string
+		if shard < len(previous) {
+			before = previous[shard]
+		}
+		replicas, keep := node.shardReplicas(record)
+		payload := node.serializeRecord(record)
+		for _, peerID := range replicas {
+			// Owners that held the shard already have the record, unless
+			// this node is letting it go
+			if keep && slices.Contains(before, peerID) {
+				continue
+			}
+			node.send(peerID, record.ID, payload, PriorityBulk)
+			result.HandedOff++
+		}
+		if !keep {
+			released = append(released, record.ID)
+		}
+	}
+	result.Released = node.localDatabase.remove(released)
+
+	node.shards.mu.Lock()
+	node.shards.stats.Rebalances++
+	node.shards.stats.HandedOff += uint64(result.HandedOff)
+	node.shards.stats.Released += uint64(result.Released)
+	result.Version = node.shards.version
+	node.shards.mu.Unlock()
+	return result, nil
+}
+
+// rebalanceShards rebalances whenever the node's peers change
+func (node *P2PInfiniteVectorNode) rebalanceShards(ctx context.Context) {
+	config := node.shards.Config()
+	if config.Count <= 0 {
+		return
+	}
+	node.RebalanceShards()
+	ticker := time.NewTicker(config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if node.membersChanged() {
+				node.RebalanceShards()
+			}
+		}
+	}
+}
+
+// shardMembers returns the nodes shards can be assigned to: this node and
+// its connected peers that hold replicas
+func (node *P2PInfiniteVectorNode) shardMembers() []string {
+	members := node.storagePeers()
+	if node.roles.Roles.Has(RoleStorage) {
+		members = append(members, node.NodeID)
+	}
+	return members
+}
+
+// membersChanged reports whether the connected peers differ from the
+// members shards were last assigned to
+func (node *P2PInfiniteVectorNode) membersChanged() bool {
+	members := node.shardMembers()
+	sort.Strings(members)
+
+	node.shards.mu.RLock()
+	defer node.shards.mu.RUnlock()
+	return !slices.Equal(node.shards.members, members)
+}
+
+// ShardStatus reports the shard map and how many records this node holds
+// in each shard
+func (node *P2PInfiniteVectorNode) ShardStatus() ShardStatus {
+	config := node.shards.Config()
+	status := ShardStatus{Enabled: config.Count > 0, Config: config, Members: []string{}, Shards: []ShardInfo{}}
+	if !status.Enabled {
+		return status
+	}
+
+	local := make(map[int]int)
+	node.localDatabase.mu.RLock()
+	for _, record := range node.localDatabase.records {
+		if node.sharded(record) {
+			local[node.shards.ShardOf(record)]++
+		}
+	}
+	node.localDatabase.mu.RUnlock()
+
+	node.shards.mu.RLock()
+	defer node.shards.mu.RUnlock()
+	status.Version = node.shards.version
+	status.Members = append(status.Members, node.shards.members...)
+	status.Stats = node.shards.stats
+	for shard := 0; shard < config.Count; shard++ {
+		info := ShardInfo{ID: shard, Owners: []string{}, Local: local[shard]}
+		if shard < len(node.shards.owners) {
+			info.Owners = append(info.Owners, node.shards.owners[shard]...)
+		}
+		status.Shards = append(status.Shards, info)
+	}
+	return status
+}
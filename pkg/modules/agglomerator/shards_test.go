@@ -0,0 +1,136 @@
+package agglomerator
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+func TestShardOwnersMoveOnlyWithTheirMember(t *testing.T) {
+	shards := NewShardMap(ShardConfig{Count: 64, Replicas: 2})
+	_, changed := shards.assign([]string{"node-a", "node-b", "node-c", "node-d"})
+	require.True(t, changed)
+	before := make([][]string, 64)
+	for shard := range before {
+		before[shard] = shards.Owners(shard)
+		assert.Len(t, before[shard], 2)
+	}
+
+	_, changed = shards.assign([]string{"node-d", "node-c", "node-b", "node-a"})
+	assert.False(t, changed, "the same members in another order keep the assignment")
+
+	shards.assign([]string{"node-a", "node-b", "node-c", "node-d", "node-e"})
+	moved := 0
+	for shard := range before {
+		if after := shards.Owners(shard); !slices.Equal(before[shard], after) {
+			assert.Contains(t, after, "node-e", "only shards the new member ranks in move")
+			moved++
+		}
+	}
+	assert.Positive(t, moved)
+	assert.Less(t, moved, 64)
+
+	shards.assign([]string{"node-a", "node-c", "node-d", "node-e"})
+	for shard := range before {
+		assert.NotContains(t, shards.Owners(shard), "node-b")
+	}
+
+	protocol := NewShardMap(ShardConfig{Count: 8, Strategy: ShardByProtocol})
+	eth := func(id string) vectors.DatabaseRecord {
+		return vectors.DatabaseRecord{ID: id, Metadata: map[string]interface{}{"protocol": string(ProtocolEthereum)}}
+	}
+	assert.Equal(t, protocol.ShardOf(eth("tx-1")), protocol.ShardOf(eth("tx-2")), "a protocol's records share a shard")
+}
+
+func TestShardedStoreAndQueryReachOwners(t *testing.T) {
+	querier := &fakeQuerier{answers: map[string][]vectors.DatabaseRecord{}}
+	node := queryTestNode(querier, "peer-a", "peer-b", "peer-c")
+	node.SetShardConfig(ShardConfig{Count: 16, Replicas: 1})
+	_, err := node.RebalanceShards()
+	require.NoError(t, err)
+
+	// A record whose shard a peer owns is sent there and not kept
+	var remote vectors.DatabaseRecord
+	var owner string
+	for i := 0; owner == ""; i++ {
+		record := vectors.DatabaseRecord{ID: fmt.Sprintf("record-%d", i)}
+		if owners := node.Shards().Owners(node.Shards().ShardOf(record)); owners[0] != node.NodeID {
+			remote, owner = record, owners[0]
+		}
+	}
+	result, err := node.Store(context.Background(), remote, WriteFireAndForget)
+	require.NoError(t, err)
+	assert.Equal(t, []string{owner}, result.Replicas)
+	node.localDatabase.mu.RLock()
+	_, kept := node.localDatabase.records[remote.ID]
+	node.localDatabase.mu.RUnlock()
+	assert.False(t, kept, "records of shards owned elsewhere are not kept")
+
+	// A query for the record only asks its shard's owner
+	querier.answers[owner] = []vectors.DatabaseRecord{remote}
+	vector := vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}
+	query := node.Query(context.Background(), vector, 10, remote.ID)
+	assert.Equal(t, 1, query.Queried)
+	assert.Len(t, query.Records, 1)
+
+	// A vector query asks one owner per shard held elsewhere
+	query = node.Query(context.Background(), vector, 10)
+	assert.LessOrEqual(t, query.Queried, 3)
+
+	status := node.ShardStatus()
+	assert.True(t, status.Enabled)
+	assert.Len(t, status.Shards, 16)
+	assert.Len(t, status.Members, 4)
+
+	_, err = queryTestNode(querier).RebalanceShards()
+	assert.ErrorIs(t, err, ErrShardingDisabled)
+}
+
+func TestRebalanceHandsOffRecords(t *testing.T) {
+	node := queryTestNode(&fakeQuerier{})
+	node.SetShardConfig(ShardConfig{Count: 8, Replicas: 1})
+	_, err := node.RebalanceShards()
+	require.NoError(t, err)
+	for i := 0; i < 40; i++ {
+		_, err := node.Store(context.Background(), vectors.DatabaseRecord{ID: fmt.Sprintf("record-%d", i)}, WriteFireAndForget)
+		require.NoError(t, err)
+	}
+	node.localDatabase.mu.RLock()
+	require.Len(t, node.localDatabase.records, 40, "a node alone owns every shard")
+	node.localDatabase.mu.RUnlock()
+
+	result, err := node.RebalanceShards()
+	require.NoError(t, err)
+	assert.False(t, result.Changed)
+	assert.Zero(t, result.Released)
+
+	for _, peerID := range []string{"peer-a", "peer-b", "peer-c"} {
+		node.peers[peerID] = &PeerInfo{NodeID: peerID}
+	}
+	require.True(t, node.membersChanged())
+	result, err = node.RebalanceShards()
+	require.NoError(t, err)
+	assert.True(t, result.Changed)
+	assert.Equal(t, uint64(2), result.Version)
+	assert.Positive(t, result.Released)
+	assert.Equal(t, result.Released, result.HandedOff, "each released record goes to its one new owner")
+
+	node.localDatabase.mu.RLock()
+	held := len(node.localDatabase.records)
+	node.localDatabase.mu.RUnlock()
+	assert.Equal(t, 40-result.Released, held)
+	local := 0
+	for _, shard := range node.ShardStatus().Shards {
+		if shard.Local > 0 {
+			assert.Equal(t, []string{node.NodeID}, shard.Owners)
+		}
+		local += shard.Local
+	}
+	assert.Equal(t, held, local)
+	assert.Equal(t, uint64(result.Released), node.ShardStatus().Stats.Released)
+}
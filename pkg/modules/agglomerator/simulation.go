@@ -0,0 +1,151 @@
+package agglomerator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultSimulationTimeout = 5 * time.Second
+
+	// maxSimulatedReroutes bounds the other destinations simulated for a
+	// transaction whose own destination would reject it
+	maxSimulatedReroutes = 3
+
+	// simulationMetadataKey records the outcome of a transaction's
+	// simulation, so it can be found with meta.simulation
+	simulationMetadataKey = "simulation"
+)
+
+// Simulation outcomes
+const (
+	SimulationPassed  = "passed"
+	SimulationFailed  = "failed"  // The destination's network would reject the transaction
+	SimulationSkipped = "skipped" // The destination could not simulate it
+)
+
+// SimulationConfig controls the pre-flight simulation of transactions on
+// their destination before they are routed
+type SimulationConfig struct {
+	Enabled bool
+	Timeout time.Duration // Per simulation
+	Reroute bool          // Try the highest-scoring destinations whose simulation passes
+}
+
+// DefaultSimulationConfig simulates nothing until enabled
+func DefaultSimulationConfig() SimulationConfig {
+	return SimulationConfig{Timeout: defaultSimulationTimeout}
+}
+
+// parseSimulationConfig reads the simulation settings, falling back to the
+// defaults for those unset
+func parseSimulationConfig(moduleConfig *ModuleConfig) (SimulationConfig, error) {
+	settings := moduleConfig.Simulation
+	config := DefaultSimulationConfig()
+	config.Enabled = settings.Enabled
+	config.Reroute = settings.Reroute
+	if settings.Timeout != "" {
+		timeout, err := parseDuration(settings.Timeout)
+		if err != nil || timeout <= 0 {
+			return config, fmt.Errorf("invalid simulation timeout: %s", settings.Timeout)
+		}
+		config.Timeout = timeout
+	}
+	return config, nil
+}
+
+// ChainSimulation is the outcome of simulating a transaction on one chain
+type ChainSimulation struct {
+	ChainID string `json:"chainId"`
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+
+	err error
+}
+
+// SimulationResult is the pre-flight simulation of a transaction: its
+// destination's, then those of the destinations tried in its place
+type SimulationResult struct {
+	Outcome      string            `json:"outcome"` // Of the destination routed to
+	Chains       []ChainSimulation `json:"chains"`
+	ReroutedFrom string            `json:"reroutedFrom,omitempty"` // Destination requested, when its simulation failed
+}
+
+// simulate dry-runs tx on a chain. Chains that cannot simulate, or whose
+// endpoints could not be asked, are skipped rather than failed.
+func (a *Agglomerator) simulate(ctx context.Context, chainID string, tx *Transaction, timeout time.Duration) ChainSimulation {
+	result := ChainSimulation{ChainID: chainID, Outcome: SimulationSkipped}
+	chain, err := a.GetChain(chainID)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	simulator, ok := chain.Adapter().(Simulator)
+	if !ok {
+		result.Error = ErrSimulationUnsupported.Error()
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err = simulator.Simulate(ctx, tx)
+	switch {
+	case err == nil:
+		result.Outcome = SimulationPassed
+	case errors.Is(err, ErrSimulationFailed):
+		result.Outcome = SimulationFailed
+		result.Error, result.err = err.Error(), err
+	default:
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// simulateRoute dry-runs a transaction on its destination when simulation
+// is enabled. A destination that would reject it fails the transaction
+// with ErrSimulationFailed, or with reroute moves it to the
+// highest-scoring destination whose simulation passes. The result is kept
+// with the transaction and recorded in its metadata.
+func (m *AgglomeratorModule) simulateRoute(tx *Transaction) error {
+	tx.Simulation = nil
+	m.mu.RLock()
+	config := m.simulation
+	m.mu.RUnlock()
+	if !config.Enabled {
+		return nil
+	}
+
+	agg := m.GetAgglomerator()
+	ctx := context.Background()
+	simulation := agg.simulate(ctx, tx.ToChain, tx, config.Timeout)
+	result := &SimulationResult{Chains: []ChainSimulation{simulation}}
+	if simulation.Outcome == SimulationFailed && config.Reroute {
+		alternatives := agg.destinationsByScore(tx)
+		if len(alternatives) > maxSimulatedReroutes {
+			alternatives = alternatives[:maxSimulatedReroutes]
+		}
+		for _, id := range alternatives {
+			rerouted := agg.simulate(ctx, id, tx, config.Timeout)
+			result.Chains = append(result.Chains, rerouted)
+			if rerouted.Outcome != SimulationFailed {
+				result.ReroutedFrom = tx.ToChain
+				tx.ToChain = id
+				simulation = rerouted
+				break
+			}
+		}
+	}
+
+	result.Outcome = simulation.Outcome
+	tx.Simulation = result
+	if tx.Metadata == nil {
+		tx.Metadata = make(map[string]string)
+	}
+	tx.Metadata[simulationMetadataKey] = result.Outcome
+	if simulation.Outcome == SimulationFailed {
+		return fmt.Errorf("%s: %w", simulation.ChainID, simulation.err)
+	}
+	return nil
+}
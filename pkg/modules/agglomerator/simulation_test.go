@@ -0,0 +1,84 @@
+package agglomerator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+// newSimulationModule has a mock destination reverting every transaction,
+// a clean one, and an Ethereum chain with no adapter to simulate on
+func newSimulationModule(t *testing.T, config SimulationConfig) *AgglomeratorModule {
+	agg := NewAgglomerator(AgglomeratorConfig{})
+	for id, endpoint := range map[string]string{
+		"source":    "mock://source?blockTime=1ms",
+		"reverting": "mock://reverting?blockTime=1ms&revertRate=1",
+		"clean":     "mock://clean?blockTime=1ms",
+	} {
+		require.NoError(t, agg.RegisterChain(NewChain(id, endpoint, ProtocolMock)))
+	}
+	require.NoError(t, agg.RegisterChain(NewChain("plain", "http://localhost:8545", ProtocolEthereum)))
+	return &AgglomeratorModule{agglomerator: agg, simulation: config}
+}
+
+func simulatedTx(to string) *Transaction {
+	return &Transaction{ID: "tx-1", FromChain: "source", ToChain: to,
+		StateVector: vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolMock)}}
+}
+
+func TestSimulationRejectsRoute(t *testing.T) {
+	config := DefaultSimulationConfig()
+	tx := simulatedTx("reverting")
+	require.NoError(t, newSimulationModule(t, config).simulateRoute(tx))
+	assert.Nil(t, tx.Simulation, "nothing is simulated until enabled")
+	err := newSimulationModule(t, config).agglomerator.ProcessTransaction(context.Background(), tx)
+	assert.ErrorIs(t, err, ErrMockSubmitFailed, "without simulation the revert fails submission")
+
+	config.Enabled = true
+	m := newSimulationModule(t, config)
+	tx = simulatedTx("reverting")
+	err = m.simulateRoute(tx)
+	assert.ErrorIs(t, err, ErrSimulationFailed)
+	assert.ErrorContains(t, err, "reverting: transaction simulation failed: tx-1 reverts on reverting")
+	assert.Equal(t, "reverting", tx.ToChain)
+	assert.Equal(t, SimulationFailed, tx.Simulation.Outcome)
+	assert.Equal(t, SimulationFailed, tx.Metadata["simulation"])
+
+	tx = simulatedTx("clean")
+	require.NoError(t, m.simulateRoute(tx))
+	assert.Equal(t, []ChainSimulation{{ChainID: "clean", Outcome: SimulationPassed}}, tx.Simulation.Chains)
+	_, err = m.agglomerator.recordTransaction(tx)
+	require.NoError(t, err)
+	assert.Same(t, tx.Simulation, tx.Route.Simulation, "the simulation is reported with the route")
+
+	tx = simulatedTx("plain")
+	require.NoError(t, m.simulateRoute(tx), "chains that cannot simulate are routed to")
+	assert.Equal(t, SimulationSkipped, tx.Simulation.Outcome)
+	assert.Equal(t, ErrSimulationUnsupported.Error(), tx.Simulation.Chains[0].Error)
+}
+
+func TestSimulationReroutes(t *testing.T) {
+	m := newSimulationModule(t, SimulationConfig{Enabled: true, Timeout: defaultSimulationTimeout, Reroute: true})
+
+	tx := simulatedTx("reverting")
+	require.NoError(t, m.simulateRoute(tx))
+	assert.NotEqual(t, "reverting", tx.ToChain)
+	assert.Equal(t, "reverting", tx.Simulation.ReroutedFrom)
+	require.Len(t, tx.Simulation.Chains, 2)
+	assert.Equal(t, SimulationFailed, tx.Simulation.Chains[0].Outcome)
+	assert.Equal(t, tx.ToChain, tx.Simulation.Chains[1].ChainID)
+	assert.NotEqual(t, SimulationFailed, tx.Simulation.Outcome)
+
+	// Chains re-registered to revert everything leave nowhere to go
+	for _, id := range []string{"clean", "plain"} {
+		require.NoError(t, m.agglomerator.RegisterChain(NewChain(id, "mock://"+id+"?blockTime=1ms&revertRate=1", ProtocolMock)))
+	}
+	tx = simulatedTx("reverting")
+	assert.ErrorIs(t, m.simulateRoute(tx), ErrSimulationFailed)
+	assert.Equal(t, "reverting", tx.ToChain)
+	assert.Len(t, tx.Simulation.Chains, 3)
+	assert.Empty(t, tx.Simulation.ReroutedFrom)
+}
@@ -0,0 +1,387 @@
+package agglomerator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+var ErrSponsorBudgetExceeded = errors.New("sponsor budget exceeded")
+
+const (
+	defaultSponsorPeriod = 24 * time.Hour
+
+	// sponsorCostMetadataKey records the fees sponsored for a transaction,
+	// in the asset registry's currency, so they can be found with
+	// meta.sponsorCost
+	sponsorCostMetadataKey = "sponsorCost"
+)
+
+// SponsorConfig controls sponsored execution: a sponsor wallet per chain
+// pays the fees of transactions from tenants with a budget, so that their
+// clients need no native tokens on those chains
+type SponsorConfig struct {
+	Wallets       map[string]string  // Sponsor address by chain
+	Budgets       map[string]float64 // Per period in the registry's currency, by tenant
+	DefaultBudget float64            // For tenants not in Budgets; 0 sponsors none
+	Period        time.Duration      // Budgets are spent per period; whole days start at midnight UTC
+}
+
+// parseSponsorConfig reads the sponsorship settings, falling back to the
+// defaults for those unset
+func parseSponsorConfig(moduleConfig *ModuleConfig) (SponsorConfig, error) {
+	settings := moduleConfig.Sponsors
+	config := SponsorConfig{
+		Wallets:       settings.Wallets,
+		Budgets:       settings.Budgets,
+		DefaultBudget: settings.DefaultBudget,
+		Period:        defaultSponsorPeriod,
+	}
+	if settings.Period != "" {
+		period, err := parseDuration(settings.Period)
+		if err != nil || period <= 0 {
+			return config, fmt.Errorf("invalid sponsors period: %s", settings.Period)
+		}
+		config.Period = period
+	}
+	return config, nil
+}
+
+// SponsoredHop is the fee a sponsor wallet pays on one chain
+type SponsoredHop struct {
+	ChainID string  `json:"chainId"`
+	Sponsor string  `json:"sponsor"` // Address of the wallet paying
+	Fee     float64 `json:"fee"`     // In whole units of Asset
+	Asset   string  `json:"asset,omitempty"`
+	Cost    float64 `json:"cost"`            // Fee in the registry's currency
+	Error   string  `json:"error,omitempty"` // Why the fee could not be estimated; the hop counts as free
+}
+
+// Sponsorship is the fees sponsor wallets pay for a transaction, charged to
+// its tenant's budget
+type Sponsorship struct {
+	Tenant   string         `json:"tenant"`
+	Currency string         `json:"currency"`
+	Hops     []SponsoredHop `json:"hops"`
+	Total    float64        `json:"total"`
+}
+
+// Sponsor returns the address of the wallet paying the transaction's fees
+// on a chain, or "" when the client pays them. Adapters submit sponsored
+// transactions with it as the fee payer.
+func (s *Sponsorship) Sponsor(chainID string) string {
+	if s == nil {
+		return ""
+	}
+	for _, hop := range s.Hops {
+		if hop.ChainID == chainID {
+			return hop.Sponsor
+		}
+	}
+	return ""
+}
+
+// TenantAccount is a tenant's sponsored spending in the current period
+type TenantAccount struct {
+	Tenant       string  `json:"tenant"`
+	Budget       float64 `json:"budget"`
+	Spent        float64 `json:"spent"`
+	Reserved     float64 `json:"reserved"` // Held for transactions being routed
+	Remaining    float64 `json:"remaining"`
+	Transactions uint64  `json:"transactions"`
+}
+
+// SponsorWallet is a sponsor wallet's spending in the current period
+type SponsorWallet struct {
+	ChainID      string  `json:"chainId"`
+	Address      string  `json:"address"`
+	Fees         float64 `json:"fees"` // In whole units of the chain's fee asset
+	Cost         float64 `json:"cost"`
+	Transactions uint64  `json:"transactions"`
+}
+
+// SponsorReport is the sponsored spending in the current period
+type SponsorReport struct {
+	Currency    string          `json:"currency"`
+	PeriodStart time.Time       `json:"periodStart"`
+	PeriodEnd   time.Time       `json:"periodEnd"`
+	Tenants     []TenantAccount `json:"tenants"`
+	Wallets     []SponsorWallet `json:"wallets"`
+}
+
+// TransactionSponsoredData is the data of EventTransactionSponsored
+type TransactionSponsoredData struct {
+	Sponsorship
+}
+
+type tenantSpend struct {
+	spent        float64
+	reserved     float64
+	transactions uint64
+}
+
+type walletSpend struct {
+	fees         float64
+	cost         float64
+	transactions uint64
+}
+
+// SponsorLedger accounts for the fees sponsor wallets pay, against each
+// tenant's budget for the current period
+type SponsorLedger struct {
+	config   SponsorConfig
+	currency string
+	clock    core.Clock
+
+	mu          sync.Mutex
+	periodStart time.Time
+	tenants     map[string]*tenantSpend
+	wallets     map[string]*walletSpend // By chain
+}
+
+func NewSponsorLedger(config SponsorConfig, currency string, clock core.Clock) *SponsorLedger {
+	if config.Period <= 0 {
+		config.Period = defaultSponsorPeriod
+	}
+	if clock == nil {
+		clock = core.SystemClock
+	}
+	return &SponsorLedger{
+		config:   config,
+		currency: currency,
+		clock:    clock,
+		tenants:  make(map[string]*tenantSpend),
+		wallets:  make(map[string]*walletSpend),
+	}
+}
+
+// Wallet returns the sponsor wallet of a chain
+func (l *SponsorLedger) Wallet(chainID string) (string, bool) {
+	address, exists := l.config.Wallets[chainID]
+	return address, exists && address != ""
+}
+
+// Budget returns a tenant's budget per period
+func (l *SponsorLedger) Budget(tenant string) float64 {
+	if budget, exists := l.config.Budgets[tenant]; exists {
+		return budget
+	}
+	return l.config.DefaultBudget
+}
+
+// roll starts a new period once the current one has passed, clearing the
+// spending. The caller holds l.mu.
+func (l *SponsorLedger) roll(now time.Time) {
+	start := now.Truncate(l.config.Period)
+	if start.Equal(l.periodStart) {
+		return
+	}
+	l.periodStart = start
+	for tenant, spend := range l.tenants {
+		if spend.reserved == 0 {
+			delete(l.tenants, tenant)
+			continue
+		}
+		spend.spent, spend.transactions = 0, 0
+	}
+	l.wallets = make(map[string]*walletSpend)
+}
+
+func (l *SponsorLedger) tenant(tenant string) *tenantSpend {
+	spend, exists := l.tenants[tenant]
+	if !exists {
+		spend = &tenantSpend{}
+		l.tenants[tenant] = spend
+	}
+	return spend
+}
+
+// reserve holds a sponsorship's total against its tenant's budget while the
+// transaction is routed
+func (l *SponsorLedger) reserve(s *Sponsorship) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.roll(l.clock.Now())
+
+	spend := l.tenant(s.Tenant)
+	budget := l.Budget(s.Tenant)
+	if remaining := budget - spend.spent - spend.reserved; s.Total > remaining {
+		return fmt.Errorf("%w: tenant %s has %g %s left of %g this period, transaction estimated at %g %s",
+			ErrSponsorBudgetExceeded, s.Tenant, remaining, l.currency, budget, s.Total, l.currency)
+	}
+	spend.reserved += s.Total
+	return nil
+}
+
+// settle charges a reserved sponsorship once its transaction was routed,
+// or releases it
+func (l *SponsorLedger) settle(s *Sponsorship, charged bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.roll(l.clock.Now())
+
+	spend := l.tenant(s.Tenant)
+	spend.reserved -= s.Total
+	if spend.reserved < 0 {
+		spend.reserved = 0
+	}
+	if charged {
+		l.charge(s)
+	}
+}
+
+// charge adds a sponsorship's fees to the spending. The caller holds l.mu.
+func (l *SponsorLedger) charge(s *Sponsorship) {
+	spend := l.tenant(s.Tenant)
+	spend.spent += s.Total
+	spend.transactions++
+	for _, hop := range s.Hops {
+		wallet, exists := l.wallets[hop.ChainID]
+		if !exists {
+			wallet = &walletSpend{}
+			l.wallets[hop.ChainID] = wallet
+		}
+		wallet.fees += hop.Fee
+		wallet.cost += hop.Cost
+		wallet.transactions++
+	}
+}
+
+// restore charges the sponsorships logged in the current period, so that
+// budgets are not spent again after a restart
+func (l *SponsorLedger) restore(events []Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.roll(l.clock.Now())
+
+	for _, event := range events {
+		if event.Type != EventTransactionSponsored || event.Time.Before(l.periodStart) {
+			continue
+		}
+		var data TransactionSponsoredData
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			return fmt.Errorf("event %d: invalid %s data: %w", event.Seq, event.Type, err)
+		}
+		l.charge(&data.Sponsorship)
+	}
+	return nil
+}
+
+// Report returns the spending of tenants with a budget or spending, and of
+// every sponsor wallet, in the current period
+func (l *SponsorLedger) Report() SponsorReport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.roll(l.clock.Now())
+
+	report := SponsorReport{
+		Currency:    l.currency,
+		PeriodStart: l.periodStart,
+		PeriodEnd:   l.periodStart.Add(l.config.Period),
+		Tenants:     make([]TenantAccount, 0, len(l.config.Budgets)),
+		Wallets:     make([]SponsorWallet, 0, len(l.config.Wallets)),
+	}
+	tenants := make(map[string]bool, len(l.config.Budgets)+len(l.tenants))
+	for tenant := range l.config.Budgets {
+		tenants[tenant] = true
+	}
+	for tenant := range l.tenants {
+		tenants[tenant] = true
+	}
+	for tenant := range tenants {
+		account := TenantAccount{Tenant: tenant, Budget: l.Budget(tenant)}
+		if spend, exists := l.tenants[tenant]; exists {
+			account.Spent, account.Reserved, account.Transactions = spend.spent, spend.reserved, spend.transactions
+		}
+		account.Remaining = account.Budget - account.Spent - account.Reserved
+		report.Tenants = append(report.Tenants, account)
+	}
+	sort.Slice(report.Tenants, func(i, j int) bool { return report.Tenants[i].Tenant < report.Tenants[j].Tenant })
+
+	for chainID, address := range l.config.Wallets {
+		wallet := SponsorWallet{ChainID: chainID, Address: address}
+		if spend, exists := l.wallets[chainID]; exists {
+			wallet.Fees, wallet.Cost, wallet.Transactions = spend.fees, spend.cost, spend.transactions
+		}
+		report.Wallets = append(report.Wallets, wallet)
+	}
+	sort.Slice(report.Wallets, func(i, j int) bool { return report.Wallets[i].ChainID < report.Wallets[j].ChainID })
+	return report
+}
+
+// sponsorFees estimates the fees sponsor wallets would pay for a
+// transaction from a tenant with a budget, on its source chain and its
+// destination, and reserves them against the budget. A transaction over
+// its tenant's remaining budget fails with ErrSponsorBudgetExceeded.
+// Transactions without a tenant, or crossing no chain with a sponsor
+// wallet, pay their own fees.
+func (m *AgglomeratorModule) sponsorFees(tx *Transaction) error {
+	tx.Sponsorship = nil
+	ledger := m.GetSponsors()
+	tenant := tx.Metadata[policyTenantKey]
+	if ledger == nil || tenant == "" || ledger.Budget(tenant) <= 0 {
+		return nil
+	}
+	assets := m.GetAssets()
+	if assets == nil {
+		return fmt.Errorf("%w: no asset registry", ErrSponsorBudgetExceeded)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), priceFeedTimeout)
+	defer cancel()
+	sponsorship := &Sponsorship{Tenant: tenant, Currency: assets.Currency()}
+	hops := []string{tx.FromChain}
+	if tx.ToChain != tx.FromChain {
+		hops = append(hops, tx.ToChain)
+	}
+	for _, chainID := range hops {
+		address, exists := ledger.Wallet(chainID)
+		if !exists {
+			continue
+		}
+		// Costs are in the registry's currency, which is priced at 1
+		fee := m.estimateHop(ctx, chainID, 1)
+		sponsorship.Hops = append(sponsorship.Hops, SponsoredHop{
+			ChainID: chainID,
+			Sponsor: address,
+			Fee:     fee.Fee,
+			Asset:   fee.Asset,
+			Cost:    fee.Cost,
+			Error:   fee.Error,
+		})
+		sponsorship.Total += fee.Cost
+	}
+	if len(sponsorship.Hops) == 0 {
+		return nil
+	}
+
+	if err := ledger.reserve(sponsorship); err != nil {
+		return err
+	}
+	tx.Sponsorship = sponsorship
+	if tx.Metadata == nil {
+		tx.Metadata = make(map[string]string)
+	}
+	tx.Metadata[sponsorCostMetadataKey] = strconv.FormatFloat(sponsorship.Total, 'f', -1, 64)
+	return nil
+}
+
+// settleSponsorship charges a routed transaction's sponsorship to its
+// tenant and logs it, or releases it when routing failed
+func (m *AgglomeratorModule) settleSponsorship(tx *Transaction, processErr error) {
+	ledger := m.GetSponsors()
+	if ledger == nil || tx.Sponsorship == nil {
+		return
+	}
+	ledger.settle(tx.Sponsorship, processErr == nil)
+	if processErr == nil {
+		m.agglomerator.EventLog().record(EventTransactionSponsored, "", tx.ID, TransactionSponsoredData{*tx.Sponsorship})
+	}
+}
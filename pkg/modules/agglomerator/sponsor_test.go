@@ -0,0 +1,89 @@
+package agglomerator
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+// newSponsorModule is newFeeModule with sponsor wallets on the source and
+// cheap chains, and a daily budget of 5 USD for tenant acme
+func newSponsorModule(t *testing.T, clock core.Clock) *AgglomeratorModule {
+	m := newFeeModule(t)
+	m.agglomerator.clock = clock
+	log, err := NewEventLog(nil)
+	require.NoError(t, err)
+	m.agglomerator.SetEventLog(log)
+	m.sponsors = NewSponsorLedger(SponsorConfig{
+		Wallets: map[string]string{"source": "0xsponsor", "cheap": "SponsorSo1"},
+		Budgets: map[string]float64{"acme": 5},
+		Period:  24 * time.Hour,
+	}, "USD", clock)
+	return m
+}
+
+func tenantTx(tenant, to string) *Transaction {
+	tx := budgetedTx(to, FeeBudget{})
+	tx.FeeBudget = nil
+	tx.Metadata = map[string]string{"tenant": tenant}
+	return tx
+}
+
+func TestSponsorFees(t *testing.T) {
+	clock := core.NewFakeClock(time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+	m := newSponsorModule(t, clock)
+
+	tx := tenantTx("acme", "cheap")
+	require.NoError(t, m.sponsorFees(tx))
+	require.NotNil(t, tx.Sponsorship)
+	assert.Equal(t, []SponsoredHop{
+		{ChainID: "source", Sponsor: "0xsponsor", Fee: 0.001, Asset: "ETH", Cost: 2},
+		{ChainID: "cheap", Sponsor: "SponsorSo1", Fee: 2, Asset: "SOL", Cost: 2},
+	}, tx.Sponsorship.Hops)
+	assert.Equal(t, "4", tx.Metadata["sponsorCost"])
+	assert.Equal(t, "SponsorSo1", tx.Sponsorship.Sponsor("cheap"))
+	assert.Empty(t, tx.Sponsorship.Sponsor("expensive"))
+
+	// The reservation holds the budget until the transaction is settled
+	assert.ErrorIs(t, m.sponsorFees(tenantTx("acme", "expensive")), ErrSponsorBudgetExceeded)
+	m.settleSponsorship(tx, errors.New("routing failed"))
+	expensive := tenantTx("acme", "expensive")
+	require.NoError(t, m.sponsorFees(expensive), "released budget is spent again")
+	require.Len(t, expensive.Sponsorship.Hops, 1, "only chains with a sponsor wallet are paid for")
+	m.settleSponsorship(expensive, nil)
+
+	for _, tx := range []*Transaction{tenantTx("", "cheap"), tenantTx("other", "cheap")} {
+		require.NoError(t, m.sponsorFees(tx))
+		assert.Nil(t, tx.Sponsorship, "tenants without a budget pay their own fees")
+	}
+
+	report := m.GetSponsors().Report()
+	assert.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), report.PeriodStart)
+	assert.Equal(t, []TenantAccount{{Tenant: "acme", Budget: 5, Spent: 2, Remaining: 3, Transactions: 1}}, report.Tenants)
+	assert.Equal(t, []SponsorWallet{
+		{ChainID: "cheap", Address: "SponsorSo1"},
+		{ChainID: "source", Address: "0xsponsor", Fees: 0.001, Cost: 2, Transactions: 1},
+	}, report.Wallets)
+	assert.ErrorIs(t, m.sponsorFees(tenantTx("acme", "cheap")), ErrSponsorBudgetExceeded)
+
+	// Spending is restored from the event log within the period
+	events, err := m.agglomerator.EventLog().Events(0, 0)
+	require.NoError(t, err)
+	for i := range events {
+		events[i].Time = clock.Now() // The log stamps events by the system clock
+	}
+	restored := NewSponsorLedger(m.GetSponsors().config, "USD", clock)
+	require.NoError(t, restored.restore(events))
+	assert.Equal(t, report.Tenants, restored.Report().Tenants)
+
+	clock.Advance(12 * time.Hour)
+	assert.Zero(t, m.GetSponsors().Report().Tenants[0].Spent, "budgets are spent per period")
+	restored = NewSponsorLedger(m.GetSponsors().config, "USD", clock)
+	require.NoError(t, restored.restore(events))
+	assert.Zero(t, restored.Report().Tenants[0].Spent, "spending from past periods is not restored")
+	require.NoError(t, m.sponsorFees(tenantTx("acme", "cheap")))
+}
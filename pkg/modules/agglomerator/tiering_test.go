@@ -0,0 +1,268 @@
+package agglomerator
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+// memoryColdStore is a vectors.ColdStore held in a map
+type memoryColdStore struct {
+	mu      sync.Mutex
+	records map[string]vectors.ColdRecord
+	failPut bool
+	reading chan struct{} // When set, Get sends on it once entered, then waits to receive
+}
+
+func newMemoryColdStore() *memoryColdStore {
+	return &memoryColdStore{records: make(map[string]vectors.ColdRecord)}
+}
+
+func (s *memoryColdStore) Put(record vectors.ColdRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failPut {
+		return errors.New("disk full")
+	}
+	s.records[record.ID] = record
+	return nil
+}
+
+func (s *memoryColdStore) Get(id string) (vectors.ColdRecord, bool, error) {
+	if s.reading != nil {
+		s.reading <- struct{}{}
+		<-s.reading
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, exists := s.records[id]
+	return record, exists, nil
+}
+
+func (s *memoryColdStore) Delete(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, exists := s.records[id]
+	delete(s.records, id)
+	return exists, nil
+}
+
+func (s *memoryColdStore) Scan(fn func(vectors.ColdRecord) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, record := range s.records {
+		if !fn(record) {
+			break
+		}
+	}
+	return nil
+}
+
+func tierRecord(id string) vectors.DatabaseRecord {
+	return vectors.DatabaseRecord{
+		ID:       id,
+		Metadata: map[string]interface{}{"priority": 3},
+		Vector:   vectors.InfiniteVector{Generator: func(dim int) float64 { return float64(dim) }},
+	}
+}
+
+func TestTieringDemotesLeastRecentlyUsed(t *testing.T) {
+	index := vectors.NewInfiniteVectorIndex()
+	store := newMemoryColdStore()
+	index.EnableTiering(store, vectors.TierConfig{MemoryBudget: 1000, Dimensions: 10})
+
+	for _, id := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, index.Insert(tierRecord(id)))
+	}
+	stats, ok := index.TierStats()
+	require.True(t, ok)
+	assert.Equal(t, 2, stats.HotRecords)
+	assert.Equal(t, 2, stats.ColdRecords)
+	assert.LessOrEqual(t, stats.HotBytes, stats.MemoryBudget)
+	assert.Contains(t, store.records, "a", "the oldest records are demoted first")
+	assert.Contains(t, store.records, "b")
+	assert.Equal(t, 4, index.Len())
+	assert.Len(t, index.Records(), 4, "cold records are still listed")
+	assert.Len(t, index.InsertedBefore(time.Now().Add(time.Minute)), 4)
+
+	record, exists := index.Get("a")
+	require.True(t, exists)
+	assert.Equal(t, 3, record.Metadata["priority"])
+	assert.Equal(t, 9.0, record.Vector.GetElement(9))
+	assert.Equal(t, 10.0, record.Vector.GetElement(10), "elements past those stored come from the generator")
+	assert.NotContains(t, store.records, "a", "reads promote cold records")
+	assert.Contains(t, store.records, "c", "promotion demotes the least recently used")
+
+	stats, _ = index.TierStats()
+	assert.Equal(t, uint64(1), stats.Promotions)
+	assert.Equal(t, uint64(3), stats.Demotions)
+
+	assert.True(t, index.Delete("b"))
+	assert.NotContains(t, store.records, "b")
+	assert.Equal(t, 3, index.Len())
+	assert.False(t, index.Delete("b"))
+}
+
+func TestTieringKeepsRecordHotWhenStoreFails(t *testing.T) {
+	index := vectors.NewInfiniteVectorIndex()
+	store := newMemoryColdStore()
+	store.failPut = true
+	index.EnableTiering(store, vectors.TierConfig{MemoryBudget: 1, Dimensions: 10})
+
+	require.NoError(t, index.Insert(tierRecord("a")))
+	stats, _ := index.TierStats()
+	assert.Equal(t, 1, stats.HotRecords)
+	assert.Equal(t, uint64(1), stats.Errors)
+	_, exists := index.Get("a")
+	assert.True(t, exists)
+}
+
+func TestTieringPinsChains(t *testing.T) {
+	agg := NewAgglomerator(AgglomeratorConfig{})
+	chain := NewChain("eth", "http://localhost:8545", ProtocolEthereum)
+	chain.StateVector = vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}
+	require.NoError(t, agg.RegisterChain(chain))
+
+	store := newMemoryColdStore()
+	agg.EnableTiering(store, 1)
+	for _, id := range []string{"tx-1", "tx-2", "tx-3"} {
+		tx := &Transaction{ID: id, FromChain: "eth", ToChain: "eth", StateVector: vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}}
+		_, err := agg.recordTransaction(tx)
+		require.NoError(t, err, "chains stay hot, so routing still finds them")
+	}
+
+	stats, ok := agg.TierStats()
+	require.True(t, ok)
+	assert.Equal(t, 1, stats.PinnedRecords)
+	assert.Equal(t, 3, stats.ColdRecords)
+	assert.NotContains(t, store.records, "eth")
+	assert.Equal(t, 4, agg.vectorIndex.Len())
+}
+
+func TestTieringQueriesColdRecords(t *testing.T) {
+	index := vectors.NewInfiniteVectorIndex()
+	store := newMemoryColdStore()
+	index.EnableTiering(store, vectors.TierConfig{MemoryBudget: 1000, Dimensions: 10})
+	query := vectors.InfiniteVector{Generator: func(dim int) float64 { return float64(dim) }}
+
+	require.NoError(t, index.Insert(tierRecord("a")))
+	// Elements materialized while hot are kept when the record is demoted
+	require.Len(t, index.AdvancedQuery(0.99, query, 20), 1)
+	for _, id := range []string{"b", "c", "d"} {
+		require.NoError(t, index.Insert(tierRecord(id)))
+	}
+	require.Contains(t, store.records, "a")
+	require.Contains(t, store.records, "b")
+	assert.Len(t, store.records["a"].Elements, 20)
+	assert.Len(t, store.records["b"].Elements, 10)
+
+	ids := func(records []vectors.DatabaseRecord) []string {
+		ids := make([]string, len(records))
+		for i := range records {
+			ids[i] = records[i].ID
+		}
+		return ids
+	}
+	assert.ElementsMatch(t, []string{"a", "b", "c", "d"}, ids(index.AdvancedQuery(0.99, query, 10)))
+	assert.ElementsMatch(t, []string{"a", "b", "c", "d"}, ids(index.AdvancedQuery(0.99, query, 20)),
+		"b's elements past those stored come from its generator")
+	assert.ElementsMatch(t, []string{"a", "b", "c", "d"}, ids(index.QueryByDimension(func(vector vectors.InfiniteVector) bool {
+		return vector.GetElement(15) > 0
+	}, 10)))
+	assert.Len(t, index.QueryByDimension(func(vectors.InfiniteVector) bool { return true }, 3), 3)
+
+	stats, _ := index.TierStats()
+	assert.Zero(t, stats.Promotions, "queries read cold records without promoting them")
+}
+
+func TestTieringKeepsOversizedRecordHotOnRead(t *testing.T) {
+	index := vectors.NewInfiniteVectorIndex()
+	store := newMemoryColdStore()
+	index.EnableTiering(store, vectors.TierConfig{MemoryBudget: 500, Dimensions: 10})
+
+	big := tierRecord("big")
+	big.Metadata["blob"] = strings.Repeat("x", 1000)
+	require.NoError(t, index.Insert(tierRecord("small")))
+	require.NoError(t, index.Insert(big))
+	assert.Contains(t, store.records, "small")
+	assert.Contains(t, store.records, "big")
+
+	record, exists := index.Get("big")
+	require.True(t, exists)
+	assert.Len(t, record.Metadata["blob"], 1000)
+	assert.NotContains(t, store.records, "big", "a record read stays hot even when larger than the budget")
+	stats, _ := index.TierStats()
+	assert.Equal(t, 1, stats.HotRecords)
+	assert.Greater(t, stats.HotBytes, stats.MemoryBudget)
+
+	// Reading another record demotes it
+	_, exists = index.Get("small")
+	require.True(t, exists)
+	assert.Contains(t, store.records, "big")
+	assert.NotContains(t, store.records, "small")
+}
+
+func TestTieringReadsColdRecordsWithoutLocking(t *testing.T) {
+	index := vectors.NewInfiniteVectorIndex()
+	store := newMemoryColdStore()
+	index.EnableTiering(store, vectors.TierConfig{MemoryBudget: 1000, Dimensions: 10})
+	for _, id := range []string{"a", "b", "c"} {
+		require.NoError(t, index.Insert(tierRecord(id)))
+	}
+	require.Contains(t, store.records, "a")
+
+	store.reading = make(chan struct{})
+	read := make(chan *vectors.DatabaseRecord)
+	go func() {
+		record, _ := index.Get("a")
+		read <- &record
+	}()
+	<-store.reading
+
+	// The index answers while the cold read is stuck on the disk
+	listed := make(chan int)
+	go func() { listed <- index.Len() }()
+	select {
+	case n := <-listed:
+		assert.Equal(t, 3, n)
+	case <-time.After(5 * time.Second):
+		t.Fatal("a cold read blocked the index")
+	}
+
+	store.reading <- struct{}{}
+	record := <-read
+	assert.Equal(t, "a", record.ID)
+	assert.Equal(t, 25.0, record.Vector.GetElement(25))
+	stats, _ := index.TierStats()
+	assert.Equal(t, uint64(1), stats.Promotions)
+}
+
+func TestTieringSkipsStaleColdReads(t *testing.T) {
+	index := vectors.NewInfiniteVectorIndex()
+	store := newMemoryColdStore()
+	index.EnableTiering(store, vectors.TierConfig{MemoryBudget: 1000, Dimensions: 10})
+	for _, id := range []string{"a", "b", "c"} {
+		require.NoError(t, index.Insert(tierRecord(id)))
+	}
+	require.Contains(t, store.records, "a")
+
+	store.reading = make(chan struct{})
+	found := make(chan bool)
+	go func() {
+		_, exists := index.Get("a")
+		found <- exists
+	}()
+	<-store.reading
+
+	// Deleted while the read is in flight, so it is not brought back
+	require.True(t, index.Delete("a"))
+	store.reading <- struct{}{}
+	assert.False(t, <-found)
+	assert.Equal(t, 2, index.Len())
+}
@@ -0,0 +1,231 @@
+package agglomerator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRouteSamples bounds the recent transactions kept per route
+const maxRouteSamples = 256
+
+// TopologyNode is a chain in the routing graph
+type TopologyNode struct {
+	ID        string   `json:"id"`
+	Protocol  string   `json:"protocol"`
+	Local     bool     `json:"local"`     // Registered on this node
+	PeerKnown bool     `json:"peerKnown"` // Announced by at least one peer
+	Peers     []string `json:"peers,omitempty"`
+}
+
+// TopologyLink is a route between two chains weighted by recent usage
+type TopologyLink struct {
+	Source        string    `json:"source"`
+	Target        string    `json:"target"`
+	Count         int       `json:"count"`
+	Failures      int       `json:"failures"`
+	Weight        float64   `json:"weight"` // Count relative to the busiest route
+	AvgLatencyMs  float64   `json:"avgLatencyMs"`
+	AvgSimilarity float64   `json:"avgSimilarity"`
+	AvgScore      float64   `json:"avgScore"`
+	LastUsed      time.Time `json:"lastUsed"`
+}
+
+// Topology is the routing graph in the nodes/links shape used by D3
+type Topology struct {
+	Nodes []TopologyNode `json:"nodes"`
+	Links []TopologyLink `json:"links"`
+}
+
+type routeKey struct {
+	from, to string
+}
+
+type routeSample struct {
+	at         time.Time
+	latency    time.Duration
+	similarity float64
+	score      float64
+	failed     bool
+}
+
+// RouteUsage records recent transactions per route for the topology view
+type RouteUsage struct {
+	routes map[routeKey][]routeSample
+	mu     sync.Mutex
+}
+
+func NewRouteUsage() *RouteUsage {
+	return &RouteUsage{
+		routes: make(map[routeKey][]routeSample),
+	}
+}
+
+// Record adds a processed transaction to its route
+func (u *RouteUsage) Record(from, to string, metrics RouteMetrics, latency time.Duration, failed bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	key := routeKey{from: from, to: to}
+	samples := append(u.routes[key], routeSample{
+		at:         time.Now(),
+		latency:    latency,
+		similarity: metrics.Similarity,
+		score:      evaluateRoute(metrics),
+		failed:     failed,
+	})
+	if len(samples) > maxRouteSamples {
+		samples = samples[len(samples)-maxRouteSamples:]
+	}
+	u.routes[key] = samples
+}
+
+// Collect drops samples recorded before cutoff, and routes left without any
+func (u *RouteUsage) Collect(cutoff time.Time) int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	removed := 0
+	for key, samples := range u.routes {
+		// Samples are appended in time order
+		keep := sort.Search(len(samples), func(i int) bool {
+			return !samples[i].at.Before(cutoff)
+		})
+		removed += keep
+		if keep == len(samples) {
+			delete(u.routes, key)
+			continue
+		}
+		u.routes[key] = samples[keep:]
+	}
+	return removed
+}
+
+// Links summarises each route's samples, busiest first
+func (u *RouteUsage) Links() []TopologyLink {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	links := make([]TopologyLink, 0, len(u.routes))
+	busiest := 0
+	for key, samples := range u.routes {
+		link := TopologyLink{Source: key.from, Target: key.to, Count: len(samples)}
+
+		var latency time.Duration
+		for _, sample := range samples {
+			latency += sample.latency
+			link.AvgSimilarity += sample.similarity
+			link.AvgScore += sample.score
+			if sample.failed {
+				link.Failures++
+			}
+		}
+		n := float64(len(samples))
+		link.AvgLatencyMs = float64(latency.Microseconds()) / 1000 / n
+		link.AvgSimilarity /= n
+		link.AvgScore /= n
+		link.LastUsed = samples[len(samples)-1].at
+
+		if link.Count > busiest {
+			busiest = link.Count
+		}
+		links = append(links, link)
+	}
+
+	for i := range links {
+		links[i].Weight = float64(links[i].Count) / float64(busiest)
+	}
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].Count != links[j].Count {
+			return links[i].Count > links[j].Count
+		}
+		if links[i].Source != links[j].Source {
+			return links[i].Source < links[j].Source
+		}
+		return links[i].Target < links[j].Target
+	})
+	return links
+}
+
+// buildTopology joins local chains, peer-announced chains and route usage
+// into one graph. Routes to chains no longer known still get a node so the
+// links stay resolvable.
+func buildTopology(local []*Chain, peerChains map[string][]*Chain, links []TopologyLink) Topology {
+	nodes := make(map[string]*TopologyNode)
+	node := func(id, protocol string) *TopologyNode {
+		n, exists := nodes[id]
+		if !exists {
+			n = &TopologyNode{ID: id}
+			nodes[id] = n
+		}
+		if n.Protocol == "" {
+			n.Protocol = protocol
+		}
+		return n
+	}
+
+	for _, chain := range local {
+		node(chain.ID, chain.Protocol).Local = true
+	}
+	for peerID, chains := range peerChains {
+		for _, chain := range chains {
+			n := node(chain.ID, chain.Protocol)
+			n.PeerKnown = true
+			n.Peers = append(n.Peers, peerID)
+		}
+	}
+	for _, link := range links {
+		node(link.Source, "")
+		node(link.Target, "")
+	}
+
+	topology := Topology{
+		Nodes: make([]TopologyNode, 0, len(nodes)),
+		Links: links,
+	}
+	for _, n := range nodes {
+		if n.Protocol == "" {
+			n.Protocol = determineProtocol(n.ID)
+		}
+		sort.Strings(n.Peers)
+		topology.Nodes = append(topology.Nodes, *n)
+	}
+	sort.Slice(topology.Nodes, func(i, j int) bool {
+		return topology.Nodes[i].ID < topology.Nodes[j].ID
+	})
+	return topology
+}
+
+// DOT renders the topology as a Graphviz digraph. Peer-only chains are
+// dashed and edge width follows route weight.
+func (t Topology) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph topology {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=ellipse];\n")
+
+	for _, n := range t.Nodes {
+		attrs := []string{fmt.Sprintf("label=%q", n.ID+"\n"+n.Protocol)}
+		if !n.Local {
+			attrs = append(attrs, "style=dashed")
+		}
+		if n.PeerKnown {
+			attrs = append(attrs, `color="blue"`)
+		}
+		fmt.Fprintf(&b, "  %q [%s];\n", n.ID, strings.Join(attrs, ", "))
+	}
+
+	for _, l := range t.Links {
+		label := fmt.Sprintf("%d tx, %.1fms", l.Count, l.AvgLatencyMs)
+		if l.Failures > 0 {
+			label += fmt.Sprintf(", %d failed", l.Failures)
+		}
+		fmt.Fprintf(&b, "  %q -> %q [label=%q, penwidth=%.2f];\n",
+			l.Source, l.Target, label, 1+4*l.Weight)
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -0,0 +1,58 @@
+package agglomerator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteUsageLinks(t *testing.T) {
+	usage := NewRouteUsage()
+	usage.Record("eth", "sol", RouteMetrics{Similarity: 0.8}, 10*time.Millisecond, false)
+	usage.Record("eth", "sol", RouteMetrics{Similarity: 0.6}, 30*time.Millisecond, true)
+	usage.Record("sol", "dot", RouteMetrics{Similarity: 1}, time.Millisecond, false)
+
+	links := usage.Links()
+	require.Len(t, links, 2)
+
+	busiest := links[0]
+	assert.Equal(t, "eth", busiest.Source)
+	assert.Equal(t, "sol", busiest.Target)
+	assert.Equal(t, 2, busiest.Count)
+	assert.Equal(t, 1, busiest.Failures)
+	assert.Equal(t, 1.0, busiest.Weight)
+	assert.InDelta(t, 20, busiest.AvgLatencyMs, 0.001)
+	assert.InDelta(t, 0.7, busiest.AvgSimilarity, 0.001)
+	assert.Equal(t, 0.5, links[1].Weight)
+
+	assert.Zero(t, usage.Collect(time.Now().Add(-time.Hour)))
+	assert.Equal(t, 3, usage.Collect(time.Now().Add(time.Second)))
+	assert.Empty(t, usage.Links())
+}
+
+func TestBuildTopology(t *testing.T) {
+	local := []*Chain{{ID: "eth", Protocol: ProtocolEthereum}}
+	peers := map[string][]*Chain{
+		"peer-b": {{ID: "sol", Protocol: ProtocolSolana}, {ID: "eth", Protocol: ProtocolEthereum}},
+		"peer-a": {{ID: "sol", Protocol: ProtocolSolana}},
+	}
+	links := []TopologyLink{{Source: "eth", Target: "gone", Count: 1, Weight: 1}}
+
+	topology := buildTopology(local, peers, links)
+	require.Len(t, topology.Nodes, 3)
+
+	eth, gone, sol := topology.Nodes[0], topology.Nodes[1], topology.Nodes[2]
+	assert.True(t, eth.Local)
+	assert.True(t, eth.PeerKnown)
+	assert.Equal(t, []string{"peer-b"}, eth.Peers)
+	assert.False(t, sol.Local)
+	assert.Equal(t, []string{"peer-a", "peer-b"}, sol.Peers)
+	assert.Equal(t, "gone", gone.ID)
+	assert.False(t, gone.Local || gone.PeerKnown)
+
+	dot := topology.DOT()
+	assert.Contains(t, dot, `"eth" -> "gone"`)
+	assert.Contains(t, dot, `"sol" [label="sol\nsol", style=dashed, color="blue"]`)
+}
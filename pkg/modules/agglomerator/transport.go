@@ -0,0 +1,81 @@
+package agglomerator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Conn is an established, secured channel to a peer
+type Conn interface {
+	io.ReadWriteCloser
+	// RemotePeer identifies the other end of the channel
+	RemotePeer() string
+}
+
+// Listener accepts inbound peer channels
+type Listener interface {
+	Accept() (Conn, error)
+	Addr() string
+	Close() error
+}
+
+// Transport establishes secure channels between P2P nodes
+type Transport interface {
+	Name() string
+	// Listen accepts inbound channels on a host:port address
+	Listen(address string) (Listener, error)
+	// Dial opens a secured channel to a peer address
+	Dial(ctx context.Context, address string) (Conn, error)
+	Close() error
+}
+
+// TransportConfig selects and configures a transport
+type TransportConfig struct {
+	Type     string // Registered transport name, e.g. "tcp" or "libp2p"
+	CertFile string // TLS certificate; an ephemeral one is generated when empty
+	KeyFile  string // TLS private key
+	CAFile   string // CA bundle used to verify peers; enables mutual TLS
+}
+
+// TransportFactory builds a transport from its configuration
+type TransportFactory func(config TransportConfig) (Transport, error)
+
+var (
+	transportsMu sync.RWMutex
+	transports   = make(map[string]TransportFactory)
+)
+
+// RegisterTransport makes a transport selectable by name
+func RegisterTransport(name string, factory TransportFactory) {
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	transports[name] = factory
+}
+
+// AvailableTransports lists the registered transport names
+func AvailableTransports() []string {
+	transportsMu.RLock()
+	defer transportsMu.RUnlock()
+
+	names := make([]string, 0, len(transports))
+	for name := range transports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewTransport builds the transport selected by config.Type
+func NewTransport(config TransportConfig) (Transport, error) {
+	transportsMu.RLock()
+	factory, exists := transports[config.Type]
+	transportsMu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("transport %q not available (have %v)", config.Type, AvailableTransports())
+	}
+	return factory(config)
+}
@@ -0,0 +1,192 @@
+//go:build libp2p
+
+// The libp2p transport is compiled in with -tags libp2p, which keeps
+// go-libp2p and its dependencies out of default builds.
+
+package agglomerator
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+const hydapProtocolID = protocol.ID("/hydap/p2p/1.0.0")
+
+func init() {
+	RegisterTransport("libp2p", newLibp2pTransport)
+}
+
+// libp2pTransport carries P2P traffic as streams on a libp2p host. Channels
+// are secured by libp2p's default security transports (Noise, TLS).
+type libp2pTransport struct {
+	mu   sync.Mutex
+	host host.Host
+}
+
+func newLibp2pTransport(config TransportConfig) (Transport, error) {
+	return &libp2pTransport{}, nil
+}
+
+func (t *libp2pTransport) Name() string {
+	return "libp2p"
+}
+
+func (t *libp2pTransport) Listen(address string) (Listener, error) {
+	listenAddrs, err := toMultiaddrs(address)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.host != nil {
+		return nil, fmt.Errorf("libp2p transport already started")
+	}
+	h, err := libp2p.New(libp2p.ListenAddrStrings(listenAddrs...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to start libp2p host: %w", err)
+	}
+	t.host = h
+
+	l := &libp2pListener{
+		host:   h,
+		conns:  make(chan Conn, 16),
+		closed: make(chan struct{}),
+	}
+	h.SetStreamHandler(hydapProtocolID, func(s network.Stream) {
+		select {
+		case l.conns <- &libp2pConn{Stream: s}:
+		case <-l.closed:
+			s.Reset()
+		}
+	})
+	return l, nil
+}
+
+// Dial opens a stream to a peer given as a full multiaddr including /p2p/<peer-id>
+func (t *libp2pTransport) Dial(ctx context.Context, address string) (Conn, error) {
+	h, err := t.ensureHost()
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := peer.AddrInfoFromString(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid libp2p peer address %s: %w", address, err)
+	}
+	if err := h.Connect(ctx, *info); err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", info.ID, err)
+	}
+
+	s, err := h.NewStream(ctx, info.ID, hydapProtocolID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stream to %s: %w", info.ID, err)
+	}
+	return &libp2pConn{Stream: s}, nil
+}
+
+func (t *libp2pTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.host == nil {
+		return nil
+	}
+	err := t.host.Close()
+	t.host = nil
+	return err
+}
+
+// ensureHost starts an outbound-only host for nodes that dial without listening
+func (t *libp2pTransport) ensureHost() (host.Host, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.host != nil {
+		return t.host, nil
+	}
+	h, err := libp2p.New(libp2p.NoListenAddrs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start libp2p host: %w", err)
+	}
+	t.host = h
+	return h, nil
+}
+
+type libp2pListener struct {
+	host   host.Host
+	conns  chan Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (l *libp2pListener) Accept() (Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *libp2pListener) Addr() string {
+	addrs := l.host.Addrs()
+	if len(addrs) == 0 {
+		return "/p2p/" + l.host.ID().String()
+	}
+	return addrs[0].String() + "/p2p/" + l.host.ID().String()
+}
+
+func (l *libp2pListener) Close() error {
+	l.once.Do(func() {
+		close(l.closed)
+		l.host.RemoveStreamHandler(hydapProtocolID)
+	})
+	return nil
+}
+
+type libp2pConn struct {
+	network.Stream
+}
+
+func (c *libp2pConn) RemotePeer() string {
+	return c.Stream.Conn().RemotePeer().String()
+}
+
+// toMultiaddrs converts a host:port listen address into TCP multiaddrs. An
+// empty or unspecified host listens on both IPv4 and IPv6.
+func toMultiaddrs(address string) ([]string, error) {
+	if strings.HasPrefix(address, "/") {
+		return []string{address}, nil
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen address %s: %w", address, err)
+	}
+
+	ip := net.ParseIP(host)
+	switch {
+	case host == "" || (ip != nil && ip.IsUnspecified()):
+		return []string{
+			fmt.Sprintf("/ip4/0.0.0.0/tcp/%s", port),
+			fmt.Sprintf("/ip6/::/tcp/%s", port),
+		}, nil
+	case ip == nil:
+		return []string{fmt.Sprintf("/dns/%s/tcp/%s", host, port)}, nil
+	case ip.To4() != nil:
+		return []string{fmt.Sprintf("/ip4/%s/tcp/%s", host, port)}, nil
+	default:
+		return []string{fmt.Sprintf("/ip6/%s/tcp/%s", ip, port)}, nil
+	}
+}
@@ -0,0 +1,72 @@
+//go:build libp2p
+
+package agglomerator
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLibp2pTransportRoundTrip(t *testing.T) {
+	assert.Contains(t, AvailableTransports(), "libp2p")
+
+	listening, err := NewTransport(TransportConfig{Type: "libp2p"})
+	require.NoError(t, err)
+	defer listening.Close()
+	listener, err := listening.Listen("127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			close(received)
+			return
+		}
+		defer conn.Close()
+
+		buf := make([]byte, 5)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			close(received)
+			return
+		}
+		received <- buf
+	}()
+
+	// The dialing side starts an outbound-only host
+	dialing, err := NewTransport(TransportConfig{Type: "libp2p"})
+	require.NoError(t, err)
+	defer dialing.Close()
+	conn, err := dialing.Dial(context.Background(), listener.Addr())
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Contains(t, listener.Addr(), "/p2p/"+conn.RemotePeer())
+
+	_, err = conn.Write([]byte("hydap"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hydap"), <-received)
+
+	_, err = dialing.Dial(context.Background(), "127.0.0.1:9000")
+	assert.ErrorContains(t, err, "invalid libp2p peer address")
+}
+
+func TestToMultiaddrs(t *testing.T) {
+	for address, want := range map[string][]string{
+		":9000":               {"/ip4/0.0.0.0/tcp/9000", "/ip6/::/tcp/9000"},
+		"127.0.0.1:9000":      {"/ip4/127.0.0.1/tcp/9000"},
+		"[::1]:9000":          {"/ip6/::1/tcp/9000"},
+		"node.example:9000":   {"/dns/node.example/tcp/9000"},
+		"/ip4/10.0.0.1/tcp/1": {"/ip4/10.0.0.1/tcp/1"},
+	} {
+		addrs, err := toMultiaddrs(address)
+		require.NoError(t, err)
+		assert.Equal(t, want, addrs, address)
+	}
+	_, err := toMultiaddrs("no-port")
+	assert.Error(t, err)
+}
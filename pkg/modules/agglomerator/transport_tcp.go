@@ -0,0 +1,202 @@
+package agglomerator
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"time"
+)
+
+const dialTimeout = 5 * time.Second
+
+func init() {
+	RegisterTransport("tcp", newTCPTransport)
+}
+
+// tcpTransport carries P2P traffic over TCP secured with TLS 1.3
+type tcpTransport struct {
+	tlsConfig *tls.Config
+}
+
+func newTCPTransport(config TransportConfig) (Transport, error) {
+	tlsConfig, err := buildTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &tcpTransport{tlsConfig: tlsConfig}, nil
+}
+
+func (t *tcpTransport) Name() string {
+	return "tcp"
+}
+
+// Listen listens on a host:port. An empty or unspecified host gets a
+// dual-stack socket accepting both IPv4 and IPv6 connections.
+func (t *tcpTransport) Listen(address string) (Listener, error) {
+	ln, err := tls.Listen("tcp", address, t.tlsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+	return &tcpListener{ln: ln}, nil
+}
+
+// Dial connects to a host:port or a TCP multiaddr
+func (t *tcpTransport) Dial(ctx context.Context, address string) (Conn, error) {
+	address = multiaddrHostPort(address)
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: dialTimeout},
+		Config:    t.tlsConfig,
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial %s: %w", address, err)
+	}
+	return &tcpConn{Conn: conn}, nil
+}
+
+func (t *tcpTransport) Close() error {
+	return nil
+}
+
+type tcpListener struct {
+	ln net.Listener
+}
+
+func (l *tcpListener) Accept() (Conn, error) {
+	conn, err := l.ln.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &tcpConn{Conn: conn}, nil
+}
+
+func (l *tcpListener) Addr() string {
+	return l.ln.Addr().String()
+}
+
+func (l *tcpListener) Close() error {
+	return l.ln.Close()
+}
+
+type tcpConn struct {
+	net.Conn
+}
+
+func (c *tcpConn) RemotePeer() string {
+	return c.Conn.RemoteAddr().String()
+}
+
+// buildTLSConfig loads or generates the node certificate. With a CA bundle,
+// peers must present certificates signed by it (mutual TLS). Without one the
+// channel is encrypted but peer identity is left to the node handshake.
+func buildTLSConfig(config TransportConfig) (*tls.Config, error) {
+	var (
+		cert tls.Certificate
+		err  error
+	)
+	if config.CertFile != "" || config.KeyFile != "" {
+		cert, err = tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS key pair: %w", err)
+		}
+	} else {
+		cert, err = generateSelfSignedCert()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates:       []tls.Certificate{cert},
+		MinVersion:         tls.VersionTLS13,
+		InsecureSkipVerify: true, // Peers are addressed by IP; chains are verified below
+		ClientAuth:         tls.RequireAnyClientCert,
+	}
+
+	if config.CAFile == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(config.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", config.CAFile)
+	}
+
+	tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		return verifyPeerChain(rawCerts, pool)
+	}
+	return tlsConfig, nil
+}
+
+// verifyPeerChain checks a peer's certificate chain against the CA pool,
+// ignoring host names since peers are addressed by IP
+func verifyPeerChain(rawCerts [][]byte, roots *x509.CertPool) error {
+	if len(rawCerts) == 0 {
+		return errors.New("peer presented no certificate")
+	}
+
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return fmt.Errorf("invalid peer certificate: %w", err)
+		}
+		certs[i] = cert
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err
+}
+
+func generateSelfSignedCert() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate TLS key: %w", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "hydap-node"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}, nil
+}
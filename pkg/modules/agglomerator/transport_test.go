@@ -0,0 +1,78 @@
+package agglomerator
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTCPTransportRoundTrip(t *testing.T) {
+	transport, err := NewTransport(TransportConfig{Type: "tcp"})
+	require.NoError(t, err)
+	defer transport.Close()
+
+	listener, err := transport.Listen("127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			close(received)
+			return
+		}
+		defer conn.Close()
+
+		buf := make([]byte, 5)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			close(received)
+			return
+		}
+		received <- buf
+	}()
+
+	conn, err := transport.Dial(context.Background(), listener.Addr())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("hydap"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hydap"), <-received)
+}
+
+func TestNodeStopEndsGoroutines(t *testing.T) {
+	newNode := func() *P2PInfiniteVectorNode {
+		node := NewP2PInfiniteVectorNode("127.0.0.1", 0)
+		transport, err := NewTransport(TransportConfig{Type: "tcp"})
+		require.NoError(t, err)
+		require.NoError(t, node.UseTransport(transport))
+		node.Start()
+		return node
+	}
+	a, b := newNode(), newNode()
+	a.connectToPeer(&PeerInfo{NodeID: b.NodeID, Address: b.listener.Addr()})
+	_, err := a.peerConn(a.transport, b.NodeID)
+	require.NoError(t, err)
+
+	// Stop waits for every loop and connection handler; b's handler for
+	// a's channel is ended by b's stop, not a's close
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, b.Stop(ctx))
+	require.NoError(t, a.Stop(ctx))
+
+	// Messages sent after Stop are dropped rather than blocking
+	for i := 0; i < cap(a.bulkQueue)+1; i++ {
+		a.enqueue(DataTransferMessage{RecipientID: b.NodeID}, PriorityBulk)
+	}
+}
+
+func TestUnknownTransport(t *testing.T) {
+	_, err := NewTransport(TransportConfig{Type: "carrier-pigeon"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,174 @@
+package agglomerator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// TransactionQuery filters the transaction history; every set field must match
+type TransactionQuery struct {
+	Chain     string // Matches either side of the transaction
+	FromChain string
+	ToChain   string
+	Statuses  []string
+	After     time.Time
+	Before    time.Time
+	Metadata  map[string]string // A value of "*" only requires the key
+	Limit     int
+	Offset    int
+}
+
+// ParseTransactionQuery parses the history query syntax: whitespace-separated
+// field:value terms that must all match.
+//
+//	chain:<id>             either side of the transaction
+//	from:<id>  to:<id>     source or destination chain
+//	status:<s>[,<s>...]    any of the listed statuses
+//	after:<t>  before:<t>  RFC 3339 time, or a duration meaning that long ago
+//	meta.<key>:<value>     metadata equality; a value of * matches any value
+//
+// Values containing spaces may be double-quoted, as in meta.note:"two words".
+func ParseTransactionQuery(query string) (TransactionQuery, error) {
+	var q TransactionQuery
+
+	terms, err := splitQueryTerms(query)
+	if err != nil {
+		return q, err
+	}
+
+	for _, term := range terms {
+		field, value, ok := strings.Cut(term, ":")
+		if !ok || value == "" {
+			return q, fmt.Errorf("invalid term %q: expected field:value", term)
+		}
+
+		switch {
+		case field == "chain":
+			q.Chain = value
+		case field == "from":
+			q.FromChain = value
+		case field == "to":
+			q.ToChain = value
+		case field == "status":
+			q.Statuses = append(q.Statuses, strings.Split(value, ",")...)
+		case field == "after":
+			if q.After, err = parseQueryTime(value); err != nil {
+				return q, err
+			}
+		case field == "before":
+			if q.Before, err = parseQueryTime(value); err != nil {
+				return q, err
+			}
+		case strings.HasPrefix(field, "meta."):
+			key := strings.TrimPrefix(field, "meta.")
+			if key == "" {
+				return q, fmt.Errorf("invalid term %q: missing metadata key", term)
+			}
+			if q.Metadata == nil {
+				q.Metadata = make(map[string]string)
+			}
+			q.Metadata[key] = value
+		default:
+			return q, fmt.Errorf("unknown field %q", field)
+		}
+	}
+
+	return q, nil
+}
+
+// splitQueryTerms splits on whitespace outside double quotes and strips the
+// quotes
+func splitQueryTerms(query string) ([]string, error) {
+	var terms []string
+	var term strings.Builder
+	quoted := false
+
+	for _, r := range query {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case unicode.IsSpace(r) && !quoted:
+			if term.Len() > 0 {
+				terms = append(terms, term.String())
+				term.Reset()
+			}
+		default:
+			term.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in query")
+	}
+	if term.Len() > 0 {
+		terms = append(terms, term.String())
+	}
+	return terms, nil
+}
+
+func parseQueryTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if d, err := parseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: use RFC 3339 or a duration such as 24h", value)
+}
+
+// where builds the SQL filter for the query
+func (q TransactionQuery) where() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if q.Chain != "" {
+		conditions = append(conditions, "(from_chain = ? OR to_chain = ?)")
+		args = append(args, q.Chain, q.Chain)
+	}
+	if q.FromChain != "" {
+		conditions = append(conditions, "from_chain = ?")
+		args = append(args, q.FromChain)
+	}
+	if q.ToChain != "" {
+		conditions = append(conditions, "to_chain = ?")
+		args = append(args, q.ToChain)
+	}
+	if len(q.Statuses) > 0 {
+		placeholders := make([]string, len(q.Statuses))
+		for i, status := range q.Statuses {
+			placeholders[i] = "?"
+			args = append(args, status)
+		}
+		conditions = append(conditions, "status IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	if !q.After.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, q.After.UnixNano())
+	}
+	if !q.Before.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, q.Before.UnixNano())
+	}
+
+	keys := make([]string, 0, len(q.Metadata))
+	for key := range q.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		condition := "EXISTS (SELECT 1 FROM transaction_metadata m WHERE m.tx_id = transactions.id AND m.key = ?"
+		args = append(args, key)
+		if value := q.Metadata[key]; value != "*" {
+			condition += " AND m.value = ?"
+			args = append(args, value)
+		}
+		conditions = append(conditions, condition+")")
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
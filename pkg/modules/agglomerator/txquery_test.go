@@ -0,0 +1,73 @@
+package agglomerator
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTransactionQuery(t *testing.T) {
+	q, err := ParseTransactionQuery(`chain:eth-main status:failed,completed after:2024-01-01T00:00:00Z meta.note:"two words" meta.batch:*`)
+	require.NoError(t, err)
+	assert.Equal(t, "eth-main", q.Chain)
+	assert.Equal(t, []string{"failed", "completed"}, q.Statuses)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), q.After)
+	assert.Equal(t, map[string]string{"note": "two words", "batch": "*"}, q.Metadata)
+
+	q, err = ParseTransactionQuery("before:1h")
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(-time.Hour), q.Before, time.Second)
+
+	for _, bad := range []string{"chain", "color:red", `meta.note:"open`, "after:yesterday", "meta.:x"} {
+		_, err := ParseTransactionQuery(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestTransactionStoreQuery(t *testing.T) {
+	store, err := NewTransactionStore(filepath.Join(t.TempDir(), "transactions.db"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	now := time.Now()
+	records := []TransactionRecord{
+		{ID: "tx-1", FromChain: "eth", ToChain: "sol", Status: TxStatusCompleted, CreatedAt: now.Add(-2 * time.Hour), Metadata: map[string]string{"batch": "a"}},
+		{ID: "tx-2", FromChain: "sol", ToChain: "dot", Status: TxStatusFailed, Error: "no route", CreatedAt: now.Add(-time.Hour)},
+		{ID: "tx-3", FromChain: "dot", ToChain: "eth", Status: TxStatusCompleted, CreatedAt: now, Metadata: map[string]string{"batch": "b"}},
+	}
+	for _, record := range records {
+		require.NoError(t, store.Record(record))
+	}
+
+	ids := func(query string) []string {
+		q, err := ParseTransactionQuery(query)
+		require.NoError(t, err)
+		results, err := store.Query(q)
+		require.NoError(t, err)
+
+		var ids []string
+		for _, result := range results {
+			ids = append(ids, result.ID)
+		}
+		return ids
+	}
+
+	assert.Equal(t, []string{"tx-3", "tx-2", "tx-1"}, ids(""))
+	assert.Equal(t, []string{"tx-3", "tx-1"}, ids("chain:eth"))
+	assert.Equal(t, []string{"tx-2"}, ids("status:failed"))
+	assert.Equal(t, []string{"tx-3", "tx-2"}, ids("after:90m"))
+	assert.Equal(t, []string{"tx-3", "tx-1"}, ids("meta.batch:*"))
+	assert.Equal(t, []string{"tx-1"}, ids("meta.batch:a to:sol"))
+
+	q, _ := ParseTransactionQuery("meta.batch:b")
+	results, err := store.Query(q)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, map[string]string{"batch": "b"}, results[0].Metadata)
+
+	assert.Equal(t, 1, store.Collect(now.Add(-90*time.Minute)))
+	assert.Equal(t, []string{"tx-3", "tx-2"}, ids(""))
+}
@@ -0,0 +1,580 @@
+package agglomerator
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	// Transaction statuses recorded in the history
+	TxStatusCompleted = "completed"
+	TxStatusFailed    = "failed"
+	TxStatusHeld      = "held"     // Awaiting anomaly review
+	TxStatusRejected  = "rejected" // Rejected in anomaly review
+	TxStatusDenied    = "denied"   // Denied by the compliance policy
+	TxStatusReverted  = "reverted" // Failed simulation on its destination
+
+	defaultQueryLimit = 100
+	maxQueryLimit     = 1000
+)
+
+// TransactionRecord is a processed transaction as kept in the history
+type TransactionRecord struct {
+	ID        string            `json:"id"`
+	FromChain string            `json:"fromChain"`
+	ToChain   string            `json:"toChain"`
+	Status    string            `json:"status"`
+	Error     string            `json:"error,omitempty"`
+	BlobRef   string            `json:"blobRef,omitempty"`
+	Size      int               `json:"size"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Route     *RouteExplanation `json:"route,omitempty"` // Stored by Record, loaded by Route
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+// TransactionStore persists transaction history in SQLite, with the columns
+// used for filtering indexed
+type TransactionStore struct {
+	db *sql.DB
+}
+
+func NewTransactionStore(dbPath string) (*TransactionStore, error) {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := initTransactionDB(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	return &TransactionStore{db: db}, nil
+}
+
+func initTransactionDB(db *sql.DB) error {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS transactions (
+            id TEXT PRIMARY KEY,
+            from_chain TEXT NOT NULL,
+            to_chain TEXT NOT NULL,
+            status TEXT NOT NULL,
+            error TEXT NOT NULL DEFAULT '',
+            blob_ref TEXT NOT NULL DEFAULT '',
+            size INTEGER NOT NULL DEFAULT 0,
+            created_at INTEGER NOT NULL
+        );
+        CREATE INDEX IF NOT EXISTS idx_transactions_from_chain ON transactions (from_chain, created_at);
+        CREATE INDEX IF NOT EXISTS idx_transactions_to_chain ON transactions (to_chain, created_at);
+        CREATE INDEX IF NOT EXISTS idx_transactions_status ON transactions (status, created_at);
+        CREATE INDEX IF NOT EXISTS idx_transactions_created_at ON transactions (created_at);
+        CREATE TABLE IF NOT EXISTS transaction_metadata (
+            tx_id TEXT NOT NULL REFERENCES transactions (id) ON DELETE CASCADE,
+            key TEXT NOT NULL,
+            value TEXT NOT NULL,
+            PRIMARY KEY (tx_id, key)
+        );
+        CREATE INDEX IF NOT EXISTS idx_transaction_metadata_key ON transaction_metadata (key, value);
+        CREATE TABLE IF NOT EXISTS transaction_routes (
+            tx_id TEXT PRIMARY KEY REFERENCES transactions (id) ON DELETE CASCADE,
+            explanation TEXT NOT NULL
+        );
+        CREATE TABLE IF NOT EXISTS transaction_receipts (
+            tx_id TEXT PRIMARY KEY REFERENCES transactions (id) ON DELETE CASCADE,
+            bundle TEXT NOT NULL
+        );
+        CREATE TABLE IF NOT EXISTS policy_decisions (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            tx_id TEXT NOT NULL,
+            from_chain TEXT NOT NULL,
+            to_chain TEXT NOT NULL,
+            rule TEXT NOT NULL DEFAULT '',
+            action TEXT NOT NULL,
+            dry_run INTEGER NOT NULL,
+            blocked INTEGER NOT NULL,
+            created_at INTEGER NOT NULL
+        );
+        CREATE INDEX IF NOT EXISTS idx_policy_decisions_tx_id ON policy_decisions (tx_id);
+        CREATE INDEX IF NOT EXISTS idx_policy_decisions_created_at ON policy_decisions (created_at);
+        CREATE TABLE IF NOT EXISTS transaction_reroutes (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            tx_id TEXT NOT NULL,
+            failed_chain TEXT NOT NULL,
+            to_chain TEXT NOT NULL DEFAULT '',
+            operator TEXT NOT NULL,
+            reason TEXT NOT NULL,
+            request TEXT NOT NULL,
+            status TEXT NOT NULL,
+            error TEXT NOT NULL DEFAULT '',
+            created_at INTEGER NOT NULL
+        );
+        CREATE INDEX IF NOT EXISTS idx_transaction_reroutes_tx_id ON transaction_reroutes (tx_id);
+        CREATE INDEX IF NOT EXISTS idx_transaction_reroutes_created_at ON transaction_reroutes (created_at);
+        CREATE TABLE IF NOT EXISTS reconciliation_reports (
+            date TEXT PRIMARY KEY,
+            report TEXT NOT NULL,
+            generated_at INTEGER NOT NULL
+        );
+    `)
+	return err
+}
+
+// Record inserts or replaces a transaction and its metadata
+func (s *TransactionStore) Record(record TransactionRecord) error {
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to record transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+        INSERT OR REPLACE INTO transactions (id, from_chain, to_chain, status, error, blob_ref, size, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+    `, record.ID, record.FromChain, record.ToChain, record.Status, record.Error,
+		record.BlobRef, record.Size, record.CreatedAt.UnixNano()); err != nil {
+		return fmt.Errorf("failed to record transaction: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM transaction_metadata WHERE tx_id = ?`, record.ID); err != nil {
+		return fmt.Errorf("failed to record transaction metadata: %w", err)
+	}
+	for key, value := range record.Metadata {
+		if _, err := tx.Exec(`
+            INSERT INTO transaction_metadata (tx_id, key, value) VALUES (?, ?, ?)
+        `, record.ID, key, value); err != nil {
+			return fmt.Errorf("failed to record transaction metadata: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM transaction_routes WHERE tx_id = ?`, record.ID); err != nil {
+		return fmt.Errorf("failed to record transaction route: %w", err)
+	}
+	if record.Route != nil {
+		explanation, err := json.Marshal(record.Route)
+		if err != nil {
+			return fmt.Errorf("failed to encode transaction route: %w", err)
+		}
+		if _, err := tx.Exec(`
+            INSERT INTO transaction_routes (tx_id, explanation) VALUES (?, ?)
+        `, record.ID, string(explanation)); err != nil {
+			return fmt.Errorf("failed to record transaction route: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Query returns the transactions matching q, newest first
+func (s *TransactionStore) Query(q TransactionQuery) ([]TransactionRecord, error) {
+	where, args := q.where()
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+	if limit > maxQueryLimit {
+		limit = maxQueryLimit
+	}
+	args = append(args, limit, q.Offset)
+
+	rows, err := s.db.Query(`
+        SELECT id, from_chain, to_chain, status, error, blob_ref, size, created_at
+        FROM transactions
+        `+where+`
+        ORDER BY created_at DESC, id
+        LIMIT ? OFFSET ?
+    `, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transactions: %w", err)
+	}
+	defer rows.Close()
+
+	records := make([]TransactionRecord, 0)
+	index := make(map[string]int)
+	for rows.Next() {
+		var record TransactionRecord
+		var createdAt int64
+		if err := rows.Scan(&record.ID, &record.FromChain, &record.ToChain, &record.Status,
+			&record.Error, &record.BlobRef, &record.Size, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to read transaction: %w", err)
+		}
+		record.CreatedAt = time.Unix(0, createdAt)
+		index[record.ID] = len(records)
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read transactions: %w", err)
+	}
+
+	if err := s.loadMetadata(records, index); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// loadMetadata fills in metadata for a page of records
+func (s *TransactionStore) loadMetadata(records []TransactionRecord, index map[string]int) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, len(records))
+	args := make([]interface{}, len(records))
+	for i, record := range records {
+		placeholders[i] = "?"
+		args[i] = record.ID
+	}
+
+	rows, err := s.db.Query(`
+        SELECT tx_id, key, value FROM transaction_metadata
+        WHERE tx_id IN (`+strings.Join(placeholders, ", ")+`)
+    `, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query transaction metadata: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, key, value string
+		if err := rows.Scan(&id, &key, &value); err != nil {
+			return fmt.Errorf("failed to read transaction metadata: %w", err)
+		}
+		record := &records[index[id]]
+		if record.Metadata == nil {
+			record.Metadata = make(map[string]string)
+		}
+		record.Metadata[key] = value
+	}
+	return rows.Err()
+}
+
+// Get returns a recorded transaction with its metadata
+func (s *TransactionStore) Get(txID string) (TransactionRecord, error) {
+	var record TransactionRecord
+	var createdAt int64
+	err := s.db.QueryRow(`
+        SELECT id, from_chain, to_chain, status, error, blob_ref, size, created_at
+        FROM transactions WHERE id = ?
+    `, txID).Scan(&record.ID, &record.FromChain, &record.ToChain, &record.Status,
+		&record.Error, &record.BlobRef, &record.Size, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return record, ErrTransactionNotFound
+	}
+	if err != nil {
+		return record, fmt.Errorf("failed to query transaction: %w", err)
+	}
+	record.CreatedAt = time.Unix(0, createdAt)
+
+	records := []TransactionRecord{record}
+	if err := s.loadMetadata(records, map[string]int{record.ID: 0}); err != nil {
+		return record, err
+	}
+	return records[0], nil
+}
+
+// Route returns the routing explanation recorded with a transaction
+func (s *TransactionStore) Route(txID string) (*RouteExplanation, error) {
+	var explanation string
+	err := s.db.QueryRow(`
+        SELECT explanation FROM transaction_routes WHERE tx_id = ?
+    `, txID).Scan(&explanation)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTransactionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transaction route: %w", err)
+	}
+
+	var route RouteExplanation
+	if err := json.Unmarshal([]byte(explanation), &route); err != nil {
+		return nil, fmt.Errorf("failed to decode transaction route: %w", err)
+	}
+	return &route, nil
+}
+
+// RecordReceipt stores the receipt bundle of a completed transaction,
+// replacing any earlier one
+func (s *TransactionStore) RecordReceipt(bundle *ReceiptBundle) error {
+	encoded, err := json.Marshal(bundle)
+	if err != nil {
+		return fmt.Errorf("failed to encode receipt bundle: %w", err)
+	}
+	if _, err := s.db.Exec(`
+        INSERT OR REPLACE INTO transaction_receipts (tx_id, bundle) VALUES (?, ?)
+    `, bundle.TxID, string(encoded)); err != nil {
+		return fmt.Errorf("failed to record receipt bundle: %w", err)
+	}
+	return nil
+}
+
+// Receipt returns the receipt bundle recorded when a transaction completed
+func (s *TransactionStore) Receipt(txID string) (*ReceiptBundle, error) {
+	var encoded string
+	err := s.db.QueryRow(`
+        SELECT bundle FROM transaction_receipts WHERE tx_id = ?
+    `, txID).Scan(&encoded)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTransactionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query receipt bundle: %w", err)
+	}
+
+	var bundle ReceiptBundle
+	if err := json.Unmarshal([]byte(encoded), &bundle); err != nil {
+		return nil, fmt.Errorf("failed to decode receipt bundle: %w", err)
+	}
+	return &bundle, nil
+}
+
+// SetMetadata sets one metadata key of a recorded transaction, deleting it
+// when value is empty
+func (s *TransactionStore) SetMetadata(txID, key, value string) error {
+	var err error
+	if value == "" {
+		_, err = s.db.Exec(`DELETE FROM transaction_metadata WHERE tx_id = ? AND key = ?`, txID, key)
+	} else {
+		_, err = s.db.Exec(`
+            INSERT OR REPLACE INTO transaction_metadata (tx_id, key, value) VALUES (?, ?, ?)
+        `, txID, key, value)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update transaction metadata: %w", err)
+	}
+	return nil
+}
+
+// RecordPolicyDecision adds a policy decision to the audit trail
+func (s *TransactionStore) RecordPolicyDecision(decision PolicyDecision) error {
+	if _, err := s.db.Exec(`
+        INSERT INTO policy_decisions (tx_id, from_chain, to_chain, rule, action, dry_run, blocked, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+    `, decision.TxID, decision.FromChain, decision.ToChain, decision.Rule, decision.Action,
+		decision.DryRun, decision.Blocked, decision.CreatedAt.UnixNano()); err != nil {
+		return fmt.Errorf("failed to record policy decision: %w", err)
+	}
+	return nil
+}
+
+// PolicyDecisions returns audited policy decisions, newest first, for one
+// transaction when txID is set
+func (s *TransactionStore) PolicyDecisions(txID string, limit int) ([]PolicyDecision, error) {
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+	if limit > maxQueryLimit {
+		limit = maxQueryLimit
+	}
+
+	query := `
+        SELECT tx_id, from_chain, to_chain, rule, action, dry_run, blocked, created_at
+        FROM policy_decisions`
+	args := []interface{}{}
+	if txID != "" {
+		query += ` WHERE tx_id = ?`
+		args = append(args, txID)
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query policy decisions: %w", err)
+	}
+	defer rows.Close()
+
+	decisions := make([]PolicyDecision, 0)
+	for rows.Next() {
+		var decision PolicyDecision
+		var createdAt int64
+		if err := rows.Scan(&decision.TxID, &decision.FromChain, &decision.ToChain, &decision.Rule,
+			&decision.Action, &decision.DryRun, &decision.Blocked, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to read policy decision: %w", err)
+		}
+		decision.CreatedAt = time.Unix(0, createdAt)
+		decisions = append(decisions, decision)
+	}
+	return decisions, rows.Err()
+}
+
+// RecordReroute adds an operator's re-route of a transaction to the audit
+// trail
+func (s *TransactionStore) RecordReroute(reroute Reroute) error {
+	request, err := json.Marshal(reroute.Request)
+	if err != nil {
+		return fmt.Errorf("failed to encode reroute request: %w", err)
+	}
+	if _, err := s.db.Exec(`
+        INSERT INTO transaction_reroutes (tx_id, failed_chain, to_chain, operator, reason, request, status, error, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `, reroute.TxID, reroute.FailedChain, reroute.ToChain, reroute.Request.Operator, reroute.Request.Reason,
+		string(request), reroute.Status, reroute.Error, reroute.CreatedAt.UnixNano()); err != nil {
+		return fmt.Errorf("failed to record reroute: %w", err)
+	}
+	return nil
+}
+
+// Reroutes returns the audited re-routes of a transaction, newest first
+func (s *TransactionStore) Reroutes(txID string) ([]Reroute, error) {
+	rows, err := s.db.Query(`
+        SELECT tx_id, failed_chain, to_chain, request, status, error, created_at
+        FROM transaction_reroutes WHERE tx_id = ?
+        ORDER BY created_at DESC, id DESC
+    `, txID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reroutes: %w", err)
+	}
+	defer rows.Close()
+
+	reroutes := make([]Reroute, 0)
+	for rows.Next() {
+		var reroute Reroute
+		var request string
+		var createdAt int64
+		if err := rows.Scan(&reroute.TxID, &reroute.FailedChain, &reroute.ToChain, &request,
+			&reroute.Status, &reroute.Error, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to read reroute: %w", err)
+		}
+		if err := json.Unmarshal([]byte(request), &reroute.Request); err != nil {
+			return nil, fmt.Errorf("failed to decode reroute request: %w", err)
+		}
+		reroute.CreatedAt = time.Unix(0, createdAt)
+		reroutes = append(reroutes, reroute)
+	}
+	return reroutes, rows.Err()
+}
+
+// SaveReconciliationReport stores a report, replacing any earlier one for
+// the same day
+func (s *TransactionStore) SaveReconciliationReport(report ReconciliationReport) error {
+	encoded, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to encode reconciliation report: %w", err)
+	}
+	if _, err := s.db.Exec(`
+        INSERT OR REPLACE INTO reconciliation_reports (date, report, generated_at) VALUES (?, ?, ?)
+    `, report.Date, string(encoded), report.GeneratedAt.UnixNano()); err != nil {
+		return fmt.Errorf("failed to save reconciliation report: %w", err)
+	}
+	return nil
+}
+
+// ReconciliationReport returns the report for a day formatted with
+// ReportDateLayout
+func (s *TransactionStore) ReconciliationReport(date string) (ReconciliationReport, error) {
+	var encoded string
+	err := s.db.QueryRow(`
+        SELECT report FROM reconciliation_reports WHERE date = ?
+    `, date).Scan(&encoded)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ReconciliationReport{}, ErrReportNotFound
+	}
+	if err != nil {
+		return ReconciliationReport{}, fmt.Errorf("failed to query reconciliation report: %w", err)
+	}
+
+	var report ReconciliationReport
+	if err := json.Unmarshal([]byte(encoded), &report); err != nil {
+		return ReconciliationReport{}, fmt.Errorf("failed to decode reconciliation report: %w", err)
+	}
+	return report, nil
+}
+
+// ReconciliationReports returns the latest reports, newest day first,
+// without their mismatches
+func (s *TransactionStore) ReconciliationReports(limit int) ([]ReconciliationReport, error) {
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+	if limit > maxQueryLimit {
+		limit = maxQueryLimit
+	}
+
+	rows, err := s.db.Query(`
+        SELECT report FROM reconciliation_reports ORDER BY date DESC LIMIT ?
+    `, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reconciliation reports: %w", err)
+	}
+	defer rows.Close()
+
+	reports := make([]ReconciliationReport, 0)
+	for rows.Next() {
+		var encoded string
+		if err := rows.Scan(&encoded); err != nil {
+			return nil, fmt.Errorf("failed to read reconciliation report: %w", err)
+		}
+		var report ReconciliationReport
+		if err := json.Unmarshal([]byte(encoded), &report); err != nil {
+			return nil, fmt.Errorf("failed to decode reconciliation report: %w", err)
+		}
+		report.Mismatches = nil
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
+
+// Collect removes transactions, policy decisions and re-routes recorded
+// before cutoff
+func (s *TransactionStore) Collect(cutoff time.Time) int {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+        DELETE FROM transaction_metadata WHERE tx_id IN (
+            SELECT id FROM transactions WHERE created_at < ?
+        )
+    `, cutoff.UnixNano()); err != nil {
+		return 0
+	}
+	if _, err := tx.Exec(`
+        DELETE FROM transaction_routes WHERE tx_id IN (
+            SELECT id FROM transactions WHERE created_at < ?
+        )
+    `, cutoff.UnixNano()); err != nil {
+		return 0
+	}
+	if _, err := tx.Exec(`DELETE FROM policy_decisions WHERE created_at < ?`, cutoff.UnixNano()); err != nil {
+		return 0
+	}
+	if _, err := tx.Exec(`DELETE FROM transaction_reroutes WHERE created_at < ?`, cutoff.UnixNano()); err != nil {
+		return 0
+	}
+	result, err := tx.Exec(`DELETE FROM transactions WHERE created_at < ?`, cutoff.UnixNano())
+	if err != nil {
+		return 0
+	}
+	if err := tx.Commit(); err != nil {
+		return 0
+	}
+
+	removed, _ := result.RowsAffected()
+	return int(removed)
+}
+
+// Close the database connection
+func (s *TransactionStore) Close() error {
+	if s.db != nil {
+		return s.db.Close()
+	}
+	return nil
+}
@@ -3,13 +3,18 @@ package agglomerator
 import (
 	"context"
 	"errors"
+	"fmt"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
 	"github.com/theaxiomverse/hydap-api/pkg/vectors"
 	"sync"
+	"time"
 )
 
 var (
-	ErrNoRouteFound  = errors.New("no route found between chains")
-	ErrChainNotFound = errors.New("chain not found")
+	ErrNoRouteFound        = errors.New("no route found between chains")
+	ErrChainNotFound       = errors.New("chain not found")
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrPayloadTooLarge     = errors.New("transaction payload too large")
 )
 
 // Agglomerator manages the cross-chain operations
@@ -17,25 +22,61 @@ type Agglomerator struct {
 	chains      map[string]*Chain
 	vectorIndex *vectors.InfiniteVectorIndex
 	mu          sync.RWMutex
+	compactor   *PoolCompactor
+	health      *endpointHealth
+	clusters    *vectors.Clusterer // Groups chain and transaction vectors
+	refit       *clusterRefit
+	compareDims int                   // Vector dimensions compared for similarity
+	elements    *vectors.ElementCache // Materialized elements of chain state vectors
+	pool        PoolConfig
+	events      *EventLog // Nil when state changes are not logged
+	clock       core.Clock
+	planning    planningCounters // Route planning shared by batched transactions
+	preRouter   *PreRouter       // Nil unless routes are warmed ahead of predicted traffic
+
+	maintenanceChecks *maintenanceChecks // Nil unless windows are applied in the background
+	canaries          *canaryChecks      // Nil unless newly registered chains warm up
+	experiment        *experiment        // Nil unless an A/B experiment routes transactions
 }
 
 // AgglomeratorConfig holds initialization parameters
 type AgglomeratorConfig struct {
-	NodeID       string
-	VectorDims   int
-	SimThreshold float64
+	NodeID        string
+	VectorDims    int
+	SimThreshold  float64
+	CompareDims   int                   // Dimensions compared for similarity, DefaultCompareDims if unset
+	CacheElements int                   // Chain vector elements cached, vectors.DefaultCacheElements if unset
+	QueryCache    int                   // Routing queries cached, vectors.DefaultQueryCacheEntries if unset
+	Pool          PoolConfig            // Per-chain transaction pool limits
+	Clustering    vectors.ClusterConfig // Zero fields fall back to CompareDims and SimThreshold
+	Clock         core.Clock            // Drives health checks, chain sync and pre-routing, core.SystemClock if unset
 }
 
+// DefaultCompareDims is the number of vector dimensions compared for
+// similarity when none are configured
+const DefaultCompareDims = 50
+
 // Chain represents a blockchain network with vector state
 // In pkg/modules/agglomerator/types.go
 type Chain struct {
 	ID                  string
 	Endpoint            string
+	Endpoints           []string // Failover endpoints tried after Endpoint
 	Protocol            string
+	Zone                string            // Privacy zone the chain is private to; empty for public chains
+	Capabilities        ChainCapabilities // Transactions the chain can serve; zero serves any
 	StateVector         vectors.InfiniteVector
 	TransactionPool     *vectors.InfiniteVectorIndex
-	streamingCompressor *AdaptiveCompressor // Add this field
-	compressedBlocks    []*CompressedBlock  // Add this field
+	streamingCompressor *AdaptiveCompressor
+	compressedBlocks    []*ArchiveBlock // Compacted transaction pool history
+	archiveMu           sync.RWMutex
+	adapter             ChainAdapter // Nil when the protocol has no adapter
+	endpoints           *EndpointPool
+	admission           *PoolAdmission // Nil until registered
+	events              *EventLog
+	maintenance         *ChainMaintenance // Nil unless a maintenance window is scheduled
+	canary              *chainCanary      // Nil unless the chain warmed up as a canary
+	vectorSeed          []float64         // Imported leading dimensions of StateVector
 }
 
 // Transaction represents a cross-chain transaction
@@ -44,28 +85,171 @@ type Transaction struct {
 	FromChain   string
 	ToChain     string
 	Data        []byte
+	BlobRef     string // Blake3 hash of a payload held in the blob store
+	Metadata    map[string]string
 	StateVector vectors.InfiniteVector
 	Similarity  float64
+	Dimensions  int               // Overrides the dimensions compared when routing
+	Fee         float64           // Ranks the transaction in chain pools, after Priority
+	Priority    int               // Ranks the transaction in chain pools
+	Asset       string            // Symbol of the asset moved, registered on FromChain
+	Amount      string            // Base units of Asset
+	Value       float64           `json:"-"` // Amount in the asset registry's currency, set when valued
+	FeeBudget   *FeeBudget        // Caps the fees estimated across the route
+	Fees        *FeeEstimate      `json:"-"` // Set when a fee budget was checked
+	Simulation  *SimulationResult `json:"-"` // Set when the transaction was simulated
+	Sponsorship *Sponsorship      `json:"-"` // Set when sponsor wallets pay its fees
+	Receipt     *SubmitReceipt    `json:"-"` // Set once submitted through its destination's adapter
+	Route       *RouteExplanation `json:"-"` // Set once the route is chosen
 }
 
 // NewAgglomerator creates a new instance
 func NewAgglomerator(config AgglomeratorConfig) *Agglomerator {
+	index := vectors.NewInfiniteVectorIndex()
+	index.EnableQueryCache(config.QueryCache)
 	return &Agglomerator{
 		chains:      make(map[string]*Chain),
-		vectorIndex: vectors.NewInfiniteVectorIndex(),
+		vectorIndex: index,
+		clusters:    vectors.NewClusterer(clusterConfig(config)),
+		compareDims: compareDims(config),
+		elements:    vectors.NewElementCache(config.CacheElements),
+		pool:        config.Pool,
+		clock:       configClock(config),
+	}
+}
+
+func configClock(config AgglomeratorConfig) core.Clock {
+	if config.Clock != nil {
+		return config.Clock
+	}
+	return core.SystemClock
+}
+
+func compareDims(config AgglomeratorConfig) int {
+	if config.CompareDims > 0 {
+		return config.CompareDims
 	}
+	return DefaultCompareDims
+}
+
+// ElementCacheStats reports the hit rate of the chain vector element cache
+func (a *Agglomerator) ElementCacheStats() vectors.ElementCacheStats {
+	return a.elements.Stats()
+}
+
+// QueryCacheStats reports the cache of routing index query results
+func (a *Agglomerator) QueryCacheStats() vectors.QueryCacheStats {
+	stats, _ := a.vectorIndex.QueryCacheStats()
+	return stats
+}
+
+// EnableTiering keeps at most budget bytes of routing index records in
+// memory, demoting the least recently used transactions to store. Cold
+// records are still searched when routing. Chains are pinned in memory,
+// since every route compares against them.
+func (a *Agglomerator) EnableTiering(store vectors.ColdStore, budget int64) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	a.vectorIndex.EnableTiering(store, vectors.TierConfig{
+		MemoryBudget: budget,
+		Dimensions:   a.compareDims,
+	})
+	for id := range a.chains {
+		a.vectorIndex.Pin(id)
+	}
+}
+
+// TierStats reports the routing index's memory and disk tiers; false when
+// tiering is not enabled
+func (a *Agglomerator) TierStats() (vectors.TierStats, bool) {
+	return a.vectorIndex.TierStats()
+}
+
+// CompareDims returns the vector dimensions compared for similarity
+func (a *Agglomerator) CompareDims() int {
+	return a.compareDims
+}
+
+// dimsFor returns the dimensions compared when routing tx: its own override
+// or the configured default
+func (a *Agglomerator) dimsFor(tx *Transaction) int {
+	if tx.Dimensions > 0 {
+		return tx.Dimensions
+	}
+	return a.compareDims
 }
 
 // RegisterChain adds a new chain to the agglomerator
 func (a *Agglomerator) RegisterChain(chain *Chain) error {
+	_, err := a.GetChain(chain.ID)
+	registered := err == nil
+	if err := a.registerChain(chain); err != nil {
+		return err
+	}
+	data := ChainRegisteredData{
+		Protocol:  chain.Protocol,
+		Endpoint:  chain.Endpoint,
+		Endpoints: chain.Endpoints,
+		Zone:      chain.Zone,
+	}
+	if !chain.Capabilities.IsZero() {
+		data.Capabilities = &chain.Capabilities
+	}
+	a.EventLog().record(EventChainRegistered, chain.ID, "", data)
+	if !registered {
+		a.startCanary(chain)
+	}
+	return nil
+}
+
+// registerChain adds a chain without logging it, for chains restored from
+// the event log
+func (a *Agglomerator) registerChain(chain *Chain) error {
+	if err := chain.Capabilities.Validate(); err != nil {
+		return err
+	}
+
+	// Chains registered through the API arrive without a state vector
+	if chain.StateVector.Generator == nil {
+		chain.StateVector = vectors.InfiniteVector{Generator: getProtocolGenerator(chain.Protocol)}
+	}
+	if err := chain.attachEndpoints(); err != nil {
+		return err
+	}
+
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
+	if a.health != nil {
+		chain.endpoints.SetFailureThreshold(a.health.config.FailureThreshold)
+	}
+
 	// Initialize transaction pool with vector index
 	chain.TransactionPool = vectors.NewInfiniteVectorIndex()
+	chain.admission = NewPoolAdmission(a.pool.limitFor(chain.ID), a.pool.Policy)
+	chain.events = a.events
+
+	// Chain state vectors are compared with every transaction; share their
+	// materialized elements between the copies made by queries
+	if previous, exists := a.chains[chain.ID]; exists && previous != chain {
+		previous.StateVector.Release()
+		// A re-registered chain stays in maintenance
+		if chain.maintenance == nil {
+			chain.maintenance = previous.maintenance
+		}
+		// and keeps its warm-up
+		if chain.canary == nil {
+			chain.canary = previous.canary
+		}
+	}
+	if !chain.StateVector.Shared() {
+		a.elements.Share(&chain.StateVector)
+	}
 
 	// Store chain in local registry
 	a.chains[chain.ID] = chain
+	a.clusters.Add(chain.ID, &chain.StateVector)
 
 	// Register chain's state vector
 	record := vectors.DatabaseRecord{
@@ -74,26 +258,81 @@ func (a *Agglomerator) RegisterChain(chain *Chain) error {
 			"protocol": chain.Protocol,
 			"endpoint": chain.Endpoint,
 		},
-		Vector: chain.StateVector,
+		Vector: chain.StateVector.Copy(),
 	}
 
-	return a.vectorIndex.Insert(record)
+	if err := a.vectorIndex.Insert(record); err != nil {
+		return err
+	}
+	a.vectorIndex.Pin(chain.ID)
+	return nil
 }
 
 // ProcessTransaction handles a cross-chain transaction
 func (a *Agglomerator) ProcessTransaction(ctx context.Context, tx *Transaction) error {
+	a.defaultStateVector(tx)
+	toChain, err := a.recordTransaction(tx)
+	if err != nil {
+		return err
+	}
+	return submitTransaction(ctx, toChain, tx)
+}
+
+// defaultStateVector gives a transaction submitted without a state vector,
+// as from JSON, that of its source chain's protocol; transactions from
+// unknown chains get a zero vector and fail routing
+func (a *Agglomerator) defaultStateVector(tx *Transaction) {
+	if tx.StateVector.Generator != nil {
+		return
+	}
+	if chain, err := a.GetChain(tx.FromChain); err == nil {
+		tx.StateVector = vectors.InfiniteVector{Generator: getProtocolGenerator(chain.Protocol)}
+		return
+	}
+	tx.StateVector = vectors.InfiniteVector{Generator: func(int) float64 { return 0 }}
+}
+
+// submitTransaction sends a routed transaction to its destination's adapter;
+// adapter-backed chains confirm inclusion on the destination network, and
+// their receipt is kept with the transaction. The outcome counts towards a
+// warming chain's canary.
+func submitTransaction(ctx context.Context, toChain *Chain, tx *Transaction) error {
+	if toChain.adapter != nil {
+		receipt, err := toChain.adapter.Submit(ctx, tx)
+		toChain.recordCanary(err)
+		if err != nil {
+			return fmt.Errorf("failed to submit to %s: %w", toChain.ID, err)
+		}
+		tx.Receipt = receipt
+	}
+	return nil
+}
+
+// recordTransaction routes a transaction and adds it to both chains' pools,
+// returning the destination chain
+func (a *Agglomerator) recordTransaction(tx *Transaction) (*Chain, error) {
+	return a.recordPlanned(tx, &routePlan{})
+}
+
+// recordPlanned records a transaction with the route plan of its group,
+// making the plan first if the group has none yet
+func (a *Agglomerator) recordPlanned(tx *Transaction, plan *routePlan) (*Chain, error) {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
 
 	// Query similar chains based on state vectors
-	similarChains := a.vectorIndex.AdvancedQuery(
-		tx.Similarity,
-		tx.StateVector,
-		50, // default dimensions to compare
-	)
+	if !plan.made {
+		similarChains := a.vectorIndex.AdvancedQuery(
+			tx.Similarity,
+			tx.StateVector,
+			a.dimsFor(tx),
+		)
+		plan.routable = len(similarChains) > 0
+		plan.made = true
+	}
 
-	if len(similarChains) == 0 {
-		return ErrNoRouteFound
+	if !plan.routable {
+		return nil, ErrNoRouteFound
 	}
 
 	// Record transaction vector
@@ -105,27 +344,103 @@ func (a *Agglomerator) ProcessTransaction(ctx context.Context, tx *Transaction)
 		},
 		Vector: tx.StateVector,
 	}
-
-	if err := a.vectorIndex.Insert(record); err != nil {
-		return err
+	if tx.BlobRef != "" {
+		record.Metadata["blobRef"] = tx.BlobRef
+	}
+	if tx.Priority != 0 || tx.Fee != 0 {
+		record.Metadata["priority"] = tx.Priority
+		record.Metadata["fee"] = tx.Fee
 	}
 
-	// Add to chains' transaction pools
 	fromChain, exists := a.chains[tx.FromChain]
 	if !exists {
-		return ErrChainNotFound
+		return nil, ErrChainNotFound
 	}
 
 	toChain, exists := a.chains[tx.ToChain]
 	if !exists {
-		return ErrChainNotFound
+		return nil, ErrChainNotFound
+	}
+
+	// Private chains only carry transactions from their own zone
+	if !chainVisible(toChain, fromChain.Zone) {
+		return nil, fmt.Errorf("%w: %s", ErrZoneMismatch, toChain.ID)
+	}
+
+	// A chain that would reject the transaction is not routed to
+	if err := toChain.serves(tx, a.clock.Now()); err != nil {
+		return nil, fmt.Errorf("%s: %w", toChain.ID, err)
+	}
+
+	// Both pools must have room before the transaction is recorded
+	entry := PoolEntry{TxID: tx.ID, Priority: tx.Priority, Fee: tx.Fee, AddedAt: time.Now()}
+	if err := fromChain.admitToPool(entry); err != nil {
+		return nil, err
+	}
+	if err := toChain.admitToPool(entry); err != nil {
+		if toChain != fromChain {
+			fromChain.admission.Remove(tx.ID)
+		}
+		return nil, err
+	}
+
+	if err := a.vectorIndex.Insert(record); err != nil {
+		return nil, err
 	}
 
 	// Add to transaction pools
 	fromChain.TransactionPool.Insert(record)
 	toChain.TransactionPool.Insert(record)
+	a.clusters.Add(tx.ID, &tx.StateVector)
+	a.events.record(EventTransactionRouted, "", tx.ID, TransactionRoutedData{
+		FromChain: tx.FromChain,
+		ToChain:   tx.ToChain,
+		Priority:  tx.Priority,
+		Fee:       tx.Fee,
+		BlobRef:   tx.BlobRef,
+	})
 
-	return nil
+	if a.preRouter != nil {
+		a.preRouter.observe(tx)
+	}
+
+	tx.Route = plan.explain(tx, func() *RouteExplanation {
+		candidates := make([]*Chain, 0, len(a.chains))
+		for _, chain := range a.chains {
+			candidates = append(candidates, chain)
+		}
+		weights, arm := a.routeWeights(tx)
+		explanation := explainWeightedRoute(tx, RouteModeRequested, candidates, []string{toChain.ID}, a.dimsFor(tx), weights, func(string) bool { return true })
+		explanation.Experiment = arm
+		return explanation
+	})
+	tx.Route.Fees = tx.Fees
+	tx.Route.Simulation = tx.Simulation
+	tx.Route.Sponsorship = tx.Sponsorship
+
+	return toChain, nil
+}
+
+// SetEventLog logs the agglomerator's state changes to log from now on
+func (a *Agglomerator) SetEventLog(log *EventLog) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.events = log
+	for _, chain := range a.chains {
+		chain.events = log
+	}
+}
+
+// EventLog returns the log of state changes, or nil if there is none
+func (a *Agglomerator) EventLog() *EventLog {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.events
+}
+
+// Adapter returns the chain's submission adapter, or nil if it has none
+func (c *Chain) Adapter() ChainAdapter {
+	return c.adapter
 }
 
 func (a *Agglomerator) ListChains() []*Chain {
@@ -149,3 +464,67 @@ func (a *Agglomerator) GetChain(id string) (*Chain, error) {
 	}
 	return chain, nil
 }
+
+// CollectRecords removes transaction vector records inserted before cutoff
+// from the routing index and chain pools. Chain registrations are retained.
+func (a *Agglomerator) CollectRecords(cutoff time.Time) int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	removed := 0
+	for _, record := range a.vectorIndex.InsertedBefore(cutoff) {
+		if _, isChain := a.chains[record.ID]; isChain {
+			continue
+		}
+		if a.vectorIndex.Delete(record.ID) {
+			a.clusters.Remove(record.ID)
+			removed++
+		}
+	}
+
+	for _, chain := range a.chains {
+		if chain.TransactionPool == nil {
+			continue
+		}
+		for _, record := range chain.TransactionPool.InsertedBefore(cutoff) {
+			if chain.removeFromPool(record.ID, RemovedCollected) {
+				removed++
+			}
+		}
+	}
+	return removed
+}
+
+// BlobRefs returns the blob hashes referenced by transactions in the routing
+// index, the chain pools and their archives
+func (a *Agglomerator) BlobRefs() map[string]bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	refs := make(map[string]bool)
+	collect := func(metadata map[string]interface{}) {
+		if hash, ok := metadata["blobRef"].(string); ok && hash != "" {
+			refs[hash] = true
+		}
+	}
+
+	for _, record := range a.vectorIndex.Records() {
+		collect(record.Metadata)
+	}
+	for _, chain := range a.chains {
+		if chain.TransactionPool != nil {
+			for _, record := range chain.TransactionPool.Records() {
+				collect(record.Metadata)
+			}
+		}
+
+		chain.archiveMu.RLock()
+		for _, block := range chain.compressedBlocks {
+			for _, metadata := range block.Metadata {
+				collect(metadata)
+			}
+		}
+		chain.archiveMu.RUnlock()
+	}
+	return refs
+}
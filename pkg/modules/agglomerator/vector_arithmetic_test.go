@@ -0,0 +1,46 @@
+package agglomerator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+func TestVectorArithmetic(t *testing.T) {
+	dims := &vectors.InfiniteVector{Generator: func(dim int) float64 { return float64(dim) }}
+
+	sum := vectors.Add(dims, vectors.Constant(1), dims)
+	assert.Equal(t, 7.0, sum.GetElement(3))
+
+	scaled := vectors.Scale(dims, -2)
+	assert.Equal(t, -8.0, scaled.GetElement(4))
+
+	blended := vectors.Blend(dims, vectors.Constant(10), 0.25)
+	assert.Equal(t, 4.0, blended.GetElement(2))
+	baseline := vectors.Blend(dims, vectors.Constant(10), 0)
+	assert.Equal(t, 2.0, baseline.GetElement(2), "alpha 0 keeps the baseline")
+
+	clamped := vectors.Clamp(scaled, -5, 0)
+	assert.Equal(t, -2.0, clamped.GetElement(1))
+	assert.Equal(t, -5.0, clamped.GetElement(9))
+
+	overlay := vectors.Overlay([]float64{5, 6}, dims)
+	assert.Equal(t, []float64{5, 6, 2, 3}, vectorElements(overlay, 4))
+	padded := vectors.FromElements([]float64{5})
+	assert.Equal(t, []float64{5, 0, 0}, vectorElements(&padded, 3))
+}
+
+func TestVectorArithmeticCopiesOperands(t *testing.T) {
+	var calls int
+	base := countingVector(&calls)
+	cache := vectors.NewElementCache(100)
+	cache.Share(&base)
+	base.GetElement(9)
+	calls = 0
+
+	composed := vectors.Scale(&base, 2)
+	base = vectors.FromElements(nil)
+	assert.Equal(t, 18.0, composed.GetElement(9), "replacing an operand leaves the composition alone")
+	assert.Zero(t, calls, "copies of shared operands read their cached elements")
+}
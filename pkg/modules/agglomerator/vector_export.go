@@ -0,0 +1,232 @@
+package agglomerator
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+// Formats of a state vector export
+const (
+	VectorFormatCSV     = "csv"
+	VectorFormatParquet = "parquet"
+)
+
+// ErrInvalidVector reports imported state vector values that cannot seed a
+// chain
+var ErrInvalidVector = errors.New("invalid state vector")
+
+// ChainVector is a chain's state vector materialized to a number of
+// dimensions
+type ChainVector struct {
+	ChainID  string    `json:"chainId"`
+	Protocol string    `json:"protocol"`
+	Values   []float64 `json:"values"`
+}
+
+// ChainVectors materializes the state vectors of the chains in ids (every
+// chain if empty) speaking protocol (any if empty) to dims dimensions,
+// ordered by chain ID
+func (a *Agglomerator) ChainVectors(ids []string, protocol string, dims int) ([]ChainVector, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var chains []*Chain
+	if len(ids) == 0 {
+		for _, chain := range a.chains {
+			chains = append(chains, chain)
+		}
+	} else {
+		for _, id := range ids {
+			chain, exists := a.chains[id]
+			if !exists {
+				return nil, fmt.Errorf("%w: %s", ErrChainNotFound, id)
+			}
+			chains = append(chains, chain)
+		}
+	}
+	sort.Slice(chains, func(i, j int) bool { return chains[i].ID < chains[j].ID })
+
+	var exported []ChainVector
+	for _, chain := range chains {
+		if protocol != "" && chain.Protocol != protocol {
+			continue
+		}
+		vector := ChainVector{ChainID: chain.ID, Protocol: chain.Protocol, Values: make([]float64, dims)}
+		for d := range vector.Values {
+			vector.Values[d] = chain.StateVector.GetElement(d)
+		}
+		exported = append(exported, vector)
+	}
+	return exported, nil
+}
+
+// SeedChainVector replaces the leading dimensions of a chain's state vector
+// with values computed elsewhere; dimensions beyond them keep the chain's
+// protocol generator. The seed is logged so it survives restarts.
+func (a *Agglomerator) SeedChainVector(chainID string, values []float64) error {
+	if err := a.seedChainVector(chainID, values); err != nil {
+		return err
+	}
+	a.events.record(EventChainVector, chainID, "", ChainVectorData{Values: values})
+	return nil
+}
+
+// seedChainVector seeds a chain's state vector without logging it, for seeds
+// restored from the event log
+func (a *Agglomerator) seedChainVector(chainID string, values []float64) error {
+	if len(values) == 0 || len(values) > maxCompareDims {
+		return fmt.Errorf("%w: between 1 and %d values are required", ErrInvalidVector, maxCompareDims)
+	}
+	for d, value := range values {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return fmt.Errorf("%w: dimension %d is not finite", ErrInvalidVector, d)
+		}
+	}
+	seed := append([]float64(nil), values...)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	chain, exists := a.chains[chainID]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrChainNotFound, chainID)
+	}
+	chain.StateVector.Release()
+	chain.StateVector = vectors.Overlay(seed, &vectors.InfiniteVector{Generator: getProtocolGenerator(chain.Protocol)}).Copy()
+	a.elements.Share(&chain.StateVector)
+	chain.vectorSeed = seed
+
+	// The chain keeps no cluster assignment made for its previous vector
+	a.clusters.Remove(chain.ID)
+	a.clusters.Add(chain.ID, &chain.StateVector)
+	if err := a.vectorIndex.Insert(vectors.DatabaseRecord{
+		ID: chain.ID,
+		Metadata: map[string]interface{}{
+			"protocol": chain.Protocol,
+			"endpoint": chain.Endpoint,
+		},
+		Vector: chain.StateVector.Copy(),
+	}); err != nil {
+		return err
+	}
+	a.vectorIndex.Pin(chain.ID)
+	return nil
+}
+
+// vectorColumns names the columns of an export of dims dimensions
+func vectorColumns(dims int) []string {
+	columns := []string{"chain", "protocol"}
+	for d := 0; d < dims; d++ {
+		columns = append(columns, "d"+strconv.Itoa(d))
+	}
+	return columns
+}
+
+// WriteVectorsCSV writes chain vectors with one row per chain: its ID,
+// protocol and a column per dimension
+func WriteVectorsCSV(w io.Writer, exported []ChainVector, dims int) error {
+	writer := csv.NewWriter(w)
+	writer.Write(vectorColumns(dims))
+	for _, vector := range exported {
+		row := []string{vector.ChainID, vector.Protocol}
+		for _, value := range vector.Values {
+			row = append(row, strconv.FormatFloat(value, 'g', -1, 64))
+		}
+		writer.Write(row)
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteVectorsParquet writes chain vectors in the columns of WriteVectorsCSV
+// as a Parquet file
+func WriteVectorsParquet(w io.Writer, exported []ChainVector, dims int) error {
+	names := vectorColumns(dims)
+	columns := []parquetColumn{
+		{Name: names[0], Strings: make([]string, len(exported))},
+		{Name: names[1], Strings: make([]string, len(exported))},
+	}
+	for d := 0; d < dims; d++ {
+		columns = append(columns, parquetColumn{Name: names[d+2], Doubles: make([]float64, len(exported))})
+	}
+	for row, vector := range exported {
+		columns[0].Strings[row] = vector.ChainID
+		columns[1].Strings[row] = vector.Protocol
+		for d, value := range vector.Values {
+			columns[d+2].Doubles[row] = value
+		}
+	}
+	return writeParquet(w, columns, len(exported))
+}
+
+// ReadVectorCSV reads the values seeding chainID from CSV with a header
+// row. Dimension columns are named d0, d1, ... as in an export; the row used
+// is the one whose chain column is chainID, or the only row if there is no
+// chain column.
+func ReadVectorCSV(r io.Reader, chainID string) ([]float64, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidVector, err)
+	}
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("%w: a header and a row are required", ErrInvalidVector)
+	}
+
+	chainColumn := -1
+	dimensions := make(map[int]int) // Dimension to column
+	for column, name := range rows[0] {
+		name = strings.TrimSpace(name)
+		if name == "chain" {
+			chainColumn = column
+			continue
+		}
+		if d, err := strconv.Atoi(strings.TrimPrefix(name, "d")); err == nil && strings.HasPrefix(name, "d") && d >= 0 {
+			dimensions[d] = column
+		}
+	}
+
+	var row []string
+	switch {
+	case chainColumn >= 0:
+		for _, candidate := range rows[1:] {
+			if chainColumn < len(candidate) && candidate[chainColumn] == chainID {
+				row = candidate
+				break
+			}
+		}
+		if row == nil {
+			return nil, fmt.Errorf("%w: no row for chain %s", ErrInvalidVector, chainID)
+		}
+	case len(rows) == 2:
+		row = rows[1]
+	default:
+		return nil, fmt.Errorf("%w: several rows need a chain column", ErrInvalidVector)
+	}
+
+	values := make([]float64, len(dimensions))
+	for d := range values {
+		column, exists := dimensions[d]
+		if !exists {
+			return nil, fmt.Errorf("%w: dimension columns must run from d0 without gaps", ErrInvalidVector)
+		}
+		if column >= len(row) {
+			return nil, fmt.Errorf("%w: row has no d%d", ErrInvalidVector, d)
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(row[column]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: d%d: %v", ErrInvalidVector, d, err)
+		}
+		values[d] = value
+	}
+	return values, nil
+}
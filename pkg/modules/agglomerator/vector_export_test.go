@@ -0,0 +1,126 @@
+package agglomerator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func vectorTestAgglomerator(t *testing.T) *Agglomerator {
+	agg := NewAgglomerator(AgglomeratorConfig{})
+	log, err := NewEventLog(nil)
+	require.NoError(t, err)
+	agg.SetEventLog(log)
+	require.NoError(t, agg.RegisterChain(NewChain("eth", "http://eth:8545", ProtocolEthereum)))
+	require.NoError(t, agg.RegisterChain(NewChain("sol", "http://sol:8899", ProtocolSolana)))
+	return agg
+}
+
+func TestSeedChainVector(t *testing.T) {
+	agg := vectorTestAgglomerator(t)
+	require.NoError(t, agg.SeedChainVector("eth", []float64{0.5, -0.25}))
+
+	exported, err := agg.ChainVectors(nil, "", 4)
+	require.NoError(t, err)
+	require.Len(t, exported, 2)
+	assert.Equal(t, "eth", exported[0].ChainID)
+	eth := getProtocolGenerator(ProtocolEthereum)
+	assert.Equal(t, []float64{0.5, -0.25, eth(2), eth(3)}, exported[0].Values, "the protocol generator fills the rest")
+
+	record, found := agg.vectorIndex.Get("eth")
+	require.True(t, found)
+	assert.Equal(t, 0.5, record.Vector.GetElement(0), "similarity queries see the seed")
+
+	only, err := agg.ChainVectors([]string{"sol"}, "", 2)
+	require.NoError(t, err)
+	require.Len(t, only, 1)
+	byProtocol, err := agg.ChainVectors(nil, ProtocolSolana, 2)
+	require.NoError(t, err)
+	assert.Equal(t, only, byProtocol)
+	_, err = agg.ChainVectors([]string{"btc"}, "", 2)
+	assert.ErrorIs(t, err, ErrChainNotFound)
+
+	assert.ErrorIs(t, agg.SeedChainVector("btc", []float64{1}), ErrChainNotFound)
+	assert.ErrorIs(t, agg.SeedChainVector("eth", nil), ErrInvalidVector)
+	assert.ErrorIs(t, agg.SeedChainVector("eth", []float64{math.NaN()}), ErrInvalidVector)
+	assert.ErrorIs(t, agg.SeedChainVector("eth", make([]float64, maxCompareDims+1)), ErrInvalidVector)
+
+	state, err := agg.EventLog().State(time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0.5, -0.25}, state.Chains["eth"].Vector)
+	assert.Nil(t, state.Chains["sol"].Vector)
+
+	upgraded := NewAgglomerator(AgglomeratorConfig{})
+	_, _, err = upgraded.ImportState(agg.ExportState())
+	require.NoError(t, err)
+	handed, err := upgraded.ChainVectors([]string{"eth"}, "", 4)
+	require.NoError(t, err)
+	assert.Equal(t, exported[0].Values, handed[0].Values, "seeds are handed to a reloaded module")
+
+	require.NoError(t, agg.RegisterChain(NewChain("eth", "http://eth:8545", ProtocolEthereum)))
+	state, err = agg.EventLog().State(time.Time{})
+	require.NoError(t, err)
+	assert.Nil(t, state.Chains["eth"].Vector, "re-registering drops the seed")
+}
+
+func TestVectorCSVRoundTrip(t *testing.T) {
+	agg := vectorTestAgglomerator(t)
+	exported, err := agg.ChainVectors(nil, "", 3)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteVectorsCSV(&buf, exported, 3))
+	assert.True(t, strings.HasPrefix(buf.String(), "chain,protocol,d0,d1,d2\n"))
+
+	values, err := ReadVectorCSV(bytes.NewReader(buf.Bytes()), "sol")
+	require.NoError(t, err)
+	assert.Equal(t, exported[1].Values, values)
+
+	values, err = ReadVectorCSV(strings.NewReader("d1,d0\n2,1\n"), "eth")
+	require.NoError(t, err)
+	assert.Equal(t, []float64{1, 2}, values, "a single row needs no chain column")
+
+	for name, input := range map[string]string{
+		"no row":      "chain,d0\nsol,1\n",
+		"gap":         "d0,d2\n1,2\n",
+		"not a float": "d0\nx\n",
+		"ambiguous":   "d0\n1\n2\n",
+		"header only": "chain,d0\n",
+	} {
+		_, err := ReadVectorCSV(strings.NewReader(input), "eth")
+		assert.ErrorIs(t, err, ErrInvalidVector, name)
+	}
+}
+
+func TestWriteVectorsParquet(t *testing.T) {
+	exported := []ChainVector{
+		{ChainID: "eth", Protocol: ProtocolEthereum, Values: []float64{1.5, -2}},
+		{ChainID: "sol", Protocol: ProtocolSolana, Values: []float64{0.25, 8}},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, WriteVectorsParquet(&buf, exported, 2))
+	file := buf.Bytes()
+
+	require.Greater(t, len(file), 12)
+	assert.Equal(t, parquetMagic, string(file[:4]))
+	assert.Equal(t, parquetMagic, string(file[len(file)-4:]))
+	footer := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	require.Less(t, footer, len(file)-12)
+	meta := file[len(file)-8-footer : len(file)-8]
+	for _, name := range []string{"chain", "protocol", "d0", "d1"} {
+		assert.True(t, bytes.Contains(meta, []byte(name)), "footer names column %s", name)
+	}
+
+	// Pages hold values PLAIN encoded: doubles little-endian, strings length
+	// prefixed
+	d1 := binary.LittleEndian.AppendUint64(nil, math.Float64bits(-2))
+	d1 = binary.LittleEndian.AppendUint64(d1, math.Float64bits(8))
+	assert.True(t, bytes.Contains(file, d1))
+	assert.True(t, bytes.Contains(file, []byte("\x03\x00\x00\x00eth\x03\x00\x00\x00sol")))
+}
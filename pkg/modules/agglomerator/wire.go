@@ -0,0 +1,311 @@
+package agglomerator
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/theaxiomverse/hydap-api/pkg/keymanagement/pb"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Peer channels carry length-delimited protobuf frames: a Handshake each way,
+// then Envelopes from the dialing node. From secureWireVersion the key
+// exchange in keyexchange.go runs between the two, and the envelopes are
+// sealed. The schemas live in pkg/keymanagement/proto/hydap.proto.
+
+const (
+	// frameOverhead is the room allowed for envelope fields around a payload
+	frameOverhead = 64 << 10
+
+	// defaultMaxFrame bounds frames read without a limit of their own
+	defaultMaxFrame = 4 << 20
+)
+
+// writeFrame encodes m with its length prefix into a pooled buffer and
+// writes it in one call, so secure channels seal it as one record
+func writeFrame(w io.Writer, m proto.Message) error {
+	size := proto.Size(m)
+	buf := getBuffer(binary.MaxVarintLen64 + size)
+	defer putBuffer(buf)
+
+	frame := binary.AppendUvarint(*buf, uint64(size))
+	frame, err := proto.MarshalOptions{UseCachedSize: true}.MarshalAppend(frame, m)
+	*buf = frame
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(frame)
+	return err
+}
+
+// readFrame reads one length-prefixed frame into a pooled buffer. Decoding
+// copies bytes fields out of it, so nothing in m refers to the buffer once
+// it is returned.
+func readFrame(r *bufio.Reader, m proto.Message, maxSize int64) error {
+	if maxSize <= 0 {
+		maxSize = defaultMaxFrame
+	}
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	if size > uint64(maxSize) {
+		return &protodelim.SizeTooLargeError{Size: size, MaxSize: uint64(maxSize)}
+	}
+
+	buf := getBuffer(int(size))
+	defer putBuffer(buf)
+	*buf = (*buf)[:size]
+	if _, err := io.ReadFull(r, *buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return proto.Unmarshal(*buf, m)
+}
+
+// unixNano converts a wire timestamp, keeping zero as the zero time
+func unixNano(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns).UTC()
+}
+
+func timeNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// proto converts a message to a WireVersion envelope
+func (msg DataTransferMessage) proto() *pb.Envelope {
+	envelope := &pb.Envelope{
+		SenderId:    msg.SenderID,
+		RecipientId: msg.RecipientID,
+		DataId:      msg.DataID,
+		VectorHash:  msg.VectorHash,
+		Payload:     msg.Payload,
+		Timestamp:   timeNano(msg.Timestamp),
+		Epoch:       msg.Epoch,
+		Sequence:    msg.Sequence,
+		ChunkIndex:  int32(msg.ChunkIndex),
+		ChunkCount:  int32(msg.ChunkCount),
+		Version:     WireVersion,
+	}
+	if len(msg.unknown) > 0 {
+		envelope.ProtoReflect().SetUnknown(msg.unknown)
+	}
+	return envelope
+}
+
+func envelopeFromProto(envelope *pb.Envelope) DataTransferMessage {
+	msg := DataTransferMessage{
+		SenderID:    envelope.GetSenderId(),
+		RecipientID: envelope.GetRecipientId(),
+		DataID:      envelope.GetDataId(),
+		VectorHash:  envelope.GetVectorHash(),
+		Payload:     envelope.GetPayload(),
+		Timestamp:   unixNano(envelope.GetTimestamp()),
+		Epoch:       envelope.GetEpoch(),
+		Sequence:    envelope.GetSequence(),
+		ChunkIndex:  int(envelope.GetChunkIndex()),
+		ChunkCount:  int(envelope.GetChunkCount()),
+	}
+	if unknown := envelope.ProtoReflect().GetUnknown(); len(unknown) > 0 {
+		msg.unknown = append([]byte(nil), unknown...)
+	}
+	return msg
+}
+
+func (h Handshake) proto() *pb.Handshake {
+	return &pb.Handshake{
+		NodeId:      h.NodeID,
+		Algorithms:  h.Algorithms,
+		Algorithm:   h.Algorithm,
+		Attestation: h.Attestation.proto(),
+		Error:       h.Error,
+		Version:     h.Version,
+		MinVersion:  h.MinVersion,
+
+		AdmissionNonce: h.AdmissionNonce,
+	}
+}
+
+func handshakeFromProto(hello *pb.Handshake) Handshake {
+	return Handshake{
+		NodeID:      hello.GetNodeId(),
+		Algorithms:  hello.GetAlgorithms(),
+		Algorithm:   hello.GetAlgorithm(),
+		Attestation: attestationFromProto(hello.GetAttestation()),
+		Error:       hello.GetError(),
+		Version:     hello.GetVersion(),
+		MinVersion:  hello.GetMinVersion(),
+
+		AdmissionNonce: hello.GetAdmissionNonce(),
+	}
+}
+
+func (a *Attestation) proto() *pb.Attestation {
+	if a == nil {
+		return nil
+	}
+	return &pb.Attestation{
+		NodeId:           a.NodeID,
+		SigningAlgorithm: a.SigningAlgorithm,
+		SigningKey:       a.SigningKey,
+		KemAlgorithm:     a.KEMAlgorithm,
+		KemKey:           a.KEMKey,
+		IssuedAt:         timeNano(a.IssuedAt),
+		ExpiresAt:        timeNano(a.ExpiresAt),
+		Issuer:           a.Issuer,
+		Signature:        a.Signature,
+	}
+}
+
+func attestationFromProto(attestation *pb.Attestation) *Attestation {
+	if attestation == nil {
+		return nil
+	}
+	return &Attestation{
+		NodeID:           attestation.GetNodeId(),
+		SigningAlgorithm: attestation.GetSigningAlgorithm(),
+		SigningKey:       attestation.GetSigningKey(),
+		KEMAlgorithm:     attestation.GetKemAlgorithm(),
+		KEMKey:           attestation.GetKemKey(),
+		IssuedAt:         unixNano(attestation.GetIssuedAt()),
+		ExpiresAt:        unixNano(attestation.GetExpiresAt()),
+		Issuer:           attestation.GetIssuer(),
+		Signature:        attestation.GetSignature(),
+	}
+}
+
+// Proto converts a chain to its wire form
+func (s ChainState) Proto() *pb.Chain {
+	return &pb.Chain{
+		Id:           s.ID,
+		Protocol:     s.Protocol,
+		Endpoint:     s.Endpoint,
+		Endpoints:    s.Endpoints,
+		RegisteredAt: timeNano(s.RegisteredAt),
+		Zone:         s.Zone,
+	}
+}
+
+func ChainStateFromProto(chain *pb.Chain) ChainState {
+	return ChainState{
+		ID:           chain.GetId(),
+		Protocol:     chain.GetProtocol(),
+		Endpoint:     chain.GetEndpoint(),
+		Endpoints:    chain.GetEndpoints(),
+		Zone:         chain.GetZone(),
+		RegisteredAt: unixNano(chain.GetRegisteredAt()),
+	}
+}
+
+// Proto converts a transaction to its wire form, carrying the first dims
+// elements of its state vector
+func (tx *Transaction) Proto(dims int) *pb.Transaction {
+	return &pb.Transaction{
+		Id:         tx.ID,
+		FromChain:  tx.FromChain,
+		ToChain:    tx.ToChain,
+		Data:       tx.Data,
+		BlobRef:    tx.BlobRef,
+		Metadata:   tx.Metadata,
+		Dimensions: int32(tx.Dimensions),
+		Fee:        tx.Fee,
+		Priority:   int32(tx.Priority),
+		Asset:      tx.Asset,
+		Amount:     tx.Amount,
+		Vector:     vectorElements(&tx.StateVector, dims),
+	}
+}
+
+// TransactionFromProto rebuilds a transaction; state vector elements past
+// those sent are zero
+func TransactionFromProto(tx *pb.Transaction) *Transaction {
+	return &Transaction{
+		ID:          tx.GetId(),
+		FromChain:   tx.GetFromChain(),
+		ToChain:     tx.GetToChain(),
+		Data:        tx.GetData(),
+		BlobRef:     tx.GetBlobRef(),
+		Metadata:    tx.GetMetadata(),
+		StateVector: elementVector(tx.GetVector()),
+		Dimensions:  int(tx.GetDimensions()),
+		Fee:         tx.GetFee(),
+		Priority:    int(tx.GetPriority()),
+		Asset:       tx.GetAsset(),
+		Amount:      tx.GetAmount(),
+	}
+}
+
+// RecordProto converts a database record to its wire form, carrying the
+// first dims elements of its vector
+func RecordProto(record *vectors.DatabaseRecord, dims int) (*pb.DatabaseRecord, error) {
+	metadata, err := structpb.NewStruct(record.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.DatabaseRecord{
+		Id:       record.ID,
+		Metadata: metadata,
+		Vector:   vectorElements(&record.Vector, dims),
+	}, nil
+}
+
+func RecordFromProto(record *pb.DatabaseRecord) vectors.DatabaseRecord {
+	return vectors.DatabaseRecord{
+		ID:       record.GetId(),
+		Metadata: record.GetMetadata().AsMap(),
+		Vector:   elementVector(record.GetVector()),
+	}
+}
+
+// Proto converts route metrics to their wire form
+func (m RouteMetrics) Proto() *pb.RouteMetrics {
+	return &pb.RouteMetrics{
+		Speed:      m.Speed,
+		Finality:   m.Finality,
+		Cost:       m.Cost,
+		Similarity: m.Similarity,
+		Value:      m.Value,
+	}
+}
+
+func RouteMetricsFromProto(m *pb.RouteMetrics) RouteMetrics {
+	return RouteMetrics{
+		Speed:      m.GetSpeed(),
+		Finality:   m.GetFinality(),
+		Cost:       m.GetCost(),
+		Similarity: m.GetSimilarity(),
+		Value:      m.GetValue(),
+		Health:     1, // Health is judged by the node routing and not sent
+	}
+}
+
+// vectorElements materializes the first dims elements of a vector; vectors
+// without a generator send none
+func vectorElements(vector *vectors.InfiniteVector, dims int) []float64 {
+	if vector.Generator == nil || dims <= 0 {
+		return nil
+	}
+	elements := make([]float64, dims)
+	for d := range elements {
+		elements[d] = vector.GetElement(d)
+	}
+	return elements
+}
+
+// elementVector wraps received elements in a vector that is zero past them
+func elementVector(elements []float64) vectors.InfiniteVector {
+	return vectors.FromElements(elements)
+}
@@ -0,0 +1,101 @@
+package agglomerator
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/keymanagement/pb"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestFramesRoundTrip(t *testing.T) {
+	caPublic, caKey := newCAKey(t)
+	msg := DataTransferMessage{
+		SenderID:    "node-a",
+		RecipientID: "node-b",
+		DataID:      "record-1",
+		Payload:     []byte("payload"),
+		Timestamp:   time.Now(),
+		Sequence:    7,
+		ChunkIndex:  1,
+		ChunkCount:  3,
+	}
+	hello := Handshake{
+		NodeID:      "node-a",
+		Algorithms:  []string{"DILITHIUM5"},
+		Attestation: attestNode("node-a", caKey, time.Now()),
+	}
+
+	var stream bytes.Buffer
+	require.NoError(t, writeFrame(&stream, hello.proto()))
+	require.NoError(t, writeFrame(&stream, msg.proto()))
+
+	reader := bufio.NewReader(&stream)
+	var frame pb.Handshake
+	require.NoError(t, readFrame(reader, &frame, frameOverhead))
+	received := handshakeFromProto(&frame)
+	assert.Equal(t, hello.Algorithms, received.Algorithms)
+
+	anchors := NewTrustAnchors()
+	_, err := anchors.Add("deployment", caPublic)
+	require.NoError(t, err)
+	assert.NoError(t, anchors.Verify(received.Attestation, "node-a", time.Now()), "attestation still verifies after the wire")
+
+	var envelope pb.Envelope
+	require.NoError(t, readFrame(reader, &envelope, frameOverhead))
+	got := envelopeFromProto(&envelope)
+	assert.True(t, msg.Timestamp.Equal(got.Timestamp))
+	got.Timestamp = msg.Timestamp
+	assert.Equal(t, msg, got)
+
+	require.NoError(t, writeFrame(&stream, DataTransferMessage{Payload: make([]byte, 1024)}.proto()))
+	assert.Error(t, readFrame(bufio.NewReader(&stream), &envelope, 512), "frames over the limit are refused")
+}
+
+func TestRecordAndTransactionProto(t *testing.T) {
+	record := vectors.DatabaseRecord{
+		ID:       "tx-1",
+		Metadata: map[string]interface{}{"fromChain": "eth", "priority": 2},
+		Vector:   vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)},
+	}
+	wire, err := RecordProto(&record, 4)
+	require.NoError(t, err)
+	data, err := proto.Marshal(wire)
+	require.NoError(t, err)
+
+	var decoded pb.DatabaseRecord
+	require.NoError(t, proto.Unmarshal(data, &decoded))
+	restored := RecordFromProto(&decoded)
+	assert.Equal(t, "tx-1", restored.ID)
+	assert.Equal(t, map[string]interface{}{"fromChain": "eth", "priority": float64(2)}, restored.Metadata)
+	for d := 0; d < 4; d++ {
+		assert.Equal(t, record.Vector.GetElement(d), restored.Vector.GetElement(d))
+	}
+	assert.Zero(t, restored.Vector.GetElement(4), "elements past those sent are zero")
+
+	tx := &Transaction{
+		ID:          "tx-2",
+		FromChain:   "eth",
+		ToChain:     "btc",
+		Metadata:    map[string]string{"memo": "hello"},
+		StateVector: vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)},
+		Fee:         1.5,
+		Priority:    3,
+	}
+	back := TransactionFromProto(tx.Proto(2))
+	assert.Equal(t, tx.ID, back.ID)
+	assert.Equal(t, tx.Metadata, back.Metadata)
+	assert.Equal(t, tx.Fee, back.Fee)
+	assert.Equal(t, tx.Priority, back.Priority)
+	assert.Equal(t, tx.StateVector.GetElement(1), back.StateVector.GetElement(1))
+
+	metrics := RouteMetrics{Speed: 0.9, Cost: 0.2, Similarity: 0.7, Health: 1}
+	assert.Equal(t, metrics, RouteMetricsFromProto(metrics.Proto()))
+	chain := ChainState{ID: "eth", Protocol: "ethereum", Endpoint: "http://localhost:8545", RegisteredAt: time.Unix(1700000000, 0).UTC()}
+	assert.Equal(t, chain, ChainStateFromProto(chain.Proto()))
+}
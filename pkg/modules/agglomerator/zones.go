@@ -0,0 +1,386 @@
+package agglomerator
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/theaxiomverse/hydap-api/pkg/keymanagement/pb"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+	"google.golang.org/protobuf/proto"
+)
+
+// Private chains belong to a privacy zone: a named group of nodes sharing a
+// symmetric key. Their registrations are sealed with the key and sent only
+// to peers that have proven they hold it, and only transactions from a chain
+// in the same zone are routed to them. Zones are named on the wire by a tag
+// derived from the key, so non-members do not learn their names.
+
+// zoneDataPrefix marks data transfers for a privacy zone: a membership
+// proof under zone/<tag>/member, or a sealed record under zone/<tag>/<id>
+const zoneDataPrefix = "zone/"
+
+const zoneMemberSuffix = "member"
+
+// zoneAnnounceInterval is how often membership proofs are sent to peers
+const zoneAnnounceInterval = time.Minute
+
+var (
+	ErrUnknownZone  = errors.New("unknown privacy zone")
+	ErrZoneMismatch = errors.New("chain is private to another zone")
+)
+
+type privacyZone struct {
+	name string
+	tag  string
+	key  []byte
+	aead cipher.AEAD
+}
+
+// PrivacyZones holds the zones a node belongs to and the peers proven to
+// share each one
+type PrivacyZones struct {
+	mu      sync.RWMutex
+	zones   map[string]*privacyZone // By name
+	tags    map[string]*privacyZone // By wire tag
+	members map[string]map[string]bool
+}
+
+func NewPrivacyZones() *PrivacyZones {
+	return &PrivacyZones{
+		zones:   make(map[string]*privacyZone),
+		tags:    make(map[string]*privacyZone),
+		members: make(map[string]map[string]bool),
+	}
+}
+
+// parseZoneKey decodes a base64 256-bit zone key
+func parseZoneKey(key string) ([]byte, error) {
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(decoded) != 32 {
+		return nil, errors.New("zone key must be 32 bytes of base64")
+	}
+	return decoded, nil
+}
+
+// GenerateZoneKey returns a new base64 zone key
+func GenerateZoneKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// Add joins a zone, replacing the key of one with the same name
+func (z *PrivacyZones) Add(name, key string) error {
+	if name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid zone name %q", name)
+	}
+	decoded, err := parseZoneKey(key)
+	if err != nil {
+		return fmt.Errorf("zone %s: %w", name, err)
+	}
+	block, err := aes.NewCipher(decoded)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	tag := zoneMAC(decoded, []byte("hydap zone tag"))
+	zone := &privacyZone{name: name, tag: hex.EncodeToString(tag[:16]), key: decoded, aead: aead}
+
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if previous, exists := z.zones[name]; exists {
+		delete(z.tags, previous.tag)
+		delete(z.members, name)
+	}
+	z.zones[name] = zone
+	z.tags[zone.tag] = zone
+	return nil
+}
+
+// Names returns the zones this node belongs to
+func (z *PrivacyZones) Names() []string {
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+	names := make([]string, 0, len(z.zones))
+	for name := range z.zones {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Has reports whether this node belongs to a zone
+func (z *PrivacyZones) Has(name string) bool {
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+	_, exists := z.zones[name]
+	return exists
+}
+
+// Members returns the peers proven to belong to a zone
+func (z *PrivacyZones) Members(name string) []string {
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+	members := make([]string, 0, len(z.members[name]))
+	for peerID := range z.members[name] {
+		members = append(members, peerID)
+	}
+	sort.Strings(members)
+	return members
+}
+
+// Forget drops a peer from every zone's members
+func (z *PrivacyZones) Forget(peerID string) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	for _, members := range z.members {
+		delete(members, peerID)
+	}
+}
+
+func (z *PrivacyZones) byName(name string) (*privacyZone, bool) {
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+	zone, exists := z.zones[name]
+	return zone, exists
+}
+
+func (z *PrivacyZones) byTag(tag string) (*privacyZone, bool) {
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+	zone, exists := z.tags[tag]
+	return zone, exists
+}
+
+// addMember records a proven member, reporting whether it is new
+func (z *PrivacyZones) addMember(name, peerID string) bool {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	members, exists := z.members[name]
+	if !exists {
+		members = make(map[string]bool)
+		z.members[name] = members
+	}
+	if members[peerID] {
+		return false
+	}
+	members[peerID] = true
+	return true
+}
+
+func zoneMAC(key []byte, parts ...[]byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	for _, part := range parts {
+		mac.Write(part)
+		mac.Write([]byte{0})
+	}
+	return mac.Sum(nil)
+}
+
+// proof shows the recipient that the sender holds the zone key; it is bound
+// to both node IDs so it cannot be replayed by another node
+func (zone *privacyZone) proof(senderID, recipientID string) []byte {
+	return zoneMAC(zone.key, []byte("hydap zone member"), []byte(senderID), []byte(recipientID))
+}
+
+// seal encrypts a record for the zone, bound to its ID
+func (zone *privacyZone) seal(recordID string, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, zone.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return zone.aead.Seal(nonce, nonce, plaintext, []byte(zone.tag+"/"+recordID)), nil
+}
+
+func (zone *privacyZone) open(recordID string, sealed []byte) ([]byte, error) {
+	if len(sealed) < zone.aead.NonceSize() {
+		return nil, errors.New("sealed record too short")
+	}
+	nonce, ciphertext := sealed[:zone.aead.NonceSize()], sealed[zone.aead.NonceSize():]
+	return zone.aead.Open(nil, nonce, ciphertext, []byte(zone.tag+"/"+recordID))
+}
+
+// Zones returns the privacy zones this node belongs to
+func (node *P2PInfiniteVectorNode) Zones() *PrivacyZones {
+	return node.zones
+}
+
+// StorePrivateData stores a record of a zone and sends it, sealed, to the
+// peers proven to belong to the zone. Private records stay out of the
+// record filter advertised to all peers.
+func (node *P2PInfiniteVectorNode) StorePrivateData(zoneName string, record *vectors.DatabaseRecord) error {
+	zone, exists := node.zones.byName(zoneName)
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrUnknownZone, zoneName)
+	}
+	record.Metadata["zone"] = zoneName
+	node.localDatabase.putPrivate(record)
+
+	if node.flagEnabled(FlagReplication) {
+		for _, peerID := range node.zones.Members(zoneName) {
+			node.sendPrivateRecord(zone, peerID, record)
+		}
+	}
+	return nil
+}
+
+// sendPrivateRecord seals a record for a zone member. As in serializeRecord,
+// only identity and metadata travel.
+func (node *P2PInfiniteVectorNode) sendPrivateRecord(zone *privacyZone, peerID string, record *vectors.DatabaseRecord) {
+	wire, err := RecordProto(record, 0)
+	if err != nil {
+		return
+	}
+	data, err := proto.Marshal(wire)
+	if err != nil {
+		return
+	}
+	sealed, err := zone.seal(record.ID, data)
+	if err != nil {
+		return
+	}
+	node.send(peerID, zoneDataPrefix+zone.tag+"/"+record.ID, sealed, PriorityControl)
+}
+
+// putPrivate stores a record without adding it to the advertised filter
+func (db *InfiniteVectorDatabase) putPrivate(record *vectors.DatabaseRecord) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.records[record.ID] = vectors.DatabaseRecord{ID: record.ID, Metadata: record.Metadata, Vector: record.Vector.Copy()}
+	db.storedAt[record.ID] = db.clock.Now()
+}
+
+// announceZones periodically proves this node's zone memberships to its
+// peers
+func (node *P2PInfiniteVectorNode) announceZones(ctx context.Context) {
+	ticker := time.NewTicker(zoneAnnounceInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		node.peerMutex.RLock()
+		peers := make([]string, 0, len(node.peers))
+		for peerID := range node.peers {
+			peers = append(peers, peerID)
+		}
+		node.peerMutex.RUnlock()
+
+		for _, name := range node.zones.Names() {
+			for _, peerID := range peers {
+				node.sendZoneProof(name, peerID)
+			}
+		}
+	}
+}
+
+func (node *P2PInfiniteVectorNode) sendZoneProof(name, peerID string) {
+	zone, exists := node.zones.byName(name)
+	if !exists {
+		return
+	}
+	node.send(peerID, zoneDataPrefix+zone.tag+"/"+zoneMemberSuffix, zone.proof(node.NodeID, peerID), PriorityControl)
+}
+
+// receiveZoneData handles a membership proof or a sealed record for a zone.
+// Data for zones this node is not in, and records from peers that have not
+// proven membership, are dropped.
+func (node *P2PInfiniteVectorNode) receiveZoneData(msg DataTransferMessage, path string) {
+	tag, id, ok := strings.Cut(path, "/")
+	if !ok {
+		return
+	}
+	zone, exists := node.zones.byTag(tag)
+	if !exists {
+		return
+	}
+
+	if id == zoneMemberSuffix {
+		if !hmac.Equal(msg.Payload, zone.proof(msg.SenderID, node.NodeID)) {
+			return
+		}
+		if node.zones.addMember(zone.name, msg.SenderID) {
+			// Answer a new member at once and share the zone's records
+			node.sendZoneProof(zone.name, msg.SenderID)
+			for _, record := range node.localDatabase.zoneRecords(zone.name) {
+				node.sendPrivateRecord(zone, msg.SenderID, record)
+			}
+		}
+		return
+	}
+
+	if !contains(node.zones.Members(zone.name), msg.SenderID) {
+		return
+	}
+	plaintext, err := zone.open(id, msg.Payload)
+	if err != nil {
+		return
+	}
+	var replica pb.DatabaseRecord
+	if err := proto.Unmarshal(plaintext, &replica); err != nil || replica.GetId() != id {
+		return
+	}
+	metadata := replica.GetMetadata().AsMap()
+	metadata["zone"] = zone.name
+	node.localDatabase.putPrivate(&vectors.DatabaseRecord{ID: id, Metadata: metadata})
+}
+
+// isPrivate reports whether a record's metadata puts it in a privacy zone
+func isPrivate(metadata map[string]interface{}) bool {
+	_, private := metadata["zone"]
+	return private
+}
+
+// zoneRecords returns the identity and metadata of a zone's stored records,
+// which is what is sent to members
+func (db *InfiniteVectorDatabase) zoneRecords(zone string) []*vectors.DatabaseRecord {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	var records []*vectors.DatabaseRecord
+	for id := range db.records {
+		if metadata := db.records[id].Metadata; metadata["zone"] == zone {
+			records = append(records, &vectors.DatabaseRecord{ID: id, Metadata: metadata})
+		}
+	}
+	return records
+}
+
+// chainVisible reports whether a chain may carry a transaction from a zone;
+// public chains carry any
+func chainVisible(chain *Chain, zone string) bool {
+	return chain.Zone == "" || chain.Zone == zone
+}
+
+// transactionZone returns the zone of a transaction's source chain, local or
+// found among candidates; the caller holds p.mu
+func (p *P2PAgglomerator) transactionZone(tx *Transaction, candidates []*Chain) string {
+	if chain, exists := p.chains[tx.FromChain]; exists {
+		return chain.Zone
+	}
+	for _, chain := range candidates {
+		if chain.ID == tx.FromChain {
+			return chain.Zone
+		}
+	}
+	return ""
+}
@@ -0,0 +1,92 @@
+package agglomerator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/vectors"
+)
+
+func TestPrivacyZoneMembershipAndRecords(t *testing.T) {
+	key, err := GenerateZoneKey()
+	require.NoError(t, err)
+	otherKey, err := GenerateZoneKey()
+	require.NoError(t, err)
+
+	a := NewP2PInfiniteVectorNode("127.0.0.1", 0)
+	b := NewP2PInfiniteVectorNode("127.0.0.1", 0)
+	outsider := NewP2PInfiniteVectorNode("127.0.0.1", 0)
+	require.NoError(t, a.Zones().Add("consortium", key))
+	require.NoError(t, b.Zones().Add("consortium", key))
+	require.NoError(t, outsider.Zones().Add("consortium", otherKey))
+	assert.Error(t, a.Zones().Add("bad", "c2hvcnQ="), "keys must be 32 bytes")
+
+	zone, _ := a.zones.byName("consortium")
+	deliver := func(to *P2PInfiniteVectorNode, from, dataID string, payload []byte) {
+		path := dataID[len(zoneDataPrefix):]
+		to.receiveZoneData(DataTransferMessage{SenderID: from, RecipientID: to.NodeID, DataID: dataID, Payload: payload}, path)
+	}
+
+	// A proof under another key, or made for another recipient, is ignored
+	wrong, _ := outsider.zones.byName("consortium")
+	deliver(b, outsider.NodeID, zoneDataPrefix+zone.tag+"/"+zoneMemberSuffix, wrong.proof(outsider.NodeID, b.NodeID))
+	deliver(b, a.NodeID, zoneDataPrefix+zone.tag+"/"+zoneMemberSuffix, zone.proof(a.NodeID, outsider.NodeID))
+	assert.Empty(t, b.Zones().Members("consortium"))
+	assert.NotEqual(t, zone.tag, wrong.tag, "zones with other keys have other tags")
+
+	deliver(b, a.NodeID, zoneDataPrefix+zone.tag+"/"+zoneMemberSuffix, zone.proof(a.NodeID, b.NodeID))
+	assert.Equal(t, []string{a.NodeID}, b.Zones().Members("consortium"))
+
+	record := vectors.DatabaseRecord{ID: "settlement", Metadata: map[string]interface{}{
+		"protocol": ProtocolEthereum,
+		"endpoint": "https://rpc.internal",
+		"type":     "chain_registration",
+	}}
+	sealed, err := zone.seal(record.ID, a.serializeRecord(vectors.DatabaseRecord{ID: record.ID, Metadata: record.Metadata}))
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed), "rpc.internal")
+
+	_, err = zone.open("other", sealed)
+	assert.Error(t, err, "sealed records are bound to their ID")
+	deliver(b, outsider.NodeID, zoneDataPrefix+zone.tag+"/"+record.ID, sealed)
+	assert.NotContains(t, b.localDatabase.records, record.ID, "records from non-members are dropped")
+
+	deliver(b, a.NodeID, zoneDataPrefix+zone.tag+"/"+record.ID, sealed)
+	require.Contains(t, b.localDatabase.records, record.ID)
+	stored := b.localDatabase.records[record.ID].Metadata
+	assert.Equal(t, "consortium", stored["zone"])
+	assert.Equal(t, "https://rpc.internal", stored["endpoint"])
+	assert.False(t, b.localDatabase.filter.Filter().MayContain(record.ID), "private records are not advertised")
+
+	b.dropPeer(a.NodeID)
+	assert.Empty(t, b.Zones().Members("consortium"))
+}
+
+func TestPrivateChainsOnlyCarryTheirZone(t *testing.T) {
+	agg := NewAgglomerator(AgglomeratorConfig{})
+	for id, zone := range map[string]string{"public": "", "private": "consortium", "member": "consortium", "rival": "other"} {
+		chain := NewChain(id, "http://localhost:8545", ProtocolEthereum)
+		chain.Zone = zone
+		chain.StateVector = vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}
+		require.NoError(t, agg.RegisterChain(chain))
+	}
+	newTx := func(id, from, to string) *Transaction {
+		return &Transaction{ID: id, FromChain: from, ToChain: to,
+			StateVector: vectors.InfiniteVector{Generator: getProtocolGenerator(ProtocolEthereum)}}
+	}
+
+	_, err := agg.recordTransaction(newTx("tx-1", "public", "private"))
+	assert.ErrorIs(t, err, ErrZoneMismatch)
+	_, err = agg.recordTransaction(newTx("tx-2", "rival", "private"))
+	assert.ErrorIs(t, err, ErrZoneMismatch)
+	_, err = agg.recordTransaction(newTx("tx-3", "member", "private"))
+	assert.NoError(t, err)
+	_, err = agg.recordTransaction(newTx("tx-4", "private", "public"))
+	assert.NoError(t, err, "private chains may send to public ones")
+
+	p2p := NewP2PAgglomeratorWithNode(AgglomeratorConfig{}, NewP2PInfiniteVectorNode("127.0.0.1", 0))
+	chain := NewChain("hidden", "http://localhost:8545", ProtocolEthereum)
+	chain.Zone = "consortium"
+	assert.ErrorIs(t, p2p.RegisterChain(chain), ErrUnknownZone, "private chains need a configured zone")
+}
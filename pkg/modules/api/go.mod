@@ -1,4 +1,14 @@
 module github.com/theaxiomverse/hydap-api/pkg/modules/api
 
 go 1.23
-require github.com/go-chi/chi/v5 v5.2.0
+
+require (
+	github.com/go-chi/chi/v5 v5.2.0
+	github.com/stretchr/testify v1.9.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
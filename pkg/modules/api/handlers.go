@@ -3,18 +3,31 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/go-chi/chi/v5"
 	"github.com/theaxiomverse/hydap-api/pkg/modules/base"
 	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
 	"net/http"
+	"strconv"
 )
 
 type ModuleAPI struct {
 	registry *core.ModuleRegistry
 	config   *core.ConfigManager
 	metrics  *core.MetricsExporter
+	tokens   *core.TokenStore // Nil unless token auth is enabled
+
+	diagnostics *core.Diagnostics // Nil disables the diagnostics route
+	dumper      *core.CrashDumper // Nil disables the crash dump route
+	validate    ConfigValidator   // Nil only checks configs are JSON objects
+
+	desired *core.DesiredStateReconciler // Nil disables the desired state routes
 }
 
+// ConfigValidator checks a module's config without loading the module,
+// returning every problem found
+type ConfigValidator func(module string, config map[string]interface{}) []error
+
 func NewModuleAPI(registry *core.ModuleRegistry, config *core.ConfigManager, metrics *core.MetricsExporter) *ModuleAPI {
 	return &ModuleAPI{
 		registry: registry,
@@ -23,14 +36,49 @@ func NewModuleAPI(registry *core.ModuleRegistry, config *core.ConfigManager, met
 	}
 }
 
+// SetTokenStore enables the token management routes
+func (api *ModuleAPI) SetTokenStore(tokens *core.TokenStore) {
+	api.tokens = tokens
+}
+
+// SetDiagnostics enables the diagnostics route
+func (api *ModuleAPI) SetDiagnostics(diagnostics *core.Diagnostics) {
+	api.diagnostics = diagnostics
+}
+
+// SetCrashDumper enables the crash dump route
+func (api *ModuleAPI) SetCrashDumper(dumper *core.CrashDumper) {
+	api.dumper = dumper
+}
+
+// SetDesiredState enables the desired state routes
+func (api *ModuleAPI) SetDesiredState(desired *core.DesiredStateReconciler) {
+	api.desired = desired
+}
+
+// SetConfigValidator checks each config applied in bulk with validate
+func (api *ModuleAPI) SetConfigValidator(validate ConfigValidator) {
+	api.validate = validate
+}
+
 func (api *ModuleAPI) ListModules(w http.ResponseWriter, r *http.Request) {
+	if core.IsWatch(r) {
+		core.ServeWatch(w, r, func() (map[string]interface{}, error) {
+			modules := make(map[string]interface{})
+			for _, info := range api.registry.List() {
+				modules[info.Name] = info
+			}
+			return modules, nil
+		}, nil)
+		return
+	}
 	modules := api.registry.List()
 	json.NewEncoder(w).Encode(modules)
 }
 
 func (api *ModuleAPI) GetHealth(w http.ResponseWriter, r *http.Request) {
 	name := chi.URLParam(r, "name")
-	health := api.registry.GetAllHealth()[name]
+	health := api.registry.GetAllHealth(r.Context())[name]
 	json.NewEncoder(w).Encode(health)
 }
 
@@ -48,6 +96,126 @@ func (api *ModuleAPI) UpdateConfig(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// BulkUpdateConfig applies a map of module name to config all at once. Every
+// config is validated first; if any fails, none is stored and the problems
+// are returned by module with 422.
+func (api *ModuleAPI) BulkUpdateConfig(w http.ResponseWriter, r *http.Request) {
+	var configs map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&configs); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(configs) == 0 {
+		http.Error(w, "no module configs", http.StatusBadRequest)
+		return
+	}
+
+	problems := make(map[string][]string)
+	for name, config := range configs {
+		if found := api.checkConfig(name, config); len(found) > 0 {
+			problems[name] = found
+		}
+	}
+	if len(problems) > 0 {
+		respondConfigProblems(w, "invalid module configs; none were applied", problems)
+		return
+	}
+
+	revisions, err := api.config.SetConfigs(configs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"revisions": revisions})
+}
+
+// ListConfigRevisions lists the stored revisions of a module's config
+func (api *ModuleAPI) ListConfigRevisions(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	revisions, err := api.config.ListConfigRevisions(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// DiffConfig compares two config revisions. ?to defaults to the latest
+// revision and ?from to the one before it.
+func (api *ModuleAPI) DiffConfig(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	to, err := revisionParam(r, "to")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if to == 0 {
+		if to, err = api.config.LatestConfigRevision(name); err != nil {
+			respondRevisionError(w, err)
+			return
+		}
+	}
+	from, err := revisionParam(r, "from")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if from == 0 {
+		from = to - 1
+	}
+
+	fromRevision, err := api.config.GetConfigRevision(name, from)
+	if err != nil {
+		respondRevisionError(w, err)
+		return
+	}
+	toRevision, err := api.config.GetConfigRevision(name, to)
+	if err != nil {
+		respondRevisionError(w, err)
+		return
+	}
+
+	changes, err := core.DiffConfig(fromRevision.Config, toRevision.Config)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fromRevision.Config, toRevision.Config = nil, nil
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"module":  name,
+		"from":    fromRevision,
+		"to":      toRevision,
+		"changes": changes,
+	})
+}
+
+// revisionParam reads a revision number from the query, returning 0 when
+// it is absent
+func revisionParam(r *http.Request, param string) (int, error) {
+	value := r.URL.Query().Get(param)
+	if value == "" {
+		return 0, nil
+	}
+	revision, err := strconv.Atoi(value)
+	if err != nil || revision < 1 {
+		return 0, errors.New("invalid " + param + " revision")
+	}
+	return revision, nil
+}
+
+func respondRevisionError(w http.ResponseWriter, err error) {
+	if errors.Is(err, core.ErrConfigRevisionNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
 func (api *ModuleAPI) AddModule(w http.ResponseWriter, r *http.Request) {
 	var config base.ModuleConfig
 	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
@@ -55,13 +223,20 @@ func (api *ModuleAPI) AddModule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	timeouts, err := core.TimeoutsFromConfig(config.Config)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	mod, err := api.registry.Loader.LoadFromConfig(config)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if err := api.registry.RegisterWithDeps(mod, config.DependsOn); err != nil {
+	api.registry.SetTimeouts(mod.Name(), timeouts)
+	if err := api.registry.RegisterWithDeps(r.Context(), mod, config.DependsOn); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -70,6 +245,32 @@ func (api *ModuleAPI) AddModule(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusCreated)
 }
 
+// ValidateModule dry-runs a module config without registering it, returning
+// 200 when it would load or 422 with the errors when it would not
+func (api *ModuleAPI) ValidateModule(w http.ResponseWriter, r *http.Request) {
+	var config base.ModuleConfig
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := api.registry.DryRun(r.Context(), config)
+	if errors.Is(err, core.ErrDryRunUnsupported) {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !result.Valid {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
 func (api *ModuleAPI) GetModule(w http.ResponseWriter, r *http.Request) {
 	name := chi.URLParam(r, "name")
 	mod, exists := api.registry.Get(name)
@@ -80,9 +281,39 @@ func (api *ModuleAPI) GetModule(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(mod)
 }
 
+// DeleteModule terminates a module. It answers 409 with the dependents if
+// other modules need it, unless ?cascade=true asks for them to be torn down
+// first.
 func (api *ModuleAPI) DeleteModule(w http.ResponseWriter, r *http.Request) {
 	name := chi.URLParam(r, "name")
-	if err := api.registry.Terminate(name); err != nil {
+	if _, exists := api.registry.Get(name); !exists {
+		http.Error(w, "module not found", http.StatusNotFound)
+		return
+	}
+
+	if r.URL.Query().Get("cascade") == "true" {
+		terminated, err := api.registry.TerminateCascade(r.Context(), name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string][]string{"terminated": terminated})
+		return
+	}
+
+	err := api.registry.Terminate(r.Context(), name)
+	var dependentsErr *core.DependentsError
+	if errors.As(err, &dependentsErr) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":      err.Error(),
+			"dependents": dependentsErr.Dependents,
+		})
+		return
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -91,30 +322,416 @@ func (api *ModuleAPI) DeleteModule(w http.ResponseWriter, r *http.Request) {
 
 func (api *ModuleAPI) StartModule(w http.ResponseWriter, r *http.Request) {
 	name := chi.URLParam(r, "name")
-	mod, exists := api.registry.Get(name)
-	if !exists {
+	if _, exists := api.registry.Get(name); !exists {
 		http.Error(w, "module not found", http.StatusNotFound)
 		return
 	}
 
-	if err := mod.Initialize(); err != nil {
+	result, err := api.registry.Start(r.Context(), name)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
 }
 
 func (api *ModuleAPI) StopModule(w http.ResponseWriter, r *http.Request) {
 	name := chi.URLParam(r, "name")
-	mod, exists := api.registry.Get(name)
-	if !exists {
+	if _, exists := api.registry.Get(name); !exists {
 		http.Error(w, "module not found", http.StatusNotFound)
 		return
 	}
 
-	if err := mod.Terminate(); err != nil {
+	result, err := api.registry.Stop(r.Context(), name)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// ListPanics lists the panics recently recovered from modules
+func (api *ModuleAPI) ListPanics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.registry.Panics())
+}
+
+// GetVersion reports the build and the API versions it serves
+func (api *ModuleAPI) GetVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(core.GetBuildInfo())
+}
+
+// GetDiagnostics runs the self-diagnostics. A report with a failed check is
+// served with 503 so it can back a readiness probe.
+func (api *ModuleAPI) GetDiagnostics(w http.ResponseWriter, r *http.Request) {
+	if api.diagnostics == nil {
+		http.Error(w, "diagnostics not enabled", http.StatusNotFound)
+		return
+	}
+
+	report := api.diagnostics.Run(r.Context())
+	status := http.StatusOK
+	if report.Status == core.CheckFail {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// GetCrashDump captures the bundle written on fatal errors, for support
+// cases, without stopping the node
+func (api *ModuleAPI) GetCrashDump(w http.ResponseWriter, r *http.Request) {
+	if api.dumper == nil {
+		http.Error(w, "crash dumps not enabled", http.StatusNotFound)
+		return
+	}
+
+	reason := r.URL.Query().Get("reason")
+	if reason == "" {
+		reason = "requested"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="hydap-dump.json"`)
+	json.NewEncoder(w).Encode(api.dumper.Capture(reason))
+}
+
+// ListFlags lists the feature flags, evaluated for the node given as the
+// node query parameter
+func (api *ModuleAPI) ListFlags(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.config.Flags().List(r.URL.Query().Get("node")))
+}
+
+// SetFlag stores a feature flag's setting; it applies without a restart
+func (api *ModuleAPI) SetFlag(w http.ResponseWriter, r *http.Request) {
+	var flag core.FeatureFlag
+	if err := json.NewDecoder(r.Body).Decode(&flag); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	flag.Name = chi.URLParam(r, "name")
+
+	if err := api.config.Flags().Set(flag); err != nil {
+		if errors.Is(err, core.ErrFlagNotDefined) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(flag)
+}
+
+// ResetFlag returns a feature flag to its default
+func (api *ModuleAPI) ResetFlag(w http.ResponseWriter, r *http.Request) {
+	if err := api.config.Flags().Reset(chi.URLParam(r, "name")); err != nil {
+		if errors.Is(err, core.ErrFlagNotDefined) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetDesiredState returns the stored desired state and its revision
+func (api *ModuleAPI) GetDesiredState(w http.ResponseWriter, r *http.Request) {
+	if api.desired == nil {
+		http.Error(w, "desired state not enabled", http.StatusNotFound)
+		return
+	}
+	desired, revision, err := api.desired.Get()
+	if err != nil {
+		respondDesiredStateError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"revision": revision,
+		"state":    desired,
+	})
+}
+
+// UpdateDesiredState stores a desired state document and reconciles it at
+// once, returning the drift report. Module configs are validated as in
+// bulk updates; if any fails, nothing is stored.
+func (api *ModuleAPI) UpdateDesiredState(w http.ResponseWriter, r *http.Request) {
+	if api.desired == nil {
+		http.Error(w, "desired state not enabled", http.StatusNotFound)
+		return
+	}
+	var desired core.DesiredState
+	if err := json.NewDecoder(r.Body).Decode(&desired); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var configs map[string]json.RawMessage
+	if section, exists := desired.Sections[core.ModulesSection]; exists && json.Unmarshal(section, &configs) == nil {
+		problems := make(map[string][]string)
+		for name, config := range configs {
+			if found := api.checkConfig(name, config); len(found) > 0 {
+				problems[name] = found
+			}
+		}
+		if len(problems) > 0 {
+			respondConfigProblems(w, "invalid module configs; the desired state was not stored", problems)
+			return
+		}
+	}
+
+	if _, err := api.desired.Set(desired); err != nil {
+		respondDesiredStateError(w, err)
+		return
+	}
+	api.reconcile(w, r, false)
+}
+
+// GetDrift returns the report of the last reconciliation
+func (api *ModuleAPI) GetDrift(w http.ResponseWriter, r *http.Request) {
+	if api.desired == nil {
+		http.Error(w, "desired state not enabled", http.StatusNotFound)
+		return
+	}
+	report, ok := api.desired.LastReport()
+	if !ok {
+		http.Error(w, "the desired state has not been reconciled", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// ReconcileDesiredState reconciles the desired state now. With ?dryRun=true
+// drift is only reported, and the report is not kept as the last one.
+func (api *ModuleAPI) ReconcileDesiredState(w http.ResponseWriter, r *http.Request) {
+	if api.desired == nil {
+		http.Error(w, "desired state not enabled", http.StatusNotFound)
+		return
+	}
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+	api.reconcile(w, r, dryRun)
+}
+
+func (api *ModuleAPI) reconcile(w http.ResponseWriter, r *http.Request, dryRun bool) {
+	report, err := api.desired.Reconcile(r.Context(), dryRun)
+	if err != nil {
+		respondDesiredStateError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// ListTokens lists the issued API tokens, without their secrets
+func (api *ModuleAPI) ListTokens(w http.ResponseWriter, r *http.Request) {
+	if api.tokens == nil {
+		http.Error(w, "token auth not enabled", http.StatusNotFound)
+		return
+	}
+	tokens, err := api.tokens.List()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// IssueToken creates a token scoped to the given modules, or an unscoped one
+// when none are given. The secret is only returned here.
+func (api *ModuleAPI) IssueToken(w http.ResponseWriter, r *http.Request) {
+	if api.tokens == nil {
+		http.Error(w, "token auth not enabled", http.StatusNotFound)
+		return
+	}
+	var req struct {
+		Name    string   `json:"name"`
+		Modules []string `json:"modules"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	token, secret, err := api.tokens.Issue(req.Name, req.Modules)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token":  token,
+		"secret": secret,
+	})
+}
+
+func (api *ModuleAPI) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	if api.tokens == nil {
+		http.Error(w, "token auth not enabled", http.StatusNotFound)
+		return
+	}
+	err := api.tokens.Revoke(chi.URLParam(r, "id"))
+	if errors.Is(err, core.ErrTokenNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListPresets lists the stored config presets, without their configs
+func (api *ModuleAPI) ListPresets(w http.ResponseWriter, r *http.Request) {
+	presets, err := api.config.ListPresets()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(presets)
+}
+
+func (api *ModuleAPI) GetPreset(w http.ResponseWriter, r *http.Request) {
+	preset, err := api.config.GetPreset(chi.URLParam(r, "name"))
+	if err != nil {
+		respondPresetError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preset)
+}
+
+// SavePreset stores a preset under the name in the path, replacing any
+// preset of that name. Its config is checked as the module's would be.
+func (api *ModuleAPI) SavePreset(w http.ResponseWriter, r *http.Request) {
+	var preset core.ConfigPreset
+	if err := json.NewDecoder(r.Body).Decode(&preset); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	preset.Name = chi.URLParam(r, "name")
+	if preset.Module != "" {
+		if problems := api.checkConfig(preset.Module, preset.Config); len(problems) > 0 {
+			respondConfigProblems(w, "invalid preset config", map[string][]string{preset.Module: problems})
+			return
+		}
+	}
+
+	if err := api.config.SavePreset(preset); err != nil {
+		respondPresetError(w, err)
+		return
+	}
+	saved, err := api.config.GetPreset(preset.Name)
+	if err != nil {
+		respondPresetError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(saved)
+}
+
+func (api *ModuleAPI) DeletePreset(w http.ResponseWriter, r *http.Request) {
+	if err := api.config.DeletePreset(chi.URLParam(r, "name")); err != nil {
+		respondPresetError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ApplyPreset makes the preset's module config from the preset with the
+// request's overrides merged in, as a JSON merge patch, and stores it as a
+// new revision. With ?dryRun=true the config is returned without storing it.
+func (api *ModuleAPI) ApplyPreset(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Overrides json.RawMessage `json:"overrides"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if string(req.Overrides) == "null" {
+		req.Overrides = nil
+	}
+
+	module, config, err := api.config.InstantiatePreset(chi.URLParam(r, "name"), req.Overrides)
+	if err != nil {
+		respondPresetError(w, err)
+		return
+	}
+	if problems := api.checkConfig(module, config); len(problems) > 0 {
+		respondConfigProblems(w, "invalid module config; it was not applied", map[string][]string{module: problems})
+		return
+	}
+
+	result := map[string]interface{}{"module": module, "config": config}
+	if r.URL.Query().Get("dryRun") != "true" {
+		revisions, err := api.config.SetConfigs(map[string]json.RawMessage{module: config})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result["revision"] = revisions[module]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// checkConfig returns the problems with a module's config: that it is not a
+// JSON object, or what the config validator finds
+func (api *ModuleAPI) checkConfig(module string, config json.RawMessage) []string {
+	var section map[string]interface{}
+	if err := json.Unmarshal(config, &section); err != nil || section == nil {
+		return []string{"config must be a JSON object"}
+	}
+	if api.validate == nil {
+		return nil
+	}
+	var problems []string
+	for _, err := range api.validate(module, section) {
+		problems = append(problems, err.Error())
+	}
+	return problems
+}
+
+func respondConfigProblems(w http.ResponseWriter, message string, problems map[string][]string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":    message,
+		"problems": problems,
+	})
+}
+
+func respondDesiredStateError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, core.ErrNoDesiredState):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, core.ErrInvalidDesiredState):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func respondPresetError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, core.ErrPresetNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, core.ErrInvalidPreset):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
 }
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/base"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+// newTestAPI serves a registry holding the given modules, each registered
+// with its dependencies
+func newTestAPI(t *testing.T, modules map[string][]string, order ...string) (*core.ModuleRegistry, *httptest.Server) {
+	t.Helper()
+	registry := core.NewModuleRegistry(nil)
+	for _, name := range order {
+		metadata := base.NewModuleMetadata(name, "1.0.0", "test module", "test", "MIT")
+		require.NoError(t, registry.RegisterWithDeps(context.Background(), base.CreateNewModule(metadata, nil), modules[name]))
+	}
+
+	server := httptest.NewServer(NewModuleAPI(registry, nil, core.NewMetricsExporter()).Router())
+	t.Cleanup(server.Close)
+	return registry, server
+}
+
+func deleteModule(t *testing.T, server *httptest.Server, path string, out interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodDelete, server.URL+path, nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	if out != nil {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp.StatusCode
+}
+
+func TestDeleteModuleCascade(t *testing.T) {
+	registry, server := newTestAPI(t, map[string][]string{
+		"index":   {"storage"},
+		"gateway": {"index"},
+		"metrics": {"storage"},
+	}, "storage", "index", "gateway", "metrics", "standalone")
+
+	// Modules others depend on are refused with their direct dependents
+	var conflict struct {
+		Error      string   `json:"error"`
+		Dependents []string `json:"dependents"`
+	}
+	assert.Equal(t, http.StatusConflict, deleteModule(t, server, "/modules/storage", &conflict))
+	assert.Equal(t, []string{"index", "metrics"}, conflict.Dependents)
+	assert.Equal(t, "module storage is required by index, metrics", conflict.Error)
+	_, exists := registry.Get("storage")
+	assert.True(t, exists)
+
+	assert.Equal(t, http.StatusNoContent, deleteModule(t, server, "/modules/standalone", nil))
+	assert.Equal(t, http.StatusNotFound, deleteModule(t, server, "/modules/standalone", nil))
+
+	// A cascade tears dependents down before the modules they depend on
+	var cascade struct {
+		Terminated []string `json:"terminated"`
+	}
+	assert.Equal(t, http.StatusOK, deleteModule(t, server, "/modules/storage?cascade=true", &cascade))
+	require.Len(t, cascade.Terminated, 4)
+	position := make(map[string]int)
+	for i, name := range cascade.Terminated {
+		position[name] = i
+	}
+	assert.Less(t, position["gateway"], position["index"])
+	assert.Less(t, position["index"], position["storage"])
+	assert.Less(t, position["metrics"], position["storage"])
+	assert.Empty(t, registry.List())
+}
+
+func TestListPanics(t *testing.T) {
+	registry, server := newTestAPI(t, nil, "gateway")
+
+	resp, err := http.Get(server.URL + "/modules/panics")
+	require.NoError(t, err)
+	var panics []core.PanicRecord
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&panics))
+	resp.Body.Close()
+	assert.Empty(t, panics)
+
+	handler := registry.Recover("gateway")(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("route table corrupted")
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/routes", nil))
+
+	resp, err = http.Get(server.URL + "/modules/panics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&panics))
+	require.Len(t, panics, 1)
+	assert.Equal(t, "gateway", panics[0].Module)
+	assert.Equal(t, "GET /routes", panics[0].Operation)
+	assert.Equal(t, "route table corrupted", panics[0].Value)
+
+	// The module reports the panic that made it unhealthy
+	var health core.ModuleHealth
+	resp, err = http.Get(server.URL + "/modules/gateway/health")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&health))
+	assert.Equal(t, "unhealthy", health.Status)
+	require.NotNil(t, health.Panic)
+	assert.Equal(t, "GET /routes", health.Panic.Operation)
+}
@@ -1,20 +1,77 @@
 package api
 
-import "github.com/go-chi/chi/v5"
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
 
 func (api *ModuleAPI) Router() chi.Router {
 	r := chi.NewRouter()
 
-	r.Get("/modules", api.ListModules)
+	r.Get("/version", api.GetVersion)
+	r.With(core.ETag).Get("/modules", api.ListModules)
 	r.Post("/modules", api.AddModule)
+	r.Post("/modules/validate", api.ValidateModule)
+	r.Put("/modules/config/bulk", api.BulkUpdateConfig)
+	r.Get("/modules/panics", api.ListPanics)
 	r.Route("/modules/{name}", func(r chi.Router) {
 		r.Get("/", api.GetModule)
 		r.Get("/health", api.GetHealth)
 		r.Put("/config", api.UpdateConfig)
+		r.With(core.ETag).Get("/config/revisions", api.ListConfigRevisions)
+		r.Get("/config/diff", api.DiffConfig)
 		r.Delete("/", api.DeleteModule)
 		r.Post("/start", api.StartModule)
 		r.Post("/stop", api.StopModule)
 	})
 
+	r.Get("/presets", api.ListPresets)
+	r.Route("/presets/{name}", func(r chi.Router) {
+		r.Get("/", api.GetPreset)
+		r.Put("/", api.SavePreset)
+		r.Delete("/", api.DeletePreset)
+		r.Post("/apply", api.ApplyPreset)
+	})
+
+	r.Get("/flags", api.ListFlags)
+	r.Put("/flags/{name}", api.SetFlag)
+	r.Delete("/flags/{name}", api.ResetFlag)
+
+	r.Get("/desired-state", api.GetDesiredState)
+	r.Put("/desired-state", api.UpdateDesiredState)
+	r.Get("/desired-state/drift", api.GetDrift)
+	r.Post("/desired-state/reconcile", api.ReconcileDesiredState)
+
+	r.Get("/admin/diagnostics", api.GetDiagnostics)
+	r.Get("/admin/dump", api.GetCrashDump)
+
+	r.Get("/tokens", api.ListTokens)
+	r.Post("/tokens", api.IssueToken)
+	r.Delete("/tokens/{id}", api.RevokeToken)
+
 	return r
 }
+
+// FixedModuleRoutes lists the routes under /modules whose first segment is
+// not a module name, such as "validate" for /modules/validate
+func FixedModuleRoutes() []string {
+	seen := make(map[string]bool)
+	chi.Walk((&ModuleAPI{}).Router(), func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		rest, found := strings.CutPrefix(route, "/modules/")
+		if name, _, _ := strings.Cut(rest, "/"); found && name != "" && !strings.HasPrefix(name, "{") {
+			seen[name] = true
+		}
+		return nil
+	})
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
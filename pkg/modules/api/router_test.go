@@ -0,0 +1,11 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixedModuleRoutes(t *testing.T) {
+	assert.Equal(t, []string{"config", "panics", "validate"}, FixedModuleRoutes())
+}
@@ -8,15 +8,20 @@ import (
 
 // pkg/modules/base/module.go
 
-type Module interface {
+// Identity is implemented by every generation of module
+type Identity interface {
 	Name() string
-	Initialize() error
-	Terminate() error
 	Signature() string
-	HealthCheck() error
 	GetState() ModuleState
 	Version() string
 }
+
+type Module interface {
+	Identity
+	Initialize() error
+	Terminate() error
+	HealthCheck() error
+}
 type ModuleConfig struct {
 	Name      string
 	Version   string
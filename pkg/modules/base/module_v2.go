@@ -0,0 +1,65 @@
+package base
+
+import "context"
+
+// ModuleV2 is a module whose lifecycle methods take a context, which carries
+// the deadline the registry allows them, and report what they did. The
+// registry prefers it to Module; Adapt lets a Module be driven the same way.
+type ModuleV2 interface {
+	Identity
+	Initialize(ctx context.Context) (LifecycleResult, error)
+	Terminate(ctx context.Context) (LifecycleResult, error)
+	HealthCheck(ctx context.Context) HealthResult
+}
+
+// LifecycleResult reports the outcome of Initialize or Terminate
+type LifecycleResult struct {
+	State    ModuleState            `json:"state"`
+	Message  string                 `json:"message,omitempty"`
+	Warnings []string               `json:"warnings,omitempty"` // Problems that did not stop the operation
+	Details  map[string]interface{} `json:"details,omitempty"`
+}
+
+// HealthResult reports a module's health, component by component
+type HealthResult struct {
+	Healthy bool              `json:"healthy"`
+	Message string            `json:"message,omitempty"` // Why the module is unhealthy
+	Checks  map[string]string `json:"checks,omitempty"`  // Component to problem, or "ok"
+}
+
+// Adapt returns module as a ModuleV2: itself if it is one, or a wrapper
+// that calls a Module's methods and reports its state afterwards. It
+// returns nil for a value that is neither.
+func Adapt(module Identity) ModuleV2 {
+	switch m := module.(type) {
+	case ModuleV2:
+		return m
+	case Module:
+		return moduleAdapter{m}
+	default:
+		return nil
+	}
+}
+
+// moduleAdapter drives a Module through ModuleV2. The Module cannot observe
+// the context, so it is only abandoned at the deadline, not stopped.
+type moduleAdapter struct {
+	Module
+}
+
+func (a moduleAdapter) Initialize(ctx context.Context) (LifecycleResult, error) {
+	err := a.Module.Initialize()
+	return LifecycleResult{State: a.GetState()}, err
+}
+
+func (a moduleAdapter) Terminate(ctx context.Context) (LifecycleResult, error) {
+	err := a.Module.Terminate()
+	return LifecycleResult{State: a.GetState()}, err
+}
+
+func (a moduleAdapter) HealthCheck(ctx context.Context) HealthResult {
+	if err := a.Module.HealthCheck(); err != nil {
+		return HealthResult{Message: err.Error()}
+	}
+	return HealthResult{Healthy: true}
+}
@@ -0,0 +1,187 @@
+package compression
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/agglomerator"
+)
+
+const (
+	EncodingFloats = "floats"
+	EncodingBytes  = "bytes"
+
+	// maxRequestSize bounds request bodies, so a block's components are
+	// never buffered past it
+	maxRequestSize = 32 << 20
+)
+
+type API struct {
+	module *CompressionModule
+}
+
+func NewAPI(module *CompressionModule) *API {
+	return &API{module: module}
+}
+
+func (api *API) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Post("/compress", api.Compress)
+	r.Get("/compress/stats", api.GetStats)
+	r.Post("/decompress", api.Decompress)
+
+	return r
+}
+
+// CompressRequest carries a float array to compress
+type CompressRequest struct {
+	Data []float64 `json:"data"`
+}
+
+// CompressResponse wraps a compressed block with the encoding of its source
+type CompressResponse struct {
+	Encoding string                        `json:"encoding"`
+	Block    *agglomerator.CompressedBlock `json:"block"`
+}
+
+// DecompressRequest is the body accepted by the decompress endpoint
+type DecompressRequest struct {
+	Encoding string                        `json:"encoding"`
+	Block    *agglomerator.CompressedBlock `json:"block"`
+}
+
+// respondJSON is a helper function to send JSON responses
+func respondJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if data != nil {
+		if err := json.NewEncoder(w).Encode(data); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// respondError is a helper function to send error responses
+func respondError(w http.ResponseWriter, code int, message string) {
+	respondJSON(w, code, map[string]string{"error": message})
+}
+
+// Compress accepts either a JSON float array or raw bytes
+// (Content-Type: application/octet-stream) and returns a compressed block
+func (api *API) Compress(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	var (
+		block    *agglomerator.CompressedBlock
+		encoding string
+		err      error
+	)
+
+	if r.Header.Get("Content-Type") == "application/octet-stream" {
+		raw, readErr := io.ReadAll(r.Body)
+		if readErr != nil {
+			respondError(w, http.StatusBadRequest, "failed to read request body")
+			return
+		}
+		block, err = api.module.CompressBytes(raw)
+		encoding = EncodingBytes
+	} else {
+		var req CompressRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		block, err = api.module.Compress(req.Data)
+		encoding = EncodingFloats
+	}
+	if err != nil {
+		respondError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, CompressResponse{
+		Encoding: encoding,
+		Block:    block,
+	})
+}
+
+// Decompress reconstructs a block, returning raw bytes when the block
+// was produced from a bytes payload. Bytes are streamed a row at a time.
+func (api *API) Decompress(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	var req DecompressRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondError(w, http.StatusRequestEntityTooLarge, "block too large")
+			return
+		}
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if req.Encoding == EncodingBytes {
+		// Validation runs before the first row, so errors can still be
+		// reported with a status
+		started := false
+		err := api.module.DecompressRows(req.Block, func(values []float64) error {
+			if !started {
+				w.Header().Set("Content-Type", "application/octet-stream")
+				w.WriteHeader(http.StatusOK)
+				started = true
+			}
+			_, err := w.Write(floatsToBytes(values))
+			return err
+		})
+		if err != nil && !started {
+			respondDecompressError(w, err)
+		}
+		return
+	}
+
+	data, err := api.module.Decompress(req.Block)
+	if err != nil {
+		respondDecompressError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"data": data,
+	})
+}
+
+// respondDecompressError reports blocks over the size limit as too large and
+// other invalid blocks as unprocessable
+func respondDecompressError(w http.ResponseWriter, err error) {
+	if errors.Is(err, agglomerator.ErrDecompressionLimit) {
+		respondError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+	respondError(w, http.StatusUnprocessableEntity, err.Error())
+}
+
+func (api *API) GetStats(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, api.module.GetStats())
+}
+
+func bytesToFloats(raw []byte) []float64 {
+	data := make([]float64, len(raw))
+	for i, b := range raw {
+		data[i] = float64(b)
+	}
+	return data
+}
+
+// floatsToBytes rounds reconstructed values back into the byte range
+func floatsToBytes(data []float64) []byte {
+	raw := make([]byte, len(data))
+	for i, v := range data {
+		raw[i] = byte(math.Max(0, math.Min(255, math.Round(v))))
+	}
+	return raw
+}
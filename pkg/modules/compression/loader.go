@@ -0,0 +1,57 @@
+package compression
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/theaxiomverse/hydap-api/pkg/modules/base"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+type CompressionLoader struct {
+	configManager *core.ConfigManager
+	metrics       *core.MetricsExporter
+	logger        *core.ModuleLogger
+}
+
+func NewCompressionLoader(
+	configManager *core.ConfigManager,
+	metrics *core.MetricsExporter,
+	logger *core.ModuleLogger,
+) *CompressionLoader {
+	return &CompressionLoader{
+		configManager: configManager,
+		metrics:       metrics,
+		logger:        logger,
+	}
+}
+
+func (l *CompressionLoader) LoadFromConfig(config base.ModuleConfig) (base.Module, error) {
+	if config.Name != ModuleName {
+		return nil, fmt.Errorf("invalid module name: %s", config.Name)
+	}
+
+	module := NewCompressionModule(
+		l.configManager,
+		l.metrics,
+		l.logger,
+	)
+
+	// Config is optional; the module falls back to defaults
+	if len(config.Config) > 0 {
+		configJSON, err := json.Marshal(config.Config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal config: %w", err)
+		}
+
+		if err := l.configManager.SetConfig(config.Name, configJSON); err != nil {
+			return nil, fmt.Errorf("failed to store config: %w", err)
+		}
+	}
+
+	return module, nil
+}
+
+func (l *CompressionLoader) Load(path string) (base.Module, error) {
+	return nil, fmt.Errorf("file-based loading not implemented")
+}
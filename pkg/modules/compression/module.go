@@ -0,0 +1,271 @@
+package compression
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/theaxiomverse/hydap-api/pkg/modules/agglomerator"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/base"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+const ModuleName = "compression"
+
+// ModuleConfig represents the compression module's configuration structure
+type ModuleConfig struct {
+	Tolerance       float64 `json:"tolerance"`
+	MaxRank         int     `json:"maxRank"`
+	EnergyThreshold float64 `json:"energyThreshold"`
+	MinSparsity     float64 `json:"minSparsity"`
+	ForceMaxRank    bool    `json:"forceMaxRank"`
+
+	// MaxDecompressedSize bounds the values one decompression may produce;
+	// zero uses agglomerator.DefaultMaxDecompressedSize
+	MaxDecompressedSize int `json:"maxDecompressedSize"`
+}
+
+// DefaultModuleConfig returns the codec settings used when no config is stored
+func DefaultModuleConfig() ModuleConfig {
+	return ModuleConfig{
+		Tolerance:       0.01,
+		MaxRank:         10,
+		EnergyThreshold: 0.95,
+		MinSparsity:     0.5,
+	}
+}
+
+// Validate checks the codec settings and returns every problem found
+func (c *ModuleConfig) Validate() []error {
+	var errs []error
+	if c.MaxRank <= 0 {
+		errs = append(errs, fmt.Errorf("maxRank: must be positive, got %d", c.MaxRank))
+	}
+	if c.Tolerance <= 0 {
+		errs = append(errs, fmt.Errorf("tolerance: must be positive, got %v", c.Tolerance))
+	}
+	if c.EnergyThreshold <= 0 || c.EnergyThreshold > 1 {
+		errs = append(errs, fmt.Errorf("energyThreshold: must be in (0, 1], got %v", c.EnergyThreshold))
+	}
+	if c.MinSparsity < 0 || c.MinSparsity > 1 {
+		errs = append(errs, fmt.Errorf("minSparsity: must be between 0 and 1, got %v", c.MinSparsity))
+	}
+	if c.MaxDecompressedSize < 0 {
+		errs = append(errs, fmt.Errorf("maxDecompressedSize: must not be negative, got %d", c.MaxDecompressedSize))
+	}
+	return errs
+}
+
+// Stats holds usage counters for the compression codec
+type Stats struct {
+	BlocksCompressed   uint64  `json:"blocksCompressed"`
+	BlocksDecompressed uint64  `json:"blocksDecompressed"`
+	BytesIn            uint64  `json:"bytesIn"`
+	BytesOut           uint64  `json:"bytesOut"`
+	Errors             uint64  `json:"errors"`
+	AverageRatio       float64 `json:"averageRatio"`
+}
+
+// CompressionModule exposes the SVD codec as a registered module
+type CompressionModule struct {
+	base.BaseModule
+	compressor    *agglomerator.AdaptiveCompressor
+	config        *ModuleConfig
+	configManager *core.ConfigManager
+	metrics       *core.MetricsExporter
+	logger        *core.ModuleLogger
+	stats         Stats
+	mu            sync.RWMutex
+}
+
+func NewCompressionModule(
+	configManager *core.ConfigManager,
+	metrics *core.MetricsExporter,
+	logger *core.ModuleLogger,
+) *CompressionModule {
+	metadata := base.NewModuleMetadata(
+		ModuleName,
+		"1.0.0",
+		"SVD-based compression codec exposed over HTTP",
+		"HyDAP Team",
+		"MIT",
+	)
+
+	baseModule := base.CreateNewModule(metadata, nil).(*base.BaseModule)
+
+	return &CompressionModule{
+		BaseModule:    *baseModule,
+		configManager: configManager,
+		metrics:       metrics,
+		logger:        logger,
+	}
+}
+
+// Initialize implements Module interface
+func (m *CompressionModule) Initialize() error {
+	if err := m.BaseModule.Initialize(); err != nil {
+		return err
+	}
+
+	// Fall back to defaults when no configuration has been stored
+	moduleConfig := DefaultModuleConfig()
+	if configData, err := m.configManager.GetConfig(m.Name()); err == nil {
+		if err := json.Unmarshal(configData, &moduleConfig); err != nil {
+			m.SetState(base.StateError)
+			return fmt.Errorf("failed to parse config: %w", err)
+		}
+	}
+	if errs := moduleConfig.Validate(); len(errs) > 0 {
+		m.SetState(base.StateError)
+		return fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+
+	m.mu.Lock()
+	m.config = &moduleConfig
+	m.compressor = agglomerator.NewAdaptiveCompressor(agglomerator.CompressorConfig{
+		Tolerance:           moduleConfig.Tolerance,
+		MaxRank:             moduleConfig.MaxRank,
+		EnergyThreshold:     moduleConfig.EnergyThreshold,
+		MinSparsity:         moduleConfig.MinSparsity,
+		ForceMaxRank:        moduleConfig.ForceMaxRank,
+		MaxDecompressedSize: moduleConfig.MaxDecompressedSize,
+	})
+	m.mu.Unlock()
+
+	m.metrics.RegisterModule(m.Name())
+	m.logger.Log(m.Name(), "INFO", fmt.Sprintf("Compression codec ready (maxRank=%d)", moduleConfig.MaxRank))
+
+	m.SetState(base.StateRunning)
+	return nil
+}
+
+// Terminate implements Module interface
+func (m *CompressionModule) Terminate() error {
+	m.mu.Lock()
+	m.compressor = nil
+	m.mu.Unlock()
+	return m.BaseModule.Terminate()
+}
+
+// GetConfig returns the current module configuration
+func (m *CompressionModule) GetConfig() *ModuleConfig {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.config
+}
+
+// Compress encodes data into a compressed block and records stats
+func (m *CompressionModule) Compress(data []float64) (*agglomerator.CompressedBlock, error) {
+	return m.compress(data, len(data)*8)
+}
+
+// CompressBytes encodes raw bytes, one element per byte, counting the
+// bytes rather than the elements they are widened to as input
+func (m *CompressionModule) CompressBytes(raw []byte) (*agglomerator.CompressedBlock, error) {
+	return m.compress(bytesToFloats(raw), len(raw))
+}
+
+// compress encodes data read from size input bytes
+func (m *CompressionModule) compress(data []float64, size int) (*agglomerator.CompressedBlock, error) {
+	m.mu.RLock()
+	compressor := m.compressor
+	m.mu.RUnlock()
+
+	if compressor == nil || m.GetState() != base.StateRunning {
+		return nil, fmt.Errorf("module not in running state: %s", m.GetState())
+	}
+
+	block, err := compressor.CompressBlock(data)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.stats.Errors++
+		return nil, err
+	}
+
+	in := uint64(size)
+	out := uint64(blockSize(block))
+	m.stats.BlocksCompressed++
+	m.stats.BytesIn += in
+	m.stats.BytesOut += out
+
+	// Running mean of the per-block compression ratio; an empty input has
+	// no ratio and counts as 0, which keeps the stats encodable as JSON
+	ratio := 0.0
+	if in > 0 {
+		ratio = float64(out) / float64(in)
+	}
+	m.stats.AverageRatio += (ratio - m.stats.AverageRatio) / float64(m.stats.BlocksCompressed)
+
+	return block, nil
+}
+
+// Decompress reconstructs data from a compressed block and records stats
+func (m *CompressionModule) Decompress(block *agglomerator.CompressedBlock) ([]float64, error) {
+	compressor, err := m.running()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := block.DecompressLimit(compressor.MaxDecompressedSize())
+	m.recordDecompress(err)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// DecompressRows reconstructs a block a row at a time, passing each row to
+// emit, so large blocks are never held whole. The block is validated
+// before the first row.
+func (m *CompressionModule) DecompressRows(block *agglomerator.CompressedBlock, emit func(values []float64) error) error {
+	compressor, err := m.running()
+	if err != nil {
+		return err
+	}
+
+	err = block.DecompressRows(compressor.MaxDecompressedSize(), emit)
+	m.recordDecompress(err)
+	return err
+}
+
+// running returns the compressor, or an error when the module is not running
+func (m *CompressionModule) running() (*agglomerator.AdaptiveCompressor, error) {
+	m.mu.RLock()
+	compressor := m.compressor
+	m.mu.RUnlock()
+
+	if compressor == nil || m.GetState() != base.StateRunning {
+		return nil, fmt.Errorf("module not in running state: %s", m.GetState())
+	}
+	return compressor, nil
+}
+
+func (m *CompressionModule) recordDecompress(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.stats.Errors++
+		return
+	}
+	m.stats.BlocksDecompressed++
+}
+
+// GetStats returns a snapshot of the codec counters
+func (m *CompressionModule) GetStats() Stats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.stats
+}
+
+// blockSize estimates the encoded size of a compressed block in bytes
+func blockSize(block *agglomerator.CompressedBlock) int {
+	size := len(block.S) * 8
+	for i := range block.U {
+		size += len(block.U[i]) * 8
+		size += len(block.V[i]) * 8
+	}
+	return size
+}
@@ -0,0 +1,112 @@
+package compression
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/core"
+)
+
+func newTestModule(t *testing.T, config string) (*CompressionModule, error) {
+	configManager, err := core.NewConfigManager(filepath.Join(t.TempDir(), "config.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { configManager.Close() })
+	if config != "" {
+		require.NoError(t, configManager.SetConfig(ModuleName, json.RawMessage(config)))
+	}
+	module := NewCompressionModule(configManager, core.NewMetricsExporter(), &core.ModuleLogger{})
+	return module, module.Initialize()
+}
+
+func post(t *testing.T, handler http.Handler, path, contentType string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCompressionRoundTrip(t *testing.T) {
+	module, err := newTestModule(t, "")
+	require.NoError(t, err)
+	routes := NewAPI(module).Routes()
+
+	data := make([]float64, 64)
+	for i := range data {
+		data[i] = float64(i % 8)
+	}
+	body, err := json.Marshal(CompressRequest{Data: data})
+	require.NoError(t, err)
+	rec := post(t, routes, "/compress", "application/json", body)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var compressed CompressResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &compressed))
+	assert.Equal(t, EncodingFloats, compressed.Encoding)
+
+	body, err = json.Marshal(DecompressRequest{Encoding: compressed.Encoding, Block: compressed.Block})
+	require.NoError(t, err)
+	rec = post(t, routes, "/decompress", "application/json", body)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var decompressed struct{ Data []float64 }
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &decompressed))
+	require.Len(t, decompressed.Data, len(data))
+	for i := range data {
+		assert.InDelta(t, data[i], decompressed.Data[i], 0.5)
+	}
+
+	// Raw bytes come back as bytes
+	raw := bytes.Repeat([]byte("hydap"), 20)
+	rec = post(t, routes, "/compress", "application/octet-stream", raw)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &compressed))
+	assert.Equal(t, EncodingBytes, compressed.Encoding)
+	body, err = json.Marshal(DecompressRequest{Encoding: compressed.Encoding, Block: compressed.Block})
+	require.NoError(t, err)
+	rec = post(t, routes, "/decompress", "application/json", body)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/octet-stream", rec.Header().Get("Content-Type"))
+	assert.Equal(t, raw, rec.Body.Bytes())
+
+	stats := module.GetStats()
+	assert.Equal(t, uint64(2), stats.BlocksCompressed)
+	assert.Equal(t, uint64(2), stats.BlocksDecompressed)
+	assert.Zero(t, stats.Errors)
+	assert.Equal(t, uint64(len(data)*8+len(raw)), stats.BytesIn, "floats count 8 bytes each, raw bytes one")
+
+	rec = post(t, routes, "/decompress", "application/json", []byte(`{"encoding": "floats", "block": {}}`))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, "an empty block is not decompressed")
+	rec = post(t, routes, "/compress", "application/json", []byte(`{"data": `))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestCompressionRejectsInvalidConfig(t *testing.T) {
+	module, err := newTestModule(t, `{"maxRank": 0, "tolerance": 0.01, "energyThreshold": 2, "minSparsity": 0.5}`)
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "maxRank")
+	assert.ErrorContains(t, err, "energyThreshold")
+	_, err = module.Compress([]float64{1, 2, 3})
+	assert.Error(t, err, "a module that failed to start compresses nothing")
+}
+
+func TestCompressionStatsBeforeAnyBlock(t *testing.T) {
+	module, err := newTestModule(t, "")
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/compress/stats", nil)
+	rec := httptest.NewRecorder()
+	NewAPI(module).Routes().ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var stats Stats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	assert.Zero(t, stats.AverageRatio)
+
+	block, err := module.CompressBytes([]byte("hydap"))
+	require.NoError(t, err)
+	assert.InDelta(t, float64(blockSize(block))/5, module.GetStats().AverageRatio, 1e-9)
+}
@@ -0,0 +1,324 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken  = errors.New("invalid API token")
+	ErrTokenNotFound = errors.New("API token not found")
+)
+
+// tokenPrefix marks issued secrets so they are recognizable in logs and
+// secret scanners
+const tokenPrefix = "hydap_"
+
+// APIToken is an issued API token. A token with no modules is unscoped: it
+// may manage every module and use the routes that belong to none.
+type APIToken struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Modules   []string  `json:"modules,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Allows reports whether the token may manage a module; "" names the routes
+// that belong to no module
+func (t APIToken) Allows(module string) bool {
+	if len(t.Modules) == 0 {
+		return true
+	}
+	for _, allowed := range t.Modules {
+		if allowed == module {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenStore keeps issued API tokens. Only a hash of each secret is stored.
+type TokenStore struct {
+	db *sql.DB
+}
+
+func NewTokenStore(dbPath string) (*TokenStore, error) {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if _, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS api_tokens (
+            id TEXT PRIMARY KEY,
+            name TEXT NOT NULL,
+            secret_hash TEXT NOT NULL UNIQUE,
+            modules JSON NOT NULL,
+            created_at DATETIME NOT NULL
+        );
+    `); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	return &TokenStore{db: db}, nil
+}
+
+func (s *TokenStore) Close() error {
+	return s.db.Close()
+}
+
+// Issue creates a token scoped to modules, or unscoped when none are given,
+// and returns it with its secret. The secret cannot be retrieved later.
+func (s *TokenStore) Issue(name string, modules []string) (APIToken, string, error) {
+	if name == "" {
+		return APIToken{}, "", fmt.Errorf("token name is required")
+	}
+	for _, module := range modules {
+		if module == "" {
+			return APIToken{}, "", fmt.Errorf("empty module name")
+		}
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return APIToken{}, "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	secret := tokenPrefix + hex.EncodeToString(random)
+
+	token := APIToken{
+		ID:        NewID(),
+		Name:      name,
+		Modules:   modules,
+		CreatedAt: time.Now().UTC(),
+	}
+	scope, err := json.Marshal(modules)
+	if err != nil {
+		return APIToken{}, "", err
+	}
+	if modules == nil {
+		scope = []byte("[]")
+	}
+
+	if _, err := s.db.Exec(`
+        INSERT INTO api_tokens (id, name, secret_hash, modules, created_at)
+        VALUES (?, ?, ?, ?, ?)
+    `, token.ID, token.Name, hashSecret(secret), scope, token.CreatedAt); err != nil {
+		return APIToken{}, "", fmt.Errorf("failed to store token: %w", err)
+	}
+	return token, secret, nil
+}
+
+// List returns the issued tokens, oldest first
+func (s *TokenStore) List() ([]APIToken, error) {
+	rows, err := s.db.Query(`
+        SELECT id, name, modules, created_at FROM api_tokens ORDER BY created_at, id
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := make([]APIToken, 0)
+	for rows.Next() {
+		token, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// Revoke deletes a token so its secret is no longer accepted
+func (s *TokenStore) Revoke(id string) error {
+	result, err := s.db.Exec(`DELETE FROM api_tokens WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %s", ErrTokenNotFound, id)
+	}
+	return nil
+}
+
+// Count returns the number of issued tokens
+func (s *TokenStore) Count() (int, error) {
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM api_tokens`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count tokens: %w", err)
+	}
+	return count, nil
+}
+
+// Authenticate returns the token a secret was issued for
+func (s *TokenStore) Authenticate(secret string) (APIToken, error) {
+	if !strings.HasPrefix(secret, tokenPrefix) {
+		return APIToken{}, ErrInvalidToken
+	}
+	row := s.db.QueryRow(`
+        SELECT id, name, modules, created_at FROM api_tokens WHERE secret_hash = ?
+    `, hashSecret(secret))
+	token, err := scanToken(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return APIToken{}, ErrInvalidToken
+	}
+	return token, err
+}
+
+func scanToken(row interface{ Scan(...interface{}) error }) (APIToken, error) {
+	var token APIToken
+	var modules string
+	if err := row.Scan(&token.ID, &token.Name, &modules, &token.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return token, err
+		}
+		return token, fmt.Errorf("failed to read token: %w", err)
+	}
+	if err := json.Unmarshal([]byte(modules), &token.Modules); err != nil {
+		return token, fmt.Errorf("invalid token scope: %w", err)
+	}
+	if len(token.Modules) == 0 {
+		token.Modules = nil
+	}
+	return token, nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+type tokenContextKey struct{}
+
+// TokenFromContext returns the token that authenticated a request
+func TokenFromContext(ctx context.Context) (APIToken, bool) {
+	token, ok := ctx.Value(tokenContextKey{}).(APIToken)
+	return token, ok
+}
+
+// modulesRoutePrefix is where the module management API names its module:
+// /api/modules/{name}/...
+const modulesRoutePrefix = "/api/modules/"
+
+// TokenAuth authenticates API requests with bearer tokens and checks that
+// each token may manage the module the route belongs to
+type TokenAuth struct {
+	tokens *TokenStore
+	routes moduleRoutes
+}
+
+// moduleRoutes assigns request paths to the modules they belong to
+type moduleRoutes struct {
+	prefixes []moduleRoute   // Longest prefix first
+	fixed    map[string]bool // Routes under /api/modules/ that name no module
+}
+
+type moduleRoute struct {
+	prefix string
+	module string
+}
+
+func NewTokenAuth(tokens *TokenStore) *TokenAuth {
+	return &TokenAuth{tokens: tokens}
+}
+
+// Route assigns the routes under prefix, such as /api/agglomerator, to a
+// module. Routes under /api/modules/{name} belong to the named module.
+func (a *TokenAuth) Route(prefix, module string) {
+	a.routes.add(prefix, module)
+}
+
+// FixedRoute marks /api/modules/{name}, such as /api/modules/validate, as a
+// route of the module API itself rather than of a module called name
+func (a *TokenAuth) FixedRoute(name string) {
+	a.routes.addFixed(name)
+}
+
+func (routes *moduleRoutes) add(prefix, module string) {
+	routes.prefixes = append(routes.prefixes, moduleRoute{prefix: strings.TrimSuffix(prefix, "/"), module: module})
+	sort.SliceStable(routes.prefixes, func(i, j int) bool {
+		return len(routes.prefixes[i].prefix) > len(routes.prefixes[j].prefix)
+	})
+}
+
+func (routes *moduleRoutes) addFixed(name string) {
+	if routes.fixed == nil {
+		routes.fixed = make(map[string]bool)
+	}
+	routes.fixed[name] = true
+}
+
+// moduleFor returns the module a request path belongs to, or "" when it
+// belongs to none
+func (routes *moduleRoutes) moduleFor(path string) string {
+	if strings.HasPrefix(path, modulesRoutePrefix) {
+		name, _, _ := strings.Cut(strings.TrimPrefix(path, modulesRoutePrefix), "/")
+		if name != "" && !routes.fixed[name] {
+			return name
+		}
+	}
+	for _, route := range routes.prefixes {
+		if path == route.prefix || strings.HasPrefix(path, route.prefix+"/") {
+			return route.module
+		}
+	}
+	return ""
+}
+
+// Middleware rejects requests without a valid token with 401 and those whose
+// token is not scoped to the route's module with 403
+func (a *TokenAuth) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || secret == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="hydap"`)
+			respondError(w, http.StatusUnauthorized, "missing API token")
+			return
+		}
+
+		token, err := a.tokens.Authenticate(secret)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="hydap", error="invalid_token"`)
+			if errors.Is(err, ErrInvalidToken) {
+				respondError(w, http.StatusUnauthorized, err.Error())
+			} else {
+				respondError(w, http.StatusInternalServerError, err.Error())
+			}
+			return
+		}
+
+		if module := a.routes.moduleFor(r.URL.Path); !token.Allows(module) {
+			message := "token is not scoped to module " + module
+			if module == "" {
+				message = "route requires an unscoped token"
+			}
+			respondError(w, http.StatusForbidden, message)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tokenContextKey{}, token)))
+	})
+}
+
+func respondError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
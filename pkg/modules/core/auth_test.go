@@ -0,0 +1,90 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenAuthScopes(t *testing.T) {
+	tokens, err := NewTokenStore(filepath.Join(t.TempDir(), "tokens.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { tokens.Close() })
+
+	_, unscoped, err := tokens.Issue("admin", nil)
+	require.NoError(t, err)
+	_, scoped, err := tokens.Issue("ops", []string{"compression"})
+	require.NoError(t, err)
+
+	auth := NewTokenAuth(tokens)
+	auth.Route("/api/compress", "compression")
+	auth.Route("/api/agglomerator", "blockchain_agglomerator")
+	for _, name := range []string{"config", "panics", "validate"} {
+		auth.FixedRoute(name)
+	}
+	handler := auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := TokenFromContext(r.Context())
+		assert.True(t, ok)
+		assert.NotEmpty(t, token.ID)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	status := func(secret, path string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if secret != "" {
+			req.Header.Set("Authorization", "Bearer "+secret)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for _, c := range []struct {
+		secret string
+		path   string
+		want   int
+	}{
+		{"", "/api/compress", http.StatusUnauthorized},
+		{"hydap_unknown", "/api/compress", http.StatusUnauthorized},
+		{"not-a-token", "/api/compress", http.StatusUnauthorized},
+
+		// Unscoped tokens reach every route
+		{unscoped, "/api/compress", http.StatusNoContent},
+		{unscoped, "/api/modules/compression/config", http.StatusNoContent},
+		{unscoped, "/api/modules/panics", http.StatusNoContent},
+		{unscoped, "/api/tokens", http.StatusNoContent},
+
+		// Scoped tokens reach only their modules' routes
+		{scoped, "/api/compress", http.StatusNoContent},
+		{scoped, "/api/compress/stats", http.StatusNoContent},
+		{scoped, "/api/modules/compression", http.StatusNoContent},
+		{scoped, "/api/modules/compression/config", http.StatusNoContent},
+		{scoped, "/api/agglomerator/chains", http.StatusForbidden},
+		{scoped, "/api/modules/blockchain_agglomerator/health", http.StatusForbidden},
+		{scoped, "/api/compressor", http.StatusForbidden},
+
+		// Fixed module API routes belong to no module, so they need an
+		// unscoped token whatever module they happen to share a name with
+		{scoped, "/api/modules/panics", http.StatusForbidden},
+		{scoped, "/api/modules/validate", http.StatusForbidden},
+		{scoped, "/api/modules/config/bulk", http.StatusForbidden},
+		{scoped, "/api/tokens", http.StatusForbidden},
+	} {
+		assert.Equal(t, c.want, status(c.secret, c.path), c.path)
+	}
+}
+
+func TestModuleRoutesLongestPrefixFirst(t *testing.T) {
+	var routes moduleRoutes
+	routes.add("/api/agglomerator/", "blockchain_agglomerator")
+	routes.add("/api/agglomerator/compress", "compression")
+
+	assert.Equal(t, "compression", routes.moduleFor("/api/agglomerator/compress/x"))
+	assert.Equal(t, "blockchain_agglomerator", routes.moduleFor("/api/agglomerator"))
+	assert.Equal(t, "panics", routes.moduleFor("/api/modules/panics"))
+	assert.Equal(t, "", routes.moduleFor("/api/modules/"))
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// Set at build time with
+//
+//	-ldflags "-X github.com/theaxiomverse/hydap-api/pkg/modules/core.Version=1.2.0
+//	          -X github.com/theaxiomverse/hydap-api/pkg/modules/core.Commit=$(git rev-parse HEAD)
+//	          -X github.com/theaxiomverse/hydap-api/pkg/modules/core.BuildDate=$(date -u +%FT%TZ)"
+//
+// Unset values fall back to what the Go toolchain recorded in the binary.
+var (
+	Version   = ""
+	Commit    = ""
+	BuildDate = ""
+)
+
+// APIVersions lists the versions of the HTTP API this build serves
+var APIVersions = []string{"v1"}
+
+// BuildInfo describes the running binary
+type BuildInfo struct {
+	Version     string                 `json:"version"`
+	Commit      string                 `json:"commit,omitempty"`
+	Modified    bool                   `json:"modified,omitempty"` // Built from a tree with uncommitted changes
+	BuildDate   string                 `json:"buildDate,omitempty"`
+	GoVersion   string                 `json:"goVersion"`
+	Features    map[string]interface{} `json:"features"`
+	APIVersions []string               `json:"apiVersions"`
+}
+
+var (
+	featuresMu sync.RWMutex
+	features   = make(map[string]interface{})
+)
+
+// RegisterFeature reports an optional capability of this build, such as a
+// linked library's version or the transports compiled in
+func RegisterFeature(name string, value interface{}) {
+	featuresMu.Lock()
+	defer featuresMu.Unlock()
+	features[name] = value
+}
+
+// GetBuildInfo returns the version, commit and features of the running binary
+func GetBuildInfo() BuildInfo {
+	info := BuildInfo{
+		Version:     Version,
+		Commit:      Commit,
+		BuildDate:   BuildDate,
+		GoVersion:   runtime.Version(),
+		Features:    make(map[string]interface{}),
+		APIVersions: append([]string(nil), APIVersions...),
+	}
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && build.Main.Version != "(devel)" {
+			info.Version = build.Main.Version
+		}
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = Commit == "" && setting.Value == "true"
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+
+	featuresMu.RLock()
+	defer featuresMu.RUnlock()
+	for name, value := range features {
+		info.Features[name] = value
+	}
+	return info
+}
@@ -0,0 +1,33 @@
+package core
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetBuildInfo(t *testing.T) {
+	info := GetBuildInfo()
+	assert.Equal(t, "dev", info.Version, "test binaries have no module version")
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+	assert.Equal(t, []string{"v1"}, info.APIVersions)
+
+	version, commit, buildDate := Version, Commit, BuildDate
+	t.Cleanup(func() { Version, Commit, BuildDate = version, commit, buildDate })
+	Version, Commit, BuildDate = "1.2.0", "abc123", "2026-01-02T03:04:05Z"
+	RegisterFeature("transports", []string{"tcp"})
+
+	info = GetBuildInfo()
+	assert.Equal(t, "1.2.0", info.Version)
+	assert.Equal(t, "abc123", info.Commit)
+	assert.Equal(t, "2026-01-02T03:04:05Z", info.BuildDate)
+	assert.False(t, info.Modified, "a commit set at build time is not checked against the tree")
+	assert.Equal(t, []string{"tcp"}, info.Features["transports"])
+
+	// Callers get copies
+	info.Features["transports"] = nil
+	info.APIVersions[0] = "v0"
+	assert.Equal(t, []string{"tcp"}, GetBuildInfo().Features["transports"])
+	assert.Equal(t, []string{"v1"}, APIVersions)
+}
@@ -0,0 +1,173 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the time source of components that wait or timestamp, so tests
+// can replace the system clock with a FakeClock
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers ticks from a Clock
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// SystemClock reads the system clock
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (systemClock) NewTicker(d time.Duration) Ticker       { return systemTicker{time.NewTicker(d)} }
+
+type systemTicker struct {
+	ticker *time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time { return t.ticker.C }
+func (t systemTicker) Stop()               { t.ticker.Stop() }
+
+// FakeClock is a Clock that only moves when told to. Timers and tickers
+// fire as Advance passes their deadlines.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+	changed chan struct{} // Closed and replaced whenever waiters change
+}
+
+// fakeWaiter is a pending After or a running ticker
+type fakeWaiter struct {
+	at     time.Time
+	period time.Duration // Zero for After
+	ch     chan time.Time
+}
+
+// NewFakeClock creates a clock stopped at start
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start, changed: make(chan struct{})}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w := &fakeWaiter{at: c.now.Add(d), ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		w.ch <- c.now
+		return w.ch
+	}
+	c.add(w)
+	return w.ch
+}
+
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for FakeClock.NewTicker")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w := &fakeWaiter{at: c.now.Add(d), period: d, ch: make(chan time.Time, 1)}
+	c.add(w)
+	return &fakeTicker{clock: c, waiter: w}
+}
+
+// Advance moves the clock forward, firing every timer and tick due by the
+// new time in order. Like time.Ticker, a ticker whose last tick has not
+// been received drops the next.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	end := c.now.Add(d)
+	for {
+		next := c.nextDue(end)
+		if next == nil {
+			break
+		}
+		c.now = next.at
+		select {
+		case next.ch <- c.now:
+		default:
+		}
+		if next.period > 0 {
+			next.at = next.at.Add(next.period)
+		} else {
+			c.remove(next)
+		}
+	}
+	c.now = end
+}
+
+// BlockUntil waits until n timers and tickers are pending, so a test can
+// advance the clock once the code under test has started waiting
+func (c *FakeClock) BlockUntil(n int) {
+	for {
+		c.mu.Lock()
+		pending, changed := len(c.waiters), c.changed
+		c.mu.Unlock()
+		if pending >= n {
+			return
+		}
+		<-changed
+	}
+}
+
+// nextDue returns the earliest waiter due by end, or nil
+func (c *FakeClock) nextDue(end time.Time) *fakeWaiter {
+	var next *fakeWaiter
+	for _, w := range c.waiters {
+		if !w.at.After(end) && (next == nil || w.at.Before(next.at)) {
+			next = w
+		}
+	}
+	return next
+}
+
+func (c *FakeClock) add(w *fakeWaiter) {
+	c.waiters = append(c.waiters, w)
+	c.notify()
+}
+
+func (c *FakeClock) remove(w *fakeWaiter) {
+	for i, pending := range c.waiters {
+		if pending == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			c.notify()
+			return
+		}
+	}
+}
+
+func (c *FakeClock) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+type fakeTicker struct {
+	clock  *FakeClock
+	waiter *fakeWaiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.waiter.ch }
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.clock.remove(t.waiter)
+}
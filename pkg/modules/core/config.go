@@ -2,17 +2,33 @@
 package core
 
 import (
+	"bytes"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	_ "github.com/mattn/go-sqlite3"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 )
 
+var ErrConfigRevisionNotFound = errors.New("config revision not found")
+
+// ConfigRevision is one stored version of a module's configuration
+type ConfigRevision struct {
+	Revision  int             `json:"revision"`
+	Config    json.RawMessage `json:"config,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
 type ConfigManager struct {
 	db       *sql.DB
 	reloader *HotReloader
+
+	flagsOnce sync.Once
+	flags     *FeatureFlags
 }
 
 func NewConfigManager(dbPath string) (*ConfigManager, error) {
@@ -34,38 +50,131 @@ func NewConfigManager(dbPath string) (*ConfigManager, error) {
 	return &ConfigManager{db: db}, nil
 }
 
+// NewMemoryConfigManager returns a config manager backed by a private
+// in-memory database, for trying configurations without touching stored ones
+func NewMemoryConfigManager() (*ConfigManager, error) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// Each connection to :memory: would get its own empty database
+	db.SetMaxOpenConns(1)
+
+	if err := initConfigDB(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	return &ConfigManager{db: db}, nil
+}
+
 func initConfigDB(db *sql.DB) error {
 	_, err := db.Exec(`
         CREATE TABLE IF NOT EXISTS module_configs (
             module_name TEXT PRIMARY KEY,
             config JSON NOT NULL,
             updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-        )
+        );
+        CREATE TABLE IF NOT EXISTS module_config_revisions (
+            module_name TEXT NOT NULL,
+            revision INTEGER NOT NULL,
+            config JSON NOT NULL,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            PRIMARY KEY (module_name, revision)
+        );
+        CREATE TABLE IF NOT EXISTS config_presets (
+            name TEXT PRIMARY KEY,
+            module_name TEXT NOT NULL,
+            description TEXT NOT NULL DEFAULT '',
+            config JSON NOT NULL,
+            updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+        );
+        INSERT INTO module_config_revisions (module_name, revision, config, created_at)
+        SELECT module_name, 1, config, updated_at FROM module_configs
+        WHERE module_name NOT IN (SELECT module_name FROM module_config_revisions);
     `)
 	return err
 }
 
 func (cm *ConfigManager) SetConfig(module string, config json.RawMessage) error {
+	_, err := cm.SetConfigs(map[string]json.RawMessage{module: config})
+	return err
+}
+
+// SetConfigs stores the configs of several modules in one transaction, so
+// either every module gets its new config or none does. It returns the
+// revision each config is stored as.
+func (cm *ConfigManager) SetConfigs(configs map[string]json.RawMessage) (map[string]int, error) {
+	for module, config := range configs {
+		if err := checkConfig(config); err != nil {
+			return nil, fmt.Errorf("module %s: %w", module, err)
+		}
+	}
+
+	tx, err := cm.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to store configuration: %w", err)
+	}
+	defer tx.Rollback()
+
+	revisions := make(map[string]int, len(configs))
+	for module, config := range configs {
+		revision, err := storeConfig(tx, module, config)
+		if err != nil {
+			return nil, err
+		}
+		revisions[module] = revision
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to store configuration: %w", err)
+	}
+	return revisions, nil
+}
+
+// checkConfig checks a config is a JSON object
+func checkConfig(config json.RawMessage) error {
 	if len(config) == 0 {
 		return fmt.Errorf("empty configuration provided")
 	}
-
-	// Validate JSON
 	var jsonCheck map[string]interface{}
 	if err := json.Unmarshal(config, &jsonCheck); err != nil {
 		return fmt.Errorf("invalid JSON configuration: %w", err)
 	}
+	return nil
+}
+
+// storeConfig makes config a module's current config within tx, returning
+// its revision
+func storeConfig(tx *sql.Tx, module string, config json.RawMessage) (int, error) {
+	var revision int
+	var latest json.RawMessage
+	err := tx.QueryRow(`
+        SELECT revision, config FROM module_config_revisions
+        WHERE module_name = ? ORDER BY revision DESC LIMIT 1
+    `, module).Scan(&revision, &latest)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to read configuration revision: %w", err)
+	}
 
-	_, err := cm.db.Exec(`
+	if _, err := tx.Exec(`
         INSERT OR REPLACE INTO module_configs (module_name, config, updated_at)
         VALUES (?, ?, CURRENT_TIMESTAMP)
-    `, module, config)
-
-	if err != nil {
-		return fmt.Errorf("failed to store configuration: %w", err)
+    `, module, config); err != nil {
+		return 0, fmt.Errorf("failed to store configuration: %w", err)
 	}
 
-	return nil
+	// Re-applying the current configuration does not start a new revision
+	if bytes.Equal(latest, config) {
+		return revision, nil
+	}
+	if _, err := tx.Exec(`
+        INSERT INTO module_config_revisions (module_name, revision, config)
+        VALUES (?, ?, ?)
+    `, module, revision+1, config); err != nil {
+		return 0, fmt.Errorf("failed to store configuration revision: %w", err)
+	}
+	return revision + 1, nil
 }
 
 func (cm *ConfigManager) GetConfig(module string) (json.RawMessage, error) {
@@ -85,6 +194,61 @@ func (cm *ConfigManager) GetConfig(module string) (json.RawMessage, error) {
 	return config, nil
 }
 
+// ListConfigRevisions returns a module's configuration revisions, oldest
+// first, without their contents
+func (cm *ConfigManager) ListConfigRevisions(module string) ([]ConfigRevision, error) {
+	rows, err := cm.db.Query(`
+        SELECT revision, created_at FROM module_config_revisions
+        WHERE module_name = ? ORDER BY revision
+    `, module)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list configuration revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := make([]ConfigRevision, 0)
+	for rows.Next() {
+		var revision ConfigRevision
+		if err := rows.Scan(&revision.Revision, &revision.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to read configuration revision: %w", err)
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+// GetConfigRevision returns one revision of a module's configuration
+func (cm *ConfigManager) GetConfigRevision(module string, revision int) (ConfigRevision, error) {
+	result := ConfigRevision{Revision: revision}
+	err := cm.db.QueryRow(`
+        SELECT config, created_at FROM module_config_revisions
+        WHERE module_name = ? AND revision = ?
+    `, module, revision).Scan(&result.Config, &result.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return result, fmt.Errorf("%w: %s revision %d", ErrConfigRevisionNotFound, module, revision)
+	}
+	if err != nil {
+		return result, fmt.Errorf("failed to retrieve configuration revision: %w", err)
+	}
+	return result, nil
+}
+
+// LatestConfigRevision returns the number of a module's current revision
+func (cm *ConfigManager) LatestConfigRevision(module string) (int, error) {
+	var revision sql.NullInt64
+	err := cm.db.QueryRow(`
+        SELECT MAX(revision) FROM module_config_revisions WHERE module_name = ?
+    `, module).Scan(&revision)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve configuration revision: %w", err)
+	}
+	if !revision.Valid {
+		return 0, fmt.Errorf("%w: %s has no revisions", ErrConfigRevisionNotFound, module)
+	}
+	return int(revision.Int64), nil
+}
+
 // Close the database connection
 func (cm *ConfigManager) Close() error {
 	if cm.db != nil {
@@ -0,0 +1,104 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Kinds of ConfigChange
+const (
+	ConfigAdded   = "added"
+	ConfigRemoved = "removed"
+	ConfigChanged = "changed"
+)
+
+// ConfigChange is one difference between two configurations. Path uses dots
+// for object keys and brackets for array indexes, as in p2p.bootstrapPeers[0].
+type ConfigChange struct {
+	Path string      `json:"path"`
+	Op   string      `json:"op"`
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+}
+
+// DiffConfig compares two JSON configurations and returns their differences
+// ordered by path
+func DiffConfig(from, to json.RawMessage) ([]ConfigChange, error) {
+	var fromValue, toValue interface{}
+	if err := json.Unmarshal(from, &fromValue); err != nil {
+		return nil, fmt.Errorf("invalid JSON configuration: %w", err)
+	}
+	if err := json.Unmarshal(to, &toValue); err != nil {
+		return nil, fmt.Errorf("invalid JSON configuration: %w", err)
+	}
+
+	changes := make([]ConfigChange, 0)
+	diffConfigValues("", fromValue, toValue, &changes)
+	return changes, nil
+}
+
+func diffConfigValues(path string, from, to interface{}, changes *[]ConfigChange) {
+	switch fromValue := from.(type) {
+	case map[string]interface{}:
+		if toValue, ok := to.(map[string]interface{}); ok {
+			diffConfigObjects(path, fromValue, toValue, changes)
+			return
+		}
+	case []interface{}:
+		if toValue, ok := to.([]interface{}); ok {
+			diffConfigArrays(path, fromValue, toValue, changes)
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(from, to) {
+		*changes = append(*changes, ConfigChange{Path: path, Op: ConfigChanged, From: from, To: to})
+	}
+}
+
+func diffConfigObjects(path string, from, to map[string]interface{}, changes *[]ConfigChange) {
+	keys := make([]string, 0, len(from)+len(to))
+	for key := range from {
+		keys = append(keys, key)
+	}
+	for key := range to {
+		if _, exists := from[key]; !exists {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		keyPath := key
+		if path != "" {
+			keyPath = path + "." + key
+		}
+
+		fromValue, inFrom := from[key]
+		toValue, inTo := to[key]
+		switch {
+		case !inFrom:
+			*changes = append(*changes, ConfigChange{Path: keyPath, Op: ConfigAdded, To: toValue})
+		case !inTo:
+			*changes = append(*changes, ConfigChange{Path: keyPath, Op: ConfigRemoved, From: fromValue})
+		default:
+			diffConfigValues(keyPath, fromValue, toValue, changes)
+		}
+	}
+}
+
+func diffConfigArrays(path string, from, to []interface{}, changes *[]ConfigChange) {
+	for i := 0; i < len(from) || i < len(to); i++ {
+		indexPath := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case i >= len(from):
+			*changes = append(*changes, ConfigChange{Path: indexPath, Op: ConfigAdded, To: to[i]})
+		case i >= len(to):
+			*changes = append(*changes, ConfigChange{Path: indexPath, Op: ConfigRemoved, From: from[i]})
+		default:
+			diffConfigValues(indexPath, from[i], to[i], changes)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package core
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+var (
+	ErrPresetNotFound = errors.New("config preset not found")
+	ErrInvalidPreset  = errors.New("invalid config preset")
+)
+
+var presetNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// ConfigPreset is a named configuration for a module, such as
+// mainnet-conservative, that module configs are made from with overrides
+type ConfigPreset struct {
+	Name        string          `json:"name"`
+	Module      string          `json:"module"`
+	Description string          `json:"description,omitempty"`
+	Config      json.RawMessage `json:"config,omitempty"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+}
+
+// SavePreset stores a preset, replacing any with the same name
+func (cm *ConfigManager) SavePreset(preset ConfigPreset) error {
+	if !presetNamePattern.MatchString(preset.Name) {
+		return fmt.Errorf("%w: name %q must be lowercase letters, digits, '.', '_' or '-'", ErrInvalidPreset, preset.Name)
+	}
+	if preset.Module == "" {
+		return fmt.Errorf("%w: module required", ErrInvalidPreset)
+	}
+	if err := checkConfig(preset.Config); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPreset, err)
+	}
+
+	if _, err := cm.db.Exec(`
+        INSERT OR REPLACE INTO config_presets (name, module_name, description, config, updated_at)
+        VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+    `, preset.Name, preset.Module, preset.Description, preset.Config); err != nil {
+		return fmt.Errorf("failed to store config preset: %w", err)
+	}
+	return nil
+}
+
+// GetPreset returns a preset with its config
+func (cm *ConfigManager) GetPreset(name string) (ConfigPreset, error) {
+	preset := ConfigPreset{Name: name}
+	err := cm.db.QueryRow(`
+        SELECT module_name, description, config, updated_at FROM config_presets WHERE name = ?
+    `, name).Scan(&preset.Module, &preset.Description, &preset.Config, &preset.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return preset, fmt.Errorf("%w: %s", ErrPresetNotFound, name)
+	}
+	if err != nil {
+		return preset, fmt.Errorf("failed to retrieve config preset: %w", err)
+	}
+	return preset, nil
+}
+
+// ListPresets returns the stored presets ordered by name, without their
+// configs
+func (cm *ConfigManager) ListPresets() ([]ConfigPreset, error) {
+	rows, err := cm.db.Query(`
+        SELECT name, module_name, description, updated_at FROM config_presets ORDER BY name
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list config presets: %w", err)
+	}
+	defer rows.Close()
+
+	presets := make([]ConfigPreset, 0)
+	for rows.Next() {
+		var preset ConfigPreset
+		if err := rows.Scan(&preset.Name, &preset.Module, &preset.Description, &preset.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to read config preset: %w", err)
+		}
+		presets = append(presets, preset)
+	}
+	return presets, rows.Err()
+}
+
+// DeletePreset removes a preset. Configs made from it are unaffected.
+func (cm *ConfigManager) DeletePreset(name string) error {
+	result, err := cm.db.Exec(`DELETE FROM config_presets WHERE name = ?`, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete config preset: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %s", ErrPresetNotFound, name)
+	}
+	return nil
+}
+
+// InstantiatePreset makes a config for the preset's module from the preset
+// with overrides merged in, without storing it. It returns the module the
+// config is for.
+func (cm *ConfigManager) InstantiatePreset(name string, overrides json.RawMessage) (string, json.RawMessage, error) {
+	preset, err := cm.GetPreset(name)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(overrides) == 0 {
+		return preset.Module, preset.Config, nil
+	}
+	config, err := MergeConfig(preset.Config, overrides)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrInvalidPreset, err)
+	}
+	return preset.Module, config, nil
+}
+
+// MergeConfig applies overrides to a JSON configuration as a JSON merge
+// patch (RFC 7386): objects are merged key by key, a null removes a key and
+// any other value, arrays included, replaces the one it overrides
+func MergeConfig(config, overrides json.RawMessage) (json.RawMessage, error) {
+	var base, patch interface{}
+	if err := json.Unmarshal(config, &base); err != nil {
+		return nil, fmt.Errorf("invalid JSON configuration: %w", err)
+	}
+	if err := json.Unmarshal(overrides, &patch); err != nil {
+		return nil, fmt.Errorf("invalid JSON overrides: %w", err)
+	}
+	if _, ok := patch.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("overrides must be a JSON object")
+	}
+	return json.Marshal(mergeConfigValues(base, patch))
+}
+
+func mergeConfigValues(base, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	baseObject, ok := base.(map[string]interface{})
+	if !ok {
+		baseObject = make(map[string]interface{})
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(baseObject, key)
+			continue
+		}
+		baseObject[key] = mergeConfigValues(baseObject[key], value)
+	}
+	return baseObject
+}
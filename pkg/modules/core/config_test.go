@@ -0,0 +1,90 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestConfigManager(t *testing.T) *ConfigManager {
+	t.Helper()
+	configManager, err := NewMemoryConfigManager()
+	require.NoError(t, err)
+	t.Cleanup(func() { configManager.Close() })
+	return configManager
+}
+
+func TestConfigRevisions(t *testing.T) {
+	cm := newTestConfigManager(t)
+
+	_, err := cm.LatestConfigRevision("node")
+	assert.ErrorIs(t, err, ErrConfigRevisionNotFound)
+
+	require.NoError(t, cm.SetConfig("node", json.RawMessage(`{"port":8000}`)))
+	require.NoError(t, cm.SetConfig("node", json.RawMessage(`{"port":8001}`)))
+	// Re-applying the current config keeps its revision
+	require.NoError(t, cm.SetConfig("node", json.RawMessage(`{"port":8001}`)))
+
+	revisions, err := cm.ListConfigRevisions("node")
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, 1, revisions[0].Revision)
+	assert.Nil(t, revisions[0].Config)
+
+	latest, err := cm.LatestConfigRevision("node")
+	require.NoError(t, err)
+	assert.Equal(t, 2, latest)
+
+	first, err := cm.GetConfigRevision("node", 1)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"port":8000}`, string(first.Config))
+	_, err = cm.GetConfigRevision("node", 3)
+	assert.ErrorIs(t, err, ErrConfigRevisionNotFound)
+
+	current, err := cm.GetConfig("node")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"port":8001}`, string(current))
+}
+
+func TestSetConfigsIsAllOrNothing(t *testing.T) {
+	cm := newTestConfigManager(t)
+
+	_, err := cm.SetConfigs(map[string]json.RawMessage{
+		"node":  json.RawMessage(`{"port":8000}`),
+		"codec": json.RawMessage(`[1, 2]`),
+	})
+	assert.ErrorContains(t, err, "module codec: invalid JSON configuration")
+	_, err = cm.GetConfig("node")
+	assert.Error(t, err)
+
+	revisions, err := cm.SetConfigs(map[string]json.RawMessage{
+		"node":  json.RawMessage(`{"port":8000}`),
+		"codec": json.RawMessage(`{"maxRank":10}`),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"node": 1, "codec": 1}, revisions)
+}
+
+func TestDiffConfig(t *testing.T) {
+	changes, err := DiffConfig(
+		json.RawMessage(`{"port":8000,"p2p":{"bootstrapPeers":["a","b"],"maxPeers":50},"logLevel":"info"}`),
+		json.RawMessage(`{"port":8001,"p2p":{"bootstrapPeers":["a"],"maxPeers":50,"chunkSize":"256KB"},"metrics":{"enabled":true}}`),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []ConfigChange{
+		{Path: "logLevel", Op: ConfigRemoved, From: "info"},
+		{Path: "metrics", Op: ConfigAdded, To: map[string]interface{}{"enabled": true}},
+		{Path: "p2p.bootstrapPeers[1]", Op: ConfigRemoved, From: "b"},
+		{Path: "p2p.chunkSize", Op: ConfigAdded, To: "256KB"},
+		{Path: "port", Op: ConfigChanged, From: float64(8000), To: float64(8001)},
+	}, changes)
+
+	changes, err = DiffConfig(json.RawMessage(`{"a":1}`), json.RawMessage(`{"a":1}`))
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	_, err = DiffConfig(json.RawMessage(`{`), json.RawMessage(`{}`))
+	assert.Error(t, err)
+}
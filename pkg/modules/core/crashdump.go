@@ -0,0 +1,165 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// maxGoroutineDump bounds the goroutine stacks captured in a bundle
+const maxGoroutineDump = 8 << 20
+
+// CrashBundle is a snapshot of the node taken on a fatal error or for a
+// support case
+type CrashBundle struct {
+	Reason     string                     `json:"reason"`
+	Time       time.Time                  `json:"time"`
+	Build      BuildInfo                  `json:"build"`
+	Goroutines string                     `json:"goroutines"`
+	State      map[string]json.RawMessage `json:"state"`
+	Errors     map[string]string          `json:"errors,omitempty"` // State that could not be captured
+}
+
+// StateSource returns a part of the node's state for a crash bundle. It must
+// be safe to call from a goroutine that is panicking.
+type StateSource func() (interface{}, error)
+
+// CrashDumper captures crash bundles from the state sources registered by
+// the service and its modules, writing them to files in dir
+type CrashDumper struct {
+	dir     string
+	mu      sync.Mutex
+	names   []string
+	sources map[string]StateSource
+}
+
+func NewCrashDumper(dir string) *CrashDumper {
+	return &CrashDumper{dir: dir, sources: make(map[string]StateSource)}
+}
+
+// Register adds a state source, replacing one of the same name
+func (d *CrashDumper) Register(name string, source StateSource) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, exists := d.sources[name]; !exists {
+		d.names = append(d.names, name)
+	}
+	d.sources[name] = source
+}
+
+// Capture takes a bundle. A source that fails or panics is reported under
+// Errors instead of failing the bundle.
+func (d *CrashDumper) Capture(reason string) CrashBundle {
+	d.mu.Lock()
+	names := append([]string(nil), d.names...)
+	sources := make([]StateSource, len(names))
+	for i, name := range names {
+		sources[i] = d.sources[name]
+	}
+	d.mu.Unlock()
+
+	bundle := CrashBundle{
+		Reason:     reason,
+		Time:       time.Now().UTC(),
+		Build:      GetBuildInfo(),
+		Goroutines: goroutineDump(),
+		State:      make(map[string]json.RawMessage, len(names)),
+		Errors:     make(map[string]string),
+	}
+	for i, name := range names {
+		var data []byte
+		err := protect(name, "crash dump", func() error {
+			state, err := sources[i]()
+			if err != nil {
+				return err
+			}
+			data, err = json.Marshal(state)
+			return err
+		})
+		if err != nil {
+			bundle.Errors[name] = err.Error()
+			continue
+		}
+		bundle.State[name] = data
+	}
+	return bundle
+}
+
+// WriteFile captures a bundle and writes it to a new file in the dump
+// directory, returning its path
+func (d *CrashDumper) WriteFile(reason string) (string, error) {
+	bundle := d.Capture(reason)
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode crash bundle: %w", err)
+	}
+
+	if err := os.MkdirAll(d.dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create crash dump directory: %w", err)
+	}
+	path := filepath.Join(d.dir, "crash-"+bundle.Time.Format("20060102T150405.000Z")+".json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return "", fmt.Errorf("failed to write crash bundle: %w", err)
+	}
+	return path, nil
+}
+
+// Fatal writes a bundle for an unrecoverable error, reporting where it went
+// on stderr
+func (d *CrashDumper) Fatal(err error) {
+	path, writeErr := d.WriteFile(err.Error())
+	if writeErr != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write crash bundle: %v\n", writeErr)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Wrote crash bundle to %s\n", path)
+}
+
+// Recover, when deferred, writes a bundle if the calling goroutine panics and
+// then lets the panic continue
+func (d *CrashDumper) Recover() {
+	value := recover()
+	if value == nil {
+		return
+	}
+	d.Fatal(fmt.Errorf("panic: %v", value))
+	panic(value)
+}
+
+// CaptureCrashOutput has the runtime write the stacks of a panic that no
+// goroutine recovers, or of a fatal runtime error, to crash.log in the dump
+// directory. Module state cannot be captured then, as the process dies
+// without running deferred calls.
+func (d *CrashDumper) CaptureCrashOutput() error {
+	if err := os.MkdirAll(d.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create crash dump directory: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(d.dir, "crash.log"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open crash log: %w", err)
+	}
+	// The runtime keeps its own copy of the descriptor
+	defer f.Close()
+	if err := debug.SetCrashOutput(f, debug.CrashOptions{}); err != nil {
+		return fmt.Errorf("failed to set crash output: %w", err)
+	}
+	return nil
+}
+
+// goroutineDump returns the stacks of all goroutines, truncated to
+// maxGoroutineDump bytes
+func goroutineDump() string {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= maxGoroutineDump {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
@@ -0,0 +1,391 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DesiredStateConfigKey is the ConfigManager entry holding the desired state
+// document, which gives it the same revision history as module configs
+const DesiredStateConfigKey = "desired_state"
+
+// ModulesSection is the desired state section holding module configs by
+// module name
+const ModulesSection = "modules"
+
+// Desired state modes
+const (
+	DesiredStateEnforce = "enforce" // Drift is reported and corrected
+	DesiredStateReport  = "report"  // Drift is only reported
+)
+
+// DefaultDesiredStateInterval is how often the actual state is compared with
+// a desired state document that sets no interval
+const DefaultDesiredStateInterval = 30 * time.Second
+
+// minDesiredStateInterval keeps a mistyped interval from busy-looping
+const minDesiredStateInterval = time.Second
+
+// Kinds of Drift
+const (
+	DriftMissing   = "missing"   // Declared but absent
+	DriftChanged   = "changed"   // Present with other settings than declared
+	DriftUnmanaged = "unmanaged" // Present but not declared; never corrected
+)
+
+var (
+	ErrNoDesiredState      = errors.New("no desired state stored")
+	ErrInvalidDesiredState = errors.New("invalid desired state")
+)
+
+// DesiredState declares the state a deployment is driven toward. Each
+// section, such as modules, is owned by a StateReconciler; state in a
+// section left out is not managed.
+type DesiredState struct {
+	Mode     string                     // DesiredStateEnforce when empty
+	Interval string                     // DefaultDesiredStateInterval when empty
+	Sections map[string]json.RawMessage // By section name
+}
+
+// MarshalJSON writes the sections alongside mode and interval, as one object
+func (d DesiredState) MarshalJSON() ([]byte, error) {
+	document := make(map[string]interface{}, len(d.Sections)+2)
+	for name, section := range d.Sections {
+		document[name] = section
+	}
+	if d.Mode != "" {
+		document["mode"] = d.Mode
+	}
+	if d.Interval != "" {
+		document["interval"] = d.Interval
+	}
+	return json.Marshal(document)
+}
+
+// UnmarshalJSON reads every key but mode and interval as a section
+func (d *DesiredState) UnmarshalJSON(data []byte) error {
+	var document map[string]json.RawMessage
+	if err := json.Unmarshal(data, &document); err != nil {
+		return err
+	}
+	*d = DesiredState{Sections: make(map[string]json.RawMessage, len(document))}
+	for name, value := range document {
+		var err error
+		switch name {
+		case "mode":
+			err = json.Unmarshal(value, &d.Mode)
+		case "interval":
+			err = json.Unmarshal(value, &d.Interval)
+		default:
+			d.Sections[name] = value
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// enforced reports whether drift is corrected
+func (d DesiredState) enforced() bool {
+	return d.Mode == "" || d.Mode == DesiredStateEnforce
+}
+
+// interval returns how often the state is reconciled
+func (d DesiredState) interval() time.Duration {
+	interval, err := time.ParseDuration(d.Interval)
+	if err != nil || interval < minDesiredStateInterval {
+		return DefaultDesiredStateInterval
+	}
+	return interval
+}
+
+// Drift is one difference between the desired and the actual state
+type Drift struct {
+	Section   string         `json:"section"`
+	Resource  string         `json:"resource"` // e.g. a module name or chain ID
+	Kind      string         `json:"kind"`
+	Changes   []ConfigChange `json:"changes,omitempty"` // From the actual to the desired settings
+	Corrected bool           `json:"corrected"`
+	Error     string         `json:"error,omitempty"` // Why it was not corrected
+}
+
+// DriftReport is the outcome of one reconciliation
+type DriftReport struct {
+	Revision  int               `json:"revision"` // Of the desired state document
+	Mode      string            `json:"mode"`
+	DryRun    bool              `json:"dryRun,omitempty"`
+	CheckedAt time.Time         `json:"checkedAt"`
+	InSync    bool              `json:"inSync"` // Nothing drifted, or every drift was corrected
+	Drift     []Drift           `json:"drift"`
+	Errors    map[string]string `json:"errors,omitempty"` // Sections that could not be compared
+}
+
+// StateReconciler drives one section of the desired state
+type StateReconciler interface {
+	// Validate checks a section before it is stored
+	Validate(section json.RawMessage) error
+	// Reconcile compares the actual state with the section and returns the
+	// drift; with enforce set it also corrects it
+	Reconcile(ctx context.Context, section json.RawMessage, enforce bool) ([]Drift, error)
+}
+
+// DesiredStateReconciler compares the actual state with the desired state
+// document stored in a ConfigManager, correcting drift unless the document
+// is in report mode. The modules section, of module configs, is built in;
+// other sections are added with Register.
+type DesiredStateReconciler struct {
+	store *ConfigManager
+
+	mu       sync.RWMutex
+	sections map[string]StateReconciler
+	last     *DriftReport
+
+	runMu sync.Mutex // One reconciliation at a time
+}
+
+func NewDesiredStateReconciler(store *ConfigManager) *DesiredStateReconciler {
+	d := &DesiredStateReconciler{
+		store:    store,
+		sections: make(map[string]StateReconciler),
+	}
+	d.Register(ModulesSection, moduleConfigReconciler{store: store})
+	return d
+}
+
+// Register makes reconciler the owner of a section
+func (d *DesiredStateReconciler) Register(section string, reconciler StateReconciler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sections[section] = reconciler
+}
+
+func (d *DesiredStateReconciler) section(name string) (StateReconciler, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	reconciler, exists := d.sections[name]
+	return reconciler, exists
+}
+
+// Get returns the stored desired state and its revision
+func (d *DesiredStateReconciler) Get() (DesiredState, int, error) {
+	var desired DesiredState
+	revision, err := d.store.LatestConfigRevision(DesiredStateConfigKey)
+	if errors.Is(err, ErrConfigRevisionNotFound) {
+		return desired, 0, ErrNoDesiredState
+	}
+	if err != nil {
+		return desired, 0, err
+	}
+	config, err := d.store.GetConfig(DesiredStateConfigKey)
+	if err != nil {
+		return desired, 0, err
+	}
+	if err := json.Unmarshal(config, &desired); err != nil {
+		return desired, 0, fmt.Errorf("failed to decode desired state: %w", err)
+	}
+	return desired, revision, nil
+}
+
+// Set validates and stores the desired state, returning its revision. It is
+// reconciled on the next pass, or at once with Reconcile.
+func (d *DesiredStateReconciler) Set(desired DesiredState) (int, error) {
+	if err := d.Validate(desired); err != nil {
+		return 0, err
+	}
+	encoded, err := json.Marshal(desired)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode desired state: %w", err)
+	}
+	if err := d.store.SetConfig(DesiredStateConfigKey, encoded); err != nil {
+		return 0, err
+	}
+	return d.store.LatestConfigRevision(DesiredStateConfigKey)
+}
+
+// Validate checks the mode, the interval and every section
+func (d *DesiredStateReconciler) Validate(desired DesiredState) error {
+	switch desired.Mode {
+	case "", DesiredStateEnforce, DesiredStateReport:
+	default:
+		return fmt.Errorf("%w: mode must be %s or %s", ErrInvalidDesiredState, DesiredStateEnforce, DesiredStateReport)
+	}
+	if desired.Interval != "" {
+		interval, err := time.ParseDuration(desired.Interval)
+		if err != nil {
+			return fmt.Errorf("%w: interval: %v", ErrInvalidDesiredState, err)
+		}
+		if interval < minDesiredStateInterval {
+			return fmt.Errorf("%w: interval must be at least %s", ErrInvalidDesiredState, minDesiredStateInterval)
+		}
+	}
+	for _, name := range sortedSections(desired.Sections) {
+		reconciler, exists := d.section(name)
+		if !exists {
+			return fmt.Errorf("%w: unknown section %s", ErrInvalidDesiredState, name)
+		}
+		if err := reconciler.Validate(desired.Sections[name]); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidDesiredState, name, err)
+		}
+	}
+	return nil
+}
+
+// Interval returns how often the stored desired state asks to be reconciled
+func (d *DesiredStateReconciler) Interval() time.Duration {
+	desired, _, err := d.Get()
+	if err != nil {
+		return DefaultDesiredStateInterval
+	}
+	return desired.interval()
+}
+
+// Reconcile compares the actual state with the stored desired state, section
+// by section, correcting drift when the document enforces it and dryRun is
+// not set. Reports of runs that are not dry runs are kept for LastReport.
+func (d *DesiredStateReconciler) Reconcile(ctx context.Context, dryRun bool) (DriftReport, error) {
+	d.runMu.Lock()
+	defer d.runMu.Unlock()
+
+	desired, revision, err := d.Get()
+	if err != nil {
+		return DriftReport{}, err
+	}
+	report := DriftReport{
+		Revision:  revision,
+		Mode:      DesiredStateEnforce,
+		DryRun:    dryRun,
+		CheckedAt: time.Now().UTC(),
+		InSync:    true,
+		Drift:     make([]Drift, 0),
+	}
+	if !desired.enforced() {
+		report.Mode = DesiredStateReport
+	}
+	enforce := desired.enforced() && !dryRun
+
+	for _, name := range sortedSections(desired.Sections) {
+		reconciler, exists := d.section(name)
+		if !exists {
+			// Stored by a build that has the section
+			report.setError(name, errors.New("unknown section"))
+			continue
+		}
+		drift, err := reconciler.Reconcile(ctx, desired.Sections[name], enforce)
+		if err != nil {
+			report.setError(name, err)
+			continue
+		}
+		for _, entry := range drift {
+			entry.Section = name
+			if !entry.Corrected {
+				report.InSync = false
+			}
+			report.Drift = append(report.Drift, entry)
+		}
+	}
+
+	if !dryRun {
+		d.mu.Lock()
+		d.last = &report
+		d.mu.Unlock()
+	}
+	return report, nil
+}
+
+func (r *DriftReport) setError(section string, err error) {
+	if r.Errors == nil {
+		r.Errors = make(map[string]string)
+	}
+	r.Errors[section] = err.Error()
+	r.InSync = false
+}
+
+// LastReport returns the report of the last reconciliation that was not a
+// dry run, or false if there has been none
+func (d *DesiredStateReconciler) LastReport() (DriftReport, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.last == nil {
+		return DriftReport{}, false
+	}
+	return *d.last, true
+}
+
+func sortedSections(sections map[string]json.RawMessage) []string {
+	names := make([]string, 0, len(sections))
+	for name := range sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// moduleConfigReconciler stores the configs of the modules section. Only the
+// declared modules are managed, and each config is compared as a whole.
+type moduleConfigReconciler struct {
+	store *ConfigManager
+}
+
+func (m moduleConfigReconciler) decode(section json.RawMessage) (map[string]json.RawMessage, error) {
+	var configs map[string]json.RawMessage
+	if err := json.Unmarshal(section, &configs); err != nil {
+		return nil, errors.New("must map module names to configs")
+	}
+	return configs, nil
+}
+
+func (m moduleConfigReconciler) Validate(section json.RawMessage) error {
+	configs, err := m.decode(section)
+	if err != nil {
+		return err
+	}
+	for _, name := range sortedSections(configs) {
+		if err := checkConfig(configs[name]); err != nil {
+			return fmt.Errorf("module %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (m moduleConfigReconciler) Reconcile(_ context.Context, section json.RawMessage, enforce bool) ([]Drift, error) {
+	configs, err := m.decode(section)
+	if err != nil {
+		return nil, err
+	}
+
+	var drift []Drift
+	for _, name := range sortedSections(configs) {
+		entry := Drift{Resource: name, Kind: DriftMissing}
+		if _, err := m.store.LatestConfigRevision(name); err == nil {
+			actual, err := m.store.GetConfig(name)
+			if err != nil {
+				return nil, err
+			}
+			if entry.Changes, err = DiffConfig(actual, configs[name]); err != nil {
+				return nil, fmt.Errorf("module %s: %w", name, err)
+			}
+			if len(entry.Changes) == 0 {
+				continue
+			}
+			entry.Kind = DriftChanged
+		} else if !errors.Is(err, ErrConfigRevisionNotFound) {
+			return nil, err
+		}
+
+		if enforce {
+			if err := m.store.SetConfig(name, configs[name]); err != nil {
+				entry.Error = err.Error()
+			} else {
+				entry.Corrected = true
+			}
+		}
+		drift = append(drift, entry)
+	}
+	return drift, nil
+}
@@ -0,0 +1,218 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// CheckStatus is the outcome of a diagnostic check, ordered from best to worst
+type CheckStatus string
+
+const (
+	CheckPass CheckStatus = "pass"
+	CheckWarn CheckStatus = "warn"
+	CheckFail CheckStatus = "fail"
+)
+
+func (s CheckStatus) rank() int {
+	switch s {
+	case CheckPass:
+		return 0
+	case CheckWarn:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// checkTimeout bounds each diagnostic check
+const checkTimeout = 10 * time.Second
+
+// CheckResult reports one diagnostic check
+type CheckResult struct {
+	Name    string      `json:"name"`
+	Status  CheckStatus `json:"status"`
+	Message string      `json:"message"`
+}
+
+// DiagnosticReport collects every check; its status is the worst of theirs
+type DiagnosticReport struct {
+	Status CheckStatus   `json:"status"`
+	Checks []CheckResult `json:"checks"`
+	Time   time.Time     `json:"time"`
+}
+
+// DiagnosticCheck inspects one aspect of the node
+type DiagnosticCheck func(ctx context.Context) (CheckStatus, string)
+
+// Diagnostics runs the checks registered by the service and its modules
+type Diagnostics struct {
+	mu     sync.Mutex
+	names  []string
+	checks map[string]DiagnosticCheck
+}
+
+func NewDiagnostics() *Diagnostics {
+	return &Diagnostics{checks: make(map[string]DiagnosticCheck)}
+}
+
+// Register adds a check, replacing one of the same name
+func (d *Diagnostics) Register(name string, check DiagnosticCheck) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, exists := d.checks[name]; !exists {
+		d.names = append(d.names, name)
+	}
+	d.checks[name] = check
+}
+
+// Run runs every check in parallel, each within checkTimeout, and reports
+// them in the order registered. A check that panics or overruns fails.
+func (d *Diagnostics) Run(ctx context.Context) DiagnosticReport {
+	d.mu.Lock()
+	names := append([]string(nil), d.names...)
+	checks := make([]DiagnosticCheck, len(names))
+	for i, name := range names {
+		checks[i] = d.checks[name]
+	}
+	d.mu.Unlock()
+
+	report := DiagnosticReport{Status: CheckPass, Checks: make([]CheckResult, len(names)), Time: time.Now().UTC()}
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string, check DiagnosticCheck) {
+			defer wg.Done()
+			report.Checks[i] = runCheck(ctx, name, check)
+		}(i, name, checks[i])
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status.rank() > report.Status.rank() {
+			report.Status = result.Status
+		}
+	}
+	return report
+}
+
+func runCheck(ctx context.Context, name string, check DiagnosticCheck) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	result := CheckResult{Name: name}
+	err := withDeadline(ctx, 0, func() error {
+		return protect(name, "diagnostic", func() error {
+			result.Status, result.Message = check(ctx)
+			return nil
+		})
+	})
+	if err != nil {
+		// The check may still be writing result
+		return CheckResult{Name: name, Status: CheckFail, Message: err.Error()}
+	}
+	return result
+}
+
+// CheckDatabase runs SQLite's integrity check on an open database
+func CheckDatabase(db *sql.DB) DiagnosticCheck {
+	return func(ctx context.Context) (CheckStatus, string) {
+		var result string
+		if err := db.QueryRowContext(ctx, `PRAGMA quick_check`).Scan(&result); err != nil {
+			return CheckFail, fmt.Sprintf("integrity check failed: %v", err)
+		}
+		if result != "ok" {
+			return CheckFail, result
+		}
+		return CheckPass, "ok"
+	}
+}
+
+// CheckDatabaseFile checks a SQLite file without holding it open. A missing
+// file passes, as it is created on first start.
+func CheckDatabaseFile(path string) DiagnosticCheck {
+	return func(ctx context.Context) (CheckStatus, string) {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return CheckPass, fmt.Sprintf("%s does not exist yet", path)
+		}
+		db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+		if err != nil {
+			return CheckFail, err.Error()
+		}
+		defer db.Close()
+		status, message := CheckDatabase(db)(ctx)
+		return status, path + ": " + message
+	}
+}
+
+// CheckIntegrity checks the config database
+func (cm *ConfigManager) CheckIntegrity() DiagnosticCheck {
+	return CheckDatabase(cm.db)
+}
+
+// CheckDiskSpace warns when the filesystem holding path has less than
+// warnBytes free, and fails below failBytes
+func CheckDiskSpace(path string, warnBytes, failBytes uint64) DiagnosticCheck {
+	return func(ctx context.Context) (CheckStatus, string) {
+		free, err := freeSpace(path)
+		if err != nil {
+			return CheckWarn, fmt.Sprintf("cannot read free space at %s: %v", path, err)
+		}
+		message := fmt.Sprintf("%d MB free at %s", free>>20, path)
+		switch {
+		case free < failBytes:
+			return CheckFail, message
+		case free < warnBytes:
+			return CheckWarn, message
+		default:
+			return CheckPass, message
+		}
+	}
+}
+
+// CheckPortBindable fails when nothing can listen on a TCP address
+func CheckPortBindable(address string) DiagnosticCheck {
+	return func(ctx context.Context) (CheckStatus, string) {
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			return CheckFail, fmt.Sprintf("cannot listen on %s: %v", address, err)
+		}
+		listener.Close()
+		return CheckPass, fmt.Sprintf("%s is free", address)
+	}
+}
+
+// CheckReachable warns about TCP addresses that do not accept connections,
+// and fails when none of them do
+func CheckReachable(addresses []string) DiagnosticCheck {
+	return func(ctx context.Context) (CheckStatus, string) {
+		if len(addresses) == 0 {
+			return CheckPass, "no peers configured"
+		}
+
+		var dialer net.Dialer
+		var unreachable []string
+		for _, address := range addresses {
+			conn, err := dialer.DialContext(ctx, "tcp", address)
+			if err != nil {
+				unreachable = append(unreachable, address)
+				continue
+			}
+			conn.Close()
+		}
+		switch {
+		case len(unreachable) == len(addresses):
+			return CheckFail, fmt.Sprintf("no peer reachable: %v", unreachable)
+		case len(unreachable) > 0:
+			return CheckWarn, fmt.Sprintf("%d of %d peers unreachable: %v", len(unreachable), len(addresses), unreachable)
+		default:
+			return CheckPass, fmt.Sprintf("%d peers reachable", len(addresses))
+		}
+	}
+}
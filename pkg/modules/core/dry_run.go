@@ -0,0 +1,106 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/theaxiomverse/hydap-api/pkg/modules/base"
+)
+
+var ErrDryRunUnsupported = errors.New("dry-run validation not configured")
+
+// LoaderFactory builds a module loader bound to the given dependencies
+type LoaderFactory func(configManager *ConfigManager, metrics *MetricsExporter, logger *ModuleLogger) base.ModuleLoader
+
+// DryRunResult reports whether a module config would load and initialize
+type DryRunResult struct {
+	Module string   `json:"module"`
+	Valid  bool     `json:"valid"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// SetSandboxLoader sets the factory DryRun uses to load modules in isolation
+func (r *ModuleRegistry) SetSandboxLoader(factory LoaderFactory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sandboxLoader = factory
+}
+
+// DryRun loads and initializes a module from config, then terminates it. The
+// module gets its own in-memory config store and metrics, so nothing is
+// registered and stored configuration is untouched. Resources acquired
+// during Initialize, such as listeners, are real until termination, so a
+// config that reuses a live module's ports reports the conflict.
+func (r *ModuleRegistry) DryRun(ctx context.Context, config base.ModuleConfig) (DryRunResult, error) {
+	r.mu.RLock()
+	factory := r.sandboxLoader
+	var missing []string
+	for _, dep := range config.DependsOn {
+		if _, exists := r.modules[dep]; !exists {
+			missing = append(missing, dep)
+		}
+	}
+	r.mu.RUnlock()
+
+	if factory == nil {
+		return DryRunResult{}, ErrDryRunUnsupported
+	}
+
+	configManager, err := NewMemoryConfigManager()
+	if err != nil {
+		return DryRunResult{}, fmt.Errorf("failed to create sandbox config: %w", err)
+	}
+	defer configManager.Close()
+
+	result := DryRunResult{Module: config.Name}
+	for _, dep := range missing {
+		result.Errors = append(result.Errors, fmt.Sprintf("missing dependency %s", dep))
+	}
+
+	logger := &ModuleLogger{Outputs: make(map[string]*os.File)}
+	loader := factory(configManager, NewMetricsExporter(), logger)
+	mod, err := loader.LoadFromConfig(config)
+	if err != nil {
+		result.Errors = append(result.Errors, errorMessages(err)...)
+		return result, nil
+	}
+
+	timeouts, err := TimeoutsFromConfig(config.Config)
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return result, nil
+	}
+	initErr := withDeadline(ctx, timeouts.Initialize, func() error {
+		return protect(config.Name, "Initialize", mod.Initialize)
+	})
+	if initErr != nil {
+		result.Errors = append(result.Errors, errorMessages(initErr)...)
+	}
+	// Roll back whatever Initialize set up; after a failed Initialize the
+	// module may be half-built, so only report teardown errors otherwise
+	if err := withDeadline(ctx, timeouts.Terminate, func() error {
+		return protect(config.Name, "Terminate", mod.Terminate)
+	}); err != nil && initErr == nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("failed to terminate: %v", err))
+	}
+
+	result.Valid = len(result.Errors) == 0
+	return result, nil
+}
+
+// errorMessages flattens joined errors, such as a module's config
+// validation failures, into one message each
+func errorMessages(err error) []string {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if joined, ok := e.(interface{ Unwrap() []error }); ok {
+			var messages []string
+			for _, inner := range joined.Unwrap() {
+				messages = append(messages, errorMessages(inner)...)
+			}
+			return messages
+		}
+	}
+	return []string{err.Error()}
+}
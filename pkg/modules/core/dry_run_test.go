@@ -0,0 +1,137 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/base"
+)
+
+// testModule is a module whose Initialize and Terminate run the given hooks
+type testModule struct {
+	base.BaseModule
+	initialize func() error
+	terminate  func() error
+}
+
+func newTestModule(name string) *testModule {
+	metadata := base.NewModuleMetadata(name, "1.0.0", "test module", "test", "MIT")
+	return &testModule{BaseModule: *base.CreateNewModule(metadata, nil).(*base.BaseModule)}
+}
+
+func (m *testModule) Initialize() error {
+	if err := m.BaseModule.Initialize(); err != nil {
+		return err
+	}
+	if m.initialize != nil {
+		if err := m.initialize(); err != nil {
+			m.SetState(base.StateError)
+			return err
+		}
+	}
+	m.SetState(base.StateRunning)
+	return nil
+}
+
+func (m *testModule) Terminate() error {
+	if m.terminate != nil {
+		if err := m.terminate(); err != nil {
+			return err
+		}
+	}
+	return m.BaseModule.Terminate()
+}
+
+// testLoader builds modules with a function
+type testLoader func(config base.ModuleConfig) (base.Module, error)
+
+func (l testLoader) Load(path string) (base.Module, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (l testLoader) LoadFromConfig(config base.ModuleConfig) (base.Module, error) {
+	return l(config)
+}
+
+func TestDryRunReportsProblems(t *testing.T) {
+	registry := NewModuleRegistry(nil)
+	_, err := registry.DryRun(context.Background(), base.ModuleConfig{Name: "test"})
+	assert.ErrorIs(t, err, ErrDryRunUnsupported)
+
+	var loaded []*testModule
+	registry.SetSandboxLoader(func(configManager *ConfigManager, metrics *MetricsExporter, logger *ModuleLogger) base.ModuleLoader {
+		return testLoader(func(config base.ModuleConfig) (base.Module, error) {
+			if config.Name == "unknown" {
+				return nil, fmt.Errorf("no loader for module: %s", config.Name)
+			}
+			mod := newTestModule(config.Name)
+			mod.initialize = func() error {
+				var errs []error
+				if _, ok := config.Config["port"].(float64); !ok {
+					errs = append(errs, errors.New("port: required"))
+				}
+				if _, ok := config.Config["host"].(string); !ok {
+					errs = append(errs, errors.New("host: required"))
+				}
+				return errors.Join(errs...)
+			}
+			loaded = append(loaded, mod)
+			return mod, nil
+		})
+	})
+
+	result, err := registry.DryRun(context.Background(), base.ModuleConfig{
+		Name:   "test",
+		Config: map[string]interface{}{"port": float64(8080), "host": "localhost"},
+	})
+	require.NoError(t, err)
+	assert.True(t, result.Valid)
+	assert.Empty(t, result.Errors)
+
+	// The module was started and torn down, and never registered
+	require.Len(t, loaded, 1)
+	assert.Equal(t, base.StateUninitialized, loaded[0].GetState())
+	_, exists := registry.Get("test")
+	assert.False(t, exists)
+
+	result, err = registry.DryRun(context.Background(), base.ModuleConfig{
+		Name:      "test",
+		DependsOn: []string{"storage"},
+		Config:    map[string]interface{}{},
+	})
+	require.NoError(t, err)
+	assert.False(t, result.Valid)
+	assert.Equal(t, []string{"missing dependency storage", "port: required", "host: required"}, result.Errors)
+
+	result, err = registry.DryRun(context.Background(), base.ModuleConfig{Name: "unknown"})
+	require.NoError(t, err)
+	assert.False(t, result.Valid)
+	assert.Equal(t, []string{"no loader for module: unknown"}, result.Errors)
+
+	result, err = registry.DryRun(context.Background(), base.ModuleConfig{
+		Name:   "test",
+		Config: map[string]interface{}{"timeouts": "soon"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"timeouts: must be a map of durations"}, result.Errors)
+}
+
+func TestDryRunReportsTeardownFailure(t *testing.T) {
+	registry := NewModuleRegistry(nil)
+	registry.SetSandboxLoader(func(configManager *ConfigManager, metrics *MetricsExporter, logger *ModuleLogger) base.ModuleLoader {
+		return testLoader(func(config base.ModuleConfig) (base.Module, error) {
+			mod := newTestModule(config.Name)
+			mod.terminate = func() error { return errors.New("listener still open") }
+			return mod, nil
+		})
+	})
+
+	result, err := registry.DryRun(context.Background(), base.ModuleConfig{Name: "test"})
+	require.NoError(t, err)
+	assert.False(t, result.Valid)
+	assert.Equal(t, []string{"failed to terminate: listener still open"}, result.Errors)
+}
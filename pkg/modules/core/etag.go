@@ -0,0 +1,90 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// ETag serves the successful responses of the handlers it wraps with an
+// ETag computed from the body, and answers a request whose If-None-Match
+// names the current tag with 304 Not Modified and no body. The tag is weak
+// since the body may be compressed on the way out. Handlers that stream
+// should not be wrapped: the response is buffered to hash it. Watch
+// requests pass through, as they stream.
+func ETag(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (r.Method != http.MethodGet && r.Method != http.MethodHead) || IsWatch(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		buffered := &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+		next.ServeHTTP(buffered, r)
+
+		header := w.Header()
+		for key, values := range buffered.header {
+			header[key] = values
+		}
+		if buffered.status != http.StatusOK {
+			w.WriteHeader(buffered.status)
+			w.Write(buffered.body.Bytes())
+			return
+		}
+
+		sum := sha256.Sum256(buffered.body.Bytes())
+		tag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+		header.Set("ETag", tag)
+		if etagMatches(r.Header.Get("If-None-Match"), tag) {
+			header.Del("Content-Length")
+			header.Del("Content-Type")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write(buffered.body.Bytes())
+	})
+}
+
+// etagMatches reports whether an If-None-Match header names tag, comparing
+// weakly as the header requires
+func etagMatches(ifNoneMatch, tag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	opaque := strings.TrimPrefix(tag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == opaque {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedResponse holds a handler's response until it has been tagged
+type bufferedResponse struct {
+	header      http.Header
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.wroteHeader {
+		return
+	}
+	b.status = status
+	b.wroteHeader = true
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.wroteHeader = true
+	return b.body.Write(p)
+}
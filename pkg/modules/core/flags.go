@@ -0,0 +1,287 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FlagsConfigKey is the ConfigManager entry holding the stored feature flags,
+// which gives them the same revision history as module configs
+const FlagsConfigKey = "feature_flags"
+
+// flagsRefreshInterval bounds how stale flags changed by another process may be
+const flagsRefreshInterval = 5 * time.Second
+
+var ErrFlagNotDefined = errors.New("feature flag not defined")
+
+// FeatureFlag is the stored setting of a flag. A flag is off unless Enabled;
+// when enabled it is on for the listed Nodes and for Rollout percent of the
+// others, or for every node when neither is set.
+type FeatureFlag struct {
+	Name    string   `json:"name"`
+	Enabled bool     `json:"enabled"`
+	Rollout int      `json:"rollout,omitempty"` // Percent of nodes, chosen by a hash of flag and node
+	Nodes   []string `json:"nodes,omitempty"`
+}
+
+// FlagDefinition declares a flag and its setting when none is stored
+type FlagDefinition struct {
+	Name        string `json:"name"`
+	Default     bool   `json:"default"`
+	Description string `json:"description"`
+}
+
+// FlagStatus is a flag's definition, its stored setting if any, and whether
+// it is on for the node asked about
+type FlagStatus struct {
+	FlagDefinition
+	Setting *FeatureFlag `json:"setting,omitempty"`
+	Active  bool         `json:"active"`
+}
+
+var (
+	flagDefinitionsMu sync.RWMutex
+	flagDefinitions   = make(map[string]FlagDefinition)
+)
+
+// DefineFlag declares a feature flag. Only defined flags can be set.
+func DefineFlag(name string, defaultEnabled bool, description string) {
+	flagDefinitionsMu.Lock()
+	defer flagDefinitionsMu.Unlock()
+	flagDefinitions[name] = FlagDefinition{Name: name, Default: defaultEnabled, Description: description}
+}
+
+func flagDefinition(name string) (FlagDefinition, bool) {
+	flagDefinitionsMu.RLock()
+	defer flagDefinitionsMu.RUnlock()
+	definition, exists := flagDefinitions[name]
+	return definition, exists
+}
+
+// FeatureFlags evaluates flags stored in a ConfigManager. Changes made
+// through it apply at once; changes stored by another process are picked up
+// within flagsRefreshInterval.
+type FeatureFlags struct {
+	mu        sync.RWMutex
+	store     *ConfigManager // Nil keeps flags in memory only
+	flags     map[string]FeatureFlag
+	revision  int
+	checkedAt time.Time
+}
+
+// NewFeatureFlags reads flags from store, or keeps them in memory when
+// store is nil
+func NewFeatureFlags(store *ConfigManager) (*FeatureFlags, error) {
+	f := &FeatureFlags{store: store, flags: make(map[string]FeatureFlag)}
+	if err := f.refresh(true); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Flags returns the feature flags stored in this manager, shared by every
+// caller so changes apply at once
+func (cm *ConfigManager) Flags() *FeatureFlags {
+	cm.flagsOnce.Do(func() {
+		flags, err := NewFeatureFlags(cm)
+		if err != nil {
+			// Unreadable flags fall back to their defaults until the next refresh
+			flags = &FeatureFlags{store: cm, flags: make(map[string]FeatureFlag), checkedAt: time.Now()}
+		}
+		cm.flags = flags
+	})
+	return cm.flags
+}
+
+// Enabled reports whether a flag is on for a node. Undefined flags are off,
+// and a nil FeatureFlags reports every flag's default.
+func (f *FeatureFlags) Enabled(name, nodeID string) bool {
+	var setting FeatureFlag
+	stored := false
+	if f != nil {
+		f.refresh(false)
+		f.mu.RLock()
+		setting, stored = f.flags[name]
+		f.mu.RUnlock()
+	}
+
+	if !stored {
+		definition, _ := flagDefinition(name)
+		return definition.Default
+	}
+	return setting.active(nodeID)
+}
+
+func (flag FeatureFlag) active(nodeID string) bool {
+	if !flag.Enabled {
+		return false
+	}
+	for _, node := range flag.Nodes {
+		if node == nodeID {
+			return true
+		}
+	}
+	if flag.Rollout > 0 {
+		return flagBucket(flag.Name, nodeID) < flag.Rollout
+	}
+	return len(flag.Nodes) == 0
+}
+
+// flagBucket places a node in one of 100 buckets, independently per flag,
+// so raising a rollout only adds nodes
+func flagBucket(name, nodeID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(nodeID))
+	return int(h.Sum32() % 100)
+}
+
+// Set stores a flag's setting
+func (f *FeatureFlags) Set(flag FeatureFlag) error {
+	if _, defined := flagDefinition(flag.Name); !defined {
+		return fmt.Errorf("%w: %s", ErrFlagNotDefined, flag.Name)
+	}
+	if flag.Rollout < 0 || flag.Rollout > 100 {
+		return fmt.Errorf("flag %s: rollout must be between 0 and 100", flag.Name)
+	}
+	for _, node := range flag.Nodes {
+		if node == "" {
+			return fmt.Errorf("flag %s: empty node ID", flag.Name)
+		}
+	}
+	return f.update(func(flags map[string]FeatureFlag) { flags[flag.Name] = flag })
+}
+
+// Reset removes a flag's setting, returning it to its default
+func (f *FeatureFlags) Reset(name string) error {
+	if _, defined := flagDefinition(name); !defined {
+		return fmt.Errorf("%w: %s", ErrFlagNotDefined, name)
+	}
+	return f.update(func(flags map[string]FeatureFlag) { delete(flags, name) })
+}
+
+// List returns every defined or stored flag, in name order, evaluated for
+// a node
+func (f *FeatureFlags) List(nodeID string) []FlagStatus {
+	f.refresh(false)
+
+	f.mu.RLock()
+	stored := make(map[string]FeatureFlag, len(f.flags))
+	for name, flag := range f.flags {
+		stored[name] = flag
+	}
+	f.mu.RUnlock()
+
+	names := make(map[string]bool, len(stored))
+	for name := range stored {
+		names[name] = true
+	}
+	flagDefinitionsMu.RLock()
+	for name := range flagDefinitions {
+		names[name] = true
+	}
+	flagDefinitionsMu.RUnlock()
+
+	statuses := make([]FlagStatus, 0, len(names))
+	for name := range names {
+		definition, defined := flagDefinition(name)
+		if !defined {
+			// Stored by another build that defines it
+			definition.Name = name
+		}
+		status := FlagStatus{FlagDefinition: definition, Active: definition.Default}
+		if setting, exists := stored[name]; exists {
+			status.Setting = &setting
+			status.Active = setting.active(nodeID)
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// update applies change to the stored flags and saves them
+func (f *FeatureFlags) update(change func(map[string]FeatureFlag)) error {
+	if err := f.refresh(true); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	flags := make(map[string]FeatureFlag, len(f.flags))
+	for name, flag := range f.flags {
+		flags[name] = flag
+	}
+	change(flags)
+
+	if f.store != nil {
+		encoded, err := json.Marshal(flags)
+		if err != nil {
+			return fmt.Errorf("failed to encode feature flags: %w", err)
+		}
+		if err := f.store.SetConfig(FlagsConfigKey, encoded); err != nil {
+			return err
+		}
+		if revision, err := f.store.LatestConfigRevision(FlagsConfigKey); err == nil {
+			f.revision = revision
+		}
+	}
+	f.flags = flags
+	return nil
+}
+
+// refresh reloads the stored flags when their revision has changed, at most
+// every flagsRefreshInterval unless forced
+func (f *FeatureFlags) refresh(force bool) error {
+	if f.store == nil {
+		return nil
+	}
+
+	f.mu.RLock()
+	fresh := !force && time.Since(f.checkedAt) < flagsRefreshInterval
+	f.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !force && time.Since(f.checkedAt) < flagsRefreshInterval {
+		return nil
+	}
+	f.checkedAt = time.Now()
+
+	revision, err := f.store.LatestConfigRevision(FlagsConfigKey)
+	if errors.Is(err, ErrConfigRevisionNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if revision == f.revision {
+		return nil
+	}
+
+	stored, err := f.store.GetConfigRevision(FlagsConfigKey, revision)
+	if err != nil {
+		return err
+	}
+	flags := make(map[string]FeatureFlag)
+	if err := json.Unmarshal(stored.Config, &flags); err != nil {
+		return fmt.Errorf("failed to decode feature flags: %w", err)
+	}
+	for name, flag := range flags {
+		flag.Name = name
+		flags[name] = flag
+	}
+	f.flags = flags
+	f.revision = revision
+	return nil
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+)
+
+var ErrInvalidGateways = errors.New("invalid gateway set")
+
+const (
+	// GatewayHeader names the gateway instance that served a module's
+	// request
+	GatewayHeader = "X-Hydap-Gateway"
+	// ForwardedByHeader names the gateway that forwarded a request to its
+	// owner. Forwarded requests are always served where they arrive, so a
+	// request is forwarded at most once even while gateways disagree on the
+	// set.
+	ForwardedByHeader = "X-Hydap-Forwarded-By"
+)
+
+// GatewayRouter spreads modules over the gateway instances fronting one
+// cluster. Each module is owned by one gateway, chosen by rendezvous hashing
+// of its name, and requests for it that reach any other gateway are proxied
+// to the owner, so clients may send them to any gateway. Requests for routes
+// that belong to no module are served locally.
+type GatewayRouter struct {
+	self     string
+	gateways []string
+	proxies  map[string]*httputil.ReverseProxy
+	routes   moduleRoutes
+}
+
+// NewGatewayRouter routes among gateways, by instance ID to base URL, as the
+// gateway self, which must be one of them
+func NewGatewayRouter(self string, gateways map[string]string) (*GatewayRouter, error) {
+	if _, ok := gateways[self]; !ok {
+		return nil, fmt.Errorf("%w: gateway %q is not in the set", ErrInvalidGateways, self)
+	}
+
+	g := &GatewayRouter{
+		self:    self,
+		proxies: make(map[string]*httputil.ReverseProxy, len(gateways)),
+	}
+	for id, address := range gateways {
+		target, err := url.Parse(address)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			return nil, fmt.Errorf("%w: gateway %s has URL %q, want http(s)://host[:port]", ErrInvalidGateways, id, address)
+		}
+		g.gateways = append(g.gateways, id)
+		if id != self {
+			g.proxies[id] = g.newProxy(target)
+		}
+	}
+	sort.Strings(g.gateways)
+	return g, nil
+}
+
+func (g *GatewayRouter) newProxy(target *url.URL) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			r.SetXForwarded()
+			r.Out.Header.Set(ForwardedByHeader, g.self)
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			respondError(w, http.StatusBadGateway, fmt.Sprintf("gateway %s is unreachable: %v", g.Owner(g.routes.moduleFor(r.URL.Path)), err))
+		},
+	}
+}
+
+// Route assigns the routes under prefix to a module, as TokenAuth.Route
+// does
+func (g *GatewayRouter) Route(prefix, module string) {
+	g.routes.add(prefix, module)
+}
+
+// FixedRoute marks a route of the module API itself, as TokenAuth.FixedRoute
+// does
+func (g *GatewayRouter) FixedRoute(name string) {
+	g.routes.addFixed(name)
+}
+
+// Gateways returns the IDs of the gateways in the set, sorted
+func (g *GatewayRouter) Gateways() []string {
+	return append([]string(nil), g.gateways...)
+}
+
+// Owner returns the ID of the gateway owning a module. Gateways rank by the
+// hash of their ID with the module name, so adding or removing a gateway
+// only moves the modules it ranks first for.
+func (g *GatewayRouter) Owner(module string) string {
+	var owner string
+	var best uint64
+	for _, id := range g.gateways {
+		sum := sha256.Sum256([]byte(id + ":" + module))
+		if score := binary.BigEndian.Uint64(sum[:8]); owner == "" || score > best {
+			owner, best = id, score
+		}
+	}
+	return owner
+}
+
+// Middleware proxies module requests this gateway does not own to their
+// owner and names the serving gateway on the module requests it serves
+func (g *GatewayRouter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		module := g.routes.moduleFor(r.URL.Path)
+		if module == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if owner := g.Owner(module); owner != g.self && r.Header.Get(ForwardedByHeader) == "" {
+			g.proxies[owner].ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set(GatewayHeader, g.self)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,192 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collectable is implemented by stores that can evict expired entries
+type Collectable interface {
+	// Collect removes entries last touched before cutoff and returns how many were removed
+	Collect(cutoff time.Time) int
+}
+
+// CollectableFunc adapts a function to the Collectable interface
+type CollectableFunc func(cutoff time.Time) int
+
+func (f CollectableFunc) Collect(cutoff time.Time) int {
+	return f(cutoff)
+}
+
+// RetentionPolicy defines how long entries of a store are retained
+type RetentionPolicy struct {
+	Store string
+	TTL   time.Duration
+}
+
+// GCStats summarizes garbage collection activity
+type GCStats struct {
+	Runs      uint64            `json:"runs"`
+	Collected map[string]uint64 `json:"collected"`
+	LastRun   time.Time         `json:"lastRun"`
+}
+
+type gcStore struct {
+	store  Collectable
+	policy RetentionPolicy
+}
+
+// GarbageCollector periodically applies retention policies to registered stores
+type GarbageCollector struct {
+	mu        sync.Mutex
+	stores    map[string]*gcStore
+	stats     GCStats
+	interval  time.Duration
+	clock     Clock
+	stop      chan struct{}
+	collected *prometheus.CounterVec
+	runs      prometheus.Counter
+}
+
+func NewGarbageCollector(module string, interval time.Duration, metrics *MetricsExporter) *GarbageCollector {
+	gc := &GarbageCollector{
+		stores:   make(map[string]*gcStore),
+		interval: interval,
+		clock:    SystemClock,
+		stats: GCStats{
+			Collected: make(map[string]uint64),
+		},
+		collected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "gc_collected_total",
+			Help:        "Total number of records removed by garbage collection",
+			ConstLabels: prometheus.Labels{"module": module},
+		}, []string{"store"}),
+		runs: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "gc_runs_total",
+			Help:        "Total number of garbage collection runs",
+			ConstLabels: prometheus.Labels{"module": module},
+		}),
+	}
+
+	if metrics != nil {
+		// A collector rebuilt for the same module, as on restart, keeps
+		// counting where the last one left off
+		gc.collected = registerOnce(metrics.registry, gc.collected).(*prometheus.CounterVec)
+		gc.runs = registerOnce(metrics.registry, gc.runs).(prometheus.Counter)
+	}
+	return gc
+}
+
+// registerOnce registers collector, or returns the one already registered
+// under its name
+func registerOnce(registry *prometheus.Registry, collector prometheus.Collector) prometheus.Collector {
+	if err := registry.Register(collector); err != nil {
+		var registered prometheus.AlreadyRegisteredError
+		if errors.As(err, &registered) {
+			return registered.ExistingCollector
+		}
+		panic(err)
+	}
+	return collector
+}
+
+// Register adds a store under the given retention policy
+func (gc *GarbageCollector) Register(store Collectable, policy RetentionPolicy) error {
+	if policy.TTL <= 0 {
+		return fmt.Errorf("invalid TTL for store %s: %s", policy.Store, policy.TTL)
+	}
+
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	if _, exists := gc.stores[policy.Store]; exists {
+		return fmt.Errorf("store %s already registered", policy.Store)
+	}
+	gc.stores[policy.Store] = &gcStore{store: store, policy: policy}
+	return nil
+}
+
+// RunOnce applies every retention policy immediately and returns the
+// number of records removed per store
+func (gc *GarbageCollector) RunOnce() map[string]int {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	now := gc.clock.Now()
+	result := make(map[string]int, len(gc.stores))
+	for name, s := range gc.stores {
+		n := s.store.Collect(now.Add(-s.policy.TTL))
+		result[name] = n
+		gc.stats.Collected[name] += uint64(n)
+		gc.collected.WithLabelValues(name).Add(float64(n))
+	}
+
+	gc.stats.Runs++
+	gc.stats.LastRun = now
+	gc.runs.Inc()
+	return result
+}
+
+// Stats returns a snapshot of the collector's counters
+func (gc *GarbageCollector) Stats() GCStats {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	stats := GCStats{
+		Runs:      gc.stats.Runs,
+		LastRun:   gc.stats.LastRun,
+		Collected: make(map[string]uint64, len(gc.stats.Collected)),
+	}
+	for name, n := range gc.stats.Collected {
+		stats.Collected[name] = n
+	}
+	return stats
+}
+
+// UseClock sets the clock retention is measured against. It must be called
+// before Start.
+func (gc *GarbageCollector) UseClock(clock Clock) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	gc.clock = clock
+}
+
+// Start launches the periodic collection loop
+func (gc *GarbageCollector) Start() {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	if gc.stop != nil || gc.interval <= 0 {
+		return
+	}
+	gc.stop = make(chan struct{})
+	go gc.loop(gc.clock.NewTicker(gc.interval), gc.stop)
+}
+
+// Stop halts the periodic collection loop
+func (gc *GarbageCollector) Stop() {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	if gc.stop != nil {
+		close(gc.stop)
+		gc.stop = nil
+	}
+}
+
+func (gc *GarbageCollector) loop(ticker Ticker, stop chan struct{}) {
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			gc.RunOnce()
+		case <-stop:
+			return
+		}
+	}
+}
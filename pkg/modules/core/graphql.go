@@ -0,0 +1,818 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// graphQLMaxDepth bounds how deeply selections nest, so one request
+	// cannot fan out over the whole state graph
+	graphQLMaxDepth = 10
+	// graphQLMaxRequestSize bounds a POSTed request document
+	graphQLMaxRequestSize = 1 << 20
+)
+
+var graphQLBuiltinScalars = map[string]string{
+	"String":  "UTF-8 text",
+	"Int":     "A signed 32-bit integer",
+	"Float":   "A double-precision number",
+	"Boolean": "true or false",
+	"ID":      "A unique identifier, serialized as a string",
+}
+
+// GraphQLResolver resolves a field of source, the value its parent field
+// resolved to, which is nil for fields of the query type. args holds every
+// declared argument, with defaults filled in.
+type GraphQLResolver func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error)
+
+// GraphQLArgument is an argument of a field. Types are written in SDL
+// notation, such as [String!]!, and must be scalars or lists of them.
+type GraphQLArgument struct {
+	Type        string
+	Default     interface{} // Used when the argument is omitted
+	Description string
+}
+
+// GraphQLField is a field of an object type
+type GraphQLField struct {
+	Type        string // In SDL notation, naming an object type or scalar
+	Description string
+	Args        map[string]GraphQLArgument
+	Resolve     GraphQLResolver // Nil reads the field from source
+}
+
+// GraphQLObject is an object type
+type GraphQLObject struct {
+	Description string
+	Fields      map[string]*GraphQLField
+}
+
+// GraphQLSchema executes GraphQL queries against resolvers. It implements
+// the query subset needed to read nested state: operations with variables,
+// aliases, arguments and __typename. Fragments, directives, mutations and
+// introspection are not supported; SDL prints the schema instead.
+type GraphQLSchema struct {
+	query   string
+	types   map[string]*GraphQLObject
+	scalars map[string]string
+}
+
+// NewGraphQLSchema builds a schema whose root is the object type named
+// query. scalars declares custom scalars, by name to description, whose
+// values are serialized as JSON.
+func NewGraphQLSchema(query string, types map[string]*GraphQLObject, scalars map[string]string) (*GraphQLSchema, error) {
+	s := &GraphQLSchema{query: query, types: types, scalars: make(map[string]string)}
+	for name, description := range graphQLBuiltinScalars {
+		s.scalars[name] = description
+	}
+	for name, description := range scalars {
+		if _, exists := types[name]; exists {
+			return nil, fmt.Errorf("GraphQL type %s is both an object and a scalar", name)
+		}
+		s.scalars[name] = description
+	}
+	if _, exists := types[query]; !exists {
+		return nil, fmt.Errorf("GraphQL query type %s is not defined", query)
+	}
+
+	for typeName, object := range types {
+		for fieldName, field := range object.Fields {
+			if !s.isType(field.Type) {
+				return nil, fmt.Errorf("GraphQL field %s.%s has unknown type %s", typeName, fieldName, field.Type)
+			}
+			for argName, arg := range field.Args {
+				if !s.isInputType(arg.Type) {
+					return nil, fmt.Errorf("GraphQL argument %s.%s(%s) has type %s, want a scalar", typeName, fieldName, argName, arg.Type)
+				}
+			}
+		}
+	}
+	return s, nil
+}
+
+// isType reports whether typ is well formed and names a defined type
+func (s *GraphQLSchema) isType(typ string) bool {
+	name := graphQLNamedType(typ)
+	if strings.Count(typ, "[") != strings.Count(typ, "]") || name == "" {
+		return false
+	}
+	_, object := s.types[name]
+	_, scalar := s.scalars[name]
+	return object || scalar
+}
+
+// isInputType reports whether typ is a scalar or a list of them
+func (s *GraphQLSchema) isInputType(typ string) bool {
+	_, scalar := s.scalars[graphQLNamedType(typ)]
+	return scalar && s.isType(typ)
+}
+
+// graphQLNamedType strips the list and non-null wrappers off typ
+func graphQLNamedType(typ string) string {
+	return strings.Trim(typ, "[]!")
+}
+
+func graphQLNonNull(typ string) bool {
+	return strings.HasSuffix(typ, "!")
+}
+
+// graphQLElemType returns the element type of a list type, or "" when typ
+// is not a list
+func graphQLElemType(typ string) string {
+	typ = strings.TrimSuffix(typ, "!")
+	if !strings.HasPrefix(typ, "[") || !strings.HasSuffix(typ, "]") {
+		return ""
+	}
+	return typ[1 : len(typ)-1]
+}
+
+// GraphQLRequest is a query and its variables, as sent over HTTP
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// GraphQLLocation is a position in a request document
+type GraphQLLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// GraphQLError is an error reported in a response, with the path of the
+// field it nulled when it was raised during execution
+type GraphQLError struct {
+	Message   string            `json:"message"`
+	Locations []GraphQLLocation `json:"locations,omitempty"`
+	Path      []interface{}     `json:"path,omitempty"`
+}
+
+// GraphQLResponse is the result of a request. Data is absent when the
+// request could not be executed, and null when a non-null root field failed.
+type GraphQLResponse struct {
+	Data   json.RawMessage `json:"data,omitempty"`
+	Errors []GraphQLError  `json:"errors,omitempty"`
+}
+
+// Execute runs the query of req
+func (s *GraphQLSchema) Execute(ctx context.Context, req GraphQLRequest) GraphQLResponse {
+	doc, err := parseGraphQL(req.Query)
+	if err != nil {
+		var syntaxErr *GraphQLSyntaxError
+		if errors.As(err, &syntaxErr) {
+			return graphQLRequestError(syntaxErr.Error(), GraphQLLocation{Line: syntaxErr.Line, Column: syntaxErr.Column})
+		}
+		return graphQLRequestError(err.Error())
+	}
+
+	op, err := doc.operation(req.OperationName)
+	if err != nil {
+		return graphQLRequestError(err.Error())
+	}
+	if op.kind != "query" {
+		return graphQLRequestError("only queries are supported, not " + op.kind)
+	}
+	variables, errs := s.coerceVariables(op, req.Variables)
+	if len(errs) > 0 {
+		return GraphQLResponse{Errors: errs}
+	}
+	if errs := s.validate(op, variables); len(errs) > 0 {
+		return GraphQLResponse{Errors: errs}
+	}
+
+	exec := &gqlExecution{schema: s, variables: variables}
+	data, ok := exec.selectionSet(ctx, s.query, nil, op.selections, nil)
+	var encoded []byte
+	if ok {
+		encoded, err = json.Marshal(data)
+		if err != nil {
+			return graphQLRequestError("failed to encode response: " + err.Error())
+		}
+	} else {
+		encoded = []byte("null")
+	}
+	return GraphQLResponse{Data: encoded, Errors: exec.errors}
+}
+
+func graphQLRequestError(message string, locations ...GraphQLLocation) GraphQLResponse {
+	return GraphQLResponse{Errors: []GraphQLError{{Message: message, Locations: locations}}}
+}
+
+// operation picks the operation to run from doc
+func (doc *gqlDocument) operation(name string) (*gqlOperation, error) {
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, errors.New("operationName is required for a document with several operations")
+		}
+		return doc.operations[0], nil
+	}
+	for _, op := range doc.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+// coerceVariables checks the variables sent with a request against op's
+// definitions, filling in defaults
+func (s *GraphQLSchema) coerceVariables(op *gqlOperation, values map[string]interface{}) (map[string]interface{}, []GraphQLError) {
+	variables := make(map[string]interface{}, len(op.variables))
+	var errs []GraphQLError
+	for _, definition := range op.variables {
+		if !s.isInputType(definition.typ) {
+			errs = append(errs, GraphQLError{Message: fmt.Sprintf("variable $%s has type %s, want a scalar", definition.name, definition.typ)})
+			continue
+		}
+		value, provided := values[definition.name]
+		if !provided && definition.defaultVal != nil {
+			var err error
+			if value, err = definition.defaultVal.literal(nil); err != nil {
+				errs = append(errs, GraphQLError{Message: fmt.Sprintf("variable $%s: %s", definition.name, err)})
+				continue
+			}
+			provided = true
+		}
+		if !provided {
+			if graphQLNonNull(definition.typ) {
+				errs = append(errs, GraphQLError{Message: fmt.Sprintf("variable $%s of required type %s was not provided", definition.name, definition.typ)})
+			}
+			continue
+		}
+		coerced, err := coerceGraphQLInput(value, definition.typ)
+		if err != nil {
+			errs = append(errs, GraphQLError{Message: fmt.Sprintf("variable $%s: %s", definition.name, err)})
+			continue
+		}
+		variables[definition.name] = coerced
+	}
+	return variables, errs
+}
+
+// validate checks op against the schema before it runs, so a query either
+// runs in full or not at all
+func (s *GraphQLSchema) validate(op *gqlOperation, variables map[string]interface{}) []GraphQLError {
+	types := make(map[string]string, len(op.variables))
+	for _, definition := range op.variables {
+		types[definition.name] = definition.typ
+		// A default stands in for null, so the variable is never null
+		if definition.defaultVal != nil && definition.defaultVal.kind != gqlNullValue && !graphQLNonNull(definition.typ) {
+			types[definition.name] += "!"
+		}
+	}
+	var errs []GraphQLError
+	s.validateSelections(s.query, op.selections, 1, types, variables, &errs)
+	return errs
+}
+
+func (s *GraphQLSchema) validateSelections(typeName string, selections []*gqlField, depth int, types map[string]string, variables map[string]interface{}, errs *[]GraphQLError) {
+	fail := func(field *gqlField, format string, args ...interface{}) {
+		*errs = append(*errs, GraphQLError{
+			Message:   fmt.Sprintf(format, args...),
+			Locations: []GraphQLLocation{{Line: field.line, Column: field.col}},
+		})
+	}
+	if depth > graphQLMaxDepth {
+		fail(selections[0], "selections are nested deeper than %d levels", graphQLMaxDepth)
+		return
+	}
+
+	object := s.types[typeName]
+	seen := make(map[string]bool, len(selections))
+	for _, field := range selections {
+		if seen[field.key()] {
+			fail(field, "field %s is selected more than once; alias one of them", field.key())
+			continue
+		}
+		seen[field.key()] = true
+
+		if field.name == "__typename" {
+			if len(field.args) > 0 || field.selections != nil {
+				fail(field, "__typename takes no arguments or selections")
+			}
+			continue
+		}
+		definition, exists := object.Fields[field.name]
+		if !exists {
+			fail(field, "cannot query field %s on type %s", field.name, typeName)
+			continue
+		}
+
+		provided := make(map[string]bool, len(field.args))
+		for _, arg := range field.args {
+			argDefinition, exists := definition.Args[arg.name]
+			if !exists {
+				fail(field, "unknown argument %s on field %s.%s", arg.name, typeName, field.name)
+				continue
+			}
+			provided[arg.name] = true
+			if arg.value.kind == gqlVariableValue {
+				varType, defined := types[arg.value.raw]
+				if !defined {
+					fail(field, "variable $%s is not defined", arg.value.raw)
+				} else if !graphQLVariableFits(varType, argDefinition.Type) {
+					fail(field, "variable $%s of type %s cannot be used as argument %s of type %s", arg.value.raw, varType, arg.name, argDefinition.Type)
+				}
+				continue
+			}
+			value, err := arg.value.literal(variables)
+			if err == nil {
+				_, err = coerceGraphQLInput(value, argDefinition.Type)
+			}
+			if err != nil {
+				fail(field, "argument %s of field %s.%s: %s", arg.name, typeName, field.name, err)
+			}
+		}
+		for name, argDefinition := range definition.Args {
+			if !provided[name] && argDefinition.Default == nil && graphQLNonNull(argDefinition.Type) {
+				fail(field, "field %s.%s requires argument %s of type %s", typeName, field.name, name, argDefinition.Type)
+			}
+		}
+
+		named := graphQLNamedType(definition.Type)
+		if _, isObject := s.types[named]; isObject {
+			if field.selections == nil {
+				fail(field, "field %s of type %s must have a selection of subfields", field.name, definition.Type)
+				continue
+			}
+			s.validateSelections(named, field.selections, depth+1, types, variables, errs)
+		} else if field.selections != nil {
+			fail(field, "field %s of type %s has no subfields to select", field.name, definition.Type)
+		}
+	}
+}
+
+// graphQLVariableFits reports whether a variable of varType may be passed
+// to an argument of argType
+func graphQLVariableFits(varType, argType string) bool {
+	if graphQLNonNull(argType) && !graphQLNonNull(varType) {
+		return false
+	}
+	return strings.ReplaceAll(varType, "!", "") == strings.ReplaceAll(argType, "!", "")
+}
+
+// literal converts a value written in a document to its JSON form
+func (v gqlValue) literal(variables map[string]interface{}) (interface{}, error) {
+	switch v.kind {
+	case gqlNullValue:
+		return nil, nil
+	case gqlVariableValue:
+		return variables[v.raw], nil
+	case gqlIntValue:
+		n, err := strconv.ParseInt(v.raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %s", v.raw)
+		}
+		return n, nil
+	case gqlFloatValue:
+		return strconv.ParseFloat(v.raw, 64)
+	case gqlStringValue:
+		return v.raw, nil
+	case gqlBooleanValue:
+		return v.raw == "true", nil
+	case gqlListValue:
+		list := make([]interface{}, len(v.list))
+		for i, item := range v.list {
+			value, err := item.literal(variables)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = value
+		}
+		return list, nil
+	case gqlObjectValue:
+		object := make(map[string]interface{}, len(v.fields))
+		for _, field := range v.fields {
+			value, err := field.value.literal(variables)
+			if err != nil {
+				return nil, err
+			}
+			object[field.name] = value
+		}
+		return object, nil
+	}
+	return nil, fmt.Errorf("enum value %s is not supported", v.raw)
+}
+
+// coerceGraphQLInput converts an input value, from a document or decoded
+// JSON, to typ. Ints become int, Floats float64 and IDs string; a single
+// value passed for a list becomes a list of one.
+func coerceGraphQLInput(value interface{}, typ string) (interface{}, error) {
+	if value == nil {
+		if graphQLNonNull(typ) {
+			return nil, fmt.Errorf("expected %s, got null", typ)
+		}
+		return nil, nil
+	}
+	if elem := graphQLElemType(typ); elem != "" {
+		items, isList := value.([]interface{})
+		if !isList {
+			items = []interface{}{value}
+		}
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			coerced, err := coerceGraphQLInput(item, elem)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = coerced
+		}
+		return list, nil
+	}
+
+	name := graphQLNamedType(typ)
+	switch name {
+	case "Int":
+		n, ok := graphQLInteger(value)
+		if !ok || n < math.MinInt32 || n > math.MaxInt32 {
+			return nil, fmt.Errorf("expected Int, got %v", value)
+		}
+		return int(n), nil
+	case "Float":
+		switch number := value.(type) {
+		case float64:
+			return number, nil
+		case int64:
+			return float64(number), nil
+		}
+		return nil, fmt.Errorf("expected Float, got %v", value)
+	case "String":
+		if str, ok := value.(string); ok {
+			return str, nil
+		}
+		return nil, fmt.Errorf("expected String, got %v", value)
+	case "ID":
+		if str, ok := value.(string); ok {
+			return str, nil
+		}
+		if n, ok := graphQLInteger(value); ok {
+			return strconv.FormatInt(n, 10), nil
+		}
+		return nil, fmt.Errorf("expected ID, got %v", value)
+	case "Boolean":
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("expected Boolean, got %v", value)
+	}
+	// Custom scalars are taken as sent
+	return value, nil
+}
+
+// graphQLInteger reads an integer from a document literal or a JSON number
+func graphQLInteger(value interface{}) (int64, bool) {
+	switch number := value.(type) {
+	case int64:
+		return number, true
+	case float64:
+		if number != math.Trunc(number) || math.Abs(number) > 1<<53 {
+			return 0, false
+		}
+		return int64(number), true
+	}
+	return 0, false
+}
+
+// gqlExecution is the state of one running query
+type gqlExecution struct {
+	schema    *GraphQLSchema
+	variables map[string]interface{}
+	errors    []GraphQLError
+}
+
+// gqlObjectResult is an object in the response, keeping its fields in the
+// order they were selected
+type gqlObjectResult []gqlResultField
+
+type gqlResultField struct {
+	key   string
+	value interface{}
+}
+
+func (o gqlObjectResult) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(field.key)
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// selectionSet resolves selections of source, an object of type typeName.
+// It reports false when a non-null field is null, which nulls the object.
+func (e *gqlExecution) selectionSet(ctx context.Context, typeName string, source interface{}, selections []*gqlField, path []interface{}) (gqlObjectResult, bool) {
+	object := e.schema.types[typeName]
+	result := make(gqlObjectResult, 0, len(selections))
+	for _, field := range selections {
+		fieldPath := append(append([]interface{}{}, path...), field.key())
+		if field.name == "__typename" {
+			result = append(result, gqlResultField{key: field.key(), value: typeName})
+			continue
+		}
+
+		definition := object.Fields[field.name]
+		value, err := e.resolve(ctx, definition, field, source)
+		if err != nil {
+			e.fail(field, fieldPath, err.Error())
+			if graphQLNonNull(definition.Type) {
+				return nil, false
+			}
+			result = append(result, gqlResultField{key: field.key(), value: nil})
+			continue
+		}
+		completed, ok := e.complete(ctx, definition.Type, field, value, fieldPath)
+		if !ok {
+			return nil, false
+		}
+		result = append(result, gqlResultField{key: field.key(), value: completed})
+	}
+	return result, true
+}
+
+func (e *gqlExecution) resolve(ctx context.Context, definition *GraphQLField, field *gqlField, source interface{}) (interface{}, error) {
+	if definition.Resolve == nil {
+		return graphQLField(source, field.name), nil
+	}
+
+	args := make(map[string]interface{}, len(definition.Args))
+	for name, argDefinition := range definition.Args {
+		if argDefinition.Default != nil {
+			args[name] = argDefinition.Default
+		}
+	}
+	for _, arg := range field.args {
+		if arg.value.kind == gqlVariableValue {
+			value, set := e.variables[arg.value.raw]
+			if set {
+				args[arg.name] = value
+			}
+			continue
+		}
+		// Validated before execution
+		value, _ := arg.value.literal(e.variables)
+		args[arg.name], _ = coerceGraphQLInput(value, definition.Args[arg.name].Type)
+	}
+	return definition.Resolve(ctx, source, args)
+}
+
+// complete converts a resolved value to typ, reporting false when it is
+// null where typ is non-null
+func (e *gqlExecution) complete(ctx context.Context, typ string, field *gqlField, value interface{}, path []interface{}) (interface{}, bool) {
+	// null propagates to the nearest nullable parent
+	propagate := func() (interface{}, bool) { return nil, !graphQLNonNull(typ) }
+
+	if graphQLIsNull(value) {
+		if graphQLNonNull(typ) {
+			e.fail(field, path, fmt.Sprintf("null returned for non-null field %s", field.key()))
+		}
+		return propagate()
+	}
+
+	if elem := graphQLElemType(typ); elem != "" {
+		items := reflect.ValueOf(value)
+		if items.Kind() != reflect.Slice && items.Kind() != reflect.Array {
+			e.fail(field, path, fmt.Sprintf("field %s resolved to a %T, not a list", field.key(), value))
+			return propagate()
+		}
+		list := make([]interface{}, items.Len())
+		for i := range list {
+			completed, ok := e.complete(ctx, elem, field, items.Index(i).Interface(), append(append([]interface{}{}, path...), i))
+			if !ok {
+				return propagate()
+			}
+			list[i] = completed
+		}
+		return list, true
+	}
+
+	named := graphQLNamedType(typ)
+	if _, isObject := e.schema.types[named]; isObject {
+		result, ok := e.selectionSet(ctx, named, value, field.selections, path)
+		if !ok {
+			return propagate()
+		}
+		return result, true
+	}
+	return value, true
+}
+
+func (e *gqlExecution) fail(field *gqlField, path []interface{}, message string) {
+	e.errors = append(e.errors, GraphQLError{
+		Message:   message,
+		Locations: []GraphQLLocation{{Line: field.line, Column: field.col}},
+		Path:      path,
+	})
+}
+
+// graphQLIsNull reports whether value is null. Nil slices are empty lists.
+func graphQLIsNull(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Interface, reflect.Func:
+		return v.IsNil()
+	}
+	return false
+}
+
+// graphQLField reads the field name from source, a map or a struct, whose
+// fields are matched by JSON name
+func graphQLField(source interface{}, name string) interface{} {
+	if values, ok := source.(map[string]interface{}); ok {
+		return values[name]
+	}
+	v := reflect.ValueOf(source)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		value := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+		if !value.IsValid() {
+			return nil
+		}
+		return value.Interface()
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			structField := t.Field(i)
+			tag, _, _ := strings.Cut(structField.Tag.Get("json"), ",")
+			if structField.Anonymous && tag == "" {
+				if value := graphQLField(v.Field(i).Interface(), name); value != nil {
+					return value
+				}
+				continue
+			}
+			if !structField.IsExported() || tag == "-" {
+				continue
+			}
+			if tag == name || (tag == "" && strings.EqualFold(structField.Name, name)) {
+				return v.Field(i).Interface()
+			}
+		}
+	}
+	return nil
+}
+
+// SDL prints the schema in the GraphQL schema definition language
+func (s *GraphQLSchema) SDL() string {
+	var b strings.Builder
+	writeDescription := func(indent, description string) {
+		if description != "" {
+			fmt.Fprintf(&b, "%s%s\n", indent, strconv.Quote(description))
+		}
+	}
+
+	scalars := make([]string, 0, len(s.scalars))
+	for name := range s.scalars {
+		if _, builtin := graphQLBuiltinScalars[name]; !builtin {
+			scalars = append(scalars, name)
+		}
+	}
+	sort.Strings(scalars)
+	for _, name := range scalars {
+		writeDescription("", s.scalars[name])
+		fmt.Fprintf(&b, "scalar %s\n\n", name)
+	}
+
+	// The query type first, then the others by name
+	names := make([]string, 0, len(s.types))
+	for name := range s.types {
+		if name != s.query {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	names = append([]string{s.query}, names...)
+	for i, name := range names {
+		object := s.types[name]
+		writeDescription("", object.Description)
+		fmt.Fprintf(&b, "type %s {\n", name)
+		fields := make([]string, 0, len(object.Fields))
+		for fieldName := range object.Fields {
+			fields = append(fields, fieldName)
+		}
+		sort.Strings(fields)
+		for _, fieldName := range fields {
+			field := object.Fields[fieldName]
+			writeDescription("  ", field.Description)
+			fmt.Fprintf(&b, "  %s%s: %s\n", fieldName, graphQLArgumentsSDL(field.Args), field.Type)
+		}
+		b.WriteString("}\n")
+		if i < len(names)-1 {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+func graphQLArgumentsSDL(args map[string]GraphQLArgument) string {
+	if len(args) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		arg := args[name]
+		parts[i] = name + ": " + arg.Type
+		if arg.Default != nil {
+			value, _ := json.Marshal(arg.Default)
+			parts[i] += " = " + string(value)
+		}
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// ServeHTTP serves GraphQL over HTTP. Queries are POSTed as JSON with query,
+// operationName and variables, POSTed as application/graphql, or sent as GET
+// parameters of the same names. Requests that cannot run are answered 400.
+func (s *GraphQLSchema) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req GraphQLRequest
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		req.Query = query.Get("query")
+		req.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				writeGraphQLResponse(w, http.StatusBadRequest, graphQLRequestError("invalid variables"))
+				return
+			}
+		}
+	case http.MethodPost:
+		body := http.MaxBytesReader(w, r.Body, graphQLMaxRequestSize)
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType == "application/graphql" {
+			query, err := io.ReadAll(body)
+			if err != nil {
+				writeGraphQLResponse(w, http.StatusRequestEntityTooLarge, graphQLRequestError("request too large"))
+				return
+			}
+			req.Query = string(query)
+		} else if err := json.NewDecoder(body).Decode(&req); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeGraphQLResponse(w, http.StatusRequestEntityTooLarge, graphQLRequestError("request too large"))
+				return
+			}
+			writeGraphQLResponse(w, http.StatusBadRequest, graphQLRequestError("invalid request body"))
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeGraphQLResponse(w, http.StatusMethodNotAllowed, graphQLRequestError("method not allowed"))
+		return
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		writeGraphQLResponse(w, http.StatusBadRequest, graphQLRequestError("missing query"))
+		return
+	}
+
+	response := s.Execute(r.Context(), req)
+	status := http.StatusOK
+	if response.Data == nil {
+		status = http.StatusBadRequest
+	}
+	writeGraphQLResponse(w, status, response)
+}
+
+func writeGraphQLResponse(w http.ResponseWriter, status int, response GraphQLResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,450 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The GraphQL query language subset read by parseGraphQL: operations with
+// variable definitions, fields with aliases and arguments, and nested
+// selection sets. Fragments and directives are rejected.
+
+type gqlTokenKind int
+
+const (
+	gqlEOF gqlTokenKind = iota
+	gqlPunct
+	gqlName
+	gqlInt
+	gqlFloat
+	gqlString
+)
+
+type gqlToken struct {
+	kind  gqlTokenKind
+	value string
+	line  int
+	col   int
+}
+
+// gqlDocument is a parsed request document
+type gqlDocument struct {
+	operations []*gqlOperation
+}
+
+type gqlOperation struct {
+	kind       string // Only "query" is executed
+	name       string
+	variables  []gqlVariable
+	selections []*gqlField
+}
+
+type gqlVariable struct {
+	name       string
+	typ        string // In SDL notation, such as [String!]!
+	defaultVal *gqlValue
+}
+
+type gqlField struct {
+	alias      string
+	name       string
+	args       []gqlArgument
+	selections []*gqlField // Nil for leaf fields
+	line       int
+	col        int
+}
+
+// key names the field in the response
+func (f *gqlField) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type gqlArgument struct {
+	name  string
+	value gqlValue
+}
+
+type gqlValueKind int
+
+const (
+	gqlNullValue gqlValueKind = iota
+	gqlVariableValue
+	gqlIntValue
+	gqlFloatValue
+	gqlStringValue
+	gqlBooleanValue
+	gqlEnumValue
+	gqlListValue
+	gqlObjectValue
+)
+
+type gqlValue struct {
+	kind   gqlValueKind
+	raw    string // Name, number or decoded string
+	list   []gqlValue
+	fields []gqlArgument
+}
+
+// GraphQLSyntaxError is a malformed request document
+type GraphQLSyntaxError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *GraphQLSyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at %d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+type gqlParser struct {
+	src  string
+	pos  int
+	line int
+	col  int
+	tok  gqlToken
+}
+
+// parseGraphQL parses a request document
+func parseGraphQL(src string) (doc *gqlDocument, err error) {
+	p := &gqlParser{src: src, line: 1, col: 1}
+	defer func() {
+		if r := recover(); r != nil {
+			syntaxErr, ok := r.(*GraphQLSyntaxError)
+			if !ok {
+				panic(r)
+			}
+			err = syntaxErr
+		}
+	}()
+
+	p.next()
+	doc = &gqlDocument{}
+	for p.tok.kind != gqlEOF {
+		doc.operations = append(doc.operations, p.operation())
+	}
+	if len(doc.operations) == 0 {
+		p.fail("document has no operations")
+	}
+	return doc, nil
+}
+
+func (p *gqlParser) fail(format string, args ...interface{}) {
+	panic(&GraphQLSyntaxError{Line: p.tok.line, Column: p.tok.col, Message: fmt.Sprintf(format, args...)})
+}
+
+func (p *gqlParser) operation() *gqlOperation {
+	op := &gqlOperation{kind: "query"}
+	if p.peek(gqlPunct, "{") {
+		op.selections = p.selectionSet()
+		return op
+	}
+	if p.tok.kind != gqlName {
+		p.fail("expected an operation, got %q", p.tok.value)
+	}
+	switch p.tok.value {
+	case "query", "mutation", "subscription":
+		op.kind = p.tok.value
+	case "fragment":
+		p.fail("fragments are not supported")
+	default:
+		p.fail("expected an operation, got %q", p.tok.value)
+	}
+	p.next()
+	if p.tok.kind == gqlName {
+		op.name = p.tok.value
+		p.next()
+	}
+	if p.skip(gqlPunct, "(") {
+		for !p.skip(gqlPunct, ")") {
+			p.expect(gqlPunct, "$")
+			variable := gqlVariable{name: p.name()}
+			p.expect(gqlPunct, ":")
+			variable.typ = p.typeRef()
+			if p.skip(gqlPunct, "=") {
+				value := p.value(true)
+				variable.defaultVal = &value
+			}
+			op.variables = append(op.variables, variable)
+		}
+	}
+	p.noDirectives()
+	op.selections = p.selectionSet()
+	return op
+}
+
+func (p *gqlParser) selectionSet() []*gqlField {
+	p.expect(gqlPunct, "{")
+	var fields []*gqlField
+	for !p.skip(gqlPunct, "}") {
+		if p.peek(gqlPunct, "...") {
+			p.fail("fragments are not supported")
+		}
+		fields = append(fields, p.field())
+	}
+	if len(fields) == 0 {
+		p.fail("empty selection set")
+	}
+	return fields
+}
+
+func (p *gqlParser) field() *gqlField {
+	field := &gqlField{line: p.tok.line, col: p.tok.col}
+	field.name = p.name()
+	if p.skip(gqlPunct, ":") {
+		field.alias = field.name
+		field.name = p.name()
+	}
+	if p.skip(gqlPunct, "(") {
+		for !p.skip(gqlPunct, ")") {
+			arg := gqlArgument{name: p.name()}
+			p.expect(gqlPunct, ":")
+			arg.value = p.value(false)
+			field.args = append(field.args, arg)
+		}
+	}
+	p.noDirectives()
+	if p.peek(gqlPunct, "{") {
+		field.selections = p.selectionSet()
+	}
+	return field
+}
+
+func (p *gqlParser) noDirectives() {
+	if p.peek(gqlPunct, "@") {
+		p.fail("directives are not supported")
+	}
+}
+
+// typeRef reads a type reference such as [String!]! back into SDL notation
+func (p *gqlParser) typeRef() string {
+	var typ string
+	if p.skip(gqlPunct, "[") {
+		typ = "[" + p.typeRef() + "]"
+		p.expect(gqlPunct, "]")
+	} else {
+		typ = p.name()
+	}
+	if p.skip(gqlPunct, "!") {
+		typ += "!"
+	}
+	return typ
+}
+
+// value reads an input value; constant values, such as variable defaults,
+// may not refer to variables
+func (p *gqlParser) value(constant bool) gqlValue {
+	tok := p.tok
+	switch {
+	case tok.kind == gqlPunct && tok.value == "$":
+		if constant {
+			p.fail("variables are not allowed here")
+		}
+		p.next()
+		return gqlValue{kind: gqlVariableValue, raw: p.name()}
+	case tok.kind == gqlInt:
+		p.next()
+		return gqlValue{kind: gqlIntValue, raw: tok.value}
+	case tok.kind == gqlFloat:
+		p.next()
+		return gqlValue{kind: gqlFloatValue, raw: tok.value}
+	case tok.kind == gqlString:
+		p.next()
+		return gqlValue{kind: gqlStringValue, raw: tok.value}
+	case tok.kind == gqlName:
+		p.next()
+		switch tok.value {
+		case "true", "false":
+			return gqlValue{kind: gqlBooleanValue, raw: tok.value}
+		case "null":
+			return gqlValue{kind: gqlNullValue}
+		}
+		return gqlValue{kind: gqlEnumValue, raw: tok.value}
+	case tok.kind == gqlPunct && tok.value == "[":
+		p.next()
+		list := gqlValue{kind: gqlListValue}
+		for !p.skip(gqlPunct, "]") {
+			list.list = append(list.list, p.value(constant))
+		}
+		return list
+	case tok.kind == gqlPunct && tok.value == "{":
+		p.next()
+		object := gqlValue{kind: gqlObjectValue}
+		for !p.skip(gqlPunct, "}") {
+			field := gqlArgument{name: p.name()}
+			p.expect(gqlPunct, ":")
+			field.value = p.value(constant)
+			object.fields = append(object.fields, field)
+		}
+		return object
+	}
+	p.fail("expected a value, got %q", tok.value)
+	return gqlValue{}
+}
+
+func (p *gqlParser) name() string {
+	if p.tok.kind != gqlName {
+		p.fail("expected a name, got %q", p.tok.value)
+	}
+	name := p.tok.value
+	p.next()
+	return name
+}
+
+func (p *gqlParser) peek(kind gqlTokenKind, value string) bool {
+	return p.tok.kind == kind && p.tok.value == value
+}
+
+func (p *gqlParser) skip(kind gqlTokenKind, value string) bool {
+	if p.peek(kind, value) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *gqlParser) expect(kind gqlTokenKind, value string) {
+	if !p.skip(kind, value) {
+		if p.tok.kind == gqlEOF {
+			p.fail("expected %q, got end of document", value)
+		}
+		p.fail("expected %q, got %q", value, p.tok.value)
+	}
+}
+
+// next reads the next token, skipping whitespace, commas and comments
+func (p *gqlParser) next() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.advance(1)
+			}
+			continue
+		}
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' && c != ',' {
+			break
+		}
+		p.advance(1)
+	}
+	p.tok = gqlToken{line: p.line, col: p.col}
+	if p.pos >= len(p.src) {
+		p.tok.kind = gqlEOF
+		return
+	}
+
+	start := p.pos
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.advance(3)
+		p.tok.kind, p.tok.value = gqlPunct, "..."
+	case strings.IndexByte("!$()[]{}:=@|&", c) >= 0:
+		p.advance(1)
+		p.tok.kind, p.tok.value = gqlPunct, string(c)
+	case c == '_' || isLetter(c):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || isLetter(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.advance(1)
+		}
+		p.tok.kind, p.tok.value = gqlName, p.src[start:p.pos]
+	case c == '-' || isDigit(c):
+		p.number()
+	case c == '"':
+		p.string()
+	default:
+		r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+		p.fail("unexpected character %q", r)
+	}
+}
+
+func (p *gqlParser) number() {
+	start := p.pos
+	p.tok.kind = gqlInt
+	if p.src[p.pos] == '-' {
+		p.advance(1)
+	}
+	digits := func() {
+		begin := p.pos
+		for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+			p.advance(1)
+		}
+		if p.pos == begin {
+			p.fail("invalid number %q", p.src[start:p.pos])
+		}
+	}
+	digits()
+	if p.pos < len(p.src) && p.src[p.pos] == '.' {
+		p.tok.kind = gqlFloat
+		p.advance(1)
+		digits()
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		p.tok.kind = gqlFloat
+		p.advance(1)
+		if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+			p.advance(1)
+		}
+		digits()
+	}
+	p.tok.value = p.src[start:p.pos]
+}
+
+// string reads a quoted or block string, decoding its escapes
+func (p *gqlParser) string() {
+	p.tok.kind = gqlString
+	if strings.HasPrefix(p.src[p.pos:], `"""`) {
+		p.advance(3)
+		end := strings.Index(p.src[p.pos:], `"""`)
+		if end < 0 {
+			p.fail("unterminated block string")
+		}
+		p.tok.value = strings.TrimSpace(p.src[p.pos : p.pos+end])
+		p.advance(end + 3)
+		return
+	}
+
+	start := p.pos
+	p.advance(1)
+	for {
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' {
+			p.fail("unterminated string")
+		}
+		c := p.src[p.pos]
+		p.advance(1)
+		if c == '\\' && p.pos < len(p.src) {
+			p.advance(1)
+		} else if c == '"' {
+			break
+		}
+	}
+	// GraphQL string escapes are Go's, plus an escaped slash
+	value, err := strconv.Unquote(strings.ReplaceAll(p.src[start:p.pos], `\/`, "/"))
+	if err != nil {
+		p.fail("invalid string %s", p.src[start:p.pos])
+	}
+	p.tok.value = value
+}
+
+func (p *gqlParser) advance(n int) {
+	for i := 0; i < n; i++ {
+		if p.src[p.pos] == '\n' {
+			p.line++
+			p.col = 1
+		} else {
+			p.col++
+		}
+		p.pos++
+	}
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -1,9 +1,12 @@
 package core
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"time"
+
+	"github.com/theaxiomverse/hydap-api/pkg/modules/base"
 )
 
 type HealthEndpoint struct {
@@ -11,11 +14,12 @@ type HealthEndpoint struct {
 }
 
 func (h *HealthEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	statuses := h.registry.GetAllHealth()
+	statuses := h.registry.GetAllHealth(r.Context())
 	json.NewEncoder(w).Encode(statuses)
 }
 
-func (r *ModuleRegistry) GetAllHealth() map[string]ModuleHealth {
+// GetAllHealth checks every module, each within its HealthCheck deadline
+func (r *ModuleRegistry) GetAllHealth(ctx context.Context) map[string]ModuleHealth {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -26,9 +30,31 @@ func (r *ModuleRegistry) GetAllHealth() map[string]ModuleHealth {
 			LastChecked: time.Now(),
 		}
 
-		if err := mod.HealthCheck(); err != nil {
+		// Sent rather than assigned, as a check abandoned at its deadline
+		// may still return
+		results := make(chan base.HealthResult, 1)
+		module := base.Adapt(mod)
+		err := r.call(ctx, mod, "HealthCheck", r.Timeouts(name).HealthCheck, func(ctx context.Context) error {
+			results <- module.HealthCheck(ctx)
+			return nil
+		})
+		if err != nil {
 			status.Status = "unhealthy"
 			status.Error = err.Error()
+		} else if result := <-results; !result.Healthy {
+			status.Status = "unhealthy"
+			status.Error = result.Message
+			status.Checks = result.Checks
+		} else {
+			status.Checks = result.Checks
+		}
+		if record, exists := r.lastPanic(name); exists {
+			status.Status = "unhealthy"
+			status.Panic = &record
+		}
+		if record, exists := r.lastTimeout(name); exists {
+			status.Status = "unhealthy"
+			status.Timeout = &record
 		}
 
 		health[name] = status
@@ -37,7 +63,10 @@ func (r *ModuleRegistry) GetAllHealth() map[string]ModuleHealth {
 }
 
 type ModuleHealth struct {
-	Status      string    `json:"status"`
-	LastChecked time.Time `json:"last_checked"`
-	Error       string    `json:"error,omitempty"`
+	Status      string            `json:"status"`
+	LastChecked time.Time         `json:"last_checked"`
+	Error       string            `json:"error,omitempty"`
+	Checks      map[string]string `json:"checks,omitempty"` // Reported by ModuleV2 modules
+	Panic       *PanicRecord      `json:"panic,omitempty"`
+	Timeout     *TimeoutRecord    `json:"timeout,omitempty"`
 }
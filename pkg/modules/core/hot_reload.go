@@ -1,17 +1,30 @@
 package core
 
 import (
+	"context"
 	"fmt"
 	"github.com/fsnotify/fsnotify"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/base"
 	"log"
 	"os"
 	"path/filepath"
 )
 
+// StateTransfer is implemented by modules that hand their state to the
+// instance replacing them during a hot reload
+type StateTransfer interface {
+	// ExportState serializes the running instance's state
+	ExportState() ([]byte, error)
+	// ImportState restores state exported by the previous instance; it is
+	// called after Initialize and before the new instance is registered
+	ImportState(state []byte) error
+}
+
 type HotReloader struct {
-	watcher  *fsnotify.Watcher
-	registry *ModuleRegistry
-	logger   *log.Logger
+	watcher   *fsnotify.Watcher
+	registry  *ModuleRegistry
+	logger    *log.Logger
+	lifecycle *LifecycleManager
 }
 
 func NewHotReloader(registry *ModuleRegistry, logger *log.Logger) (*HotReloader, error) {
@@ -21,15 +34,24 @@ func NewHotReloader(registry *ModuleRegistry, logger *log.Logger) (*HotReloader,
 	}
 
 	hr := &HotReloader{
-		watcher:  watcher,
-		registry: registry,
-		logger:   logger,
+		watcher:   watcher,
+		registry:  registry,
+		logger:    logger,
+		lifecycle: NewLifecycleManager(),
 	}
 
-	go hr.watchLoop()
+	hr.lifecycle.Go(hr.watchLoop)
 	return hr, nil
 }
 
+// Close stops watching, waiting for a reload in progress to finish
+func (h *HotReloader) Close() error {
+	if err := h.lifecycle.Stop(context.Background()); err != nil {
+		return err
+	}
+	return h.watcher.Close()
+}
+
 func (h *HotReloader) handleChange(event fsnotify.Event) error {
 	if event.Op != fsnotify.Write {
 		return nil
@@ -44,29 +66,65 @@ func (h *HotReloader) handleChange(event fsnotify.Event) error {
 		return fmt.Errorf("failed to load updated module: %w", err)
 	}
 
-	// Stop old module
-	if oldModule, exists := h.registry.Get(moduleName); exists {
-		if err := oldModule.Terminate(); err != nil {
+	// Capture state while the old module is still running
+	ctx := context.Background()
+	timeouts := h.registry.Timeouts(moduleName)
+	var state []byte
+	oldModule, exists := h.registry.Get(moduleName)
+	if exists {
+		err = h.registry.call(ctx, oldModule, "ExportState", timeouts.Terminate, func(context.Context) (err error) {
+			state, err = exportState(oldModule, newModule)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to export state of old module: %w", err)
+		}
+
+		// Stop old module
+		if _, err := h.registry.terminateModule(ctx, oldModule); err != nil {
 			return fmt.Errorf("failed to terminate old module: %w", err)
 		}
 	}
 
 	// Initialize and register new module
-	if err := newModule.Initialize(); err != nil {
+	if _, err := h.registry.initialize(ctx, newModule); err != nil {
 		return fmt.Errorf("failed to initialize new module: %w", err)
 	}
+	if state != nil {
+		if err := h.registry.call(ctx, newModule, "ImportState", timeouts.Initialize, func(context.Context) error {
+			return newModule.(StateTransfer).ImportState(state)
+		}); err != nil {
+			h.logger.Printf("Module %s reloaded without its state: %v", moduleName, err)
+		}
+	}
 
 	h.registry.mu.Lock()
 	h.registry.modules[moduleName] = newModule
 	h.registry.mu.Unlock()
+	h.registry.clearFailure(moduleName)
 
 	h.logger.Printf("Module %s reloaded successfully", moduleName)
 	return nil
 }
 
-func (h *HotReloader) watchLoop() {
+// exportState returns the old module's state when both versions support
+// state transfer, or nil when the new one starts empty
+func exportState(oldModule, newModule base.Identity) ([]byte, error) {
+	source, ok := oldModule.(StateTransfer)
+	if !ok {
+		return nil, nil
+	}
+	if _, ok := newModule.(StateTransfer); !ok {
+		return nil, nil
+	}
+	return source.ExportState()
+}
+
+func (h *HotReloader) watchLoop(ctx context.Context) {
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case event := <-h.watcher.Events:
 			if err := h.handleChange(event); err != nil {
 				h.logger.Printf("Hot reload error: %v", err)
@@ -0,0 +1,230 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// IdempotencyKeyHeader carries a client-chosen key that makes retries of
+	// a mutating request safe: requests repeating a key get the first
+	// request's response instead of running again
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed for a repeated
+	// key
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// IdempotencyCache remembers the responses of mutating requests sent with
+// an Idempotency-Key for a while. Keys are scoped to the request's method,
+// path and API token, and bound to the body of the request that first used
+// them. Server errors and 429s are not remembered, so a retry
+// after one runs the request again. Requests and responses with bodies over
+// the cache's size limit are not remembered either: large uploads are
+// streamed to their handler rather than held in memory.
+type IdempotencyCache struct {
+	mu       sync.Mutex
+	clock    Clock
+	ttl      time.Duration
+	capacity int
+	maxBody  int64
+	entries  map[string]*idempotentResponse
+	order    []*idempotentResponse // Oldest first
+}
+
+// idempotentResponse is a remembered response, or a pending one while done
+// is open
+type idempotentResponse struct {
+	key     string
+	request [sha256.Size]byte // Hash of the first request's body
+	done    chan struct{}
+	settled bool
+	at      time.Time
+	status  int
+	header  http.Header
+	body    []byte
+	evicted bool
+}
+
+// NewIdempotencyCache remembers up to capacity responses for ttl each, for
+// requests and responses whose bodies are at most maxBody bytes
+func NewIdempotencyCache(ttl time.Duration, capacity int, maxBody int64) *IdempotencyCache {
+	return &IdempotencyCache{
+		clock:    SystemClock,
+		ttl:      ttl,
+		capacity: capacity,
+		maxBody:  maxBody,
+		entries:  make(map[string]*idempotentResponse),
+	}
+}
+
+// SetClock replaces the clock responses age by
+func (c *IdempotencyCache) SetClock(clock Clock) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clock = clock
+}
+
+// Middleware replays the remembered response of a repeated key. A request
+// repeating a key with a different body gets 422, and one repeating a key
+// whose first request is still running gets 409.
+func (c *IdempotencyCache) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" || r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions ||
+			r.ContentLength > c.maxBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+		token, _ := TokenFromContext(r.Context())
+		key = token.ID + " " + r.Method + " " + r.URL.Path + " " + key
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, c.maxBody+1))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "failed to read request body: "+err.Error())
+			return
+		}
+		if int64(len(body)) > c.maxBody {
+			// A streamed body of unannounced length turned out too large
+			// to remember: the handler reads on from what was read
+			r.Body = prefixedBody{Reader: io.MultiReader(bytes.NewReader(body), r.Body), Closer: r.Body}
+			next.ServeHTTP(w, r)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		request := sha256.Sum256(body)
+
+		entry, first := c.claim(key, request)
+		if !first {
+			if entry.request != request {
+				respondError(w, http.StatusUnprocessableEntity, "the idempotency key was used with a different request body")
+				return
+			}
+			select {
+			case <-entry.done:
+			default:
+				respondError(w, http.StatusConflict, "a request with this idempotency key is in progress")
+				return
+			}
+			for name, values := range entry.header {
+				w.Header()[name] = values
+			}
+			w.Header().Set(IdempotentReplayedHeader, "true")
+			w.WriteHeader(entry.status)
+			w.Write(entry.body)
+			return
+		}
+
+		recorder := &recordingWriter{ResponseWriter: w, status: http.StatusOK, limit: c.maxBody}
+		defer func() {
+			c.finish(entry, recorder)
+		}()
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+// claim returns the entry of key, creating a pending one for request when
+// there is none and reporting whether it did
+func (c *IdempotencyCache) claim(key string, request [sha256.Size]byte) (*idempotentResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	c.pruneLocked(now)
+	if entry, ok := c.entries[key]; ok {
+		return entry, false
+	}
+	entry := &idempotentResponse{key: key, request: request, done: make(chan struct{}), at: now}
+	c.entries[key] = entry
+	c.order = append(c.order, entry)
+	return entry, true
+}
+
+// finish remembers the recorded response of key's first request, or forgets
+// the key when the response should not be replayed
+func (c *IdempotencyCache) finish(entry *idempotentResponse, recorder *recordingWriter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry.status = recorder.status
+	entry.header = recorder.Header().Clone()
+	// Replays are encoded afresh for the repeating client
+	entry.header.Del("Content-Encoding")
+	entry.header.Del("Content-Length")
+	entry.body = recorder.body.Bytes()
+	entry.settled = true
+	close(entry.done)
+	if !recorder.wroteHeader || recorder.truncated || recorder.status >= 500 || recorder.status == http.StatusTooManyRequests {
+		c.evictLocked(entry)
+	}
+}
+
+// pruneLocked drops expired entries and, past capacity, the oldest settled
+// ones
+func (c *IdempotencyCache) pruneLocked(now time.Time) {
+	kept := c.order[:0]
+	for i, entry := range c.order {
+		if entry.evicted {
+			continue
+		}
+		if entry.settled && (now.Sub(entry.at) > c.ttl || len(c.order)-i > c.capacity) {
+			c.evictLocked(entry)
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	c.order = kept
+}
+
+func (c *IdempotencyCache) evictLocked(entry *idempotentResponse) {
+	if !entry.evicted {
+		delete(c.entries, entry.key)
+		entry.evicted = true
+	}
+}
+
+// prefixedBody is a request body whose start has already been read
+type prefixedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// recordingWriter copies a response as it is written, giving up on the
+// copy once it exceeds limit bytes
+type recordingWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	limit       int64
+	truncated   bool
+	body        bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	if !w.truncated {
+		if int64(w.body.Len()+len(p)) > w.limit {
+			w.truncated = true
+			w.body = bytes.Buffer{}
+		} else {
+			w.body.Write(p)
+		}
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
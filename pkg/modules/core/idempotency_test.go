@@ -0,0 +1,96 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyCacheReplaysResponses(t *testing.T) {
+	var served []string
+	handler := NewIdempotencyCache(time.Hour, 10, 1<<10).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		served = append(served, string(body))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"` + string(body) + `"}`))
+	}))
+	send := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/chains", strings.NewReader(body))
+		req.Header.Set(IdempotencyKeyHeader, key)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	first := send("k1", "eth")
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(IdempotentReplayedHeader))
+
+	replay := send("k1", "eth")
+	assert.Equal(t, http.StatusCreated, replay.Code)
+	assert.Equal(t, "true", replay.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, `{"id":"eth"}`, replay.Body.String())
+
+	// Reusing a key for another request is refused rather than answered
+	// with the first request's response
+	mismatch := send("k1", "btc")
+	assert.Equal(t, http.StatusUnprocessableEntity, mismatch.Code)
+	assert.Contains(t, mismatch.Body.String(), "different request body")
+
+	assert.Equal(t, http.StatusCreated, send("k2", "btc").Code)
+	assert.Equal(t, []string{"eth", "btc"}, served, "the handler reads the body the middleware hashed")
+}
+
+func TestIdempotencyCacheSkipsLargeBodies(t *testing.T) {
+	var served int
+	handler := NewIdempotencyCache(time.Hour, 10, 8).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		served++
+		w.Write(body)
+	}))
+	send := func(key string, body io.Reader) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/blobs", body)
+		req.Header.Set(IdempotencyKeyHeader, key)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	// Announced, streamed and echoed bodies over the limit all run again
+	for i := 0; i < 2; i++ {
+		large := send("announced", strings.NewReader("0123456789"))
+		assert.Equal(t, "0123456789", large.Body.String())
+		assert.Empty(t, large.Header().Get(IdempotentReplayedHeader))
+
+		streamed := send("streamed", io.MultiReader(strings.NewReader("01234"), strings.NewReader("56789")))
+		assert.Equal(t, "0123456789", streamed.Body.String(), "the handler reads the whole streamed body")
+		assert.Empty(t, streamed.Header().Get(IdempotentReplayedHeader))
+	}
+	assert.Equal(t, 4, served)
+
+	send("small", strings.NewReader("0123"))
+	assert.Equal(t, "true", send("small", strings.NewReader("0123")).Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, 5, served)
+
+	// A small request with a response over the limit is not remembered
+	padded := NewIdempotencyCache(time.Hour, 10, 8).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+		w.Write([]byte("0123456789"))
+	}))
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/chains", strings.NewReader("eth"))
+		req.Header.Set(IdempotencyKeyHeader, "padded")
+		recorder := httptest.NewRecorder()
+		padded.ServeHTTP(recorder, req)
+		assert.Equal(t, "0123456789", recorder.Body.String())
+	}
+	assert.Equal(t, 7, served)
+}
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IDs are ULIDs: a 48-bit millisecond timestamp followed by 80 random bits,
+// written as 26 Crockford base32 characters. They sort lexically by time, and
+// those made by one generator sort in the order they were made.
+
+// crockford is the base32 alphabet of IDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// idLength is the length of an encoded ID
+const idLength = 26
+
+// IDGenerator makes IDs that increase even within a millisecond and when the
+// clock steps back
+type IDGenerator struct {
+	mu     sync.Mutex
+	now    func() time.Time
+	last   uint64   // Millisecond of the last ID
+	random [10]byte // Random part of the last ID
+}
+
+// NewIDGenerator creates a generator reading time from now, or the system
+// clock when now is nil
+func NewIDGenerator(now func() time.Time) *IDGenerator {
+	if now == nil {
+		now = time.Now
+	}
+	return &IDGenerator{now: now}
+}
+
+var defaultIDs = NewIDGenerator(nil)
+
+// NewID returns a time-ordered ID for transactions, tokens, messages and
+// log entries
+func NewID() string {
+	return defaultIDs.New()
+}
+
+// New returns an ID greater than any this generator returned before
+func (g *IDGenerator) New() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(g.now().UnixMilli())
+	if ms > g.last {
+		g.last = ms
+		g.fill()
+	} else if !g.increment() {
+		// The random part ran out within one millisecond; borrow the next
+		g.last++
+		g.fill()
+	}
+
+	var id [16]byte
+	for i := 0; i < 6; i++ {
+		id[i] = byte(g.last >> (40 - 8*i))
+	}
+	copy(id[6:], g.random[:])
+	return encodeID(id)
+}
+
+func (g *IDGenerator) fill() {
+	if _, err := rand.Read(g.random[:]); err != nil {
+		panic(fmt.Sprintf("failed to generate ID: %v", err))
+	}
+}
+
+// increment adds one to the random part, reporting false on overflow
+func (g *IDGenerator) increment() bool {
+	for i := len(g.random) - 1; i >= 0; i-- {
+		g.random[i]++
+		if g.random[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeID writes 128 bits as 26 characters of 5 bits, the first padded
+// with two leading zero bits
+func encodeID(id [16]byte) string {
+	var out [idLength]byte
+	for i := range out {
+		var value byte
+		for bit := i*5 - 2; bit < i*5+3; bit++ {
+			value <<= 1
+			if bit >= 0 && id[bit/8]&(0x80>>(bit%8)) != 0 {
+				value |= 1
+			}
+		}
+		out[i] = crockford[value]
+	}
+	return string(out[:])
+}
+
+// IDTime returns the time, to the millisecond, at which an ID was made
+func IDTime(id string) (time.Time, error) {
+	if len(id) != idLength {
+		return time.Time{}, fmt.Errorf("invalid ID %q: must be %d characters", id, idLength)
+	}
+	var ms uint64
+	for _, c := range strings.ToUpper(id[:10]) {
+		value := strings.IndexRune(crockford, c)
+		if value < 0 {
+			return time.Time{}, fmt.Errorf("invalid ID %q: bad character %q", id, c)
+		}
+		ms = ms<<5 | uint64(value)
+	}
+	if ms >= 1<<48 {
+		return time.Time{}, fmt.Errorf("invalid ID %q: timestamp out of range", id)
+	}
+	return time.UnixMilli(int64(ms)).UTC(), nil
+}
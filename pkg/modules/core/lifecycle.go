@@ -0,0 +1,64 @@
+package core
+
+import (
+	"context"
+	"sync"
+)
+
+// LifecycleManager runs a component's background goroutines. Each is given
+// a context that Stop cancels, and Stop waits for all of them to return, so
+// a stopped component leaves nothing running.
+type LifecycleManager struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	stopped bool
+}
+
+func NewLifecycleManager() *LifecycleManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &LifecycleManager{ctx: ctx, cancel: cancel}
+}
+
+// Go runs fn in a goroutine, passing it the context Stop cancels. Once Stop
+// has been called it does nothing.
+func (l *LifecycleManager) Go(fn func(ctx context.Context)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stopped {
+		return
+	}
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		fn(l.ctx)
+	}()
+}
+
+// Done is closed when Stop is called
+func (l *LifecycleManager) Done() <-chan struct{} {
+	return l.ctx.Done()
+}
+
+// Stop cancels the goroutines' context and waits until they have returned
+// or ctx is done, returning ctx's error in the latter case. It may be called
+// more than once.
+func (l *LifecycleManager) Stop(ctx context.Context) error {
+	l.mu.Lock()
+	l.stopped = true
+	l.mu.Unlock()
+	l.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
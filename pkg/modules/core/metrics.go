@@ -29,6 +29,10 @@ func NewMetricsExporter() *MetricsExporter {
 func (me *MetricsExporter) RegisterModule(name string) {
 	me.mu.Lock()
 	defer me.mu.Unlock()
+	if _, exists := me.modules[name]; exists {
+		// A restarted module keeps counting where it left off
+		return
+	}
 
 	mm := &moduleMetrics{
 		health: prometheus.NewGauge(prometheus.GaugeOpts{
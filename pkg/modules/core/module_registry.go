@@ -1,24 +1,43 @@
 package core
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/theaxiomverse/hydap-api/pkg/modules/base"
+	"sort"
+	"strings"
 	"sync"
 )
 
 type ModuleRegistry struct {
-	modules map[string]base.Module
+	modules map[string]base.Identity // Each a base.Module or base.ModuleV2
 	deps    map[string][]string
 	mu      sync.RWMutex
 	Loader  base.ModuleLoader
+
+	sandboxLoader LoaderFactory // Builds isolated loaders for DryRun
+
+	// Panics and timeouts recovered from modules; panics and timedOut hold
+	// the one that put each module in StateError until it is started again
+	statusMu      sync.Mutex
+	panics        map[string]PanicRecord
+	timedOut      map[string]TimeoutRecord
+	panicLog      []PanicRecord
+	panicHandlers []func(PanicRecord)
+
+	timeoutMu sync.Mutex
+	timeouts  map[string]OperationTimeouts // Defaults for modules not listed
 }
 
 func NewModuleRegistry(loader base.ModuleLoader) *ModuleRegistry {
 	return &ModuleRegistry{
-		modules: make(map[string]base.Module),
-		deps:    make(map[string][]string),
-		Loader:  loader,
+		modules:  make(map[string]base.Identity),
+		deps:     make(map[string][]string),
+		Loader:   loader,
+		panics:   make(map[string]PanicRecord),
+		timedOut: make(map[string]TimeoutRecord),
+		timeouts: make(map[string]OperationTimeouts),
 	}
 }
 
@@ -34,7 +53,24 @@ func (l *defaultLoader) LoadFromConfig(config base.ModuleConfig) (base.Module, e
 	return nil, fmt.Errorf("not implemented")
 }
 
-func (r *ModuleRegistry) Register(module base.Module) error {
+// LoaderSet dispatches to the loader registered for each module name
+type LoaderSet map[string]base.ModuleLoader
+
+func (s LoaderSet) Load(path string) (base.Module, error) {
+	return nil, fmt.Errorf("file-based loading not implemented")
+}
+
+func (s LoaderSet) LoadFromConfig(config base.ModuleConfig) (base.Module, error) {
+	loader, exists := s[config.Name]
+	if !exists {
+		return nil, fmt.Errorf("no loader for module: %s", config.Name)
+	}
+	return loader.LoadFromConfig(config)
+}
+
+// Register initializes a module, within its Initialize deadline, and adds it
+// to the registry. The module must be a base.Module or base.ModuleV2.
+func (r *ModuleRegistry) Register(ctx context.Context, module base.Identity) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -43,16 +79,41 @@ func (r *ModuleRegistry) Register(module base.Module) error {
 		return fmt.Errorf("module %s already registered", name)
 	}
 
-	if err := module.Initialize(); err != nil {
+	if _, err := r.initialize(ctx, module); err != nil {
 		return fmt.Errorf("failed to initialize %s: %w", name, err)
 	}
 
 	r.modules[name] = module
+	r.clearFailure(name)
 	return nil
 }
 
-func (r *ModuleRegistry) RegisterWithDeps(module base.Module, deps []string) error {
-	if err := r.Register(module); err != nil {
+// Start initializes a registered module again, clearing a panic or timeout
+// that put it in StateError once it succeeds
+func (r *ModuleRegistry) Start(ctx context.Context, name string) (base.LifecycleResult, error) {
+	mod, exists := r.Get(name)
+	if !exists {
+		return base.LifecycleResult{}, fmt.Errorf("module %s not found", name)
+	}
+	result, err := r.initialize(ctx, mod)
+	if err != nil {
+		return result, err
+	}
+	r.clearFailure(name)
+	return result, nil
+}
+
+// Stop terminates a registered module, leaving it registered
+func (r *ModuleRegistry) Stop(ctx context.Context, name string) (base.LifecycleResult, error) {
+	mod, exists := r.Get(name)
+	if !exists {
+		return base.LifecycleResult{}, fmt.Errorf("module %s not found", name)
+	}
+	return r.terminateModule(ctx, mod)
+}
+
+func (r *ModuleRegistry) RegisterWithDeps(ctx context.Context, module base.Identity, deps []string) error {
+	if err := r.Register(ctx, module); err != nil {
 		return err
 	}
 	r.deps[module.Name()] = deps
@@ -69,7 +130,7 @@ func (r *ModuleRegistry) resolveDeps(name string) error {
 	return nil
 }
 
-func (r *ModuleRegistry) LoadFromConfig(config []byte) error {
+func (r *ModuleRegistry) LoadFromConfig(ctx context.Context, config []byte) error {
 	var configs []base.ModuleConfig
 	if err := json.Unmarshal(config, &configs); err != nil {
 		return err
@@ -80,37 +141,124 @@ func (r *ModuleRegistry) LoadFromConfig(config []byte) error {
 		if err != nil {
 			return err
 		}
-		if err := r.RegisterWithDeps(mod, cfg.DependsOn); err != nil {
+		timeouts, err := TimeoutsFromConfig(cfg.Config)
+		if err != nil {
+			return fmt.Errorf("module %s: %w", cfg.Name, err)
+		}
+		r.SetTimeouts(mod.Name(), timeouts)
+		if err := r.RegisterWithDeps(ctx, mod, cfg.DependsOn); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (r *ModuleRegistry) Get(name string) (base.Module, bool) {
+func (r *ModuleRegistry) Get(name string) (base.Identity, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	mod, exists := r.modules[name]
 	return mod, exists
 }
 
-func (r *ModuleRegistry) Terminate(name string) error {
+// DependentsError is returned when terminating a module that other
+// registered modules still depend on
+type DependentsError struct {
+	Module     string
+	Dependents []string
+}
+
+func (e *DependentsError) Error() string {
+	return fmt.Sprintf("module %s is required by %s", e.Module, strings.Join(e.Dependents, ", "))
+}
+
+// Terminate stops and removes a module, refusing with a *DependentsError
+// while other modules depend on it
+func (r *ModuleRegistry) Terminate(ctx context.Context, name string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	mod, exists := r.modules[name]
-	if !exists {
+	if _, exists := r.modules[name]; !exists {
 		return fmt.Errorf("module %s not found", name)
 	}
+	if dependents := r.dependents(name); len(dependents) > 0 {
+		return &DependentsError{Module: name, Dependents: dependents}
+	}
+	return r.terminate(ctx, name)
+}
+
+// TerminateCascade stops a module and everything that depends on it,
+// dependents first. It returns the modules terminated, in order, and stops
+// at the first failure.
+func (r *ModuleRegistry) TerminateCascade(ctx context.Context, name string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.modules[name]; !exists {
+		return nil, fmt.Errorf("module %s not found", name)
+	}
 
-	if err := mod.Terminate(); err != nil {
+	var terminated []string
+	for _, module := range r.teardownOrder(name) {
+		if err := r.terminate(ctx, module); err != nil {
+			return terminated, err
+		}
+		terminated = append(terminated, module)
+	}
+	return terminated, nil
+}
+
+// terminate stops and removes a single module; callers hold r.mu
+func (r *ModuleRegistry) terminate(ctx context.Context, name string) error {
+	if _, err := r.terminateModule(ctx, r.modules[name]); err != nil {
 		return fmt.Errorf("failed to terminate %s: %w", name, err)
 	}
 
 	delete(r.modules, name)
+	delete(r.deps, name)
+	r.clearFailure(name)
 	return nil
 }
 
+// dependents lists the registered modules that directly depend on name
+func (r *ModuleRegistry) dependents(name string) []string {
+	var dependents []string
+	for module, deps := range r.deps {
+		if _, exists := r.modules[module]; !exists {
+			continue
+		}
+		for _, dep := range deps {
+			if dep == name {
+				dependents = append(dependents, module)
+				break
+			}
+		}
+	}
+	sort.Strings(dependents)
+	return dependents
+}
+
+// teardownOrder lists name and its transitive dependents so that every
+// module comes before the modules it depends on
+func (r *ModuleRegistry) teardownOrder(name string) []string {
+	var order []string
+	visited := make(map[string]bool)
+
+	var visit func(string)
+	visit = func(module string) {
+		if visited[module] {
+			return
+		}
+		visited[module] = true
+		for _, dependent := range r.dependents(module) {
+			visit(dependent)
+		}
+		order = append(order, module)
+	}
+	visit(name)
+
+	return order
+}
+
 // pkg/modules/core/registry.go
 
 func (r *ModuleRegistry) List() []ModuleInfo {
@@ -119,13 +267,28 @@ func (r *ModuleRegistry) List() []ModuleInfo {
 
 	modules := make([]ModuleInfo, 0, len(r.modules))
 	for name, mod := range r.modules {
-		modules = append(modules, ModuleInfo{
-			Name:    name,
-			Status:  mod.GetState(),
-			Deps:    r.deps[name],
-			Version: mod.Version(),
-		})
+		info := ModuleInfo{
+			Name: name,
+			Deps: r.deps[name],
+		}
+		if err := r.invoke(mod, "GetState", func() error {
+			info.Status = mod.GetState()
+			info.Version = mod.Version()
+			return nil
+		}); err != nil {
+			info.Status = base.StateError
+		}
+		if record, exists := r.lastPanic(name); exists {
+			info.Status = base.StateError
+			info.Panic = &record
+		}
+		if record, exists := r.lastTimeout(name); exists {
+			info.Status = base.StateError
+			info.Timeout = &record
+		}
+		modules = append(modules, info)
 	}
+	sort.Slice(modules, func(i, j int) bool { return modules[i].Name < modules[j].Name })
 	return modules
 }
 
@@ -134,4 +297,6 @@ type ModuleInfo struct {
 	Status  base.ModuleState `json:"status"`
 	Deps    []string         `json:"dependencies,omitempty"`
 	Version string           `json:"version"`
+	Panic   *PanicRecord     `json:"panic,omitempty"` // Why the module is in StateError
+	Timeout *TimeoutRecord   `json:"timeout,omitempty"`
 }
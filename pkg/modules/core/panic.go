@@ -0,0 +1,162 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/theaxiomverse/hydap-api/pkg/modules/base"
+)
+
+// maxPanicRecords bounds the recovered panics a registry keeps
+const maxPanicRecords = 100
+
+// PanicRecord describes a panic recovered at a module boundary
+type PanicRecord struct {
+	Module    string    `json:"module"`
+	Operation string    `json:"operation"` // Module method or API request that panicked
+	Value     string    `json:"value"`
+	Stack     string    `json:"stack"`
+	Time      time.Time `json:"time"`
+}
+
+// PanicError is returned in place of a panic raised by a module
+type PanicError struct {
+	Record PanicRecord
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("module %s panicked in %s: %s", e.Record.Module, e.Record.Operation, e.Record.Value)
+}
+
+// protect calls fn, turning a panic it raises into a *PanicError
+func protect(module, operation string, fn func() error) (err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = &PanicError{Record: PanicRecord{
+				Module:    module,
+				Operation: operation,
+				Value:     fmt.Sprint(value),
+				Stack:     string(debug.Stack()),
+				Time:      time.Now(),
+			}}
+		}
+	}()
+	return fn()
+}
+
+// invoke calls one of a module's methods, recording a panic it raises
+// instead of letting it take down the process
+func (r *ModuleRegistry) invoke(module base.Identity, operation string, fn func() error) error {
+	err := protect(module.Name(), operation, fn)
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		r.recordPanic(module, panicErr.Record)
+	}
+	return err
+}
+
+// recordPanic puts a module, if registered, in StateError, keeps the panic for its status
+// and notifies the OnPanic handlers. It does not take r.mu, as panics are
+// recovered while it is held.
+func (r *ModuleRegistry) recordPanic(module base.Identity, record PanicRecord) {
+	setErrorState(module)
+
+	r.statusMu.Lock()
+	r.panics[record.Module] = record
+	r.panicLog = append(r.panicLog, record)
+	if len(r.panicLog) > maxPanicRecords {
+		r.panicLog = r.panicLog[len(r.panicLog)-maxPanicRecords:]
+	}
+	handlers := r.panicHandlers // Only ever appended to
+	r.statusMu.Unlock()
+
+	for _, handler := range handlers {
+		handler(record)
+	}
+}
+
+// setErrorState puts a module that can change state in StateError
+func setErrorState(module base.Identity) {
+	if setter, ok := module.(interface{ SetState(base.ModuleState) }); ok {
+		protect(module.Name(), "SetState", func() error {
+			setter.SetState(base.StateError)
+			return nil
+		})
+	}
+}
+
+// OnPanic calls handler with every panic recovered from a module
+func (r *ModuleRegistry) OnPanic(handler func(PanicRecord)) {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+	r.panicHandlers = append(r.panicHandlers, handler)
+}
+
+// Panics returns the most recently recovered panics, oldest first
+func (r *ModuleRegistry) Panics() []PanicRecord {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+	return append([]PanicRecord(nil), r.panicLog...)
+}
+
+// lastPanic returns the panic that put a module in StateError, if any
+func (r *ModuleRegistry) lastPanic(name string) (PanicRecord, bool) {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+	record, exists := r.panics[name]
+	return record, exists
+}
+
+// clearFailure forgets the panic or timeout that put a module in StateError
+func (r *ModuleRegistry) clearFailure(name string) {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+	delete(r.panics, name)
+	delete(r.timedOut, name)
+}
+
+// Recover wraps a module's API routes so a handler panic answers 500 and
+// puts the module in StateError instead of crashing the server
+func (r *ModuleRegistry) Recover(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			defer func() {
+				value := recover()
+				if value == nil {
+					return
+				}
+				if value == http.ErrAbortHandler {
+					// Deliberate aborts are left to net/http
+					panic(value)
+				}
+
+				r.RecordPanic(name, req.Method+" "+req.URL.Path, value)
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": fmt.Sprintf("module %s panicked", name),
+				})
+			}()
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// RecordPanic records a panic recovered from an operation of a module's API
+// served outside Recover, such as a gRPC call, and puts the module in
+// StateError. Call it from the deferred function that recovered value.
+func (r *ModuleRegistry) RecordPanic(name, operation string, value interface{}) {
+	module, _ := r.Get(name)
+	r.recordPanic(module, PanicRecord{
+		Module:    name,
+		Operation: operation,
+		Value:     fmt.Sprint(value),
+		Stack:     string(debug.Stack()),
+		Time:      time.Now(),
+	})
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/base"
+)
+
+func TestRegistryRecoversModulePanics(t *testing.T) {
+	registry := NewModuleRegistry(nil)
+	var handled []PanicRecord
+	registry.OnPanic(func(record PanicRecord) { handled = append(handled, record) })
+
+	broken := newTestModule("broken")
+	broken.initialize = func() error { panic("nil map") }
+	err := registry.Register(context.Background(), broken)
+	var panicErr *PanicError
+	require.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, "broken", panicErr.Record.Module)
+	assert.Equal(t, "Initialize", panicErr.Record.Operation)
+	assert.Equal(t, "nil map", panicErr.Record.Value)
+	assert.NotEmpty(t, panicErr.Record.Stack)
+	_, exists := registry.Get("broken")
+	assert.False(t, exists, "a module that panics while registering is not added")
+
+	// A registered module that panics is put in StateError until it starts
+	mod := newTestModule("flaky")
+	require.NoError(t, registry.Register(context.Background(), mod))
+	mod.initialize = func() error { panic(errors.New("flaky")) }
+	_, err = registry.Start(context.Background(), "flaky")
+	require.ErrorAs(t, err, &panicErr)
+
+	info := registry.List()
+	require.Len(t, info, 1)
+	assert.Equal(t, base.StateError, info[0].Status)
+	require.NotNil(t, info[0].Panic)
+	assert.Equal(t, "flaky", info[0].Panic.Value)
+	assert.Equal(t, "unhealthy", registry.GetAllHealth(context.Background())["flaky"].Status)
+
+	panics := registry.Panics()
+	require.Len(t, panics, 2)
+	assert.Equal(t, "broken", panics[0].Module)
+	assert.Equal(t, "flaky", panics[1].Module)
+	assert.Equal(t, panics, handled)
+
+	mod.initialize = nil
+	_, err = registry.Start(context.Background(), "flaky")
+	require.NoError(t, err)
+	info = registry.List()
+	assert.Equal(t, base.StateRunning, info[0].Status)
+	assert.Nil(t, info[0].Panic)
+	assert.Len(t, registry.Panics(), 2, "the log keeps panics that were cleared")
+}
+
+func TestRecoverAnswersHandlerPanics(t *testing.T) {
+	registry := NewModuleRegistry(nil)
+	require.NoError(t, registry.Register(context.Background(), newTestModule("api")))
+
+	handler := registry.Recover("api")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/abort" {
+			panic(http.ErrAbortHandler)
+		}
+		var routes map[string]string
+		routes["missing"] = r.URL.Path
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/boom", nil))
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	var body map[string]string
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&body))
+	assert.Equal(t, "module api panicked", body["error"])
+
+	panics := registry.Panics()
+	require.Len(t, panics, 1)
+	assert.Equal(t, "api", panics[0].Module)
+	assert.Equal(t, "POST /boom", panics[0].Operation)
+	assert.Contains(t, panics[0].Value, "nil map")
+	assert.Equal(t, base.StateError, registry.List()[0].Status)
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abort", nil))
+	}, "deliberate aborts are left to net/http")
+	assert.Len(t, registry.Panics(), 1)
+}
@@ -0,0 +1,44 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretProvider resolves the ${secret:path} references in config templates
+type SecretProvider interface {
+	Secret(path string) (string, error)
+}
+
+// FileSecretProvider reads each secret from a file under Dir, the layout
+// used by Docker and Kubernetes secret mounts
+type FileSecretProvider struct {
+	Dir string
+}
+
+func NewFileSecretProvider(dir string) *FileSecretProvider {
+	return &FileSecretProvider{Dir: dir}
+}
+
+// Secret returns the file's contents without the trailing newline. Paths
+// are relative to Dir and may not leave it.
+func (p *FileSecretProvider) Secret(path string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(path))
+	if path == "" || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid secret path %q", path)
+	}
+
+	data, err := os.ReadFile(filepath.Join(p.Dir, clean))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret %s: %w", path, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
@@ -0,0 +1,30 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSecretProvider(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "db"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "db", "password"), []byte("hunter2\n"), 0600))
+	provider := NewFileSecretProvider(dir)
+
+	secret, err := provider.Secret("db/password")
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", secret)
+
+	_, err = provider.Secret("db/missing")
+	assert.ErrorIs(t, err, ErrSecretNotFound)
+
+	// Secrets may not be read from outside the directory
+	for _, path := range []string{"", "../password", "db/../../password", "/etc/passwd"} {
+		_, err = provider.Secret(path)
+		assert.ErrorContains(t, err, "invalid secret path", path)
+	}
+}
@@ -0,0 +1,194 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/theaxiomverse/hydap-api/pkg/modules/base"
+)
+
+// ErrOperationTimeout is returned when a module method outlives its deadline
+var ErrOperationTimeout = errors.New("module operation timed out")
+
+// OperationTimeouts bounds how long a module's lifecycle methods may run
+type OperationTimeouts struct {
+	Initialize  time.Duration
+	Terminate   time.Duration
+	HealthCheck time.Duration
+}
+
+// DefaultOperationTimeouts returns the deadlines of modules configured
+// without any
+func DefaultOperationTimeouts() OperationTimeouts {
+	return OperationTimeouts{
+		Initialize:  30 * time.Second,
+		Terminate:   30 * time.Second,
+		HealthCheck: 5 * time.Second,
+	}
+}
+
+// TimeoutsFromConfig reads the "timeouts" section of a module's config, as
+// durations under "initialize", "terminate" and "healthCheck". Missing
+// entries keep their defaults.
+func TimeoutsFromConfig(config map[string]interface{}) (OperationTimeouts, error) {
+	timeouts := DefaultOperationTimeouts()
+	raw, exists := config["timeouts"]
+	if !exists {
+		return timeouts, nil
+	}
+	section, ok := raw.(map[string]interface{})
+	if !ok {
+		return timeouts, fmt.Errorf("timeouts: must be a map of durations")
+	}
+
+	fields := map[string]*time.Duration{
+		"initialize":  &timeouts.Initialize,
+		"terminate":   &timeouts.Terminate,
+		"healthCheck": &timeouts.HealthCheck,
+	}
+	for key, value := range section {
+		field, known := fields[key]
+		if !known {
+			return timeouts, fmt.Errorf("timeouts.%s: unknown operation", key)
+		}
+		text, ok := value.(string)
+		if !ok {
+			return timeouts, fmt.Errorf("timeouts.%s: must be a duration string", key)
+		}
+		duration, err := time.ParseDuration(text)
+		if err != nil || duration <= 0 {
+			return timeouts, fmt.Errorf("timeouts.%s: invalid duration %q", key, text)
+		}
+		*field = duration
+	}
+	return timeouts, nil
+}
+
+// TimeoutRecord describes a module method abandoned at its deadline
+type TimeoutRecord struct {
+	Module    string    `json:"module"`
+	Operation string    `json:"operation"`
+	Timeout   string    `json:"timeout"`
+	Time      time.Time `json:"time"`
+}
+
+// SetTimeouts sets the deadlines of a module's lifecycle methods
+func (r *ModuleRegistry) SetTimeouts(name string, timeouts OperationTimeouts) {
+	r.timeoutMu.Lock()
+	defer r.timeoutMu.Unlock()
+	r.timeouts[name] = timeouts
+}
+
+// Timeouts returns the deadlines of a module's lifecycle methods
+func (r *ModuleRegistry) Timeouts(name string) OperationTimeouts {
+	r.timeoutMu.Lock()
+	defer r.timeoutMu.Unlock()
+	if timeouts, exists := r.timeouts[name]; exists {
+		return timeouts
+	}
+	return DefaultOperationTimeouts()
+}
+
+// withDeadline runs fn, giving up when ctx is done or timeout passes. fn
+// keeps running after either, as module methods cannot be interrupted.
+func withDeadline(ctx context.Context, timeout time.Duration, fn func() error) error {
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-expired:
+		return ErrOperationTimeout
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// call runs one of a module's methods under ctx and the module's deadline
+// for it, passing fn a context that expires at the deadline. A method that
+// outlives its deadline puts the module in StateError; one abandoned because
+// ctx is done does not.
+func (r *ModuleRegistry) call(ctx context.Context, module base.Identity, operation string, timeout time.Duration, fn func(context.Context) error) error {
+	opCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		opCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	err := withDeadline(ctx, timeout, func() error {
+		return r.invoke(module, operation, func() error { return fn(opCtx) })
+	})
+	if errors.Is(err, ErrOperationTimeout) || (err != nil && opCtx.Err() != nil && ctx.Err() == nil) {
+		// The deadline passed, whether or not the module noticed first
+		err = ErrOperationTimeout
+		r.recordTimeout(module, TimeoutRecord{
+			Module:    module.Name(),
+			Operation: operation,
+			Timeout:   timeout.String(),
+			Time:      time.Now(),
+		})
+		return fmt.Errorf("%s after %s: %w", operation, timeout, err)
+	}
+	return err
+}
+
+// initialize and terminateModule drive a module's lifecycle through
+// base.ModuleV2, within its deadlines
+func (r *ModuleRegistry) initialize(ctx context.Context, module base.Identity) (base.LifecycleResult, error) {
+	return r.lifecycle(ctx, module, "Initialize", r.Timeouts(module.Name()).Initialize, base.ModuleV2.Initialize)
+}
+
+func (r *ModuleRegistry) terminateModule(ctx context.Context, module base.Identity) (base.LifecycleResult, error) {
+	return r.lifecycle(ctx, module, "Terminate", r.Timeouts(module.Name()).Terminate, base.ModuleV2.Terminate)
+}
+
+func (r *ModuleRegistry) lifecycle(ctx context.Context, module base.Identity, operation string, timeout time.Duration, method func(base.ModuleV2, context.Context) (base.LifecycleResult, error)) (base.LifecycleResult, error) {
+	v2 := base.Adapt(module)
+	if v2 == nil {
+		return base.LifecycleResult{}, fmt.Errorf("module %s implements neither Module nor ModuleV2", module.Name())
+	}
+
+	// Sent rather than assigned, as a method abandoned at its deadline
+	// may still return
+	results := make(chan base.LifecycleResult, 1)
+	err := r.call(ctx, module, operation, timeout, func(ctx context.Context) error {
+		result, err := method(v2, ctx)
+		results <- result
+		return err
+	})
+	select {
+	case result := <-results:
+		return result, err
+	default:
+		return base.LifecycleResult{}, err
+	}
+}
+
+// recordTimeout puts a module in StateError and keeps the timeout for its
+// status until the module is started again
+func (r *ModuleRegistry) recordTimeout(module base.Identity, record TimeoutRecord) {
+	setErrorState(module)
+
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+	r.timedOut[record.Module] = record
+}
+
+// lastTimeout returns the timeout that put a module in StateError, if any
+func (r *ModuleRegistry) lastTimeout(name string) (TimeoutRecord, bool) {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+	record, exists := r.timedOut[name]
+	return record, exists
+}
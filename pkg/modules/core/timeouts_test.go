@@ -0,0 +1,213 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theaxiomverse/hydap-api/pkg/modules/base"
+)
+
+func TestTimeoutsFromConfig(t *testing.T) {
+	timeouts, err := TimeoutsFromConfig(map[string]interface{}{"port": 8080})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultOperationTimeouts(), timeouts)
+
+	timeouts, err = TimeoutsFromConfig(map[string]interface{}{
+		"timeouts": map[string]interface{}{"initialize": "2m", "healthCheck": "500ms"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, OperationTimeouts{Initialize: 2 * time.Minute, Terminate: 30 * time.Second, HealthCheck: 500 * time.Millisecond}, timeouts)
+
+	for message, section := range map[string]interface{}{
+		"timeouts: must be a map of durations":      "1s",
+		"timeouts.restart: unknown operation":       map[string]interface{}{"restart": "1s"},
+		"timeouts.terminate: must be a duration":    map[string]interface{}{"terminate": 5},
+		`timeouts.initialize: invalid duration "0"`: map[string]interface{}{"initialize": "0"},
+	} {
+		_, err := TimeoutsFromConfig(map[string]interface{}{"timeouts": section})
+		assert.ErrorContains(t, err, message)
+	}
+}
+
+// slowModule is a module whose Initialize blocks, while it is set to, until
+// the test ends. Its state is guarded, as the registry changes it while an
+// abandoned Initialize is still running.
+type slowModule struct {
+	name     string
+	release  chan struct{}
+	mu       sync.Mutex
+	state    base.ModuleState
+	blocking bool
+}
+
+func newSlowModule(t *testing.T, name string, blocking bool) *slowModule {
+	mod := &slowModule{name: name, release: make(chan struct{}), blocking: blocking}
+	t.Cleanup(func() { close(mod.release) })
+	return mod
+}
+
+func (m *slowModule) Name() string      { return m.name }
+func (m *slowModule) Signature() string { return "" }
+func (m *slowModule) Version() string   { return "1.0.0" }
+
+func (m *slowModule) GetState() base.ModuleState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state
+}
+
+func (m *slowModule) SetState(state base.ModuleState) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state = state
+}
+
+func (m *slowModule) setBlocking(blocking bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blocking = blocking
+}
+
+func (m *slowModule) Initialize() error {
+	m.mu.Lock()
+	blocking := m.blocking
+	m.mu.Unlock()
+	if blocking {
+		<-m.release
+		return nil
+	}
+	m.SetState(base.StateRunning)
+	return nil
+}
+
+func (m *slowModule) Terminate() error {
+	m.SetState(base.StateUninitialized)
+	return nil
+}
+
+func (m *slowModule) HealthCheck() error { return nil }
+
+func TestRegistryEnforcesDeadlines(t *testing.T) {
+	registry := NewModuleRegistry(nil)
+	deadlines := OperationTimeouts{Initialize: 20 * time.Millisecond, Terminate: time.Second, HealthCheck: time.Second}
+	registry.SetTimeouts("slow", deadlines)
+	assert.Equal(t, deadlines, registry.Timeouts("slow"))
+	assert.Equal(t, DefaultOperationTimeouts(), registry.Timeouts("other"))
+
+	err := registry.Register(context.Background(), newSlowModule(t, "slow", true))
+	assert.ErrorIs(t, err, ErrOperationTimeout)
+	assert.ErrorContains(t, err, "Initialize after 20ms")
+	_, exists := registry.Get("slow")
+	assert.False(t, exists)
+
+	// A registered module that outlives its deadline is put in StateError
+	// until it starts in time
+	mod := newSlowModule(t, "flaky", false)
+	require.NoError(t, registry.Register(context.Background(), mod))
+	registry.SetTimeouts("flaky", deadlines)
+	mod.setBlocking(true)
+	_, err = registry.Start(context.Background(), "flaky")
+	assert.ErrorIs(t, err, ErrOperationTimeout)
+
+	info := registry.List()
+	require.Len(t, info, 1)
+	assert.Equal(t, base.StateError, info[0].Status)
+	require.NotNil(t, info[0].Timeout)
+	assert.Equal(t, "Initialize", info[0].Timeout.Operation)
+	assert.Equal(t, "20ms", info[0].Timeout.Timeout)
+	assert.Equal(t, "unhealthy", registry.GetAllHealth(context.Background())["flaky"].Status)
+
+	mod.setBlocking(false)
+	_, err = registry.Start(context.Background(), "flaky")
+	require.NoError(t, err)
+	assert.Nil(t, registry.List()[0].Timeout)
+}
+
+func TestRegistryCancelledCallIsNotATimeout(t *testing.T) {
+	registry := NewModuleRegistry(nil)
+	mod := newSlowModule(t, "slow", false)
+	require.NoError(t, registry.Register(context.Background(), mod))
+	mod.setBlocking(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := registry.Start(ctx, "slow")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NotErrorIs(t, err, ErrOperationTimeout)
+
+	info := registry.List()
+	require.Len(t, info, 1)
+	assert.Nil(t, info[0].Timeout, "the caller gave up, not the module")
+}
+
+// contextModule is a base.ModuleV2 whose Initialize, while it is set to,
+// waits for its context to expire
+type contextModule struct {
+	slowModule
+}
+
+func (m *contextModule) Initialize(ctx context.Context) (base.LifecycleResult, error) {
+	m.mu.Lock()
+	blocking := m.blocking
+	m.mu.Unlock()
+	if blocking {
+		<-ctx.Done()
+		return base.LifecycleResult{}, ctx.Err()
+	}
+	m.SetState(base.StateRunning)
+	return base.LifecycleResult{State: base.StateRunning, Warnings: []string{"cache is cold"}}, nil
+}
+
+func (m *contextModule) Terminate(ctx context.Context) (base.LifecycleResult, error) {
+	m.SetState(base.StateUninitialized)
+	return base.LifecycleResult{State: base.StateUninitialized, Message: "flushed"}, nil
+}
+
+func (m *contextModule) HealthCheck(ctx context.Context) base.HealthResult {
+	return base.HealthResult{Message: "queue is backlogged", Checks: map[string]string{"db": "ok", "queue": "backlogged"}}
+}
+
+func TestRegistryDrivesModuleV2(t *testing.T) {
+	registry := NewModuleRegistry(nil)
+	mod := &contextModule{slowModule: slowModule{name: "v2"}}
+	require.NoError(t, registry.Register(context.Background(), mod))
+
+	result, err := registry.Start(context.Background(), "v2")
+	require.NoError(t, err)
+	assert.Equal(t, base.LifecycleResult{State: base.StateRunning, Warnings: []string{"cache is cold"}}, result)
+
+	health := registry.GetAllHealth(context.Background())["v2"]
+	assert.Equal(t, "unhealthy", health.Status)
+	assert.Equal(t, "queue is backlogged", health.Error)
+	assert.Equal(t, map[string]string{"db": "ok", "queue": "backlogged"}, health.Checks)
+
+	// A module that notices its deadline first still timed out
+	registry.SetTimeouts("v2", OperationTimeouts{Initialize: 20 * time.Millisecond, Terminate: time.Second, HealthCheck: time.Second})
+	mod.setBlocking(true)
+	_, err = registry.Start(context.Background(), "v2")
+	assert.ErrorIs(t, err, ErrOperationTimeout)
+	require.NotNil(t, registry.List()[0].Timeout)
+
+	result, err = registry.Stop(context.Background(), "v2")
+	require.NoError(t, err)
+	assert.Equal(t, "flushed", result.Message)
+}
+
+func TestRegistryAdaptsModules(t *testing.T) {
+	registry := NewModuleRegistry(nil)
+
+	// A Module is driven through ModuleV2, reporting its state afterwards
+	_, err := registry.Start(context.Background(), "missing")
+	assert.ErrorContains(t, err, "module missing not found")
+	require.NoError(t, registry.Register(context.Background(), newTestModule("v1")))
+	result, err := registry.Start(context.Background(), "v1")
+	require.NoError(t, err)
+	assert.Equal(t, base.StateRunning, result.State)
+
+	err = registry.Register(context.Background(), struct{ base.Identity }{newTestModule("identity")})
+	assert.ErrorContains(t, err, "module identity implements neither Module nor ModuleV2")
+}
@@ -1,8 +1,8 @@
 package core
 
 import (
-	"github.com/google/uuid"
 	"sync"
+	"time"
 )
 
 type Transaction struct {
@@ -11,6 +11,8 @@ type Transaction struct {
 	Operation string
 	Data      []byte
 	Status    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
 }
 
 type TransactionManager struct {
@@ -25,11 +27,14 @@ func NewTransactionManager() *TransactionManager {
 }
 
 func (tm *TransactionManager) Begin(module string, op string) *Transaction {
+	now := time.Now()
 	tx := &Transaction{
-		ID:        uuid.NewString(),
+		ID:        NewID(),
 		Module:    module,
 		Operation: op,
 		Status:    "pending",
+		CreatedAt: now,
+		UpdatedAt: now,
 	}
 	tm.mu.Lock()
 	tm.Txns[tx.ID] = tx
@@ -51,7 +56,24 @@ func (tm *TransactionManager) UpdateStatus(id string, status string) bool {
 	defer tm.mu.Unlock()
 	if tx, exists := tm.Txns[id]; exists {
 		tx.Status = status
+		tx.UpdatedAt = time.Now()
 		return true
 	}
 	return false
 }
+
+// Collect removes finished transactions last updated before cutoff.
+// Pending transactions are kept regardless of age.
+func (tm *TransactionManager) Collect(cutoff time.Time) int {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	removed := 0
+	for id, tx := range tm.Txns {
+		if tx.Status != "pending" && tx.UpdatedAt.Before(cutoff) {
+			delete(tm.Txns, id)
+			removed++
+		}
+	}
+	return removed
+}
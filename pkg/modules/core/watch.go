@@ -0,0 +1,129 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Watch event types, as Kubernetes names them
+const (
+	WatchAdded    = "ADDED"
+	WatchModified = "MODIFIED"
+	WatchDeleted  = "DELETED"
+	WatchError    = "ERROR" // The object is {"error": message} and the stream ends
+)
+
+// watchPollInterval is how often a watched collection is listed when
+// nothing signals a change
+const watchPollInterval = time.Second
+
+// WatchEvent is one line of a watch stream
+type WatchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// WatchList lists a watched collection's objects by key
+type WatchList func() (map[string]interface{}, error)
+
+// IsWatch reports whether r asks to watch a collection with ?watch=true
+func IsWatch(r *http.Request) bool {
+	watch, _ := strconv.ParseBool(r.URL.Query().Get("watch"))
+	return watch
+}
+
+// ServeWatch holds r open and streams the changes of a collection as JSON
+// lines, Kubernetes-style: every object first as ADDED, then each addition,
+// change and removal as ADDED, MODIFIED and DELETED, until the client goes
+// away. Changes are found by listing the collection every second, and at
+// once whenever changed signals; an object is MODIFIED when its JSON changes
+// and DELETED with its last state. Changes between two listings coalesce.
+func ServeWatch(w http.ResponseWriter, r *http.Request, list WatchList, changed <-chan struct{}) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+	objects, err := list()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(w)
+	sent := make(map[string]json.RawMessage)
+	send := func(objects map[string]interface{}) bool {
+		keys := make([]string, 0, len(objects))
+		for key := range objects {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			encoded, err := json.Marshal(objects[key])
+			if err != nil {
+				return false
+			}
+			previous, exists := sent[key]
+			event := WatchEvent{Type: WatchAdded, Object: encoded}
+			if exists {
+				if bytes.Equal(previous, encoded) {
+					continue
+				}
+				event.Type = WatchModified
+			}
+			if encoder.Encode(event) != nil {
+				return false
+			}
+			sent[key] = encoded
+		}
+
+		var deleted []string
+		for key := range sent {
+			if _, exists := objects[key]; !exists {
+				deleted = append(deleted, key)
+			}
+		}
+		sort.Strings(deleted)
+		for _, key := range deleted {
+			if encoder.Encode(WatchEvent{Type: WatchDeleted, Object: sent[key]}) != nil {
+				return false
+			}
+			delete(sent, key)
+		}
+		flusher.Flush()
+		return true
+	}
+	if !send(objects) {
+		return
+	}
+
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		case _, ok := <-changed:
+			if !ok {
+				changed = nil // Polling still finds changes
+			}
+		}
+		if objects, err = list(); err != nil {
+			message, _ := json.Marshal(map[string]string{"error": err.Error()})
+			encoder.Encode(WatchEvent{Type: WatchError, Object: message})
+			return
+		}
+		if !send(objects) {
+			return
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package vectors
+
+import (
+	"math"
+	"sort"
+)
+
+// DefaultAnalysisTolerance is the mean similarity error accepted when
+// recommending a smaller number of dimensions
+const DefaultAnalysisTolerance = 0.05
+
+// maxAnalysisPairs bounds the query/reference pairs scored by
+// AnalyzeDimensions
+const maxAnalysisPairs = 5000
+
+// DimensionStats describes one dimension across the analyzed vectors
+type DimensionStats struct {
+	Dimension     int     `json:"dimension"`
+	Mean          float64 `json:"mean"`
+	Variance      float64 `json:"variance"`
+	VarianceShare float64 `json:"varianceShare"` // Fraction of the total variance
+	Importance    float64 `json:"importance"`    // Fraction of the similarity scores it decides
+}
+
+// PrefixError is the similarity error from comparing only the first
+// Dimensions dimensions
+type PrefixError struct {
+	Dimensions int     `json:"dimensions"`
+	MeanError  float64 `json:"meanError"`
+	MaxError   float64 `json:"maxError"`
+}
+
+// DimensionAnalysis reports which dimensions drive similarity between query
+// vectors, such as transactions, and reference vectors, such as chains
+type DimensionAnalysis struct {
+	Dimensions  int              `json:"dimensions"`
+	Samples     int              `json:"samples"` // Vectors with a generator
+	Pairs       int              `json:"pairs"`   // Query/reference pairs scored
+	Stats       []DimensionStats `json:"stats"`
+	Ranked      []int            `json:"ranked"` // Dimensions by importance, highest first
+	Prefixes    []PrefixError    `json:"prefixes"`
+	Tolerance   float64          `json:"tolerance"`
+	Recommended int              `json:"recommended"` // Fewest leading dimensions within Tolerance
+}
+
+// AnalyzeDimensions samples the first dims dimensions of queries and
+// references. Variance is taken over all of them; importance is each
+// dimension's mean absolute share of the correlation computed by
+// ComputeVectorSimilarity between every query and reference. Prefixes report
+// how far similarity moves when only leading dimensions are compared, which
+// is what lowering the configured dimensions does.
+func AnalyzeDimensions(queries, references []DatabaseRecord, dims int, tolerance float64) DimensionAnalysis {
+	if tolerance <= 0 {
+		tolerance = DefaultAnalysisTolerance
+	}
+	analysis := DimensionAnalysis{
+		Dimensions:  dims,
+		Stats:       make([]DimensionStats, dims),
+		Ranked:      make([]int, dims),
+		Prefixes:    make([]PrefixError, 0),
+		Tolerance:   tolerance,
+		Recommended: dims,
+	}
+	if dims < 1 {
+		return analysis
+	}
+
+	querySamples := sampleRecords(queries, dims)
+	referenceSamples := sampleRecords(references, dims)
+	all := append(append([][]float64(nil), querySamples...), referenceSamples...)
+	analysis.Samples = len(all)
+
+	for d := range analysis.Stats {
+		analysis.Stats[d].Dimension = d
+		analysis.Ranked[d] = d
+	}
+	analyzeVariance(analysis.Stats, all)
+
+	// Prefixes 2..dims; a single dimension has no correlation
+	if dims > 1 {
+		analysis.Prefixes = make([]PrefixError, dims-1)
+		for k := range analysis.Prefixes {
+			analysis.Prefixes[k].Dimensions = k + 2
+		}
+	}
+
+	var totalImportance float64
+	for _, query := range querySamples {
+		for _, reference := range referenceSamples {
+			if analysis.Pairs >= maxAnalysisPairs {
+				break
+			}
+			analysis.Pairs++
+			totalImportance += analyzePair(analysis.Stats, analysis.Prefixes, query, reference)
+		}
+	}
+
+	if analysis.Pairs > 0 {
+		for d := range analysis.Stats {
+			if totalImportance > 0 {
+				analysis.Stats[d].Importance /= totalImportance
+			}
+		}
+		for k := range analysis.Prefixes {
+			analysis.Prefixes[k].MeanError /= float64(analysis.Pairs)
+		}
+		for _, prefix := range analysis.Prefixes {
+			if prefix.MeanError <= tolerance {
+				analysis.Recommended = prefix.Dimensions
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(analysis.Ranked, func(i, j int) bool {
+		a, b := analysis.Stats[analysis.Ranked[i]], analysis.Stats[analysis.Ranked[j]]
+		if a.Importance != b.Importance {
+			return a.Importance > b.Importance
+		}
+		return a.Variance > b.Variance
+	})
+	return analysis
+}
+
+func sampleRecords(records []DatabaseRecord, dims int) [][]float64 {
+	samples := make([][]float64, 0, len(records))
+	for i := range records {
+		if sample, ok := Sample(&records[i].Vector, dims); ok {
+			samples = append(samples, sample)
+		}
+	}
+	return samples
+}
+
+// analyzeVariance fills in the mean, variance and variance share of each
+// dimension
+func analyzeVariance(stats []DimensionStats, samples [][]float64) {
+	if len(samples) == 0 {
+		return
+	}
+
+	n := float64(len(samples))
+	var total float64
+	for d := range stats {
+		var sum, sum2 float64
+		for _, sample := range samples {
+			sum += sample[d]
+			sum2 += sample[d] * sample[d]
+		}
+		mean := sum / n
+		stats[d].Mean = mean
+		stats[d].Variance = math.Max(sum2/n-mean*mean, 0)
+		total += stats[d].Variance
+	}
+	if total > 0 {
+		for d := range stats {
+			stats[d].VarianceShare = stats[d].Variance / total
+		}
+	}
+}
+
+// analyzePair adds the absolute contribution of each dimension to the
+// correlation of x and y, and the error of each prefix correlation against
+// the full one. It returns the sum of the contributions added.
+func analyzePair(stats []DimensionStats, prefixes []PrefixError, x, y []float64) float64 {
+	full := sampleSimilarity(x, y)
+
+	var sumX, sumY float64
+	for d := range x {
+		sumX += x[d]
+		sumY += y[d]
+	}
+	meanX, meanY := sumX/float64(len(x)), sumY/float64(len(y))
+
+	var sxx, syy float64
+	for d := range x {
+		sxx += (x[d] - meanX) * (x[d] - meanX)
+		syy += (y[d] - meanY) * (y[d] - meanY)
+	}
+	norm := math.Sqrt(sxx * syy)
+
+	var added float64
+	if norm > 0 {
+		for d := range x {
+			contribution := math.Abs((x[d] - meanX) * (y[d] - meanY) / norm)
+			stats[d].Importance += contribution
+			added += contribution
+		}
+	}
+
+	// Prefix correlations from running sums, as ComputeVectorSimilarity
+	// computes them
+	var pXY, pX, pY, pX2, pY2 float64
+	for d := range x {
+		pXY += x[d] * y[d]
+		pX += x[d]
+		pY += y[d]
+		pX2 += x[d] * x[d]
+		pY2 += y[d] * y[d]
+		if d == 0 {
+			continue
+		}
+
+		n := float64(d + 1)
+		similarity := 0.0
+		if denominator := math.Sqrt((n*pX2 - pX*pX) * (n*pY2 - pY*pY)); denominator != 0 {
+			similarity = (n*pXY - pX*pY) / denominator
+		}
+		diff := math.Abs(similarity - full)
+		prefixes[d-1].MeanError += diff
+		prefixes[d-1].MaxError = math.Max(prefixes[d-1].MaxError, diff)
+	}
+	return added
+}
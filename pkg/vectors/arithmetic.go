@@ -0,0 +1,77 @@
+package vectors
+
+import "math"
+
+// Vector arithmetic composes vectors into new generator-backed vectors.
+// Operands are taken by pointer and copied, so a composed vector keeps
+// reading the elements its operands had when it was built even if the
+// originals are later replaced; copies of shared vectors still read through
+// their ElementCache. Elements are computed lazily, one dimension at a time,
+// like any other vector.
+
+// Constant returns a vector whose every element is value
+func Constant(value float64) *InfiniteVector {
+	return &InfiniteVector{Generator: func(int) float64 { return value }}
+}
+
+// FromElements returns a vector of elements that is zero past them
+func FromElements(elements []float64) InfiniteVector {
+	return InfiniteVector{Generator: func(dim int) float64 {
+		if dim < len(elements) {
+			return elements[dim]
+		}
+		return 0
+	}}
+}
+
+// Overlay returns a vector of elements that continues with base's elements
+// past them
+func Overlay(elements []float64, base *InfiniteVector) *InfiniteVector {
+	operand := base.Copy()
+	return &InfiniteVector{Generator: func(dim int) float64 {
+		if dim < len(elements) {
+			return elements[dim]
+		}
+		return operand.GetElement(dim)
+	}}
+}
+
+// Add returns the element-wise sum of vs
+func Add(vs ...*InfiniteVector) *InfiniteVector {
+	operands := make([]InfiniteVector, len(vs))
+	for i := range vs {
+		operands[i] = vs[i].Copy()
+	}
+	return &InfiniteVector{Generator: func(dim int) float64 {
+		var sum float64
+		for i := range operands {
+			sum += operands[i].GetElement(dim)
+		}
+		return sum
+	}}
+}
+
+// Scale returns v with every element multiplied by factor
+func Scale(v *InfiniteVector, factor float64) *InfiniteVector {
+	operand := v.Copy()
+	return &InfiniteVector{Generator: func(dim int) float64 {
+		return operand.GetElement(dim) * factor
+	}}
+}
+
+// Blend returns the weighted mix (1-alpha)*a + alpha*b: alpha 0 is a, 1 is
+// b. It blends observations b into a baseline a.
+func Blend(a, b *InfiniteVector, alpha float64) *InfiniteVector {
+	baseline, observed := a.Copy(), b.Copy()
+	return &InfiniteVector{Generator: func(dim int) float64 {
+		return (1-alpha)*baseline.GetElement(dim) + alpha*observed.GetElement(dim)
+	}}
+}
+
+// Clamp returns v with its elements limited to [min, max]
+func Clamp(v *InfiniteVector, min, max float64) *InfiniteVector {
+	operand := v.Copy()
+	return &InfiniteVector{Generator: func(dim int) float64 {
+		return math.Max(min, math.Min(max, operand.GetElement(dim)))
+	}}
+}
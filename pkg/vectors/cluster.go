@@ -0,0 +1,305 @@
+package vectors
+
+import (
+	"sort"
+	"sync"
+)
+
+// ClusterConfig controls clustering of vectors
+type ClusterConfig struct {
+	MaxClusters int     // Upper bound on the number of clusters
+	Threshold   float64 // Minimum similarity for a vector to join a cluster
+	Dimensions  int     // Dimensions compared
+}
+
+// ClusterInfo describes a cluster
+type ClusterInfo struct {
+	ID       int       `json:"id"`
+	Size     int       `json:"size"`    // Vectors folded into the centroid
+	Members  int       `json:"members"` // IDs currently assigned
+	Centroid []float64 `json:"centroid"`
+}
+
+type cluster struct {
+	id       int
+	centroid []float64
+	size     int
+}
+
+// Clusterer groups vectors into clusters online: a vector joins the most
+// similar cluster, moving its centroid, or starts a new cluster when none is
+// similar enough and MaxClusters allows. Fit refines the clusters with
+// k-means passes. Cluster IDs are never reused, so assignments stay stable
+// across refits.
+type Clusterer struct {
+	config      ClusterConfig
+	clusters    []*cluster
+	nextID      int
+	assignments map[string]int
+	mu          sync.RWMutex
+}
+
+func NewClusterer(config ClusterConfig) *Clusterer {
+	if config.MaxClusters < 1 {
+		config.MaxClusters = 1
+	}
+	if config.Dimensions < 1 {
+		config.Dimensions = 50
+	}
+	return &Clusterer{
+		config:      config,
+		assignments: make(map[string]int),
+	}
+}
+
+// Sample materializes the first dims elements of a vector. Vectors without a
+// generator cannot be sampled.
+func Sample(v *InfiniteVector, dims int) ([]float64, bool) {
+	if v.Generator == nil {
+		return nil, false
+	}
+	sample := make([]float64, dims)
+	for i := range sample {
+		sample[i] = v.GetElement(i)
+	}
+	return sample, true
+}
+
+// sampleVector wraps a sample so it can be compared with
+// ComputeVectorSimilarity
+func sampleVector(sample []float64) InfiniteVector {
+	return FromElements(sample)
+}
+
+func sampleSimilarity(a, b []float64) float64 {
+	return ComputeVectorSimilarity(sampleVector(a), sampleVector(b), len(a))
+}
+
+// Nearest returns the most similar cluster to v. ok is false when v cannot
+// be sampled or no clusters exist.
+func (c *Clusterer) Nearest(v *InfiniteVector) (id int, similarity float64, ok bool) {
+	sample, ok := Sample(v, c.config.Dimensions)
+	if !ok {
+		return -1, 0, false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	nearest, similarity := c.nearest(sample)
+	if nearest == nil {
+		return -1, 0, false
+	}
+	return nearest.id, similarity, true
+}
+
+// Add assigns v to a cluster, updating its centroid, and records the
+// assignment under id unless id is empty. A vector already assigned under
+// id keeps its cluster.
+func (c *Clusterer) Add(id string, v *InfiniteVector) (cluster int, similarity float64, ok bool) {
+	sample, ok := Sample(v, c.config.Dimensions)
+	if !ok {
+		return -1, 0, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if existing, assigned := c.assignments[id]; assigned && id != "" {
+		for _, cl := range c.clusters {
+			if cl.id == existing {
+				return existing, sampleSimilarity(cl.centroid, sample), true
+			}
+		}
+	}
+
+	cl, similarity := c.add(sample)
+	if id != "" {
+		c.assignments[id] = cl.id
+	}
+	return cl.id, similarity, true
+}
+
+// add folds a sample into its cluster; callers hold c.mu
+func (c *Clusterer) add(sample []float64) (*cluster, float64) {
+	nearest, similarity := c.nearest(sample)
+	if nearest == nil || (similarity < c.config.Threshold && len(c.clusters) < c.config.MaxClusters) {
+		cl := &cluster{id: c.nextID, centroid: sample, size: 1}
+		c.nextID++
+		c.clusters = append(c.clusters, cl)
+		return cl, 1
+	}
+
+	nearest.size++
+	for i := range nearest.centroid {
+		nearest.centroid[i] += (sample[i] - nearest.centroid[i]) / float64(nearest.size)
+	}
+	return nearest, similarity
+}
+
+// nearest returns the most similar cluster to a sample; callers hold c.mu
+func (c *Clusterer) nearest(sample []float64) (*cluster, float64) {
+	var best *cluster
+	var bestSimilarity float64
+	for _, cl := range c.clusters {
+		similarity := sampleSimilarity(cl.centroid, sample)
+		if best == nil || similarity > bestSimilarity {
+			best, bestSimilarity = cl, similarity
+		}
+	}
+	return best, bestSimilarity
+}
+
+// Assignment returns the cluster an ID was assigned to
+func (c *Clusterer) Assignment(id string) (int, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	cluster, exists := c.assignments[id]
+	return cluster, exists
+}
+
+// Members returns the IDs assigned to a cluster, sorted
+func (c *Clusterer) Members(cluster int) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	members := make([]string, 0)
+	for id, assigned := range c.assignments {
+		if assigned == cluster {
+			members = append(members, id)
+		}
+	}
+	sort.Strings(members)
+	return members
+}
+
+// Remove forgets an ID's assignment; the centroid is left as it is
+func (c *Clusterer) Remove(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, exists := c.assignments[id]
+	delete(c.assignments, id)
+	return exists
+}
+
+// Clusters describes every cluster in ID order
+func (c *Clusterer) Clusters() []ClusterInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	members := make(map[int]int)
+	for _, cluster := range c.assignments {
+		members[cluster]++
+	}
+
+	infos := make([]ClusterInfo, len(c.clusters))
+	for i, cl := range c.clusters {
+		infos[i] = ClusterInfo{
+			ID:       cl.id,
+			Size:     cl.size,
+			Members:  members[cl.id],
+			Centroid: append([]float64(nil), cl.centroid...),
+		}
+	}
+	return infos
+}
+
+// Reset removes all clusters and assignments
+func (c *Clusterer) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clusters = nil
+	c.assignments = make(map[string]int)
+}
+
+// Fit reclusters records with up to iterations k-means passes, starting from
+// the current centroids so that cluster IDs carry over. Assignments are
+// replaced by those of the records; clusters left empty are dropped.
+func (c *Clusterer) Fit(records []DatabaseRecord, iterations int) {
+	ids := make([]string, 0, len(records))
+	samples := make([][]float64, 0, len(records))
+	for i := range records {
+		if sample, ok := Sample(&records[i].Vector, c.config.Dimensions); ok {
+			ids = append(ids, records[i].ID)
+			samples = append(samples, sample)
+		}
+	}
+
+	if iterations < 1 {
+		iterations = 1
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Seed any clusters the records need with an online pass
+	if len(c.clusters) == 0 {
+		for _, sample := range samples {
+			c.add(append([]float64(nil), sample...))
+		}
+	}
+
+	assigned := make([]*cluster, len(samples))
+	for pass := 0; pass < iterations; pass++ {
+		changed := false
+		for i, sample := range samples {
+			nearest, similarity := c.nearest(sample)
+			if similarity < c.config.Threshold && len(c.clusters) < c.config.MaxClusters {
+				nearest = &cluster{id: c.nextID, centroid: append([]float64(nil), sample...)}
+				c.nextID++
+				c.clusters = append(c.clusters, nearest)
+			}
+			if assigned[i] != nearest {
+				assigned[i] = nearest
+				changed = true
+			}
+		}
+		c.recenter(samples, assigned)
+		if !changed {
+			break
+		}
+	}
+
+	c.assignments = make(map[string]int, len(ids))
+	for i, id := range ids {
+		if assigned[i] != nil {
+			c.assignments[id] = assigned[i].id
+		}
+	}
+}
+
+// recenter moves each centroid to the mean of its samples and drops
+// clusters without any; callers hold c.mu
+func (c *Clusterer) recenter(samples [][]float64, assigned []*cluster) {
+	sums := make(map[*cluster][]float64)
+	counts := make(map[*cluster]int)
+	for i, cl := range assigned {
+		if cl == nil {
+			continue
+		}
+		sum, exists := sums[cl]
+		if !exists {
+			sum = make([]float64, len(samples[i]))
+			sums[cl] = sum
+		}
+		for d, value := range samples[i] {
+			sum[d] += value
+		}
+		counts[cl]++
+	}
+
+	kept := c.clusters[:0]
+	for _, cl := range c.clusters {
+		count := counts[cl]
+		if count == 0 {
+			continue
+		}
+		for d := range cl.centroid {
+			cl.centroid[d] = sums[cl][d] / float64(count)
+		}
+		cl.size = count
+		kept = append(kept, cl)
+	}
+	c.clusters = kept
+}
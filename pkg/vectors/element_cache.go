@@ -0,0 +1,222 @@
+package vectors
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultCacheElements bounds an ElementCache created without a capacity
+const DefaultCacheElements = 1 << 18
+
+// ElementCacheStats reports an ElementCache's size and effectiveness
+type ElementCacheStats struct {
+	Vectors     int     `json:"vectors"`     // Vectors with cached elements
+	Elements    int     `json:"elements"`    // Elements cached across vectors
+	MaxElements int     `json:"maxElements"` // Capacity in elements
+	Hits        uint64  `json:"hits"`        // Elements served from the cache
+	Misses      uint64  `json:"misses"`      // Elements generated for shared vectors
+	Evictions   uint64  `json:"evictions"`
+	HitRate     float64 `json:"hitRate"`
+}
+
+type cacheEntry struct {
+	id       uint64
+	elements []float64
+}
+
+// ElementCache holds materialized elements for shared vectors. Vectors are
+// usually passed by value, so each copy would otherwise regenerate elements
+// its original already computed; copies of a shared vector read from and
+// write through to one entry instead. Entries are evicted least recently
+// used first once MaxElements is exceeded, and dropped when the last
+// reference to their vector is released.
+type ElementCache struct {
+	maxElements int
+	elements    int
+	entries     map[uint64]*list.Element
+	lru         *list.List // Front is most recently used
+	nextID      uint64
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	evictions   atomic.Uint64
+	mu          sync.Mutex
+}
+
+// vectorHandle ties a vector and its copies to a cache entry
+type vectorHandle struct {
+	cache *ElementCache
+	id    uint64
+	refs  atomic.Int64
+}
+
+func NewElementCache(maxElements int) *ElementCache {
+	if maxElements <= 0 {
+		maxElements = DefaultCacheElements
+	}
+	return &ElementCache{
+		maxElements: maxElements,
+		entries:     make(map[uint64]*list.Element),
+		lru:         list.New(),
+	}
+}
+
+// Share makes v and copies taken from it afterwards materialize through the
+// cache. Sharing a vector that is already shared adds a reference; each
+// Share is balanced by a Release.
+func (c *ElementCache) Share(v *InfiniteVector) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.shared != nil {
+		v.shared.refs.Add(1)
+		return
+	}
+
+	c.mu.Lock()
+	c.nextID++
+	handle := &vectorHandle{cache: c, id: c.nextID}
+	c.mu.Unlock()
+
+	handle.refs.Store(1)
+	v.shared = handle
+}
+
+// Release drops a reference taken by Share. The cache entry is removed with
+// the last reference; v and its copies keep the elements they hold.
+func (v *InfiniteVector) Release() {
+	v.mu.Lock()
+	handle := v.shared
+	v.mu.Unlock()
+
+	if handle != nil && handle.refs.Add(-1) == 0 {
+		handle.cache.remove(handle.id)
+	}
+}
+
+// Shared reports whether v materializes through an ElementCache
+func (v *InfiniteVector) Shared() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.shared != nil
+}
+
+// Copy returns a copy of v. A shared vector's copy holds none of the
+// elements materialized so far and reads them through the cache instead, so
+// copies kept alongside the original, such as index records, stay small.
+func (v *InfiniteVector) Copy() InfiniteVector {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if v.shared != nil {
+		return InfiniteVector{shared: v.shared, Generator: v.Generator}
+	}
+	return InfiniteVector{elements: v.elements[:len(v.elements):len(v.elements)], Generator: v.Generator}
+}
+
+// load extends local with cached elements up to and including dimension
+// where the cache has them
+func (h *vectorHandle) load(local []float64, dimension int) []float64 {
+	if h.refs.Load() <= 0 {
+		return local
+	}
+
+	c := h.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, exists := c.entries[h.id]
+	if !exists {
+		return local
+	}
+	cached := item.Value.(*cacheEntry).elements
+	if len(cached) <= len(local) {
+		return local
+	}
+	c.lru.MoveToFront(item)
+
+	end := dimension + 1
+	if end > len(cached) {
+		end = len(cached)
+	}
+	c.hits.Add(uint64(end - len(local)))
+	return append(local[:len(local):len(local)], cached[len(local):end]...)
+}
+
+// store writes a vector's elements through to the cache after generated of
+// them were computed
+func (h *vectorHandle) store(elements []float64, generated int) {
+	if h.refs.Load() <= 0 {
+		return
+	}
+
+	c := h.cache
+	c.misses.Add(uint64(generated))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, exists := c.entries[h.id]
+	if !exists {
+		item = c.lru.PushFront(&cacheEntry{id: h.id})
+		c.entries[h.id] = item
+	} else {
+		c.lru.MoveToFront(item)
+	}
+
+	entry := item.Value.(*cacheEntry)
+	if len(entry.elements) >= len(elements) {
+		return
+	}
+	c.elements += len(elements) - len(entry.elements)
+	entry.elements = append(entry.elements[:len(entry.elements):len(entry.elements)], elements[len(entry.elements):]...)
+	c.evict(h.id)
+}
+
+// evict drops least recently used entries other than keep until the cache
+// is within capacity; callers hold c.mu
+func (c *ElementCache) evict(keep uint64) {
+	for c.elements > c.maxElements {
+		item := c.lru.Back()
+		if item == nil || item.Value.(*cacheEntry).id == keep {
+			return
+		}
+		c.removeItem(item)
+		c.evictions.Add(1)
+	}
+}
+
+func (c *ElementCache) remove(id uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if item, exists := c.entries[id]; exists {
+		c.removeItem(item)
+	}
+}
+
+// removeItem drops an entry; callers hold c.mu
+func (c *ElementCache) removeItem(item *list.Element) {
+	entry := item.Value.(*cacheEntry)
+	c.lru.Remove(item)
+	delete(c.entries, entry.id)
+	c.elements -= len(entry.elements)
+}
+
+// Stats reports the cache's size and hit rate
+func (c *ElementCache) Stats() ElementCacheStats {
+	c.mu.Lock()
+	stats := ElementCacheStats{
+		Vectors:     len(c.entries),
+		Elements:    c.elements,
+		MaxElements: c.maxElements,
+	}
+	c.mu.Unlock()
+
+	stats.Hits = c.hits.Load()
+	stats.Misses = c.misses.Load()
+	stats.Evictions = c.evictions.Load()
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
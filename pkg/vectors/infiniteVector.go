@@ -4,18 +4,23 @@ import (
 	"fmt"
 	"math"
 	"sync"
+	"time"
 )
 
 type InfiniteVectorIndex struct {
 	mu                  sync.RWMutex
-	vectorSpace         map[string]InfiniteVector
+	vectorSpace         map[string]*InfiniteVector
 	dimensionGenerators map[string]func(int) float64
 	metadataStore       map[string]map[string]interface{}
+	insertedAt          map[string]time.Time
+	tier                *tier       // Nil unless EnableTiering was called
+	cache               *QueryCache // Nil unless EnableQueryCache was called
 }
 
 type InfiniteVector struct {
 	mu        sync.RWMutex
 	elements  []float64
+	shared    *vectorHandle // Set by ElementCache.Share; kept by copies
 	Generator func(int) float64
 }
 
@@ -27,9 +32,10 @@ type DatabaseRecord struct {
 
 func NewInfiniteVectorIndex() *InfiniteVectorIndex {
 	return &InfiniteVectorIndex{
-		vectorSpace:         make(map[string]InfiniteVector),
+		vectorSpace:         make(map[string]*InfiniteVector),
 		dimensionGenerators: make(map[string]func(int) float64),
 		metadataStore:       make(map[string]map[string]interface{}),
+		insertedAt:          make(map[string]time.Time),
 	}
 }
 
@@ -49,12 +55,139 @@ func (db *InfiniteVectorIndex) Insert(record DatabaseRecord) error {
 		}
 	}
 
-	db.vectorSpace[record.ID] = record.Vector
+	if db.tier != nil {
+		if _, hot := db.vectorSpace[record.ID]; !hot {
+			db.dropCold(record.ID)
+		}
+	}
+
+	vector := record.Vector.Copy()
+	db.vectorSpace[record.ID] = &vector
 	db.metadataStore[record.ID] = record.Metadata
+	db.insertedAt[record.ID] = time.Now()
+	db.recordAdded(record.ID)
 
+	if db.tier != nil {
+		db.tier.forget(record.ID)
+		db.tier.touch(record.ID, db.recordSize(record.ID))
+		db.demote("")
+	}
 	return nil
 }
 
+// Get returns the record stored under id, promoting it to memory if it is
+// cold
+func (db *InfiniteVectorIndex) Get(id string) (DatabaseRecord, bool) {
+	db.mu.RLock()
+	tiered := db.tier != nil
+	db.mu.RUnlock()
+	if tiered {
+		return db.getTiered(id)
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	vector, exists := db.vectorSpace[id]
+	if !exists {
+		return DatabaseRecord{}, false
+	}
+	return DatabaseRecord{
+		ID:       id,
+		Metadata: db.metadataStore[id],
+		Vector:   vector.Copy(),
+	}, true
+}
+
+func (db *InfiniteVectorIndex) getTiered(id string) (DatabaseRecord, bool) {
+	var metadata map[string]interface{}
+	var vector *InfiniteVector
+	exists := db.promote(id, func() {
+		db.tier.touch(id, db.recordSize(id))
+		// A record larger than the budget stays hot until something else is
+		// used, rather than being demoted as soon as it was read
+		db.demote(id)
+		metadata = db.metadataStore[id]
+		vector = db.vectorSpace[id]
+	})
+	if !exists {
+		return DatabaseRecord{}, false
+	}
+	return DatabaseRecord{
+		ID:       id,
+		Metadata: metadata,
+		Vector:   vector.Copy(),
+	}, true
+}
+
+// Delete removes a record from the index
+func (db *InfiniteVectorIndex) Delete(id string) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, exists := db.vectorSpace[id]; !exists {
+		return db.tier != nil && db.dropCold(id)
+	}
+	delete(db.vectorSpace, id)
+	delete(db.metadataStore, id)
+	delete(db.insertedAt, id)
+	db.recordRemoved(id)
+	if db.tier != nil {
+		db.tier.forget(id)
+		delete(db.tier.pinned, id)
+	}
+	return true
+}
+
+// Len returns the number of records in the index, hot and cold
+func (db *InfiniteVectorIndex) Len() int {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.tier != nil {
+		return len(db.vectorSpace) + len(db.tier.cold)
+	}
+	return len(db.vectorSpace)
+}
+
+// Records returns every record in the index, reading cold records without
+// promoting them
+func (db *InfiniteVectorIndex) Records() []DatabaseRecord {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	results := make([]DatabaseRecord, 0, len(db.vectorSpace))
+	for id, vector := range db.vectorSpace {
+		results = append(results, DatabaseRecord{
+			ID:       id,
+			Metadata: db.metadataStore[id],
+			Vector:   vector.Copy(),
+		})
+	}
+	return db.scanCold(results, func(ColdRecord) (keep, more bool) { return true, true })
+}
+
+// InsertedBefore returns the records inserted before cutoff
+func (db *InfiniteVectorIndex) InsertedBefore(cutoff time.Time) []DatabaseRecord {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var results []DatabaseRecord
+	for id, vector := range db.vectorSpace {
+		if db.insertedAt[id].Before(cutoff) {
+			results = append(results, DatabaseRecord{
+				ID:       id,
+				Metadata: db.metadataStore[id],
+				Vector:   vector.Copy(),
+			})
+		}
+	}
+	return db.scanCold(results, func(record ColdRecord) (keep, more bool) {
+		return record.InsertedAt.Before(cutoff), true
+	})
+}
+
+// QueryByDimension returns up to maxResults records dimensionSelector
+// accepts, hot records first
 func (db *InfiniteVectorIndex) QueryByDimension(
 	dimensionSelector func(vector InfiniteVector) bool,
 	maxResults int,
@@ -65,20 +198,27 @@ func (db *InfiniteVectorIndex) QueryByDimension(
 	var results []DatabaseRecord
 
 	for id, vector := range db.vectorSpace {
-		if dimensionSelector(vector) {
+		if dimensionSelector(vector.Copy()) {
 			results = append(results, DatabaseRecord{
 				ID:       id,
 				Metadata: db.metadataStore[id],
-				Vector:   vector,
+				Vector:   vector.Copy(),
 			})
 
 			if len(results) >= maxResults {
-				break
+				return results
 			}
 		}
 	}
 
-	return results
+	remaining := maxResults - len(results)
+	return db.scanCold(results, func(record ColdRecord) (keep, more bool) {
+		if !dimensionSelector(db.coldVector(record)) {
+			return false, true
+		}
+		remaining--
+		return true, remaining > 0
+	})
 }
 
 func (db *InfiniteVectorIndex) AdvancedQuery(
@@ -89,23 +229,49 @@ func (db *InfiniteVectorIndex) AdvancedQuery(
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
+	// Cached results stay valid while the lock is held
+	var query []float64
+	var key [32]byte
+	if db.cache != nil && maxDimensions > 0 {
+		query = make([]float64, maxDimensions)
+		for d := range query {
+			query[d] = queryVector.GetElement(d)
+		}
+		key = QueryFingerprint(similarityThreshold, query)
+		if results, hit := db.cache.Get(key); hit {
+			return results
+		}
+	}
+
 	var results []DatabaseRecord
 
 	for id, vector := range db.vectorSpace {
-		similarity := ComputeVectorSimilarity(queryVector, vector, maxDimensions)
-		if similarity >= similarityThreshold {
+		if similarity(&queryVector, vector, maxDimensions) >= similarityThreshold {
 			results = append(results, DatabaseRecord{
 				ID:       id,
 				Metadata: db.metadataStore[id],
-				Vector:   vector,
+				Vector:   vector.Copy(),
 			})
 		}
 	}
+	results = db.scanCold(results, func(record ColdRecord) (keep, more bool) {
+		vector := db.coldVector(record)
+		return similarity(&queryVector, &vector, maxDimensions) >= similarityThreshold, true
+	})
 
+	if query != nil {
+		db.cache.put(&queryCacheEntry{key: key, results: results, query: query, threshold: similarityThreshold})
+	}
 	return results
 }
 
 func ComputeVectorSimilarity(v1, v2 InfiniteVector, dimensions int) float64 {
+	return similarity(&v1, &v2, dimensions)
+}
+
+// similarity is ComputeVectorSimilarity for vectors the caller holds, so
+// elements materialized while comparing are kept
+func similarity(v1, v2 *InfiniteVector, dimensions int) float64 {
 	var sumXY, sumX, sumY, sumX2, sumY2 float64
 
 	for i := 0; i < dimensions; i++ {
@@ -134,10 +300,17 @@ func (v *InfiniteVector) GetElement(dimension int) float64 {
 	v.mu.Lock()
 	defer v.mu.Unlock()
 
+	if dimension >= len(v.elements) && v.shared != nil {
+		v.elements = v.shared.load(v.elements, dimension)
+	}
 	if dimension >= len(v.elements) {
+		start := len(v.elements)
 		for len(v.elements) <= dimension {
 			v.elements = append(v.elements, v.Generator(len(v.elements)))
 		}
+		if v.shared != nil {
+			v.shared.store(v.elements, len(v.elements)-start)
+		}
 	}
 
 	return v.elements[dimension]
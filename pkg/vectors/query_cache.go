@@ -0,0 +1,241 @@
+package vectors
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultQueryCacheEntries bounds a QueryCache created without a capacity
+const DefaultQueryCacheEntries = 1024
+
+// QueryCacheStats reports a QueryCache's size and effectiveness
+type QueryCacheStats struct {
+	Entries       int     `json:"entries"`
+	MaxEntries    int     `json:"maxEntries"`
+	Hits          uint64  `json:"hits"`
+	Misses        uint64  `json:"misses"`
+	Invalidations uint64  `json:"invalidations"` // Entries dropped because the data changed
+	Evictions     uint64  `json:"evictions"`
+	HitRate       float64 `json:"hitRate"`
+}
+
+// QueryCache holds the results of recent queries, keyed by a fingerprint of
+// the query, least recently used first out. Entries are dropped when the
+// records they were computed from change: an index drops a similarity query
+// when a record it returned is deleted, or a record inserted would match it.
+type QueryCache struct {
+	maxEntries    int
+	entries       map[[32]byte]*list.Element
+	lru           *list.List // Front is most recently used
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	invalidations atomic.Uint64
+	evictions     atomic.Uint64
+	mu            sync.Mutex
+}
+
+type queryCacheEntry struct {
+	key     [32]byte
+	results []DatabaseRecord
+	ids     map[string]bool
+
+	// Set for similarity queries, so inserts can be matched against them
+	query     []float64
+	threshold float64
+}
+
+func NewQueryCache(maxEntries int) *QueryCache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultQueryCacheEntries
+	}
+	return &QueryCache{
+		maxEntries: maxEntries,
+		entries:    make(map[[32]byte]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// QueryFingerprint identifies a similarity query by its threshold, the
+// leading elements of its vector, and any further parameters
+func QueryFingerprint(threshold float64, elements []float64, params ...string) [32]byte {
+	h := sha256.New()
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], math.Float64bits(threshold))
+	h.Write(buf[:])
+	binary.BigEndian.PutUint64(buf[:], uint64(len(elements)))
+	h.Write(buf[:])
+	for _, element := range elements {
+		binary.BigEndian.PutUint64(buf[:], math.Float64bits(element))
+		h.Write(buf[:])
+	}
+	for _, param := range params {
+		h.Write([]byte{0})
+		h.Write([]byte(param))
+	}
+
+	var sum [32]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// Get returns a copy of the results cached under key
+func (c *QueryCache) Get(key [32]byte) ([]DatabaseRecord, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, exists := c.entries[key]
+	if !exists {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	c.lru.MoveToFront(item)
+	return append([]DatabaseRecord(nil), item.Value.(*queryCacheEntry).results...), true
+}
+
+// Put caches results under key until Invalidate, or InvalidateRecord for one
+// of the records, drops them
+func (c *QueryCache) Put(key [32]byte, results []DatabaseRecord) {
+	c.put(&queryCacheEntry{key: key, results: results})
+}
+
+func (c *QueryCache) put(entry *queryCacheEntry) {
+	entry.results = append([]DatabaseRecord(nil), entry.results...)
+	entry.ids = make(map[string]bool, len(entry.results))
+	for i := range entry.results {
+		entry.ids[entry.results[i].ID] = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if item, exists := c.entries[entry.key]; exists {
+		item.Value = entry
+		c.lru.MoveToFront(item)
+		return
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	for len(c.entries) > c.maxEntries {
+		c.remove(c.lru.Back())
+		c.evictions.Add(1)
+	}
+}
+
+// Invalidate drops every entry
+func (c *QueryCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.invalidations.Add(uint64(len(c.entries)))
+	c.entries = make(map[[32]byte]*list.Element)
+	c.lru.Init()
+}
+
+// InvalidateRecord drops the entries whose results include a record
+func (c *QueryCache) InvalidateRecord(id string) {
+	c.invalidate(func(entry *queryCacheEntry) bool {
+		return entry.ids[id]
+	})
+}
+
+// recordInserted drops the entries a new or replaced record would change:
+// those that returned it, and similarity queries it matches
+func (c *QueryCache) recordInserted(id string, vector *InfiniteVector) {
+	// Materialize the elements compared once rather than per entry
+	c.mu.Lock()
+	dims := 0
+	for _, item := range c.entries {
+		dims = max(dims, len(item.Value.(*queryCacheEntry).query))
+	}
+	c.mu.Unlock()
+	if dims > 0 {
+		vector.GetElement(dims - 1)
+	}
+
+	c.invalidate(func(entry *queryCacheEntry) bool {
+		if entry.ids[id] {
+			return true
+		}
+		if entry.query == nil {
+			return false
+		}
+		query := InfiniteVector{elements: entry.query, Generator: zeroGenerator}
+		return similarity(&query, vector, len(entry.query)) >= entry.threshold
+	})
+}
+
+func (c *QueryCache) invalidate(stale func(*queryCacheEntry) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, item := range c.entries {
+		if stale(item.Value.(*queryCacheEntry)) {
+			c.remove(item)
+			c.invalidations.Add(1)
+		}
+	}
+}
+
+func (c *QueryCache) remove(item *list.Element) {
+	delete(c.entries, item.Value.(*queryCacheEntry).key)
+	c.lru.Remove(item)
+}
+
+// Stats returns a snapshot of the cache's counters
+func (c *QueryCache) Stats() QueryCacheStats {
+	c.mu.Lock()
+	entries := len(c.entries)
+	c.mu.Unlock()
+
+	stats := QueryCacheStats{
+		Entries:       entries,
+		MaxEntries:    c.maxEntries,
+		Hits:          c.hits.Load(),
+		Misses:        c.misses.Load(),
+		Invalidations: c.invalidations.Load(),
+		Evictions:     c.evictions.Load(),
+	}
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(lookups)
+	}
+	return stats
+}
+
+func zeroGenerator(int) float64 { return 0 }
+
+// EnableQueryCache caches the results of up to maxEntries AdvancedQuery
+// calls. Inserts and deletes drop only the entries they would change.
+func (db *InfiniteVectorIndex) EnableQueryCache(maxEntries int) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.cache = NewQueryCache(maxEntries)
+}
+
+// QueryCacheStats reports the index's query cache; false when it is not
+// enabled
+func (db *InfiniteVectorIndex) QueryCacheStats() (QueryCacheStats, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.cache == nil {
+		return QueryCacheStats{}, false
+	}
+	return db.cache.Stats(), true
+}
+
+// recordAdded and recordRemoved keep the query cache in step with the hot
+// records; callers hold db.mu
+func (db *InfiniteVectorIndex) recordAdded(id string) {
+	if db.cache != nil {
+		db.cache.recordInserted(id, db.vectorSpace[id])
+	}
+}
+
+func (db *InfiniteVectorIndex) recordRemoved(id string) {
+	if db.cache != nil {
+		db.cache.InvalidateRecord(id)
+	}
+}
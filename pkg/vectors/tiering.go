@@ -0,0 +1,352 @@
+package vectors
+
+import (
+	"container/list"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTierDimensions is the number of elements kept for a cold record
+// when TierConfig.Dimensions is unset
+const DefaultTierDimensions = 50
+
+// tierRecordOverhead approximates the memory a hot record costs beyond its
+// elements and metadata: map entries, the vector header and its generator
+const tierRecordOverhead = 256
+
+// ColdRecord is a record demoted from an index's memory tier. Generators
+// cannot be stored, so the store holds the elements materialized by then, at
+// least TierConfig.Dimensions of them, and the index keeps the generator in
+// memory to compute the rest.
+type ColdRecord struct {
+	ID         string
+	Metadata   map[string]interface{}
+	Elements   []float64
+	InsertedAt time.Time
+}
+
+// ColdStore holds records demoted from an index's memory tier. Get is called
+// without the index's lock, so it may run concurrently with the other methods.
+type ColdStore interface {
+	Put(record ColdRecord) error
+	Get(id string) (ColdRecord, bool, error)
+	Delete(id string) (bool, error)
+	// Scan calls fn for every stored record until fn returns false
+	Scan(fn func(ColdRecord) bool) error
+}
+
+// TierConfig bounds an index's memory tier
+type TierConfig struct {
+	MemoryBudget int64 // Estimated bytes of hot records before the least recently used are demoted
+	Dimensions   int   // Elements at least stored for a cold record, DefaultTierDimensions if unset
+}
+
+// TierStats reports how an index's records are split between tiers
+type TierStats struct {
+	HotRecords    int    `json:"hotRecords"`
+	ColdRecords   int    `json:"coldRecords"`
+	PinnedRecords int    `json:"pinnedRecords"`
+	HotBytes      int64  `json:"hotBytes"` // Estimated
+	MemoryBudget  int64  `json:"memoryBudget"`
+	Promotions    uint64 `json:"promotions"`
+	Demotions     uint64 `json:"demotions"`
+	Errors        uint64 `json:"errors"` // Failed cold store reads and writes
+}
+
+// tier tracks hot records least recently used first; callers hold the
+// index's lock
+type tier struct {
+	store   ColdStore
+	config  TierConfig
+	lru     *list.List // Front is most recently used
+	entries map[string]*list.Element
+	pinned  map[string]bool
+	bytes   int64
+	cold    map[string]coldEntry
+	version uint64        // Counts demotions
+	stats   TierStats     // Promotions and demotions
+	errors  atomic.Uint64 // Counted under the read lock by scans
+}
+
+type tierEntry struct {
+	id   string
+	size int64
+}
+
+// coldEntry is what the index keeps in memory of a cold record
+type coldEntry struct {
+	generator func(int) float64
+	version   uint64 // Changes each time the record is demoted
+}
+
+// EnableTiering keeps at most config.MemoryBudget of records in memory and
+// demotes the least recently used of the rest to store. Get promotes a cold
+// record back to memory; Records, InsertedBefore, QueryByDimension and
+// AdvancedQuery read cold records without promoting them. Cold records keep
+// their generators in memory, so they read as they did while hot. The store
+// must be empty; call EnableTiering before inserting.
+func (db *InfiniteVectorIndex) EnableTiering(store ColdStore, config TierConfig) {
+	if config.Dimensions <= 0 {
+		config.Dimensions = DefaultTierDimensions
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.tier = &tier{
+		store:   store,
+		config:  config,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+		pinned:  make(map[string]bool),
+		cold:    make(map[string]coldEntry),
+	}
+	for id := range db.vectorSpace {
+		db.tier.touch(id, db.recordSize(id))
+	}
+	db.demote("")
+}
+
+// Pin keeps a record in memory, promoting it if it is cold. Pinned records
+// do not count against the memory budget. It reports whether the record
+// exists; without tiering every record is hot.
+func (db *InfiniteVectorIndex) Pin(id string) bool {
+	db.mu.RLock()
+	if db.tier == nil {
+		_, exists := db.vectorSpace[id]
+		db.mu.RUnlock()
+		return exists
+	}
+	db.mu.RUnlock()
+
+	return db.promote(id, func() {
+		db.tier.pinned[id] = true
+		db.tier.forget(id)
+	})
+}
+
+// TierStats reports the index's tiers; false when tiering is not enabled
+func (db *InfiniteVectorIndex) TierStats() (TierStats, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.tier == nil {
+		return TierStats{}, false
+	}
+	stats := db.tier.stats
+	stats.HotRecords = len(db.vectorSpace)
+	stats.ColdRecords = len(db.tier.cold)
+	stats.PinnedRecords = len(db.tier.pinned)
+	stats.HotBytes = db.tier.bytes
+	stats.MemoryBudget = db.tier.config.MemoryBudget
+	stats.Errors = db.tier.errors.Load()
+	return stats, true
+}
+
+// touch marks a hot record as most recently used
+func (t *tier) touch(id string, size int64) {
+	if t.pinned[id] {
+		return
+	}
+	if item, exists := t.entries[id]; exists {
+		t.lru.MoveToFront(item)
+		return
+	}
+	t.entries[id] = t.lru.PushFront(&tierEntry{id: id, size: size})
+	t.bytes += size
+}
+
+// forget stops tracking a record that was deleted, demoted or pinned
+func (t *tier) forget(id string) {
+	if item, exists := t.entries[id]; exists {
+		t.bytes -= item.Value.(*tierEntry).size
+		t.lru.Remove(item)
+		delete(t.entries, id)
+	}
+}
+
+// recordSize estimates the memory a hot record costs; callers hold db.mu
+func (db *InfiniteVectorIndex) recordSize(id string) int64 {
+	elements := max(db.tier.config.Dimensions, db.vectorSpace[id].materialized())
+	size := int64(tierRecordOverhead + len(id) + 8*elements)
+	for key, value := range db.metadataStore[id] {
+		size += int64(len(key)) + 16
+		switch v := value.(type) {
+		case string:
+			size += int64(len(v))
+		case []byte:
+			size += int64(len(v))
+		}
+	}
+	return size
+}
+
+// demote moves least recently used records other than keep to the cold
+// store until the hot tier is within budget; callers hold db.mu
+func (db *InfiniteVectorIndex) demote(keep string) {
+	t := db.tier
+	for t.bytes > t.config.MemoryBudget {
+		item := t.lru.Back()
+		if item == nil || item.Value.(*tierEntry).id == keep {
+			return
+		}
+		id := item.Value.(*tierEntry).id
+		vector := db.vectorSpace[id]
+
+		record := ColdRecord{
+			ID:         id,
+			Metadata:   db.metadataStore[id],
+			Elements:   vector.snapshot(t.config.Dimensions),
+			InsertedAt: db.insertedAt[id],
+		}
+		if err := t.store.Put(record); err != nil {
+			// Keep the record hot rather than lose it
+			t.errors.Add(1)
+			return
+		}
+
+		db.recordRemoved(id)
+		t.forget(id)
+		delete(db.vectorSpace, id)
+		delete(db.metadataStore, id)
+		delete(db.insertedAt, id)
+		t.version++
+		t.cold[id] = coldEntry{generator: vector.Generator, version: t.version}
+		t.stats.Demotions++
+	}
+}
+
+// promote makes a record hot and calls fn with db.mu held while it is. A
+// cold record is read from the store without holding db.mu, so a slow disk
+// does not stall the index. It reports whether the record exists.
+func (db *InfiniteVectorIndex) promote(id string, fn func()) bool {
+	for {
+		db.mu.Lock()
+		if _, hot := db.vectorSpace[id]; hot {
+			fn()
+			db.mu.Unlock()
+			return true
+		}
+		t := db.tier
+		entry, cold := t.cold[id]
+		db.mu.Unlock()
+		if !cold {
+			return false
+		}
+
+		record, exists, err := t.store.Get(id)
+		if err != nil {
+			t.errors.Add(1)
+			return false
+		}
+
+		db.mu.Lock()
+		if current, cold := t.cold[id]; !cold || current.version != entry.version {
+			// Promoted, deleted or demoted again while it was read
+			db.mu.Unlock()
+			continue
+		}
+		if !exists {
+			db.mu.Unlock()
+			t.errors.Add(1)
+			return false
+		}
+		if _, err := t.store.Delete(id); err != nil {
+			db.mu.Unlock()
+			t.errors.Add(1)
+			return false
+		}
+
+		vector := db.coldVector(record)
+		db.vectorSpace[id] = &vector
+		db.metadataStore[id] = record.Metadata
+		db.insertedAt[id] = record.InsertedAt
+		db.recordAdded(id)
+		delete(t.cold, id)
+		t.stats.Promotions++
+		t.touch(id, db.recordSize(id))
+		fn()
+		db.mu.Unlock()
+		return true
+	}
+}
+
+// coldVector rebuilds a cold record's vector from its stored elements and
+// the generator kept for it; callers hold db.mu
+func (db *InfiniteVectorIndex) coldVector(record ColdRecord) InfiniteVector {
+	elements := record.Elements
+	generator := db.tier.cold[record.ID].generator
+	return InfiniteVector{
+		elements: append([]float64(nil), elements...),
+		Generator: func(dim int) float64 {
+			if dim < len(elements) {
+				return elements[dim]
+			}
+			if generator == nil {
+				return 0
+			}
+			return generator(dim)
+		},
+	}
+}
+
+// snapshot returns a copy of the elements materialized for v, generating
+// at least n of them
+func (v *InfiniteVector) snapshot(n int) []float64 {
+	if n > 0 {
+		v.GetElement(n - 1)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.shared != nil {
+		v.elements = v.shared.load(v.elements, math.MaxInt32)
+	}
+	return append([]float64(nil), v.elements...)
+}
+
+// materialized returns the number of elements v holds
+func (v *InfiniteVector) materialized() int {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return len(v.elements)
+}
+
+// coldDatabaseRecord rebuilds a cold record; callers hold db.mu
+func (db *InfiniteVectorIndex) coldDatabaseRecord(record ColdRecord) DatabaseRecord {
+	return DatabaseRecord{ID: record.ID, Metadata: record.Metadata, Vector: db.coldVector(record)}
+}
+
+// dropCold deletes a record from the cold store, reporting whether it was
+// there; callers hold db.mu
+func (db *InfiniteVectorIndex) dropCold(id string) bool {
+	if _, cold := db.tier.cold[id]; !cold {
+		return false
+	}
+	if _, err := db.tier.store.Delete(id); err != nil {
+		db.tier.errors.Add(1)
+		return false
+	}
+	delete(db.tier.cold, id)
+	db.recordRemoved(id)
+	return true
+}
+
+// scanCold appends the cold records matching keep, until it reports there
+// should be no more; callers hold db.mu
+func (db *InfiniteVectorIndex) scanCold(results []DatabaseRecord, match func(ColdRecord) (keep, more bool)) []DatabaseRecord {
+	if db.tier == nil || len(db.tier.cold) == 0 {
+		return results
+	}
+	err := db.tier.store.Scan(func(record ColdRecord) bool {
+		keep, more := match(record)
+		if keep {
+			results = append(results, db.coldDatabaseRecord(record))
+		}
+		return more
+	})
+	if err != nil {
+		db.tier.errors.Add(1)
+	}
+	return results
+}
@@ -0,0 +1,83 @@
+package vectors
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// ScoredRecord is a record with its similarity to a query
+type ScoredRecord struct {
+	DatabaseRecord
+	Similarity float64
+}
+
+// TopK returns the k in-memory records most similar to queryVector over
+// maxDimensions, most similar first; ties are ordered by ID. Unlike
+// AdvancedQuery it needs no threshold and keeps only k records while
+// scanning.
+func (db *InfiniteVectorIndex) TopK(queryVector *InfiniteVector, k, maxDimensions int) []ScoredRecord {
+	if k <= 0 {
+		return nil
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	best := make(scoredHeap, 0, min(k, len(db.vectorSpace)))
+	for id, vector := range db.vectorSpace {
+		score := similarity(queryVector, vector, maxDimensions)
+		if len(best) == k && !best.worse(best[0].id, best[0].similarity, id, score) {
+			continue
+		}
+		scored := scoredVector{id: id, vector: vector, similarity: score}
+		if len(best) < k {
+			heap.Push(&best, scored)
+		} else {
+			best[0] = scored
+			heap.Fix(&best, 0)
+		}
+	}
+
+	sort.Slice(best, func(i, j int) bool {
+		return best.worse(best[j].id, best[j].similarity, best[i].id, best[i].similarity)
+	})
+	results := make([]ScoredRecord, len(best))
+	for i, scored := range best {
+		results[i] = ScoredRecord{
+			DatabaseRecord: DatabaseRecord{ID: scored.id, Metadata: db.metadataStore[scored.id], Vector: scored.vector.Copy()},
+			Similarity:     scored.similarity,
+		}
+	}
+	return results
+}
+
+// scoredVector is a hot record's vector with its similarity to a query
+type scoredVector struct {
+	id         string
+	vector     *InfiniteVector
+	similarity float64
+}
+
+// scoredHeap is a min-heap of scored vectors, the least similar on top
+type scoredHeap []scoredVector
+
+// worse reports whether record a ranks below record b
+func (scoredHeap) worse(aID string, a float64, bID string, b float64) bool {
+	if a != b {
+		return a < b
+	}
+	return aID > bID
+}
+
+func (h scoredHeap) Len() int { return len(h) }
+func (h scoredHeap) Less(i, j int) bool {
+	return h.worse(h[i].id, h[i].similarity, h[j].id, h[j].similarity)
+}
+func (h scoredHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *scoredHeap) Push(x interface{}) { *h = append(*h, x.(scoredVector)) }
+func (h *scoredHeap) Pop() interface{} {
+	old := *h
+	scored := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return scored
+}
@@ -0,0 +1,126 @@
+package vectors
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// topKVector varies in its first three dimensions and is flat after them
+func topKVector(seed float64) InfiniteVector {
+	return InfiniteVector{Generator: func(dim int) float64 {
+		if dim < 3 {
+			return math.Sin(seed + float64(dim)*2)
+		}
+		return 0
+	}}
+}
+
+func TestIndexTopK(t *testing.T) {
+	index := NewInfiniteVectorIndex()
+	query := topKVector(0)
+	for id, seed := range map[string]float64{"far": 3, "near": 0.1, "exact": 0, "twin": 0, "mid": 1} {
+		require.NoError(t, index.Insert(DatabaseRecord{ID: id, Vector: topKVector(seed)}))
+	}
+
+	top := index.TopK(&query, 3, 3)
+	require.Len(t, top, 3)
+	assert.Equal(t, []string{"exact", "twin", "near"}, []string{top[0].ID, top[1].ID, top[2].ID}, "ties are ordered by ID")
+	assert.InDelta(t, 1.0, top[0].Similarity, 1e-9)
+	assert.GreaterOrEqual(t, top[1].Similarity, top[2].Similarity)
+
+	assert.Len(t, index.TopK(&query, 10, 3), 5)
+	assert.Empty(t, index.TopK(&query, 0, 3))
+}
+
+// Index benchmarks run at each size and dimension count, skipping those
+// holding more than maxIndexBenchElements materialized elements. -short
+// skips the million-record sizes. Compare runs with benchstat.
+var (
+	indexBenchSizes = []int{1_000, 100_000, 1_000_000}
+	indexBenchDims  = []int{16, 64, 256}
+)
+
+const maxIndexBenchElements = 1 << 25
+
+// indexBenchVector has pseudo-random elements, materialized to dims as the
+// compared dimensions of stored vectors are
+func indexBenchVector(seed, dims int) InfiniteVector {
+	generator := func(dim int) float64 {
+		return math.Sin(float64(seed)*12.9898 + float64(dim)*78.233)
+	}
+	elements := make([]float64, dims)
+	for d := range elements {
+		elements[d] = generator(d)
+	}
+	return InfiniteVector{elements: elements, Generator: generator}
+}
+
+func fillIndexBench(b *testing.B, records, dims int) *InfiniteVectorIndex {
+	index := NewInfiniteVectorIndex()
+	for i := 0; i < records; i++ {
+		require.NoError(b, index.Insert(DatabaseRecord{ID: fmt.Sprintf("record-%d", i), Vector: indexBenchVector(i, dims)}))
+	}
+	return index
+}
+
+// indexBench runs bench for each size and dimension count with an index
+// holding that many records
+func indexBench(b *testing.B, bench func(b *testing.B, index *InfiniteVectorIndex, dims int)) {
+	for _, records := range indexBenchSizes {
+		for _, dims := range indexBenchDims {
+			if records*dims > maxIndexBenchElements {
+				continue
+			}
+			b.Run(fmt.Sprintf("records=%d/dims=%d", records, dims), func(b *testing.B) {
+				if records >= 1_000_000 && testing.Short() {
+					b.Skip("million-record index skipped in short mode")
+				}
+				index := fillIndexBench(b, records, dims)
+				b.ReportAllocs()
+				b.ResetTimer()
+				bench(b, index, dims)
+			})
+		}
+	}
+}
+
+// BenchmarkIndexInsert inserts records into an index already holding the
+// size's records
+func BenchmarkIndexInsert(b *testing.B) {
+	indexBench(b, func(b *testing.B, index *InfiniteVectorIndex, dims int) {
+		b.StopTimer()
+		inserted := make([]DatabaseRecord, b.N)
+		for i := range inserted {
+			inserted[i] = DatabaseRecord{ID: fmt.Sprintf("inserted-%d", i), Vector: indexBenchVector(-i, dims)}
+		}
+		b.StartTimer()
+		for i := range inserted {
+			index.Insert(inserted[i])
+		}
+	})
+}
+
+// BenchmarkIndexAdvancedQuery scans for records similar to a query over the
+// compared dimensions, without the query cache
+func BenchmarkIndexAdvancedQuery(b *testing.B) {
+	indexBench(b, func(b *testing.B, index *InfiniteVectorIndex, dims int) {
+		query := indexBenchVector(-1, dims)
+		for i := 0; i < b.N; i++ {
+			index.AdvancedQuery(0.5, query, dims)
+		}
+	})
+}
+
+// BenchmarkIndexTopK finds the ten records most similar to a query
+func BenchmarkIndexTopK(b *testing.B) {
+	indexBench(b, func(b *testing.B, index *InfiniteVectorIndex, dims int) {
+		query := indexBenchVector(-1, dims)
+		for i := 0; i < b.N; i++ {
+			index.TopK(&query, 10, dims)
+		}
+	})
+}